@@ -6,6 +6,9 @@ import (
 	"strings"
 
 	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/kyc"
+	"github.com/bvnk/bank/money"
+	"github.com/bvnk/bank/products"
 	"github.com/shopspring/decimal"
 )
 
@@ -50,6 +53,9 @@ Accounts (acmt) transactions are as follows:
 1004 - RemoveAccountPushToken
 1005 - SearchForAccount
 1006 - RetrieveAccount
+1007 - SetOverdraft
+1008 - AccrueOverdraftCharges
+1009 - ChargeMonthlyFees
 
 ## Merchant accounts
 1100 - MerchantAccountCreate
@@ -71,7 +77,12 @@ Accounts (acmt) transactions are as follows:
    AccountHolderAddressLine1~
    AccountHolderAddressLine2~
    AccountHolderAddressLine3~
-   AccountHolderPostalCode
+   AccountHolderPostalCode~
+   AccountType~
+   Currency
+
+Currency is the ISO 4217 code the account is held in, USD when empty. Balances, overdrafts and
+every payment in or out of the account are in that currency.
 */
 type AccountHolder struct {
 	AccountNumber string
@@ -102,13 +113,34 @@ type AccountDetails struct {
 	AccountNumber     string
 	BankNumber        string
 	AccountHolderName string
-	AccountBalance    decimal.Decimal
-	Overdraft         decimal.Decimal
-	AvailableBalance  decimal.Decimal
+	AccountBalance    money.Money
+	Overdraft         money.Money
+	AvailableBalance  money.Money
+	Currency          string
 	Type              string
+	Status            string
 	Timestamp         int
 }
 
+// inCurrency puts the balances read from the database in the account's currency, rounded to its
+// minor units
+func (accountDetails *AccountDetails) inCurrency() {
+	accountDetails.AccountBalance = accountDetails.AccountBalance.In(accountDetails.Currency)
+	accountDetails.Overdraft = accountDetails.Overdraft.In(accountDetails.Currency)
+	accountDetails.AvailableBalance = accountDetails.AvailableBalance.In(accountDetails.Currency)
+}
+
+// AccountClosure is the outcome of closing an account (acmt.019). Any remaining balance is
+// swept to another account in the transaction with ID TransactionID
+type AccountClosure struct {
+	AccountNumber      string
+	SweepAccountNumber string
+	SweptAmount        money.Money
+	Penalty            money.Money
+	TransactionID      int64
+	Timestamp          int32
+}
+
 type MerchantDetails struct {
 	ID                   string
 	Name                 string
@@ -133,8 +165,24 @@ type MerchantDetails struct {
 // Set up some defaults
 const (
 	BANK_NUMBER       = "a0299975-b8e2-4358-8f1a-911ee12dbaac"
-	OPENING_BALANCE   = 100.
-	OPENING_OVERDRAFT = 0.
+	OPENING_OVERDRAFT = 0
+)
+
+// bankNumber is the configured bank number new accounts are opened under, BANK_NUMBER when none
+// is configured
+func bankNumber() string {
+	if Config.BankNumber != "" {
+		return Config.BankNumber
+	}
+	return BANK_NUMBER
+}
+
+// Account statuses. Closed accounts are kept so their history stays resolvable. Restricted
+// accounts belong to holders who are not verified yet and cannot make payments
+const (
+	ACCOUNT_RESTRICTED = "restricted"
+	ACCOUNT_OPEN       = "open"
+	ACCOUNT_CLOSED     = "closed"
 )
 
 func ProcessAccount(data []string) (result interface{}, err error) {
@@ -159,6 +207,41 @@ func ProcessAccount(data []string) (result interface{}, err error) {
 			return "", errors.New("accounts.ProcessAccount: " + err.Error())
 		}
 		break
+	case 3:
+		// acmt~3~contactNumber1~contactNumber2~emailAddress~addressLine1~addressLine2~addressLine3~postalCode~password
+		result, err = modifyAccountHolder(data)
+		if err != nil {
+			return "", errors.New("accounts.ProcessAccount: " + err.Error())
+		}
+		break
+	case 13:
+		// acmt~13~accountType~format
+		result, err = accountReport(data)
+		if err != nil {
+			return "", errors.New("accounts.ProcessAccount: " + err.Error())
+		}
+		break
+	case 19:
+		// acmt~19~accountNumber~sweepAccountNumber
+		result, err = closeAccount(data)
+		if err != nil {
+			return "", errors.New("accounts.ProcessAccount: " + err.Error())
+		}
+		break
+	case 23:
+		// acmt~23
+		result, err = verifyAccountHolder(data)
+		if err != nil {
+			return "", errors.New("accounts.ProcessAccount: " + err.Error())
+		}
+		break
+	case 24:
+		// acmt~24
+		result, err = identificationVerificationReport(data)
+		if err != nil {
+			return "", errors.New("accounts.ProcessAccount: " + err.Error())
+		}
+		break
 	case 1001:
 		result, err = fetchUserAccounts(data)
 		if err != nil {
@@ -212,6 +295,27 @@ func ProcessAccount(data []string) (result interface{}, err error) {
 		if err != nil {
 			return "", errors.New("accounts.ProcessAccount: " + err.Error())
 		}
+	case 1007:
+		// acmt~1007~accountNumber~limit~rate~dailyFee
+		result, err = setOverdraft(data)
+		if err != nil {
+			return "", errors.New("accounts.ProcessAccount: " + err.Error())
+		}
+		break
+	case 1008:
+		// acmt~1008~date
+		result, err = accrueOverdraftCharges(data)
+		if err != nil {
+			return "", errors.New("accounts.ProcessAccount: " + err.Error())
+		}
+		break
+	case 1009:
+		// acmt~1009~month
+		result, err = chargeMonthlyFees(data)
+		if err != nil {
+			return "", errors.New("accounts.ProcessAccount: " + err.Error())
+		}
+		break
 	// Merchant account create
 	case 1100:
 		if len(data) < 19 {
@@ -277,7 +381,7 @@ func openAccount(data []string) (result interface{}, err error) {
 		return
 	}
 
-	// Test: acmt~1~Kyle~Redelinghuys~19000101~190001011234098~1112223456~~email@domain.com~Physical Address 1~~~1000
+	// Test: acmt~1~Kyle~Redelinghuys~01011900~190001011234098~1112223456~~email@domain.com~Physical Address 1~~~1000
 	// @FIXME: Remove new line from data
 //	data[len(data)-1] = strings.Replace(data[len(data)-1], "\n", "", -1)
 
@@ -290,6 +394,14 @@ func openAccount(data []string) (result interface{}, err error) {
 	if err != nil {
 		return "", errors.New("accounts.openAccount: " + err.Error())
 	}
+	product, err := products.GetProduct(accountHolderObject.Type)
+	if err != nil {
+		return "", errors.New("accounts.openAccount: " + err.Error())
+	}
+	err = applyProduct(&accountHolderObject, product, products.HOLDER_INDIVIDUAL)
+	if err != nil {
+		return "", errors.New("accounts.openAccount: " + err.Error())
+	}
 	err = createAccount(&accountHolderObject, &accountHolderDetailsObject)
 	if err != nil {
 		return "", errors.New("accounts.openAccount: " + err.Error())
@@ -299,36 +411,81 @@ func openAccount(data []string) (result interface{}, err error) {
 	return
 }
 
+// closeAccount closes an account held by the token user (AccountClosingRequestV02). The account
+// must have nothing in flight, and any remaining balance is swept to the nominated account at
+// this bank, which must be one of the holder's own while the account is restricted. Term
+// accounts closed before they mature pay the early withdrawal penalty out of the balance. The
+// sweep account may be left empty when the balance is zero
 func closeAccount(data []string) (result interface{}, err error) {
 	// Validate string against required info/length
-	if len(data) < 15 {
+	if len(data) < 5 {
 		err = errors.New("accounts.closeAccount: Not all fields present")
 		return
 	}
 
-	// Check if account already exists, check on ID number
-	accountHolder, _ := getAccountUser(data[6])
-	if accountHolder == (AccountHolderDetails{}) {
-		return "", errors.New("accounts.closeAccount: Account does not exist.")
+	accountNumber := data[3]
+	sweepAccountNumber := strings.TrimRight(data[4], "\x00")
+	if accountNumber == "" {
+		return "", errors.New("accounts.closeAccount: Account number missing")
+	}
+	if accountNumber == sweepAccountNumber {
+		return "", errors.New("accounts.closeAccount: Cannot sweep the balance to the account being closed")
 	}
 
-	// @FIXME: Remove new line from data
-	data[len(data)-1] = strings.Replace(data[len(data)-1], "\n", "", -1)
-
-	// Delete account
-	accountHolderObject, err := setAccountDetails(data)
+	// Check if account holder valid
+	tokenUser, err := appauth.GetUserFromToken(data[0])
 	if err != nil {
 		return "", errors.New("accounts.closeAccount: " + err.Error())
 	}
-	accountHolderDetailsObject, err := setAccountHolderDetails(data)
+	err = CheckUserAccountValidFromToken(tokenUser, accountNumber)
 	if err != nil {
-		return "", errors.New("accounts.closeAccount: " + err.Error())
+		return "", errors.New("accounts.closeAccount: Account holder not valid")
 	}
-	err = deleteAccount(&accountHolderObject, &accountHolderDetailsObject)
+
+	// Restricted accounts can only sweep to their holder's own accounts
+	sweepAccountHeld := sweepAccountNumber != "" && CheckUserAccountValidFromToken(tokenUser, sweepAccountNumber) == nil
+
+	closure, err := doCloseAccount(accountNumber, sweepAccountNumber, sweepAccountHeld)
 	if err != nil {
 		return "", errors.New("accounts.closeAccount: " + err.Error())
 	}
 
+	result = closure
+	return
+}
+
+// checkAccountClosable returns why an account cannot be closed yet, given its locked details and
+// the number of payments and mandates still in flight
+func checkAccountClosable(account AccountDetails, pendingItems int) (err error) {
+	if account.Status == ACCOUNT_CLOSED {
+		return errors.New("accounts.checkAccountClosable: Account already closed")
+	}
+	if pendingItems > 0 {
+		return errors.New("accounts.checkAccountClosable: Account has " + strconv.Itoa(pendingItems) + " pending items")
+	}
+	// The available balance is the balance plus the overdraft limit, unless funds are held
+	if account.AvailableBalance.Cmp(account.AccountBalance.Add(account.Overdraft)) != 0 {
+		return errors.New("accounts.checkAccountClosable: Account has funds on hold")
+	}
+	if account.AccountBalance.Sign() < 0 {
+		return errors.New("accounts.checkAccountClosable: Account is overdrawn")
+	}
+	return
+}
+
+// checkSweepAccount returns why the balance of an account being closed cannot be swept to the
+// sweep account. Holders who are not verified cannot make payments, so their restricted accounts
+// can only be swept to another account they hold
+func checkSweepAccount(account AccountDetails, sweepAccount AccountDetails, sweepAccountHeld bool) (err error) {
+	if sweepAccount.Status == ACCOUNT_CLOSED {
+		return errors.New("accounts.checkSweepAccount: Sweep account is closed")
+	}
+	if sweepAccount.Currency != account.Currency {
+		return errors.New("accounts.checkSweepAccount: Sweep account must be in the account's currency " + account.Currency)
+	}
+	if account.Status == ACCOUNT_RESTRICTED && !sweepAccountHeld {
+		return errors.New("accounts.checkSweepAccount: Restricted accounts can only be swept to the holder's own accounts")
+	}
 	return
 }
 
@@ -343,22 +500,30 @@ func setAccountDetails(data []string) (accountDetails AccountDetails, err error)
 	if data[3] == "" {
 		return AccountDetails{}, errors.New("accounts.setAccountDetails: Given name cannot be empty")
 	}
-	accountDetails.BankNumber = BANK_NUMBER
+	currency := ""
+	if len(data) > 15 {
+		currency = strings.TrimRight(data[15], "\x00")
+	}
+	accountDetails.Currency, err = money.ParseCurrency(currency)
+	if err != nil {
+		return AccountDetails{}, errors.New("accounts.setAccountDetails: " + err.Error())
+	}
+	accountDetails.BankNumber = bankNumber()
 	accountDetails.AccountHolderName = data[4] + "," + data[3] // Family Name, Given Name
-	accountDetails.AccountBalance = decimal.NewFromFloat(OPENING_BALANCE)
-	accountDetails.Overdraft = decimal.NewFromFloat(OPENING_OVERDRAFT)
-	accountDetails.AvailableBalance = decimal.NewFromFloat(OPENING_BALANCE + OPENING_OVERDRAFT)
+	// The opening balance comes from the product, see applyProduct
+	accountDetails.AccountBalance = money.Zero(accountDetails.Currency)
+	accountDetails.Overdraft = money.New(decimal.New(OPENING_OVERDRAFT, 0), accountDetails.Currency)
+	accountDetails.AvailableBalance = accountDetails.AccountBalance.Add(accountDetails.Overdraft)
 	// Get account type
 	accountType := data[14]
 	switch accountType {
 	case "":
 		accountType = "cheque" // Default to chequing account
 		break
-	case "savings", "cheque", "merchant", "money-market", "cd", "ira", "rcp", "credit", "mortgage", "loan":
-		// Valid
-		break
 	default:
-		return AccountDetails{}, errors.New("accounts.setAccountDetails: Account type not valid, must be one of savings, cheque, merchant, money-market, cd, ira, rcp, credit, mortgage, loan")
+		if !products.ValidCode(accountType) {
+			return AccountDetails{}, errors.New("accounts.setAccountDetails: Account type not valid, must be a product code")
+		}
 		break
 	}
 	accountDetails.Type = accountType
@@ -366,11 +531,26 @@ func setAccountDetails(data []string) (accountDetails AccountDetails, err error)
 	return
 }
 
+// applyProduct checks the holder can open an account of the product in the account's currency
+// and credits the product's opening balance
+func applyProduct(accountDetails *AccountDetails, product products.Product, holder string) (err error) {
+	err = product.CheckOpening(holder)
+	if err != nil {
+		return errors.New("accounts.applyProduct: " + err.Error())
+	}
+	err = product.CheckCurrency(accountDetails.Currency)
+	if err != nil {
+		return errors.New("accounts.applyProduct: " + err.Error())
+	}
+	accountDetails.AccountBalance = product.OpeningBalance.In(accountDetails.Currency)
+	accountDetails.AvailableBalance = accountDetails.AccountBalance.Add(accountDetails.Overdraft)
+	return
+}
+
 func setAccountHolderDetails(data []string) (accountHolderDetails AccountHolderDetails, err error) {
 	if len(data) < 12 {
 		return AccountHolderDetails{}, errors.New("accounts.setAccountHolderDetails: Not all field values present")
 	}
-	//@TODO: Test date parsing in format ddmmyyyy
 	if data[4] == "" {
 		return AccountHolderDetails{}, errors.New("accounts.setAccountHolderDetails: Family name cannot be empty")
 	}
@@ -378,7 +558,15 @@ func setAccountHolderDetails(data []string) (accountHolderDetails AccountHolderD
 		return AccountHolderDetails{}, errors.New("accounts.setAccountHolderDetails: Given name cannot be empty")
 	}
 
-	// @TODO Integrity checks
+	err = kyc.ValidateDateOfBirth(data[5])
+	if err != nil {
+		return AccountHolderDetails{}, errors.New("accounts.setAccountHolderDetails: " + err.Error())
+	}
+	err = kyc.ValidateIdentificationNumber(data[6])
+	if err != nil {
+		return AccountHolderDetails{}, errors.New("accounts.setAccountHolderDetails: " + err.Error())
+	}
+
 	accountHolderDetails.GivenName = data[3]
 	accountHolderDetails.FamilyName = data[4]
 	accountHolderDetails.DateOfBirth = data[5]
@@ -540,6 +728,21 @@ func CheckUserAccountValidFromToken(userID string, accountNumber string) (err er
 	return
 }
 
+// CheckMerchantAccountValidFromToken checks that the token user holds the account and that the
+// account is a merchant account, returning the merchant it belongs to
+func CheckMerchantAccountValidFromToken(userID string, accountNumber string) (merchantID string, err error) {
+	err = CheckUserAccountValidFromToken(userID, accountNumber)
+	if err != nil {
+		return "", errors.New("accounts.CheckMerchantAccountValidFromToken: " + err.Error())
+	}
+
+	merchantID, err = getMerchantIDFromAccountNumber(accountNumber)
+	if err != nil {
+		return "", errors.New("accounts.CheckMerchantAccountValidFromToken: " + err.Error())
+	}
+	return
+}
+
 func merchantAccountCreate(data []string) (result interface{}, err error) {
 	tokenUser, err := appauth.GetUserFromToken(data[0])
 	if err != nil {
@@ -556,6 +759,14 @@ func merchantAccountCreate(data []string) (result interface{}, err error) {
 	if err != nil {
 		return "", errors.New("accounts.merchantAccountCreate: " + err.Error())
 	}
+	product, err := products.GetProduct(accountDetails.Type)
+	if err != nil {
+		return "", errors.New("accounts.merchantAccountCreate: " + err.Error())
+	}
+	err = applyProduct(&accountDetails, product, products.HOLDER_MERCHANT)
+	if err != nil {
+		return "", errors.New("accounts.merchantAccountCreate: " + err.Error())
+	}
 
 	err = createMerchantAccount(&merchantObject, &accountDetails, &accountHolder)
 	if err != nil {
@@ -730,11 +941,20 @@ func setMerchantDetails(data []string, identificationNumber string, setType stri
 	// @FIXME We leave logo out for now, not sure how to parse pictures
 	merchantDetails.IdentificationNumber = identificationNumber
 
-	accountDetails.BankNumber = BANK_NUMBER
+	currency := ""
+	if setType == "create" && len(data) > 19 {
+		currency = strings.TrimRight(data[19], "\x00")
+	}
+	accountDetails.Currency, err = money.ParseCurrency(currency)
+	if err != nil {
+		return MerchantDetails{}, AccountDetails{}, errors.New("accounts.setMerchantDetails: " + err.Error())
+	}
+	accountDetails.BankNumber = bankNumber()
 	accountDetails.AccountHolderName = data[3] // Business Name
-	accountDetails.AccountBalance = decimal.NewFromFloat(OPENING_BALANCE)
-	accountDetails.Overdraft = decimal.NewFromFloat(OPENING_OVERDRAFT)
-	accountDetails.AvailableBalance = decimal.NewFromFloat(OPENING_BALANCE + OPENING_OVERDRAFT)
+	// The opening balance comes from the product, see applyProduct
+	accountDetails.AccountBalance = money.Zero(accountDetails.Currency)
+	accountDetails.Overdraft = money.New(decimal.New(OPENING_OVERDRAFT, 0), accountDetails.Currency)
+	accountDetails.AvailableBalance = accountDetails.AccountBalance.Add(accountDetails.Overdraft)
 
 	if setType == "create" {
 		accountType := data[18]
@@ -742,11 +962,10 @@ func setMerchantDetails(data []string, identificationNumber string, setType stri
 		case "":
 			accountType = "merchant" // Default to merchant account
 			break
-		case "merchant", "credit", "mortgage", "loan":
-			// Valid
-			break
 		default:
-			return MerchantDetails{}, AccountDetails{}, errors.New("accounts.setMerchantDetails: Account type not valid for a merchant, must be one of merchant, credit, mortgage, loan")
+			if !products.ValidCode(accountType) {
+				return MerchantDetails{}, AccountDetails{}, errors.New("accounts.setMerchantDetails: Account type not valid, must be a product code")
+			}
 			break
 		}
 		accountDetails.Type = accountType
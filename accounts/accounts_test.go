@@ -1,9 +1,14 @@
 package accounts
 
 import (
+	"database/sql"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/bvnk/bank/money"
+	"github.com/bvnk/bank/products"
 	"github.com/shopspring/decimal"
 )
 
@@ -80,21 +85,76 @@ func TestSetAccountDetails(t *testing.T) {
 		t.Errorf("SetAccountDetails does not pass. TYPE. Looking for %v, got %v", "accounts.AccountDetails", reflect.TypeOf(accountDetails).String())
 	}
 
-	if !accountDetails.Overdraft.Equals(decimal.NewFromFloat(OPENING_OVERDRAFT)) {
-		t.Errorf("SetAccountDetails does not pass. DETAILS. Looking for %v, got %v", decimal.NewFromFloat(OPENING_OVERDRAFT), accountDetails.Overdraft)
+	if !accountDetails.Overdraft.Amount.Equals(decimal.New(OPENING_OVERDRAFT, 0)) {
+		t.Errorf("SetAccountDetails does not pass. DETAILS. Looking for %v, got %v", decimal.New(OPENING_OVERDRAFT, 0), accountDetails.Overdraft)
 	}
 
-	if !accountDetails.AccountBalance.Equals(decimal.NewFromFloat(OPENING_BALANCE)) {
-		t.Errorf("SetAccountDetails does not pass. DETAILS. Looking for %v, got %v", decimal.NewFromFloat(OPENING_BALANCE), accountDetails.AccountBalance)
+	if !accountDetails.AccountBalance.IsZero() {
+		t.Errorf("SetAccountDetails does not pass. DETAILS. Looking for %v, got %v", 0, accountDetails.AccountBalance)
 	}
 
-	if !accountDetails.AvailableBalance.Equals(decimal.NewFromFloat(OPENING_BALANCE + OPENING_OVERDRAFT)) {
-		t.Errorf("SetAccountDetails does not pass. DETAILS. Looking for %v, got %v", decimal.NewFromFloat(OPENING_BALANCE+OPENING_OVERDRAFT), accountDetails.AvailableBalance)
+	if !accountDetails.AvailableBalance.Amount.Equals(decimal.New(OPENING_OVERDRAFT, 0)) {
+		t.Errorf("SetAccountDetails does not pass. DETAILS. Looking for %v, got %v", decimal.New(OPENING_OVERDRAFT, 0), accountDetails.AvailableBalance)
 	}
 
 	if accountDetails.AccountHolderName != "Doe,John" {
 		t.Errorf("SetAccountDetails does not pass. DETAILS. Looking for %v, got %v", "Doe,John", accountDetails.AccountHolderName)
 	}
+
+	if accountDetails.Currency != money.DEFAULT_CURRENCY {
+		t.Errorf("SetAccountDetails does not pass. CURRENCY. Looking for %v, got %v", money.DEFAULT_CURRENCY, accountDetails.Currency)
+	}
+
+	accountDetails, err = setAccountDetails(append(tst, "eur"))
+	if err != nil || accountDetails.Currency != "EUR" || accountDetails.AccountBalance.Currency != "EUR" {
+		t.Errorf("SetAccountDetails currency does not pass. Looking for %v, got %v %v", "EUR", accountDetails.Currency, err)
+	}
+
+	_, err = setAccountDetails(append(tst, "XYZ"))
+	if err == nil {
+		t.Errorf("SetAccountDetails invalid currency does not pass. Looking for %v, got %v", "Currency not valid", nil)
+	}
+}
+
+func TestApplyProduct(t *testing.T) {
+	product := products.Product{
+		Code:           "savings",
+		Holders:        []string{products.HOLDER_INDIVIDUAL},
+		OpeningBalance: money.New(decimal.New(50, 0), money.DEFAULT_CURRENCY),
+		Currency:       money.DEFAULT_CURRENCY,
+		Status:         products.PRODUCT_ACTIVE,
+	}
+	accountDetails := AccountDetails{
+		Currency:       money.DEFAULT_CURRENCY,
+		AccountBalance: money.Zero(money.DEFAULT_CURRENCY),
+		Overdraft:      money.New(decimal.New(10, 0), money.DEFAULT_CURRENCY),
+	}
+
+	err := applyProduct(&accountDetails, product, products.HOLDER_INDIVIDUAL)
+	if err != nil {
+		t.Errorf("ApplyProduct does not pass. ERROR. Looking for %v, got %v", nil, err)
+	}
+	if accountDetails.AccountBalance.StringFixed() != "50.00" || accountDetails.AvailableBalance.StringFixed() != "60.00" {
+		t.Errorf("ApplyProduct does not pass. DETAILS. Looking for %v, got %v", "50.00 60.00", accountDetails.AccountBalance.StringFixed()+" "+accountDetails.AvailableBalance.StringFixed())
+	}
+
+	err = applyProduct(&accountDetails, product, products.HOLDER_MERCHANT)
+	if err == nil {
+		t.Errorf("ApplyProduct merchant does not pass. Looking for %v, got %v", "cannot be held by a merchant", nil)
+	}
+
+	accountDetails.Currency = "EUR"
+	err = applyProduct(&accountDetails, product, products.HOLDER_INDIVIDUAL)
+	if err == nil {
+		t.Errorf("ApplyProduct currency does not pass. Looking for %v, got %v", "only offered in USD", nil)
+	}
+	accountDetails.Currency = money.DEFAULT_CURRENCY
+
+	product.Status = products.PRODUCT_WITHDRAWN
+	err = applyProduct(&accountDetails, product, products.HOLDER_INDIVIDUAL)
+	if err == nil {
+		t.Errorf("ApplyProduct withdrawn does not pass. Looking for %v, got %v", "Product savings is withdrawn", nil)
+	}
 }
 
 func TestSetAccountHolderDetailsFailure(t *testing.T) {
@@ -210,3 +270,149 @@ func TestAddAccountPushTokenFunctional(t *testing.T) {
 
 }
 */
+
+func TestProcessAccountClose(t *testing.T) {
+	tst := []string{"", "", "19", "accountNumber"}
+	_, err := ProcessAccount(tst)
+
+	if err == nil {
+		t.Errorf("ProcessAccount close does not pass. Looking for %v, got %v", "Not all fields present", nil)
+	}
+
+	tst = []string{"", "", "19", "accountNumber", "accountNumber"}
+	_, err = ProcessAccount(tst)
+
+	if err == nil {
+		t.Errorf("ProcessAccount close does not pass. Looking for %v, got %v", "Cannot sweep the balance to the account being closed", nil)
+	}
+}
+
+func TestCheckAccountClosable(t *testing.T) {
+	balance := money.New(decimal.New(10, 0), money.DEFAULT_CURRENCY)
+	account := AccountDetails{AccountNumber: "accountNumber", AccountBalance: balance, AvailableBalance: balance, Status: ACCOUNT_OPEN}
+
+	err := checkAccountClosable(account, 0)
+	if err != nil {
+		t.Errorf("CheckAccountClosable does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	err = checkAccountClosable(account, 1)
+	if err == nil {
+		t.Errorf("CheckAccountClosable pending does not pass. Looking for %v, got %v", "Account has 1 pending items", nil)
+	}
+
+	onHold := account
+	onHold.AvailableBalance = money.Zero(money.DEFAULT_CURRENCY)
+	err = checkAccountClosable(onHold, 0)
+	if err == nil {
+		t.Errorf("CheckAccountClosable hold does not pass. Looking for %v, got %v", "Account has funds on hold", nil)
+	}
+
+	overdrawn := account
+	overdrawn.AccountBalance = balance.Neg()
+	overdrawn.AvailableBalance = balance.Neg()
+	err = checkAccountClosable(overdrawn, 0)
+	if err == nil {
+		t.Errorf("CheckAccountClosable overdrawn does not pass. Looking for %v, got %v", "Account is overdrawn", nil)
+	}
+
+	withOverdraft := account
+	withOverdraft.Overdraft = money.New(decimal.New(100, 0), money.DEFAULT_CURRENCY)
+	withOverdraft.AvailableBalance = balance.Add(withOverdraft.Overdraft)
+	err = checkAccountClosable(withOverdraft, 0)
+	if err != nil {
+		t.Errorf("CheckAccountClosable overdraft does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	closed := account
+	closed.Status = ACCOUNT_CLOSED
+	err = checkAccountClosable(closed, 0)
+	if err == nil {
+		t.Errorf("CheckAccountClosable closed does not pass. Looking for %v, got %v", "Account already closed", nil)
+	}
+}
+
+func TestCheckSweepAccount(t *testing.T) {
+	account := AccountDetails{AccountNumber: "accountNumber", Currency: money.DEFAULT_CURRENCY, Status: ACCOUNT_OPEN}
+	sweepAccount := AccountDetails{AccountNumber: "sweepAccountNumber", Currency: money.DEFAULT_CURRENCY, Status: ACCOUNT_OPEN}
+
+	err := checkSweepAccount(account, sweepAccount, false)
+	if err != nil {
+		t.Errorf("CheckSweepAccount does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	closed := sweepAccount
+	closed.Status = ACCOUNT_CLOSED
+	err = checkSweepAccount(account, closed, true)
+	if err == nil || !strings.Contains(err.Error(), "Sweep account is closed") {
+		t.Errorf("CheckSweepAccount closed does not pass. Looking for %v, got %v", "Sweep account is closed", err)
+	}
+
+	otherCurrency := sweepAccount
+	otherCurrency.Currency = "EUR"
+	err = checkSweepAccount(account, otherCurrency, true)
+	if err == nil || !strings.Contains(err.Error(), "Sweep account must be in the account's currency") {
+		t.Errorf("CheckSweepAccount currency does not pass. Looking for %v, got %v", "Sweep account must be in the account's currency", err)
+	}
+
+	// An unverified holder cannot pay their balance to someone else by closing the account
+	restricted := account
+	restricted.Status = ACCOUNT_RESTRICTED
+	err = checkSweepAccount(restricted, sweepAccount, false)
+	if err == nil || !strings.Contains(err.Error(), "Restricted accounts can only be swept to the holder's own accounts") {
+		t.Errorf("CheckSweepAccount restricted does not pass. Looking for %v, got %v", "Restricted accounts can only be swept to the holder's own accounts", err)
+	}
+
+	err = checkSweepAccount(restricted, sweepAccount, true)
+	if err != nil {
+		t.Errorf("CheckSweepAccount restricted own account does not pass. Looking for %v, got %v", nil, err)
+	}
+}
+
+func TestChargeClosurePenalty(t *testing.T) {
+	balance := money.New(decimal.New(10, 0), money.DEFAULT_CURRENCY)
+	account := AccountDetails{AccountNumber: "accountNumber", AccountBalance: balance, Currency: money.DEFAULT_CURRENCY}
+
+	SetEarlyWithdrawalPenalty(nil, nil)
+	_, err := doChargeClosurePenalty(nil, account, 0)
+	if err == nil || !strings.Contains(err.Error(), "Early withdrawal penalty not available") {
+		t.Errorf("ChargeClosurePenalty unset does not pass. Looking for %v, got %v", "Early withdrawal penalty not available", err)
+	}
+
+	var charged money.Money
+	penaltyOn := func(penalty money.Money) {
+		SetEarlyWithdrawalPenalty(func(tx *sql.Tx, accountNumber string, amount money.Money, now time.Time) (money.Money, error) {
+			return penalty, nil
+		}, func(tx *sql.Tx, accountNumber string, penalty money.Money, sqlTime int32) error {
+			charged = penalty
+			return nil
+		})
+	}
+	defer SetEarlyWithdrawalPenalty(nil, nil)
+
+	penaltyOn(money.New(decimal.New(2, 0), money.DEFAULT_CURRENCY))
+	penalty, err := doChargeClosurePenalty(nil, account, 0)
+	if err != nil || penalty.Amount.String() != "2" || charged.Amount.String() != "2" {
+		t.Errorf("ChargeClosurePenalty does not pass. Looking for %v, got %v %v %v", "2", penalty, charged, err)
+	}
+
+	// The penalty never takes the account below zero
+	penaltyOn(money.New(decimal.New(25, 0), money.DEFAULT_CURRENCY))
+	penalty, err = doChargeClosurePenalty(nil, account, 0)
+	if err != nil || penalty.Amount.String() != "10" || charged.Amount.String() != "10" {
+		t.Errorf("ChargeClosurePenalty capped does not pass. Looking for %v, got %v %v %v", "10", penalty, charged, err)
+	}
+}
+
+func TestSweepJournalEntry(t *testing.T) {
+	amount := money.New(decimal.New(1050, -2), money.DEFAULT_CURRENCY)
+	entry := sweepJournalEntry("accountNumber", "sweepAccountNumber", amount, 1, 0)
+
+	err := entry.Validate()
+	if err != nil {
+		t.Errorf("SweepJournalEntry does not pass. Looking for %v, got %v", nil, err)
+	}
+	if entry.Lines[0].LedgerAccount != "accountNumber" || entry.Lines[1].LedgerAccount != "sweepAccountNumber" {
+		t.Errorf("SweepJournalEntry does not pass. Looking for %v, got %v", "accountNumber to sweepAccountNumber", entry.Lines)
+	}
+}
@@ -10,7 +10,12 @@ import (
 	"time"
 
 	"github.com/bvnk/bank/configuration"
+	"github.com/bvnk/bank/kyc"
+	"github.com/bvnk/bank/ledger"
+	"github.com/bvnk/bank/money"
+	"github.com/bvnk/bank/products"
 	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
 )
 
 var Config configuration.Configuration
@@ -19,6 +24,18 @@ func SetConfig(config *configuration.Configuration) {
 	Config = *config
 }
 
+// The interest package works out and charges the penalty for withdrawing from a term account
+// before it matures. It imports this package, so it hands them over when the server starts
+var (
+	earlyWithdrawalPenalty       func(tx *sql.Tx, accountNumber string, amount money.Money, now time.Time) (penalty money.Money, err error)
+	chargeEarlyWithdrawalPenalty func(tx *sql.Tx, accountNumber string, penalty money.Money, sqlTime int32) (err error)
+)
+
+func SetEarlyWithdrawalPenalty(penalty func(tx *sql.Tx, accountNumber string, amount money.Money, now time.Time) (money.Money, error), charge func(tx *sql.Tx, accountNumber string, penalty money.Money, sqlTime int32) error) {
+	earlyWithdrawalPenalty = penalty
+	chargeEarlyWithdrawalPenalty = charge
+}
+
 func loadDatabase() (db *sql.DB, err error) {
 	// Test connection with ping
 	err = Config.Db.Ping()
@@ -34,48 +51,649 @@ func createAccount(accountDetails *AccountDetails, accountHolderDetails *Account
 	t := time.Now()
 	sqlTime := int32(t.Unix())
 
-	err = doCreateAccount(sqlTime, accountDetails, accountHolderDetails)
+	// Accounts of holders who are not verified yet open restricted
+	verificationStatus, err := getAccountUserVerificationStatus(accountHolderDetails.IdentificationNumber)
+	if err != nil {
+		return errors.New("accounts.createAccount: " + err.Error())
+	}
+	accountDetails.Status = accountStatusForHolder(verificationStatus)
+
+	// The account, its opening balance and its holder are created together or not at all
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return errors.New("accounts.createAccount: Could not start database transaction. " + err.Error())
+	}
+
+	err = doCreateAccount(tx, sqlTime, accountDetails, accountHolderDetails)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("accounts.createAccount: " + err.Error())
+	}
+
+	err = doPostOpeningBalance(tx, sqlTime, accountDetails)
 	if err != nil {
+		tx.Rollback()
 		return errors.New("accounts.createAccount: " + err.Error())
 	}
 
-	err = doCreateAccountUser(sqlTime, accountHolderDetails, accountDetails)
+	err = doCreateAccountUser(tx, sqlTime, accountHolderDetails, accountDetails)
 	if err != nil {
+		tx.Rollback()
 		return errors.New("accounts.createAccount: " + err.Error())
 	}
 
-	err = doCreateAccountUserAccount(sqlTime, accountHolderDetails, accountDetails)
+	err = doCreateAccountUserAccount(tx, sqlTime, accountHolderDetails, accountDetails)
 	if err != nil {
+		tx.Rollback()
 		return errors.New("accounts.createAccount: " + err.Error())
 	}
 
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("accounts.createAccount: Could not commit transaction. " + err.Error())
+	}
+
 	return
 }
 
-func deleteAccount(accountDetails *AccountDetails, accountHolderDetails *AccountHolderDetails) (err error) {
-	err = doDeleteAccount(accountDetails)
+// doCloseAccount sweeps the balance and marks the account closed in one database transaction.
+// Both account rows stay locked until it commits, so nothing can be posted to them in between.
+// sweepAccountHeld says whether the holder closing the account also holds the sweep account
+func doCloseAccount(accountNumber string, sweepAccountNumber string, sweepAccountHeld bool) (closure AccountClosure, err error) {
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return AccountClosure{}, errors.New("accounts.doCloseAccount: Could not start database transaction. " + err.Error())
+	}
+
+	account, err := getAccountDetailsForUpdate(tx, accountNumber)
+	if err != nil {
+		tx.Rollback()
+		return AccountClosure{}, errors.New("accounts.doCloseAccount: " + err.Error())
+	}
+
+	pendingItems, err := countPendingItems(tx, accountNumber)
+	if err != nil {
+		tx.Rollback()
+		return AccountClosure{}, errors.New("accounts.doCloseAccount: " + err.Error())
+	}
+
+	err = checkAccountClosable(account, pendingItems)
+	if err != nil {
+		tx.Rollback()
+		return AccountClosure{}, errors.New("accounts.doCloseAccount: " + err.Error())
+	}
+
+	sqlTime := int32(time.Now().Unix())
+	closure = AccountClosure{AccountNumber: accountNumber, SweptAmount: account.AccountBalance, Penalty: money.Zero(account.Currency), Timestamp: sqlTime}
+
+	if account.AccountBalance.Sign() > 0 {
+		if sweepAccountNumber == "" {
+			tx.Rollback()
+			return AccountClosure{}, errors.New("accounts.doCloseAccount: Sweep account needed for the remaining balance")
+		}
+
+		sweepAccount, err := getAccountDetailsForUpdate(tx, sweepAccountNumber)
+		if err != nil {
+			tx.Rollback()
+			return AccountClosure{}, errors.New("accounts.doCloseAccount: Sweep account not valid. " + err.Error())
+		}
+		err = checkSweepAccount(account, sweepAccount, sweepAccountHeld)
+		if err != nil {
+			tx.Rollback()
+			return AccountClosure{}, errors.New("accounts.doCloseAccount: " + err.Error())
+		}
+
+		// Closing a term account before it matures costs a penalty, taken from the balance
+		closure.Penalty, err = doChargeClosurePenalty(tx, account, sqlTime)
+		if err != nil {
+			tx.Rollback()
+			return AccountClosure{}, errors.New("accounts.doCloseAccount: " + err.Error())
+		}
+		account.AccountBalance = account.AccountBalance.Sub(closure.Penalty)
+		closure.SweptAmount = account.AccountBalance
+
+		closure.SweepAccountNumber = sweepAccountNumber
+		if account.AccountBalance.Sign() > 0 {
+			closure.TransactionID, err = doSweepBalance(tx, account, sweepAccount, sqlTime)
+			if err != nil {
+				tx.Rollback()
+				return AccountClosure{}, errors.New("accounts.doCloseAccount: " + err.Error())
+			}
+		}
+	}
+
+	err = doSetAccountClosed(tx, accountNumber, sqlTime)
+	if err != nil {
+		tx.Rollback()
+		return AccountClosure{}, errors.New("accounts.doCloseAccount: " + err.Error())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return AccountClosure{}, errors.New("accounts.doCloseAccount: Could not commit transaction. " + err.Error())
+	}
+
+	return
+}
+
+// getAccountDetailsForUpdate fetches an account and locks its row until tx ends
+func getAccountDetailsForUpdate(tx *sql.Tx, id string) (accountDetails AccountDetails, err error) {
+	rows, err := tx.Query("SELECT `accountNumber`, `bankNumber`, `accountHolderName`, `accountBalance`, `overdraft`, `availableBalance`, `currency`, `status` FROM `accounts` WHERE `accountNumber` = ? FOR UPDATE", id)
+	if err != nil {
+		return AccountDetails{}, errors.New("accounts.getAccountDetailsForUpdate: " + err.Error())
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		err := rows.Scan(&accountDetails.AccountNumber, &accountDetails.BankNumber, &accountDetails.AccountHolderName, &accountDetails.AccountBalance, &accountDetails.Overdraft, &accountDetails.AvailableBalance, &accountDetails.Currency, &accountDetails.Status)
+		if err != nil {
+			return AccountDetails{}, errors.New("accounts.getAccountDetailsForUpdate: Could not retrieve account details. " + err.Error())
+		}
+		count++
+	}
+
+	if count == 0 {
+		return AccountDetails{}, errors.New("accounts.getAccountDetailsForUpdate: Account not found")
+	}
+	accountDetails.inCurrency()
+
+	return
+}
+
+// countPendingItems counts the payments on the account that are not final yet, the mandates
+// that could still collect from or pay into it and the holds that could still be captured
+func countPendingItems(tx *sql.Tx, accountNumber string) (pendingItems int, err error) {
+	var transactions, mandates, holds int
+	err = tx.QueryRow("SELECT COUNT(*) FROM `transactions` WHERE (`senderAccountNumber` = ? OR `receiverAccountNumber` = ?) AND `status` IN ('received', 'pending', 'accepted')", accountNumber, accountNumber).Scan(&transactions)
+	if err != nil {
+		return 0, errors.New("accounts.countPendingItems: " + err.Error())
+	}
+
+	err = tx.QueryRow("SELECT COUNT(*) FROM `mandates` WHERE (`debtorAccountNumber` = ? OR `creditorAccountNumber` = ?) AND `status` IN ('pending', 'active')", accountNumber, accountNumber).Scan(&mandates)
+	if err != nil {
+		return 0, errors.New("accounts.countPendingItems: " + err.Error())
+	}
+
+	err = tx.QueryRow("SELECT COUNT(*) FROM `holds` WHERE (`accountNumber` = ? OR `payeeAccountNumber` = ?) AND `status` = 'active'", accountNumber, accountNumber).Scan(&holds)
+	if err != nil {
+		return 0, errors.New("accounts.countPendingItems: " + err.Error())
+	}
+
+	return transactions + mandates + holds, nil
+}
+
+// doChargeClosurePenalty charges the early withdrawal penalty on the balance of an account being
+// closed. The penalty is never more than the balance
+func doChargeClosurePenalty(tx *sql.Tx, account AccountDetails, sqlTime int32) (penalty money.Money, err error) {
+	if earlyWithdrawalPenalty == nil || chargeEarlyWithdrawalPenalty == nil {
+		return money.Money{}, errors.New("accounts.doChargeClosurePenalty: Early withdrawal penalty not available")
+	}
+
+	penalty, err = earlyWithdrawalPenalty(tx, account.AccountNumber, account.AccountBalance, time.Now())
+	if err != nil {
+		return money.Money{}, errors.New("accounts.doChargeClosurePenalty: " + err.Error())
+	}
+	if penalty.Cmp(account.AccountBalance) > 0 {
+		penalty = account.AccountBalance
+	}
+
+	err = chargeEarlyWithdrawalPenalty(tx, account.AccountNumber, penalty, sqlTime)
+	if err != nil {
+		return money.Money{}, errors.New("accounts.doChargeClosurePenalty: " + err.Error())
+	}
+
+	return
+}
+
+// doSweepBalance moves the whole balance of an account being closed to the sweep account. The
+// sweep is recorded as an acmt~19 transaction so it shows on both accounts' history
+func doSweepBalance(tx *sql.Tx, account AccountDetails, sweepAccount AccountDetails, sqlTime int32) (transactionID int64, err error) {
+	amount := account.AccountBalance
+	desc := "Account closure"
+
+	updateStatement := "UPDATE accounts SET `accountBalance` = (`accountBalance` + ?), `availableBalance` = (`availableBalance` + ?), `timestamp` = ? WHERE `accountNumber` = ? "
+	stmtUpd, err := tx.Prepare(updateStatement)
+	if err != nil {
+		return 0, errors.New("accounts.doSweepBalance: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	_, err = stmtUpd.Exec(amount.Neg(), amount.Neg(), sqlTime, account.AccountNumber)
+	if err != nil {
+		return 0, errors.New("accounts.doSweepBalance: " + err.Error())
+	}
+	_, err = stmtUpd.Exec(amount, amount, sqlTime, sweepAccount.AccountNumber)
+	if err != nil {
+		return 0, errors.New("accounts.doSweepBalance: " + err.Error())
+	}
+
+	insertStatement := "INSERT INTO transactions (`transaction`, `type`, `senderAccountNumber`, `senderBankNumber`, `receiverAccountNumber`, `receiverBankNumber`, `transactionAmount`, `currency`, `feeAmount`, `desc`, `timestamp`, `status`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	stmtIns, err := tx.Prepare(insertStatement)
+	if err != nil {
+		return 0, errors.New("accounts.doSweepBalance: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	res, err := stmtIns.Exec("acmt", 19, account.AccountNumber, "", sweepAccount.AccountNumber, "", amount, amount.Currency, 0, desc, sqlTime, "settled")
+	if err != nil {
+		return 0, errors.New("accounts.doSweepBalance: " + err.Error())
+	}
+	transactionID, err = res.LastInsertId()
+	if err != nil {
+		return 0, errors.New("accounts.doSweepBalance: Could not get transaction ID. " + err.Error())
+	}
+
+	stmtHistory, err := tx.Prepare("INSERT INTO transaction_status_history (`transactionID`, `fromStatus`, `toStatus`, `reasonCode`, `timestamp`) VALUES(?, ?, ?, ?, ?)")
+	if err != nil {
+		return 0, errors.New("accounts.doSweepBalance: " + err.Error())
+	}
+	defer stmtHistory.Close()
+
+	_, err = stmtHistory.Exec(transactionID, "", "settled", "", sqlTime)
+	if err != nil {
+		return 0, errors.New("accounts.doSweepBalance: " + err.Error())
+	}
+
+	_, err = ledger.PostJournal(tx, sweepJournalEntry(account.AccountNumber, sweepAccount.AccountNumber, amount, transactionID, sqlTime))
+	if err != nil {
+		return 0, errors.New("accounts.doSweepBalance: " + err.Error())
+	}
+
+	return
+}
+
+// sweepJournalEntry books the balance of a closed account over to the sweep account
+func sweepJournalEntry(accountNumber string, sweepAccountNumber string, amount money.Money, transactionID int64, sqlTime int32) (entry ledger.JournalEntry) {
+	entry = ledger.JournalEntry{TransactionID: transactionID, Desc: "acmt~19 Account closure", Timestamp: sqlTime}
+	entry.Lines = append(entry.Lines, ledger.Debit(accountNumber, amount.Amount)...)
+	entry.Lines = append(entry.Lines, ledger.Credit(sweepAccountNumber, amount.Amount)...)
+	return
+}
+
+func doSetAccountClosed(tx *sql.Tx, accountNumber string, sqlTime int32) (err error) {
+	stmtUpd, err := tx.Prepare("UPDATE accounts SET `status` = ?, `closedTimestamp` = ?, `timestamp` = ? WHERE `accountNumber` = ? AND `status` IN (?, ?)")
+	if err != nil {
+		return errors.New("accounts.doSetAccountClosed: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	res, err := stmtUpd.Exec(ACCOUNT_CLOSED, sqlTime, sqlTime, accountNumber, ACCOUNT_OPEN, ACCOUNT_RESTRICTED)
+	if err != nil {
+		return errors.New("accounts.doSetAccountClosed: " + err.Error())
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errors.New("accounts.doSetAccountClosed: Could not get rows affected. " + err.Error())
+	}
+	if affected != 1 {
+		return errors.New("accounts.doSetAccountClosed: Account not open")
+	}
+
+	return
+}
+
+// getOverdraftForUpdate locks the account and fetches its overdraft facility, status and type
+func getOverdraftForUpdate(tx *sql.Tx, accountNumber string) (facility OverdraftFacility, status string, accountType string, err error) {
+	rows, err := tx.Query("SELECT `accountNumber`, `overdraft`, `overdraftRate`, `overdraftDailyFee`, `availableBalance`, `currency`, `status`, `type` FROM `accounts` WHERE `accountNumber` = ? FOR UPDATE", accountNumber)
+	if err != nil {
+		return OverdraftFacility{}, "", "", errors.New("accounts.getOverdraftForUpdate: " + err.Error())
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		err := rows.Scan(&facility.AccountNumber, &facility.Limit, &facility.Rate, &facility.DailyFee, &facility.AvailableBalance, &facility.Currency, &status, &accountType)
+		if err != nil {
+			return OverdraftFacility{}, "", "", errors.New("accounts.getOverdraftForUpdate: Could not retrieve overdraft. " + err.Error())
+		}
+		count++
+	}
+
+	if count == 0 {
+		return OverdraftFacility{}, "", "", errors.New("accounts.getOverdraftForUpdate: Account not found")
+	}
+	facility.inCurrency()
+
+	return
+}
+
+// doSetOverdraft changes the overdraft limit and moves the available balance with it. A nil
+// rate or daily fee keeps the current one
+func doSetOverdraft(accountNumber string, limit money.Money, rate *decimal.Decimal, dailyFee *money.Money) (facility OverdraftFacility, err error) {
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return OverdraftFacility{}, errors.New("accounts.doSetOverdraft: Could not start database transaction. " + err.Error())
+	}
+
+	current, status, accountType, err := getOverdraftForUpdate(tx, accountNumber)
+	if err != nil {
+		tx.Rollback()
+		return OverdraftFacility{}, errors.New("accounts.doSetOverdraft: " + err.Error())
+	}
+	if status == ACCOUNT_CLOSED {
+		tx.Rollback()
+		return OverdraftFacility{}, errors.New("accounts.doSetOverdraft: Account is closed")
+	}
+
+	// The account's product decides whether it can be granted the limit
+	product, err := products.GetProduct(accountType)
+	if err != nil {
+		tx.Rollback()
+		return OverdraftFacility{}, errors.New("accounts.doSetOverdraft: " + err.Error())
+	}
+	if !limit.SameCurrency(current.Limit) || (dailyFee != nil && !dailyFee.SameCurrency(current.Limit)) {
+		tx.Rollback()
+		return OverdraftFacility{}, errors.New("accounts.doSetOverdraft: Overdraft must be in the account's currency " + current.Currency)
+	}
+	err = product.CheckOverdraft(limit)
+	if err != nil {
+		tx.Rollback()
+		return OverdraftFacility{}, errors.New("accounts.doSetOverdraft: " + err.Error())
+	}
+
+	sqlTime := int32(time.Now().Unix())
+	facility = current
+	facility.Limit = limit
+	facility.AvailableBalance = availableAfterLimitChange(current.AvailableBalance, current.Limit, limit)
+	facility.Timestamp = sqlTime
+	if rate != nil {
+		facility.Rate = *rate
+	}
+	if dailyFee != nil {
+		facility.DailyFee = *dailyFee
+	}
+
+	updateStatement := "UPDATE accounts SET `overdraft` = ?, `overdraftRate` = ?, `overdraftDailyFee` = ?, `availableBalance` = (`availableBalance` + ?), `timestamp` = ? WHERE `accountNumber` = ? "
+	stmtUpd, err := tx.Prepare(updateStatement)
+	if err != nil {
+		tx.Rollback()
+		return OverdraftFacility{}, errors.New("accounts.doSetOverdraft: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	_, err = stmtUpd.Exec(facility.Limit, facility.Rate.String(), facility.DailyFee, limit.Sub(current.Limit), sqlTime, accountNumber)
+	if err != nil {
+		tx.Rollback()
+		return OverdraftFacility{}, errors.New("accounts.doSetOverdraft: " + err.Error())
+	}
+
+	insertStatement := "INSERT INTO accounts_overdraft_history (`accountNumber`, `previousLimit`, `newLimit`, `rate`, `dailyFee`, `timestamp`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?)"
+	stmtIns, err := tx.Prepare(insertStatement)
+	if err != nil {
+		tx.Rollback()
+		return OverdraftFacility{}, errors.New("accounts.doSetOverdraft: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	_, err = stmtIns.Exec(accountNumber, current.Limit, facility.Limit, facility.Rate.String(), facility.DailyFee, sqlTime)
+	if err != nil {
+		tx.Rollback()
+		return OverdraftFacility{}, errors.New("accounts.doSetOverdraft: " + err.Error())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return OverdraftFacility{}, errors.New("accounts.doSetOverdraft: Could not commit transaction. " + err.Error())
+	}
+
+	return
+}
+
+// getChargeableOverdrafts lists the facilities of accounts that are not closed and are charged
+// for being overdrawn
+func getChargeableOverdrafts() (facilities []OverdraftFacility, err error) {
+	rows, err := Config.Db.Query("SELECT `accountNumber`, `overdraft`, `overdraftRate`, `overdraftDailyFee`, `availableBalance`, `currency` FROM `accounts` WHERE `status` != ? AND (`overdraftRate` > 0 OR `overdraftDailyFee` > 0)", ACCOUNT_CLOSED)
+	if err != nil {
+		return nil, errors.New("accounts.getChargeableOverdrafts: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var facility OverdraftFacility
+		if err := rows.Scan(&facility.AccountNumber, &facility.Limit, &facility.Rate, &facility.DailyFee, &facility.AvailableBalance, &facility.Currency); err != nil {
+			return nil, errors.New("accounts.getChargeableOverdrafts: Could not retrieve overdraft. " + err.Error())
+		}
+		facility.inCurrency()
+		facilities = append(facilities, facility)
+	}
+
+	return
+}
+
+// doAccrueOverdraftCharges charges every account that ended the day overdrawn. Accounts already
+// charged for the day are skipped
+func doAccrueOverdraftCharges(date string, from time.Time, to time.Time) (accruals []OverdraftAccrual, err error) {
+	facilities, err := getChargeableOverdrafts()
+	if err != nil {
+		return nil, errors.New("accounts.doAccrueOverdraftCharges: " + err.Error())
+	}
+
+	for _, facility := range facilities {
+		closingBalance, err := ledger.GetLedgerBalanceBefore(facility.AccountNumber, int32(to.Unix()))
+		if err != nil {
+			return accruals, errors.New("accounts.doAccrueOverdraftCharges: " + err.Error())
+		}
+
+		balance := money.New(closingBalance, facility.Currency)
+		interest, fee := overdraftCharges(balance, facility.Rate, facility.DailyFee)
+		if interest.IsZero() && fee.IsZero() {
+			continue
+		}
+
+		accrual := OverdraftAccrual{
+			AccountNumber: facility.AccountNumber,
+			Date:          date,
+			Balance:       balance,
+			Interest:      interest,
+			Fee:           fee,
+			Timestamp:     int32(time.Now().Unix()),
+		}
+		posted, err := doPostOverdraftAccrual(&accrual)
+		if err != nil {
+			return accruals, errors.New("accounts.doAccrueOverdraftCharges: " + err.Error())
+		}
+		if posted {
+			accruals = append(accruals, accrual)
+		}
+	}
+
+	return
+}
+
+// doPostOverdraftAccrual debits the day's charges from the account and records the accrual.
+// It posts nothing when the account was already charged for the day
+func doPostOverdraftAccrual(accrual *OverdraftAccrual) (posted bool, err error) {
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return false, errors.New("accounts.doPostOverdraftAccrual: Could not start database transaction. " + err.Error())
+	}
+
+	rows, err := tx.Query("SELECT `id` FROM `overdraft_accruals` WHERE `accountNumber` = ? AND `accrualDate` = ? FOR UPDATE", accrual.AccountNumber, accrual.Date)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.New("accounts.doPostOverdraftAccrual: " + err.Error())
+	}
+	charged := rows.Next()
+	rows.Close()
+	if charged {
+		tx.Rollback()
+		return false, nil
+	}
+
+	total := accrual.Interest.Add(accrual.Fee)
+	updateStatement := "UPDATE accounts SET `accountBalance` = (`accountBalance` - ?), `availableBalance` = (`availableBalance` - ?), `timestamp` = ? WHERE `accountNumber` = ? "
+	stmtUpd, err := tx.Prepare(updateStatement)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.New("accounts.doPostOverdraftAccrual: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	_, err = stmtUpd.Exec(total, total, accrual.Timestamp, accrual.AccountNumber)
 	if err != nil {
-		return errors.New("accounts.deleteAccount: " + err.Error())
+		tx.Rollback()
+		return false, errors.New("accounts.doPostOverdraftAccrual: " + err.Error())
 	}
 
-	err = doDeleteAccountUser(accountHolderDetails)
+	accrual.JournalID, err = ledger.PostJournal(tx, overdraftJournalEntry(*accrual))
 	if err != nil {
-		return errors.New("accounts.deleteAccount: " + err.Error())
+		tx.Rollback()
+		return false, errors.New("accounts.doPostOverdraftAccrual: " + err.Error())
 	}
 
-	err = doDeleteAccountUserAccounts(accountHolderDetails)
+	insertStatement := "INSERT INTO overdraft_accruals (`accountNumber`, `accrualDate`, `balance`, `interest`, `fee`, `journalID`, `timestamp`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?)"
+	stmtIns, err := tx.Prepare(insertStatement)
 	if err != nil {
-		return errors.New("accounts.deleteAccount: " + err.Error())
+		tx.Rollback()
+		return false, errors.New("accounts.doPostOverdraftAccrual: " + err.Error())
 	}
+	defer stmtIns.Close()
 
+	_, err = stmtIns.Exec(accrual.AccountNumber, accrual.Date, accrual.Balance, accrual.Interest, accrual.Fee, accrual.JournalID, accrual.Timestamp)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.New("accounts.doPostOverdraftAccrual: " + err.Error())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, errors.New("accounts.doPostOverdraftAccrual: Could not commit transaction. " + err.Error())
+	}
+
+	return true, nil
+}
+
+// overdraftJournalEntry books a day's overdraft interest and fee from the account to the bank's income
+func overdraftJournalEntry(accrual OverdraftAccrual) (entry ledger.JournalEntry) {
+	entry = ledger.JournalEntry{Desc: "acmt~1008 Overdraft charges " + accrual.Date, Timestamp: accrual.Timestamp}
+	entry.Lines = append(entry.Lines, ledger.Debit(accrual.AccountNumber, accrual.Interest.Add(accrual.Fee).Amount)...)
+	entry.Lines = append(entry.Lines, ledger.Credit(ledger.InCurrency(ledger.INTEREST_INCOME, accrual.Balance.Currency), accrual.Interest.Amount)...)
+	entry.Lines = append(entry.Lines, ledger.Credit(ledger.InCurrency(ledger.FEE_INCOME, accrual.Balance.Currency), accrual.Fee.Amount)...)
 	return
 }
 
-func doCreateAccount(sqlTime int32, accountDetails *AccountDetails, accountHolderDetails *AccountHolderDetails) (err error) {
+// getFeeAccounts lists the accounts that are not closed, were opened before the given time and
+// whose product charges a monthly fee, with that fee. Product fees are in the product's currency,
+// so only accounts held in it are charged
+func getFeeAccounts(openedBefore time.Time) (charges []MonthlyFeeCharge, err error) {
+	selectStatement := "SELECT a.`accountNumber`, p.`monthlyFee`, p.`currency` FROM `accounts` a INNER JOIN `products` p ON p.`code` = a.`type` "
+	selectStatement += "WHERE a.`status` != ? AND a.`currency` = p.`currency` AND p.`monthlyFee` > 0 AND a.`accountNumber` IN "
+	selectStatement += "(SELECT `accountNumber` FROM `accounts_users_accounts` GROUP BY `accountNumber` HAVING MIN(`timestamp`) < ?)"
+	rows, err := Config.Db.Query(selectStatement, ACCOUNT_CLOSED, int32(openedBefore.Unix()))
+	if err != nil {
+		return nil, errors.New("accounts.getFeeAccounts: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var charge MonthlyFeeCharge
+		var currency string
+		if err := rows.Scan(&charge.AccountNumber, &charge.Amount, &currency); err != nil {
+			return nil, errors.New("accounts.getFeeAccounts: Could not retrieve account. " + err.Error())
+		}
+		charge.Amount = charge.Amount.In(currency)
+		charges = append(charges, charge)
+	}
+
+	return
+}
+
+// doChargeMonthlyFees charges the month's product fee to every account that was open by the end
+// of it. Accounts already charged for the month are skipped
+func doChargeMonthlyFees(month string, to time.Time) (charges []MonthlyFeeCharge, err error) {
+	feeAccounts, err := getFeeAccounts(to)
+	if err != nil {
+		return nil, errors.New("accounts.doChargeMonthlyFees: " + err.Error())
+	}
+
+	for _, charge := range feeAccounts {
+		charge.Month = month
+		charge.Timestamp = int32(time.Now().Unix())
+		posted, err := doPostMonthlyFee(&charge)
+		if err != nil {
+			return charges, errors.New("accounts.doChargeMonthlyFees: " + err.Error())
+		}
+		if posted {
+			charges = append(charges, charge)
+		}
+	}
+
+	return
+}
+
+// doPostMonthlyFee debits the month's fee from the account and records the charge. It posts
+// nothing when the account was already charged for the month
+func doPostMonthlyFee(charge *MonthlyFeeCharge) (posted bool, err error) {
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return false, errors.New("accounts.doPostMonthlyFee: Could not start database transaction. " + err.Error())
+	}
+
+	rows, err := tx.Query("SELECT `id` FROM `account_monthly_fees` WHERE `accountNumber` = ? AND `month` = ? FOR UPDATE", charge.AccountNumber, charge.Month)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.New("accounts.doPostMonthlyFee: " + err.Error())
+	}
+	charged := rows.Next()
+	rows.Close()
+	if charged {
+		tx.Rollback()
+		return false, nil
+	}
+
+	updateStatement := "UPDATE accounts SET `accountBalance` = (`accountBalance` - ?), `availableBalance` = (`availableBalance` - ?), `timestamp` = ? WHERE `accountNumber` = ? "
+	stmtUpd, err := tx.Prepare(updateStatement)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.New("accounts.doPostMonthlyFee: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	_, err = stmtUpd.Exec(charge.Amount, charge.Amount, charge.Timestamp, charge.AccountNumber)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.New("accounts.doPostMonthlyFee: " + err.Error())
+	}
+
+	charge.JournalID, err = ledger.PostJournal(tx, monthlyFeeJournalEntry(*charge))
+	if err != nil {
+		tx.Rollback()
+		return false, errors.New("accounts.doPostMonthlyFee: " + err.Error())
+	}
+
+	insertStatement := "INSERT INTO account_monthly_fees (`accountNumber`, `month`, `amount`, `journalID`, `timestamp`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?)"
+	stmtIns, err := tx.Prepare(insertStatement)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.New("accounts.doPostMonthlyFee: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	_, err = stmtIns.Exec(charge.AccountNumber, charge.Month, charge.Amount, charge.JournalID, charge.Timestamp)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.New("accounts.doPostMonthlyFee: " + err.Error())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, errors.New("accounts.doPostMonthlyFee: Could not commit transaction. " + err.Error())
+	}
+
+	return true, nil
+}
+
+func doCreateAccount(db ledger.Preparer, sqlTime int32, accountDetails *AccountDetails, accountHolderDetails *AccountHolderDetails) (err error) {
 	// Create account
-	insertStatement := "INSERT INTO accounts (`accountNumber`, `bankNumber`, `accountHolderName`, `accountBalance`, `overdraft`, `availableBalance`, `type`, `timestamp`) "
-	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?, ?)"
-	stmtIns, err := Config.Db.Prepare(insertStatement)
+	insertStatement := "INSERT INTO accounts (`accountNumber`, `bankNumber`, `accountHolderName`, `accountBalance`, `overdraft`, `availableBalance`, `currency`, `type`, `status`, `timestamp`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	stmtIns, err := db.Prepare(insertStatement)
 	if err != nil {
 		return errors.New("accounts.doCreateAccount: " + err.Error())
 	}
@@ -87,7 +705,14 @@ func doCreateAccount(sqlTime int32, accountDetails *AccountDetails, accountHolde
 	newUuid, err := uuid.NewV4()
 	accountDetails.AccountNumber = newUuid.String()
 
-	_, err = stmtIns.Exec(accountDetails.AccountNumber, accountDetails.BankNumber, accountDetails.AccountHolderName, accountDetails.AccountBalance, accountDetails.Overdraft, accountDetails.AvailableBalance, accountDetails.Type, sqlTime)
+	if accountDetails.Status == "" {
+		accountDetails.Status = ACCOUNT_OPEN
+	}
+	if accountDetails.Currency == "" {
+		accountDetails.Currency = money.DEFAULT_CURRENCY
+	}
+
+	_, err = stmtIns.Exec(accountDetails.AccountNumber, accountDetails.BankNumber, accountDetails.AccountHolderName, accountDetails.AccountBalance, accountDetails.Overdraft, accountDetails.AvailableBalance, accountDetails.Currency, accountDetails.Type, accountDetails.Status, sqlTime)
 	if err != nil {
 		return errors.New("accounts.doCreateAccount: " + err.Error())
 	}
@@ -112,6 +737,26 @@ func doCreateAccount(sqlTime int32, accountDetails *AccountDetails, accountHolde
 	return
 }
 
+// doPostOpeningBalance records the balance an account is opened with in the ledger, in the same
+// database transaction as the account
+func doPostOpeningBalance(db ledger.Preparer, sqlTime int32, accountDetails *AccountDetails) (err error) {
+	entry := ledger.JournalEntry{Desc: "Opening balance", Timestamp: sqlTime}
+	entry.Lines = append(entry.Lines, ledger.Debit(ledger.OPENING_BALANCES, accountDetails.AccountBalance.Amount)...)
+	entry.Lines = append(entry.Lines, ledger.Credit(accountDetails.AccountNumber, accountDetails.AccountBalance.Amount)...)
+
+	// Nothing to record for accounts opened with a zero balance
+	if len(entry.Lines) == 0 {
+		return
+	}
+
+	_, err = ledger.PostJournal(db, entry)
+	if err != nil {
+		return errors.New("accounts.doPostOpeningBalance: " + err.Error())
+	}
+
+	return
+}
+
 func doDeleteAccount(accountDetails *AccountDetails) (err error) {
 	deleteStatement := "DELETE FROM accounts WHERE `accountNumber` = ? AND `bankNumber` = ? AND `accountHolderName` = ? "
 	stmtDel, err := Config.Db.Prepare(deleteStatement)
@@ -130,7 +775,7 @@ func doDeleteAccount(accountDetails *AccountDetails) (err error) {
 	return
 }
 
-func doCreateAccountUser(sqlTime int32, accountHolderDetails *AccountHolderDetails, accountDetails *AccountDetails) (err error) {
+func doCreateAccountUser(db ledger.Preparer, sqlTime int32, accountHolderDetails *AccountHolderDetails, accountDetails *AccountDetails) (err error) {
 	// Check if the user already exists
 	account, err := getAccountUser(accountHolderDetails.IdentificationNumber)
 	if err != nil {
@@ -145,7 +790,7 @@ func doCreateAccountUser(sqlTime int32, accountHolderDetails *AccountHolderDetai
 	// Create account meta
 	insertStatement := "INSERT INTO accounts_users (`accountHolderGivenName`, `accountHolderFamilyName`, `accountHolderDateOfBirth`, `accountHolderIdentificationNumber`, `accountHolderContactNumber1`, `accountHolderContactNumber2`, `accountHolderEmailAddress`, `accountHolderAddressLine1`, `accountHolderAddressLine2`, `accountHolderAddressLine3`, `accountHolderPostalCode`, `timestamp`) "
 	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
-	stmtIns, err := Config.Db.Prepare(insertStatement)
+	stmtIns, err := db.Prepare(insertStatement)
 	if err != nil {
 		return errors.New("accounts.doCreateAccountUser: " + err.Error())
 	}
@@ -161,10 +806,10 @@ func doCreateAccountUser(sqlTime int32, accountHolderDetails *AccountHolderDetai
 	return
 }
 
-func doCreateAccountUserAccount(sqlTime int32, accountHolderDetails *AccountHolderDetails, accountDetails *AccountDetails) (err error) {
+func doCreateAccountUserAccount(db ledger.Preparer, sqlTime int32, accountHolderDetails *AccountHolderDetails, accountDetails *AccountDetails) (err error) {
 	insertStatement := "INSERT INTO accounts_users_accounts (`accountHolderIdentificationNumber`, `accountNumber`, `bankNumber`, `timestamp`) "
 	insertStatement += "VALUES(?, ?, ?, ?)"
-	stmtIns, err := Config.Db.Prepare(insertStatement)
+	stmtIns, err := db.Prepare(insertStatement)
 	if err != nil {
 		return errors.New("accounts.doCreateAccountUserAccount: " + err.Error())
 	}
@@ -217,7 +862,7 @@ func doDeleteAccountUserAccounts(accountHolderDetails *AccountHolderDetails) (er
 }
 
 func getAccountDetails(id string) (accountDetails AccountDetails, err error) {
-	rows, err := Config.Db.Query("SELECT `accountNumber`, `bankNumber`, `accountHolderName`, `accountBalance`, `overdraft`, `availableBalance` FROM `accounts` WHERE `accountNumber` = ?", id)
+	rows, err := Config.Db.Query("SELECT `accountNumber`, `bankNumber`, `accountHolderName`, `accountBalance`, `overdraft`, `availableBalance`, `currency`, `status` FROM `accounts` WHERE `accountNumber` = ?", id)
 	if err != nil {
 		return AccountDetails{}, errors.New("accounts.getAccountDetails: " + err.Error())
 	}
@@ -225,7 +870,7 @@ func getAccountDetails(id string) (accountDetails AccountDetails, err error) {
 
 	count := 0
 	for rows.Next() {
-		err := rows.Scan(&accountDetails.AccountNumber, &accountDetails.BankNumber, &accountDetails.AccountHolderName, &accountDetails.AccountBalance, &accountDetails.Overdraft, &accountDetails.AvailableBalance)
+		err := rows.Scan(&accountDetails.AccountNumber, &accountDetails.BankNumber, &accountDetails.AccountHolderName, &accountDetails.AccountBalance, &accountDetails.Overdraft, &accountDetails.AvailableBalance, &accountDetails.Currency, &accountDetails.Status)
 		if err != nil {
 			break
 		}
@@ -240,6 +885,7 @@ func getAccountDetails(id string) (accountDetails AccountDetails, err error) {
 		// There cannot be more than one account with the same accountNumber
 		return AccountDetails{}, errors.New("accounts.getAccountDetails: More than one account found")
 	}
+	accountDetails.inCurrency()
 
 	return
 }
@@ -264,6 +910,195 @@ func getAccountUser(id string) (accountDetails AccountHolderDetails, err error)
 	return
 }
 
+// getAccountUserVersion fetches the account holder's details together with their version
+func getAccountUserVersion(id string) (accountDetails AccountHolderDetails, version int, err error) {
+	rows, err := Config.Db.Query("SELECT `accountHolderGivenName`, `accountHolderFamilyName`, `accountHolderDateOfBirth`, `accountHolderIdentificationNumber`, `accountHolderContactNumber1`, COALESCE(`accountHolderContactNumber2`, ''), `accountHolderEmailAddress`, `accountHolderAddressLine1`, COALESCE(`accountHolderAddressLine2`, ''), COALESCE(`accountHolderAddressLine3`, ''), `accountHolderPostalCode`, `version` FROM `accounts_users` WHERE `accountHolderIdentificationNumber` = ?", id)
+	if err != nil {
+		return AccountHolderDetails{}, 0, errors.New("accounts.getAccountUserVersion: " + err.Error())
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		if err := rows.Scan(&accountDetails.GivenName, &accountDetails.FamilyName, &accountDetails.DateOfBirth, &accountDetails.IdentificationNumber, &accountDetails.ContactNumber1, &accountDetails.ContactNumber2, &accountDetails.EmailAddress, &accountDetails.AddressLine1, &accountDetails.AddressLine2,
+			&accountDetails.AddressLine3, &accountDetails.PostalCode, &version); err != nil {
+			return AccountHolderDetails{}, 0, errors.New("accounts.getAccountUserVersion: Could not retrieve account holder details. " + err.Error())
+		}
+		count++
+	}
+
+	if count == 0 {
+		return AccountHolderDetails{}, 0, errors.New("accounts.getAccountUserVersion: Account holder not found")
+	}
+
+	return
+}
+
+// getAccountUserVerificationStatus fetches the account holder's verification status, empty
+// when the holder does not exist yet
+func getAccountUserVerificationStatus(id string) (verificationStatus string, err error) {
+	rows, err := Config.Db.Query("SELECT `verificationStatus` FROM `accounts_users` WHERE `accountHolderIdentificationNumber` = ?", id)
+	if err != nil {
+		return "", errors.New("accounts.getAccountUserVerificationStatus: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(&verificationStatus); err != nil {
+			return "", errors.New("accounts.getAccountUserVerificationStatus: Could not retrieve verification status. " + err.Error())
+		}
+	}
+
+	return
+}
+
+// doRecordVerification keeps the verification report and sets the holder's verification status.
+// Once the holder is verified their restricted accounts open
+func doRecordVerification(verification IdentificationVerification) (err error) {
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return errors.New("accounts.doRecordVerification: Could not start database transaction. " + err.Error())
+	}
+
+	insertStatement := "INSERT INTO identification_verifications (`verificationID`, `accountHolderIdentificationNumber`, `verifier`, `status`, `reason`, `reference`, `timestamp`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?)"
+	stmtIns, err := tx.Prepare(insertStatement)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("accounts.doRecordVerification: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	_, err = stmtIns.Exec(verification.VerificationID, verification.IdentificationNumber, verification.Verifier, verification.Status, verification.Reason, verification.Reference, verification.Timestamp)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("accounts.doRecordVerification: " + err.Error())
+	}
+
+	updateStatement := "UPDATE accounts_users SET `verificationStatus` = ?, `verificationTimestamp` = ? WHERE `accountHolderIdentificationNumber` = ?"
+	stmtUpd, err := tx.Prepare(updateStatement)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("accounts.doRecordVerification: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	_, err = stmtUpd.Exec(verification.Status, verification.Timestamp, verification.IdentificationNumber)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("accounts.doRecordVerification: " + err.Error())
+	}
+
+	if verification.Status == kyc.STATUS_VERIFIED {
+		openStatement := "UPDATE accounts SET `status` = ? WHERE `status` = ? AND `accountNumber` IN " +
+			"(SELECT `accountNumber` FROM `accounts_users_accounts` WHERE `accountHolderIdentificationNumber` = ?)"
+		stmtOpen, err := tx.Prepare(openStatement)
+		if err != nil {
+			tx.Rollback()
+			return errors.New("accounts.doRecordVerification: " + err.Error())
+		}
+		defer stmtOpen.Close()
+
+		_, err = stmtOpen.Exec(ACCOUNT_OPEN, ACCOUNT_RESTRICTED, verification.IdentificationNumber)
+		if err != nil {
+			tx.Rollback()
+			return errors.New("accounts.doRecordVerification: " + err.Error())
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("accounts.doRecordVerification: Could not commit transaction. " + err.Error())
+	}
+
+	return
+}
+
+// getLatestVerification fetches the holder's latest verification report, empty if there is none
+func getLatestVerification(id string) (verification IdentificationVerification, err error) {
+	rows, err := Config.Db.Query("SELECT `verificationID`, `accountHolderIdentificationNumber`, `verifier`, `status`, COALESCE(`reason`, ''), COALESCE(`reference`, ''), `timestamp` "+
+		"FROM `identification_verifications` WHERE `accountHolderIdentificationNumber` = ? ORDER BY `id` DESC LIMIT 1", id)
+	if err != nil {
+		return IdentificationVerification{}, errors.New("accounts.getLatestVerification: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(&verification.VerificationID, &verification.IdentificationNumber, &verification.Verifier, &verification.Status, &verification.Reason, &verification.Reference, &verification.Timestamp); err != nil {
+			return IdentificationVerification{}, errors.New("accounts.getLatestVerification: Could not retrieve verification. " + err.Error())
+		}
+	}
+
+	return
+}
+
+// doModifyAccountHolder applies the changes on top of the given version and records the values
+// they replace. The update fails if the details have changed since they were read
+func doModifyAccountHolder(id string, version int, changes []holderChange) (err error) {
+	sqlTime := int32(time.Now().Unix())
+
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return errors.New("accounts.doModifyAccountHolder: Could not start database transaction. " + err.Error())
+	}
+
+	updateStatement := "UPDATE accounts_users SET "
+	args := []interface{}{}
+	for _, change := range changes {
+		updateStatement += "`" + change.Field.Column + "` = ?, "
+		args = append(args, change.New)
+	}
+	updateStatement += "`version` = `version` + 1, `timestamp` = ? WHERE `accountHolderIdentificationNumber` = ? AND `version` = ?"
+	args = append(args, sqlTime, id, version)
+
+	stmtUpd, err := tx.Prepare(updateStatement)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("accounts.doModifyAccountHolder: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	res, err := stmtUpd.Exec(args...)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("accounts.doModifyAccountHolder: " + err.Error())
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return errors.New("accounts.doModifyAccountHolder: Could not get rows affected. " + err.Error())
+	}
+	if affected != 1 {
+		tx.Rollback()
+		return errors.New("accounts.doModifyAccountHolder: Account holder details have changed, try again")
+	}
+
+	insertStatement := "INSERT INTO accounts_users_history (`accountHolderIdentificationNumber`, `version`, `field`, `previousValue`, `newValue`, `timestamp`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?)"
+	stmtIns, err := tx.Prepare(insertStatement)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("accounts.doModifyAccountHolder: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	for _, change := range changes {
+		_, err = stmtIns.Exec(id, version, change.Field.Name, change.Previous, change.New, sqlTime)
+		if err != nil {
+			tx.Rollback()
+			return errors.New("accounts.doModifyAccountHolder: " + err.Error())
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("accounts.doModifyAccountHolder: Could not commit transaction. " + err.Error())
+	}
+
+	return
+}
+
 func getAllAccountDetails() (allAccounts []AccountDetails, err error) {
 	rows, err := Config.Db.Query("SELECT `accountNumber`, `bankNumber`, `accountHolderName` FROM `accounts`")
 	if err != nil {
@@ -289,7 +1124,7 @@ func getAllAccountDetails() (allAccounts []AccountDetails, err error) {
 
 func getUserAccountsDetail(userID string) (accounts []AccountDetails, err error) {
 	rows, err := Config.Db.Query(
-		"SELECT a.accountNumber, a.bankNumber, a.accountHolderName, a.accountBalance, a.overdraft, a.availableBalance "+
+		"SELECT a.accountNumber, a.bankNumber, a.accountHolderName, a.accountBalance, a.overdraft, a.availableBalance, a.currency, a.type, a.status "+
 			"FROM accounts a "+
 			"LEFT JOIN accounts_users_accounts au "+
 			"ON au.accountNumber = a.accountNumber "+
@@ -303,9 +1138,10 @@ func getUserAccountsDetail(userID string) (accounts []AccountDetails, err error)
 	count := 0
 	for rows.Next() {
 		var account AccountDetails
-		if err := rows.Scan(&account.AccountNumber, &account.BankNumber, &account.AccountHolderName, &account.AccountBalance, &account.Overdraft, &account.AvailableBalance); err != nil {
+		if err := rows.Scan(&account.AccountNumber, &account.BankNumber, &account.AccountHolderName, &account.AccountBalance, &account.Overdraft, &account.AvailableBalance, &account.Currency, &account.Type, &account.Status); err != nil {
 			break
 		}
+		account.inCurrency()
 
 		accounts = append(accounts, account)
 		count++
@@ -314,6 +1150,46 @@ func getUserAccountsDetail(userID string) (accounts []AccountDetails, err error)
 	return
 }
 
+// getUserAccountsReport extends getUserAccountsDetail with the date each account was linked to
+// the holder, the cash account type of its product and the merchant it belongs to. An empty
+// account type reports every account
+func getUserAccountsReport(userID string, accountType string) (entries []AccountReportEntry, err error) {
+	rows, err := Config.Db.Query(
+		"SELECT a.accountNumber, a.bankNumber, a.accountHolderName, a.accountBalance, a.overdraft, a.availableBalance, a.currency, a.type, a.status, a.timestamp, "+
+			"a.closedTimestamp, au.timestamp, COALESCE(p.cashAccountType, ''), COALESCE(mu.merchantID, ''), COALESCE(m.merchantName, '') "+
+			"FROM accounts a "+
+			"LEFT JOIN products p "+
+			"ON p.code = a.type "+
+			"LEFT JOIN accounts_users_accounts au "+
+			"ON au.accountNumber = a.accountNumber "+
+			"AND au.bankNumber = a.bankNumber "+
+			"LEFT JOIN merchant_users_accounts mu "+
+			"ON mu.accountNumber = a.accountNumber "+
+			"AND mu.bankNumber = a.bankNumber "+
+			"LEFT JOIN merchants m "+
+			"ON m.merchantID = mu.merchantID "+
+			"WHERE au.accountHolderIdentificationNumber = ? "+
+			"AND (? = '' OR a.type = ?) "+
+			"ORDER BY au.timestamp", userID, accountType, accountType)
+	if err != nil {
+		return nil, errors.New("accounts.getUserAccountsReport: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry AccountReportEntry
+		if err := rows.Scan(&entry.AccountNumber, &entry.BankNumber, &entry.AccountHolderName, &entry.AccountBalance, &entry.Overdraft, &entry.AvailableBalance, &entry.Currency, &entry.Type, &entry.Status, &entry.Timestamp,
+			&entry.ClosedTimestamp, &entry.OpeningTimestamp, &entry.CashAccountType, &entry.MerchantID, &entry.MerchantName); err != nil {
+			return nil, errors.New("accounts.getUserAccountsReport: " + err.Error())
+		}
+		entry.inCurrency()
+
+		entries = append(entries, entry)
+	}
+
+	return
+}
+
 func getAllAccountNumbersByID(userID string) (accountIDs []string, err error) {
 //	rows, err := Config.Db.Query("SELECT `accountNumber` FROM `accounts_users_accounts` WHERE `accountHolderIdentificationNumber` = ?", userID)
 	rows, err := Config.Db.Query("SELECT `accountNumber` FROM `accounts_users_accounts` WHERE `accountNumber` = ?", userID)
@@ -474,33 +1350,59 @@ func createMerchantAccount(merchantDetails *MerchantDetails, accountDetails *Acc
 	t := time.Now()
 	sqlTime := int32(t.Unix())
 
-	err = doCreateAccount(sqlTime, accountDetails, accountHolderDetails)
+	verificationStatus, err := getAccountUserVerificationStatus(accountHolderDetails.IdentificationNumber)
 	if err != nil {
-		return errors.New("accounts.createAccount: " + err.Error())
+		return errors.New("accounts.createMerchantAccount: " + err.Error())
+	}
+	accountDetails.Status = accountStatusForHolder(verificationStatus)
+
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return errors.New("accounts.createMerchantAccount: Could not start database transaction. " + err.Error())
+	}
+
+	err = doCreateAccount(tx, sqlTime, accountDetails, accountHolderDetails)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("accounts.createMerchantAccount: " + err.Error())
+	}
+
+	err = doPostOpeningBalance(tx, sqlTime, accountDetails)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("accounts.createMerchantAccount: " + err.Error())
 	}
 
-	err = doCreateMerchant(sqlTime, merchantDetails)
+	err = doCreateMerchant(tx, sqlTime, merchantDetails)
 	if err != nil {
+		tx.Rollback()
 		return errors.New("accounts.createMerchantAccount: " + err.Error())
 	}
 
-	err = doCreateAccountUserAccount(sqlTime, accountHolderDetails, accountDetails)
+	err = doCreateAccountUserAccount(tx, sqlTime, accountHolderDetails, accountDetails)
 	if err != nil {
+		tx.Rollback()
 		return errors.New("accounts.createMerchantAccount: " + err.Error())
 	}
 
-	err = doCreateAccountMerchantAccount(sqlTime, merchantDetails, accountHolderDetails, accountDetails)
+	err = doCreateAccountMerchantAccount(tx, sqlTime, merchantDetails, accountHolderDetails, accountDetails)
 	if err != nil {
+		tx.Rollback()
 		return errors.New("accounts.createMerchantAccount: " + err.Error())
 	}
 
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("accounts.createMerchantAccount: Could not commit transaction. " + err.Error())
+	}
+
 	return
 }
 
-func doCreateMerchant(sqltime int32, merchantDetails *MerchantDetails) (err error) {
+func doCreateMerchant(db ledger.Preparer, sqltime int32, merchantDetails *MerchantDetails) (err error) {
 	insertStatement := "INSERT INTO merchants (`merchantID`, `merchantName`, `merchantDescription`, `merchantContactGivenName`, `merchantContactFamilyName`, `merchantAddressLine1`, `merchantAddressLine2`, `merchantAddressLine3`, `merchantCountry`, `merchantPostalCode`, `merchantBusinessSector`, `merchantWebsite`, `merchantContactPhone`, `merchantContactFax`, `merchantContactEmail`, `merchantLogo`, `merchantIdentificationNumber`,`timestamp`) "
 	insertStatement += "VALUES(?, ?, ?, ?, ?,  ?,  ?,  ?,  ?,  ?,  ?,  ?,  ?,  ?,  ?,  ?,  ?, ?)"
-	stmtIns, err := Config.Db.Prepare(insertStatement)
+	stmtIns, err := db.Prepare(insertStatement)
 	if err != nil {
 		return errors.New("accounts.doCreateMerchant: " + err.Error())
 	}
@@ -675,10 +1577,10 @@ func doDeleteSingleAccountUserAccounts(accountHolderDetails *AccountHolderDetail
 	return
 }
 
-func doCreateAccountMerchantAccount(sqlTime int32, merchantDetails *MerchantDetails, accountHolderDetails *AccountHolderDetails, accountDetails *AccountDetails) (err error) {
+func doCreateAccountMerchantAccount(db ledger.Preparer, sqlTime int32, merchantDetails *MerchantDetails, accountHolderDetails *AccountHolderDetails, accountDetails *AccountDetails) (err error) {
 	insertStatement := "INSERT INTO merchant_users_accounts (`accountHolderIdentificationNumber`, `merchantID`, `accountNumber`, `bankNumber`, `timestamp`) "
 	insertStatement += "VALUES(?, ?, ?, ?, ?)"
-	stmtIns, err := Config.Db.Prepare(insertStatement)
+	stmtIns, err := db.Prepare(insertStatement)
 	if err != nil {
 		return errors.New("accounts.doCreateAccountMerchantAccount: " + err.Error())
 	}
@@ -736,6 +1638,28 @@ func getAllMerchantAccountNumbersByMerchantID(merchantID string) (accountIDs []s
 	return
 }
 
+func getMerchantIDFromAccountNumber(accountNumber string) (merchantID string, err error) {
+	rows, err := Config.Db.Query("SELECT `merchantID` FROM `merchant_users_accounts` WHERE `accountNumber` = ?", accountNumber)
+	if err != nil {
+		return "", errors.New("accounts.getMerchantIDFromAccountNumber: " + err.Error())
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		if err := rows.Scan(&merchantID); err != nil {
+			return "", errors.New("accounts.getMerchantIDFromAccountNumber: " + err.Error())
+		}
+		count++
+	}
+
+	if count == 0 {
+		return "", errors.New("accounts.getMerchantIDFromAccountNumber: Account is not a merchant account")
+	}
+
+	return
+}
+
 func getMerchantAccountFromSearchData(searchStr string) (allMerchantDetails []MerchantDetails, err error) {
 	searchString := "%" + searchStr + "%"
 	rows, err := Config.Db.Query("SELECT `merchantID`, `merchantName`, `merchantDescription` FROM `merchants` WHERE `merchantID` like ? OR `merchantName` like ? OR `merchantDescription` like ? OR  `merchantWebsite` like ? LIMIT 10", searchString, searchString, searchString, searchString)
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/bvnk/bank/configuration"
+	"github.com/bvnk/bank/money"
 	"github.com/shopspring/decimal"
 )
 
@@ -52,10 +53,12 @@ func TestDoCreateAccount(t *testing.T) {
 		"",
 		"",
 		"User,Test",
-		decimal.NewFromFloat(0.),
-		decimal.NewFromFloat(0.),
-		decimal.NewFromFloat(0.),
+		money.Zero(money.DEFAULT_CURRENCY),
+		money.Zero(money.DEFAULT_CURRENCY),
+		money.Zero(money.DEFAULT_CURRENCY),
+		money.DEFAULT_CURRENCY,
 		"cheque",
+		ACCOUNT_OPEN,
 		0,
 	}
 
@@ -75,7 +78,7 @@ func TestDoCreateAccount(t *testing.T) {
 
 	ti := time.Now()
 	sqlTime := int32(ti.Unix())
-	err := doCreateAccount(sqlTime, &accountDetail, &accountHolderDetail)
+	err := doCreateAccount(Config.Db, sqlTime, &accountDetail, &accountHolderDetail)
 
 	if err != nil {
 		t.Errorf("DoCreateAccount does not pass. Looking for %v, got %v", nil, err)
@@ -93,10 +96,12 @@ func BenchmarkDoCreateAccount(b *testing.B) {
 			"",
 			"",
 			"User,Test",
-			decimal.NewFromFloat(0.),
-			decimal.NewFromFloat(0.),
-			decimal.NewFromFloat(0.),
+			money.Zero(money.DEFAULT_CURRENCY),
+			money.Zero(money.DEFAULT_CURRENCY),
+			money.Zero(money.DEFAULT_CURRENCY),
+			money.DEFAULT_CURRENCY,
 			"cheque",
+			ACCOUNT_OPEN,
 			0,
 		}
 
@@ -116,7 +121,7 @@ func BenchmarkDoCreateAccount(b *testing.B) {
 
 		ti := time.Now()
 		sqlTime := int32(ti.Unix())
-		_ = doCreateAccount(sqlTime, &accountDetail, &accountHolderDetail)
+		_ = doCreateAccount(Config.Db, sqlTime, &accountDetail, &accountHolderDetail)
 		_ = doDeleteAccount(&accountDetail)
 	}
 }
@@ -126,10 +131,12 @@ func TestDoAccountMeta(t *testing.T) {
 		"",
 		"",
 		"User,Test",
-		decimal.NewFromFloat(0.),
-		decimal.NewFromFloat(0.),
-		decimal.NewFromFloat(0.),
+		money.Zero(money.DEFAULT_CURRENCY),
+		money.Zero(money.DEFAULT_CURRENCY),
+		money.Zero(money.DEFAULT_CURRENCY),
+		money.DEFAULT_CURRENCY,
 		"cheque",
+		ACCOUNT_OPEN,
 		0,
 	}
 
@@ -150,7 +157,7 @@ func TestDoAccountMeta(t *testing.T) {
 	ti := time.Now()
 	sqlTime := int32(ti.Unix())
 
-	err := doCreateAccountUser(sqlTime, &accountHolderDetail, &accountDetail)
+	err := doCreateAccountUser(Config.Db, sqlTime, &accountHolderDetail, &accountDetail)
 	if err != nil {
 		t.Errorf("DoAccountMeta doCreateAccountUser does not pass. Looking for %v, got %v", nil, err)
 	}
@@ -167,10 +174,12 @@ func BenchmarkDoAccountMeta(b *testing.B) {
 			"",
 			"",
 			"User,Test",
-			decimal.NewFromFloat(0.),
-			decimal.NewFromFloat(0.),
-			decimal.NewFromFloat(0.),
+			money.Zero(money.DEFAULT_CURRENCY),
+			money.Zero(money.DEFAULT_CURRENCY),
+			money.Zero(money.DEFAULT_CURRENCY),
+			money.DEFAULT_CURRENCY,
 			"cheque",
+			ACCOUNT_OPEN,
 			0,
 		}
 
@@ -190,7 +199,7 @@ func BenchmarkDoAccountMeta(b *testing.B) {
 
 		ti := time.Now()
 		sqlTime := int32(ti.Unix())
-		_ = doCreateAccountUser(sqlTime, &accountHolderDetail, &accountDetail)
+		_ = doCreateAccountUser(Config.Db, sqlTime, &accountHolderDetail, &accountDetail)
 
 		_ = doDeleteAccountUser(&accountHolderDetail)
 	}
@@ -201,10 +210,12 @@ func TestGetAccount(t *testing.T) {
 		"",
 		"",
 		"User,Test",
-		decimal.NewFromFloat(0.),
-		decimal.NewFromFloat(0.),
-		decimal.NewFromFloat(0.),
+		money.Zero(money.DEFAULT_CURRENCY),
+		money.Zero(money.DEFAULT_CURRENCY),
+		money.Zero(money.DEFAULT_CURRENCY),
+		money.DEFAULT_CURRENCY,
 		"cheque",
+		ACCOUNT_OPEN,
 		0,
 	}
 
@@ -225,12 +236,12 @@ func TestGetAccount(t *testing.T) {
 	ti := time.Now()
 	sqlTime := int32(ti.Unix())
 
-	err := doCreateAccount(sqlTime, &accountDetail, &accountHolderDetail)
+	err := doCreateAccount(Config.Db, sqlTime, &accountDetail, &accountHolderDetail)
 	if err != nil {
 		t.Errorf("GetAccount CreateAccount does not pass. Looking for %v, got %v", nil, err)
 	}
 
-	err = doCreateAccountUser(sqlTime, &accountHolderDetail, &accountDetail)
+	err = doCreateAccountUser(Config.Db, sqlTime, &accountHolderDetail, &accountDetail)
 	if err != nil {
 		t.Errorf("GetAccount CreateAccountMeta does not pass. Looking for %v, got %v", nil, err)
 	}
@@ -251,13 +262,13 @@ func TestGetAccount(t *testing.T) {
 	if getAccountDetails.BankNumber != "" {
 		t.Errorf("GetAccount does not pass. DETAILS. BankNumber: Looking for %v, got %v", "", getAccountDetails.BankNumber)
 	}
-	if !getAccountDetails.Overdraft.Equals(decimal.NewFromFloat(0.)) {
+	if !getAccountDetails.Overdraft.Amount.Equals(decimal.NewFromFloat(0.)) {
 		t.Errorf("GetAccount does not pass. DETAILS. Overdraft: Looking for %v, got %v", decimal.NewFromFloat(0.), getAccountDetails.Overdraft)
 	}
-	if !getAccountDetails.AvailableBalance.Equals(decimal.NewFromFloat(0.)) {
+	if !getAccountDetails.AvailableBalance.Amount.Equals(decimal.NewFromFloat(0.)) {
 		t.Errorf("GetAccount does not pass. DETAILS. AvailableBalance: Looking for %v, got %v", decimal.NewFromFloat(0.), getAccountDetails.AvailableBalance)
 	}
-	if !getAccountDetails.AccountBalance.Equals(decimal.NewFromFloat(0.)) {
+	if !getAccountDetails.AccountBalance.Amount.Equals(decimal.NewFromFloat(0.)) {
 		t.Errorf("GetAccount does not pass. DETAILS. AccountBalance: Looking for %v, got %v", decimal.NewFromFloat(0.), getAccountDetails.AccountBalance)
 	}
 	if getAccountDetails.AccountHolderName != "User,Test" {
@@ -282,10 +293,12 @@ func BenchmarkDoGetAccount(b *testing.B) {
 			"",
 			"",
 			"User,Test",
-			decimal.NewFromFloat(0.),
-			decimal.NewFromFloat(0.),
-			decimal.NewFromFloat(0.),
+			money.Zero(money.DEFAULT_CURRENCY),
+			money.Zero(money.DEFAULT_CURRENCY),
+			money.Zero(money.DEFAULT_CURRENCY),
+			money.DEFAULT_CURRENCY,
 			"cheque",
+			ACCOUNT_OPEN,
 			0,
 		}
 
@@ -306,8 +319,8 @@ func BenchmarkDoGetAccount(b *testing.B) {
 		ti := time.Now()
 		sqlTime := int32(ti.Unix())
 
-		_ = doCreateAccount(sqlTime, &accountDetail, &accountHolderDetail)
-		_ = doCreateAccountUser(sqlTime, &accountHolderDetail, &accountDetail)
+		_ = doCreateAccount(Config.Db, sqlTime, &accountDetail, &accountHolderDetail)
+		_ = doCreateAccountUser(Config.Db, sqlTime, &accountHolderDetail, &accountDetail)
 		// Get account
 		_, _ = getAccountDetails(accountDetail.AccountNumber)
 		_ = doDeleteAccount(&accountDetail)
@@ -320,10 +333,12 @@ func TestGetAccountMeta(t *testing.T) {
 		"",
 		"",
 		"User,Test",
-		decimal.NewFromFloat(0.),
-		decimal.NewFromFloat(0.),
-		decimal.NewFromFloat(0.),
+		money.Zero(money.DEFAULT_CURRENCY),
+		money.Zero(money.DEFAULT_CURRENCY),
+		money.Zero(money.DEFAULT_CURRENCY),
+		money.DEFAULT_CURRENCY,
 		"cheque",
+		ACCOUNT_OPEN,
 		0,
 	}
 
@@ -344,12 +359,12 @@ func TestGetAccountMeta(t *testing.T) {
 	ti := time.Now()
 	sqlTime := int32(ti.Unix())
 
-	err := doCreateAccount(sqlTime, &accountDetail, &accountHolderDetail)
+	err := doCreateAccount(Config.Db, sqlTime, &accountDetail, &accountHolderDetail)
 	if err != nil {
 		t.Errorf("GetAccountMeta CreateAccount does not pass. Looking for %v, got %v", nil, err)
 	}
 
-	err = doCreateAccountUser(sqlTime, &accountHolderDetail, &accountDetail)
+	err = doCreateAccountUser(Config.Db, sqlTime, &accountHolderDetail, &accountDetail)
 	if err != nil {
 		t.Errorf("GetAccountMeta CreateAccountMeta does not pass. Looking for %v, got %v", nil, err)
 	}
@@ -412,10 +427,12 @@ func BenchmarkDoGetAccountMeta(b *testing.B) {
 			"",
 			"",
 			"User,Test",
-			decimal.NewFromFloat(0.),
-			decimal.NewFromFloat(0.),
-			decimal.NewFromFloat(0.),
+			money.Zero(money.DEFAULT_CURRENCY),
+			money.Zero(money.DEFAULT_CURRENCY),
+			money.Zero(money.DEFAULT_CURRENCY),
+			money.DEFAULT_CURRENCY,
 			"cheque",
+			ACCOUNT_OPEN,
 			0,
 		}
 
@@ -436,8 +453,8 @@ func BenchmarkDoGetAccountMeta(b *testing.B) {
 		ti := time.Now()
 		sqlTime := int32(ti.Unix())
 
-		_ = doCreateAccount(sqlTime, &accountDetail, &accountHolderDetail)
-		_ = doCreateAccountUser(sqlTime, &accountHolderDetail, &accountDetail)
+		_ = doCreateAccount(Config.Db, sqlTime, &accountDetail, &accountHolderDetail)
+		_ = doCreateAccountUser(Config.Db, sqlTime, &accountHolderDetail, &accountDetail)
 		// Get account
 		_, _ = getAccountUser(accountHolderDetail.IdentificationNumber)
 		_ = doDeleteAccount(&accountDetail)
@@ -468,10 +485,12 @@ func TestGetSingleAccountDetail(t *testing.T) {
 		"",
 		"",
 		"User,Test",
-		decimal.NewFromFloat(0.),
-		decimal.NewFromFloat(0.),
-		decimal.NewFromFloat(0.),
+		money.Zero(money.DEFAULT_CURRENCY),
+		money.Zero(money.DEFAULT_CURRENCY),
+		money.Zero(money.DEFAULT_CURRENCY),
+		money.DEFAULT_CURRENCY,
 		"cheque",
+		ACCOUNT_OPEN,
 		0,
 	}
 
@@ -492,12 +511,12 @@ func TestGetSingleAccountDetail(t *testing.T) {
 	ti := time.Now()
 	sqlTime := int32(ti.Unix())
 
-	err := doCreateAccount(sqlTime, &accountDetail, &accountHolderDetail)
+	err := doCreateAccount(Config.Db, sqlTime, &accountDetail, &accountHolderDetail)
 	if err != nil {
 		t.Errorf("GetSingleAccountDetail CreateAccount does not pass. Looking for %v, got %v", nil, err)
 	}
 
-	err = doCreateAccountUser(sqlTime, &accountHolderDetail, &accountDetail)
+	err = doCreateAccountUser(Config.Db, sqlTime, &accountHolderDetail, &accountDetail)
 	if err != nil {
 		t.Errorf("GetSingleAccountDetail CreateAccountMeta does not pass. Looking for %v, got %v", nil, err)
 	}
@@ -515,13 +534,13 @@ func TestGetSingleAccountDetail(t *testing.T) {
 	if getAccountDetails.BankNumber != "" {
 		t.Errorf("GetSingleAccountDetail does not pass. DETAILS. BankNumber: Looking for %v, got %v", "", getAccountDetails.BankNumber)
 	}
-	if !getAccountDetails.Overdraft.Equals(decimal.NewFromFloat(0.)) {
+	if !getAccountDetails.Overdraft.Amount.Equals(decimal.NewFromFloat(0.)) {
 		t.Errorf("GetSingleAccountDetail does not pass. DETAILS. Overdraft: Looking for %v, got %v", decimal.NewFromFloat(0.), getAccountDetails.Overdraft)
 	}
-	if !getAccountDetails.AvailableBalance.Equals(decimal.NewFromFloat(0.)) {
+	if !getAccountDetails.AvailableBalance.Amount.Equals(decimal.NewFromFloat(0.)) {
 		t.Errorf("GetSingleAccountDetail does not pass. DETAILS. AvailableBalance: Looking for %v, got %v", decimal.NewFromFloat(0.), getAccountDetails.AvailableBalance)
 	}
-	if !getAccountDetails.AccountBalance.Equals(decimal.NewFromFloat(0.)) {
+	if !getAccountDetails.AccountBalance.Amount.Equals(decimal.NewFromFloat(0.)) {
 		t.Errorf("GetSingleAccountDetail does not pass. DETAILS. AccountBalance: Looking for %v, got %v", decimal.NewFromFloat(0.), getAccountDetails.AccountBalance)
 	}
 	if getAccountDetails.AccountHolderName != "User,Test" {
@@ -545,10 +564,12 @@ func BenchmarkGetSingleAccountDetail(b *testing.B) {
 			"",
 			"",
 			"User,Test",
-			decimal.NewFromFloat(0.),
-			decimal.NewFromFloat(0.),
-			decimal.NewFromFloat(0.),
+			money.Zero(money.DEFAULT_CURRENCY),
+			money.Zero(money.DEFAULT_CURRENCY),
+			money.Zero(money.DEFAULT_CURRENCY),
+			money.DEFAULT_CURRENCY,
 			"cheque",
+			ACCOUNT_OPEN,
 			0,
 		}
 
@@ -569,8 +590,8 @@ func BenchmarkGetSingleAccountDetail(b *testing.B) {
 		ti := time.Now()
 		sqlTime := int32(ti.Unix())
 
-		_ = doCreateAccount(sqlTime, &accountDetail, &accountHolderDetail)
-		_ = doCreateAccountUser(sqlTime, &accountHolderDetail, &accountDetail)
+		_ = doCreateAccount(Config.Db, sqlTime, &accountDetail, &accountHolderDetail)
+		_ = doCreateAccountUser(Config.Db, sqlTime, &accountHolderDetail, &accountDetail)
 		// Do get account call
 		_, _ = getAccountDetails(accountDetail.AccountNumber)
 		_ = doDeleteAccount(&accountDetail)
@@ -583,10 +604,12 @@ func TestGetSingleAccountNumberByID(t *testing.T) {
 		"",
 		"",
 		"User,Test",
-		decimal.NewFromFloat(0.),
-		decimal.NewFromFloat(0.),
-		decimal.NewFromFloat(0.),
+		money.Zero(money.DEFAULT_CURRENCY),
+		money.Zero(money.DEFAULT_CURRENCY),
+		money.Zero(money.DEFAULT_CURRENCY),
+		money.DEFAULT_CURRENCY,
 		"cheque",
+		ACCOUNT_OPEN,
 		0,
 	}
 
@@ -607,12 +630,12 @@ func TestGetSingleAccountNumberByID(t *testing.T) {
 	ti := time.Now()
 	sqlTime := int32(ti.Unix())
 
-	err := doCreateAccount(sqlTime, &accountDetail, &accountHolderDetail)
+	err := doCreateAccount(Config.Db, sqlTime, &accountDetail, &accountHolderDetail)
 	if err != nil {
 		t.Errorf("GetSingleAccountNumberByID CreateAccount does not pass. Looking for %v, got %v", nil, err)
 	}
 
-	err = doCreateAccountUser(sqlTime, &accountHolderDetail, &accountDetail)
+	err = doCreateAccountUser(Config.Db, sqlTime, &accountHolderDetail, &accountDetail)
 	if err != nil {
 		t.Errorf("GetSingleAccountNumberByID CreateAccountMeta does not pass. Looking for %v, got %v", nil, err)
 	}
@@ -647,10 +670,12 @@ func BenchmarkGetSingleAccountNumberByID(b *testing.B) {
 			"",
 			"",
 			"User,Test",
-			decimal.NewFromFloat(0.),
-			decimal.NewFromFloat(0.),
-			decimal.NewFromFloat(0.),
+			money.Zero(money.DEFAULT_CURRENCY),
+			money.Zero(money.DEFAULT_CURRENCY),
+			money.Zero(money.DEFAULT_CURRENCY),
+			money.DEFAULT_CURRENCY,
 			"cheque",
+			ACCOUNT_OPEN,
 			0,
 		}
 
@@ -671,8 +696,8 @@ func BenchmarkGetSingleAccountNumberByID(b *testing.B) {
 		ti := time.Now()
 		sqlTime := int32(ti.Unix())
 
-		_ = doCreateAccount(sqlTime, &accountDetail, &accountHolderDetail)
-		_ = doCreateAccountUser(sqlTime, &accountHolderDetail, &accountDetail)
+		_ = doCreateAccount(Config.Db, sqlTime, &accountDetail, &accountHolderDetail)
+		_ = doCreateAccountUser(Config.Db, sqlTime, &accountHolderDetail, &accountDetail)
 		// Do get account call
 		_, _ = getAllAccountNumbersByID(accountHolderDetail.IdentificationNumber)
 		_ = doDeleteAccount(&accountDetail)
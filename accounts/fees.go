@@ -0,0 +1,79 @@
+package accounts
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/ledger"
+	"github.com/bvnk/bank/money"
+)
+
+/*
+Monthly product fees
+
+acmt~1009~
+   Month
+
+Charges the monthly fee of each account's product for a month (YYYY-MM, last month when
+empty) to every account that is not closed and was opened before the month ended. Each
+account is charged once per month, so the run can be repeated.
+*/
+
+// MonthlyFeeCharge is the monthly fee of an account's product charged for one month
+type MonthlyFeeCharge struct {
+	AccountNumber string
+	Month         string
+	Amount        money.Money
+	JournalID     int64
+	Timestamp     int32
+}
+
+func chargeMonthlyFees(data []string) (result interface{}, err error) {
+	loc := location()
+	now := time.Now().In(loc)
+	month := now.AddDate(0, 0, -now.Day()).Format("2006-01")
+	if len(data) > 3 && strings.TrimRight(data[3], "\x00") != "" {
+		month = strings.TrimRight(data[3], "\x00")
+	}
+
+	_, to, err := feeMonth(month, time.Now(), loc)
+	if err != nil {
+		return "", errors.New("accounts.chargeMonthlyFees: " + err.Error())
+	}
+
+	_, err = appauth.CheckOperator(data[0])
+	if err != nil {
+		return "", errors.New("accounts.chargeMonthlyFees: " + err.Error())
+	}
+
+	charges, err := doChargeMonthlyFees(month, to)
+	if err != nil {
+		return "", errors.New("accounts.chargeMonthlyFees: " + err.Error())
+	}
+
+	return charges, nil
+}
+
+// feeMonth turns a month into [from, to) in the bank's time zone. Only months that are over
+// can be charged
+func feeMonth(month string, now time.Time, loc *time.Location) (from time.Time, to time.Time, err error) {
+	from, err = time.ParseInLocation("2006-01", month, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("accounts.feeMonth: Month must be YYYY-MM")
+	}
+	to = from.AddDate(0, 1, 0)
+	if to.After(now) {
+		return time.Time{}, time.Time{}, errors.New("accounts.feeMonth: Month " + month + " is not over yet")
+	}
+	return
+}
+
+// monthlyFeeJournalEntry books a month's product fee from the account to the bank's fee income
+func monthlyFeeJournalEntry(charge MonthlyFeeCharge) (entry ledger.JournalEntry) {
+	entry = ledger.JournalEntry{Desc: "acmt~1009 Monthly fee " + charge.Month, Timestamp: charge.Timestamp}
+	entry.Lines = append(entry.Lines, ledger.Debit(charge.AccountNumber, charge.Amount.Amount)...)
+	entry.Lines = append(entry.Lines, ledger.Credit(ledger.FEE_INCOME, charge.Amount.Amount)...)
+	return
+}
@@ -0,0 +1,53 @@
+package accounts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bvnk/bank/ledger"
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+func TestProcessAccountMonthlyFees(t *testing.T) {
+	invalid := map[string][]string{
+		"month":         {"", "", "1009", "2017-13"},
+		"current month": {"", "", "1009", time.Now().Format("2006-01")},
+	}
+	for name, tst := range invalid {
+		_, err := ProcessAccount(tst)
+		if err == nil {
+			t.Errorf("ProcessAccount monthly fees %v does not pass. Looking for %v, got %v", name, "error", nil)
+		}
+	}
+}
+
+func TestFeeMonth(t *testing.T) {
+	now := time.Date(2017, 2, 1, 12, 0, 0, 0, time.UTC)
+	from, to, err := feeMonth("2017-01", now, time.UTC)
+	if err != nil || !from.Equal(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2017, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("FeeMonth does not pass. Looking for %v, got %v %v %v", "2017-01-01 - 2017-02-01", from, to, err)
+	}
+
+	_, _, err = feeMonth("2017-02", now, time.UTC)
+	if err == nil {
+		t.Errorf("FeeMonth current month does not pass. Looking for %v, got %v", "Month is not over yet", nil)
+	}
+}
+
+func TestMonthlyFeeJournalEntry(t *testing.T) {
+	charge := MonthlyFeeCharge{
+		AccountNumber: "accountNumber",
+		Month:         "2017-01",
+		Amount:        money.New(decimal.New(5, 0), money.DEFAULT_CURRENCY),
+	}
+	entry := monthlyFeeJournalEntry(charge)
+
+	err := entry.Validate()
+	if err != nil {
+		t.Errorf("MonthlyFeeJournalEntry does not pass. Looking for %v, got %v", nil, err)
+	}
+	if len(entry.Lines) != 2 || entry.Lines[1].LedgerAccount != ledger.FEE_INCOME {
+		t.Errorf("MonthlyFeeJournalEntry does not pass. Looking for %v, got %v", "debit account, credit fee income", entry.Lines)
+	}
+}
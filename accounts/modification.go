@@ -0,0 +1,187 @@
+package accounts
+
+import (
+	"errors"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/bvnk/bank/appauth"
+)
+
+/*
+Account modification instruction (acmt.003) for the account holder's contact details
+
+acmt~3~
+   AccountHolderContactNumber1~
+   AccountHolderContactNumber2~
+   AccountHolderEmailAddress~
+   AccountHolderAddressLine1~
+   AccountHolderAddressLine2~
+   AccountHolderAddressLine3~
+   AccountHolderPostalCode~
+   Password
+
+All fields are sent, a field left as it is stays unchanged. Names, date of birth and
+identification number cannot be changed this way. The password is only needed when a
+sensitive field changes.
+*/
+
+// AccountHolderModification is the outcome of an account modification instruction. Version is
+// the holder details' version after the change
+type AccountHolderModification struct {
+	IdentificationNumber string
+	Version              int
+	ChangedFields        []string
+}
+
+const MAX_ADDRESS_LINE_LENGTH = 255
+
+var (
+	phoneNumberPattern = regexp.MustCompile(`^\+?[0-9 ]{7,20}$`)
+	postalCodePattern  = regexp.MustCompile(`^[A-Za-z0-9 -]{2,10}$`)
+)
+
+// holderField is a field of AccountHolderDetails that acmt~3 can change
+type holderField struct {
+	Name      string
+	Column    string
+	Required  bool
+	Sensitive bool
+	Valid     func(value string) bool
+	Value     func(accountHolderDetails *AccountHolderDetails) *string
+}
+
+var modifiableHolderFields = []holderField{
+	{"ContactNumber1", "accountHolderContactNumber1", true, true, validPhoneNumber, func(d *AccountHolderDetails) *string { return &d.ContactNumber1 }},
+	{"ContactNumber2", "accountHolderContactNumber2", false, false, validPhoneNumber, func(d *AccountHolderDetails) *string { return &d.ContactNumber2 }},
+	{"EmailAddress", "accountHolderEmailAddress", true, true, validEmailAddress, func(d *AccountHolderDetails) *string { return &d.EmailAddress }},
+	{"AddressLine1", "accountHolderAddressLine1", true, false, validAddressLine, func(d *AccountHolderDetails) *string { return &d.AddressLine1 }},
+	{"AddressLine2", "accountHolderAddressLine2", false, false, validAddressLine, func(d *AccountHolderDetails) *string { return &d.AddressLine2 }},
+	{"AddressLine3", "accountHolderAddressLine3", false, false, validAddressLine, func(d *AccountHolderDetails) *string { return &d.AddressLine3 }},
+	{"PostalCode", "accountHolderPostalCode", true, false, validPostalCode, func(d *AccountHolderDetails) *string { return &d.PostalCode }},
+}
+
+// holderChange is a single field moving from its previous value to a new one
+type holderChange struct {
+	Field    holderField
+	Previous string
+	New      string
+}
+
+func modifyAccountHolder(data []string) (result interface{}, err error) {
+	if len(data) < 11 {
+		return "", errors.New("accounts.modifyAccountHolder: Not all fields present")
+	}
+
+	requested := AccountHolderDetails{
+		ContactNumber1: strings.TrimSpace(data[3]),
+		ContactNumber2: strings.TrimSpace(data[4]),
+		EmailAddress:   strings.TrimSpace(data[5]),
+		AddressLine1:   strings.TrimSpace(data[6]),
+		AddressLine2:   strings.TrimSpace(data[7]),
+		AddressLine3:   strings.TrimSpace(data[8]),
+		PostalCode:     strings.TrimSpace(data[9]),
+	}
+	password := strings.TrimRight(data[10], "\x00")
+
+	err = validateHolderDetails(requested)
+	if err != nil {
+		return "", errors.New("accounts.modifyAccountHolder: " + err.Error())
+	}
+
+	// The token user is the account holder's identification number
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("accounts.modifyAccountHolder: " + err.Error())
+	}
+
+	current, version, err := getAccountUserVersion(tokenUser)
+	if err != nil {
+		return "", errors.New("accounts.modifyAccountHolder: " + err.Error())
+	}
+
+	changes := holderChanges(current, requested)
+	modification := AccountHolderModification{IdentificationNumber: tokenUser, Version: version}
+	if len(changes) == 0 {
+		return modification, nil
+	}
+
+	if sensitiveChange(changes) {
+		err = appauth.CheckUserPassword(tokenUser, password)
+		if err != nil {
+			return "", errors.New("accounts.modifyAccountHolder: Password needed to change these details. " + err.Error())
+		}
+	}
+
+	err = doModifyAccountHolder(tokenUser, version, changes)
+	if err != nil {
+		return "", errors.New("accounts.modifyAccountHolder: " + err.Error())
+	}
+
+	modification.Version = version + 1
+	for _, change := range changes {
+		modification.ChangedFields = append(modification.ChangedFields, change.Field.Name)
+	}
+	return modification, nil
+}
+
+// validateHolderDetails checks every modifiable field and names all the fields that are not valid
+func validateHolderDetails(accountHolderDetails AccountHolderDetails) (err error) {
+	invalid := []string{}
+	for _, field := range modifiableHolderFields {
+		value := *field.Value(&accountHolderDetails)
+		if value == "" {
+			if field.Required {
+				invalid = append(invalid, field.Name+" cannot be empty")
+			}
+			continue
+		}
+		if !field.Valid(value) {
+			invalid = append(invalid, field.Name+" is not valid")
+		}
+	}
+
+	if len(invalid) > 0 {
+		return errors.New("accounts.validateHolderDetails: " + strings.Join(invalid, ", "))
+	}
+	return
+}
+
+// holderChanges lists the modifiable fields that differ between the current and requested details
+func holderChanges(current AccountHolderDetails, requested AccountHolderDetails) (changes []holderChange) {
+	for _, field := range modifiableHolderFields {
+		previous := *field.Value(&current)
+		value := *field.Value(&requested)
+		if previous != value {
+			changes = append(changes, holderChange{Field: field, Previous: previous, New: value})
+		}
+	}
+	return
+}
+
+func sensitiveChange(changes []holderChange) bool {
+	for _, change := range changes {
+		if change.Field.Sensitive {
+			return true
+		}
+	}
+	return false
+}
+
+func validPhoneNumber(value string) bool {
+	return phoneNumberPattern.MatchString(value)
+}
+
+func validEmailAddress(value string) bool {
+	address, err := mail.ParseAddress(value)
+	return err == nil && address.Address == value
+}
+
+func validAddressLine(value string) bool {
+	return len(value) <= MAX_ADDRESS_LINE_LENGTH && !strings.ContainsAny(value, "~\n")
+}
+
+func validPostalCode(value string) bool {
+	return postalCodePattern.MatchString(value)
+}
@@ -0,0 +1,83 @@
+package accounts
+
+import (
+	"testing"
+)
+
+func validHolderDetails() AccountHolderDetails {
+	return AccountHolderDetails{
+		ContactNumber1: "+27 11 222 3456",
+		EmailAddress:   "email@domain.com",
+		AddressLine1:   "Physical Address 1",
+		PostalCode:     "1000",
+	}
+}
+
+func TestProcessAccountModify(t *testing.T) {
+	tst := []string{"", "", "3", "1112223456"}
+	_, err := ProcessAccount(tst)
+
+	if err == nil {
+		t.Errorf("ProcessAccount modify does not pass. Looking for %v, got %v", "Not all fields present", nil)
+	}
+
+	tst = []string{"", "", "3", "", "", "not an email", "", "", "", "", ""}
+	_, err = ProcessAccount(tst)
+
+	if err == nil {
+		t.Errorf("ProcessAccount modify does not pass. Looking for %v, got %v", "ContactNumber1 cannot be empty", nil)
+	}
+}
+
+func TestValidateHolderDetails(t *testing.T) {
+	err := validateHolderDetails(validHolderDetails())
+	if err != nil {
+		t.Errorf("ValidateHolderDetails does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	invalid := map[string]func(*AccountHolderDetails){
+		"ContactNumber1 empty":   func(d *AccountHolderDetails) { d.ContactNumber1 = "" },
+		"ContactNumber1 letters": func(d *AccountHolderDetails) { d.ContactNumber1 = "call me" },
+		"ContactNumber2 short":   func(d *AccountHolderDetails) { d.ContactNumber2 = "123" },
+		"EmailAddress empty":     func(d *AccountHolderDetails) { d.EmailAddress = "" },
+		"EmailAddress name":      func(d *AccountHolderDetails) { d.EmailAddress = "Jane <email@domain.com>" },
+		"EmailAddress invalid":   func(d *AccountHolderDetails) { d.EmailAddress = "email@" },
+		"AddressLine1 empty":     func(d *AccountHolderDetails) { d.AddressLine1 = "" },
+		"AddressLine3 separator": func(d *AccountHolderDetails) { d.AddressLine3 = "Block~B" },
+		"PostalCode invalid":     func(d *AccountHolderDetails) { d.PostalCode = "10/00" },
+	}
+	for name, change := range invalid {
+		accountHolderDetails := validHolderDetails()
+		change(&accountHolderDetails)
+		err := validateHolderDetails(accountHolderDetails)
+		if err == nil {
+			t.Errorf("ValidateHolderDetails %v does not pass. Looking for %v, got %v", name, "error", nil)
+		}
+	}
+}
+
+func TestHolderChanges(t *testing.T) {
+	current := validHolderDetails()
+	current.GivenName = "John"
+
+	requested := validHolderDetails()
+	changes := holderChanges(current, requested)
+	if len(changes) != 0 {
+		t.Errorf("HolderChanges does not pass. Looking for %v, got %v", 0, len(changes))
+	}
+
+	requested.AddressLine2 = "Suburb"
+	changes = holderChanges(current, requested)
+	if len(changes) != 1 || changes[0].Field.Name != "AddressLine2" || changes[0].Previous != "" || changes[0].New != "Suburb" {
+		t.Errorf("HolderChanges does not pass. Looking for %v, got %v", "AddressLine2 from '' to Suburb", changes)
+	}
+	if sensitiveChange(changes) {
+		t.Errorf("HolderChanges sensitive does not pass. Looking for %v, got %v", false, true)
+	}
+
+	requested.EmailAddress = "new@domain.com"
+	changes = holderChanges(current, requested)
+	if len(changes) != 2 || !sensitiveChange(changes) {
+		t.Errorf("HolderChanges sensitive does not pass. Looking for %v, got %v", "2 changes, sensitive", changes)
+	}
+}
@@ -0,0 +1,221 @@
+package accounts
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+/*
+Overdraft facilities
+
+acmt~1007~
+   AccountNumber~
+   Limit~
+   Rate~
+   DailyFee
+
+Grants, changes or revokes the overdraft limit of an account. A limit of 0 revokes the
+facility. Rate is the yearly interest rate in percent charged on a negative balance and
+DailyFee is charged for every day the account ends overdrawn. Both are optional, when left
+empty they stay as they are, so an account that is still overdrawn after its facility is
+revoked keeps being charged. The limit and fee are in the account's currency.
+
+acmt~1008~
+   Date
+
+Accrues overdraft interest and fees for a day (YYYY-MM-DD, yesterday when empty) on every
+account whose balance ended that day negative. Each account is charged once per day, so
+the run can be repeated.
+*/
+
+// Overdraft interest is calculated on the actual number of days over a 365 day year
+const OVERDRAFT_DAYS_IN_YEAR = 365
+
+// Largest yearly overdraft interest rate, in percent
+const MAX_OVERDRAFT_RATE = 100
+
+// OverdraftFacility is an account's overdraft limit and what it costs. The available balance
+// includes the limit
+type OverdraftFacility struct {
+	AccountNumber    string
+	Limit            money.Money
+	Rate             decimal.Decimal
+	DailyFee         money.Money
+	AvailableBalance money.Money
+	Currency         string
+	Timestamp        int32
+}
+
+// OverdraftAccrual is the interest and fee charged for one day an account ended overdrawn
+type OverdraftAccrual struct {
+	AccountNumber string
+	Date          string
+	Balance       money.Money
+	Interest      money.Money
+	Fee           money.Money
+	JournalID     int64
+	Timestamp     int32
+}
+
+func setOverdraft(data []string) (result interface{}, err error) {
+	if len(data) < 5 {
+		return "", errors.New("accounts.setOverdraft: Not all fields present")
+	}
+
+	accountNumber := data[3]
+	if accountNumber == "" {
+		return "", errors.New("accounts.setOverdraft: Account number missing")
+	}
+
+	limitStr := strings.TrimSpace(data[4])
+	err = checkAmount(limitStr)
+	if err != nil {
+		return "", errors.New("accounts.setOverdraft: Limit not valid. " + err.Error())
+	}
+
+	// Rate and fee are optional, nil keeps the current value
+	var rate *decimal.Decimal
+	if len(data) > 5 && strings.TrimSpace(data[5]) != "" {
+		value, err := decimal.NewFromString(strings.TrimSpace(data[5]))
+		if err != nil {
+			return "", errors.New("accounts.setOverdraft: Rate not valid. " + err.Error())
+		}
+		if value.Sign() < 0 || value.GreaterThan(decimal.New(MAX_OVERDRAFT_RATE, 0)) {
+			return "", errors.New("accounts.setOverdraft: Rate must be between 0 and 100 percent")
+		}
+		rate = &value
+	}
+	dailyFeeStr := ""
+	if len(data) > 6 {
+		dailyFeeStr = strings.TrimRight(data[6], "\x00")
+	}
+	if dailyFeeStr != "" {
+		err = checkAmount(dailyFeeStr)
+		if err != nil {
+			return "", errors.New("accounts.setOverdraft: Daily fee not valid. " + err.Error())
+		}
+	}
+
+	_, err = appauth.CheckOperator(data[0])
+	if err != nil {
+		return "", errors.New("accounts.setOverdraft: " + err.Error())
+	}
+
+	// The limit and fee are in the currency of the account
+	account, err := getAccountDetails(accountNumber)
+	if err != nil {
+		return "", errors.New("accounts.setOverdraft: " + err.Error())
+	}
+	limit, err := money.NewFromString(limitStr, account.Currency)
+	if err != nil {
+		return "", errors.New("accounts.setOverdraft: Limit not valid. " + err.Error())
+	}
+	var dailyFee *money.Money
+	if dailyFeeStr != "" {
+		value, err := money.NewFromString(dailyFeeStr, account.Currency)
+		if err != nil {
+			return "", errors.New("accounts.setOverdraft: Daily fee not valid. " + err.Error())
+		}
+		dailyFee = &value
+	}
+
+	facility, err := doSetOverdraft(accountNumber, limit, rate, dailyFee)
+	if err != nil {
+		return "", errors.New("accounts.setOverdraft: " + err.Error())
+	}
+
+	return facility, nil
+}
+
+// checkAmount validates an amount that cannot be negative before the currency it is in is
+// known. Its minor units are checked once it is converted
+func checkAmount(amount string) (err error) {
+	value, err := decimal.NewFromString(amount)
+	if err != nil {
+		return errors.New("accounts.checkAmount: " + err.Error())
+	}
+	if value.Sign() < 0 {
+		return errors.New("accounts.checkAmount: Amount cannot be negative")
+	}
+	return
+}
+
+func accrueOverdraftCharges(data []string) (result interface{}, err error) {
+	loc := location()
+	date := time.Now().In(loc).AddDate(0, 0, -1).Format("2006-01-02")
+	if len(data) > 3 && strings.TrimRight(data[3], "\x00") != "" {
+		date = strings.TrimRight(data[3], "\x00")
+	}
+
+	from, to, err := accrualDay(date, time.Now(), loc)
+	if err != nil {
+		return "", errors.New("accounts.accrueOverdraftCharges: " + err.Error())
+	}
+
+	_, err = appauth.CheckOperator(data[0])
+	if err != nil {
+		return "", errors.New("accounts.accrueOverdraftCharges: " + err.Error())
+	}
+
+	accruals, err := doAccrueOverdraftCharges(date, from, to)
+	if err != nil {
+		return "", errors.New("accounts.accrueOverdraftCharges: " + err.Error())
+	}
+
+	return accruals, nil
+}
+
+// accrualDay turns a date into [from, to) in the bank's time zone. Only days that are over
+// can be accrued
+func accrualDay(date string, now time.Time, loc *time.Location) (from time.Time, to time.Time, err error) {
+	from, err = time.ParseInLocation("2006-01-02", date, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("accounts.accrualDay: Date must be YYYY-MM-DD")
+	}
+	to = from.AddDate(0, 0, 1)
+	if to.After(now) {
+		return time.Time{}, time.Time{}, errors.New("accounts.accrualDay: Day " + date + " is not over yet")
+	}
+	return
+}
+
+// location is the bank's time zone, which decides where accrual days begin and end
+func location() *time.Location {
+	loc, err := time.LoadLocation(Config.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// overdraftCharges works out a day's interest and fee on the balance the account ended the day
+// with. Nothing is charged on a balance that is not negative
+func overdraftCharges(balance money.Money, rate decimal.Decimal, dailyFee money.Money) (interest money.Money, fee money.Money) {
+	interest = money.Zero(balance.Currency)
+	fee = money.Zero(balance.Currency)
+	if balance.Sign() >= 0 {
+		return
+	}
+
+	dailyRate := rate.Div(decimal.New(100*OVERDRAFT_DAYS_IN_YEAR, 0))
+	interest = balance.Neg().MulRate(dailyRate)
+	fee = money.New(dailyFee.Amount, balance.Currency)
+	return
+}
+
+// inCurrency puts the amounts of the facility read from the database in the account's currency
+func (facility *OverdraftFacility) inCurrency() {
+	facility.Limit = facility.Limit.In(facility.Currency)
+	facility.DailyFee = facility.DailyFee.In(facility.Currency)
+	facility.AvailableBalance = facility.AvailableBalance.In(facility.Currency)
+}
+
+// availableAfterLimitChange moves the available balance by the change in the overdraft limit
+func availableAfterLimitChange(available money.Money, previousLimit money.Money, limit money.Money) money.Money {
+	return available.Add(limit.Sub(previousLimit))
+}
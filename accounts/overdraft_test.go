@@ -0,0 +1,102 @@
+package accounts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bvnk/bank/ledger"
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+func TestProcessAccountOverdraft(t *testing.T) {
+	tst := []string{"", "", "1007", "accountNumber"}
+	_, err := ProcessAccount(tst)
+	if err == nil {
+		t.Errorf("ProcessAccount overdraft does not pass. Looking for %v, got %v", "Not all fields present", nil)
+	}
+
+	invalid := map[string][]string{
+		"negative limit": {"", "", "1007", "accountNumber", "-100"},
+		"limit":          {"", "", "1007", "accountNumber", "one"},
+		"rate":           {"", "", "1007", "accountNumber", "100", "101"},
+		"daily fee":      {"", "", "1007", "accountNumber", "100", "10", "-1"},
+	}
+	for name, tst := range invalid {
+		_, err := ProcessAccount(tst)
+		if err == nil {
+			t.Errorf("ProcessAccount overdraft %v does not pass. Looking for %v, got %v", name, "error", nil)
+		}
+	}
+
+	tst = []string{"", "", "1008", "2017-13-01"}
+	_, err = ProcessAccount(tst)
+	if err == nil {
+		t.Errorf("ProcessAccount overdraft accrual does not pass. Looking for %v, got %v", "Date must be YYYY-MM-DD", nil)
+	}
+}
+
+func TestOverdraftCharges(t *testing.T) {
+	usd := func(amount string) money.Money {
+		return money.New(decimal.RequireFromString(amount), money.DEFAULT_CURRENCY)
+	}
+
+	// 18.25% a year on 1000.00 is 0.50 a day
+	interest, fee := overdraftCharges(usd("-1000"), decimal.RequireFromString("18.25"), usd("1.5"))
+	if interest.StringFixed() != "0.50" || fee.StringFixed() != "1.50" {
+		t.Errorf("OverdraftCharges does not pass. Looking for %v, got %v", "0.50 1.50", interest.StringFixed()+" "+fee.StringFixed())
+	}
+
+	interest, fee = overdraftCharges(usd("0"), decimal.RequireFromString("18.25"), usd("1.5"))
+	if !interest.IsZero() || !fee.IsZero() {
+		t.Errorf("OverdraftCharges positive balance does not pass. Looking for %v, got %v", "0.00 0.00", interest.StringFixed()+" "+fee.StringFixed())
+	}
+}
+
+func TestAvailableAfterLimitChange(t *testing.T) {
+	usd := func(amount int64) money.Money {
+		return money.New(decimal.New(amount, 0), money.DEFAULT_CURRENCY)
+	}
+
+	available := availableAfterLimitChange(usd(50), usd(0), usd(200))
+	if available.Cmp(usd(250)) != 0 {
+		t.Errorf("AvailableAfterLimitChange grant does not pass. Looking for %v, got %v", "250.00", available.StringFixed())
+	}
+
+	// Revoking the facility of an overdrawn account leaves nothing to spend
+	available = availableAfterLimitChange(usd(150), usd(200), usd(0))
+	if available.Cmp(usd(-50)) != 0 {
+		t.Errorf("AvailableAfterLimitChange revoke does not pass. Looking for %v, got %v", "-50.00", available.StringFixed())
+	}
+}
+
+func TestAccrualDay(t *testing.T) {
+	now := time.Date(2017, 1, 2, 12, 0, 0, 0, time.UTC)
+	from, to, err := accrualDay("2017-01-01", now, time.UTC)
+	if err != nil || !from.Equal(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2017, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("AccrualDay does not pass. Looking for %v, got %v %v %v", "2017-01-01 - 2017-01-02", from, to, err)
+	}
+
+	_, _, err = accrualDay("2017-01-02", now, time.UTC)
+	if err == nil {
+		t.Errorf("AccrualDay today does not pass. Looking for %v, got %v", "Day is not over yet", nil)
+	}
+}
+
+func TestOverdraftJournalEntry(t *testing.T) {
+	accrual := OverdraftAccrual{
+		AccountNumber: "accountNumber",
+		Date:          "2017-01-01",
+		Interest:      money.New(decimal.New(50, -2), money.DEFAULT_CURRENCY),
+		Fee:           money.Zero(money.DEFAULT_CURRENCY),
+	}
+	entry := overdraftJournalEntry(accrual)
+
+	err := entry.Validate()
+	if err != nil {
+		t.Errorf("OverdraftJournalEntry does not pass. Looking for %v, got %v", nil, err)
+	}
+	if len(entry.Lines) != 2 || entry.Lines[1].LedgerAccount != ledger.INTEREST_INCOME {
+		t.Errorf("OverdraftJournalEntry does not pass. Looking for %v, got %v", "debit account, credit interest income", entry.Lines)
+	}
+}
@@ -0,0 +1,159 @@
+package accounts
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/iso20022"
+	"github.com/bvnk/bank/money"
+	"github.com/bvnk/bank/products"
+	"github.com/satori/go.uuid"
+)
+
+/*
+Account report request (acmt.013), answered with an account report (acmt.014)
+
+acmt~13~
+   AccountType~
+   Format
+
+Reports every account of the token user. AccountType is optional and limits the report to
+accounts of that type. Format is json (default) or xml, which returns an acmt.014 document.
+*/
+
+// AccountReport lists all the accounts of a holder
+type AccountReport struct {
+	ReportID             string
+	IdentificationNumber string
+	AccountType          string
+	Accounts             []AccountReportEntry
+	Timestamp            int32
+}
+
+// AccountReportEntry is an account with the date it was opened, the ISO 20022 cash account
+// type of its product and the merchant it is linked to, if any
+type AccountReportEntry struct {
+	AccountDetails
+	CashAccountType  string
+	OpeningTimestamp int32
+	ClosedTimestamp  int32
+	MerchantID       string
+	MerchantName     string
+}
+
+func accountReport(data []string) (result interface{}, err error) {
+	accountType := ""
+	if len(data) > 3 {
+		accountType = data[3]
+	}
+	if accountType != "" && !products.ValidCode(accountType) {
+		return "", errors.New("accounts.accountReport: Account type not valid, must be a product code")
+	}
+
+	format := "json"
+	if len(data) > 4 && data[4] != "" {
+		format = data[4]
+	}
+	if format != "json" && format != "xml" {
+		return "", errors.New("accounts.accountReport: Format must be json or xml")
+	}
+
+	// The token user is the account holder's identification number
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("accounts.accountReport: " + err.Error())
+	}
+
+	entries, err := getUserAccountsReport(tokenUser, accountType)
+	if err != nil {
+		return "", errors.New("accounts.accountReport: " + err.Error())
+	}
+
+	newUuid, err := uuid.NewV4()
+	if err != nil {
+		return "", errors.New("accounts.accountReport: Could not generate report ID. " + err.Error())
+	}
+
+	report := AccountReport{
+		ReportID:             iso20022.MessageID(newUuid.String()),
+		IdentificationNumber: tokenUser,
+		AccountType:          accountType,
+		Accounts:             entries,
+		Timestamp:            int32(time.Now().Unix()),
+	}
+
+	if format == "xml" {
+		return iso20022.Marshal(reportToAcmt014(report))
+	}
+	return report, nil
+}
+
+// reportToAcmt014 maps a report onto an acmt.014 account report
+func reportToAcmt014(report AccountReport) (document iso20022.Acmt014Document) {
+	reportTime := time.Unix(int64(report.Timestamp), 0)
+
+	document.Xmlns = iso20022.ACMT_014_NAMESPACE
+	document.AcctRpt.Refs.MsgId = iso20022.MessageIdentification{Id: report.ReportID, CreDtTm: iso20022.ISODateTime(reportTime)}
+	document.AcctRpt.AcctOwnr = &iso20022.PartyIdentification{Nm: report.IdentificationNumber}
+	for _, entry := range report.Accounts {
+		document.AcctRpt.Acct = append(document.AcctRpt.Acct, entryToAcmt014(entry, reportTime))
+	}
+	return
+}
+
+func entryToAcmt014(entry AccountReportEntry, reportTime time.Time) (account iso20022.CustomerAccount) {
+	account = iso20022.CustomerAccount{
+		Id:  iso20022.AccountIdentification{Othr: &iso20022.GenericIdentification{Id: entry.AccountNumber}},
+		Nm:  entry.AccountHolderName,
+		Sts: iso20022.ACCOUNT_ENABLED,
+		Tp:  &iso20022.CashAccountType{Cd: entry.CashAccountType},
+		Ccy: entry.AccountBalance.Currency,
+	}
+	if account.Tp.Cd == "" {
+		// Types without a standard code are reported by name
+		account.Tp = &iso20022.CashAccountType{Prtry: entry.Type}
+	}
+	switch entry.Status {
+	case ACCOUNT_RESTRICTED:
+		account.Sts = iso20022.ACCOUNT_PENDING_OPENING
+	case ACCOUNT_CLOSED:
+		account.Sts = iso20022.ACCOUNT_DISABLED
+	}
+	if entry.OpeningTimestamp != 0 {
+		account.OpngDt = iso20022.ISODate(time.Unix(int64(entry.OpeningTimestamp), 0))
+	}
+	if entry.ClosedTimestamp != 0 {
+		account.ClsgDt = iso20022.ISODate(time.Unix(int64(entry.ClosedTimestamp), 0))
+	}
+
+	booked := reportBalance(iso20022.BALANCE_INTERIM_BOOKED, entry.AccountBalance, reportTime)
+	available := reportBalance(iso20022.BALANCE_INTERIM_AVAILABLE, entry.AvailableBalance, reportTime)
+	if !entry.Overdraft.IsZero() {
+		// The available balance includes the overdraft
+		available.CdtLine = &iso20022.CreditLine{
+			Incl: true,
+			Amt:  &iso20022.ActiveOrHistoricCurrencyAndAmount{Value: entry.Overdraft.StringFixed(), Ccy: entry.Overdraft.Currency},
+		}
+	}
+	account.Bal = []iso20022.CashBalance{booked, available}
+
+	if entry.MerchantID != "" {
+		account.Mrchnt = &iso20022.MerchantIdentification{Id: entry.MerchantID, Nm: entry.MerchantName}
+	}
+	return
+}
+
+// reportBalance builds a balance of the report, negative balances are debits
+func reportBalance(balanceType string, balance money.Money, date time.Time) iso20022.CashBalance {
+	indicator := iso20022.CREDIT
+	if balance.Sign() < 0 {
+		indicator = iso20022.DEBIT
+	}
+	return iso20022.CashBalance{
+		Tp:        iso20022.BalanceType{CdOrPrtry: iso20022.BalanceTypeCode{Cd: balanceType}},
+		Amt:       iso20022.ActiveOrHistoricCurrencyAndAmount{Value: money.New(balance.Amount.Abs(), balance.Currency).StringFixed(), Ccy: balance.Currency},
+		CdtDbtInd: indicator,
+		Dt:        iso20022.DateAndDateTime{Dt: iso20022.ISODate(date)},
+	}
+}
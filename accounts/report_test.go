@@ -0,0 +1,87 @@
+package accounts
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bvnk/bank/iso20022"
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+func TestProcessAccountReport(t *testing.T) {
+	tst := []string{"", "", "13", "Current Account"}
+	_, err := ProcessAccount(tst)
+
+	if err == nil {
+		t.Errorf("ProcessAccount report does not pass. Looking for %v, got %v", "Account type not valid", nil)
+	}
+
+	tst = []string{"", "", "13", "savings", "pdf"}
+	_, err = ProcessAccount(tst)
+
+	if err == nil {
+		t.Errorf("ProcessAccount report does not pass. Looking for %v, got %v", "Format must be json or xml", nil)
+	}
+}
+
+func TestReportToAcmt014(t *testing.T) {
+	report := AccountReport{
+		ReportID:             "reportID",
+		IdentificationNumber: "idNumber",
+		Timestamp:            1483228800,
+		Accounts: []AccountReportEntry{
+			{
+				AccountDetails: AccountDetails{
+					AccountNumber:     "accountNum",
+					AccountHolderName: "Family,Given",
+					AccountBalance:    money.New(decimal.RequireFromString("-10"), "USD"),
+					Overdraft:         money.New(decimal.RequireFromString("100"), "USD"),
+					AvailableBalance:  money.New(decimal.RequireFromString("90"), "USD"),
+					Type:              "cheque",
+					Status:            ACCOUNT_OPEN,
+				},
+				CashAccountType:  "CACC",
+				OpeningTimestamp: 1451606400,
+				MerchantID:       "merchantID",
+				MerchantName:     "Merchant",
+			},
+			{
+				AccountDetails: AccountDetails{
+					AccountNumber:    "accountNumClosed",
+					AccountBalance:   money.Zero("USD"),
+					Overdraft:        money.Zero("USD"),
+					AvailableBalance: money.Zero("USD"),
+					Type:             "ira",
+					Status:           ACCOUNT_CLOSED,
+				},
+				ClosedTimestamp: 1483228800,
+			},
+		},
+	}
+
+	document, err := iso20022.Marshal(reportToAcmt014(report))
+	if err != nil {
+		t.Errorf("ReportToAcmt014 does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	expected := []string{
+		iso20022.ACMT_014_NAMESPACE,
+		"<Id>reportID</Id>",
+		"<Cd>CACC</Cd>",
+		"<Prtry>ira</Prtry>",
+		"<Sts>ENAB</Sts>",
+		"<Sts>DISA</Sts>",
+		"<OpngDt>2016-01-01</OpngDt>",
+		"<ClsgDt>2017-01-01</ClsgDt>",
+		"<Amt Ccy=\"USD\">10.00</Amt>",
+		"<CdtDbtInd>DBIT</CdtDbtInd>",
+		"<Amt Ccy=\"USD\">100.00</Amt>",
+		"<Id>merchantID</Id>",
+	}
+	for _, part := range expected {
+		if !strings.Contains(document, part) {
+			t.Errorf("ReportToAcmt014 does not pass. Looking for %v, got %v", part, document)
+		}
+	}
+}
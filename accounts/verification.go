@@ -0,0 +1,116 @@
+package accounts
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/iso20022"
+	"github.com/bvnk/bank/kyc"
+	"github.com/satori/go.uuid"
+)
+
+/*
+Identification verification request (acmt.023) and report (acmt.024)
+
+acmt~23
+acmt~24
+
+acmt~23 asks the configured verifier to check the token user's identification, using the
+details they opened their account with. Until the holder is verified their accounts are
+restricted and cannot make payments. acmt~24 returns the latest verification report.
+*/
+
+// IdentificationVerification is an identification verification report (acmt.024)
+type IdentificationVerification struct {
+	VerificationID       string
+	IdentificationNumber string
+	Verifier             string
+	Status               string
+	Reason               string
+	Reference            string
+	Timestamp            int32
+}
+
+func verifyAccountHolder(data []string) (result interface{}, err error) {
+	// The token user is the account holder's identification number
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("accounts.verifyAccountHolder: " + err.Error())
+	}
+
+	accountHolderDetails, err := getAccountUser(tokenUser)
+	if err != nil {
+		return "", errors.New("accounts.verifyAccountHolder: " + err.Error())
+	}
+	if accountHolderDetails == (AccountHolderDetails{}) {
+		return "", errors.New("accounts.verifyAccountHolder: Account holder not found")
+	}
+
+	verificationStatus, err := getAccountUserVerificationStatus(tokenUser)
+	if err != nil {
+		return "", errors.New("accounts.verifyAccountHolder: " + err.Error())
+	}
+	if verificationStatus == kyc.STATUS_VERIFIED {
+		return "", errors.New("accounts.verifyAccountHolder: Account holder already verified")
+	}
+
+	outcome, err := kyc.Verify(kyc.VerificationRequest{
+		IdentificationNumber: accountHolderDetails.IdentificationNumber,
+		GivenName:            accountHolderDetails.GivenName,
+		FamilyName:           accountHolderDetails.FamilyName,
+		DateOfBirth:          accountHolderDetails.DateOfBirth,
+	})
+	if err != nil {
+		return "", errors.New("accounts.verifyAccountHolder: " + err.Error())
+	}
+
+	newUuid, err := uuid.NewV4()
+	if err != nil {
+		return "", errors.New("accounts.verifyAccountHolder: Could not generate verification ID. " + err.Error())
+	}
+
+	verification := IdentificationVerification{
+		VerificationID:       iso20022.MessageID(newUuid.String()),
+		IdentificationNumber: tokenUser,
+		Verifier:             Config.KYCVerifier,
+		Status:               outcome.Status,
+		Reason:               outcome.Reason,
+		Reference:            outcome.Reference,
+		Timestamp:            int32(time.Now().Unix()),
+	}
+
+	err = doRecordVerification(verification)
+	if err != nil {
+		return "", errors.New("accounts.verifyAccountHolder: " + err.Error())
+	}
+
+	return verification, nil
+}
+
+func identificationVerificationReport(data []string) (result interface{}, err error) {
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("accounts.identificationVerificationReport: " + err.Error())
+	}
+
+	verification, err := getLatestVerification(tokenUser)
+	if err != nil {
+		return "", errors.New("accounts.identificationVerificationReport: " + err.Error())
+	}
+
+	// Holders who never asked to be verified have no report yet
+	if verification.VerificationID == "" {
+		verification = IdentificationVerification{IdentificationNumber: tokenUser, Status: kyc.STATUS_UNVERIFIED}
+	}
+
+	return verification, nil
+}
+
+// accountStatusForHolder is the status new accounts of a holder open with
+func accountStatusForHolder(verificationStatus string) string {
+	if verificationStatus == kyc.STATUS_VERIFIED {
+		return ACCOUNT_OPEN
+	}
+	return ACCOUNT_RESTRICTED
+}
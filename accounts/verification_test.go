@@ -0,0 +1,37 @@
+package accounts
+
+import (
+	"testing"
+
+	"github.com/bvnk/bank/kyc"
+)
+
+func TestSetAccountHolderDetailsIdentification(t *testing.T) {
+	invalid := map[string][]string{
+		"date of birth":         {"", "", "", "John", "Doe", "19000101", "010119001234123", "111", "222", "user@domain.com", "address 1", "address 2", "address 3", "2000", "cheque"},
+		"future date of birth":  {"", "", "", "John", "Doe", "01013000", "010119001234123", "111", "222", "user@domain.com", "address 1", "address 2", "address 3", "2000", "cheque"},
+		"identification number": {"", "", "", "John", "Doe", "01011900", "0101~1900", "111", "222", "user@domain.com", "address 1", "address 2", "address 3", "2000", "cheque"},
+	}
+	for name, tst := range invalid {
+		_, err := setAccountHolderDetails(tst)
+		if err == nil {
+			t.Errorf("SetAccountHolderDetails %v does not pass. Looking for %v, got %v", name, "error", nil)
+		}
+	}
+}
+
+func TestAccountStatusForHolder(t *testing.T) {
+	statuses := map[string]string{
+		"":                    ACCOUNT_RESTRICTED,
+		kyc.STATUS_UNVERIFIED: ACCOUNT_RESTRICTED,
+		kyc.STATUS_PENDING:    ACCOUNT_RESTRICTED,
+		kyc.STATUS_REJECTED:   ACCOUNT_RESTRICTED,
+		kyc.STATUS_VERIFIED:   ACCOUNT_OPEN,
+	}
+	for verificationStatus, expected := range statuses {
+		status := accountStatusForHolder(verificationStatus)
+		if status != expected {
+			t.Errorf("AccountStatusForHolder %v does not pass. Looking for %v, got %v", verificationStatus, expected, status)
+		}
+	}
+}
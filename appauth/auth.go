@@ -1,6 +1,7 @@
 package appauth
 
 import (
+	"crypto/subtle"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -20,6 +21,12 @@ const (
 	LETTER_BYTES        = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 )
 
+// User roles. Operators run the bank, every other user is a customer
+const (
+	ROLE_CUSTOMER = "customer"
+	ROLE_OPERATOR = "operator"
+)
+
 var Config configuration.Configuration
 
 func SetConfig(config *configuration.Configuration) {
@@ -228,6 +235,32 @@ func RemoveUserPassword(user string, clearTextPassword string) (result string, e
 	return
 }
 
+// CheckUserPassword authenticates a user again by password, for changes where holding a token
+// is not enough
+func CheckUserPassword(user string, clearTextPassword string) (err error) {
+	userHashedPassword, userSalt, err := getUserPasswordSaltFromUID(user)
+	if err != nil {
+		return errors.New("appauth.CheckUserPassword: Could not retrieve user details. " + err.Error())
+	}
+	if userHashedPassword == "" {
+		return errors.New("appauth.CheckUserPassword: Authentication credentials invalid")
+	}
+
+	// Generate hash
+	userPasswordSalt := userSalt + clearTextPassword
+	hashOutput, err := argon2.Key([]byte(userPasswordSalt), []byte(Config.PasswordSalt), 3, 4, 4096, 64, argon2.Argon2i)
+	if err != nil {
+		return errors.New("appauth.CheckUserPassword: Could not generate secure hash. " + err.Error())
+	}
+	hash := hex.EncodeToString(hashOutput)
+
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(userHashedPassword)) != 1 {
+		return errors.New("appauth.CheckUserPassword: Authentication credentials invalid")
+	}
+
+	return
+}
+
 func CreateToken(authUser string, password string) (token string, err error) {
 	rows, err := Config.Db.Query("SELECT `password`, `salt`, `accountHolderIdentificationNumber` FROM `accounts_user_auth` WHERE `authUser` = ?", authUser)
 	if err != nil {
@@ -324,6 +357,26 @@ func GetUserFromToken(token string) (user string, err error) {
 	return
 }
 
+// CheckOperator returns the user of a token held by a bank operator. Bank operations call it
+// before they touch any data, so customer tokens are turned away whether the request comes
+// over the TCP protocol or the HTTP API
+func CheckOperator(token string) (user string, err error) {
+	user, err = GetUserFromToken(token)
+	if err != nil {
+		return "", errors.New("appauth.CheckOperator: " + err.Error())
+	}
+
+	role, err := getUserRole(user)
+	if err != nil {
+		return "", errors.New("appauth.CheckOperator: " + err.Error())
+	}
+	if role != ROLE_OPERATOR {
+		return "", errors.New("appauth.CheckOperator: Operator role required")
+	}
+
+	return
+}
+
 func RandStringBytes(n int) string {
 	b := make([]byte, n)
 	for i := range b {
@@ -349,3 +402,21 @@ func getUserPasswordSaltFromUID(user string) (hashedPassword string, userSalt st
 
 	return
 }
+
+// getUserRole returns the role of a user, customer when the user has no login
+func getUserRole(user string) (role string, err error) {
+	rows, err := Config.Db.Query("SELECT `role` FROM `accounts_user_auth` WHERE `accountHolderIdentificationNumber` = ?", user)
+	if err != nil {
+		return "", errors.New("appauth.getUserRole: Error with select query. " + err.Error())
+	}
+	defer rows.Close()
+
+	role = ROLE_CUSTOMER
+	if rows.Next() {
+		if err := rows.Scan(&role); err != nil {
+			return "", errors.New("appauth.getUserRole: Could not retrieve role")
+		}
+	}
+
+	return
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sync"
+
+	"github.com/bvnk/bank/configuration"
+	"github.com/bvnk/bank/iso8583"
+	"github.com/bvnk/bank/transactions"
+)
+
+// runServer is restarted in a loop, the card listener only starts once
+var cardServerOnce sync.Once
+
+// runCardServer listens for ISO 8583 card messages on the configured card port. Acquirers
+// must present a client certificate signed by one of the CardAcquirerCA certificates. Without
+// TLS anyone who reaches the port could submit payments, so no-tls mode is refused unless
+// CardTestMode is set
+func runCardServer(mode string, bankConfig configuration.Configuration) (err error) {
+	port := bankConfig.CardPort
+	var l net.Listener
+	switch mode {
+	case "tls":
+		cert, err := tls.LoadX509KeyPair(configuration.ImportPath+"certs/server.pem", configuration.ImportPath+"certs/server.key")
+		if err != nil {
+			return errors.New("server.runCardServer: " + err.Error())
+		}
+		acquirers, err := acquirerCertPool(bankConfig.CardAcquirerCA)
+		if err != nil {
+			return errors.New("server.runCardServer: " + err.Error())
+		}
+		config := tls.Config{Certificates: []tls.Certificate{cert}, ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: acquirers}
+		config.Rand = rand.Reader
+		l, err = tls.Listen(CONN_TYPE, CONN_HOST+":"+port, &config)
+		if err != nil {
+			return errors.New("server.runCardServer: " + err.Error())
+		}
+	default:
+		if !bankConfig.CardTestMode {
+			return errors.New("server.runCardServer: Card messages need TLS, set CardTestMode to listen without it")
+		}
+		l, err = net.Listen(CONN_TYPE, CONN_HOST+":"+port)
+		if err != nil {
+			return errors.New("server.runCardServer: " + err.Error())
+		}
+	}
+
+	defer l.Close()
+	bLog(0, "Listening for card messages on "+CONN_HOST+":"+port, trace())
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return errors.New("server.runCardServer: " + err.Error())
+		}
+		go handleCardConnection(conn)
+	}
+}
+
+// handleCardConnection answers the messages on a connection in the order they arrive, until
+// the acquirer closes it or sends something that cannot be read
+func handleCardConnection(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		request, err := iso8583.ReadMessage(conn)
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			bLog(2, "Card message not readable. "+err.Error(), trace())
+			return
+		}
+
+		response, err := transactions.ProcessISO8583(request)
+		if err != nil {
+			bLog(1, err.Error(), trace())
+		}
+
+		err = iso8583.WriteMessage(conn, response)
+		if err != nil {
+			bLog(2, "Card response not sent. "+err.Error(), trace())
+			return
+		}
+	}
+}
+
+// acquirerCertPool loads the CA certificates acquirers' client certificates are verified
+// against. Paths that are not absolute are relative to the bank's directory
+func acquirerCertPool(path string) (pool *x509.CertPool, err error) {
+	if path == "" {
+		return nil, errors.New("server.acquirerCertPool: CardAcquirerCA must be set to accept card messages over TLS")
+	}
+	if !filepath.IsAbs(path) {
+		path = configuration.ImportPath + path
+	}
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.New("server.acquirerCertPool: " + err.Error())
+	}
+
+	pool = x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("server.acquirerCertPool: No certificates found in CardAcquirerCA")
+	}
+
+	return
+}
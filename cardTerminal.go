@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/bvnk/bank/configuration"
+	"github.com/bvnk/bank/iso8583"
+	"github.com/bvnk/bank/money"
+	"github.com/bvnk/bank/vault"
+)
+
+// Identifies the simulated acquirer and terminal in the messages they send
+const (
+	TERMINAL_ACQUIRER_ID = "123456"
+	TERMINAL_ID          = "TERM0001"
+)
+
+// cardTerminal is a test terminal that sends card messages to the card server, to exercise
+// it locally
+type cardTerminal struct {
+	conn         net.Conn
+	version      byte
+	stan         int
+	pan          string
+	expiry       string
+	cardAcceptor string
+	currency     string
+}
+
+// runCardTerminal runs through a day at a terminal against a local card server: an echo, an
+// authorisation and its completion, a cancelled authorisation, and a purchase that is
+// reversed and the reversal repeated. The card's token and expiry (YYMM) and the card acceptor
+// ID (mapped to a merchant account in CardAcceptors) are given as arguments, optionally
+// followed by the version to speak, 1987 or 1993, and the currency of the card's account (USD
+// by default). The terminal gets the PAN it sends from the vault
+func runCardTerminal(mode string, token string, expiry string, cardAcceptor string, version string, currency string) (err error) {
+	if token == "" || expiry == "" || cardAcceptor == "" {
+		return errors.New("cardTerminal.runCardTerminal: Usage: cardTerminal <card token> <expiry YYMM> <card acceptor ID> [1987|1993] [currency]")
+	}
+	currency, err = money.ParseCurrency(currency)
+	if err != nil {
+		return errors.New("cardTerminal.runCardTerminal: " + err.Error())
+	}
+
+	Config, err := configuration.LoadConfig()
+	if err != nil {
+		return errors.New("cardTerminal.runCardTerminal: " + err.Error())
+	}
+	if Config.CardPort == "" {
+		return errors.New("cardTerminal.runCardTerminal: No CardPort configured")
+	}
+
+	vault.SetConfig(&Config)
+	pan, err := vault.Detokenise(token, vault.CALLER_CARD_TERMINAL)
+	if err != nil {
+		return errors.New("cardTerminal.runCardTerminal: " + err.Error())
+	}
+
+	terminal := cardTerminal{version: iso8583.VERSION_1987, pan: pan, expiry: expiry, cardAcceptor: cardAcceptor, currency: money.NumericCode(currency)}
+	if version == "1993" {
+		terminal.version = iso8583.VERSION_1993
+	}
+
+	switch mode {
+	case "tls":
+		cert, err := tls.LoadX509KeyPair(configuration.ImportPath+"certs/client.pem", configuration.ImportPath+"certs/client.key")
+		if err != nil {
+			return errors.New("cardTerminal.runCardTerminal: " + err.Error())
+		}
+		config := tls.Config{Certificates: []tls.Certificate{cert}, InsecureSkipVerify: true}
+		terminal.conn, err = tls.Dial(CONN_TYPE, CONN_HOST+":"+Config.CardPort, &config)
+		if err != nil {
+			return errors.New("cardTerminal.runCardTerminal: " + err.Error())
+		}
+	default:
+		terminal.conn, err = net.Dial(CONN_TYPE, CONN_HOST+":"+Config.CardPort)
+		if err != nil {
+			return errors.New("cardTerminal.runCardTerminal: " + err.Error())
+		}
+	}
+	defer terminal.conn.Close()
+
+	fmt.Println("Card terminal " + TERMINAL_ID + " on " + CONN_HOST + ":" + Config.CardPort)
+
+	// Echo
+	echo := terminal.message("800")
+	if terminal.version == iso8583.VERSION_1993 {
+		echo.MTI = "1804"
+		echo.Set(iso8583.FIELD_FUNCTION_CODE, "831")
+	} else {
+		echo.Set(iso8583.FIELD_NETWORK_CODE, "301")
+	}
+	_, err = terminal.send(echo)
+	if err != nil {
+		return err
+	}
+
+	// Authorisation of 12.50, completed for 10.00
+	authorisation, err := terminal.send(terminal.purchase("100", 1250))
+	if err != nil {
+		return err
+	}
+	completion := terminal.purchase("200", 1000)
+	terminal.setOriginal(&completion, authorisation)
+	_, err = terminal.send(completion)
+	if err != nil {
+		return err
+	}
+
+	// Authorisation of 5.00, reversed when the customer walks away
+	authorisation, err = terminal.send(terminal.purchase("100", 500))
+	if err != nil {
+		return err
+	}
+	reversal := terminal.message("400")
+	terminal.setOriginal(&reversal, authorisation)
+	_, err = terminal.send(reversal)
+	if err != nil {
+		return err
+	}
+
+	// Purchase of 2.50 without authorisation, reversed by advice after a timeout. The advice
+	// is repeated as if its response was lost, and gets the same answer
+	purchase, err := terminal.send(terminal.purchase("200", 250))
+	if err != nil {
+		return err
+	}
+	advice := terminal.message("420")
+	terminal.setOriginal(&advice, purchase)
+	_, err = terminal.send(advice)
+	if err != nil {
+		return err
+	}
+	advice.MTI = advice.MTI[:3] + "1"
+	_, err = terminal.send(advice)
+	if err != nil {
+		return err
+	}
+
+	return
+}
+
+// message starts a message of the terminal's version with the next STAN
+func (t *cardTerminal) message(mti string) iso8583.Message {
+	t.stan++
+	now := time.Now()
+	m := iso8583.NewMessage(string(t.version) + mti)
+	m.Set(iso8583.FIELD_TRANSMISSION_TIME, now.UTC().Format("0102150405"))
+	m.Set(iso8583.FIELD_STAN, iso8583.PadNumber(strconv.Itoa(t.stan%1000000), 6))
+	if t.version == iso8583.VERSION_1993 {
+		m.Set(iso8583.FIELD_LOCAL_TIME, now.Format("060102150405"))
+	} else {
+		m.Set(iso8583.FIELD_LOCAL_TIME, now.Format("150405"))
+	}
+	m.Set(iso8583.FIELD_ACQUIRER_ID, TERMINAL_ACQUIRER_ID)
+	return m
+}
+
+// purchase is an authorisation or financial message for amount in minor units
+func (t *cardTerminal) purchase(mti string, amount int64) iso8583.Message {
+	m := t.message(mti)
+	m.Set(iso8583.FIELD_PAN, t.pan)
+	m.Set(iso8583.FIELD_PROCESSING_CODE, "000000")
+	m.Set(iso8583.FIELD_AMOUNT, iso8583.PadNumber(strconv.FormatInt(amount, 10), 12))
+	m.Set(iso8583.FIELD_EXPIRY, t.expiry)
+	m.Set(iso8583.FIELD_TERMINAL_ID, TERMINAL_ID)
+	m.Set(iso8583.FIELD_CARD_ACCEPTOR_ID, fmt.Sprintf("%-15s", t.cardAcceptor))
+	m.Set(iso8583.FIELD_CURRENCY, t.currency)
+	return m
+}
+
+// setOriginal names the message original was sent as in m's original data elements
+func (t *cardTerminal) setOriginal(m *iso8583.Message, original iso8583.Message) {
+	if t.version == iso8583.VERSION_1993 {
+		m.Set(iso8583.FIELD_ORIGINAL_DATA_1993, original.MTI+original.Get(iso8583.FIELD_STAN)+original.Get(iso8583.FIELD_LOCAL_TIME)+iso8583.PadNumber(strconv.Itoa(len(TERMINAL_ACQUIRER_ID)), 2)+TERMINAL_ACQUIRER_ID)
+		return
+	}
+	m.Set(iso8583.FIELD_ORIGINAL_DATA_1987, original.MTI+original.Get(iso8583.FIELD_STAN)+original.Get(iso8583.FIELD_TRANSMISSION_TIME)+iso8583.PadNumber(TERMINAL_ACQUIRER_ID, 11)+iso8583.PadNumber("", 11))
+}
+
+// send sends a message, prints the response and returns the message sent
+func (t *cardTerminal) send(m iso8583.Message) (sent iso8583.Message, err error) {
+	fmt.Printf("-> %s STAN %s amount %s\n", m.MTI, m.Get(iso8583.FIELD_STAN), m.Get(iso8583.FIELD_AMOUNT))
+	err = iso8583.WriteMessage(t.conn, m)
+	if err != nil {
+		return iso8583.Message{}, errors.New("cardTerminal.send: " + err.Error())
+	}
+
+	response, err := iso8583.ReadMessage(t.conn)
+	if err != nil {
+		return iso8583.Message{}, errors.New("cardTerminal.send: " + err.Error())
+	}
+	fmt.Printf("<- %s STAN %s response %s authorisation %s\n", response.MTI, response.Get(iso8583.FIELD_STAN), response.Get(iso8583.FIELD_RESPONSE_CODE), response.Get(iso8583.FIELD_AUTHORISATION_CODE))
+
+	return m, nil
+}
@@ -0,0 +1,64 @@
+package cards
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bvnk/bank/money"
+)
+
+// Reasons a card is declined at authorisation
+const (
+	DECLINE_INACTIVE          = "inactive"
+	DECLINE_FROZEN            = "frozen"
+	DECLINE_CANCELLED         = "cancelled"
+	DECLINE_EXPIRED           = "expired"
+	DECLINE_EXPIRY_MISMATCH   = "expiry mismatch"
+	DECLINE_TRANSACTION_LIMIT = "transaction limit"
+	DECLINE_DAILY_LIMIT       = "daily limit"
+)
+
+// CheckAuthorisation checks that a card can pay amount at now: that it is active and not
+// expired, that expiry (YYMM) is the card's, and that amount stays within its limits given
+// what has been spent on it today. When the card is declined decline says why
+func CheckAuthorisation(card Card, pan string, expiry string, amount money.Money, spent money.Money, now time.Time) (decline string, err error) {
+	switch card.Status {
+	case CARD_INACTIVE:
+		return DECLINE_INACTIVE, errors.New("cards.CheckAuthorisation: Card not activated")
+	case CARD_FROZEN:
+		return DECLINE_FROZEN, errors.New("cards.CheckAuthorisation: Card frozen")
+	case CARD_CANCELLED:
+		return DECLINE_CANCELLED, errors.New("cards.CheckAuthorisation: Card cancelled")
+	}
+	if now.Unix() >= int64(card.Expires) {
+		return DECLINE_EXPIRED, errors.New("cards.CheckAuthorisation: Card expired")
+	}
+
+	match, err := checkHash(card.expiryHash, pan, expiry)
+	if err != nil {
+		return "", errors.New("cards.CheckAuthorisation: " + err.Error())
+	}
+	if !match {
+		return DECLINE_EXPIRY_MISMATCH, errors.New("cards.CheckAuthorisation: Expiry does not match card")
+	}
+
+	return CheckLimits(card, amount, spent)
+}
+
+// CheckLimits checks amount against the card's per transaction limit, and amount on top of
+// what has been spent today against its daily limit. Zero limits are no limit
+func CheckLimits(card Card, amount money.Money, spent money.Money) (decline string, err error) {
+	if !card.PerTransactionLimit.IsZero() && amount.Cmp(card.PerTransactionLimit) == 1 {
+		return DECLINE_TRANSACTION_LIMIT, errors.New("cards.CheckLimits: Amount exceeds the card's per transaction limit of " + card.PerTransactionLimit.String())
+	}
+	if !card.DailyLimit.IsZero() && spent.Add(amount).Cmp(card.DailyLimit) == 1 {
+		return DECLINE_DAILY_LIMIT, errors.New("cards.CheckLimits: Amount exceeds the card's daily limit of " + card.DailyLimit.String())
+	}
+	return "", nil
+}
+
+// StartOfDay is when the day of now began, which daily limits count from
+func StartOfDay(now time.Time) time.Time {
+	local := now.In(location())
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location())
+}
@@ -0,0 +1,499 @@
+package cards
+
+/*
+Debit cards issued against accounts
+
+card~1~
+   AccountNumber~
+   Type~
+   PerTransactionLimit~
+   DailyLimit
+
+Issues a virtual or physical card on an account the token user holds. Virtual cards are active
+straight away, physical cards once the holder activates them. Limits are optional, an empty
+limit is no limit, and are in the account's currency. Returns the card with its PAN, expiry
+(MM/YY) and CVV, which are only ever shown here: the bank keeps the expiry and CVV as hashes.
+The PAN itself is kept in the vault and the card only knows it by its token.
+
+card~2~
+   CardID~
+   CVV
+
+Activates a physical card, proving it arrived by its CVV.
+
+card~3~CardID freezes a card, card~4~CardID unfreezes it. A frozen card is declined until it
+is unfrozen.
+
+card~5~CardID replaces a card, e.g. when it is lost, stolen or about to expire. The card is
+cancelled and a new one of the same type and limits issued on the account, with a new PAN,
+returned as card~1 returns it.
+
+card~6~CardID cancels a card for good.
+
+card~7~
+   CardID~
+   PerTransactionLimit~
+   DailyLimit
+
+Sets a card's spending limits, checked when the card is authorised.
+
+card~1000~AccountNumber lists the cards on an account.
+
+card~1001 moves the PANs of cards issued before the vault into it, leaving their tokens.
+
+card~1001 is limited to bank operators, every other request to the holder of the card's
+account. PANs are BIN (Config.CardBIN) followed by random digits and a Luhn check digit.
+*/
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bvnk/bank/accounts"
+	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/configuration"
+	"github.com/bvnk/bank/money"
+	"github.com/bvnk/bank/vault"
+	"github.com/shopspring/decimal"
+)
+
+var Config configuration.Configuration
+
+func SetConfig(config *configuration.Configuration) {
+	Config = *config
+}
+
+// Card types
+const (
+	CARD_VIRTUAL  = "virtual"
+	CARD_PHYSICAL = "physical"
+)
+
+// Card statuses
+const (
+	CARD_INACTIVE  = "inactive"
+	CARD_ACTIVE    = "active"
+	CARD_FROZEN    = "frozen"
+	CARD_CANCELLED = "cancelled"
+)
+
+// Years a card is valid for, from the month it is issued
+const CARD_VALIDITY_YEARS = 3
+
+// Card is a card on an account. The PAN is only known by its vault token and last four digits,
+// the expiry and CVV only as hashes
+type Card struct {
+	ID                  int64
+	UserID              string
+	AccountNumber       string
+	Type                string
+	Token               string
+	PANLast4            string
+	Status              string
+	Currency            string
+	PerTransactionLimit money.Money
+	DailyLimit          money.Money
+	ReplacesCardID      int64
+	Expires             int32
+	Timestamp           int32
+	expiryHash          string
+	cvvHash             string
+}
+
+// IssuedCard is a new card with the details printed on it, returned once when it is issued
+type IssuedCard struct {
+	Card   Card
+	PAN    string
+	Expiry string
+	CVV    string
+}
+
+func ProcessCard(data []string) (result interface{}, err error) {
+	if len(data) < 3 {
+		return "", errors.New("cards.ProcessCard: Not all data is present")
+	}
+
+	// Remove null termination from the last field
+	data[len(data)-1] = strings.TrimRight(data[len(data)-1], "\x00")
+
+	cardType, err := strconv.ParseInt(data[2], 10, 64)
+	if err != nil {
+		return "", errors.New("cards.ProcessCard: Could not get type of card request. " + err.Error())
+	}
+
+	switch cardType {
+	case 1:
+		result, err = issueCard(data)
+		if err != nil {
+			return "", errors.New("cards.ProcessCard: " + err.Error())
+		}
+		break
+	case 2:
+		result, err = activateCard(data)
+		if err != nil {
+			return "", errors.New("cards.ProcessCard: " + err.Error())
+		}
+		break
+	case 3:
+		result, err = changeCardStatus(data, CARD_ACTIVE, CARD_FROZEN)
+		if err != nil {
+			return "", errors.New("cards.ProcessCard: " + err.Error())
+		}
+		break
+	case 4:
+		result, err = changeCardStatus(data, CARD_FROZEN, CARD_ACTIVE)
+		if err != nil {
+			return "", errors.New("cards.ProcessCard: " + err.Error())
+		}
+		break
+	case 5:
+		result, err = replaceCard(data)
+		if err != nil {
+			return "", errors.New("cards.ProcessCard: " + err.Error())
+		}
+		break
+	case 6:
+		result, err = cancelCard(data)
+		if err != nil {
+			return "", errors.New("cards.ProcessCard: " + err.Error())
+		}
+		break
+	case 7:
+		result, err = setCardLimits(data)
+		if err != nil {
+			return "", errors.New("cards.ProcessCard: " + err.Error())
+		}
+		break
+	case 1000:
+		result, err = listCards(data)
+		if err != nil {
+			return "", errors.New("cards.ProcessCard: " + err.Error())
+		}
+		break
+	case 1001:
+		_, err = appauth.CheckOperator(data[0])
+		if err != nil {
+			return "", errors.New("cards.ProcessCard: " + err.Error())
+		}
+		result, err = tokeniseCards()
+		if err != nil {
+			return "", errors.New("cards.ProcessCard: " + err.Error())
+		}
+		break
+	default:
+		return "", errors.New("cards.ProcessCard: Card request type invalid")
+	}
+
+	return
+}
+
+func issueCard(data []string) (result IssuedCard, err error) {
+	if len(data) < 7 {
+		return IssuedCard{}, errors.New("cards.issueCard: Not all fields present")
+	}
+
+	accountNumber := data[3]
+	if accountNumber == "" {
+		return IssuedCard{}, errors.New("cards.issueCard: Account number missing")
+	}
+	cardType := strings.ToLower(strings.TrimSpace(data[4]))
+	if cardType != CARD_VIRTUAL && cardType != CARD_PHYSICAL {
+		return IssuedCard{}, errors.New("cards.issueCard: Card type must be " + CARD_VIRTUAL + " or " + CARD_PHYSICAL)
+	}
+	perTransactionLimit, dailyLimit, err := parseLimits(data[5], data[6])
+	if err != nil {
+		return IssuedCard{}, errors.New("cards.issueCard: " + err.Error())
+	}
+
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return IssuedCard{}, errors.New("cards.issueCard: " + err.Error())
+	}
+	err = accounts.CheckUserAccountValidFromToken(tokenUser, accountNumber)
+	if err != nil {
+		return IssuedCard{}, errors.New("cards.issueCard: Account not valid")
+	}
+
+	currency, err := getAccountCurrency(accountNumber)
+	if err != nil {
+		return IssuedCard{}, errors.New("cards.issueCard: " + err.Error())
+	}
+	card := Card{
+		UserID:        tokenUser,
+		AccountNumber: accountNumber,
+		Type:          cardType,
+		Currency:      currency,
+	}
+	card.PerTransactionLimit, card.DailyLimit, err = limitsIn(perTransactionLimit, dailyLimit, currency)
+	if err != nil {
+		return IssuedCard{}, errors.New("cards.issueCard: " + err.Error())
+	}
+	result, err = doIssueCard(card, 0)
+	if err != nil {
+		return IssuedCard{}, errors.New("cards.issueCard: " + err.Error())
+	}
+
+	return
+}
+
+func activateCard(data []string) (result Card, err error) {
+	if len(data) < 5 {
+		return Card{}, errors.New("cards.activateCard: Not all fields present")
+	}
+
+	card, err := holderCard(data)
+	if err != nil {
+		return Card{}, errors.New("cards.activateCard: " + err.Error())
+	}
+	if card.Status != CARD_INACTIVE {
+		return Card{}, errors.New("cards.activateCard: Card is not waiting for activation, status is " + card.Status)
+	}
+
+	pan, err := vault.Detokenise(card.Token, vault.CALLER_CARD_ACTIVATION)
+	if err != nil {
+		return Card{}, errors.New("cards.activateCard: " + err.Error())
+	}
+	match, err := checkHash(card.cvvHash, pan, expiryOf(card), strings.TrimSpace(data[4]))
+	if err != nil {
+		return Card{}, errors.New("cards.activateCard: " + err.Error())
+	}
+	if !match {
+		return Card{}, errors.New("cards.activateCard: CVV does not match")
+	}
+
+	err = updateCardStatus(card.ID, CARD_INACTIVE, CARD_ACTIVE)
+	if err != nil {
+		return Card{}, errors.New("cards.activateCard: " + err.Error())
+	}
+
+	card.Status = CARD_ACTIVE
+	return card, nil
+}
+
+// changeCardStatus moves a card from one status to the next, e.g. when it is frozen
+func changeCardStatus(data []string, fromStatus string, toStatus string) (result Card, err error) {
+	if len(data) < 4 {
+		return Card{}, errors.New("cards.changeCardStatus: Not all fields present")
+	}
+
+	card, err := holderCard(data)
+	if err != nil {
+		return Card{}, errors.New("cards.changeCardStatus: " + err.Error())
+	}
+	if card.Status != fromStatus {
+		return Card{}, errors.New("cards.changeCardStatus: Card must be " + fromStatus + ", status is " + card.Status)
+	}
+
+	err = updateCardStatus(card.ID, fromStatus, toStatus)
+	if err != nil {
+		return Card{}, errors.New("cards.changeCardStatus: " + err.Error())
+	}
+
+	card.Status = toStatus
+	return card, nil
+}
+
+func replaceCard(data []string) (result IssuedCard, err error) {
+	if len(data) < 4 {
+		return IssuedCard{}, errors.New("cards.replaceCard: Not all fields present")
+	}
+
+	card, err := holderCard(data)
+	if err != nil {
+		return IssuedCard{}, errors.New("cards.replaceCard: " + err.Error())
+	}
+	if card.Status == CARD_CANCELLED {
+		return IssuedCard{}, errors.New("cards.replaceCard: Card is cancelled")
+	}
+
+	replacement := Card{
+		UserID:              card.UserID,
+		AccountNumber:       card.AccountNumber,
+		Type:                card.Type,
+		Currency:            card.Currency,
+		PerTransactionLimit: card.PerTransactionLimit,
+		DailyLimit:          card.DailyLimit,
+	}
+	result, err = doIssueCard(replacement, card.ID)
+	if err != nil {
+		return IssuedCard{}, errors.New("cards.replaceCard: " + err.Error())
+	}
+
+	return
+}
+
+func cancelCard(data []string) (result Card, err error) {
+	if len(data) < 4 {
+		return Card{}, errors.New("cards.cancelCard: Not all fields present")
+	}
+
+	card, err := holderCard(data)
+	if err != nil {
+		return Card{}, errors.New("cards.cancelCard: " + err.Error())
+	}
+	if card.Status == CARD_CANCELLED {
+		return Card{}, errors.New("cards.cancelCard: Card is already cancelled")
+	}
+
+	err = updateCardStatus(card.ID, card.Status, CARD_CANCELLED)
+	if err != nil {
+		return Card{}, errors.New("cards.cancelCard: " + err.Error())
+	}
+
+	card.Status = CARD_CANCELLED
+	return card, nil
+}
+
+func setCardLimits(data []string) (result Card, err error) {
+	if len(data) < 6 {
+		return Card{}, errors.New("cards.setCardLimits: Not all fields present")
+	}
+
+	perTransactionLimit, dailyLimit, err := parseLimits(data[4], data[5])
+	if err != nil {
+		return Card{}, errors.New("cards.setCardLimits: " + err.Error())
+	}
+
+	card, err := holderCard(data)
+	if err != nil {
+		return Card{}, errors.New("cards.setCardLimits: " + err.Error())
+	}
+	if card.Status == CARD_CANCELLED {
+		return Card{}, errors.New("cards.setCardLimits: Card is cancelled")
+	}
+	card.PerTransactionLimit, card.DailyLimit, err = limitsIn(perTransactionLimit, dailyLimit, card.Currency)
+	if err != nil {
+		return Card{}, errors.New("cards.setCardLimits: " + err.Error())
+	}
+
+	err = updateCardLimits(card.ID, card.PerTransactionLimit, card.DailyLimit)
+	if err != nil {
+		return Card{}, errors.New("cards.setCardLimits: " + err.Error())
+	}
+
+	return card, nil
+}
+
+func listCards(data []string) (result []Card, err error) {
+	if len(data) < 4 {
+		return []Card{}, errors.New("cards.listCards: Not all fields present")
+	}
+
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return []Card{}, errors.New("cards.listCards: " + err.Error())
+	}
+	err = accounts.CheckUserAccountValidFromToken(tokenUser, data[3])
+	if err != nil {
+		return []Card{}, errors.New("cards.listCards: Account not valid")
+	}
+
+	result, err = getAccountCards(data[3])
+	if err != nil {
+		return []Card{}, errors.New("cards.listCards: " + err.Error())
+	}
+
+	return
+}
+
+// tokeniseCards vaults the PANs of cards issued before the vault, returning how many it moved
+func tokeniseCards() (result int, err error) {
+	untokenised, err := getUntokenisedCards()
+	if err != nil {
+		return 0, errors.New("cards.tokeniseCards: " + err.Error())
+	}
+
+	for _, card := range untokenised {
+		token, err := vault.Tokenise(card.Token)
+		if err != nil {
+			return result, errors.New("cards.tokeniseCards: Card " + strconv.FormatInt(card.ID, 10) + ". " + err.Error())
+		}
+		err = updateCardToken(card.ID, token)
+		if err != nil {
+			return result, errors.New("cards.tokeniseCards: " + err.Error())
+		}
+		result++
+	}
+
+	return
+}
+
+// holderCard fetches the card in data[3], checking the token user holds its account
+func holderCard(data []string) (card Card, err error) {
+	cardID, err := strconv.ParseInt(data[3], 10, 64)
+	if err != nil {
+		return Card{}, errors.New("cards.holderCard: Could not parse card ID. " + err.Error())
+	}
+
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return Card{}, errors.New("cards.holderCard: " + err.Error())
+	}
+
+	card, err = getCard(cardID)
+	if err != nil {
+		return Card{}, errors.New("cards.holderCard: " + err.Error())
+	}
+	err = accounts.CheckUserAccountValidFromToken(tokenUser, card.AccountNumber)
+	if err != nil {
+		return Card{}, errors.New("cards.holderCard: Card not valid")
+	}
+
+	return
+}
+
+// parseLimits reads a card's per transaction and daily limits. Empty limits are zero, no limit.
+// They are put in the currency of the card's account by limitsIn
+func parseLimits(perTransaction string, daily string) (perTransactionLimit decimal.Decimal, dailyLimit decimal.Decimal, err error) {
+	perTransactionLimit, err = parseLimit(perTransaction)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, errors.New("cards.parseLimits: Per transaction limit " + err.Error())
+	}
+	dailyLimit, err = parseLimit(daily)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, errors.New("cards.parseLimits: Daily limit " + err.Error())
+	}
+	if dailyLimit.Sign() != 0 && perTransactionLimit.Cmp(dailyLimit) == 1 {
+		return decimal.Zero, decimal.Zero, errors.New("cards.parseLimits: Per transaction limit exceeds daily limit")
+	}
+	return
+}
+
+func parseLimit(value string) (limit decimal.Decimal, err error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return decimal.Zero, nil
+	}
+	limit, err = decimal.NewFromString(value)
+	if err != nil {
+		return decimal.Zero, errors.New("not valid. " + err.Error())
+	}
+	if limit.Sign() < 0 {
+		return decimal.Zero, errors.New("must not be negative")
+	}
+	return
+}
+
+// limitsIn puts a card's limits in the currency of its account, which they may not have more
+// decimal places than
+func limitsIn(perTransaction decimal.Decimal, daily decimal.Decimal, currency string) (perTransactionLimit money.Money, dailyLimit money.Money, err error) {
+	perTransactionLimit, err = money.NewFromString(perTransaction.String(), currency)
+	if err != nil {
+		return money.Money{}, money.Money{}, errors.New("cards.limitsIn: Per transaction limit not valid. " + err.Error())
+	}
+	dailyLimit, err = money.NewFromString(daily.String(), currency)
+	if err != nil {
+		return money.Money{}, money.Money{}, errors.New("cards.limitsIn: Daily limit not valid. " + err.Error())
+	}
+	return
+}
+
+func location() *time.Location {
+	loc, err := time.LoadLocation(Config.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
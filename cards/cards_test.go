@@ -0,0 +1,176 @@
+package cards
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+func TestProcessCard(t *testing.T) {
+	tst := []string{"", ""}
+	_, err := ProcessCard(tst)
+	if err == nil {
+		t.Errorf("ProcessCard does not pass. Looking for %v, got %v", "Not all data is present", nil)
+	}
+
+	// Each request fails validation before the token is checked
+	invalid := map[string]struct {
+		data     []string
+		expected string
+	}{
+		"request type":      {[]string{"", "", "0"}, "Card request type invalid"},
+		"issue fields":      {[]string{"", "", "1", "accountNumber", "virtual"}, "Not all fields present"},
+		"account number":    {[]string{"", "", "1", "", "virtual", "", ""}, "Account number missing"},
+		"card type":         {[]string{"", "", "1", "accountNumber", "credit", "", ""}, "Card type must be virtual or physical"},
+		"negative limit":    {[]string{"", "", "1", "accountNumber", "virtual", "-10", ""}, "Per transaction limit must not be negative"},
+		"limits":            {[]string{"", "", "1", "accountNumber", "virtual", "100", "50"}, "Per transaction limit exceeds daily limit"},
+		"activation fields": {[]string{"", "", "2", "1"}, "Not all fields present"},
+		"card ID":           {[]string{"", "", "3", "one"}, "Could not parse card ID"},
+		"replace fields":    {[]string{"", "", "5"}, "Not all fields present"},
+		"limit fields":      {[]string{"", "", "7", "1", "100"}, "Not all fields present"},
+		"limit amount":      {[]string{"", "", "7", "1", "ten", ""}, "Per transaction limit not valid"},
+		"list fields":       {[]string{"", "", "1000"}, "Not all fields present"},
+	}
+	for name, tst := range invalid {
+		_, err := ProcessCard(tst.data)
+		if err == nil || !strings.Contains(err.Error(), tst.expected) {
+			t.Errorf("ProcessCard %v does not pass. Looking for %v, got %v", name, tst.expected, err)
+		}
+	}
+}
+
+func TestLuhnCheckDigit(t *testing.T) {
+	if digit := LuhnCheckDigit("7992739871"); digit != '3' {
+		t.Errorf("LuhnCheckDigit does not pass. Looking for %v, got %v", "3", string(digit))
+	}
+
+	valid := map[string]bool{
+		"79927398713":          false,
+		"4111111111111111":     true,
+		"4111111111111112":     false,
+		"4000056655665556":     true,
+		"411111111111111a":     false,
+		"6011111111111117":     true,
+		"41111111111111111111": false,
+	}
+	for pan, expected := range valid {
+		if ValidPAN(pan) != expected {
+			t.Errorf("ValidPAN %v does not pass. Looking for %v, got %v", pan, expected, !expected)
+		}
+	}
+}
+
+func TestGeneratePAN(t *testing.T) {
+	pan, err := GeneratePAN("400000", PAN_LENGTH)
+	if err != nil || len(pan) != PAN_LENGTH || !strings.HasPrefix(pan, "400000") || !ValidPAN(pan) {
+		t.Errorf("GeneratePAN does not pass. Looking for %v, got %v %v", "valid 16 digit PAN starting 400000", pan, err)
+	}
+
+	invalid := map[string]struct {
+		bin    string
+		length int
+	}{
+		"short BIN":  {"40000", PAN_LENGTH},
+		"long BIN":   {"400000001", PAN_LENGTH},
+		"BIN digits": {"40000a", PAN_LENGTH},
+		"length":     {"400000", MAX_PAN_LENGTH + 1},
+	}
+	for name, test := range invalid {
+		_, err := GeneratePAN(test.bin, test.length)
+		if err == nil {
+			t.Errorf("GeneratePAN %v does not pass. Looking for %v, got %v", name, "error", nil)
+		}
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Date(2016, time.March, 15, 12, 0, 0, 0, location())
+	expiry, expires := newExpiry(now)
+	if expiry != "1903" {
+		t.Errorf("NewExpiry does not pass. Looking for %v, got %v", "1903", expiry)
+	}
+	if expected := time.Date(2019, time.April, 1, 0, 0, 0, 0, location()); int64(expires) != expected.Unix() {
+		t.Errorf("NewExpiry expires does not pass. Looking for %v, got %v", expected, time.Unix(int64(expires), 0))
+	}
+	if got := expiryOf(Card{Expires: expires}); got != expiry {
+		t.Errorf("ExpiryOf does not pass. Looking for %v, got %v", expiry, got)
+	}
+	if formatExpiry(expiry) != "03/19" {
+		t.Errorf("FormatExpiry does not pass. Looking for %v, got %v", "03/19", formatExpiry(expiry))
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	perTransaction, daily, err := parseLimits("", "")
+	if err != nil || perTransaction.Sign() != 0 || daily.Sign() != 0 {
+		t.Errorf("ParseLimits empty does not pass. Looking for %v, got %v %v %v", "0 0", perTransaction, daily, err)
+	}
+
+	perTransaction, daily, err = parseLimits("100", "")
+	if err != nil || !perTransaction.Equal(decimal.New(100, 0)) || daily.Sign() != 0 {
+		t.Errorf("ParseLimits no daily limit does not pass. Looking for %v, got %v %v %v", "100 0", perTransaction, daily, err)
+	}
+
+	_, _, err = parseLimits("100", "50")
+	if err == nil {
+		t.Errorf("ParseLimits per transaction over daily does not pass. Looking for %v, got %v", "error", nil)
+	}
+}
+
+func TestLimitsIn(t *testing.T) {
+	perTransaction, daily, err := limitsIn(decimal.RequireFromString("100.50"), decimal.Zero, "EUR")
+	if err != nil || perTransaction.Currency != "EUR" || perTransaction.StringFixed() != "100.50" || daily.Currency != "EUR" || !daily.IsZero() {
+		t.Errorf("LimitsIn does not pass. Looking for %v, got %v %v %v", "100.50 EUR 0.00 EUR", perTransaction, daily, err)
+	}
+
+	_, _, err = limitsIn(decimal.RequireFromString("100.50"), decimal.Zero, "JPY")
+	if err == nil {
+		t.Errorf("LimitsIn minor units does not pass. Looking for %v, got %v", "error", nil)
+	}
+}
+
+func TestCheckLimits(t *testing.T) {
+	card := Card{PerTransactionLimit: money.RequireFromString("100", money.DEFAULT_CURRENCY), DailyLimit: money.RequireFromString("250", money.DEFAULT_CURRENCY)}
+	tests := map[string]struct {
+		amount   money.Money
+		spent    money.Money
+		expected string
+	}{
+		"within limits":     {money.RequireFromString("100", money.DEFAULT_CURRENCY), money.RequireFromString("150", money.DEFAULT_CURRENCY), ""},
+		"transaction limit": {money.RequireFromString("100.01", money.DEFAULT_CURRENCY), money.RequireFromString("0", money.DEFAULT_CURRENCY), DECLINE_TRANSACTION_LIMIT},
+		"daily limit":       {money.RequireFromString("50", money.DEFAULT_CURRENCY), money.RequireFromString("200.01", money.DEFAULT_CURRENCY), DECLINE_DAILY_LIMIT},
+	}
+	for name, test := range tests {
+		decline, err := CheckLimits(card, test.amount, test.spent)
+		if decline != test.expected || (err == nil) != (test.expected == "") {
+			t.Errorf("CheckLimits %v does not pass. Looking for %v, got %v %v", name, test.expected, decline, err)
+		}
+	}
+
+	decline, err := CheckLimits(Card{}, money.RequireFromString("10000", money.DEFAULT_CURRENCY), money.RequireFromString("10000", money.DEFAULT_CURRENCY))
+	if err != nil {
+		t.Errorf("CheckLimits no limits does not pass. Looking for %v, got %v %v", nil, decline, err)
+	}
+}
+
+func TestCheckAuthorisation(t *testing.T) {
+	now := time.Now()
+	tests := map[string]struct {
+		card     Card
+		expected string
+	}{
+		"inactive":  {Card{Status: CARD_INACTIVE, Expires: int32(now.Unix()) + 3600}, DECLINE_INACTIVE},
+		"frozen":    {Card{Status: CARD_FROZEN, Expires: int32(now.Unix()) + 3600}, DECLINE_FROZEN},
+		"cancelled": {Card{Status: CARD_CANCELLED, Expires: int32(now.Unix()) + 3600}, DECLINE_CANCELLED},
+		"expired":   {Card{Status: CARD_ACTIVE, Expires: int32(now.Unix())}, DECLINE_EXPIRED},
+	}
+	for name, test := range tests {
+		decline, err := CheckAuthorisation(test.card, "4000001234567899", "2903", money.RequireFromString("10", money.DEFAULT_CURRENCY), money.RequireFromString("0", money.DEFAULT_CURRENCY), now)
+		if err == nil || decline != test.expected {
+			t.Errorf("CheckAuthorisation %v does not pass. Looking for %v, got %v", name, test.expected, decline)
+		}
+	}
+}
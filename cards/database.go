@@ -0,0 +1,356 @@
+package cards
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/bvnk/bank/accounts"
+	"github.com/bvnk/bank/money"
+	"github.com/bvnk/bank/vault"
+)
+
+// Attempts at a PAN not issued before
+const MAX_PAN_ATTEMPTS = 5
+
+// doIssueCard issues a card on an open account, cancelling the card it replaces (if any) in
+// the same database transaction
+func doIssueCard(card Card, replacesCardID int64) (result IssuedCard, err error) {
+	if Config.CardBIN == "" {
+		return IssuedCard{}, errors.New("cards.doIssueCard: No card BIN configured")
+	}
+
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return IssuedCard{}, errors.New("cards.doIssueCard: Could not start database transaction. " + err.Error())
+	}
+
+	status, currency, err := getAccountStatusForUpdate(tx, card.AccountNumber)
+	if err != nil {
+		tx.Rollback()
+		return IssuedCard{}, errors.New("cards.doIssueCard: " + err.Error())
+	}
+	if status == accounts.ACCOUNT_CLOSED {
+		tx.Rollback()
+		return IssuedCard{}, errors.New("cards.doIssueCard: Account closed")
+	}
+	if currency != card.Currency {
+		tx.Rollback()
+		return IssuedCard{}, errors.New("cards.doIssueCard: Card limits are not in the account's currency")
+	}
+
+	if replacesCardID != 0 {
+		err = doUpdateCardStatus(tx, replacesCardID, "", CARD_CANCELLED)
+		if err != nil {
+			tx.Rollback()
+			return IssuedCard{}, errors.New("cards.doIssueCard: " + err.Error())
+		}
+	}
+
+	pan, err := newPAN()
+	if err != nil {
+		tx.Rollback()
+		return IssuedCard{}, errors.New("cards.doIssueCard: " + err.Error())
+	}
+	card.Token, err = vault.Tokenise(pan)
+	if err != nil {
+		tx.Rollback()
+		return IssuedCard{}, errors.New("cards.doIssueCard: " + err.Error())
+	}
+	cvv, err := randomDigits(CVV_LENGTH)
+	if err != nil {
+		tx.Rollback()
+		return IssuedCard{}, errors.New("cards.doIssueCard: " + err.Error())
+	}
+
+	now := time.Now()
+	expiry, expires := newExpiry(now)
+	card.expiryHash, err = hashCardData(pan, expiry)
+	if err != nil {
+		tx.Rollback()
+		return IssuedCard{}, errors.New("cards.doIssueCard: " + err.Error())
+	}
+	card.cvvHash, err = hashCardData(pan, expiry, cvv)
+	if err != nil {
+		tx.Rollback()
+		return IssuedCard{}, errors.New("cards.doIssueCard: " + err.Error())
+	}
+
+	// Physical cards wait in the post until the holder activates them
+	card.Status = CARD_ACTIVE
+	if card.Type == CARD_PHYSICAL {
+		card.Status = CARD_INACTIVE
+	}
+	card.PANLast4 = pan[len(pan)-4:]
+	card.ReplacesCardID = replacesCardID
+	card.Expires = expires
+	card.Timestamp = int32(now.Unix())
+
+	card.ID, err = saveCard(tx, card)
+	if err != nil {
+		tx.Rollback()
+		return IssuedCard{}, errors.New("cards.doIssueCard: " + err.Error())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return IssuedCard{}, errors.New("cards.doIssueCard: Could not commit transaction. " + err.Error())
+	}
+
+	return IssuedCard{card, pan, formatExpiry(expiry), cvv}, nil
+}
+
+// newPAN generates PANs until it finds one not issued before
+func newPAN() (pan string, err error) {
+	for i := 0; i < MAX_PAN_ATTEMPTS; i++ {
+		pan, err = GeneratePAN(Config.CardBIN, PAN_LENGTH)
+		if err != nil {
+			return "", errors.New("cards.newPAN: " + err.Error())
+		}
+
+		_, found, err := vault.LookupToken(pan)
+		if err != nil {
+			return "", errors.New("cards.newPAN: " + err.Error())
+		}
+		if !found {
+			return pan, nil
+		}
+	}
+	return "", errors.New("cards.newPAN: Could not generate an unused PAN")
+}
+
+// getAccountCurrency returns the currency an account is held in
+func getAccountCurrency(accountNumber string) (currency string, err error) {
+	err = Config.Db.QueryRow("SELECT `currency` FROM `accounts` WHERE `accountNumber` = ?", accountNumber).Scan(&currency)
+	if err == sql.ErrNoRows {
+		return "", errors.New("cards.getAccountCurrency: Account " + accountNumber + " not found")
+	}
+	if err != nil {
+		return "", errors.New("cards.getAccountCurrency: " + err.Error())
+	}
+	return
+}
+
+func getAccountStatusForUpdate(tx *sql.Tx, accountNumber string) (status string, currency string, err error) {
+	rows, err := tx.Query("SELECT `status`, `currency` FROM `accounts` WHERE `accountNumber` = ? FOR UPDATE", accountNumber)
+	if err != nil {
+		return "", "", errors.New("cards.getAccountStatusForUpdate: " + err.Error())
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return "", "", errors.New("cards.getAccountStatusForUpdate: Account " + accountNumber + " not found")
+	}
+	if err := rows.Scan(&status, &currency); err != nil {
+		return "", "", errors.New("cards.getAccountStatusForUpdate: " + err.Error())
+	}
+
+	return
+}
+
+func saveCard(tx *sql.Tx, card Card) (cardID int64, err error) {
+	insertStatement := "INSERT INTO cards (`userID`, `accountNumber`, `type`, `token`, `panLast4`, `expiryHash`, `cvvHash`, `status`, `perTransactionLimit`, `dailyLimit`, `replacesCardID`, `expires`, `timestamp`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	stmtIns, err := tx.Prepare(insertStatement)
+	if err != nil {
+		return 0, errors.New("cards.saveCard: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	replacesCardID := sql.NullInt64{Int64: card.ReplacesCardID, Valid: card.ReplacesCardID != 0}
+	res, err := stmtIns.Exec(card.UserID, card.AccountNumber, card.Type, card.Token, card.PANLast4, card.expiryHash, card.cvvHash, card.Status, card.PerTransactionLimit, card.DailyLimit, replacesCardID, card.Expires, card.Timestamp)
+	if err != nil {
+		return 0, errors.New("cards.saveCard: " + err.Error())
+	}
+
+	cardID, err = res.LastInsertId()
+	if err != nil {
+		return 0, errors.New("cards.saveCard: Could not get card ID. " + err.Error())
+	}
+
+	return
+}
+
+// The currency of a card's account, which its limits and payments are in
+const cardCurrency = "COALESCE((SELECT `currency` FROM `accounts` WHERE `accounts`.`accountNumber` = `cards`.`accountNumber`), '')"
+
+const cardColumns = "`id`, `userID`, `accountNumber`, `type`, `token`, `panLast4`, `status`, " + cardCurrency + ", `perTransactionLimit`, `dailyLimit`, COALESCE(`replacesCardID`, 0), `expires`, `timestamp`, `expiryHash`, `cvvHash`"
+
+func scanCards(rows *sql.Rows) (cards []Card, err error) {
+	cards = []Card{}
+	for rows.Next() {
+		card := Card{}
+		err = rows.Scan(&card.ID, &card.UserID, &card.AccountNumber, &card.Type, &card.Token, &card.PANLast4, &card.Status, &card.Currency, &card.PerTransactionLimit, &card.DailyLimit, &card.ReplacesCardID, &card.Expires, &card.Timestamp, &card.expiryHash, &card.cvvHash)
+		if err != nil {
+			return []Card{}, errors.New("cards.scanCards: Could not retrieve cards. " + err.Error())
+		}
+		card.PerTransactionLimit = card.PerTransactionLimit.In(card.Currency)
+		card.DailyLimit = card.DailyLimit.In(card.Currency)
+		cards = append(cards, card)
+	}
+	return
+}
+
+func getCard(cardID int64) (card Card, err error) {
+	rows, err := Config.Db.Query("SELECT "+cardColumns+" FROM `cards` WHERE `id` = ?", cardID)
+	if err != nil {
+		return Card{}, errors.New("cards.getCard: " + err.Error())
+	}
+	defer rows.Close()
+
+	cards, err := scanCards(rows)
+	if err != nil {
+		return Card{}, errors.New("cards.getCard: " + err.Error())
+	}
+	if len(cards) == 0 {
+		return Card{}, errors.New("cards.getCard: Card not found")
+	}
+
+	return cards[0], nil
+}
+
+// GetCardByPAN finds the card with a PAN by its token, for authorising it
+func GetCardByPAN(pan string) (card Card, found bool, err error) {
+	token, found, err := vault.LookupToken(pan)
+	if err != nil {
+		return Card{}, false, errors.New("cards.GetCardByPAN: " + err.Error())
+	}
+	if !found {
+		return Card{}, false, nil
+	}
+
+	rows, err := Config.Db.Query("SELECT "+cardColumns+" FROM `cards` WHERE `token` = ?", token)
+	if err != nil {
+		return Card{}, false, errors.New("cards.GetCardByPAN: " + err.Error())
+	}
+	defer rows.Close()
+
+	cards, err := scanCards(rows)
+	if err != nil {
+		return Card{}, false, errors.New("cards.GetCardByPAN: " + err.Error())
+	}
+	if len(cards) == 0 {
+		return Card{}, false, nil
+	}
+
+	return cards[0], true, nil
+}
+
+// getUntokenisedCards lists the cards issued before the vault, whose token is still their PAN
+func getUntokenisedCards() (cards []Card, err error) {
+	rows, err := Config.Db.Query("SELECT " + cardColumns + " FROM `cards` ORDER BY `id`")
+	if err != nil {
+		return []Card{}, errors.New("cards.getUntokenisedCards: " + err.Error())
+	}
+	defer rows.Close()
+
+	all, err := scanCards(rows)
+	if err != nil {
+		return []Card{}, errors.New("cards.getUntokenisedCards: " + err.Error())
+	}
+
+	cards = []Card{}
+	for _, card := range all {
+		if !vault.IsToken(card.Token) {
+			cards = append(cards, card)
+		}
+	}
+
+	return
+}
+
+func updateCardToken(cardID int64, token string) (err error) {
+	stmtUpd, err := Config.Db.Prepare("UPDATE `cards` SET `token` = ? WHERE `id` = ?")
+	if err != nil {
+		return errors.New("cards.updateCardToken: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	_, err = stmtUpd.Exec(token, cardID)
+	if err != nil {
+		return errors.New("cards.updateCardToken: " + err.Error())
+	}
+
+	return
+}
+
+func getAccountCards(accountNumber string) (cards []Card, err error) {
+	rows, err := Config.Db.Query("SELECT "+cardColumns+" FROM `cards` WHERE `accountNumber` = ? ORDER BY `id`", accountNumber)
+	if err != nil {
+		return []Card{}, errors.New("cards.getAccountCards: " + err.Error())
+	}
+	defer rows.Close()
+
+	cards, err = scanCards(rows)
+	if err != nil {
+		return []Card{}, errors.New("cards.getAccountCards: " + err.Error())
+	}
+
+	return
+}
+
+func updateCardStatus(cardID int64, fromStatus string, toStatus string) (err error) {
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return errors.New("cards.updateCardStatus: Could not start database transaction. " + err.Error())
+	}
+
+	err = doUpdateCardStatus(tx, cardID, fromStatus, toStatus)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("cards.updateCardStatus: " + err.Error())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("cards.updateCardStatus: Could not commit transaction. " + err.Error())
+	}
+
+	return
+}
+
+// doUpdateCardStatus moves a card from fromStatus, or any status but cancelled when
+// fromStatus is empty, to toStatus
+func doUpdateCardStatus(tx *sql.Tx, cardID int64, fromStatus string, toStatus string) (err error) {
+	updateStatement := "UPDATE `cards` SET `status` = ? WHERE `id` = ? AND `status` = ?"
+	args := []interface{}{toStatus, cardID, fromStatus}
+	if fromStatus == "" {
+		updateStatement = "UPDATE `cards` SET `status` = ? WHERE `id` = ? AND `status` != ?"
+		args = []interface{}{toStatus, cardID, CARD_CANCELLED}
+	}
+	stmtUpd, err := tx.Prepare(updateStatement)
+	if err != nil {
+		return errors.New("cards.doUpdateCardStatus: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	res, err := stmtUpd.Exec(args...)
+	if err != nil {
+		return errors.New("cards.doUpdateCardStatus: " + err.Error())
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errors.New("cards.doUpdateCardStatus: Could not get rows affected. " + err.Error())
+	}
+	if affected != 1 {
+		return errors.New("cards.doUpdateCardStatus: Card status has changed")
+	}
+
+	return
+}
+
+func updateCardLimits(cardID int64, perTransactionLimit money.Money, dailyLimit money.Money) (err error) {
+	stmtUpd, err := Config.Db.Prepare("UPDATE `cards` SET `perTransactionLimit` = ?, `dailyLimit` = ? WHERE `id` = ?")
+	if err != nil {
+		return errors.New("cards.updateCardLimits: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	_, err = stmtUpd.Exec(perTransactionLimit, dailyLimit, cardID)
+	if err != nil {
+		return errors.New("cards.updateCardLimits: " + err.Error())
+	}
+
+	return
+}
@@ -0,0 +1,127 @@
+package cards
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/pzduniak/argon2"
+)
+
+// Length of the PANs issued, and the shortest and longest PANs there are
+const (
+	PAN_LENGTH     = 16
+	MIN_PAN_LENGTH = 12
+	MAX_PAN_LENGTH = 19
+)
+
+// Digits in a CVV
+const CVV_LENGTH = 3
+
+// GeneratePAN returns a random PAN of length digits starting with bin and ending with its
+// Luhn check digit
+func GeneratePAN(bin string, length int) (pan string, err error) {
+	if len(bin) < 6 || len(bin) > 8 || !isDigits(bin) {
+		return "", errors.New("cards.GeneratePAN: BIN must be 6 to 8 digits")
+	}
+	if length < MIN_PAN_LENGTH || length > MAX_PAN_LENGTH || length <= len(bin)+1 {
+		return "", errors.New("cards.GeneratePAN: PAN length not valid")
+	}
+
+	account, err := randomDigits(length - len(bin) - 1)
+	if err != nil {
+		return "", errors.New("cards.GeneratePAN: " + err.Error())
+	}
+
+	pan = bin + account
+	return pan + string(LuhnCheckDigit(pan)), nil
+}
+
+// LuhnCheckDigit returns the digit that makes digits followed by it pass the Luhn check
+func LuhnCheckDigit(digits string) byte {
+	sum := 0
+	// Counting from the right of the full number, the check digit is first so every
+	// other digit from the last of digits is doubled
+	for i := len(digits) - 1; i >= 0; i-- {
+		digit := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// ValidPAN checks a PAN's length, digits and Luhn check digit
+func ValidPAN(pan string) bool {
+	if len(pan) < MIN_PAN_LENGTH || len(pan) > MAX_PAN_LENGTH || !isDigits(pan) {
+		return false
+	}
+	return LuhnCheckDigit(pan[:len(pan)-1]) == pan[len(pan)-1]
+}
+
+// newExpiry returns the expiry (YYMM) of a card issued at now, and the time it stops working:
+// the start of the month after its expiry
+func newExpiry(now time.Time) (expiry string, expires int32) {
+	month := time.Date(now.Year()+CARD_VALIDITY_YEARS, now.Month(), 1, 0, 0, 0, 0, location())
+	return month.Format("0601"), int32(month.AddDate(0, 1, 0).Unix())
+}
+
+// expiryOf is the expiry (YYMM) of a card
+func expiryOf(card Card) string {
+	return time.Unix(int64(card.Expires), 0).In(location()).AddDate(0, 0, -1).Format("0601")
+}
+
+// formatExpiry turns YYMM into MM/YY, as printed on cards
+func formatExpiry(expiry string) string {
+	return expiry[2:] + "/" + expiry[:2]
+}
+
+// hashCardData hashes card details the bank does not keep, like the CVV, salted with the PAN
+func hashCardData(pan string, details ...string) (hash string, err error) {
+	output, err := argon2.Key([]byte(pan+strings.Join(details, "")), []byte(Config.PasswordSalt), 3, 4, 4096, 64, argon2.Argon2i)
+	if err != nil {
+		return "", errors.New("cards.hashCardData: Could not generate secure hash. " + err.Error())
+	}
+	return hex.EncodeToString(output), nil
+}
+
+// checkHash checks card details against their hash
+func checkHash(hash string, pan string, details ...string) (match bool, err error) {
+	check, err := hashCardData(pan, details...)
+	if err != nil {
+		return false, errors.New("cards.checkHash: " + err.Error())
+	}
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(check)) == 1, nil
+}
+
+func randomDigits(n int) (digits string, err error) {
+	b := make([]byte, n)
+	for i := range b {
+		digit, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", errors.New("cards.randomDigits: " + err.Error())
+		}
+		b[i] = byte('0' + digit.Int64())
+	}
+	return string(b), nil
+}
+
+func isDigits(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -34,6 +34,36 @@ type Configuration struct {
 	PushEnv       string
 	ApplePushCert string
 	ApplePushKey  string
+	// This bank's number, as other banks address it
+	BankNumber string
+	// Banks we exchange interbank payments with, by bank number
+	Peers map[string]Peer
+	// Verifier that checks account holders' identification (KYC), e.g. local
+	KYCVerifier string
+	// Port the ISO 8583 card listener runs on, none when empty
+	CardPort string
+	// Merchant account paid for each card acceptor ID (ISO 8583 field 42)
+	CardAcceptors map[string]string
+	// PEM file of the CAs that sign acquirers' client certificates, relative to the bank's directory
+	CardAcquirerCA string
+	// Accept card messages without TLS, only for the test terminal (cardTerminalNoTLS)
+	CardTestMode bool
+	// BIN (first 6 to 8 digits) of the PANs of cards the bank issues
+	CardBIN string
+	// Key (hex encoded, 32 bytes) the card vault encrypts PANs with
+	VaultKey string
+	// CSV file of FX rates loaded when the server starts, relative to the bank's directory
+	FXRatesFile string
+}
+
+// Peer is another bank reachable over its HTTP API
+type Peer struct {
+	// Base URL of the peer's HTTP API, e.g. https://localhost:8444
+	Endpoint string
+	// Shared secret both banks send with their messages
+	Secret string
+	// Accept the peer's self-signed certificate, for testing against a local instance
+	InsecureSkipVerify bool
 }
 
 // Initialization of the working directory. Needed to load asset files.
@@ -0,0 +1,279 @@
+package fees
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/bvnk/bank/ledger"
+	"github.com/bvnk/bank/money"
+)
+
+// Queryer is satisfied by both *sql.DB and *sql.Tx, so fees can be read inside the database
+// transaction that charges them
+type Queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+const scheduleColumns = "`id`, `code`, `desc`, `painType`, `accountType`, `merchantID`, `method`, `amount`, `rate`, `minimumFee`, `maximumFee`, `currency`, `status`, `timestamp`"
+
+const waiverColumns = "`id`, `code`, `painType`, `accountNumber`, `accountType`, `merchantID`, " +
+	"IFNULL(DATE_FORMAT(`fromDate`, '%Y-%m-%d'), ''), IFNULL(DATE_FORMAT(`toDate`, '%Y-%m-%d'), ''), `reason`, `status`, `timestamp`"
+
+// doSaveSchedule writes a schedule and its tiers in one database transaction
+func doSaveSchedule(schedule Schedule) (scheduleID int64, err error) {
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return 0, errors.New("fees.doSaveSchedule: Could not start database transaction. " + err.Error())
+	}
+
+	insertStatement := "INSERT INTO fee_schedules (`code`, `desc`, `painType`, `accountType`, `merchantID`, `method`, `amount`, `rate`, `minimumFee`, `maximumFee`, `currency`, `status`, `timestamp`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	stmtIns, err := tx.Prepare(insertStatement)
+	if err != nil {
+		tx.Rollback()
+		return 0, errors.New("fees.doSaveSchedule: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	res, err := stmtIns.Exec(schedule.Code, schedule.Desc, schedule.PainType, schedule.AccountType, schedule.MerchantID, schedule.Method, schedule.Amount, schedule.Rate, schedule.MinimumFee, schedule.MaximumFee, schedule.Currency, schedule.Status, schedule.Timestamp)
+	if err != nil {
+		tx.Rollback()
+		return 0, errors.New("fees.doSaveSchedule: " + err.Error())
+	}
+
+	scheduleID, err = res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, errors.New("fees.doSaveSchedule: " + err.Error())
+	}
+
+	stmtTier, err := tx.Prepare("INSERT INTO fee_schedule_tiers (`scheduleID`, `minimumAmount`, `rate`) VALUES(?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return 0, errors.New("fees.doSaveSchedule: " + err.Error())
+	}
+	defer stmtTier.Close()
+
+	for _, tier := range schedule.Tiers {
+		_, err = stmtTier.Exec(scheduleID, tier.MinimumAmount, tier.Rate)
+		if err != nil {
+			tx.Rollback()
+			return 0, errors.New("fees.doSaveSchedule: " + err.Error())
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, errors.New("fees.doSaveSchedule: Could not commit transaction. " + err.Error())
+	}
+
+	return
+}
+
+func doSaveWaiver(waiver Waiver) (waiverID int64, err error) {
+	insertStatement := "INSERT INTO fee_waivers (`code`, `painType`, `accountNumber`, `accountType`, `merchantID`, `fromDate`, `toDate`, `reason`, `status`, `timestamp`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	stmtIns, err := Config.Db.Prepare(insertStatement)
+	if err != nil {
+		return 0, errors.New("fees.doSaveWaiver: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	fromDate := sql.NullString{String: waiver.FromDate, Valid: waiver.FromDate != ""}
+	toDate := sql.NullString{String: waiver.ToDate, Valid: waiver.ToDate != ""}
+	res, err := stmtIns.Exec(waiver.Code, waiver.PainType, waiver.AccountNumber, waiver.AccountType, waiver.MerchantID, fromDate, toDate, waiver.Reason, waiver.Status, waiver.Timestamp)
+	if err != nil {
+		return 0, errors.New("fees.doSaveWaiver: " + err.Error())
+	}
+
+	waiverID, err = res.LastInsertId()
+	if err != nil {
+		return 0, errors.New("fees.doSaveWaiver: " + err.Error())
+	}
+
+	return
+}
+
+// doWithdraw withdraws an active schedule or waiver from table
+func doWithdraw(table string, id int64) (err error) {
+	stmtUpdate, err := Config.Db.Prepare("UPDATE " + table + " SET `status` = ? WHERE `id` = ? AND `status` = ?")
+	if err != nil {
+		return errors.New("fees.doWithdraw: " + err.Error())
+	}
+	defer stmtUpdate.Close()
+
+	res, err := stmtUpdate.Exec(STATUS_WITHDRAWN, id, STATUS_ACTIVE)
+	if err != nil {
+		return errors.New("fees.doWithdraw: " + err.Error())
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errors.New("fees.doWithdraw: " + err.Error())
+	}
+	if affected == 0 {
+		return errors.New("fees.doWithdraw: No active entry with ID " + strconv.FormatInt(id, 10))
+	}
+
+	return
+}
+
+func getActiveSchedules(db Queryer) (schedules []Schedule, err error) {
+	rows, err := db.Query("SELECT "+scheduleColumns+" FROM `fee_schedules` WHERE `status` = ? ORDER BY `code`, `id`", STATUS_ACTIVE)
+	if err != nil {
+		return nil, errors.New("fees.getActiveSchedules: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		schedule := Schedule{}
+		err = rows.Scan(&schedule.ID, &schedule.Code, &schedule.Desc, &schedule.PainType, &schedule.AccountType, &schedule.MerchantID, &schedule.Method, &schedule.Amount, &schedule.Rate, &schedule.MinimumFee, &schedule.MaximumFee, &schedule.Currency, &schedule.Status, &schedule.Timestamp)
+		if err != nil {
+			return nil, errors.New("fees.getActiveSchedules: Could not retrieve schedule. " + err.Error())
+		}
+		schedule.Amount = schedule.Amount.In(schedule.Currency)
+		schedule.MinimumFee = schedule.MinimumFee.In(schedule.Currency)
+		schedule.MaximumFee = schedule.MaximumFee.In(schedule.Currency)
+		schedules = append(schedules, schedule)
+	}
+	rows.Close()
+
+	for i := range schedules {
+		if schedules[i].Method != METHOD_TIERED {
+			continue
+		}
+		schedules[i].Tiers, err = getTiers(db, schedules[i].ID)
+		if err != nil {
+			return nil, errors.New("fees.getActiveSchedules: " + err.Error())
+		}
+	}
+
+	return
+}
+
+func getTiers(db Queryer, scheduleID int64) (tiers []Tier, err error) {
+	rows, err := db.Query("SELECT `minimumAmount`, `rate` FROM `fee_schedule_tiers` WHERE `scheduleID` = ? ORDER BY `minimumAmount`", scheduleID)
+	if err != nil {
+		return nil, errors.New("fees.getTiers: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tier := Tier{}
+		err = rows.Scan(&tier.MinimumAmount, &tier.Rate)
+		if err != nil {
+			return nil, errors.New("fees.getTiers: Could not retrieve tier. " + err.Error())
+		}
+		tiers = append(tiers, tier)
+	}
+
+	return
+}
+
+func getActiveWaivers(db Queryer) (waivers []Waiver, err error) {
+	rows, err := db.Query("SELECT "+waiverColumns+" FROM `fee_waivers` WHERE `status` = ? ORDER BY `id`", STATUS_ACTIVE)
+	if err != nil {
+		return nil, errors.New("fees.getActiveWaivers: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		waiver := Waiver{}
+		err = rows.Scan(&waiver.ID, &waiver.Code, &waiver.PainType, &waiver.AccountNumber, &waiver.AccountType, &waiver.MerchantID, &waiver.FromDate, &waiver.ToDate, &waiver.Reason, &waiver.Status, &waiver.Timestamp)
+		if err != nil {
+			return nil, errors.New("fees.getActiveWaivers: Could not retrieve waiver. " + err.Error())
+		}
+		waivers = append(waivers, waiver)
+	}
+
+	return
+}
+
+// getPayer reads the account type and merchant of the account paying the fees
+func getPayer(db Queryer, accountNumber string) (payer Payer, err error) {
+	rows, err := db.Query("SELECT a.`type`, IFNULL(mu.`merchantID`, '') FROM `accounts` a "+
+		"LEFT JOIN `merchant_users_accounts` mu ON mu.`accountNumber` = a.`accountNumber` AND mu.`bankNumber` = a.`bankNumber` "+
+		"WHERE a.`accountNumber` = ? LIMIT 1", accountNumber)
+	if err != nil {
+		return Payer{}, errors.New("fees.getPayer: " + err.Error())
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return Payer{}, errors.New("fees.getPayer: Account " + accountNumber + " not found")
+	}
+	payer.AccountNumber = accountNumber
+	err = rows.Scan(&payer.AccountType, &payer.MerchantID)
+	if err != nil {
+		return Payer{}, errors.New("fees.getPayer: Could not retrieve account. " + err.Error())
+	}
+
+	return
+}
+
+// Assess works out the fees the account pays on a transaction of painType for amount, from
+// the schedules and waivers in force now
+func Assess(db Queryer, painType int64, accountNumber string, amount money.Money, now time.Time) (items []Item, err error) {
+	payer, err := getPayer(db, accountNumber)
+	if err != nil {
+		return nil, errors.New("fees.Assess: " + err.Error())
+	}
+
+	schedules, err := getActiveSchedules(db)
+	if err != nil {
+		return nil, errors.New("fees.Assess: " + err.Error())
+	}
+
+	waivers, err := getActiveWaivers(db)
+	if err != nil {
+		return nil, errors.New("fees.Assess: " + err.Error())
+	}
+
+	return Breakdown(schedules, waivers, payer, painType, amount, now.In(location()).Format("2006-01-02")), nil
+}
+
+// SaveItems stores the fee breakdown of a transaction
+func SaveItems(db ledger.Preparer, transactionID int64, items []Item) (err error) {
+	if len(items) == 0 {
+		return
+	}
+
+	insertStatement := "INSERT INTO transaction_fees (`transactionID`, `code`, `desc`, `scheduleID`, `waiverID`, `amount`, `incomeAccount`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?)"
+	stmtIns, err := db.Prepare(insertStatement)
+	if err != nil {
+		return errors.New("fees.SaveItems: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	for _, item := range items {
+		_, err = stmtIns.Exec(transactionID, item.Code, item.Desc, item.ScheduleID, item.WaiverID, item.Amount, item.IncomeAccount)
+		if err != nil {
+			return errors.New("fees.SaveItems: " + err.Error())
+		}
+	}
+
+	return
+}
+
+// GetItems returns the fee breakdown of a transaction
+func GetItems(db Queryer, transactionID int64) (items []Item, err error) {
+	rows, err := db.Query("SELECT `transactionID`, `code`, `desc`, `scheduleID`, `waiverID`, `amount`, `incomeAccount` FROM `transaction_fees` WHERE `transactionID` = ? ORDER BY `code`", transactionID)
+	if err != nil {
+		return nil, errors.New("fees.GetItems: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := Item{}
+		err = rows.Scan(&item.TransactionID, &item.Code, &item.Desc, &item.ScheduleID, &item.WaiverID, &item.Amount, &item.IncomeAccount)
+		if err != nil {
+			return nil, errors.New("fees.GetItems: Could not retrieve fee. " + err.Error())
+		}
+		items = append(items, item)
+	}
+
+	return
+}
@@ -0,0 +1,155 @@
+package fees
+
+import (
+	"sort"
+
+	"github.com/bvnk/bank/ledger"
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+// Payer is the account a transaction's fees are charged to
+type Payer struct {
+	AccountNumber string
+	AccountType   string
+	MerchantID    string
+}
+
+// Breakdown works out the fees of a transaction of painType for amount paid by payer on date
+// (YYYY-MM-DD). Each fee code is charged once, by its most specific schedule, and the items
+// are ordered by code. Schedules are priced in one currency and only charge transactions in it
+func Breakdown(schedules []Schedule, waivers []Waiver, payer Payer, painType int64, amount money.Money, date string) (items []Item) {
+	chosen := map[string]Schedule{}
+	for _, schedule := range schedules {
+		if !schedule.applies(payer, painType, amount.Currency) {
+			continue
+		}
+		current, ok := chosen[schedule.Code]
+		if !ok || schedule.weight() > current.weight() || (schedule.weight() == current.weight() && schedule.ID > current.ID) {
+			chosen[schedule.Code] = schedule
+		}
+	}
+
+	for _, schedule := range chosen {
+		item := Item{
+			Code:          schedule.Code,
+			Desc:          schedule.Desc,
+			ScheduleID:    schedule.ID,
+			Amount:        schedule.fee(amount),
+			IncomeAccount: ledger.FeeIncomeAccount(schedule.Code),
+		}
+		for _, waiver := range waivers {
+			if waiver.applies(schedule.Code, payer, painType, date) {
+				item.WaiverID = waiver.ID
+				item.Amount = money.Zero(amount.Currency)
+				break
+			}
+		}
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Code < items[j].Code })
+	return
+}
+
+// Total adds up the amounts of fee items
+func Total(items []Item, currency string) money.Money {
+	total := money.Zero(currency)
+	for _, item := range items {
+		total = total.Add(item.Amount)
+	}
+	return total
+}
+
+func (schedule Schedule) applies(payer Payer, painType int64, currency string) bool {
+	if schedule.Status != STATUS_ACTIVE {
+		return false
+	}
+	if schedule.Currency != currency {
+		return false
+	}
+	if schedule.PainType != 0 && schedule.PainType != painType {
+		return false
+	}
+	if schedule.AccountType != "" && schedule.AccountType != payer.AccountType {
+		return false
+	}
+	if schedule.MerchantID != "" && schedule.MerchantID != payer.MerchantID {
+		return false
+	}
+	return true
+}
+
+// weight ranks how specific a schedule is: a merchant outranks an account type, which
+// outranks a PAIN type
+func (schedule Schedule) weight() (weight int) {
+	if schedule.MerchantID != "" {
+		weight += 4
+	}
+	if schedule.AccountType != "" {
+		weight += 2
+	}
+	if schedule.PainType != 0 {
+		weight++
+	}
+	return
+}
+
+// fee charges the schedule on amount, kept between the minimum and maximum fee
+func (schedule Schedule) fee(amount money.Money) (fee money.Money) {
+	switch schedule.Method {
+	case METHOD_FLAT:
+		fee = money.New(schedule.Amount.Amount, amount.Currency)
+	case METHOD_PERCENTAGE:
+		fee = amount.MulRate(schedule.Rate.Div(decimal.New(100, 0)))
+	case METHOD_TIERED:
+		rate := schedule.Rate
+		for _, tier := range schedule.Tiers {
+			if amount.Amount.LessThan(tier.MinimumAmount) {
+				break
+			}
+			rate = tier.Rate
+		}
+		fee = amount.MulRate(rate.Div(decimal.New(100, 0)))
+	default:
+		fee = money.Zero(amount.Currency)
+	}
+
+	minimum := money.New(schedule.MinimumFee.Amount, amount.Currency)
+	if fee.Cmp(minimum) < 0 {
+		fee = minimum
+	}
+	maximum := money.New(schedule.MaximumFee.Amount, amount.Currency)
+	if !maximum.IsZero() && fee.Cmp(maximum) > 0 {
+		fee = maximum
+	}
+	return
+}
+
+func (waiver Waiver) applies(code string, payer Payer, painType int64, date string) bool {
+	if waiver.Status != STATUS_ACTIVE {
+		return false
+	}
+	if waiver.Code != "" && waiver.Code != code {
+		return false
+	}
+	if waiver.PainType != 0 && waiver.PainType != painType {
+		return false
+	}
+	if waiver.AccountNumber != "" && waiver.AccountNumber != payer.AccountNumber {
+		return false
+	}
+	if waiver.AccountType != "" && waiver.AccountType != payer.AccountType {
+		return false
+	}
+	if waiver.MerchantID != "" && waiver.MerchantID != payer.MerchantID {
+		return false
+	}
+	if waiver.FromDate != "" && date < waiver.FromDate {
+		return false
+	}
+	if waiver.ToDate != "" && date > waiver.ToDate {
+		return false
+	}
+	return true
+}
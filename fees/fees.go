@@ -0,0 +1,533 @@
+package fees
+
+/*
+Transaction fees
+
+Fees on payments, direct debits and deposits are worked out by a fee engine from fee
+schedules. A schedule charges a fee, identified by its Code, as a flat amount, a percentage of
+the transaction amount, or a percentage that depends on the amount (tiered), kept between a
+minimum and a maximum. Schedules can apply to one PAIN type, one account type (product) and
+one merchant, each left empty for all. When several schedules of the same fee apply, the most
+specific wins: a merchant's schedule over an account type's, over a PAIN type's. Different
+fees add up, and each is credited to its own income account (bank:fee-income:Code).
+
+The fee is paid by the account that initiates the transaction: the sender of a payment, the
+merchant collecting a direct debit and the account a deposit is made into. Waivers let a fee,
+or all fees, go uncharged for an account, account type or merchant, optionally for a period.
+
+fee~1~
+   Code~
+   Desc~
+   PainType~
+   AccountType~
+   MerchantID~
+   Method~
+   Amount~
+   Rate~
+   MinimumFee~
+   MaximumFee~
+   Tiers~
+   Currency
+
+Adds a schedule. Code is lower case letters, digits and hyphens. PainType is 0 or empty for
+all types. Method is flat (Amount), percentage (Rate in percent) or tiered, which charges Rate
+below the first tier and otherwise the rate of the highest tier the amount reaches, with
+Tiers as minimumAmount:rate pairs separated by commas (e.g. 1000:0.5,10000:0.25).
+MaximumFee 0 or empty means no maximum. The amounts and tiers are in Currency (USD when
+empty), and the schedule only charges transactions in that currency, so each currency the
+bank holds accounts in is priced with schedules of its own.
+
+fee~2~
+   ScheduleID
+
+Withdraws a schedule.
+
+fee~3~
+   Code~
+   PainType~
+   AccountNumber~
+   AccountType~
+   MerchantID~
+   FromDate~
+   ToDate~
+   Reason
+
+Adds a waiver of the fee with Code, or of all fees when empty, for transactions of PainType
+(0 or empty for all types). At least one of AccountNumber, AccountType and MerchantID must be
+given. FromDate and ToDate are YYYY-MM-DD and inclusive, empty for no limit.
+
+fee~4~
+   WaiverID
+
+Cancels a waiver.
+
+fee~1000
+fee~1001
+fee~1002~
+   TransactionID
+
+List the active schedules, the active waivers and the itemised fees of a transaction.
+
+All are limited to bank operators.
+*/
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/configuration"
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+var Config configuration.Configuration
+
+func SetConfig(config *configuration.Configuration) {
+	Config = *config
+}
+
+// Fee methods
+const (
+	METHOD_FLAT       = "flat"
+	METHOD_PERCENTAGE = "percentage"
+	METHOD_TIERED     = "tiered"
+)
+
+// Schedule and waiver statuses
+const (
+	STATUS_ACTIVE    = "active"
+	STATUS_WITHDRAWN = "withdrawn"
+)
+
+// Largest fee rate, in percent
+const MAX_FEE_RATE = 100
+
+var codePattern = regexp.MustCompile(`^[a-z0-9-]{1,20}$`)
+
+// Schedule is how one fee is charged on the transactions it applies to
+type Schedule struct {
+	ID          int64
+	Code        string
+	Desc        string
+	PainType    int64
+	AccountType string
+	MerchantID  string
+	Method      string
+	Amount      money.Money
+	Rate        decimal.Decimal
+	MinimumFee  money.Money
+	MaximumFee  money.Money
+	Tiers       []Tier
+	Currency    string
+	Status      string
+	Timestamp   int32
+}
+
+// Tier is the rate charged on amounts of at least MinimumAmount
+type Tier struct {
+	MinimumAmount decimal.Decimal
+	Rate          decimal.Decimal
+}
+
+// Waiver lets fees go uncharged
+type Waiver struct {
+	ID            int64
+	Code          string
+	PainType      int64
+	AccountNumber string
+	AccountType   string
+	MerchantID    string
+	FromDate      string
+	ToDate        string
+	Reason        string
+	Status        string
+	Timestamp     int32
+}
+
+// Item is one fee charged on a transaction. Waived fees are kept with a zero amount and the
+// waiver that cleared them
+type Item struct {
+	TransactionID int64
+	Code          string
+	Desc          string
+	ScheduleID    int64
+	WaiverID      int64
+	Amount        money.Money
+	IncomeAccount string
+}
+
+func ProcessFee(data []string) (result interface{}, err error) {
+	if len(data) < 3 {
+		return "", errors.New("fees.ProcessFee: Not all data is present")
+	}
+
+	// Remove null termination from the last field
+	data[len(data)-1] = strings.TrimRight(data[len(data)-1], "\x00")
+
+	feeType, err := strconv.ParseInt(data[2], 10, 64)
+	if err != nil {
+		return "", errors.New("fees.ProcessFee: Could not get type of fee request. " + err.Error())
+	}
+
+	switch feeType {
+	case 1:
+		result, err = setSchedule(data)
+		if err != nil {
+			return "", errors.New("fees.ProcessFee: " + err.Error())
+		}
+		break
+	case 2:
+		result, err = withdrawSchedule(data)
+		if err != nil {
+			return "", errors.New("fees.ProcessFee: " + err.Error())
+		}
+		break
+	case 3:
+		result, err = setWaiver(data)
+		if err != nil {
+			return "", errors.New("fees.ProcessFee: " + err.Error())
+		}
+		break
+	case 4:
+		result, err = cancelWaiver(data)
+		if err != nil {
+			return "", errors.New("fees.ProcessFee: " + err.Error())
+		}
+		break
+	case 1000:
+		_, err = appauth.CheckOperator(data[0])
+		if err != nil {
+			return "", errors.New("fees.ProcessFee: " + err.Error())
+		}
+		result, err = getActiveSchedules(Config.Db)
+		if err != nil {
+			return "", errors.New("fees.ProcessFee: " + err.Error())
+		}
+		break
+	case 1001:
+		_, err = appauth.CheckOperator(data[0])
+		if err != nil {
+			return "", errors.New("fees.ProcessFee: " + err.Error())
+		}
+		result, err = getActiveWaivers(Config.Db)
+		if err != nil {
+			return "", errors.New("fees.ProcessFee: " + err.Error())
+		}
+		break
+	case 1002:
+		result, err = transactionFees(data)
+		if err != nil {
+			return "", errors.New("fees.ProcessFee: " + err.Error())
+		}
+		break
+	default:
+		return "", errors.New("fees.ProcessFee: Fee request type invalid")
+	}
+
+	return
+}
+
+func setSchedule(data []string) (result interface{}, err error) {
+	if len(data) < 9 {
+		return "", errors.New("fees.setSchedule: Not all fields present")
+	}
+
+	currency := ""
+	if len(data) > 14 {
+		currency = data[14]
+	}
+	currency, err = money.ParseCurrency(currency)
+	if err != nil {
+		return "", errors.New("fees.setSchedule: " + err.Error())
+	}
+
+	schedule := Schedule{
+		Code:        data[3],
+		Desc:        data[4],
+		AccountType: data[6],
+		MerchantID:  data[7],
+		Method:      data[8],
+		Amount:      money.Zero(currency),
+		MinimumFee:  money.Zero(currency),
+		MaximumFee:  money.Zero(currency),
+		Currency:    currency,
+		Status:      STATUS_ACTIVE,
+		Timestamp:   int32(time.Now().Unix()),
+	}
+	if !codePattern.MatchString(schedule.Code) {
+		return "", errors.New("fees.setSchedule: Code must be 1 to 20 lower case letters, digits or hyphens")
+	}
+	if schedule.Desc == "" {
+		return "", errors.New("fees.setSchedule: Description cannot be empty")
+	}
+
+	schedule.PainType, err = parsePainType(data[5])
+	if err != nil {
+		return "", errors.New("fees.setSchedule: " + err.Error())
+	}
+
+	if schedule.Method != METHOD_FLAT && schedule.Method != METHOD_PERCENTAGE && schedule.Method != METHOD_TIERED {
+		return "", errors.New("fees.setSchedule: Method must be flat, percentage or tiered")
+	}
+	if len(data) > 9 && data[9] != "" {
+		schedule.Amount, err = parseAmount(data[9], currency)
+		if err != nil {
+			return "", errors.New("fees.setSchedule: Amount not valid. " + err.Error())
+		}
+	}
+	if len(data) > 10 && data[10] != "" {
+		schedule.Rate, err = parseRate(data[10])
+		if err != nil {
+			return "", errors.New("fees.setSchedule: " + err.Error())
+		}
+	}
+	if len(data) > 11 && data[11] != "" {
+		schedule.MinimumFee, err = parseAmount(data[11], currency)
+		if err != nil {
+			return "", errors.New("fees.setSchedule: Minimum fee not valid. " + err.Error())
+		}
+	}
+	if len(data) > 12 && data[12] != "" {
+		schedule.MaximumFee, err = parseAmount(data[12], currency)
+		if err != nil {
+			return "", errors.New("fees.setSchedule: Maximum fee not valid. " + err.Error())
+		}
+	}
+	if !schedule.MaximumFee.IsZero() && schedule.MaximumFee.Cmp(schedule.MinimumFee) < 0 {
+		return "", errors.New("fees.setSchedule: Maximum fee cannot be below the minimum fee")
+	}
+	if len(data) > 13 && data[13] != "" {
+		schedule.Tiers, err = parseTiers(data[13])
+		if err != nil {
+			return "", errors.New("fees.setSchedule: " + err.Error())
+		}
+	}
+
+	switch schedule.Method {
+	case METHOD_FLAT:
+		if !schedule.Rate.IsZero() || len(schedule.Tiers) > 0 {
+			return "", errors.New("fees.setSchedule: Flat fees have an amount, not a rate or tiers")
+		}
+	case METHOD_PERCENTAGE:
+		if !schedule.Amount.IsZero() || len(schedule.Tiers) > 0 {
+			return "", errors.New("fees.setSchedule: Percentage fees have a rate, not an amount or tiers")
+		}
+	case METHOD_TIERED:
+		if !schedule.Amount.IsZero() {
+			return "", errors.New("fees.setSchedule: Tiered fees have rates, not an amount")
+		}
+		if len(schedule.Tiers) == 0 {
+			return "", errors.New("fees.setSchedule: Tiered fees need at least one tier")
+		}
+	}
+
+	_, err = appauth.CheckOperator(data[0])
+	if err != nil {
+		return "", errors.New("fees.setSchedule: " + err.Error())
+	}
+
+	schedule.ID, err = doSaveSchedule(schedule)
+	if err != nil {
+		return "", errors.New("fees.setSchedule: " + err.Error())
+	}
+
+	return schedule, nil
+}
+
+func withdrawSchedule(data []string) (result interface{}, err error) {
+	if len(data) < 4 {
+		return "", errors.New("fees.withdrawSchedule: Not all fields present")
+	}
+
+	scheduleID, err := strconv.ParseInt(data[3], 10, 64)
+	if err != nil {
+		return "", errors.New("fees.withdrawSchedule: Could not parse schedule ID. " + err.Error())
+	}
+
+	_, err = appauth.CheckOperator(data[0])
+	if err != nil {
+		return "", errors.New("fees.withdrawSchedule: " + err.Error())
+	}
+
+	err = doWithdraw("fee_schedules", scheduleID)
+	if err != nil {
+		return "", errors.New("fees.withdrawSchedule: " + err.Error())
+	}
+
+	return scheduleID, nil
+}
+
+func setWaiver(data []string) (result interface{}, err error) {
+	if len(data) < 11 {
+		return "", errors.New("fees.setWaiver: Not all fields present")
+	}
+
+	waiver := Waiver{
+		Code:          data[3],
+		AccountNumber: data[5],
+		AccountType:   data[6],
+		MerchantID:    data[7],
+		FromDate:      data[8],
+		ToDate:        data[9],
+		Reason:        data[10],
+		Status:        STATUS_ACTIVE,
+		Timestamp:     int32(time.Now().Unix()),
+	}
+	if waiver.Code != "" && !codePattern.MatchString(waiver.Code) {
+		return "", errors.New("fees.setWaiver: Code must be 1 to 20 lower case letters, digits or hyphens")
+	}
+
+	waiver.PainType, err = parsePainType(data[4])
+	if err != nil {
+		return "", errors.New("fees.setWaiver: " + err.Error())
+	}
+
+	if waiver.AccountNumber == "" && waiver.AccountType == "" && waiver.MerchantID == "" {
+		return "", errors.New("fees.setWaiver: Waivers need an account number, account type or merchant")
+	}
+	for _, date := range []string{waiver.FromDate, waiver.ToDate} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return "", errors.New("fees.setWaiver: Dates must be YYYY-MM-DD")
+		}
+	}
+	if waiver.FromDate != "" && waiver.ToDate != "" && waiver.ToDate < waiver.FromDate {
+		return "", errors.New("fees.setWaiver: To date before from date")
+	}
+	if waiver.Reason == "" {
+		return "", errors.New("fees.setWaiver: Reason cannot be empty")
+	}
+
+	_, err = appauth.CheckOperator(data[0])
+	if err != nil {
+		return "", errors.New("fees.setWaiver: " + err.Error())
+	}
+
+	waiver.ID, err = doSaveWaiver(waiver)
+	if err != nil {
+		return "", errors.New("fees.setWaiver: " + err.Error())
+	}
+
+	return waiver, nil
+}
+
+func cancelWaiver(data []string) (result interface{}, err error) {
+	if len(data) < 4 {
+		return "", errors.New("fees.cancelWaiver: Not all fields present")
+	}
+
+	waiverID, err := strconv.ParseInt(data[3], 10, 64)
+	if err != nil {
+		return "", errors.New("fees.cancelWaiver: Could not parse waiver ID. " + err.Error())
+	}
+
+	_, err = appauth.CheckOperator(data[0])
+	if err != nil {
+		return "", errors.New("fees.cancelWaiver: " + err.Error())
+	}
+
+	err = doWithdraw("fee_waivers", waiverID)
+	if err != nil {
+		return "", errors.New("fees.cancelWaiver: " + err.Error())
+	}
+
+	return waiverID, nil
+}
+
+func transactionFees(data []string) (result interface{}, err error) {
+	if len(data) < 4 {
+		return "", errors.New("fees.transactionFees: Not all fields present")
+	}
+
+	transactionID, err := strconv.ParseInt(data[3], 10, 64)
+	if err != nil {
+		return "", errors.New("fees.transactionFees: Could not parse transaction ID. " + err.Error())
+	}
+
+	_, err = appauth.CheckOperator(data[0])
+	if err != nil {
+		return "", errors.New("fees.transactionFees: " + err.Error())
+	}
+
+	items, err := GetItems(Config.Db, transactionID)
+	if err != nil {
+		return "", errors.New("fees.transactionFees: " + err.Error())
+	}
+
+	return items, nil
+}
+
+func parsePainType(value string) (painType int64, err error) {
+	if value == "" {
+		return 0, nil
+	}
+	painType, err = strconv.ParseInt(value, 10, 64)
+	if err != nil || painType < 0 {
+		return 0, errors.New("fees.parsePainType: PAIN type must be a number")
+	}
+	return
+}
+
+func parseAmount(value string, currency string) (amount money.Money, err error) {
+	amount, err = money.NewFromString(strings.TrimSpace(value), currency)
+	if err != nil {
+		return money.Money{}, errors.New("fees.parseAmount: " + err.Error())
+	}
+	if amount.Sign() < 0 {
+		return money.Money{}, errors.New("fees.parseAmount: Amount cannot be negative")
+	}
+	return
+}
+
+func parseRate(value string) (rate decimal.Decimal, err error) {
+	rate, err = decimal.NewFromString(strings.TrimSpace(value))
+	if err != nil {
+		return decimal.Zero, errors.New("fees.parseRate: Rate not valid. " + err.Error())
+	}
+	if rate.Sign() < 0 || rate.GreaterThan(decimal.New(MAX_FEE_RATE, 0)) {
+		return decimal.Zero, errors.New("fees.parseRate: Rate must be between 0 and 100 percent")
+	}
+	return
+}
+
+// parseTiers reads minimumAmount:rate pairs, sorted by minimum amount
+func parseTiers(value string) (tiers []Tier, err error) {
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 {
+			return nil, errors.New("fees.parseTiers: Tiers must be minimumAmount:rate pairs")
+		}
+		minimum, err := decimal.NewFromString(strings.TrimSpace(parts[0]))
+		if err != nil || minimum.Sign() < 0 {
+			return nil, errors.New("fees.parseTiers: Minimum amount " + parts[0] + " not valid")
+		}
+		rate, err := parseRate(parts[1])
+		if err != nil {
+			return nil, errors.New("fees.parseTiers: " + err.Error())
+		}
+		for _, tier := range tiers {
+			if tier.MinimumAmount.Equal(minimum) {
+				return nil, errors.New("fees.parseTiers: Minimum amount " + parts[0] + " appears twice")
+			}
+		}
+		tiers = append(tiers, Tier{MinimumAmount: minimum, Rate: rate})
+	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinimumAmount.LessThan(tiers[j].MinimumAmount) })
+	return
+}
+
+// location is the bank's time zone, which decides the day waivers apply on
+func location() *time.Location {
+	loc, err := time.LoadLocation(Config.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -0,0 +1,179 @@
+package fees
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bvnk/bank/ledger"
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+func TestProcessFee(t *testing.T) {
+	tst := []string{"", ""}
+	_, err := ProcessFee(tst)
+	if err == nil {
+		t.Errorf("ProcessFee does not pass. Looking for %v, got %v", "Not all data is present", nil)
+	}
+
+	// Each request fails validation before the token is checked
+	invalid := map[string]struct {
+		data     []string
+		expected string
+	}{
+		"request type":        {[]string{"", "", "0"}, "Fee request type invalid"},
+		"fields":              {[]string{"", "", "1", "payment", "Payment fee"}, "Not all fields present"},
+		"code":                {[]string{"", "", "1", "Payment", "Payment fee", "1", "", "", "flat", "1"}, "Code must be 1 to 20 lower case letters"},
+		"desc":                {[]string{"", "", "1", "payment", "", "1", "", "", "flat", "1"}, "Description cannot be empty"},
+		"pain type":           {[]string{"", "", "1", "payment", "Payment fee", "one", "", "", "flat", "1"}, "PAIN type must be a number"},
+		"method":              {[]string{"", "", "1", "payment", "Payment fee", "1", "", "", "fixed", "1"}, "Method must be flat, percentage or tiered"},
+		"amount":              {[]string{"", "", "1", "payment", "Payment fee", "1", "", "", "flat", "-1"}, "Amount cannot be negative"},
+		"rate":                {[]string{"", "", "1", "payment", "Payment fee", "1", "", "", "percentage", "", "101"}, "Rate must be between 0 and 100 percent"},
+		"flat with rate":      {[]string{"", "", "1", "payment", "Payment fee", "1", "", "", "flat", "1", "0.5"}, "Flat fees have an amount, not a rate or tiers"},
+		"percentage amount":   {[]string{"", "", "1", "payment", "Payment fee", "1", "", "", "percentage", "1", "0.5"}, "Percentage fees have a rate, not an amount or tiers"},
+		"maximum below min":   {[]string{"", "", "1", "payment", "Payment fee", "1", "", "", "percentage", "", "0.5", "2", "1"}, "Maximum fee cannot be below the minimum fee"},
+		"tiered without tier": {[]string{"", "", "1", "payment", "Payment fee", "1", "", "", "tiered", "", "0.5"}, "Tiered fees need at least one tier"},
+		"tiers":               {[]string{"", "", "1", "payment", "Payment fee", "1", "", "", "tiered", "", "0.5", "", "", "1000"}, "Tiers must be minimumAmount:rate pairs"},
+		"currency":            {[]string{"", "", "1", "payment", "Payment fee", "1", "", "", "flat", "1", "", "", "", "", "XYZ"}, "Currency XYZ not valid"},
+		"minor units":         {[]string{"", "", "1", "payment", "Payment fee", "1", "", "", "flat", "1.5", "", "", "", "", "JPY"}, "Amount has more decimal places than JPY allows"},
+		"withdraw fields":     {[]string{"", "", "2"}, "Not all fields present"},
+		"withdraw id":         {[]string{"", "", "2", "one"}, "Could not parse schedule ID"},
+		"waiver fields":       {[]string{"", "", "3", "payment"}, "Not all fields present"},
+		"waiver code":         {[]string{"", "", "3", "Payment", "", "accountNumber", "", "", "", "", "Goodwill"}, "Code must be 1 to 20 lower case letters"},
+		"waiver target":       {[]string{"", "", "3", "payment", "", "", "", "", "", "", "Goodwill"}, "Waivers need an account number, account type or merchant"},
+		"waiver date":         {[]string{"", "", "3", "payment", "", "accountNumber", "", "", "2017-13-01", "", "Goodwill"}, "Dates must be YYYY-MM-DD"},
+		"waiver dates":        {[]string{"", "", "3", "payment", "", "accountNumber", "", "", "2017-02-01", "2017-01-01", "Goodwill"}, "To date before from date"},
+		"waiver reason":       {[]string{"", "", "3", "payment", "", "accountNumber", "", "", "", "", ""}, "Reason cannot be empty"},
+		"cancel fields":       {[]string{"", "", "4"}, "Not all fields present"},
+		"transaction fields":  {[]string{"", "", "1002"}, "Not all fields present"},
+	}
+	for name, tst := range invalid {
+		_, err := ProcessFee(tst.data)
+		if err == nil || !strings.Contains(err.Error(), tst.expected) {
+			t.Errorf("ProcessFee %v does not pass. Looking for %v, got %v", name, tst.expected, err)
+		}
+	}
+}
+
+func TestParseTiers(t *testing.T) {
+	tiers, err := parseTiers("10000:0.25, 1000:0.5")
+	if err != nil || len(tiers) != 2 || !tiers[0].MinimumAmount.Equal(decimal.New(1000, 0)) || !tiers[1].Rate.Equal(decimal.New(25, -2)) {
+		t.Errorf("ParseTiers does not pass. Looking for %v, got %v %v", "sorted tiers", tiers, err)
+	}
+
+	_, err = parseTiers("1000:0.5,1000:0.25")
+	if err == nil {
+		t.Errorf("ParseTiers duplicate does not pass. Looking for %v, got %v", "appears twice", nil)
+	}
+}
+
+func TestScheduleFee(t *testing.T) {
+	percentage := Schedule{Method: METHOD_PERCENTAGE, Rate: decimal.New(1, -2)}
+	if fee := percentage.fee(money.RequireFromString("20", money.DEFAULT_CURRENCY)); fee.StringFixed() != "0.00" {
+		t.Errorf("Schedule fee percentage does not pass. Looking for %v, got %v", "0.00", fee.StringFixed())
+	}
+	if fee := percentage.fee(money.RequireFromString("12345", money.DEFAULT_CURRENCY)); fee.StringFixed() != "1.23" {
+		t.Errorf("Schedule fee percentage does not pass. Looking for %v, got %v", "1.23", fee.StringFixed())
+	}
+
+	flat := Schedule{Method: METHOD_FLAT, Amount: money.RequireFromString("0.50", money.DEFAULT_CURRENCY)}
+	if fee := flat.fee(money.RequireFromString("12345", money.DEFAULT_CURRENCY)); fee.StringFixed() != "0.50" {
+		t.Errorf("Schedule fee flat does not pass. Looking for %v, got %v", "0.50", fee.StringFixed())
+	}
+
+	tiered := Schedule{Method: METHOD_TIERED, Rate: decimal.New(1, 0), Tiers: []Tier{
+		{MinimumAmount: decimal.New(1000, 0), Rate: decimal.New(5, -1)},
+		{MinimumAmount: decimal.New(10000, 0), Rate: decimal.New(25, -2)},
+	}}
+	for amount, expected := range map[string]string{"100": "1.00", "1000": "5.00", "20000": "50.00"} {
+		if fee := tiered.fee(money.RequireFromString(amount, money.DEFAULT_CURRENCY)); fee.StringFixed() != expected {
+			t.Errorf("Schedule fee tiered %v does not pass. Looking for %v, got %v", amount, expected, fee.StringFixed())
+		}
+	}
+
+	// Minimum and maximum fees keep the fee between them
+	percentage.MinimumFee = money.RequireFromString("0.10", money.DEFAULT_CURRENCY)
+	percentage.MaximumFee = money.RequireFromString("1", money.DEFAULT_CURRENCY)
+	if fee := percentage.fee(money.RequireFromString("20", money.DEFAULT_CURRENCY)); fee.StringFixed() != "0.10" {
+		t.Errorf("Schedule fee minimum does not pass. Looking for %v, got %v", "0.10", fee.StringFixed())
+	}
+	if fee := percentage.fee(money.RequireFromString("12345", money.DEFAULT_CURRENCY)); fee.StringFixed() != "1.00" {
+		t.Errorf("Schedule fee maximum does not pass. Looking for %v, got %v", "1.00", fee.StringFixed())
+	}
+}
+
+func TestBreakdown(t *testing.T) {
+	schedules := []Schedule{
+		{ID: 1, Code: "payment", Desc: "Payment fee", PainType: 1, Method: METHOD_FLAT, Amount: money.RequireFromString("1", money.DEFAULT_CURRENCY), Currency: money.DEFAULT_CURRENCY, Status: STATUS_ACTIVE},
+		{ID: 2, Code: "payment", Desc: "Cheque payment fee", PainType: 1, AccountType: "cheque", Method: METHOD_FLAT, Amount: money.RequireFromString("0.50", money.DEFAULT_CURRENCY), Currency: money.DEFAULT_CURRENCY, Status: STATUS_ACTIVE},
+		{ID: 3, Code: "payment", Desc: "Merchant payment fee", MerchantID: "merchant", Method: METHOD_FLAT, Amount: money.RequireFromString("0.25", money.DEFAULT_CURRENCY), Currency: money.DEFAULT_CURRENCY, Status: STATUS_ACTIVE},
+		{ID: 4, Code: "fx", Desc: "Withdrawn fee", Method: METHOD_FLAT, Amount: money.RequireFromString("5", money.DEFAULT_CURRENCY), Currency: money.DEFAULT_CURRENCY, Status: STATUS_WITHDRAWN},
+		{ID: 5, Code: "deposit", Desc: "Deposit fee", PainType: 1000, Method: METHOD_FLAT, Amount: money.RequireFromString("2", money.DEFAULT_CURRENCY), Currency: money.DEFAULT_CURRENCY, Status: STATUS_ACTIVE},
+		{ID: 6, Code: "network", Desc: "Network fee", Method: METHOD_FLAT, Amount: money.RequireFromString("0.10", money.DEFAULT_CURRENCY), Currency: money.DEFAULT_CURRENCY, Status: STATUS_ACTIVE},
+	}
+
+	// The most specific schedule of each fee applies, ordered by code
+	items := Breakdown(schedules, nil, Payer{AccountNumber: "a", AccountType: "savings"}, 1, money.RequireFromString("100", money.DEFAULT_CURRENCY), "2017-01-01")
+	if len(items) != 2 || items[0].Code != "network" || items[1].ScheduleID != 1 {
+		t.Errorf("Breakdown does not pass. Looking for %v, got %v", "network and payment schedule 1", items)
+	}
+	if Total(items, money.DEFAULT_CURRENCY).StringFixed() != "1.10" {
+		t.Errorf("Breakdown total does not pass. Looking for %v, got %v", "1.10", Total(items, money.DEFAULT_CURRENCY).StringFixed())
+	}
+	if items[1].IncomeAccount != ledger.FeeIncomeAccount("payment") {
+		t.Errorf("Breakdown income account does not pass. Looking for %v, got %v", ledger.FeeIncomeAccount("payment"), items[1].IncomeAccount)
+	}
+
+	items = Breakdown(schedules, nil, Payer{AccountNumber: "a", AccountType: "cheque"}, 1, money.RequireFromString("100", money.DEFAULT_CURRENCY), "2017-01-01")
+	if len(items) != 2 || items[1].ScheduleID != 2 {
+		t.Errorf("Breakdown account type does not pass. Looking for %v, got %v", "payment schedule 2", items)
+	}
+
+	items = Breakdown(schedules, nil, Payer{AccountNumber: "a", AccountType: "cheque", MerchantID: "merchant"}, 1, money.RequireFromString("100", money.DEFAULT_CURRENCY), "2017-01-01")
+	if len(items) != 2 || items[1].ScheduleID != 3 {
+		t.Errorf("Breakdown merchant does not pass. Looking for %v, got %v", "payment schedule 3", items)
+	}
+
+	// Schedules only charge transactions in their currency
+	schedules = append(schedules, Schedule{ID: 7, Code: "payment", Desc: "Payment fee", PainType: 1, Method: METHOD_FLAT, Amount: money.RequireFromString("0.80", money.DEFAULT_CURRENCY).In("EUR"), Currency: "EUR", Status: STATUS_ACTIVE})
+	items = Breakdown(schedules, nil, Payer{AccountNumber: "a", AccountType: "savings"}, 1, money.RequireFromString("100", money.DEFAULT_CURRENCY).In("EUR"), "2017-01-01")
+	if len(items) != 1 || items[0].ScheduleID != 7 || items[0].Amount.Currency != "EUR" || items[0].Amount.StringFixed() != "0.80" {
+		t.Errorf("Breakdown currency does not pass. Looking for %v, got %v", "EUR payment schedule 7", items)
+	}
+	items = Breakdown(schedules, nil, Payer{AccountNumber: "a", AccountType: "savings"}, 1, money.RequireFromString("100", money.DEFAULT_CURRENCY).In("JPY"), "2017-01-01")
+	if len(items) != 0 {
+		t.Errorf("Breakdown unpriced currency does not pass. Looking for %v, got %v", "no fees", items)
+	}
+}
+
+func TestBreakdownWaivers(t *testing.T) {
+	schedules := []Schedule{
+		{ID: 1, Code: "payment", PainType: 1, Method: METHOD_FLAT, Amount: money.RequireFromString("1", money.DEFAULT_CURRENCY), Currency: money.DEFAULT_CURRENCY, Status: STATUS_ACTIVE},
+		{ID: 2, Code: "network", Method: METHOD_FLAT, Amount: money.RequireFromString("0.10", money.DEFAULT_CURRENCY), Currency: money.DEFAULT_CURRENCY, Status: STATUS_ACTIVE},
+	}
+	payer := Payer{AccountNumber: "a", AccountType: "cheque"}
+
+	waivers := []Waiver{{ID: 7, Code: "payment", AccountNumber: "a", FromDate: "2017-01-01", ToDate: "2017-01-31", Status: STATUS_ACTIVE}}
+	items := Breakdown(schedules, waivers, payer, 1, money.RequireFromString("100", money.DEFAULT_CURRENCY), "2017-01-31")
+	if len(items) != 2 || items[1].WaiverID != 7 || !items[1].Amount.IsZero() || items[0].Amount.StringFixed() != "0.10" {
+		t.Errorf("Breakdown waiver does not pass. Looking for %v, got %v", "payment waived", items)
+	}
+
+	items = Breakdown(schedules, waivers, payer, 1, money.RequireFromString("100", money.DEFAULT_CURRENCY), "2017-02-01")
+	if items[1].WaiverID != 0 {
+		t.Errorf("Breakdown expired waiver does not pass. Looking for %v, got %v", 0, items[1].WaiverID)
+	}
+
+	// A waiver without a code waives every fee
+	waivers = []Waiver{{ID: 8, AccountType: "cheque", Status: STATUS_ACTIVE}}
+	items = Breakdown(schedules, waivers, payer, 1, money.RequireFromString("100", money.DEFAULT_CURRENCY), "2017-01-01")
+	if !Total(items, money.DEFAULT_CURRENCY).IsZero() {
+		t.Errorf("Breakdown all fees waiver does not pass. Looking for %v, got %v", "0.00", Total(items, money.DEFAULT_CURRENCY).StringFixed())
+	}
+
+	waivers[0].Status = STATUS_WITHDRAWN
+	items = Breakdown(schedules, waivers, payer, 1, money.RequireFromString("100", money.DEFAULT_CURRENCY), "2017-01-01")
+	if Total(items, money.DEFAULT_CURRENCY).StringFixed() != "1.10" {
+		t.Errorf("Breakdown cancelled waiver does not pass. Looking for %v, got %v", "1.10", Total(items, money.DEFAULT_CURRENCY).StringFixed())
+	}
+}
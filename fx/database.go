@@ -0,0 +1,188 @@
+package fx
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/bvnk/bank/transactions"
+	"github.com/satori/go.uuid"
+)
+
+const rateColumns = "`baseCurrency`, `quoteCurrency`, `midRate`, `spread`, `source`, `timestamp`"
+
+const quoteColumns = "`quoteID`, `userID`, `amount`, `currency`, `convertedAmount`, `convertedCurrency`, `midRate`, `rate`, `spread`, `status`, `transactionID`, `expires`, `timestamp`"
+
+// doSaveRates sets the rates of their pairs in one database transaction
+func doSaveRates(rates []Rate) (err error) {
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return errors.New("fx.doSaveRates: Could not start database transaction. " + err.Error())
+	}
+
+	insertStatement := "INSERT INTO fx_rates (" + rateColumns + ") "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?) "
+	insertStatement += "ON DUPLICATE KEY UPDATE `midRate` = VALUES(`midRate`), `spread` = VALUES(`spread`), `source` = VALUES(`source`), `timestamp` = VALUES(`timestamp`)"
+	stmtIns, err := tx.Prepare(insertStatement)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("fx.doSaveRates: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	for _, rate := range rates {
+		_, err = stmtIns.Exec(rate.BaseCurrency, rate.QuoteCurrency, rate.MidRate, rate.Spread, rate.Source, rate.Timestamp)
+		if err != nil {
+			tx.Rollback()
+			return errors.New("fx.doSaveRates: " + err.Error())
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("fx.doSaveRates: Could not commit transaction. " + err.Error())
+	}
+
+	return
+}
+
+func getAllRates() (rates []Rate, err error) {
+	return queryRates("SELECT " + rateColumns + " FROM `fx_rates` ORDER BY `baseCurrency`, `quoteCurrency`")
+}
+
+// getPairRates returns the rates that convert between two currencies, either way round
+func getPairRates(from string, to string) (rates []Rate, err error) {
+	return queryRates("SELECT "+rateColumns+" FROM `fx_rates` WHERE (`baseCurrency` = ? AND `quoteCurrency` = ?) OR (`baseCurrency` = ? AND `quoteCurrency` = ?)", from, to, to, from)
+}
+
+func queryRates(query string, args ...interface{}) (rates []Rate, err error) {
+	rows, err := Config.Db.Query(query, args...)
+	if err != nil {
+		return nil, errors.New("fx.queryRates: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rate := Rate{}
+		err = rows.Scan(&rate.BaseCurrency, &rate.QuoteCurrency, &rate.MidRate, &rate.Spread, &rate.Source, &rate.Timestamp)
+		if err != nil {
+			return nil, errors.New("fx.queryRates: Could not retrieve rate. " + err.Error())
+		}
+		rates = append(rates, rate)
+	}
+
+	return
+}
+
+func saveQuote(quote Quote) (quoteID string, err error) {
+	newUuid, err := uuid.NewV4()
+	if err != nil {
+		return "", errors.New("fx.saveQuote: Could not generate quote ID. " + err.Error())
+	}
+	quoteID = newUuid.String()
+
+	insertStatement := "INSERT INTO fx_quotes (" + quoteColumns + ") "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	stmtIns, err := Config.Db.Prepare(insertStatement)
+	if err != nil {
+		return "", errors.New("fx.saveQuote: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	_, err = stmtIns.Exec(quoteID, quote.UserID, quote.Amount, quote.Amount.Currency, quote.ConvertedAmount, quote.ConvertedAmount.Currency,
+		quote.MidRate, quote.Rate, quote.Spread, quote.Status, quote.TransactionID, quote.Expires, quote.Timestamp)
+	if err != nil {
+		return "", errors.New("fx.saveQuote: " + err.Error())
+	}
+
+	return
+}
+
+// getQuoteForUpdate fetches a quote and locks its row until tx ends
+func getQuoteForUpdate(tx *sql.Tx, quoteID string) (quote Quote, err error) {
+	rows, err := tx.Query("SELECT "+quoteColumns+" FROM `fx_quotes` WHERE `quoteID` = ? FOR UPDATE", quoteID)
+	if err != nil {
+		return Quote{}, errors.New("fx.getQuoteForUpdate: " + err.Error())
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return Quote{}, errors.New("fx.getQuoteForUpdate: Quote " + quoteID + " not found")
+	}
+	var currency, convertedCurrency string
+	err = rows.Scan(&quote.QuoteID, &quote.UserID, &quote.Amount, &currency, &quote.ConvertedAmount, &convertedCurrency,
+		&quote.MidRate, &quote.Rate, &quote.Spread, &quote.Status, &quote.TransactionID, &quote.Expires, &quote.Timestamp)
+	if err != nil {
+		return Quote{}, errors.New("fx.getQuoteForUpdate: Could not retrieve quote. " + err.Error())
+	}
+	quote.Amount = quote.Amount.In(currency)
+	quote.ConvertedAmount = quote.ConvertedAmount.In(convertedCurrency)
+
+	return
+}
+
+// doExecuteQuote converts the quoted amount from the sender account to the receiver account.
+// The conversion is checked and posted by the transactions package, as payments are, and
+// commits together with the quote
+func doExecuteQuote(quoteID string, userID string, senderAccountNumber string, receiverAccountNumber string, desc string) (conversion Conversion, err error) {
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return Conversion{}, errors.New("fx.doExecuteQuote: Could not start database transaction. " + err.Error())
+	}
+
+	// Lock the quote so it cannot be executed twice concurrently
+	quote, err := getQuoteForUpdate(tx, quoteID)
+	if err != nil {
+		tx.Rollback()
+		return Conversion{}, errors.New("fx.doExecuteQuote: " + err.Error())
+	}
+	err = checkQuote(quote, userID, time.Now())
+	if err != nil {
+		tx.Rollback()
+		return Conversion{}, errors.New("fx.doExecuteQuote: " + err.Error())
+	}
+
+	transaction, _, err := transactions.PostConversion(tx, transactions.Conversion{
+		Sender:          senderAccountNumber,
+		Receiver:        receiverAccountNumber,
+		Amount:          quote.Amount,
+		ConvertedAmount: quote.ConvertedAmount,
+		Rate:            quote.Rate,
+		Desc:            desc,
+	})
+	if err != nil {
+		tx.Rollback()
+		return Conversion{}, errors.New("fx.doExecuteQuote: " + err.Error())
+	}
+
+	stmtUpd, err := tx.Prepare("UPDATE `fx_quotes` SET `status` = ?, `transactionID` = ? WHERE `quoteID` = ?")
+	if err != nil {
+		tx.Rollback()
+		return Conversion{}, errors.New("fx.doExecuteQuote: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	_, err = stmtUpd.Exec(QUOTE_EXECUTED, transaction.ID, quote.QuoteID)
+	if err != nil {
+		tx.Rollback()
+		return Conversion{}, errors.New("fx.doExecuteQuote: " + err.Error())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Conversion{}, errors.New("fx.doExecuteQuote: Could not commit transaction. " + err.Error())
+	}
+
+	conversion = Conversion{
+		QuoteID:               quote.QuoteID,
+		TransactionID:         int64(transaction.ID),
+		SenderAccountNumber:   senderAccountNumber,
+		ReceiverAccountNumber: receiverAccountNumber,
+		Amount:                quote.Amount,
+		ConvertedAmount:       quote.ConvertedAmount,
+		Fee:                   transaction.Fee,
+		Rate:                  quote.Rate,
+		Timestamp:             transaction.Timestamp,
+	}
+	return
+}
@@ -0,0 +1,326 @@
+package fx
+
+/*
+Foreign exchange
+
+Rates are mid rates between two currencies, set by the bank. Customers convert at the mid rate
+less the spread the bank takes, and the bank books what it buys and sells to its FX position
+in each currency.
+
+fx~1~
+   BaseCurrency~
+   QuoteCurrency~
+   MidRate~
+   Spread
+
+Sets the rate of a currency pair: one unit of BaseCurrency is worth MidRate units of
+QuoteCurrency. Spread is the margin in percent taken off the mid rate, DEFAULT_SPREAD when
+empty. A pair is quoted both ways, the other way round at the inverse of its mid rate.
+
+fx~2
+
+Loads rates from the CSV file named by FXRatesFile in the config, with a
+BaseCurrency,QuoteCurrency,MidRate,Spread line per pair. Empty lines and lines starting with #
+are skipped. The file is also loaded when the server starts.
+
+fx~3~
+   FromCurrency~
+   ToCurrency~
+   Amount
+
+Quotes converting Amount of FromCurrency into ToCurrency. The quote holds its rate for the user
+it is issued to until it expires, QUOTE_VALIDITY seconds later.
+
+fx~4~
+   QuoteID~
+   SenderAccountNumber~
+   ReceiverAccountNumber~
+   Desc
+
+Executes a quote. The sender account, held by the user in FromCurrency, pays the amount and
+the receiver account, held at this bank in ToCurrency, is paid the converted amount. The
+receiver is another account of the user or a third party's. It is checked and charged fees as a
+payment is, and the transaction records the rate it was converted at.
+
+fx~1000
+
+Lists the rates.
+
+fx~1 and fx~2 are limited to bank operators.
+*/
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bvnk/bank/accounts"
+	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/configuration"
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+var Config configuration.Configuration
+
+func SetConfig(config *configuration.Configuration) {
+	Config = *config
+}
+
+// Quote statuses. Quotes that are not executed in time stay open but can no longer be executed
+const (
+	QUOTE_OPEN     = "open"
+	QUOTE_EXECUTED = "executed"
+)
+
+// Where a rate was set from
+const (
+	SOURCE_ADMIN = "admin"
+	SOURCE_FILE  = "file"
+)
+
+// Seconds a quote can be executed for once it is issued
+const QUOTE_VALIDITY = 60
+
+// Spread in basis points taken off the mid rate when a rate is set without one
+const DEFAULT_SPREAD = 50
+
+// Largest spread, in percent
+const MAX_SPREAD = 10
+
+// Decimal places rates are kept to
+const RATE_PLACES = 8
+
+// Rate is the mid rate of a currency pair: one unit of BaseCurrency is worth MidRate units of
+// QuoteCurrency. Spread is in percent
+type Rate struct {
+	BaseCurrency  string
+	QuoteCurrency string
+	MidRate       decimal.Decimal
+	Spread        decimal.Decimal
+	Source        string
+	Timestamp     int32
+}
+
+// Quote is a conversion of Amount into ConvertedAmount at Rate, the mid rate less the spread.
+// Only the user it is issued to can execute it, and only until it expires
+type Quote struct {
+	QuoteID         string
+	UserID          string
+	Amount          money.Money
+	ConvertedAmount money.Money
+	MidRate         decimal.Decimal
+	Rate            decimal.Decimal
+	Spread          decimal.Decimal
+	Status          string
+	TransactionID   int64
+	Expires         int32
+	Timestamp       int32
+}
+
+// Conversion is an executed quote
+type Conversion struct {
+	QuoteID               string
+	TransactionID         int64
+	SenderAccountNumber   string
+	ReceiverAccountNumber string
+	Amount                money.Money
+	ConvertedAmount       money.Money
+	Fee                   money.Money
+	Rate                  decimal.Decimal
+	Timestamp             int32
+}
+
+func ProcessFX(data []string) (result interface{}, err error) {
+	if len(data) < 3 {
+		return "", errors.New("fx.ProcessFX: Not all data is present")
+	}
+
+	// Remove null termination from the last field
+	data[len(data)-1] = strings.TrimRight(data[len(data)-1], "\x00")
+
+	fxType, err := strconv.ParseInt(data[2], 10, 64)
+	if err != nil {
+		return "", errors.New("fx.ProcessFX: Could not get type of FX request. " + err.Error())
+	}
+
+	switch fxType {
+	case 1:
+		result, err = setRate(data)
+		if err != nil {
+			return "", errors.New("fx.ProcessFX: " + err.Error())
+		}
+		break
+	case 2:
+		result, err = loadRates(data)
+		if err != nil {
+			return "", errors.New("fx.ProcessFX: " + err.Error())
+		}
+		break
+	case 3:
+		result, err = quote(data)
+		if err != nil {
+			return "", errors.New("fx.ProcessFX: " + err.Error())
+		}
+		break
+	case 4:
+		result, err = executeQuote(data)
+		if err != nil {
+			return "", errors.New("fx.ProcessFX: " + err.Error())
+		}
+		break
+	case 1000:
+		result, err = getRates()
+		if err != nil {
+			return "", errors.New("fx.ProcessFX: " + err.Error())
+		}
+		break
+	default:
+		return "", errors.New("fx.ProcessFX: FX request type invalid")
+	}
+
+	return
+}
+
+func setRate(data []string) (result interface{}, err error) {
+	if len(data) < 6 {
+		return "", errors.New("fx.setRate: Not all fields present")
+	}
+
+	spread := ""
+	if len(data) > 6 {
+		spread = data[6]
+	}
+	rate, err := parseRate(data[3], data[4], data[5], spread)
+	if err != nil {
+		return "", errors.New("fx.setRate: " + err.Error())
+	}
+
+	_, err = appauth.CheckOperator(data[0])
+	if err != nil {
+		return "", errors.New("fx.setRate: " + err.Error())
+	}
+
+	rate.Source = SOURCE_ADMIN
+	rate.Timestamp = int32(time.Now().Unix())
+
+	err = doSaveRates([]Rate{rate})
+	if err != nil {
+		return "", errors.New("fx.setRate: " + err.Error())
+	}
+
+	return rate, nil
+}
+
+func loadRates(data []string) (result interface{}, err error) {
+	if Config.FXRatesFile == "" {
+		return "", errors.New("fx.loadRates: No rates file configured")
+	}
+
+	_, err = appauth.CheckOperator(data[0])
+	if err != nil {
+		return "", errors.New("fx.loadRates: " + err.Error())
+	}
+
+	rates, err := LoadRates()
+	if err != nil {
+		return "", errors.New("fx.loadRates: " + err.Error())
+	}
+
+	return rates, nil
+}
+
+func quote(data []string) (result interface{}, err error) {
+	if len(data) < 6 {
+		return "", errors.New("fx.quote: Not all fields present")
+	}
+
+	from, err := money.ParseCurrency(data[3])
+	if err != nil || data[3] == "" {
+		return "", errors.New("fx.quote: From currency not valid")
+	}
+	to, err := money.ParseCurrency(data[4])
+	if err != nil || data[4] == "" {
+		return "", errors.New("fx.quote: To currency not valid")
+	}
+	if from == to {
+		return "", errors.New("fx.quote: Currencies must differ")
+	}
+	amount, err := money.NewFromString(strings.TrimSpace(data[5]), from)
+	if err != nil {
+		return "", errors.New("fx.quote: Amount not valid. " + err.Error())
+	}
+	if amount.Sign() <= 0 {
+		return "", errors.New("fx.quote: Amount must be positive")
+	}
+
+	userID, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("fx.quote: " + err.Error())
+	}
+
+	rates, err := getPairRates(from, to)
+	if err != nil {
+		return "", errors.New("fx.quote: " + err.Error())
+	}
+
+	fxQuote, err := newQuote(amount, to, rates, time.Now())
+	if err != nil {
+		return "", errors.New("fx.quote: " + err.Error())
+	}
+	fxQuote.UserID = userID
+
+	fxQuote.QuoteID, err = saveQuote(fxQuote)
+	if err != nil {
+		return "", errors.New("fx.quote: " + err.Error())
+	}
+
+	return fxQuote, nil
+}
+
+func executeQuote(data []string) (result interface{}, err error) {
+	if len(data) < 6 {
+		return "", errors.New("fx.executeQuote: Not all fields present")
+	}
+
+	quoteID := data[3]
+	sender := data[4]
+	receiver := data[5]
+	if quoteID == "" || sender == "" || receiver == "" {
+		return "", errors.New("fx.executeQuote: Quote and accounts required")
+	}
+	if sender == receiver {
+		return "", errors.New("fx.executeQuote: Sender and receiver must differ")
+	}
+	desc := ""
+	if len(data) > 6 {
+		desc = data[6]
+	}
+
+	// Only the account holder can convert their funds
+	userID, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("fx.executeQuote: " + err.Error())
+	}
+	err = accounts.CheckUserAccountValidFromToken(userID, sender)
+	if err != nil {
+		return "", errors.New("fx.executeQuote: Sender not valid")
+	}
+
+	conversion, err := doExecuteQuote(quoteID, userID, sender, receiver, desc)
+	if err != nil {
+		return "", errors.New("fx.executeQuote: " + err.Error())
+	}
+
+	return conversion, nil
+}
+
+func getRates() (result interface{}, err error) {
+	rates, err := getAllRates()
+	if err != nil {
+		return "", errors.New("fx.getRates: " + err.Error())
+	}
+
+	return rates, nil
+}
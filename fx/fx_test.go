@@ -0,0 +1,184 @@
+package fx
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+func TestProcessFX(t *testing.T) {
+	tst := []string{"", ""}
+	_, err := ProcessFX(tst)
+	if err == nil {
+		t.Errorf("ProcessFX does not pass. Looking for %v, got %v", "Not all data is present", nil)
+	}
+
+	// Each request fails validation before the token is checked
+	invalid := map[string]struct {
+		data     []string
+		expected string
+	}{
+		"request type":    {[]string{"", "", "0"}, "FX request type invalid"},
+		"rate fields":     {[]string{"", "", "1", "EUR", "USD"}, "Not all fields present"},
+		"rate currency":   {[]string{"", "", "1", "EUR", "XYZ", "1.08"}, "Currency XYZ not valid"},
+		"rate":            {[]string{"", "", "1", "EUR", "USD", "-1.08"}, "Mid rate must be positive"},
+		"spread":          {[]string{"", "", "1", "EUR", "USD", "1.08", "10"}, "Spread must be at least 0 and under 10 percent"},
+		"rates file":      {[]string{"", "", "2"}, "No rates file configured"},
+		"quote fields":    {[]string{"", "", "3", "EUR", "USD"}, "Not all fields present"},
+		"quote currency":  {[]string{"", "", "3", "EUR", "", "100"}, "To currency not valid"},
+		"same currency":   {[]string{"", "", "3", "EUR", "eur", "100"}, "Currencies must differ"},
+		"amount":          {[]string{"", "", "3", "EUR", "USD", "-100"}, "Amount must be positive"},
+		"minor units":     {[]string{"", "", "3", "JPY", "USD", "100.5"}, "Amount has more decimal places than JPY allows"},
+		"execute fields":  {[]string{"", "", "4", "quoteID", "sender"}, "Not all fields present"},
+		"same account":    {[]string{"", "", "4", "quoteID", "account", "account", "desc"}, "Sender and receiver must differ"},
+		"missing account": {[]string{"", "", "4", "quoteID", "", "receiver", "desc"}, "Quote and accounts required"},
+	}
+	for name, tst := range invalid {
+		_, err := ProcessFX(tst.data)
+		if err == nil || !strings.Contains(err.Error(), tst.expected) {
+			t.Errorf("ProcessFX %v does not pass. Looking for %v, got %v", name, tst.expected, err)
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	rate, err := parseRate("eur", "USD", "1.0842", "")
+	if err != nil {
+		t.Fatalf("ParseRate does not pass. Looking for %v, got %v", nil, err)
+	}
+	if rate.BaseCurrency != "EUR" || rate.QuoteCurrency != "USD" || rate.MidRate.String() != "1.0842" {
+		t.Errorf("ParseRate does not pass. Looking for %v, got %v", "EUR USD 1.0842", rate.BaseCurrency+" "+rate.QuoteCurrency+" "+rate.MidRate.String())
+	}
+	if rate.Spread.String() != "0.5" {
+		t.Errorf("ParseRate default spread does not pass. Looking for %v, got %v", "0.5", rate.Spread.String())
+	}
+
+	rate, err = parseRate("USD", "JPY", "149.123456789", "1.25")
+	if err != nil || rate.MidRate.String() != "149.12345679" || rate.Spread.String() != "1.25" {
+		t.Errorf("ParseRate rounding does not pass. Looking for %v, got %v", "149.12345679 1.25", rate.MidRate.String()+" "+rate.Spread.String())
+	}
+
+	_, err = parseRate("USD", "USD", "1", "")
+	if err == nil {
+		t.Errorf("ParseRate same currency does not pass. Looking for %v, got %v", "Currencies must differ", nil)
+	}
+	_, err = parseRate("USD", "JPY", "0.000000001", "")
+	if err == nil {
+		t.Errorf("ParseRate small rate does not pass. Looking for %v, got %v", "Mid rate too small", nil)
+	}
+}
+
+func TestParseRates(t *testing.T) {
+	file := "# base,quote,mid,spread\nEUR,USD,1.0842,0.25\n\nUSD, JPY, 149.5\n"
+	rates, err := parseRates(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("ParseRates does not pass. Looking for %v, got %v", nil, err)
+	}
+	if len(rates) != 2 {
+		t.Fatalf("ParseRates does not pass. Looking for %v rates, got %v", 2, len(rates))
+	}
+	if rates[0].Spread.String() != "0.25" || rates[1].QuoteCurrency != "JPY" || rates[1].Spread.String() != "0.5" {
+		t.Errorf("ParseRates does not pass. Looking for %v, got %v", "0.25 JPY 0.5", rates)
+	}
+
+	invalid := map[string]string{
+		"empty":     "# no rates\n",
+		"fields":    "EUR,USD\n",
+		"rate":      "EUR,USD,one\n",
+		"duplicate": "EUR,USD,1.08\neur,usd,1.09\n",
+	}
+	for name, file := range invalid {
+		_, err := parseRates(strings.NewReader(file))
+		if err == nil {
+			t.Errorf("ParseRates %v does not pass. Looking for %v, got %v", name, "error", nil)
+		}
+	}
+
+	_, err = parseRates(strings.NewReader("EUR,USD,1.08\nGBP,USD\n"))
+	if err == nil || !strings.Contains(err.Error(), "Rate 2 ") || strings.Contains(err.Error(), "GBP,USD") {
+		t.Errorf("ParseRates error does not pass. Looking for %v, got %v", "Rate 2 without the line", err)
+	}
+}
+
+func TestPairRate(t *testing.T) {
+	rates := []Rate{
+		{BaseCurrency: "EUR", QuoteCurrency: "USD", MidRate: decimal.RequireFromString("1.25"), Spread: decimal.RequireFromString("0.5")},
+	}
+
+	midRate, spread, err := pairRate(rates, "EUR", "USD")
+	if err != nil || midRate.String() != "1.25" || spread.String() != "0.5" {
+		t.Errorf("PairRate does not pass. Looking for %v, got %v", "1.25 0.5", midRate.String()+" "+spread.String())
+	}
+
+	// The opposite pair is quoted at the inverse rate
+	midRate, _, err = pairRate(rates, "USD", "EUR")
+	if err != nil || midRate.String() != "0.8" {
+		t.Errorf("PairRate inverse does not pass. Looking for %v, got %v", "0.8", midRate.String())
+	}
+
+	_, _, err = pairRate(rates, "USD", "JPY")
+	if err == nil {
+		t.Errorf("PairRate missing does not pass. Looking for %v, got %v", "No rate from USD to JPY", nil)
+	}
+}
+
+func TestNewQuote(t *testing.T) {
+	now := time.Date(2017, 3, 15, 12, 0, 0, 0, time.UTC)
+	rates := []Rate{
+		{BaseCurrency: "USD", QuoteCurrency: "JPY", MidRate: decimal.RequireFromString("150"), Spread: decimal.RequireFromString("1")},
+		{BaseCurrency: "EUR", QuoteCurrency: "USD", MidRate: decimal.RequireFromString("1.25"), Spread: decimal.RequireFromString("0.5")},
+		{BaseCurrency: "KRW", QuoteCurrency: "USD", MidRate: decimal.RequireFromString("0.00075"), Spread: decimal.RequireFromString("0.5")},
+	}
+
+	// 100.01 USD at 150 less 1% is 14851.485, rounded to whole yen
+	quote, err := newQuote(money.RequireFromString("100.01", "USD"), "JPY", rates, now)
+	if err != nil {
+		t.Fatalf("NewQuote does not pass. Looking for %v, got %v", nil, err)
+	}
+	if quote.Rate.String() != "148.5" || quote.ConvertedAmount.StringFixed() != "14851" || quote.ConvertedAmount.Currency != "JPY" {
+		t.Errorf("NewQuote does not pass. Looking for %v, got %v", "148.5 14851 JPY", quote.Rate.String()+" "+quote.ConvertedAmount.StringFixed()+" "+quote.ConvertedAmount.Currency)
+	}
+	if quote.Status != QUOTE_OPEN || quote.Expires != int32(now.Unix())+QUOTE_VALIDITY {
+		t.Errorf("NewQuote expiry does not pass. Looking for %v, got %v", int32(now.Unix())+QUOTE_VALIDITY, quote.Expires)
+	}
+
+	// 100 USD into EUR at 0.8 less 0.5%
+	quote, err = newQuote(money.RequireFromString("100", "USD"), "EUR", rates, now)
+	if err != nil || quote.MidRate.String() != "0.8" || quote.ConvertedAmount.StringFixed() != "79.60" {
+		t.Errorf("NewQuote inverse does not pass. Looking for %v, got %v", "0.8 79.60", quote.MidRate.String()+" "+quote.ConvertedAmount.StringFixed())
+	}
+
+	_, err = newQuote(money.RequireFromString("1", "KRW"), "USD", rates, now)
+	if err == nil {
+		t.Errorf("NewQuote small amount does not pass. Looking for %v, got %v", "Amount too small to convert", nil)
+	}
+}
+
+func TestCheckQuote(t *testing.T) {
+	now := time.Date(2017, 3, 15, 12, 0, 0, 0, time.UTC)
+	quote := Quote{UserID: "user", Status: QUOTE_OPEN, Expires: int32(now.Unix()) + QUOTE_VALIDITY}
+
+	err := checkQuote(quote, "user", now)
+	if err != nil {
+		t.Errorf("CheckQuote does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	err = checkQuote(quote, "otherUser", now)
+	if err == nil {
+		t.Errorf("CheckQuote user does not pass. Looking for %v, got %v", "Quote not valid", nil)
+	}
+
+	err = checkQuote(quote, "user", now.Add(QUOTE_VALIDITY*time.Second))
+	if err == nil {
+		t.Errorf("CheckQuote expiry does not pass. Looking for %v, got %v", "Quote expired", nil)
+	}
+
+	quote.Status = QUOTE_EXECUTED
+	err = checkQuote(quote, "user", now)
+	if err == nil {
+		t.Errorf("CheckQuote status does not pass. Looking for %v, got %v", "Quote is not open", nil)
+	}
+}
@@ -0,0 +1,192 @@
+package fx
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bvnk/bank/configuration"
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+// parseRate validates a currency pair and its mid rate. An empty spread is the default spread
+func parseRate(baseCurrency string, quoteCurrency string, midRate string, spread string) (rate Rate, err error) {
+	if strings.TrimSpace(baseCurrency) == "" || strings.TrimSpace(quoteCurrency) == "" {
+		return Rate{}, errors.New("fx.parseRate: Currencies required")
+	}
+	rate.BaseCurrency, err = money.ParseCurrency(baseCurrency)
+	if err != nil {
+		return Rate{}, errors.New("fx.parseRate: " + err.Error())
+	}
+	rate.QuoteCurrency, err = money.ParseCurrency(quoteCurrency)
+	if err != nil {
+		return Rate{}, errors.New("fx.parseRate: " + err.Error())
+	}
+	if rate.BaseCurrency == rate.QuoteCurrency {
+		return Rate{}, errors.New("fx.parseRate: Currencies must differ")
+	}
+
+	rate.MidRate, err = decimal.NewFromString(strings.TrimSpace(midRate))
+	if err != nil {
+		return Rate{}, errors.New("fx.parseRate: Mid rate not valid. " + err.Error())
+	}
+	if rate.MidRate.Sign() <= 0 {
+		return Rate{}, errors.New("fx.parseRate: Mid rate must be positive")
+	}
+	rate.MidRate = rate.MidRate.Round(RATE_PLACES)
+	if rate.MidRate.Sign() == 0 {
+		return Rate{}, errors.New("fx.parseRate: Mid rate too small")
+	}
+
+	rate.Spread = decimal.New(DEFAULT_SPREAD, -2)
+	if strings.TrimSpace(spread) != "" {
+		rate.Spread, err = decimal.NewFromString(strings.TrimSpace(spread))
+		if err != nil {
+			return Rate{}, errors.New("fx.parseRate: Spread not valid. " + err.Error())
+		}
+	}
+	if rate.Spread.Sign() < 0 || rate.Spread.GreaterThanOrEqual(decimal.New(MAX_SPREAD, 0)) {
+		return Rate{}, errors.New("fx.parseRate: Spread must be at least 0 and under 10 percent")
+	}
+
+	return
+}
+
+// parseRates reads CSV lines of BaseCurrency,QuoteCurrency,MidRate,Spread. Spread is optional
+func parseRates(reader io.Reader) (rates []Rate, err error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.Comment = '#'
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, errors.New("fx.parseRates: " + err.Error())
+	}
+
+	pairs := map[string]bool{}
+	for i, record := range records {
+		if len(record) < 3 || len(record) > 4 {
+			return nil, errors.New("fx.parseRates: Rate " + strconv.Itoa(i+1) + " must be BaseCurrency,QuoteCurrency,MidRate,Spread")
+		}
+		spread := ""
+		if len(record) == 4 {
+			spread = record[3]
+		}
+		rate, err := parseRate(record[0], record[1], record[2], spread)
+		if err != nil {
+			return nil, errors.New("fx.parseRates: Rate " + strconv.Itoa(i+1) + " not valid. " + err.Error())
+		}
+		if pairs[rate.BaseCurrency+rate.QuoteCurrency] {
+			return nil, errors.New("fx.parseRates: Pair " + rate.BaseCurrency + "/" + rate.QuoteCurrency + " given more than once")
+		}
+		pairs[rate.BaseCurrency+rate.QuoteCurrency] = true
+		rates = append(rates, rate)
+	}
+	if len(rates) == 0 {
+		return nil, errors.New("fx.parseRates: No rates found")
+	}
+
+	return
+}
+
+// LoadRates sets the rates in the CSV file named by Config.FXRatesFile. Paths that are not
+// absolute are relative to the bank's directory
+func LoadRates() (rates []Rate, err error) {
+	path := Config.FXRatesFile
+	if !filepath.IsAbs(path) {
+		path = configuration.ImportPath + path
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.New("fx.LoadRates: " + err.Error())
+	}
+	defer file.Close()
+
+	rates, err = parseRates(file)
+	if err != nil {
+		return nil, errors.New("fx.LoadRates: " + err.Error())
+	}
+
+	sqlTime := int32(time.Now().Unix())
+	for i := range rates {
+		rates[i].Source = SOURCE_FILE
+		rates[i].Timestamp = sqlTime
+	}
+
+	err = doSaveRates(rates)
+	if err != nil {
+		return nil, errors.New("fx.LoadRates: " + err.Error())
+	}
+
+	return
+}
+
+// pairRate finds the mid rate and spread converting from one currency to another, from the
+// pair itself or the inverse of the opposite pair
+func pairRate(rates []Rate, from string, to string) (midRate decimal.Decimal, spread decimal.Decimal, err error) {
+	for _, rate := range rates {
+		if rate.BaseCurrency == from && rate.QuoteCurrency == to {
+			return rate.MidRate, rate.Spread, nil
+		}
+	}
+	for _, rate := range rates {
+		if rate.BaseCurrency == to && rate.QuoteCurrency == from {
+			return decimal.New(1, 0).Div(rate.MidRate).Round(RATE_PLACES), rate.Spread, nil
+		}
+	}
+	return decimal.Zero, decimal.Zero, errors.New("fx.pairRate: No rate from " + from + " to " + to)
+}
+
+// customerRate is the mid rate less the spread, what the customer gets for one unit
+func customerRate(midRate decimal.Decimal, spread decimal.Decimal) decimal.Decimal {
+	margin := decimal.New(1, 0).Sub(spread.Div(decimal.New(100, 0)))
+	return midRate.Mul(margin).Round(RATE_PLACES)
+}
+
+// newQuote quotes converting amount into a currency at now. The converted amount is rounded to
+// the minor unit of its currency
+func newQuote(amount money.Money, to string, rates []Rate, now time.Time) (quote Quote, err error) {
+	midRate, spread, err := pairRate(rates, amount.Currency, to)
+	if err != nil {
+		return Quote{}, errors.New("fx.newQuote: " + err.Error())
+	}
+
+	rate := customerRate(midRate, spread)
+	converted := money.New(amount.Amount.Mul(rate), to)
+	if converted.Sign() <= 0 {
+		return Quote{}, errors.New("fx.newQuote: Amount too small to convert")
+	}
+
+	quote = Quote{
+		Amount:          amount,
+		ConvertedAmount: converted,
+		MidRate:         midRate,
+		Rate:            rate,
+		Spread:          spread,
+		Status:          QUOTE_OPEN,
+		Expires:         int32(now.Add(QUOTE_VALIDITY * time.Second).Unix()),
+		Timestamp:       int32(now.Unix()),
+	}
+	return
+}
+
+// checkQuote checks the user can execute the quote at now
+func checkQuote(quote Quote, userID string, now time.Time) (err error) {
+	if quote.UserID != userID {
+		return errors.New("fx.checkQuote: Quote not valid")
+	}
+	if quote.Status != QUOTE_OPEN {
+		return errors.New("fx.checkQuote: Quote is not open, status is " + quote.Status)
+	}
+	if int32(now.Unix()) >= quote.Expires {
+		return errors.New("fx.checkQuote: Quote expired")
+	}
+	return
+}
@@ -8,9 +8,19 @@ import (
 
 	"github.com/bvnk/bank/accounts"
 	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/cards"
 	"github.com/bvnk/bank/configuration"
+	"github.com/bvnk/bank/fees"
+	"github.com/bvnk/bank/fx"
+	"github.com/bvnk/bank/interest"
+	"github.com/bvnk/bank/kyc"
+	"github.com/bvnk/bank/ledger"
+	"github.com/bvnk/bank/loans"
+	"github.com/bvnk/bank/products"
 	"github.com/bvnk/bank/push"
+	"github.com/bvnk/bank/statements"
 	"github.com/bvnk/bank/transactions"
+	"github.com/bvnk/bank/vault"
 )
 
 func RunHttpServer() (err error) {
@@ -27,6 +37,19 @@ func RunHttpServer() (err error) {
 	transactions.SetConfig(&Config)
 	appauth.SetConfig(&Config)
 	push.SetConfig(&Config)
+	ledger.SetConfig(&Config)
+	statements.SetConfig(&Config)
+	kyc.SetConfig(&Config)
+	interest.SetConfig(&Config)
+	loans.SetConfig(&Config)
+	products.SetConfig(&Config)
+	fees.SetConfig(&Config)
+	cards.SetConfig(&Config)
+	vault.SetConfig(&Config)
+	fx.SetConfig(&Config)
+
+	// Closing a term account before it matures costs the early withdrawal penalty
+	accounts.SetEarlyWithdrawalPenalty(interest.EarlyWithdrawalPenalty, interest.ChargeEarlyWithdrawalPenalty)
 
 	router := NewRouter()
 
@@ -69,3 +92,16 @@ func Response(responseSuccess interface{}, responseError error, w http.ResponseW
 	w.Write(jsonResponse)
 	bLog(0, "Response success: "+string(jsonResponse), trace())
 }
+
+// XMLResponse writes an ISO 20022 XML document. Errors are reported as JSON, like Response
+func XMLResponse(responseSuccess string, responseError error, w http.ResponseWriter, r *http.Request) {
+	if responseError != nil {
+		Response("", responseError, w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(responseSuccess))
+	bLog(0, "Response success: XML document", trace())
+}
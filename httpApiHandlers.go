@@ -1,11 +1,19 @@
 package main
 
 import (
+	"encoding/base64"
 	"errors"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/bvnk/bank/accounts"
 	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/cards"
+	"github.com/bvnk/bank/fx"
+	"github.com/bvnk/bank/interest"
+	"github.com/bvnk/bank/loans"
+	"github.com/bvnk/bank/products"
+	"github.com/bvnk/bank/statements"
 	"github.com/bvnk/bank/transactions"
 	"github.com/gorilla/mux"
 )
@@ -105,6 +113,7 @@ func AccountCreate(w http.ResponseWriter, r *http.Request) {
 	accountHolderAddressLine3 := r.FormValue("AccountHolderAddressLine3")
 	accountHolderPostalCode := r.FormValue("AccountHolderPostalCode")
 	accountType := r.FormValue("AccountType")
+	currency := r.FormValue("Currency")
 
 	req := []string{
 		"0",
@@ -122,6 +131,7 @@ func AccountCreate(w http.ResponseWriter, r *http.Request) {
 		accountHolderAddressLine3,
 		accountHolderPostalCode,
 		accountType,
+		currency,
 	}
 
 	response, err := accounts.ProcessAccount(req)
@@ -168,6 +178,66 @@ func AccountGetAll(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// Account report as JSON, optionally for a single account type
+func AccountReport(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	accountType := r.FormValue("AccountType")
+
+	response, err := accounts.ProcessAccount([]string{token, "acmt", "13", accountType, "json"})
+	Response(response, err, w, r)
+	return
+}
+
+// Account report as an acmt.014 XML document
+func AccountReportAcmt014(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	accountType := r.FormValue("AccountType")
+
+	response, err := accounts.ProcessAccount([]string{token, "acmt", "13", accountType, "xml"})
+	if err != nil {
+		XMLResponse("", err, w, r)
+		return
+	}
+	XMLResponse(response.(string), nil, w, r)
+	return
+}
+
+// Identification verification request (acmt.023)
+func AccountVerificationRequest(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	response, err := accounts.ProcessAccount([]string{token, "acmt", "23"})
+	Response(response, err, w, r)
+	return
+}
+
+// Identification verification report (acmt.024)
+func AccountVerificationReport(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	response, err := accounts.ProcessAccount([]string{token, "acmt", "24"})
+	Response(response, err, w, r)
+	return
+}
+
 func AccountTokenPost(w http.ResponseWriter, r *http.Request) {
 	token, err := getTokenFromHeader(w, r)
 	if err != nil {
@@ -212,6 +282,54 @@ func AccountSearch(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// Change the token user's contact details. Password is needed for sensitive fields
+func AccountModify(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	req := []string{
+		token,
+		"acmt",
+		"3",
+		r.FormValue("AccountHolderContactNumber1"),
+		r.FormValue("AccountHolderContactNumber2"),
+		r.FormValue("AccountHolderEmailAddress"),
+		r.FormValue("AccountHolderAddressLine1"),
+		r.FormValue("AccountHolderAddressLine2"),
+		r.FormValue("AccountHolderAddressLine3"),
+		r.FormValue("AccountHolderPostalCode"),
+		r.FormValue("Password"),
+	}
+
+	response, err := accounts.ProcessAccount(req)
+	Response(response, err, w, r)
+	return
+}
+
+// Close the account in the headers, sweeping what is left to SweepAccountNumber
+func AccountClose(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+	// Get account number from header
+	accountNumber := r.Header.Get("X-Auth-AccountNumber")
+	if accountNumber == "" {
+		Response("", errors.New("httpApiHandlers.AccountClose: Could not retrieve accountNumber from headers"), w, r)
+		return
+	}
+
+	sweepAccountNumber := r.FormValue("SweepAccountNumber")
+
+	response, err := accounts.ProcessAccount([]string{token, "acmt", "19", accountNumber, sweepAccountNumber})
+	Response(response, err, w, r)
+	return
+}
+
 func TransactionCreditInitiation(w http.ResponseWriter, r *http.Request) {
 	token, err := getTokenFromHeader(w, r)
 	if err != nil {
@@ -249,6 +367,80 @@ func TransactionDepositInitiation(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+func TransactionReversal(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	transactionID := r.FormValue("TransactionID")
+	reasonCode := r.FormValue("ReasonCode")
+	lat := r.FormValue("Lat")
+	lon := r.FormValue("Lon")
+	desc := r.FormValue("Desc")
+
+	response, err := transactions.ProcessPAIN([]string{token, "pain", "7", transactionID, reasonCode, lat, lon, desc})
+	Response(response, err, w, r)
+	return
+}
+
+// The request body is a pain.001 XML document, the response a pain.002 XML document
+func TransactionCreditInitiationXML(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	document, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		Response("", errors.New("httpApiHandlers.TransactionCreditInitiationXML: Could not read document. "+err.Error()), w, r)
+		return
+	}
+
+	response, err := transactions.ProcessPAIN([]string{token, "pain", "1003", base64.StdEncoding.EncodeToString(document)})
+	if err != nil {
+		XMLResponse("", err, w, r)
+		return
+	}
+	XMLResponse(response.(string), nil, w, r)
+	return
+}
+
+func TransactionStatus(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	vars := mux.Vars(r)
+	transactionID := vars["transactionID"]
+
+	response, err := transactions.ProcessPAIN([]string{token, "pain", "2", transactionID})
+	Response(response, err, w, r)
+	return
+}
+
+func TransactionDirectDebitInitiation(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	mandateID := r.FormValue("MandateID")
+	amount := r.FormValue("Amount")
+	lat := r.FormValue("Lat")
+	lon := r.FormValue("Lon")
+	desc := r.FormValue("Desc")
+
+	response, err := transactions.ProcessPAIN([]string{token, "pain", "8", mandateID, amount, lat, lon, desc})
+	Response(response, err, w, r)
+	return
+}
+
 func TransactionList(w http.ResponseWriter, r *http.Request) {
 	token, err := getTokenFromHeader(w, r)
 	if err != nil {
@@ -297,6 +489,7 @@ func MerchantAccountCreate(w http.ResponseWriter, r *http.Request) {
 	merchantContactEmail := r.FormValue("MerchantContactEmail")
 	merchantLogo := r.FormValue("MerchantLogo")
 	merchantAccountType := r.FormValue("AccountType")
+	merchantCurrency := r.FormValue("Currency")
 
 	req := []string{
 		token,
@@ -318,6 +511,7 @@ func MerchantAccountCreate(w http.ResponseWriter, r *http.Request) {
 		merchantContactEmail,
 		merchantLogo,
 		merchantAccountType,
+		merchantCurrency,
 	}
 
 	response, err := accounts.ProcessAccount(req)
@@ -425,3 +619,577 @@ func MerchantAccountSearch(w http.ResponseWriter, r *http.Request) {
 	Response(response, err, w, r)
 	return
 }
+
+func MandateInitiation(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	creditorDetails := r.FormValue("CreditorDetails")
+	debtorDetails := r.FormValue("DebtorDetails")
+	maxAmount := r.FormValue("MaxAmount")
+	frequency := r.FormValue("Frequency")
+	desc := r.FormValue("Desc")
+
+	response, err := transactions.ProcessPAIN([]string{token, "pain", "9", creditorDetails, debtorDetails, maxAmount, frequency, desc})
+	Response(response, err, w, r)
+	return
+}
+
+func MandateAmendment(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	mandateID := r.FormValue("MandateID")
+	maxAmount := r.FormValue("MaxAmount")
+	frequency := r.FormValue("Frequency")
+
+	response, err := transactions.ProcessPAIN([]string{token, "pain", "10", mandateID, maxAmount, frequency})
+	Response(response, err, w, r)
+	return
+}
+
+func MandateCancellation(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	mandateID := r.FormValue("MandateID")
+
+	response, err := transactions.ProcessPAIN([]string{token, "pain", "11", mandateID})
+	Response(response, err, w, r)
+	return
+}
+
+func MandateAcceptance(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	mandateID := r.FormValue("MandateID")
+	accepted := r.FormValue("Accepted")
+
+	response, err := transactions.ProcessPAIN([]string{token, "pain", "12", mandateID, accepted})
+	Response(response, err, w, r)
+	return
+}
+
+func MandateList(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+	// Get account number from header
+	accountNumber := r.Header.Get("X-Auth-AccountNumber")
+	if accountNumber == "" {
+		Response("", errors.New("httpApiHandlers.MandateList: Could not retrieve accountNumber from headers"), w, r)
+		return
+	}
+
+	response, err := transactions.ProcessPAIN([]string{token, "pain", "1002", accountNumber})
+	Response(response, err, w, r)
+	return
+}
+
+func HoldPlacement(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	accountDetails := r.FormValue("AccountDetails")
+	payeeDetails := r.FormValue("PayeeDetails")
+	amount := r.FormValue("Amount")
+	expiryHours := r.FormValue("ExpiryHours")
+	desc := r.FormValue("Desc")
+
+	response, err := transactions.ProcessPAIN([]string{token, "pain", "1004", accountDetails, payeeDetails, amount, expiryHours, desc})
+	Response(response, err, w, r)
+	return
+}
+
+func HoldCapture(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	holdID := r.FormValue("HoldID")
+	amount := r.FormValue("Amount")
+
+	response, err := transactions.ProcessPAIN([]string{token, "pain", "1005", holdID, amount})
+	Response(response, err, w, r)
+	return
+}
+
+func HoldCancellation(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	holdID := r.FormValue("HoldID")
+
+	response, err := transactions.ProcessPAIN([]string{token, "pain", "1006", holdID})
+	Response(response, err, w, r)
+	return
+}
+
+func HoldList(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+	// Get account number from header
+	accountNumber := r.Header.Get("X-Auth-AccountNumber")
+	if accountNumber == "" {
+		Response("", errors.New("httpApiHandlers.HoldList: Could not retrieve accountNumber from headers"), w, r)
+		return
+	}
+
+	response, err := transactions.ProcessPAIN([]string{token, "pain", "1007", accountNumber})
+	Response(response, err, w, r)
+	return
+}
+
+// Cards
+func CardIssue(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	accountNumber := r.FormValue("AccountNumber")
+	cardType := r.FormValue("Type")
+	perTransactionLimit := r.FormValue("PerTransactionLimit")
+	dailyLimit := r.FormValue("DailyLimit")
+
+	response, err := cards.ProcessCard([]string{token, "card", "1", accountNumber, cardType, perTransactionLimit, dailyLimit})
+	Response(response, err, w, r)
+	return
+}
+
+func CardActivation(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	cardID := r.FormValue("CardID")
+	cvv := r.FormValue("CVV")
+
+	response, err := cards.ProcessCard([]string{token, "card", "2", cardID, cvv})
+	Response(response, err, w, r)
+	return
+}
+
+func CardFreeze(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	cardID := r.FormValue("CardID")
+
+	response, err := cards.ProcessCard([]string{token, "card", "3", cardID})
+	Response(response, err, w, r)
+	return
+}
+
+func CardUnfreeze(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	cardID := r.FormValue("CardID")
+
+	response, err := cards.ProcessCard([]string{token, "card", "4", cardID})
+	Response(response, err, w, r)
+	return
+}
+
+func CardReplacement(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	cardID := r.FormValue("CardID")
+
+	response, err := cards.ProcessCard([]string{token, "card", "5", cardID})
+	Response(response, err, w, r)
+	return
+}
+
+func CardCancellation(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	cardID := r.FormValue("CardID")
+
+	response, err := cards.ProcessCard([]string{token, "card", "6", cardID})
+	Response(response, err, w, r)
+	return
+}
+
+func CardLimits(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	cardID := r.FormValue("CardID")
+	perTransactionLimit := r.FormValue("PerTransactionLimit")
+	dailyLimit := r.FormValue("DailyLimit")
+
+	response, err := cards.ProcessCard([]string{token, "card", "7", cardID, perTransactionLimit, dailyLimit})
+	Response(response, err, w, r)
+	return
+}
+
+func CardList(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+	// Get account number from header
+	accountNumber := r.Header.Get("X-Auth-AccountNumber")
+	if accountNumber == "" {
+		Response("", errors.New("httpApiHandlers.CardList: Could not retrieve accountNumber from headers"), w, r)
+		return
+	}
+
+	response, err := cards.ProcessCard([]string{token, "card", "1000", accountNumber})
+	Response(response, err, w, r)
+	return
+}
+
+// Statements
+// Account statement as JSON
+func StatementGet(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+	// Get account number from header
+	accountNumber := r.Header.Get("X-Auth-AccountNumber")
+	if accountNumber == "" {
+		Response("", errors.New("httpApiHandlers.StatementGet: Could not retrieve accountNumber from headers"), w, r)
+		return
+	}
+
+	vars := mux.Vars(r)
+	fromDate := vars["fromDate"]
+	toDate := vars["toDate"]
+
+	response, err := statements.ProcessCAMT([]string{token, "camt", "53", accountNumber, fromDate, toDate, "json"})
+	Response(response, err, w, r)
+	return
+}
+
+// Account statement as a camt.053 XML document
+func StatementGetCamt053(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+	// Get account number from header
+	accountNumber := r.Header.Get("X-Auth-AccountNumber")
+	if accountNumber == "" {
+		Response("", errors.New("httpApiHandlers.StatementGetCamt053: Could not retrieve accountNumber from headers"), w, r)
+		return
+	}
+
+	vars := mux.Vars(r)
+	fromDate := vars["fromDate"]
+	toDate := vars["toDate"]
+
+	response, err := statements.ProcessCAMT([]string{token, "camt", "53", accountNumber, fromDate, toDate, "xml"})
+	if err != nil {
+		XMLResponse("", err, w, r)
+		return
+	}
+	XMLResponse(response.(string), nil, w, r)
+	return
+}
+
+// Intraday account report as JSON
+func ReportIntraday(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+	// Get account number from header
+	accountNumber := r.Header.Get("X-Auth-AccountNumber")
+	if accountNumber == "" {
+		Response("", errors.New("httpApiHandlers.ReportIntraday: Could not retrieve accountNumber from headers"), w, r)
+		return
+	}
+
+	response, err := statements.ProcessCAMT([]string{token, "camt", "52", accountNumber, "json"})
+	Response(response, err, w, r)
+	return
+}
+
+// Intraday account report as a camt.052 XML document
+func ReportIntradayCamt052(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+	// Get account number from header
+	accountNumber := r.Header.Get("X-Auth-AccountNumber")
+	if accountNumber == "" {
+		Response("", errors.New("httpApiHandlers.ReportIntradayCamt052: Could not retrieve accountNumber from headers"), w, r)
+		return
+	}
+
+	response, err := statements.ProcessCAMT([]string{token, "camt", "52", accountNumber, "xml"})
+	if err != nil {
+		XMLResponse("", err, w, r)
+		return
+	}
+	XMLResponse(response.(string), nil, w, r)
+	return
+}
+
+// Debit or credit notification as JSON
+func NotificationGet(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+	// Get account number from header
+	accountNumber := r.Header.Get("X-Auth-AccountNumber")
+	if accountNumber == "" {
+		Response("", errors.New("httpApiHandlers.NotificationGet: Could not retrieve accountNumber from headers"), w, r)
+		return
+	}
+
+	vars := mux.Vars(r)
+	notificationID := vars["notificationID"]
+
+	response, err := statements.ProcessCAMT([]string{token, "camt", "54", accountNumber, notificationID, "json"})
+	Response(response, err, w, r)
+	return
+}
+
+// Debit or credit notification as a camt.054 XML document
+func NotificationGetCamt054(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+	// Get account number from header
+	accountNumber := r.Header.Get("X-Auth-AccountNumber")
+	if accountNumber == "" {
+		Response("", errors.New("httpApiHandlers.NotificationGetCamt054: Could not retrieve accountNumber from headers"), w, r)
+		return
+	}
+
+	vars := mux.Vars(r)
+	notificationID := vars["notificationID"]
+
+	response, err := statements.ProcessCAMT([]string{token, "camt", "54", accountNumber, notificationID, "xml"})
+	if err != nil {
+		XMLResponse("", err, w, r)
+		return
+	}
+	XMLResponse(response.(string), nil, w, r)
+	return
+}
+
+// List notifications after timestamp
+func NotificationList(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+	// Get account number from header
+	accountNumber := r.Header.Get("X-Auth-AccountNumber")
+	if accountNumber == "" {
+		Response("", errors.New("httpApiHandlers.NotificationList: Could not retrieve accountNumber from headers"), w, r)
+		return
+	}
+
+	vars := mux.Vars(r)
+	timestamp := vars["timestamp"]
+
+	response, err := statements.ProcessCAMT([]string{token, "camt", "1054", accountNumber, timestamp})
+	Response(response, err, w, r)
+	return
+}
+
+func ProductList(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	response, err := products.ProcessProduct([]string{token, "product", "1000"})
+	Response(response, err, w, r)
+	return
+}
+
+func ProductGet(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	vars := mux.Vars(r)
+	code := vars["code"]
+
+	response, err := products.ProcessProduct([]string{token, "product", "1001", code})
+	Response(response, err, w, r)
+	return
+}
+
+func InterestProducts(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	response, err := interest.ProcessInterest([]string{token, "interest", "1000"})
+	Response(response, err, w, r)
+	return
+}
+
+func LoanGet(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	vars := mux.Vars(r)
+	accountNumber := vars["accountNumber"]
+
+	response, err := loans.ProcessLoan([]string{token, "loan", "2", accountNumber})
+	Response(response, err, w, r)
+	return
+}
+
+// Date is optional and defaults to today
+func LoanPayoffQuote(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	vars := mux.Vars(r)
+	accountNumber := vars["accountNumber"]
+	date := r.FormValue("Date")
+
+	response, err := loans.ProcessLoan([]string{token, "loan", "4", accountNumber, date})
+	Response(response, err, w, r)
+	return
+}
+
+func FXRates(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	response, err := fx.ProcessFX([]string{token, "fx", "1000"})
+	Response(response, err, w, r)
+	return
+}
+
+func FXQuote(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	fromCurrency := r.FormValue("FromCurrency")
+	toCurrency := r.FormValue("ToCurrency")
+	amount := r.FormValue("Amount")
+
+	response, err := fx.ProcessFX([]string{token, "fx", "3", fromCurrency, toCurrency, amount})
+	Response(response, err, w, r)
+	return
+}
+
+// The receiver is another account of the user or a third party's at this bank
+func FXConversion(w http.ResponseWriter, r *http.Request) {
+	token, err := getTokenFromHeader(w, r)
+	if err != nil {
+		Response("", err, w, r)
+		return
+	}
+
+	quoteID := r.FormValue("QuoteID")
+	senderAccountNumber := r.FormValue("SenderAccountNumber")
+	receiverAccountNumber := r.FormValue("ReceiverAccountNumber")
+	desc := r.FormValue("Desc")
+
+	response, err := fx.ProcessFX([]string{token, "fx", "4", quoteID, senderAccountNumber, receiverAccountNumber, desc})
+	Response(response, err, w, r)
+	return
+}
+
+// The request body is a pacs.008 XML document from another bank, the response a pacs.002 XML
+// document. Banks authenticate with the secret shared in their peer configuration
+func InterbankCreditTransfer(w http.ResponseWriter, r *http.Request) {
+	bankNumber := r.Header.Get("X-Bank-Number")
+	secret := r.Header.Get("X-Bank-Secret")
+	if bankNumber == "" || secret == "" {
+		Response("", errors.New("httpApiHandlers.InterbankCreditTransfer: Could not retrieve bank details from headers"), w, r)
+		return
+	}
+
+	document, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		Response("", errors.New("httpApiHandlers.InterbankCreditTransfer: Could not read document. "+err.Error()), w, r)
+		return
+	}
+
+	response, err := transactions.ProcessPACS([]string{"", "pacs", "8", bankNumber, secret, base64.StdEncoding.EncodeToString(document)})
+	if err != nil {
+		XMLResponse("", err, w, r)
+		return
+	}
+	XMLResponse(response.(string), nil, w, r)
+	return
+}
@@ -66,6 +66,13 @@ var routes = Routes{
 		"/account",
 		AccountCreate,
 	},
+	// Modify account holder details
+	Route{
+		"AccountModify",
+		"PUT",
+		"/account",
+		AccountModify,
+	},
 	// Get all accounts
 	Route{
 		"AccountGetAll",
@@ -73,6 +80,34 @@ var routes = Routes{
 		"/account/all",
 		AccountGetAll,
 	},
+	// Account report for all of the holder's accounts
+	Route{
+		"AccountReport",
+		"GET",
+		"/account/report",
+		AccountReport,
+	},
+	// Account report as an acmt.014 XML document
+	Route{
+		"AccountReportAcmt014",
+		"GET",
+		"/account/report/acmt014",
+		AccountReportAcmt014,
+	},
+	// Ask for the account holder's identification to be verified
+	Route{
+		"AccountVerificationRequest",
+		"POST",
+		"/account/verification",
+		AccountVerificationRequest,
+	},
+	// Latest identification verification report
+	Route{
+		"AccountVerificationReport",
+		"GET",
+		"/account/verification",
+		AccountVerificationReport,
+	},
 	// Get single account
 	Route{
 		"AccountGet",
@@ -108,6 +143,13 @@ var routes = Routes{
 		"/account/search",
 		AccountSearch,
 	},
+	// Close account
+	Route{
+		"AccountClose",
+		"POST",
+		"/account/close",
+		AccountClose,
+	},
 	// Merchant accounts
 	// Merchant create
 	Route{
@@ -152,6 +194,13 @@ var routes = Routes{
 		"/transaction/credit",
 		TransactionCreditInitiation,
 	},
+	// Credit initiation from a pain.001 XML document
+	Route{
+		"TransactionCreditInitiationXML",
+		"POST",
+		"/transaction/pain001",
+		TransactionCreditInitiationXML,
+	},
 	// Deposit initiation
 	Route{
 		"TransactionDepositInitiation",
@@ -159,6 +208,27 @@ var routes = Routes{
 		"/transaction/deposit",
 		TransactionDepositInitiation,
 	},
+	// Payment reversal
+	Route{
+		"TransactionReversal",
+		"POST",
+		"/transaction/reversal",
+		TransactionReversal,
+	},
+	// Payment status
+	Route{
+		"TransactionStatus",
+		"GET",
+		"/transaction/{transactionID}/status",
+		TransactionStatus,
+	},
+	// Direct debit initiation
+	Route{
+		"TransactionDirectDebitInitiation",
+		"POST",
+		"/transaction/debit",
+		TransactionDirectDebitInitiation,
+	},
 	// List transactions
 	Route{
 		"TransactionList",
@@ -173,6 +243,247 @@ var routes = Routes{
 		"/transaction/list/{perPage}/{page}/{timestamp}",
 		TransactionList,
 	},
+	// Mandates
+	// Mandate initiation
+	Route{
+		"MandateInitiation",
+		"POST",
+		"/mandate/initiation",
+		MandateInitiation,
+	},
+	// Mandate amendment
+	Route{
+		"MandateAmendment",
+		"POST",
+		"/mandate/amendment",
+		MandateAmendment,
+	},
+	// Mandate cancellation
+	Route{
+		"MandateCancellation",
+		"POST",
+		"/mandate/cancellation",
+		MandateCancellation,
+	},
+	// Mandate acceptance
+	Route{
+		"MandateAcceptance",
+		"POST",
+		"/mandate/acceptance",
+		MandateAcceptance,
+	},
+	// List mandates
+	Route{
+		"MandateList",
+		"GET",
+		"/mandate/list",
+		MandateList,
+	},
+	// Holds
+	// Hold placement
+	Route{
+		"HoldPlacement",
+		"POST",
+		"/hold/placement",
+		HoldPlacement,
+	},
+	// Hold capture
+	Route{
+		"HoldCapture",
+		"POST",
+		"/hold/capture",
+		HoldCapture,
+	},
+	// Hold cancellation
+	Route{
+		"HoldCancellation",
+		"POST",
+		"/hold/cancellation",
+		HoldCancellation,
+	},
+	// List active holds
+	Route{
+		"HoldList",
+		"GET",
+		"/hold/list",
+		HoldList,
+	},
+	// Cards
+	// Card issue
+	Route{
+		"CardIssue",
+		"POST",
+		"/card/issue",
+		CardIssue,
+	},
+	// Card activation
+	Route{
+		"CardActivation",
+		"POST",
+		"/card/activation",
+		CardActivation,
+	},
+	// Card freeze
+	Route{
+		"CardFreeze",
+		"POST",
+		"/card/freeze",
+		CardFreeze,
+	},
+	// Card unfreeze
+	Route{
+		"CardUnfreeze",
+		"POST",
+		"/card/unfreeze",
+		CardUnfreeze,
+	},
+	// Card replacement
+	Route{
+		"CardReplacement",
+		"POST",
+		"/card/replacement",
+		CardReplacement,
+	},
+	// Card cancellation
+	Route{
+		"CardCancellation",
+		"POST",
+		"/card/cancellation",
+		CardCancellation,
+	},
+	// Card spending limits
+	Route{
+		"CardLimits",
+		"POST",
+		"/card/limits",
+		CardLimits,
+	},
+	// List cards on an account
+	Route{
+		"CardList",
+		"GET",
+		"/card/list",
+		CardList,
+	},
+	// Statements
+	// Account statement
+	Route{
+		"StatementGet",
+		"GET",
+		"/statement/{fromDate}/{toDate}",
+		StatementGet,
+	},
+	// Account statement as camt.053
+	Route{
+		"StatementGetCamt053",
+		"GET",
+		"/statement/{fromDate}/{toDate}/camt053",
+		StatementGetCamt053,
+	},
+	// Intraday account report
+	Route{
+		"ReportIntraday",
+		"GET",
+		"/report/intraday",
+		ReportIntraday,
+	},
+	// Intraday account report as camt.052
+	Route{
+		"ReportIntradayCamt052",
+		"GET",
+		"/report/intraday/camt052",
+		ReportIntradayCamt052,
+	},
+	// Notifications
+	// List notifications after timestamp
+	Route{
+		"NotificationList",
+		"GET",
+		"/notification/list/{timestamp}",
+		NotificationList,
+	},
+	// Debit or credit notification
+	Route{
+		"NotificationGet",
+		"GET",
+		"/notification/{notificationID}",
+		NotificationGet,
+	},
+	// Debit or credit notification as camt.054
+	Route{
+		"NotificationGetCamt054",
+		"GET",
+		"/notification/{notificationID}/camt054",
+		NotificationGetCamt054,
+	},
+	// Products
+	// Products of the catalogue that can be opened
+	Route{
+		"ProductList",
+		"GET",
+		"/products",
+		ProductList,
+	},
+	// Single product of the catalogue
+	Route{
+		"ProductGet",
+		"GET",
+		"/product/{code}",
+		ProductGet,
+	},
+	// Interest
+	// Interest products and the rates they pay today
+	Route{
+		"InterestProducts",
+		"GET",
+		"/interest/products",
+		InterestProducts,
+	},
+	// Loans
+	// Loan with its amortisation schedule and arrears
+	Route{
+		"LoanGet",
+		"GET",
+		"/loan/{accountNumber}",
+		LoanGet,
+	},
+	// Amount that settles the loan on a date
+	Route{
+		"LoanPayoffQuote",
+		"GET",
+		"/loan/{accountNumber}/payoff",
+		LoanPayoffQuote,
+	},
+	// FX
+	// Mid rates and spreads of the currency pairs
+	Route{
+		"FXRates",
+		"GET",
+		"/fx/rates",
+		FXRates,
+	},
+	// Time-limited quote converting an amount into another currency
+	Route{
+		"FXQuote",
+		"POST",
+		"/fx/quote",
+		FXQuote,
+	},
+	// Conversion of a quote between accounts
+	Route{
+		"FXConversion",
+		"POST",
+		"/fx/conversion",
+		FXConversion,
+	},
+	// Interbank
+	// Credit transfer from another bank as pacs.008
+	Route{
+		"InterbankCreditTransfer",
+		"POST",
+		"/interbank/pacs008",
+		InterbankCreditTransfer,
+	},
 }
 
 func NewRouter() *mux.Router {
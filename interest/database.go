@@ -0,0 +1,544 @@
+package interest
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/bvnk/bank/accounts"
+	"github.com/bvnk/bank/iso20022"
+	"github.com/bvnk/bank/ledger"
+	"github.com/bvnk/bank/money"
+	"github.com/bvnk/bank/statements"
+	"github.com/shopspring/decimal"
+)
+
+// doSaveProduct writes a product and replaces its tiers in one database transaction
+func doSaveProduct(product Product) (err error) {
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return errors.New("interest.doSaveProduct: Could not start database transaction. " + err.Error())
+	}
+
+	insertStatement := "INSERT INTO interest_products (`accountType`, `rateType`, `rate`, `dayCount`, `termMonths`, `penaltyDays`, `timestamp`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?) "
+	insertStatement += "ON DUPLICATE KEY UPDATE `rateType` = VALUES(`rateType`), `rate` = VALUES(`rate`), `dayCount` = VALUES(`dayCount`), "
+	insertStatement += "`termMonths` = VALUES(`termMonths`), `penaltyDays` = VALUES(`penaltyDays`), `timestamp` = VALUES(`timestamp`)"
+	stmtIns, err := tx.Prepare(insertStatement)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("interest.doSaveProduct: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	_, err = stmtIns.Exec(product.AccountType, product.RateType, product.Rate, product.DayCount, product.TermMonths, product.PenaltyDays, product.Timestamp)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("interest.doSaveProduct: " + err.Error())
+	}
+
+	stmtDel, err := tx.Prepare("DELETE FROM interest_product_tiers WHERE `accountType` = ?")
+	if err != nil {
+		tx.Rollback()
+		return errors.New("interest.doSaveProduct: " + err.Error())
+	}
+	defer stmtDel.Close()
+
+	_, err = stmtDel.Exec(product.AccountType)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("interest.doSaveProduct: " + err.Error())
+	}
+
+	stmtTier, err := tx.Prepare("INSERT INTO interest_product_tiers (`accountType`, `minimumBalance`, `rate`) VALUES(?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return errors.New("interest.doSaveProduct: " + err.Error())
+	}
+	defer stmtTier.Close()
+
+	for _, tier := range product.Tiers {
+		_, err = stmtTier.Exec(product.AccountType, tier.MinimumBalance, tier.Rate)
+		if err != nil {
+			tx.Rollback()
+			return errors.New("interest.doSaveProduct: " + err.Error())
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("interest.doSaveProduct: Could not commit transaction. " + err.Error())
+	}
+
+	return
+}
+
+func getProducts() (products []Product, err error) {
+	rows, err := Config.Db.Query("SELECT `accountType`, `rateType`, `rate`, `dayCount`, `termMonths`, `penaltyDays`, `timestamp` FROM `interest_products` ORDER BY `accountType`")
+	if err != nil {
+		return nil, errors.New("interest.getProducts: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product Product
+		if err := rows.Scan(&product.AccountType, &product.RateType, &product.Rate, &product.DayCount, &product.TermMonths, &product.PenaltyDays, &product.Timestamp); err != nil {
+			return nil, errors.New("interest.getProducts: Could not retrieve product. " + err.Error())
+		}
+		products = append(products, product)
+	}
+	rows.Close()
+
+	for i := range products {
+		products[i].Tiers, err = getTiers(products[i].AccountType)
+		if err != nil {
+			return nil, errors.New("interest.getProducts: " + err.Error())
+		}
+	}
+
+	return
+}
+
+// getProduct returns the product of an account type, or an empty product if it has none
+func getProduct(accountType string) (product Product, err error) {
+	rows, err := Config.Db.Query("SELECT `accountType`, `rateType`, `rate`, `dayCount`, `termMonths`, `penaltyDays`, `timestamp` FROM `interest_products` WHERE `accountType` = ?", accountType)
+	if err != nil {
+		return Product{}, errors.New("interest.getProduct: " + err.Error())
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return Product{}, nil
+	}
+	if err := rows.Scan(&product.AccountType, &product.RateType, &product.Rate, &product.DayCount, &product.TermMonths, &product.PenaltyDays, &product.Timestamp); err != nil {
+		return Product{}, errors.New("interest.getProduct: Could not retrieve product. " + err.Error())
+	}
+	rows.Close()
+
+	product.Tiers, err = getTiers(accountType)
+	if err != nil {
+		return Product{}, errors.New("interest.getProduct: " + err.Error())
+	}
+
+	return
+}
+
+func getTiers(accountType string) (tiers []Tier, err error) {
+	rows, err := Config.Db.Query("SELECT `minimumBalance`, `rate` FROM `interest_product_tiers` WHERE `accountType` = ? ORDER BY `minimumBalance`", accountType)
+	if err != nil {
+		return nil, errors.New("interest.getTiers: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tier Tier
+		if err := rows.Scan(&tier.MinimumBalance, &tier.Rate); err != nil {
+			return nil, errors.New("interest.getTiers: Could not retrieve tier. " + err.Error())
+		}
+		tiers = append(tiers, tier)
+	}
+
+	return
+}
+
+func doSaveRateChange(change RateChange) (err error) {
+	insertStatement := "INSERT INTO interest_rate_changes (`accountType`, `rate`, `effectiveDate`, `timestamp`) "
+	insertStatement += "VALUES(?, ?, ?, ?) "
+	insertStatement += "ON DUPLICATE KEY UPDATE `rate` = VALUES(`rate`), `timestamp` = VALUES(`timestamp`)"
+	stmtIns, err := Config.Db.Prepare(insertStatement)
+	if err != nil {
+		return errors.New("interest.doSaveRateChange: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	_, err = stmtIns.Exec(change.AccountType, change.Rate, change.EffectiveDate, change.Timestamp)
+	if err != nil {
+		return errors.New("interest.doSaveRateChange: " + err.Error())
+	}
+
+	return
+}
+
+// getRateChanges returns the changes of a variable rate sorted by effective date
+func getRateChanges(accountType string) (changes []RateChange, err error) {
+	rows, err := Config.Db.Query("SELECT `accountType`, `rate`, DATE_FORMAT(`effectiveDate`, '%Y-%m-%d'), `timestamp` FROM `interest_rate_changes` WHERE `accountType` = ? ORDER BY `effectiveDate`", accountType)
+	if err != nil {
+		return nil, errors.New("interest.getRateChanges: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var change RateChange
+		if err := rows.Scan(&change.AccountType, &change.Rate, &change.EffectiveDate, &change.Timestamp); err != nil {
+			return nil, errors.New("interest.getRateChanges: Could not retrieve rate change. " + err.Error())
+		}
+		changes = append(changes, change)
+	}
+
+	return
+}
+
+// accruingAccount is an account that earns interest and the currency it is held in
+type accruingAccount struct {
+	AccountNumber string
+	Currency      string
+}
+
+// getAccruingAccounts returns the accounts of a type that earn interest
+func getAccruingAccounts(accountType string) (accruing []accruingAccount, err error) {
+	rows, err := Config.Db.Query("SELECT `accountNumber`, `currency` FROM `accounts` WHERE `type` = ? AND `status` != ?", accountType, accounts.ACCOUNT_CLOSED)
+	if err != nil {
+		return nil, errors.New("interest.getAccruingAccounts: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var account accruingAccount
+		if err := rows.Scan(&account.AccountNumber, &account.Currency); err != nil {
+			return nil, errors.New("interest.getAccruingAccounts: Could not retrieve account. " + err.Error())
+		}
+		accruing = append(accruing, account)
+	}
+
+	return
+}
+
+// doAccrueInterest accrues a day's interest on every account that has a product, on the
+// balance the account ended the day with. Accounts that already accrued for the day are skipped
+func doAccrueInterest(day time.Time) (accruals []Accrual, err error) {
+	date := day.Format("2006-01-02")
+	end := int32(day.AddDate(0, 0, 1).Unix())
+
+	products, err := getProducts()
+	if err != nil {
+		return nil, errors.New("interest.doAccrueInterest: " + err.Error())
+	}
+
+	for _, product := range products {
+		days, basis, err := dayCountFraction(product.DayCount, day)
+		if err != nil {
+			return accruals, errors.New("interest.doAccrueInterest: " + err.Error())
+		}
+		changes, err := getRateChanges(product.AccountType)
+		if err != nil {
+			return accruals, errors.New("interest.doAccrueInterest: " + err.Error())
+		}
+		accruing, err := getAccruingAccounts(product.AccountType)
+		if err != nil {
+			return accruals, errors.New("interest.doAccrueInterest: " + err.Error())
+		}
+
+		for _, account := range accruing {
+			closingBalance, err := ledger.GetLedgerBalanceBefore(account.AccountNumber, end)
+			if err != nil {
+				return accruals, errors.New("interest.doAccrueInterest: " + err.Error())
+			}
+
+			rate := product.rateOn(date, closingBalance, changes)
+			// Accruals are stored to 10 decimal places
+			amount := dailyInterest(closingBalance, rate, days, basis).Round(10)
+			if amount.IsZero() {
+				continue
+			}
+
+			accrual := Accrual{
+				AccountNumber: account.AccountNumber,
+				Date:          date,
+				Balance:       money.New(closingBalance, account.Currency),
+				Rate:          rate,
+				Amount:        amount,
+				Timestamp:     int32(time.Now().Unix()),
+			}
+			saved, err := doSaveAccrual(accrual)
+			if err != nil {
+				return accruals, errors.New("interest.doAccrueInterest: " + err.Error())
+			}
+			if saved {
+				accruals = append(accruals, accrual)
+			}
+		}
+	}
+
+	return
+}
+
+// doSaveAccrual records an accrual. It saves nothing when the account already accrued for the day
+func doSaveAccrual(accrual Accrual) (saved bool, err error) {
+	insertStatement := "INSERT IGNORE INTO interest_accruals (`accountNumber`, `accrualDate`, `balance`, `rate`, `amount`, `timestamp`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?)"
+	stmtIns, err := Config.Db.Prepare(insertStatement)
+	if err != nil {
+		return false, errors.New("interest.doSaveAccrual: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	res, err := stmtIns.Exec(accrual.AccountNumber, accrual.Date, accrual.Balance, accrual.Rate, accrual.Amount, accrual.Timestamp)
+	if err != nil {
+		return false, errors.New("interest.doSaveAccrual: " + err.Error())
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.New("interest.doSaveAccrual: Could not get rows affected. " + err.Error())
+	}
+
+	return affected == 1, nil
+}
+
+// doCapitaliseInterest pays the interest accrued between from and to into every account that
+// is still open
+func doCapitaliseInterest(month string, from time.Time, to time.Time) (capitalisations []Capitalisation, err error) {
+	fromDate := from.Format("2006-01-02")
+	toDate := to.Format("2006-01-02")
+
+	rows, err := Config.Db.Query("SELECT DISTINCT ia.accountNumber, a.currency FROM interest_accruals ia "+
+		"INNER JOIN accounts a ON a.accountNumber = ia.accountNumber "+
+		"WHERE ia.accrualDate >= ? AND ia.accrualDate < ? AND ia.transactionID IS NULL AND a.status != ?", fromDate, toDate, accounts.ACCOUNT_CLOSED)
+	if err != nil {
+		return nil, errors.New("interest.doCapitaliseInterest: " + err.Error())
+	}
+	defer rows.Close()
+
+	var accruing []accruingAccount
+	for rows.Next() {
+		var account accruingAccount
+		if err := rows.Scan(&account.AccountNumber, &account.Currency); err != nil {
+			return nil, errors.New("interest.doCapitaliseInterest: Could not retrieve account. " + err.Error())
+		}
+		accruing = append(accruing, account)
+	}
+	rows.Close()
+
+	for _, account := range accruing {
+		capitalisation := Capitalisation{
+			AccountNumber: account.AccountNumber,
+			Amount:        money.Zero(account.Currency),
+			Month:         month,
+			Timestamp:     int32(time.Now().Unix()),
+		}
+		posted, err := doPostCapitalisation(&capitalisation, fromDate, toDate)
+		if err != nil {
+			return capitalisations, errors.New("interest.doCapitaliseInterest: " + err.Error())
+		}
+		if posted {
+			capitalisations = append(capitalisations, capitalisation)
+		}
+	}
+
+	return
+}
+
+// doPostCapitalisation pays an account's uncapitalised accruals between fromDate and toDate
+// into it as one interest transaction. It posts nothing when another run got there first
+func doPostCapitalisation(capitalisation *Capitalisation, fromDate string, toDate string) (posted bool, err error) {
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return false, errors.New("interest.doPostCapitalisation: Could not start database transaction. " + err.Error())
+	}
+
+	rows, err := tx.Query("SELECT `amount` FROM `interest_accruals` WHERE `accountNumber` = ? AND `accrualDate` >= ? AND `accrualDate` < ? AND `transactionID` IS NULL FOR UPDATE",
+		capitalisation.AccountNumber, fromDate, toDate)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.New("interest.doPostCapitalisation: " + err.Error())
+	}
+	total := decimal.Zero
+	count := 0
+	for rows.Next() {
+		var amount decimal.Decimal
+		if err := rows.Scan(&amount); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return false, errors.New("interest.doPostCapitalisation: Could not retrieve accrual. " + err.Error())
+		}
+		total = total.Add(amount)
+		count++
+	}
+	rows.Close()
+	if count == 0 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	// Accruals are kept unrounded, the month's interest is rounded once
+	capitalisation.Amount = money.New(total, capitalisation.Amount.Currency)
+
+	// Months that earned less than the smallest unit still close their accruals
+	if !capitalisation.Amount.IsZero() {
+		capitalisation.TransactionID, err = doPostInterestTransaction(tx, *capitalisation)
+		if err != nil {
+			tx.Rollback()
+			return false, errors.New("interest.doPostCapitalisation: " + err.Error())
+		}
+	}
+
+	stmtUpd, err := tx.Prepare("UPDATE interest_accruals SET `transactionID` = ? WHERE `accountNumber` = ? AND `accrualDate` >= ? AND `accrualDate` < ? AND `transactionID` IS NULL")
+	if err != nil {
+		tx.Rollback()
+		return false, errors.New("interest.doPostCapitalisation: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	_, err = stmtUpd.Exec(capitalisation.TransactionID, capitalisation.AccountNumber, fromDate, toDate)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.New("interest.doPostCapitalisation: " + err.Error())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, errors.New("interest.doPostCapitalisation: Could not commit transaction. " + err.Error())
+	}
+
+	return true, nil
+}
+
+// doPostInterestTransaction credits the interest to the account and records it as an
+// interest~4 transaction, so it shows on the account's history
+func doPostInterestTransaction(tx *sql.Tx, capitalisation Capitalisation) (transactionID int64, err error) {
+	amount := capitalisation.Amount
+	sqlTime := capitalisation.Timestamp
+	desc := "Interest " + capitalisation.Month
+
+	updateStatement := "UPDATE accounts SET `accountBalance` = (`accountBalance` + ?), `availableBalance` = (`availableBalance` + ?), `timestamp` = ? WHERE `accountNumber` = ? "
+	stmtUpd, err := tx.Prepare(updateStatement)
+	if err != nil {
+		return 0, errors.New("interest.doPostInterestTransaction: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	_, err = stmtUpd.Exec(amount, amount, sqlTime, capitalisation.AccountNumber)
+	if err != nil {
+		return 0, errors.New("interest.doPostInterestTransaction: " + err.Error())
+	}
+
+	insertStatement := "INSERT INTO transactions (`transaction`, `type`, `senderAccountNumber`, `senderBankNumber`, `receiverAccountNumber`, `receiverBankNumber`, `transactionAmount`, `currency`, `feeAmount`, `desc`, `timestamp`, `status`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	stmtIns, err := tx.Prepare(insertStatement)
+	if err != nil {
+		return 0, errors.New("interest.doPostInterestTransaction: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	res, err := stmtIns.Exec("intr", 4, ledger.InCurrency(ledger.INTEREST_EXPENSE, amount.Currency), "", capitalisation.AccountNumber, "", amount, amount.Currency, 0, desc, sqlTime, "settled")
+	if err != nil {
+		return 0, errors.New("interest.doPostInterestTransaction: " + err.Error())
+	}
+	transactionID, err = res.LastInsertId()
+	if err != nil {
+		return 0, errors.New("interest.doPostInterestTransaction: Could not get transaction ID. " + err.Error())
+	}
+
+	stmtHistory, err := tx.Prepare("INSERT INTO transaction_status_history (`transactionID`, `fromStatus`, `toStatus`, `reasonCode`, `timestamp`) VALUES(?, ?, ?, ?, ?)")
+	if err != nil {
+		return 0, errors.New("interest.doPostInterestTransaction: " + err.Error())
+	}
+	defer stmtHistory.Close()
+
+	_, err = stmtHistory.Exec(transactionID, "", "settled", "", sqlTime)
+	if err != nil {
+		return 0, errors.New("interest.doPostInterestTransaction: " + err.Error())
+	}
+
+	_, err = ledger.PostJournal(tx, capitalisationJournalEntry(capitalisation, transactionID))
+	if err != nil {
+		return 0, errors.New("interest.doPostInterestTransaction: " + err.Error())
+	}
+
+	_, err = statements.SaveNotification(tx, capitalisation.AccountNumber, transactionID, iso20022.CREDIT, amount, sqlTime)
+	if err != nil {
+		return 0, errors.New("interest.doPostInterestTransaction: " + err.Error())
+	}
+
+	return
+}
+
+// capitalisationJournalEntry books a month's interest from the bank's interest expense to the account
+func capitalisationJournalEntry(capitalisation Capitalisation, transactionID int64) (entry ledger.JournalEntry) {
+	entry = ledger.JournalEntry{TransactionID: transactionID, Desc: "interest~4 Interest " + capitalisation.Month, Timestamp: capitalisation.Timestamp}
+	entry.Lines = append(entry.Lines, ledger.Debit(ledger.InCurrency(ledger.INTEREST_EXPENSE, capitalisation.Amount.Currency), capitalisation.Amount.Amount)...)
+	entry.Lines = append(entry.Lines, ledger.Credit(capitalisation.AccountNumber, capitalisation.Amount.Amount)...)
+	return
+}
+
+// EarlyWithdrawalPenalty is the penalty for withdrawing amount from an account before its term
+// ends. It is zero for accounts whose product has no term, and once the account has matured
+func EarlyWithdrawalPenalty(tx *sql.Tx, accountNumber string, amount money.Money, now time.Time) (penalty money.Money, err error) {
+	penalty = money.Zero(amount.Currency)
+
+	rows, err := tx.Query("SELECT a.type, a.accountBalance, COALESCE(MIN(au.timestamp), 0) FROM accounts a "+
+		"LEFT JOIN accounts_users_accounts au ON au.accountNumber = a.accountNumber "+
+		"WHERE a.accountNumber = ? GROUP BY a.accountNumber, a.type, a.accountBalance", accountNumber)
+	if err != nil {
+		return money.Money{}, errors.New("interest.EarlyWithdrawalPenalty: " + err.Error())
+	}
+	var accountType string
+	var balance decimal.Decimal
+	var opened int64
+	found := rows.Next()
+	if found {
+		if err := rows.Scan(&accountType, &balance, &opened); err != nil {
+			rows.Close()
+			return money.Money{}, errors.New("interest.EarlyWithdrawalPenalty: Could not retrieve account. " + err.Error())
+		}
+	}
+	rows.Close()
+	if !found || opened == 0 {
+		return
+	}
+
+	product, err := getProduct(accountType)
+	if err != nil {
+		return money.Money{}, errors.New("interest.EarlyWithdrawalPenalty: " + err.Error())
+	}
+	if product.TermMonths == 0 {
+		return
+	}
+
+	changes, err := getRateChanges(accountType)
+	if err != nil {
+		return money.Money{}, errors.New("interest.EarlyWithdrawalPenalty: " + err.Error())
+	}
+	rate := product.rateOn(now.In(location()).Format("2006-01-02"), balance, changes)
+
+	penalty, err = withdrawalPenalty(product, rate, amount, time.Unix(opened, 0).In(location()), now)
+	if err != nil {
+		return money.Money{}, errors.New("interest.EarlyWithdrawalPenalty: " + err.Error())
+	}
+
+	return
+}
+
+// ChargeEarlyWithdrawalPenalty debits the penalty from the account inside tx. The penalty
+// gives back interest the bank paid, so it is booked against the interest expense
+func ChargeEarlyWithdrawalPenalty(tx *sql.Tx, accountNumber string, penalty money.Money, sqlTime int32) (err error) {
+	if penalty.IsZero() {
+		return
+	}
+
+	updateStatement := "UPDATE accounts SET `accountBalance` = (`accountBalance` - ?), `availableBalance` = (`availableBalance` - ?), `timestamp` = ? WHERE `accountNumber` = ? "
+	stmtUpd, err := tx.Prepare(updateStatement)
+	if err != nil {
+		return errors.New("interest.ChargeEarlyWithdrawalPenalty: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	_, err = stmtUpd.Exec(penalty, penalty, sqlTime, accountNumber)
+	if err != nil {
+		return errors.New("interest.ChargeEarlyWithdrawalPenalty: " + err.Error())
+	}
+
+	_, err = ledger.PostJournal(tx, penaltyJournalEntry(accountNumber, penalty, sqlTime))
+	if err != nil {
+		return errors.New("interest.ChargeEarlyWithdrawalPenalty: " + err.Error())
+	}
+
+	return
+}
+
+func penaltyJournalEntry(accountNumber string, penalty money.Money, sqlTime int32) (entry ledger.JournalEntry) {
+	entry = ledger.JournalEntry{Desc: "Early withdrawal penalty", Timestamp: sqlTime}
+	entry.Lines = append(entry.Lines, ledger.Debit(accountNumber, penalty.Amount)...)
+	entry.Lines = append(entry.Lines, ledger.Credit(ledger.InCurrency(ledger.INTEREST_EXPENSE, penalty.Currency), penalty.Amount)...)
+	return
+}
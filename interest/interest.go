@@ -0,0 +1,447 @@
+package interest
+
+/*
+Interest on deposit accounts
+
+Each account type whose catalogue product earns deposit interest (product~1) can have an
+interest product. Every open account of that type earns the product's rate on the balance it
+ends each day with.
+
+interest~1~
+   AccountType~
+   RateType~
+   Rate~
+   DayCount~
+   TermMonths~
+   PenaltyDays~
+   Tiers
+
+Defines or replaces the product of an account type. RateType is fixed, variable or tiered
+and Rate is the yearly rate in percent. Variable rates are changed with interest~2. Tiered
+products pay Rate below the first tier and otherwise the rate of the highest tier the whole
+balance reaches, with Tiers as minimumBalance:rate pairs separated by commas
+(e.g. 1000:1.5,10000:2). DayCount is ACT/365 (default), ACT/360, ACT/ACT or 30/360.
+TermMonths is the term of accounts of term products (product~1), such as certificates of
+deposit, counted from the day the account was opened.
+Withdrawing from an account before it matures costs PenaltyDays days of interest on the
+amount withdrawn, whether by payment, direct debit, conversion or the sweep when the account
+is closed.
+
+interest~2~
+   AccountType~
+   Rate~
+   EffectiveDate
+
+Changes the rate of a variable product from EffectiveDate (YYYY-MM-DD, today when empty).
+
+interest~3~
+   Date
+
+Accrues a day's interest (YYYY-MM-DD, yesterday when empty) on every account with a product.
+Accruals are kept unrounded and each account accrues once per day, so the run can be repeated.
+
+interest~4~
+   Month
+
+Capitalises the interest accrued in a month (YYYY-MM, last month when empty), posting an
+interest transaction to every account. Interest accrued on accounts that were closed since
+is forfeited.
+
+interest~1000
+
+Lists the products with the rate each pays today.
+*/
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/configuration"
+	"github.com/bvnk/bank/money"
+	"github.com/bvnk/bank/products"
+	"github.com/shopspring/decimal"
+)
+
+var Config configuration.Configuration
+
+func SetConfig(config *configuration.Configuration) {
+	Config = *config
+}
+
+// Rate types
+const (
+	RATE_FIXED    = "fixed"
+	RATE_VARIABLE = "variable"
+	RATE_TIERED   = "tiered"
+)
+
+// Day count conventions, deciding the fraction of a year each day's interest is worth
+const (
+	DAY_COUNT_ACTUAL_365    = "ACT/365"
+	DAY_COUNT_ACTUAL_360    = "ACT/360"
+	DAY_COUNT_ACTUAL_ACTUAL = "ACT/ACT"
+	DAY_COUNT_30_360        = "30/360"
+)
+
+// Largest yearly interest rate, in percent
+const MAX_INTEREST_RATE = 100
+
+// Product is the interest paid on an account type
+type Product struct {
+	AccountType string
+	RateType    string
+	Rate        decimal.Decimal
+	Tiers       []Tier
+	DayCount    string
+	TermMonths  int
+	PenaltyDays int
+	Timestamp   int32
+}
+
+// Tier is the rate paid on balances of at least MinimumBalance
+type Tier struct {
+	MinimumBalance decimal.Decimal
+	Rate           decimal.Decimal
+}
+
+// RateChange is a change of a variable rate from a date onwards
+type RateChange struct {
+	AccountType   string
+	Rate          decimal.Decimal
+	EffectiveDate string
+	Timestamp     int32
+}
+
+// Accrual is the interest an account earned on one day, before rounding
+type Accrual struct {
+	AccountNumber string
+	Date          string
+	Balance       money.Money
+	Rate          decimal.Decimal
+	Amount        decimal.Decimal
+	Timestamp     int32
+}
+
+// Capitalisation is a month's accrued interest paid into an account
+type Capitalisation struct {
+	AccountNumber string
+	Month         string
+	Amount        money.Money
+	TransactionID int64
+	Timestamp     int32
+}
+
+func ProcessInterest(data []string) (result interface{}, err error) {
+	if len(data) < 3 {
+		return "", errors.New("interest.ProcessInterest: Not all data is present")
+	}
+
+	// Remove null termination from the last field
+	data[len(data)-1] = strings.TrimRight(data[len(data)-1], "\x00")
+
+	interestType, err := strconv.ParseInt(data[2], 10, 64)
+	if err != nil {
+		return "", errors.New("interest.ProcessInterest: Could not get type of interest request. " + err.Error())
+	}
+
+	switch interestType {
+	case 1:
+		result, err = setProduct(data)
+		if err != nil {
+			return "", errors.New("interest.ProcessInterest: " + err.Error())
+		}
+		break
+	case 2:
+		result, err = setRate(data)
+		if err != nil {
+			return "", errors.New("interest.ProcessInterest: " + err.Error())
+		}
+		break
+	case 3:
+		result, err = accrueInterest(data)
+		if err != nil {
+			return "", errors.New("interest.ProcessInterest: " + err.Error())
+		}
+		break
+	case 4:
+		result, err = capitaliseInterest(data)
+		if err != nil {
+			return "", errors.New("interest.ProcessInterest: " + err.Error())
+		}
+		break
+	case 1000:
+		result, err = listProducts()
+		if err != nil {
+			return "", errors.New("interest.ProcessInterest: " + err.Error())
+		}
+		break
+	default:
+		return "", errors.New("interest.ProcessInterest: Interest request type invalid")
+	}
+
+	return
+}
+
+func setProduct(data []string) (result interface{}, err error) {
+	if len(data) < 6 {
+		return "", errors.New("interest.setProduct: Not all fields present")
+	}
+
+	product := Product{
+		AccountType: data[3],
+		RateType:    data[4],
+		DayCount:    DAY_COUNT_ACTUAL_365,
+		Timestamp:   int32(time.Now().Unix()),
+	}
+	if !products.ValidCode(product.AccountType) {
+		return "", errors.New("interest.setProduct: Account type must be a product code")
+	}
+	if product.RateType != RATE_FIXED && product.RateType != RATE_VARIABLE && product.RateType != RATE_TIERED {
+		return "", errors.New("interest.setProduct: Rate type must be fixed, variable or tiered")
+	}
+
+	product.Rate, err = parseRate(data[5])
+	if err != nil {
+		return "", errors.New("interest.setProduct: " + err.Error())
+	}
+
+	if len(data) > 6 && data[6] != "" {
+		product.DayCount = data[6]
+	}
+	if !validDayCount(product.DayCount) {
+		return "", errors.New("interest.setProduct: Day count must be ACT/365, ACT/360, ACT/ACT or 30/360")
+	}
+
+	if len(data) > 7 && data[7] != "" {
+		product.TermMonths, err = strconv.Atoi(data[7])
+		if err != nil || product.TermMonths < 0 {
+			return "", errors.New("interest.setProduct: Term must be a number of months")
+		}
+	}
+	if len(data) > 8 && data[8] != "" {
+		product.PenaltyDays, err = strconv.Atoi(data[8])
+		if err != nil || product.PenaltyDays < 0 {
+			return "", errors.New("interest.setProduct: Penalty must be a number of days")
+		}
+	}
+
+	if len(data) > 9 && data[9] != "" {
+		product.Tiers, err = parseTiers(data[9])
+		if err != nil {
+			return "", errors.New("interest.setProduct: " + err.Error())
+		}
+	}
+	if product.RateType == RATE_TIERED && len(product.Tiers) == 0 {
+		return "", errors.New("interest.setProduct: Tiered products need at least one tier")
+	}
+	if product.RateType != RATE_TIERED && len(product.Tiers) > 0 {
+		return "", errors.New("interest.setProduct: Only tiered products have tiers")
+	}
+
+	_, err = appauth.CheckOperator(data[0])
+	if err != nil {
+		return "", errors.New("interest.setProduct: " + err.Error())
+	}
+
+	// Only products of the catalogue that earn interest can have an interest product
+	catalogueProduct, err := products.GetProduct(product.AccountType)
+	if err != nil {
+		return "", errors.New("interest.setProduct: " + err.Error())
+	}
+	if catalogueProduct.InterestType != products.INTEREST_DEPOSIT {
+		return "", errors.New("interest.setProduct: Product " + product.AccountType + " does not earn interest")
+	}
+	err = catalogueProduct.CheckTerm(product.TermMonths)
+	if err != nil {
+		return "", errors.New("interest.setProduct: " + err.Error())
+	}
+
+	err = doSaveProduct(product)
+	if err != nil {
+		return "", errors.New("interest.setProduct: " + err.Error())
+	}
+
+	return product, nil
+}
+
+func setRate(data []string) (result interface{}, err error) {
+	if len(data) < 5 {
+		return "", errors.New("interest.setRate: Not all fields present")
+	}
+
+	change := RateChange{
+		AccountType:   data[3],
+		EffectiveDate: time.Now().In(location()).Format("2006-01-02"),
+		Timestamp:     int32(time.Now().Unix()),
+	}
+	change.Rate, err = parseRate(data[4])
+	if err != nil {
+		return "", errors.New("interest.setRate: " + err.Error())
+	}
+	if len(data) > 5 && data[5] != "" {
+		if _, err := time.Parse("2006-01-02", data[5]); err != nil {
+			return "", errors.New("interest.setRate: Effective date must be YYYY-MM-DD")
+		}
+		change.EffectiveDate = data[5]
+	}
+
+	_, err = appauth.CheckOperator(data[0])
+	if err != nil {
+		return "", errors.New("interest.setRate: " + err.Error())
+	}
+
+	product, err := getProduct(change.AccountType)
+	if err != nil {
+		return "", errors.New("interest.setRate: " + err.Error())
+	}
+	if product.AccountType == "" {
+		return "", errors.New("interest.setRate: No product for account type " + change.AccountType)
+	}
+	if product.RateType != RATE_VARIABLE {
+		return "", errors.New("interest.setRate: Only variable rates can be changed, define the product again instead")
+	}
+
+	err = doSaveRateChange(change)
+	if err != nil {
+		return "", errors.New("interest.setRate: " + err.Error())
+	}
+
+	return change, nil
+}
+
+func accrueInterest(data []string) (result interface{}, err error) {
+	loc := location()
+	date := time.Now().In(loc).AddDate(0, 0, -1).Format("2006-01-02")
+	if len(data) > 3 && data[3] != "" {
+		date = data[3]
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", date, loc)
+	if err != nil {
+		return "", errors.New("interest.accrueInterest: Date must be YYYY-MM-DD")
+	}
+	if day.AddDate(0, 0, 1).After(time.Now()) {
+		return "", errors.New("interest.accrueInterest: Day " + date + " is not over yet")
+	}
+
+	_, err = appauth.CheckOperator(data[0])
+	if err != nil {
+		return "", errors.New("interest.accrueInterest: " + err.Error())
+	}
+
+	accruals, err := doAccrueInterest(day)
+	if err != nil {
+		return "", errors.New("interest.accrueInterest: " + err.Error())
+	}
+
+	return accruals, nil
+}
+
+func capitaliseInterest(data []string) (result interface{}, err error) {
+	loc := location()
+	now := time.Now().In(loc)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc).AddDate(0, -1, 0).Format("2006-01")
+	if len(data) > 3 && data[3] != "" {
+		month = data[3]
+	}
+
+	from, err := time.ParseInLocation("2006-01", month, loc)
+	if err != nil {
+		return "", errors.New("interest.capitaliseInterest: Month must be YYYY-MM")
+	}
+	to := from.AddDate(0, 1, 0)
+	if to.After(now) {
+		return "", errors.New("interest.capitaliseInterest: Month " + month + " is not over yet")
+	}
+
+	_, err = appauth.CheckOperator(data[0])
+	if err != nil {
+		return "", errors.New("interest.capitaliseInterest: " + err.Error())
+	}
+
+	capitalisations, err := doCapitaliseInterest(month, from, to)
+	if err != nil {
+		return "", errors.New("interest.capitaliseInterest: " + err.Error())
+	}
+
+	return capitalisations, nil
+}
+
+func listProducts() (result interface{}, err error) {
+	products, err := getProducts()
+	if err != nil {
+		return "", errors.New("interest.listProducts: " + err.Error())
+	}
+
+	today := time.Now().In(location()).Format("2006-01-02")
+	for i := range products {
+		if products[i].RateType != RATE_VARIABLE {
+			continue
+		}
+		changes, err := getRateChanges(products[i].AccountType)
+		if err != nil {
+			return "", errors.New("interest.listProducts: " + err.Error())
+		}
+		products[i].Rate = products[i].rateOn(today, decimal.Zero, changes)
+	}
+
+	return products, nil
+}
+
+func validDayCount(dayCount string) bool {
+	switch dayCount {
+	case DAY_COUNT_ACTUAL_365, DAY_COUNT_ACTUAL_360, DAY_COUNT_ACTUAL_ACTUAL, DAY_COUNT_30_360:
+		return true
+	}
+	return false
+}
+
+func parseRate(value string) (rate decimal.Decimal, err error) {
+	rate, err = decimal.NewFromString(strings.TrimSpace(value))
+	if err != nil {
+		return decimal.Zero, errors.New("interest.parseRate: Rate not valid. " + err.Error())
+	}
+	if rate.Sign() < 0 || rate.GreaterThan(decimal.New(MAX_INTEREST_RATE, 0)) {
+		return decimal.Zero, errors.New("interest.parseRate: Rate must be between 0 and 100 percent")
+	}
+	return
+}
+
+// parseTiers reads minimumBalance:rate pairs and sorts them by minimum balance
+func parseTiers(value string) (tiers []Tier, err error) {
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 {
+			return nil, errors.New("interest.parseTiers: Tiers must be minimumBalance:rate pairs")
+		}
+		minimum, err := decimal.NewFromString(strings.TrimSpace(parts[0]))
+		if err != nil || minimum.Sign() < 0 {
+			return nil, errors.New("interest.parseTiers: Minimum balance " + parts[0] + " not valid")
+		}
+		rate, err := parseRate(parts[1])
+		if err != nil {
+			return nil, errors.New("interest.parseTiers: " + err.Error())
+		}
+		for _, tier := range tiers {
+			if tier.MinimumBalance.Equal(minimum) {
+				return nil, errors.New("interest.parseTiers: Minimum balance " + parts[0] + " appears twice")
+			}
+		}
+		tiers = append(tiers, Tier{MinimumBalance: minimum, Rate: rate})
+	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinimumBalance.LessThan(tiers[j].MinimumBalance) })
+	return
+}
+
+// location is the bank's time zone, which decides where accrual days begin and end
+func location() *time.Location {
+	loc, err := time.LoadLocation(Config.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
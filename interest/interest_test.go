@@ -0,0 +1,195 @@
+package interest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+func TestProcessInterest(t *testing.T) {
+	tst := []string{"", ""}
+	_, err := ProcessInterest(tst)
+	if err == nil {
+		t.Errorf("ProcessInterest does not pass. Looking for %v, got %v", "Not all data is present", nil)
+	}
+
+	// Each request fails validation before the token is checked
+	invalid := map[string]struct {
+		data     []string
+		expected string
+	}{
+		"request type":     {[]string{"", "", "0"}, "Interest request type invalid"},
+		"account type":     {[]string{"", "", "1", "Savings Account", "fixed", "2"}, "Account type must be a product code"},
+		"rate type":        {[]string{"", "", "1", "savings", "floating", "2"}, "Rate type must be fixed, variable or tiered"},
+		"rate":             {[]string{"", "", "1", "savings", "fixed", "101"}, "Rate must be between 0 and 100 percent"},
+		"day count":        {[]string{"", "", "1", "savings", "fixed", "2", "ACT/364"}, "Day count must be ACT/365"},
+		"term":             {[]string{"", "", "1", "cd", "fixed", "2", "ACT/365", "-12"}, "Term must be a number of months"},
+		"penalty":          {[]string{"", "", "1", "cd", "fixed", "2", "ACT/365", "12", "x"}, "Penalty must be a number of days"},
+		"tiered":           {[]string{"", "", "1", "savings", "tiered", "1"}, "Tiered products need at least one tier"},
+		"fixed with tiers": {[]string{"", "", "1", "savings", "fixed", "1", "", "", "", "1000:2"}, "Only tiered products have tiers"},
+		"tiers":            {[]string{"", "", "1", "savings", "tiered", "1", "", "", "", "1000"}, "Tiers must be minimumBalance:rate pairs"},
+		"effective date":   {[]string{"", "", "2", "savings", "2", "2017-13-01"}, "Effective date must be YYYY-MM-DD"},
+		"accrual date":     {[]string{"", "", "3", "2017-13-01"}, "Date must be YYYY-MM-DD"},
+		"accrual tomorrow": {[]string{"", "", "3", time.Now().AddDate(0, 0, 1).Format("2006-01-02")}, "is not over yet"},
+		"month":            {[]string{"", "", "4", "2017-13"}, "Month must be YYYY-MM"},
+		"current month":    {[]string{"", "", "4", time.Now().AddDate(0, 0, 1).Format("2006-01")}, "is not over yet"},
+	}
+	for name, tst := range invalid {
+		_, err := ProcessInterest(tst.data)
+		if err == nil || !strings.Contains(err.Error(), tst.expected) {
+			t.Errorf("ProcessInterest %v does not pass. Looking for %v, got %v", name, tst.expected, err)
+		}
+	}
+}
+
+func TestParseTiers(t *testing.T) {
+	tiers, err := parseTiers("10000:2, 1000:1.5")
+	if err != nil {
+		t.Fatalf("ParseTiers does not pass. Looking for %v, got %v", nil, err)
+	}
+	if len(tiers) != 2 || tiers[0].MinimumBalance.String() != "1000" || tiers[1].Rate.String() != "2" {
+		t.Errorf("ParseTiers does not pass. Looking for %v, got %v", "tiers sorted by minimum balance", tiers)
+	}
+
+	invalid := []string{"1000", "1000:1:2", "x:1", "-1:1", "1000:101", "1000:1,1000:2"}
+	for _, tst := range invalid {
+		_, err := parseTiers(tst)
+		if err == nil {
+			t.Errorf("ParseTiers %v does not pass. Looking for %v, got %v", tst, "error", nil)
+		}
+	}
+}
+
+func TestRateOn(t *testing.T) {
+	rate := decimal.RequireFromString
+
+	tiered := Product{RateType: RATE_TIERED, Rate: rate("0.5"), Tiers: []Tier{
+		{MinimumBalance: rate("1000"), Rate: rate("1.5")},
+		{MinimumBalance: rate("10000"), Rate: rate("2")},
+	}}
+	balances := map[string]string{
+		"0":     "0.5",
+		"999":   "0.5",
+		"1000":  "1.5",
+		"9999":  "1.5",
+		"10000": "2",
+	}
+	for balance, expected := range balances {
+		got := tiered.rateOn("2017-06-01", rate(balance), nil)
+		if !got.Equal(rate(expected)) {
+			t.Errorf("RateOn tiered %v does not pass. Looking for %v, got %v", balance, expected, got)
+		}
+	}
+
+	variable := Product{RateType: RATE_VARIABLE, Rate: rate("1")}
+	changes := []RateChange{
+		{Rate: rate("1.25"), EffectiveDate: "2017-03-01"},
+		{Rate: rate("1.75"), EffectiveDate: "2017-09-01"},
+	}
+	dates := map[string]string{
+		"2017-02-28": "1",
+		"2017-03-01": "1.25",
+		"2017-08-31": "1.25",
+		"2017-09-01": "1.75",
+	}
+	for date, expected := range dates {
+		got := variable.rateOn(date, decimal.Zero, changes)
+		if !got.Equal(rate(expected)) {
+			t.Errorf("RateOn variable %v does not pass. Looking for %v, got %v", date, expected, got)
+		}
+	}
+
+	// Fixed rates ignore any changes
+	fixed := Product{RateType: RATE_FIXED, Rate: rate("1")}
+	if got := fixed.rateOn("2017-09-01", decimal.Zero, changes); !got.Equal(rate("1")) {
+		t.Errorf("RateOn fixed does not pass. Looking for %v, got %v", "1", got)
+	}
+}
+
+func TestDayCountFraction(t *testing.T) {
+	// Every convention adds up to a whole year over a year of daily accruals
+	conventions := map[string]int{
+		DAY_COUNT_ACTUAL_365:    2017,
+		DAY_COUNT_ACTUAL_ACTUAL: 2016,
+		DAY_COUNT_30_360:        2016,
+	}
+	for dayCount, year := range conventions {
+		total := decimal.Zero
+		for day := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC); day.Year() == year; day = day.AddDate(0, 0, 1) {
+			days, basis, err := dayCountFraction(dayCount, day)
+			if err != nil {
+				t.Fatalf("DayCountFraction %v does not pass. Looking for %v, got %v", dayCount, nil, err)
+			}
+			total = total.Add(decimal.New(int64(days), 0).Div(decimal.New(int64(basis), 0)))
+		}
+		if !total.Round(8).Equal(decimal.New(1, 0)) {
+			t.Errorf("DayCountFraction %v does not pass. Looking for %v, got %v", dayCount, 1, total)
+		}
+	}
+
+	_, _, err := dayCountFraction("ACT/364", time.Now())
+	if err == nil {
+		t.Errorf("DayCountFraction does not pass. Looking for %v, got %v", "Unknown day count", nil)
+	}
+}
+
+func TestDays360(t *testing.T) {
+	date := func(value string) time.Time {
+		day, _ := time.Parse("2006-01-02", value)
+		return day
+	}
+	periods := map[string]int{
+		"2017-01-30": 0,
+		"2017-01-31": 1,
+		"2017-02-28": 3,
+		"2016-02-28": 1,
+		"2016-02-29": 2,
+		"2017-12-31": 1,
+	}
+	for from, expected := range periods {
+		day := date(from)
+		got := days360(day, day.AddDate(0, 0, 1))
+		if got != expected {
+			t.Errorf("Days360 %v does not pass. Looking for %v, got %v", from, expected, got)
+		}
+	}
+}
+
+func TestDailyInterest(t *testing.T) {
+	// 3.65% a year on 1000.00 is 0.10 a day on ACT/365
+	got := dailyInterest(decimal.RequireFromString("1000"), decimal.RequireFromString("3.65"), 1, 365)
+	if !got.Equal(decimal.RequireFromString("0.1")) {
+		t.Errorf("DailyInterest does not pass. Looking for %v, got %v", "0.1", got)
+	}
+
+	got = dailyInterest(decimal.RequireFromString("-1000"), decimal.RequireFromString("3.65"), 1, 365)
+	if !got.IsZero() {
+		t.Errorf("DailyInterest negative balance does not pass. Looking for %v, got %v", 0, got)
+	}
+}
+
+func TestWithdrawalPenalty(t *testing.T) {
+	product := Product{RateType: RATE_FIXED, Rate: decimal.RequireFromString("3.65"), DayCount: DAY_COUNT_ACTUAL_365, TermMonths: 12, PenaltyDays: 90}
+	amount := money.New(decimal.New(1000, 0), money.DEFAULT_CURRENCY)
+	opened := time.Date(2017, time.January, 15, 10, 0, 0, 0, time.UTC)
+
+	// 90 days of interest at 3.65% on 1000.00
+	penalty, err := withdrawalPenalty(product, product.Rate, amount, opened, opened.AddDate(0, 6, 0))
+	if err != nil || penalty.StringFixed() != "9.00" {
+		t.Errorf("WithdrawalPenalty before maturity does not pass. Looking for %v, got %v %v", "9.00", penalty.StringFixed(), err)
+	}
+
+	penalty, err = withdrawalPenalty(product, product.Rate, amount, opened, opened.AddDate(1, 0, 0))
+	if err != nil || !penalty.IsZero() {
+		t.Errorf("WithdrawalPenalty at maturity does not pass. Looking for %v, got %v %v", "0.00", penalty.StringFixed(), err)
+	}
+
+	product.TermMonths = 0
+	penalty, err = withdrawalPenalty(product, product.Rate, amount, opened, opened.AddDate(0, 6, 0))
+	if err != nil || !penalty.IsZero() {
+		t.Errorf("WithdrawalPenalty without term does not pass. Looking for %v, got %v %v", "0.00", penalty.StringFixed(), err)
+	}
+}
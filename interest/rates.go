@@ -0,0 +1,93 @@
+package interest
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+// rateOn is the yearly rate the product pays on a balance on a date (YYYY-MM-DD). Changes
+// must be sorted by effective date
+func (product Product) rateOn(date string, balance decimal.Decimal, changes []RateChange) (rate decimal.Decimal) {
+	rate = product.Rate
+	switch product.RateType {
+	case RATE_VARIABLE:
+		for _, change := range changes {
+			if change.EffectiveDate > date {
+				break
+			}
+			rate = change.Rate
+		}
+	case RATE_TIERED:
+		for _, tier := range product.Tiers {
+			if balance.LessThan(tier.MinimumBalance) {
+				break
+			}
+			rate = tier.Rate
+		}
+	}
+	return
+}
+
+// dayCountFraction is the fraction of a year, days over basis, that one day counts for
+func dayCountFraction(dayCount string, day time.Time) (days int, basis int, err error) {
+	switch dayCount {
+	case DAY_COUNT_ACTUAL_365:
+		return 1, 365, nil
+	case DAY_COUNT_ACTUAL_360:
+		return 1, 360, nil
+	case DAY_COUNT_ACTUAL_ACTUAL:
+		return 1, daysInYear(day.Year()), nil
+	case DAY_COUNT_30_360:
+		return days360(day, day.AddDate(0, 0, 1)), 360, nil
+	}
+	return 0, 0, errors.New("interest.dayCountFraction: Unknown day count " + dayCount)
+}
+
+func daysInYear(year int) int {
+	return time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+}
+
+// days360 counts the days between two dates as if every month had 30 days (30E/360). A month
+// still adds up to 30 days: the 31st counts for nothing and the end of February makes up the
+// missing days
+func days360(from time.Time, to time.Time) int {
+	fromDay, toDay := from.Day(), to.Day()
+	if fromDay > 30 {
+		fromDay = 30
+	}
+	if toDay > 30 {
+		toDay = 30
+	}
+	return 360*(to.Year()-from.Year()) + 30*(int(to.Month())-int(from.Month())) + toDay - fromDay
+}
+
+// dailyInterest is the interest on the balance for days over basis of a year, unrounded. Only
+// positive balances earn interest
+func dailyInterest(balance decimal.Decimal, rate decimal.Decimal, days int, basis int) decimal.Decimal {
+	if balance.Sign() <= 0 {
+		return decimal.Zero
+	}
+	return balance.Mul(rate).Mul(decimal.New(int64(days), 0)).Div(decimal.New(int64(100*basis), 0))
+}
+
+// maturity is the day an account opened at opened with a term of months matures
+func maturity(opened time.Time, termMonths int) time.Time {
+	return opened.AddDate(0, termMonths, 0)
+}
+
+// withdrawalPenalty is the product's penalty for withdrawing amount at now from an account
+// opened at opened: PenaltyDays days of interest on the amount. Nothing is due once the
+// account has matured or when it has no term
+func withdrawalPenalty(product Product, rate decimal.Decimal, amount money.Money, opened time.Time, now time.Time) (penalty money.Money, err error) {
+	if product.TermMonths == 0 || product.PenaltyDays == 0 || !now.Before(maturity(opened, product.TermMonths)) {
+		return money.Zero(amount.Currency), nil
+	}
+	_, basis, err := dayCountFraction(product.DayCount, now)
+	if err != nil {
+		return money.Money{}, errors.New("interest.withdrawalPenalty: " + err.Error())
+	}
+	return money.New(dailyInterest(amount.Amount, rate, product.PenaltyDays, basis), amount.Currency), nil
+}
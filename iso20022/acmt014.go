@@ -0,0 +1,61 @@
+package iso20022
+
+import (
+	"encoding/xml"
+)
+
+const ACMT_014_NAMESPACE = "urn:iso:std:iso:20022:tech:xsd:acmt.014.001.02"
+
+// Account statuses (AccountStatus2Code)
+const (
+	ACCOUNT_ENABLED         = "ENAB"
+	ACCOUNT_DISABLED        = "DISA"
+	ACCOUNT_PENDING_OPENING = "FORM"
+)
+
+// Acmt014Document is an AccountReportV02, sent in reply to an account report request (acmt.013).
+// The bank reports every account of a holder in one document
+type Acmt014Document struct {
+	XMLName xml.Name         `xml:"Document"`
+	Xmlns   string           `xml:"xmlns,attr"`
+	AcctRpt AccountReportV02 `xml:"AcctRpt"`
+}
+
+type AccountReportV02 struct {
+	Refs       AccountReportReferences                      `xml:"Refs"`
+	AcctSvcrId *BranchAndFinancialInstitutionIdentification `xml:"AcctSvcrId,omitempty"`
+	AcctOwnr   *PartyIdentification                         `xml:"AcctOwnr,omitempty"`
+	Acct       []CustomerAccount                            `xml:"Acct"`
+}
+
+type MessageIdentification struct {
+	Id      string `xml:"Id"`
+	CreDtTm string `xml:"CreDtTm"`
+}
+
+type AccountReportReferences struct {
+	MsgId MessageIdentification `xml:"MsgId"`
+}
+
+type CashAccountType struct {
+	Cd    string `xml:"Cd,omitempty"`
+	Prtry string `xml:"Prtry,omitempty"`
+}
+
+type CustomerAccount struct {
+	Id     AccountIdentification `xml:"Id"`
+	Nm     string                `xml:"Nm,omitempty"`
+	Sts    string                `xml:"Sts,omitempty"`
+	Tp     *CashAccountType      `xml:"Tp,omitempty"`
+	Ccy    string                `xml:"Ccy,omitempty"`
+	OpngDt string                `xml:"OpngDt,omitempty"`
+	ClsgDt string                `xml:"ClsgDt,omitempty"`
+	Bal    []CashBalance         `xml:"Bal,omitempty"`
+	// Mrchnt is the merchant the account is linked to, which has no place in the standard message
+	Mrchnt *MerchantIdentification `xml:"Mrchnt,omitempty"`
+}
+
+type MerchantIdentification struct {
+	Id string `xml:"Id"`
+	Nm string `xml:"Nm,omitempty"`
+}
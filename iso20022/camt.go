@@ -0,0 +1,160 @@
+package iso20022
+
+// Building blocks shared by the cash management (camt) account reports
+
+// Credit or debit indicators
+const (
+	CREDIT = "CRDT"
+	DEBIT  = "DBIT"
+)
+
+// Balance types (BalanceType12Code)
+const (
+	BALANCE_OPENING_BOOKED    = "OPBD"
+	BALANCE_CLOSING_BOOKED    = "CLBD"
+	BALANCE_INTERIM_BOOKED    = "ITBD"
+	BALANCE_INTERIM_AVAILABLE = "ITAV"
+)
+
+// Entry statuses (EntryStatus2Code)
+const (
+	ENTRY_BOOKED  = "BOOK"
+	ENTRY_PENDING = "PDNG"
+)
+
+type ReportGroupHeader struct {
+	MsgId   string `xml:"MsgId"`
+	CreDtTm string `xml:"CreDtTm"`
+}
+
+type DateTimePeriod struct {
+	FrDtTm string `xml:"FrDtTm"`
+	ToDtTm string `xml:"ToDtTm"`
+}
+
+type DateAndDateTime struct {
+	Dt   string `xml:"Dt,omitempty"`
+	DtTm string `xml:"DtTm,omitempty"`
+}
+
+type BalanceTypeCode struct {
+	Cd string `xml:"Cd"`
+}
+
+type BalanceType struct {
+	CdOrPrtry BalanceTypeCode `xml:"CdOrPrtry"`
+}
+
+type CreditLine struct {
+	Incl bool                               `xml:"Incl"`
+	Amt  *ActiveOrHistoricCurrencyAndAmount `xml:"Amt,omitempty"`
+}
+
+type CashBalance struct {
+	Tp        BalanceType                       `xml:"Tp"`
+	CdtLine   *CreditLine                       `xml:"CdtLine,omitempty"`
+	Amt       ActiveOrHistoricCurrencyAndAmount `xml:"Amt"`
+	CdtDbtInd string                            `xml:"CdtDbtInd"`
+	Dt        DateAndDateTime                   `xml:"Dt"`
+}
+
+type AmountAndDirection struct {
+	Amt       string `xml:"Amt"`
+	CdtDbtInd string `xml:"CdtDbtInd"`
+}
+
+type NumberAndSumOfTransactions struct {
+	NbOfNtries string              `xml:"NbOfNtries"`
+	Sum        string              `xml:"Sum"`
+	TtlNetNtry *AmountAndDirection `xml:"TtlNetNtry,omitempty"`
+}
+
+type TotalTransactions struct {
+	TtlNtries    NumberAndSumOfTransactions `xml:"TtlNtries"`
+	TtlCdtNtries NumberAndSumOfTransactions `xml:"TtlCdtNtries"`
+	TtlDbtNtries NumberAndSumOfTransactions `xml:"TtlDbtNtries"`
+}
+
+type BankTransactionCodeFamily struct {
+	Cd        string `xml:"Cd"`
+	SubFmlyCd string `xml:"SubFmlyCd"`
+}
+
+type BankTransactionCodeDomain struct {
+	Cd   string                    `xml:"Cd"`
+	Fmly BankTransactionCodeFamily `xml:"Fmly"`
+}
+
+type BankTransactionCode struct {
+	Domn BankTransactionCodeDomain `xml:"Domn"`
+}
+
+// NewBankTransactionCode builds a bank transaction code from its domain, family and sub-family
+func NewBankTransactionCode(domain string, family string, subFamily string) BankTransactionCode {
+	return BankTransactionCode{Domn: BankTransactionCodeDomain{Cd: domain, Fmly: BankTransactionCodeFamily{Cd: family, SubFmlyCd: subFamily}}}
+}
+
+type ChargesRecord struct {
+	Amt         ActiveOrHistoricCurrencyAndAmount `xml:"Amt"`
+	CdtDbtInd   string                            `xml:"CdtDbtInd"`
+	ChrgInclInd bool                              `xml:"ChrgInclInd"`
+}
+
+type Charges struct {
+	Rcrd []ChargesRecord `xml:"Rcrd"`
+}
+
+type TransactionReferences struct {
+	AcctSvcrRef string `xml:"AcctSvcrRef,omitempty"`
+	EndToEndId  string `xml:"EndToEndId,omitempty"`
+	MndtId      string `xml:"MndtId,omitempty"`
+}
+
+type TransactionParties struct {
+	DbtrAcct *CashAccount `xml:"DbtrAcct,omitempty"`
+	CdtrAcct *CashAccount `xml:"CdtrAcct,omitempty"`
+}
+
+type TransactionAgents struct {
+	DbtrAgt *BranchAndFinancialInstitutionIdentification `xml:"DbtrAgt,omitempty"`
+	CdtrAgt *BranchAndFinancialInstitutionIdentification `xml:"CdtrAgt,omitempty"`
+}
+
+type EntryTransaction struct {
+	Refs      *TransactionReferences `xml:"Refs,omitempty"`
+	RltdPties *TransactionParties    `xml:"RltdPties,omitempty"`
+	RltdAgts  *TransactionAgents     `xml:"RltdAgts,omitempty"`
+	RmtInf    *RemittanceInformation `xml:"RmtInf,omitempty"`
+}
+
+type EntryDetails struct {
+	TxDtls []EntryTransaction `xml:"TxDtls"`
+}
+
+type ReportEntry struct {
+	NtryRef      string                            `xml:"NtryRef,omitempty"`
+	Amt          ActiveOrHistoricCurrencyAndAmount `xml:"Amt"`
+	CdtDbtInd    string                            `xml:"CdtDbtInd"`
+	RvslInd      bool                              `xml:"RvslInd,omitempty"`
+	Sts          string                            `xml:"Sts"`
+	BookgDt      *DateAndDateTime                  `xml:"BookgDt,omitempty"`
+	ValDt        *DateAndDateTime                  `xml:"ValDt,omitempty"`
+	AcctSvcrRef  string                            `xml:"AcctSvcrRef,omitempty"`
+	BkTxCd       BankTransactionCode               `xml:"BkTxCd"`
+	Chrgs        *Charges                          `xml:"Chrgs,omitempty"`
+	NtryDtls     *EntryDetails                     `xml:"NtryDtls,omitempty"`
+	AddtlNtryInf string                            `xml:"AddtlNtryInf,omitempty"`
+}
+
+// NewAccount builds a cash account identified by an account number
+func NewAccount(accountNumber string, currency string) *CashAccount {
+	return &CashAccount{Id: AccountIdentification{Othr: &GenericIdentification{Id: accountNumber}}, Ccy: currency}
+}
+
+// NewAgent builds an institution identified by a bank number, nil for this bank
+func NewAgent(bankNumber string) *BranchAndFinancialInstitutionIdentification {
+	if bankNumber == "" {
+		return nil
+	}
+	return &BranchAndFinancialInstitutionIdentification{FinInstnId: FinancialInstitutionIdentification{Othr: &GenericIdentification{Id: bankNumber}}}
+}
@@ -0,0 +1,20 @@
+package iso20022
+
+import (
+	"encoding/xml"
+)
+
+const CAMT_052_NAMESPACE = "urn:iso:std:iso:20022:tech:xsd:camt.052.001.05"
+
+// Camt052Document is a BankToCustomerAccountReportV05
+type Camt052Document struct {
+	XMLName          xml.Name                       `xml:"Document"`
+	Xmlns            string                         `xml:"xmlns,attr"`
+	BkToCstmrAcctRpt BankToCustomerAccountReportV05 `xml:"BkToCstmrAcctRpt"`
+}
+
+// An intraday report carries the same elements as a statement
+type BankToCustomerAccountReportV05 struct {
+	GrpHdr ReportGroupHeader  `xml:"GrpHdr"`
+	Rpt    []AccountStatement `xml:"Rpt"`
+}
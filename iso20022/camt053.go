@@ -0,0 +1,29 @@
+package iso20022
+
+import (
+	"encoding/xml"
+)
+
+const CAMT_053_NAMESPACE = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.05"
+
+// Camt053Document is a BankToCustomerStatementV05
+type Camt053Document struct {
+	XMLName       xml.Name                   `xml:"Document"`
+	Xmlns         string                     `xml:"xmlns,attr"`
+	BkToCstmrStmt BankToCustomerStatementV05 `xml:"BkToCstmrStmt"`
+}
+
+type BankToCustomerStatementV05 struct {
+	GrpHdr ReportGroupHeader  `xml:"GrpHdr"`
+	Stmt   []AccountStatement `xml:"Stmt"`
+}
+
+type AccountStatement struct {
+	Id        string             `xml:"Id"`
+	CreDtTm   string             `xml:"CreDtTm"`
+	FrToDt    *DateTimePeriod    `xml:"FrToDt,omitempty"`
+	Acct      CashAccount        `xml:"Acct"`
+	Bal       []CashBalance      `xml:"Bal"`
+	TxsSummry *TotalTransactions `xml:"TxsSummry,omitempty"`
+	Ntry      []ReportEntry      `xml:"Ntry,omitempty"`
+}
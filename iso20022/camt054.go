@@ -0,0 +1,26 @@
+package iso20022
+
+import (
+	"encoding/xml"
+)
+
+const CAMT_054_NAMESPACE = "urn:iso:std:iso:20022:tech:xsd:camt.054.001.05"
+
+// Camt054Document is a BankToCustomerDebitCreditNotificationV05
+type Camt054Document struct {
+	XMLName               xml.Name                                 `xml:"Document"`
+	Xmlns                 string                                   `xml:"xmlns,attr"`
+	BkToCstmrDbtCdtNtfctn BankToCustomerDebitCreditNotificationV05 `xml:"BkToCstmrDbtCdtNtfctn"`
+}
+
+type BankToCustomerDebitCreditNotificationV05 struct {
+	GrpHdr ReportGroupHeader     `xml:"GrpHdr"`
+	Ntfctn []AccountNotification `xml:"Ntfctn"`
+}
+
+type AccountNotification struct {
+	Id      string        `xml:"Id"`
+	CreDtTm string        `xml:"CreDtTm"`
+	Acct    CashAccount   `xml:"Acct"`
+	Ntry    []ReportEntry `xml:"Ntry,omitempty"`
+}
@@ -0,0 +1,146 @@
+// Package iso20022 holds the XML documents of the ISO 20022 messages the bank speaks.
+// It only encodes, decodes and checks the structure of documents; the packages that
+// process the messages map them onto their own types.
+package iso20022
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ISO 20022 status reason codes (ExternalStatusReason1Code) for documents that fail validation
+const (
+	REASON_INVALID_FILE_FORMAT    = "FF01"
+	REASON_INVALID_CONTROL_SUM    = "AM10"
+	REASON_INVALID_NUMBER_OF_TXS  = "AM18"
+	REASON_MISSING_MANDATORY_INFO = "RR04"
+)
+
+// ISODateTime formats times as ISO 20022 ISODateTime
+func ISODateTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05")
+}
+
+// ISODate formats times as ISO 20022 ISODate
+func ISODate(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// MessageID turns a UUID into a message identification, which is limited to 35 characters
+func MessageID(uuid string) string {
+	return strings.Replace(uuid, "-", "", -1)
+}
+
+// Marshal encodes a document with the XML header
+func Marshal(document interface{}) (result string, err error) {
+	out, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return "", errors.New("iso20022.Marshal: " + err.Error())
+	}
+	return xml.Header + string(out), nil
+}
+
+type ActiveOrHistoricCurrencyAndAmount struct {
+	Value string `xml:",chardata"`
+	Ccy   string `xml:"Ccy,attr"`
+}
+
+type GenericIdentification struct {
+	Id string `xml:"Id"`
+}
+
+type AccountIdentification struct {
+	IBAN string                 `xml:"IBAN,omitempty"`
+	Othr *GenericIdentification `xml:"Othr,omitempty"`
+}
+
+// ID is the IBAN or other identification of the account
+func (a AccountIdentification) ID() string {
+	if a.IBAN != "" {
+		return a.IBAN
+	}
+	if a.Othr != nil {
+		return a.Othr.Id
+	}
+	return ""
+}
+
+type CashAccount struct {
+	Id  AccountIdentification `xml:"Id"`
+	Ccy string                `xml:"Ccy,omitempty"`
+	Nm  string                `xml:"Nm,omitempty"`
+}
+
+type FinancialInstitutionIdentification struct {
+	BICFI string                 `xml:"BICFI,omitempty"`
+	Nm    string                 `xml:"Nm,omitempty"`
+	Othr  *GenericIdentification `xml:"Othr,omitempty"`
+}
+
+type BranchAndFinancialInstitutionIdentification struct {
+	FinInstnId FinancialInstitutionIdentification `xml:"FinInstnId"`
+}
+
+// ID is the BIC or other identification of the institution
+func (b *BranchAndFinancialInstitutionIdentification) ID() string {
+	if b == nil {
+		return ""
+	}
+	if b.FinInstnId.BICFI != "" {
+		return b.FinInstnId.BICFI
+	}
+	if b.FinInstnId.Othr != nil {
+		return b.FinInstnId.Othr.Id
+	}
+	return ""
+}
+
+type PartyIdentification struct {
+	Nm string `xml:"Nm,omitempty"`
+}
+
+type RemittanceInformation struct {
+	Ustrd []string `xml:"Ustrd,omitempty"`
+}
+
+type StatusReason struct {
+	Cd string `xml:"Cd"`
+}
+
+type StatusReasonInformation struct {
+	Rsn      StatusReason `xml:"Rsn"`
+	AddtlInf []string     `xml:"AddtlInf,omitempty"`
+}
+
+// checkControlSum compares the number of transactions and, when given, the control sum of a
+// group against its amounts
+func checkControlSum(nbOfTxs string, ctrlSum string, amounts []string) (reasonCode string, err error) {
+	if nbOfTxs != "" && strings.TrimSpace(nbOfTxs) != decimal.New(int64(len(amounts)), 0).String() {
+		return REASON_INVALID_NUMBER_OF_TXS, errors.New("iso20022.checkControlSum: Number of transactions " + nbOfTxs + " does not match")
+	}
+	if ctrlSum == "" {
+		return
+	}
+
+	expected, err := decimal.NewFromString(strings.TrimSpace(ctrlSum))
+	if err != nil {
+		return REASON_INVALID_CONTROL_SUM, errors.New("iso20022.checkControlSum: Control sum not valid. " + err.Error())
+	}
+	sum := decimal.Zero
+	for _, amount := range amounts {
+		value, err := decimal.NewFromString(strings.TrimSpace(amount))
+		if err != nil {
+			// Amounts are checked one by one when the transactions are processed
+			continue
+		}
+		sum = sum.Add(value)
+	}
+	if !sum.Equal(expected) {
+		return REASON_INVALID_CONTROL_SUM, errors.New("iso20022.checkControlSum: Control sum " + ctrlSum + " does not match " + sum.String())
+	}
+	return
+}
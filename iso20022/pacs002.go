@@ -0,0 +1,66 @@
+package iso20022
+
+import (
+	"encoding/xml"
+	"errors"
+)
+
+const PACS_002_NAMESPACE = "urn:iso:std:iso:20022:tech:xsd:pacs.002.001.07"
+const PACS_002_MESSAGE = "pacs.002.001.07"
+
+// Pacs002Document is a FIToFIPaymentStatusReportV07
+type Pacs002Document struct {
+	XMLName         xml.Name                     `xml:"Document"`
+	Xmlns           string                       `xml:"xmlns,attr"`
+	FIToFIPmtStsRpt FIToFIPaymentStatusReportV07 `xml:"FIToFIPmtStsRpt"`
+}
+
+type FIToFIPaymentStatusReportV07 struct {
+	GrpHdr            StatusGroupHeader                   `xml:"GrpHdr"`
+	OrgnlGrpInfAndSts OriginalGroupHeader                 `xml:"OrgnlGrpInfAndSts"`
+	TxInfAndSts       []InterbankPaymentTransactionStatus `xml:"TxInfAndSts,omitempty"`
+}
+
+type InterbankPaymentTransactionStatus struct {
+	OrgnlInstrId    string                    `xml:"OrgnlInstrId,omitempty"`
+	OrgnlEndToEndId string                    `xml:"OrgnlEndToEndId,omitempty"`
+	OrgnlTxId       string                    `xml:"OrgnlTxId,omitempty"`
+	TxSts           string                    `xml:"TxSts,omitempty"`
+	StsRsnInf       []StatusReasonInformation `xml:"StsRsnInf,omitempty"`
+	AcctSvcrRef     string                    `xml:"AcctSvcrRef,omitempty"`
+}
+
+// ParsePacs002 decodes a pacs.002.001.07 document
+func ParsePacs002(document []byte) (doc Pacs002Document, err error) {
+	err = xml.Unmarshal(document, &doc)
+	if err != nil {
+		return Pacs002Document{}, errors.New("iso20022.ParsePacs002: Could not parse document. " + err.Error())
+	}
+	if doc.XMLName.Space != PACS_002_NAMESPACE {
+		return Pacs002Document{}, errors.New("iso20022.ParsePacs002: Document is not " + PACS_002_MESSAGE)
+	}
+	return
+}
+
+// TransactionStatus finds the status of a transaction by its transaction identification. A
+// transaction without a status of its own has the status of its group
+func (d *Pacs002Document) TransactionStatus(txID string) (status string, reasonCode string) {
+	report := d.FIToFIPmtStsRpt
+	for _, transaction := range report.TxInfAndSts {
+		if transaction.OrgnlTxId != txID {
+			continue
+		}
+		if transaction.TxSts != "" {
+			status = transaction.TxSts
+			if len(transaction.StsRsnInf) > 0 {
+				reasonCode = transaction.StsRsnInf[0].Rsn.Cd
+			}
+			return
+		}
+	}
+	status = report.OrgnlGrpInfAndSts.GrpSts
+	if len(report.OrgnlGrpInfAndSts.StsRsnInf) > 0 {
+		reasonCode = report.OrgnlGrpInfAndSts.StsRsnInf[0].Rsn.Cd
+	}
+	return
+}
@@ -0,0 +1,131 @@
+package iso20022
+
+import (
+	"encoding/xml"
+	"errors"
+)
+
+const PACS_008_NAMESPACE = "urn:iso:std:iso:20022:tech:xsd:pacs.008.001.05"
+const PACS_008_MESSAGE = "pacs.008.001.05"
+
+// Settlement methods (SettlementMethod1Code). INDA settles on the account the instructed agent
+// holds for the instructing agent
+const (
+	SETTLEMENT_INSTRUCTED_AGENT = "INDA"
+	SETTLEMENT_CLEARING         = "CLRG"
+)
+
+// Charge bearer following the service level
+const CHARGES_SERVICE_LEVEL = "SLEV"
+
+// Pacs008Document is a FIToFICustomerCreditTransferV05
+type Pacs008Document struct {
+	XMLName           xml.Name                        `xml:"Document"`
+	Xmlns             string                          `xml:"xmlns,attr"`
+	FIToFICstmrCdtTrf FIToFICustomerCreditTransferV05 `xml:"FIToFICstmrCdtTrf"`
+}
+
+type FIToFICustomerCreditTransferV05 struct {
+	GrpHdr      InterbankGroupHeader                 `xml:"GrpHdr"`
+	CdtTrfTxInf []InterbankCreditTransferTransaction `xml:"CdtTrfTxInf"`
+}
+
+type SettlementInstruction struct {
+	SttlmMtd string `xml:"SttlmMtd"`
+}
+
+type InterbankGroupHeader struct {
+	MsgId             string                                       `xml:"MsgId"`
+	CreDtTm           string                                       `xml:"CreDtTm"`
+	NbOfTxs           string                                       `xml:"NbOfTxs"`
+	TtlIntrBkSttlmAmt *ActiveOrHistoricCurrencyAndAmount           `xml:"TtlIntrBkSttlmAmt,omitempty"`
+	IntrBkSttlmDt     string                                       `xml:"IntrBkSttlmDt,omitempty"`
+	SttlmInf          SettlementInstruction                        `xml:"SttlmInf"`
+	InstgAgt          *BranchAndFinancialInstitutionIdentification `xml:"InstgAgt,omitempty"`
+	InstdAgt          *BranchAndFinancialInstitutionIdentification `xml:"InstdAgt,omitempty"`
+}
+
+type InterbankPaymentIdentification struct {
+	InstrId    string `xml:"InstrId,omitempty"`
+	EndToEndId string `xml:"EndToEndId"`
+	TxId       string `xml:"TxId"`
+}
+
+type InterbankCreditTransferTransaction struct {
+	PmtId          InterbankPaymentIdentification               `xml:"PmtId"`
+	IntrBkSttlmAmt ActiveOrHistoricCurrencyAndAmount            `xml:"IntrBkSttlmAmt"`
+	ChrgBr         string                                       `xml:"ChrgBr"`
+	Dbtr           PartyIdentification                          `xml:"Dbtr"`
+	DbtrAcct       *CashAccount                                 `xml:"DbtrAcct,omitempty"`
+	DbtrAgt        *BranchAndFinancialInstitutionIdentification `xml:"DbtrAgt"`
+	CdtrAgt        *BranchAndFinancialInstitutionIdentification `xml:"CdtrAgt"`
+	Cdtr           PartyIdentification                          `xml:"Cdtr"`
+	CdtrAcct       *CashAccount                                 `xml:"CdtrAcct,omitempty"`
+	RmtInf         *RemittanceInformation                       `xml:"RmtInf,omitempty"`
+}
+
+// ParsePacs008 decodes a pacs.008.001.05 document
+func ParsePacs008(document []byte) (doc Pacs008Document, err error) {
+	err = xml.Unmarshal(document, &doc)
+	if err != nil {
+		return Pacs008Document{}, errors.New("iso20022.ParsePacs008: Could not parse document. " + err.Error())
+	}
+	if doc.XMLName.Space != PACS_008_NAMESPACE {
+		return Pacs008Document{}, errors.New("iso20022.ParsePacs008: Document is not " + PACS_008_MESSAGE)
+	}
+	return
+}
+
+// Validate checks the mandatory fields and the transaction count and total. The reason code says
+// why the document as a whole has to be rejected
+func (d *Pacs008Document) Validate() (reasonCode string, err error) {
+	transfer := d.FIToFICstmrCdtTrf
+	if transfer.GrpHdr.MsgId == "" {
+		return REASON_MISSING_MANDATORY_INFO, errors.New("iso20022.Validate: Message identification missing")
+	}
+	if transfer.GrpHdr.SttlmInf.SttlmMtd == "" {
+		return REASON_MISSING_MANDATORY_INFO, errors.New("iso20022.Validate: Settlement method missing")
+	}
+	if len(transfer.CdtTrfTxInf) == 0 {
+		return REASON_MISSING_MANDATORY_INFO, errors.New("iso20022.Validate: No transactions")
+	}
+
+	amounts := []string{}
+	for _, transaction := range transfer.CdtTrfTxInf {
+		if transaction.PmtId.TxId == "" || transaction.PmtId.EndToEndId == "" {
+			return REASON_MISSING_MANDATORY_INFO, errors.New("iso20022.Validate: Transaction identification missing")
+		}
+		if transaction.CdtrAcct == nil || transaction.CdtrAcct.Id.ID() == "" {
+			return REASON_MISSING_MANDATORY_INFO, errors.New("iso20022.Validate: Creditor account missing in " + transaction.PmtId.TxId)
+		}
+		amounts = append(amounts, transaction.IntrBkSttlmAmt.Value)
+	}
+
+	if transfer.GrpHdr.NbOfTxs == "" {
+		return REASON_MISSING_MANDATORY_INFO, errors.New("iso20022.Validate: Number of transactions missing")
+	}
+	total := ""
+	if transfer.GrpHdr.TtlIntrBkSttlmAmt != nil {
+		total = transfer.GrpHdr.TtlIntrBkSttlmAmt.Value
+	}
+	reasonCode, err = checkControlSum(transfer.GrpHdr.NbOfTxs, total, amounts)
+	if err != nil {
+		return reasonCode, errors.New("iso20022.Validate: " + err.Error())
+	}
+
+	return "", nil
+}
+
+// Description joins the unstructured remittance information
+func (t *InterbankCreditTransferTransaction) Description() (desc string) {
+	if t.RmtInf == nil {
+		return ""
+	}
+	for i, line := range t.RmtInf.Ustrd {
+		if i > 0 {
+			desc += " "
+		}
+		desc += line
+	}
+	return
+}
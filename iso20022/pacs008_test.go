@@ -0,0 +1,118 @@
+package iso20022
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestParsePacs008(t *testing.T) {
+	document, err := ioutil.ReadFile("testdata/pacs.008.001.05.xml")
+	if err != nil {
+		t.Fatalf("ParsePacs008 does not pass. Could not read fixture. Looking for %v, got %v", nil, err)
+	}
+
+	doc, err := ParsePacs008(document)
+	if err != nil {
+		t.Fatalf("ParsePacs008 does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	groupHeader := doc.FIToFICstmrCdtTrf.GrpHdr
+	if groupHeader.MsgId != "PACS-20160315-0001" {
+		t.Errorf("ParsePacs008 does not pass. Looking for %v, got %v", "PACS-20160315-0001", groupHeader.MsgId)
+	}
+	if groupHeader.InstgAgt.ID() != "other-bank" || groupHeader.InstdAgt.ID() != "this-bank" {
+		t.Errorf("ParsePacs008 does not pass. Looking for %v, got %v", "other-bank this-bank", groupHeader.InstgAgt.ID()+" "+groupHeader.InstdAgt.ID())
+	}
+	if len(doc.FIToFICstmrCdtTrf.CdtTrfTxInf) != 2 {
+		t.Fatalf("ParsePacs008 does not pass. Looking for %v, got %v", 2, len(doc.FIToFICstmrCdtTrf.CdtTrfTxInf))
+	}
+
+	creditTransfer := doc.FIToFICstmrCdtTrf.CdtTrfTxInf[0]
+	if creditTransfer.IntrBkSttlmAmt.Value != "150.25" || creditTransfer.IntrBkSttlmAmt.Ccy != "USD" {
+		t.Errorf("ParsePacs008 does not pass. Looking for %v, got %v", "150.25 USD", creditTransfer.IntrBkSttlmAmt)
+	}
+	if creditTransfer.CdtrAcct.Id.ID() != "181ac0ae-45cb-461d-b740-15ce33e4612f" {
+		t.Errorf("ParsePacs008 does not pass. Looking for %v, got %v", "181ac0ae-45cb-461d-b740-15ce33e4612f", creditTransfer.CdtrAcct.Id.ID())
+	}
+	if creditTransfer.Description() != "Invoice 1001 March" {
+		t.Errorf("ParsePacs008 does not pass. Looking for %v, got %v", "Invoice 1001 March", creditTransfer.Description())
+	}
+	if doc.FIToFICstmrCdtTrf.CdtTrfTxInf[1].CdtrAgt.ID() != "" {
+		t.Errorf("ParsePacs008 does not pass. Looking for %v, got %v", "", doc.FIToFICstmrCdtTrf.CdtTrfTxInf[1].CdtrAgt.ID())
+	}
+
+	reasonCode, err := doc.Validate()
+	if err != nil {
+		t.Errorf("ParsePacs008 validate does not pass. Looking for %v, got %v %v", nil, reasonCode, err)
+	}
+
+	_, err = ParsePacs008([]byte(`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.06"></Document>`))
+	if err == nil {
+		t.Errorf("ParsePacs008 wrong namespace does not pass. Looking for %v, got %v", "error", err)
+	}
+}
+
+func TestValidatePacs008(t *testing.T) {
+	document, err := ioutil.ReadFile("testdata/pacs.008.001.05.xml")
+	if err != nil {
+		t.Fatalf("ValidatePacs008 does not pass. Could not read fixture. Looking for %v, got %v", nil, err)
+	}
+
+	doc, _ := ParsePacs008(document)
+	doc.FIToFICstmrCdtTrf.GrpHdr.TtlIntrBkSttlmAmt.Value = "175.26"
+	reasonCode, err := doc.Validate()
+	if err == nil || reasonCode != REASON_INVALID_CONTROL_SUM {
+		t.Errorf("ValidatePacs008 total does not pass. Looking for %v, got %v", REASON_INVALID_CONTROL_SUM, reasonCode)
+	}
+
+	doc, _ = ParsePacs008(document)
+	doc.FIToFICstmrCdtTrf.GrpHdr.NbOfTxs = "3"
+	reasonCode, err = doc.Validate()
+	if err == nil || reasonCode != REASON_INVALID_NUMBER_OF_TXS {
+		t.Errorf("ValidatePacs008 count does not pass. Looking for %v, got %v", REASON_INVALID_NUMBER_OF_TXS, reasonCode)
+	}
+
+	doc, _ = ParsePacs008(document)
+	doc.FIToFICstmrCdtTrf.CdtTrfTxInf[1].CdtrAcct = nil
+	reasonCode, err = doc.Validate()
+	if err == nil || reasonCode != REASON_MISSING_MANDATORY_INFO {
+		t.Errorf("ValidatePacs008 creditor account does not pass. Looking for %v, got %v", REASON_MISSING_MANDATORY_INFO, reasonCode)
+	}
+}
+
+func TestPacs002TransactionStatus(t *testing.T) {
+	report := Pacs002Document{Xmlns: PACS_002_NAMESPACE}
+	report.FIToFIPmtStsRpt.GrpHdr = StatusGroupHeader{MsgId: "MSG", CreDtTm: "2016-03-15T10:30:00"}
+	report.FIToFIPmtStsRpt.OrgnlGrpInfAndSts = OriginalGroupHeader{OrgnlMsgId: "ORIGINAL", OrgnlMsgNmId: PACS_008_MESSAGE, GrpSts: GROUP_STATUS_PARTIAL}
+	report.FIToFIPmtStsRpt.TxInfAndSts = []InterbankPaymentTransactionStatus{
+		{OrgnlTxId: "101", TxSts: TX_STATUS_SETTLED, AcctSvcrRef: "12"},
+		{OrgnlTxId: "102", TxSts: TX_STATUS_REJECTED, StsRsnInf: NewStatusReason("AC01", "Creditor account not found")},
+	}
+
+	result, err := Marshal(report)
+	if err != nil {
+		t.Fatalf("Pacs002TransactionStatus does not pass. Looking for %v, got %v", nil, err)
+	}
+	parsed, err := ParsePacs002([]byte(result))
+	if err != nil {
+		t.Fatalf("Pacs002TransactionStatus does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	status, reasonCode := parsed.TransactionStatus("101")
+	if status != TX_STATUS_SETTLED || reasonCode != "" {
+		t.Errorf("Pacs002TransactionStatus settled does not pass. Looking for %v, got %v %v", TX_STATUS_SETTLED, status, reasonCode)
+	}
+	status, reasonCode = parsed.TransactionStatus("102")
+	if status != TX_STATUS_REJECTED || reasonCode != "AC01" {
+		t.Errorf("Pacs002TransactionStatus rejected does not pass. Looking for %v, got %v %v", TX_STATUS_REJECTED+" AC01", status, reasonCode)
+	}
+
+	// A group rejected as a whole has no transaction statuses
+	parsed.FIToFIPmtStsRpt.TxInfAndSts = nil
+	parsed.FIToFIPmtStsRpt.OrgnlGrpInfAndSts.GrpSts = GROUP_STATUS_REJECTED
+	parsed.FIToFIPmtStsRpt.OrgnlGrpInfAndSts.StsRsnInf = NewStatusReason(REASON_MISSING_MANDATORY_INFO, "Message identification missing")
+	status, reasonCode = parsed.TransactionStatus("101")
+	if status != GROUP_STATUS_REJECTED || reasonCode != REASON_MISSING_MANDATORY_INFO {
+		t.Errorf("Pacs002TransactionStatus group does not pass. Looking for %v, got %v %v", GROUP_STATUS_REJECTED+" "+REASON_MISSING_MANDATORY_INFO, status, reasonCode)
+	}
+}
@@ -0,0 +1,136 @@
+package iso20022
+
+import (
+	"encoding/xml"
+	"errors"
+)
+
+const PAIN_001_NAMESPACE = "urn:iso:std:iso:20022:tech:xsd:pain.001.001.06"
+const PAIN_001_MESSAGE = "pain.001.001.06"
+
+// Pain001Document is a CustomerCreditTransferInitiationV06
+type Pain001Document struct {
+	XMLName          xml.Name                            `xml:"Document"`
+	CstmrCdtTrfInitn CustomerCreditTransferInitiationV06 `xml:"CstmrCdtTrfInitn"`
+}
+
+type CustomerCreditTransferInitiationV06 struct {
+	GrpHdr CreditTransferGroupHeader `xml:"GrpHdr"`
+	PmtInf []PaymentInstruction      `xml:"PmtInf"`
+}
+
+type CreditTransferGroupHeader struct {
+	MsgId    string              `xml:"MsgId"`
+	CreDtTm  string              `xml:"CreDtTm"`
+	NbOfTxs  string              `xml:"NbOfTxs"`
+	CtrlSum  string              `xml:"CtrlSum,omitempty"`
+	InitgPty PartyIdentification `xml:"InitgPty"`
+}
+
+type PaymentInstruction struct {
+	PmtInfId    string                                       `xml:"PmtInfId"`
+	PmtMtd      string                                       `xml:"PmtMtd"`
+	NbOfTxs     string                                       `xml:"NbOfTxs,omitempty"`
+	CtrlSum     string                                       `xml:"CtrlSum,omitempty"`
+	ReqdExctnDt string                                       `xml:"ReqdExctnDt"`
+	Dbtr        PartyIdentification                          `xml:"Dbtr"`
+	DbtrAcct    CashAccount                                  `xml:"DbtrAcct"`
+	DbtrAgt     *BranchAndFinancialInstitutionIdentification `xml:"DbtrAgt"`
+	CdtTrfTxInf []CreditTransferTransaction                  `xml:"CdtTrfTxInf"`
+}
+
+type PaymentIdentification struct {
+	InstrId    string `xml:"InstrId,omitempty"`
+	EndToEndId string `xml:"EndToEndId"`
+}
+
+type AmountType struct {
+	InstdAmt ActiveOrHistoricCurrencyAndAmount `xml:"InstdAmt"`
+}
+
+type CreditTransferTransaction struct {
+	PmtId    PaymentIdentification                        `xml:"PmtId"`
+	Amt      AmountType                                   `xml:"Amt"`
+	CdtrAgt  *BranchAndFinancialInstitutionIdentification `xml:"CdtrAgt"`
+	Cdtr     PartyIdentification                          `xml:"Cdtr"`
+	CdtrAcct CashAccount                                  `xml:"CdtrAcct"`
+	RmtInf   *RemittanceInformation                       `xml:"RmtInf"`
+}
+
+// ParsePain001 decodes a pain.001.001.06 document
+func ParsePain001(document []byte) (doc Pain001Document, err error) {
+	err = xml.Unmarshal(document, &doc)
+	if err != nil {
+		return Pain001Document{}, errors.New("iso20022.ParsePain001: Could not parse document. " + err.Error())
+	}
+	if doc.XMLName.Space != PAIN_001_NAMESPACE {
+		return Pain001Document{}, errors.New("iso20022.ParsePain001: Document is not " + PAIN_001_MESSAGE)
+	}
+	return
+}
+
+// Validate checks the mandatory fields and the transaction counts and control sums. The reason
+// code says why the document as a whole has to be rejected
+func (d *Pain001Document) Validate() (reasonCode string, err error) {
+	initiation := d.CstmrCdtTrfInitn
+	if initiation.GrpHdr.MsgId == "" {
+		return REASON_MISSING_MANDATORY_INFO, errors.New("iso20022.Validate: Message identification missing")
+	}
+	if len(initiation.PmtInf) == 0 {
+		return REASON_MISSING_MANDATORY_INFO, errors.New("iso20022.Validate: No payment information")
+	}
+
+	allAmounts := []string{}
+	for _, paymentInfo := range initiation.PmtInf {
+		if paymentInfo.PmtInfId == "" {
+			return REASON_MISSING_MANDATORY_INFO, errors.New("iso20022.Validate: Payment information identification missing")
+		}
+		if paymentInfo.PmtMtd != "TRF" {
+			return REASON_INVALID_FILE_FORMAT, errors.New("iso20022.Validate: Payment method must be TRF in " + paymentInfo.PmtInfId)
+		}
+		if paymentInfo.DbtrAcct.Id.ID() == "" {
+			return REASON_MISSING_MANDATORY_INFO, errors.New("iso20022.Validate: Debtor account missing in " + paymentInfo.PmtInfId)
+		}
+		if len(paymentInfo.CdtTrfTxInf) == 0 {
+			return REASON_MISSING_MANDATORY_INFO, errors.New("iso20022.Validate: No transactions in " + paymentInfo.PmtInfId)
+		}
+
+		amounts := []string{}
+		for _, transaction := range paymentInfo.CdtTrfTxInf {
+			if transaction.PmtId.EndToEndId == "" {
+				return REASON_MISSING_MANDATORY_INFO, errors.New("iso20022.Validate: End to end identification missing in " + paymentInfo.PmtInfId)
+			}
+			amounts = append(amounts, transaction.Amt.InstdAmt.Value)
+		}
+
+		reasonCode, err = checkControlSum(paymentInfo.NbOfTxs, paymentInfo.CtrlSum, amounts)
+		if err != nil {
+			return reasonCode, errors.New("iso20022.Validate: " + paymentInfo.PmtInfId + ". " + err.Error())
+		}
+		allAmounts = append(allAmounts, amounts...)
+	}
+
+	if initiation.GrpHdr.NbOfTxs == "" {
+		return REASON_MISSING_MANDATORY_INFO, errors.New("iso20022.Validate: Number of transactions missing")
+	}
+	reasonCode, err = checkControlSum(initiation.GrpHdr.NbOfTxs, initiation.GrpHdr.CtrlSum, allAmounts)
+	if err != nil {
+		return reasonCode, errors.New("iso20022.Validate: " + err.Error())
+	}
+
+	return "", nil
+}
+
+// Description joins the unstructured remittance information
+func (t *CreditTransferTransaction) Description() (desc string) {
+	if t.RmtInf == nil {
+		return ""
+	}
+	for i, line := range t.RmtInf.Ustrd {
+		if i > 0 {
+			desc += " "
+		}
+		desc += line
+	}
+	return
+}
@@ -0,0 +1,87 @@
+package iso20022
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestParsePain001(t *testing.T) {
+	document, err := ioutil.ReadFile("testdata/pain.001.001.06.xml")
+	if err != nil {
+		t.Fatalf("ParsePain001 does not pass. Could not read fixture. Looking for %v, got %v", nil, err)
+	}
+
+	doc, err := ParsePain001(document)
+	if err != nil {
+		t.Fatalf("ParsePain001 does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	if doc.CstmrCdtTrfInitn.GrpHdr.MsgId != "MSG-20160315-0001" {
+		t.Errorf("ParsePain001 does not pass. Looking for %v, got %v", "MSG-20160315-0001", doc.CstmrCdtTrfInitn.GrpHdr.MsgId)
+	}
+	if len(doc.CstmrCdtTrfInitn.PmtInf) != 2 {
+		t.Fatalf("ParsePain001 does not pass. Looking for %v, got %v", 2, len(doc.CstmrCdtTrfInitn.PmtInf))
+	}
+
+	paymentInfo := doc.CstmrCdtTrfInitn.PmtInf[0]
+	if paymentInfo.DbtrAcct.Id.ID() != "1b2ca241-0373-4610-abad-da7b06c50a7b" {
+		t.Errorf("ParsePain001 does not pass. Looking for %v, got %v", "1b2ca241-0373-4610-abad-da7b06c50a7b", paymentInfo.DbtrAcct.Id.ID())
+	}
+
+	creditTransfer := paymentInfo.CdtTrfTxInf[0]
+	if creditTransfer.Amt.InstdAmt.Value != "150.25" || creditTransfer.Amt.InstdAmt.Ccy != "USD" {
+		t.Errorf("ParsePain001 does not pass. Looking for %v, got %v", "150.25 USD", creditTransfer.Amt.InstdAmt)
+	}
+	if creditTransfer.CdtrAgt.ID() != "" {
+		t.Errorf("ParsePain001 does not pass. Looking for %v, got %v", "", creditTransfer.CdtrAgt.ID())
+	}
+	if creditTransfer.Description() != "Invoice 1001 March" {
+		t.Errorf("ParsePain001 does not pass. Looking for %v, got %v", "Invoice 1001 March", creditTransfer.Description())
+	}
+	if paymentInfo.CdtTrfTxInf[1].CdtrAgt.ID() != "other-bank" {
+		t.Errorf("ParsePain001 does not pass. Looking for %v, got %v", "other-bank", paymentInfo.CdtTrfTxInf[1].CdtrAgt.ID())
+	}
+
+	_, err = ParsePain001([]byte(`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.008.001.05"></Document>`))
+	if err == nil {
+		t.Errorf("ParsePain001 namespace does not pass. Looking for %v, got %v", "Document is not pain.001.001.06", nil)
+	}
+
+	_, err = ParsePain001([]byte("not xml"))
+	if err == nil {
+		t.Errorf("ParsePain001 format does not pass. Looking for %v, got %v", "Could not parse document", nil)
+	}
+}
+
+func TestValidatePain001(t *testing.T) {
+	document, _ := ioutil.ReadFile("testdata/pain.001.001.06.xml")
+	doc, err := ParsePain001(document)
+	if err != nil {
+		t.Fatalf("ValidatePain001 does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	reasonCode, err := doc.Validate()
+	if err != nil {
+		t.Errorf("ValidatePain001 does not pass. Looking for %v, got %v", nil, err)
+	}
+	if reasonCode != "" {
+		t.Errorf("ValidatePain001 does not pass. Looking for %v, got %v", "", reasonCode)
+	}
+
+	document, _ = ioutil.ReadFile("testdata/pain.001.001.06-bad-ctrlsum.xml")
+	doc, err = ParsePain001(document)
+	if err != nil {
+		t.Fatalf("ValidatePain001 does not pass. Looking for %v, got %v", nil, err)
+	}
+	reasonCode, err = doc.Validate()
+	if err == nil || reasonCode != REASON_INVALID_CONTROL_SUM {
+		t.Errorf("ValidatePain001 control sum does not pass. Looking for %v, got %v", REASON_INVALID_CONTROL_SUM, reasonCode)
+	}
+
+	doc.CstmrCdtTrfInitn.GrpHdr.CtrlSum = ""
+	doc.CstmrCdtTrfInitn.GrpHdr.NbOfTxs = "4"
+	reasonCode, err = doc.Validate()
+	if err == nil || reasonCode != REASON_INVALID_NUMBER_OF_TXS {
+		t.Errorf("ValidatePain001 number of transactions does not pass. Looking for %v, got %v", REASON_INVALID_NUMBER_OF_TXS, reasonCode)
+	}
+}
@@ -0,0 +1,104 @@
+package iso20022
+
+import (
+	"encoding/xml"
+)
+
+const PAIN_002_NAMESPACE = "urn:iso:std:iso:20022:tech:xsd:pain.002.001.07"
+
+// Group and payment information statuses (ExternalPaymentGroupStatus1Code)
+const (
+	GROUP_STATUS_ACCEPTED = "ACCP"
+	GROUP_STATUS_SETTLED  = "ACSC"
+	GROUP_STATUS_PARTIAL  = "PART"
+	GROUP_STATUS_REJECTED = "RJCT"
+)
+
+// Transaction statuses (ExternalPaymentTransactionStatus1Code)
+const (
+	TX_STATUS_RECEIVED = "RCVD"
+	TX_STATUS_PENDING  = "PDNG"
+	TX_STATUS_ACCEPTED = "ACCP"
+	TX_STATUS_SETTLED  = "ACSC"
+	TX_STATUS_REJECTED = "RJCT"
+)
+
+// Pain002Document is a CustomerPaymentStatusReportV07
+type Pain002Document struct {
+	XMLName        xml.Name                       `xml:"Document"`
+	Xmlns          string                         `xml:"xmlns,attr"`
+	CstmrPmtStsRpt CustomerPaymentStatusReportV07 `xml:"CstmrPmtStsRpt"`
+}
+
+type CustomerPaymentStatusReportV07 struct {
+	GrpHdr            StatusGroupHeader                     `xml:"GrpHdr"`
+	OrgnlGrpInfAndSts OriginalGroupHeader                   `xml:"OrgnlGrpInfAndSts"`
+	OrgnlPmtInfAndSts []OriginalPaymentInstructionAndStatus `xml:"OrgnlPmtInfAndSts,omitempty"`
+}
+
+type StatusGroupHeader struct {
+	MsgId   string `xml:"MsgId"`
+	CreDtTm string `xml:"CreDtTm"`
+}
+
+type OriginalGroupHeader struct {
+	OrgnlMsgId   string                    `xml:"OrgnlMsgId"`
+	OrgnlMsgNmId string                    `xml:"OrgnlMsgNmId"`
+	OrgnlNbOfTxs string                    `xml:"OrgnlNbOfTxs,omitempty"`
+	OrgnlCtrlSum string                    `xml:"OrgnlCtrlSum,omitempty"`
+	GrpSts       string                    `xml:"GrpSts,omitempty"`
+	StsRsnInf    []StatusReasonInformation `xml:"StsRsnInf,omitempty"`
+}
+
+type OriginalPaymentInstructionAndStatus struct {
+	OrgnlPmtInfId string                     `xml:"OrgnlPmtInfId"`
+	PmtInfSts     string                     `xml:"PmtInfSts,omitempty"`
+	TxInfAndSts   []PaymentTransactionStatus `xml:"TxInfAndSts,omitempty"`
+}
+
+type PaymentTransactionStatus struct {
+	OrgnlInstrId    string                    `xml:"OrgnlInstrId,omitempty"`
+	OrgnlEndToEndId string                    `xml:"OrgnlEndToEndId,omitempty"`
+	TxSts           string                    `xml:"TxSts,omitempty"`
+	StsRsnInf       []StatusReasonInformation `xml:"StsRsnInf,omitempty"`
+	AcctSvcrRef     string                    `xml:"AcctSvcrRef,omitempty"`
+}
+
+// NewStatusReason builds the reason information for a status
+func NewStatusReason(reasonCode string, info string) []StatusReasonInformation {
+	if reasonCode == "" {
+		return nil
+	}
+	reason := StatusReasonInformation{Rsn: StatusReason{Cd: reasonCode}}
+	// Additional information is limited to 105 characters
+	if len(info) > 105 {
+		info = info[:105]
+	}
+	if info != "" {
+		reason.AddtlInf = []string{info}
+	}
+	return []StatusReasonInformation{reason}
+}
+
+// GroupStatus sums up the statuses of the transactions in a group: settled or accepted when
+// all are, rejected when all are rejected and partially accepted otherwise
+func GroupStatus(statuses []string) string {
+	rejected, settled := 0, 0
+	for _, status := range statuses {
+		switch status {
+		case TX_STATUS_REJECTED:
+			rejected++
+		case TX_STATUS_SETTLED:
+			settled++
+		}
+	}
+	switch {
+	case len(statuses) == 0 || rejected == len(statuses):
+		return GROUP_STATUS_REJECTED
+	case rejected > 0:
+		return GROUP_STATUS_PARTIAL
+	case settled == len(statuses):
+		return GROUP_STATUS_SETTLED
+	}
+	return GROUP_STATUS_ACCEPTED
+}
@@ -0,0 +1,66 @@
+package iso20022
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGroupStatus(t *testing.T) {
+	tests := map[string][]string{
+		GROUP_STATUS_SETTLED:  {TX_STATUS_SETTLED, TX_STATUS_SETTLED},
+		GROUP_STATUS_ACCEPTED: {TX_STATUS_SETTLED, TX_STATUS_ACCEPTED},
+		GROUP_STATUS_PARTIAL:  {TX_STATUS_SETTLED, TX_STATUS_REJECTED},
+		GROUP_STATUS_REJECTED: {TX_STATUS_REJECTED, TX_STATUS_REJECTED},
+	}
+	for expected, statuses := range tests {
+		if GroupStatus(statuses) != expected {
+			t.Errorf("GroupStatus %v does not pass. Looking for %v, got %v", statuses, expected, GroupStatus(statuses))
+		}
+	}
+}
+
+func TestMarshalPain002(t *testing.T) {
+	report := Pain002Document{Xmlns: PAIN_002_NAMESPACE}
+	report.CstmrPmtStsRpt.GrpHdr = StatusGroupHeader{MsgId: "MSG", CreDtTm: "2016-03-15T10:30:00"}
+	report.CstmrPmtStsRpt.OrgnlGrpInfAndSts = OriginalGroupHeader{OrgnlMsgId: "ORIGINAL", OrgnlMsgNmId: PAIN_001_MESSAGE, GrpSts: GROUP_STATUS_PARTIAL}
+	report.CstmrPmtStsRpt.OrgnlPmtInfAndSts = []OriginalPaymentInstructionAndStatus{
+		{
+			OrgnlPmtInfId: "PMT-0001",
+			PmtInfSts:     GROUP_STATUS_PARTIAL,
+			TxInfAndSts: []PaymentTransactionStatus{
+				{OrgnlEndToEndId: "E2E-0001", TxSts: TX_STATUS_SETTLED, AcctSvcrRef: "12"},
+				{OrgnlEndToEndId: "E2E-0002", TxSts: TX_STATUS_REJECTED, StsRsnInf: NewStatusReason("AM04", "Insufficient funds")},
+			},
+		},
+	}
+
+	result, err := Marshal(report)
+	if err != nil {
+		t.Fatalf("MarshalPain002 does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	expected := []string{
+		`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.002.001.07">`,
+		`<OrgnlMsgNmId>pain.001.001.06</OrgnlMsgNmId>`,
+		`<GrpSts>PART</GrpSts>`,
+		`<TxSts>ACSC</TxSts>`,
+		`<Cd>AM04</Cd>`,
+		`<AcctSvcrRef>12</AcctSvcrRef>`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(result, e) {
+			t.Errorf("MarshalPain002 does not pass. Looking for %v, got %v", e, result)
+		}
+	}
+}
+
+func TestNewStatusReason(t *testing.T) {
+	if NewStatusReason("", "info") != nil {
+		t.Errorf("NewStatusReason does not pass. Looking for %v, got %v", nil, NewStatusReason("", "info"))
+	}
+
+	reason := NewStatusReason("NARR", strings.Repeat("a", 200))
+	if len(reason[0].AddtlInf[0]) != 105 {
+		t.Errorf("NewStatusReason does not pass. Looking for %v, got %v", 105, len(reason[0].AddtlInf[0]))
+	}
+}
@@ -0,0 +1,163 @@
+package iso8583
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Field formats
+const (
+	FIXED  = iota
+	LLVAR  // two digit length, then the value
+	LLLVAR // three digit length, then the value
+)
+
+// Field character sets
+const (
+	NUMERIC      = "n"
+	ALPHANUMERIC = "an"
+	ANY          = "ans"
+)
+
+type field struct {
+	Format  int
+	Length  int
+	Charset string
+}
+
+// Fields of the 1987 version the bank supports. Anything else in a message is an error
+var fields1987 = map[int]field{
+	2:  {LLVAR, 19, NUMERIC},      // Primary account number
+	3:  {FIXED, 6, NUMERIC},       // Processing code
+	4:  {FIXED, 12, NUMERIC},      // Amount, transaction, in minor units
+	7:  {FIXED, 10, NUMERIC},      // Transmission date and time, MMDDhhmmss
+	11: {FIXED, 6, NUMERIC},       // System trace audit number (STAN)
+	12: {FIXED, 6, NUMERIC},       // Local transaction time, hhmmss
+	13: {FIXED, 4, NUMERIC},       // Local transaction date, MMDD
+	14: {FIXED, 4, NUMERIC},       // Expiration date, YYMM
+	18: {FIXED, 4, NUMERIC},       // Merchant category code
+	22: {FIXED, 3, NUMERIC},       // POS entry mode
+	24: {FIXED, 3, NUMERIC},       // Network international identifier
+	25: {FIXED, 2, NUMERIC},       // POS condition code
+	32: {LLVAR, 11, NUMERIC},      // Acquiring institution ID
+	37: {FIXED, 12, ALPHANUMERIC}, // Retrieval reference number
+	38: {FIXED, 6, ALPHANUMERIC},  // Authorisation ID response
+	39: {FIXED, 2, ALPHANUMERIC},  // Response code
+	41: {FIXED, 8, ANY},           // Card acceptor terminal ID
+	42: {FIXED, 15, ANY},          // Card acceptor ID
+	43: {FIXED, 40, ANY},          // Card acceptor name and location
+	49: {FIXED, 3, NUMERIC},       // Currency code, transaction (ISO 4217 numeric)
+	70: {FIXED, 3, NUMERIC},       // Network management information code
+	90: {FIXED, 42, NUMERIC},      // Original data elements
+	// Account identification 1. The standard allows 28 characters, account numbers here are
+	// 36 character UUIDs
+	102: {LLVAR, 36, ANY},
+}
+
+// Fields of the 1993 version that differ from the 1987 version
+var fields1993 = map[int]field{
+	12: {FIXED, 12, NUMERIC},      // Date and time, local transaction, YYMMDDhhmmss
+	22: {FIXED, 12, ALPHANUMERIC}, // POS data code
+	24: {FIXED, 3, NUMERIC},       // Function code
+	39: {FIXED, 3, NUMERIC},       // Action code
+	43: {LLVAR, 99, ANY},          // Card acceptor name and location
+	56: {LLVAR, 35, NUMERIC},      // Original data elements
+	70: {},
+	90: {},
+}
+
+func fieldSpec(version byte, number int) (spec field, ok bool) {
+	if version == VERSION_1993 {
+		spec, ok = fields1993[number]
+		if ok {
+			// Fields dropped in 1993 have no length
+			return spec, spec.Length > 0
+		}
+	}
+	spec, ok = fields1987[number]
+	return
+}
+
+func (f field) encode(value string) (encoded []byte, err error) {
+	err = f.check(value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch f.Format {
+	case LLVAR:
+		return []byte(PadNumber(strconv.Itoa(len(value)), 2) + value), nil
+	case LLLVAR:
+		return []byte(PadNumber(strconv.Itoa(len(value)), 3) + value), nil
+	}
+	return []byte(value), nil
+}
+
+// decode reads the field from the start of packed, returning how many bytes it took
+func (f field) decode(packed []byte) (value string, read int, err error) {
+	length := f.Length
+	switch f.Format {
+	case LLVAR, LLLVAR:
+		digits := 2
+		if f.Format == LLLVAR {
+			digits = 3
+		}
+		if len(packed) < digits {
+			return "", 0, errors.New("Length missing")
+		}
+		length, err = strconv.Atoi(string(packed[:digits]))
+		if err != nil || length > f.Length {
+			return "", 0, errors.New("Length " + string(packed[:digits]) + " not valid")
+		}
+		packed = packed[digits:]
+		read = digits
+	}
+
+	if len(packed) < length {
+		return "", 0, errors.New("Value too short")
+	}
+	value = string(packed[:length])
+	err = f.check(value)
+	if err != nil {
+		return "", 0, err
+	}
+	return value, read + length, nil
+}
+
+func (f field) check(value string) error {
+	if f.Format == FIXED && len(value) != f.Length {
+		return errors.New("Must be " + strconv.Itoa(f.Length) + " characters")
+	}
+	if len(value) > f.Length {
+		return errors.New("Must be at most " + strconv.Itoa(f.Length) + " characters")
+	}
+
+	switch f.Charset {
+	case NUMERIC:
+		if !isNumeric(value) {
+			return errors.New("Must be numeric")
+		}
+	case ALPHANUMERIC:
+		for _, c := range value {
+			if !(c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == ' ') {
+				return errors.New("Must be alphanumeric")
+			}
+		}
+	default:
+		for _, c := range value {
+			if c < ' ' || c > '~' {
+				return errors.New("Must be printable")
+			}
+		}
+	}
+	return nil
+}
+
+func isNumeric(value string) bool {
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,283 @@
+// Package iso8583 encodes and decodes the ISO 8583 card messages the bank speaks, in the
+// 1987 and 1993 versions. Like the iso20022 package it only deals with the structure of
+// messages; the packages that process them map them onto their own types.
+//
+// A message on the wire is a two byte big-endian length, then the message type indicator
+// (MTI), the binary bitmap of the fields present and the fields themselves in ASCII.
+package iso8583
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Versions, the first digit of the MTI
+const (
+	VERSION_1987 = '0'
+	VERSION_1993 = '1'
+)
+
+// Message classes, the second digit of the MTI
+const (
+	CLASS_AUTHORISATION = '1'
+	CLASS_FINANCIAL     = '2'
+	CLASS_REVERSAL      = '4'
+	CLASS_NETWORK       = '8'
+)
+
+// Message functions, the third digit of the MTI
+const (
+	FUNCTION_REQUEST         = '0'
+	FUNCTION_RESPONSE        = '1'
+	FUNCTION_ADVICE          = '2'
+	FUNCTION_ADVICE_RESPONSE = '3'
+)
+
+// Fields the bank reads or writes
+const (
+	FIELD_PAN                = 2
+	FIELD_PROCESSING_CODE    = 3
+	FIELD_AMOUNT             = 4
+	FIELD_TRANSMISSION_TIME  = 7
+	FIELD_STAN               = 11
+	FIELD_LOCAL_TIME         = 12
+	FIELD_EXPIRY             = 14
+	FIELD_FUNCTION_CODE      = 24
+	FIELD_ACQUIRER_ID        = 32
+	FIELD_RRN                = 37
+	FIELD_AUTHORISATION_CODE = 38
+	FIELD_RESPONSE_CODE      = 39
+	FIELD_TERMINAL_ID        = 41
+	FIELD_CARD_ACCEPTOR_ID   = 42
+	FIELD_CARD_ACCEPTOR_NAME = 43
+	FIELD_CURRENCY           = 49
+	FIELD_ORIGINAL_DATA_1993 = 56
+	FIELD_NETWORK_CODE       = 70
+	FIELD_ORIGINAL_DATA_1987 = 90
+	FIELD_ACCOUNT_ID         = 102
+)
+
+// MAX_MESSAGE_SIZE is the most the two byte length prefix can frame
+const MAX_MESSAGE_SIZE = 65535
+
+// Message is a card message. Fields holds the value of each field present by number; the
+// bitmaps (field 1 and the primary bitmap) follow from the fields and are not in it
+type Message struct {
+	MTI    string
+	Fields map[int]string
+}
+
+// NewMessage returns an empty message of type mti
+func NewMessage(mti string) Message {
+	return Message{MTI: mti, Fields: map[int]string{}}
+}
+
+// Version is VERSION_1987 or VERSION_1993
+func (m Message) Version() byte {
+	return m.mtiDigit(0)
+}
+
+func (m Message) Class() byte {
+	return m.mtiDigit(1)
+}
+
+func (m Message) Function() byte {
+	return m.mtiDigit(2)
+}
+
+func (m Message) mtiDigit(i int) byte {
+	if len(m.MTI) != 4 {
+		return 0
+	}
+	return m.MTI[i]
+}
+
+// Get returns the value of a field, empty when it is not present
+func (m Message) Get(field int) string {
+	return m.Fields[field]
+}
+
+// Has reports whether a field is present
+func (m Message) Has(field int) bool {
+	_, ok := m.Fields[field]
+	return ok
+}
+
+// Set sets a field, replacing any value it had
+func (m *Message) Set(field int, value string) {
+	if m.Fields == nil {
+		m.Fields = map[int]string{}
+	}
+	m.Fields[field] = value
+}
+
+// Unset removes a field
+func (m *Message) Unset(field int) {
+	delete(m.Fields, field)
+}
+
+// Response returns the response to a request or advice: the MTI of the matching response,
+// with repeats (an odd last digit) answered as the original, and the request's fields echoed
+// back. The caller sets the response code and removes anything that should not be echoed
+func (m Message) Response() Message {
+	response := NewMessage(m.MTI)
+	if len(m.MTI) == 4 {
+		function := m.MTI[2]
+		if function == FUNCTION_REQUEST || function == FUNCTION_ADVICE {
+			function++
+		}
+		origin := m.MTI[3]
+		if (origin-'0')%2 == 1 {
+			origin--
+		}
+		response.MTI = m.MTI[:2] + string(function) + string(origin)
+	}
+	for field, value := range m.Fields {
+		response.Fields[field] = value
+	}
+	return response
+}
+
+// Pack encodes the message without the length prefix
+func (m Message) Pack() (packed []byte, err error) {
+	err = checkMTI(m.MTI)
+	if err != nil {
+		return nil, errors.New("iso8583.Pack: " + err.Error())
+	}
+
+	fields := make([]int, 0, len(m.Fields))
+	for field := range m.Fields {
+		if field < 2 || field > 128 {
+			return nil, errors.New("iso8583.Pack: Field " + strconv.Itoa(field) + " not valid")
+		}
+		fields = append(fields, field)
+	}
+	sort.Ints(fields)
+
+	// The secondary bitmap is only sent when a field above 64 is present
+	bitmap := make([]byte, 8)
+	if len(fields) > 0 && fields[len(fields)-1] > 64 {
+		bitmap = make([]byte, 16)
+		bitmap[0] |= 0x80
+	}
+
+	packed = append(packed, m.MTI...)
+	body := []byte{}
+	for _, field := range fields {
+		spec, ok := fieldSpec(m.Version(), field)
+		if !ok {
+			return nil, errors.New("iso8583.Pack: Field " + strconv.Itoa(field) + " not supported")
+		}
+		encoded, err := spec.encode(m.Fields[field])
+		if err != nil {
+			return nil, errors.New("iso8583.Pack: Field " + strconv.Itoa(field) + ": " + err.Error())
+		}
+		bitmap[(field-1)/8] |= 0x80 >> uint((field-1)%8)
+		body = append(body, encoded...)
+	}
+
+	packed = append(packed, bitmap...)
+	packed = append(packed, body...)
+	if len(packed) > MAX_MESSAGE_SIZE {
+		return nil, errors.New("iso8583.Pack: Message too long")
+	}
+	return
+}
+
+// Unpack decodes a message without the length prefix
+func Unpack(packed []byte) (m Message, err error) {
+	if len(packed) < 12 {
+		return Message{}, errors.New("iso8583.Unpack: Message too short")
+	}
+	m = NewMessage(string(packed[:4]))
+	err = checkMTI(m.MTI)
+	if err != nil {
+		return Message{}, errors.New("iso8583.Unpack: " + err.Error())
+	}
+
+	bitmap := packed[4:12]
+	position := 12
+	if bitmap[0]&0x80 != 0 {
+		if len(packed) < 20 {
+			return Message{}, errors.New("iso8583.Unpack: Secondary bitmap missing")
+		}
+		bitmap = packed[4:20]
+		position = 20
+	}
+
+	for field := 2; field <= len(bitmap)*8; field++ {
+		if bitmap[(field-1)/8]&(0x80>>uint((field-1)%8)) == 0 {
+			continue
+		}
+		spec, ok := fieldSpec(m.Version(), field)
+		if !ok {
+			return Message{}, errors.New("iso8583.Unpack: Field " + strconv.Itoa(field) + " not supported")
+		}
+		value, read, err := spec.decode(packed[position:])
+		if err != nil {
+			return Message{}, errors.New("iso8583.Unpack: Field " + strconv.Itoa(field) + ": " + err.Error())
+		}
+		m.Fields[field] = value
+		position += read
+	}
+
+	if position != len(packed) {
+		return Message{}, errors.New("iso8583.Unpack: " + strconv.Itoa(len(packed)-position) + " bytes after the last field")
+	}
+	return
+}
+
+// ReadMessage reads one length-prefixed message
+func ReadMessage(r io.Reader) (m Message, err error) {
+	prefix := make([]byte, 2)
+	_, err = io.ReadFull(r, prefix)
+	if err != nil {
+		return Message{}, err
+	}
+
+	packed := make([]byte, binary.BigEndian.Uint16(prefix))
+	_, err = io.ReadFull(r, packed)
+	if err != nil {
+		return Message{}, errors.New("iso8583.ReadMessage: Could not read message. " + err.Error())
+	}
+
+	return Unpack(packed)
+}
+
+// WriteMessage writes one length-prefixed message
+func WriteMessage(w io.Writer, m Message) (err error) {
+	packed, err := m.Pack()
+	if err != nil {
+		return err
+	}
+
+	prefix := make([]byte, 2)
+	binary.BigEndian.PutUint16(prefix, uint16(len(packed)))
+	_, err = w.Write(append(prefix, packed...))
+	if err != nil {
+		return errors.New("iso8583.WriteMessage: Could not write message. " + err.Error())
+	}
+	return
+}
+
+// PadNumber left pads a number with zeroes to a fixed length field
+func PadNumber(value string, length int) string {
+	if len(value) >= length {
+		return value
+	}
+	return strings.Repeat("0", length-len(value)) + value
+}
+
+func checkMTI(mti string) error {
+	if len(mti) != 4 || !isNumeric(mti) {
+		return errors.New("MTI " + mti + " not valid")
+	}
+	if mti[0] != VERSION_1987 && mti[0] != VERSION_1993 {
+		return errors.New("Version of MTI " + mti + " not supported")
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package iso8583
+
+import (
+	"bytes"
+	"testing"
+)
+
+func authorisation() Message {
+	m := NewMessage("0100")
+	m.Set(FIELD_PROCESSING_CODE, "000000")
+	m.Set(FIELD_AMOUNT, "000000001250")
+	m.Set(FIELD_TRANSMISSION_TIME, "0315120000")
+	m.Set(FIELD_STAN, "000001")
+	m.Set(FIELD_ACQUIRER_ID, "123456")
+	m.Set(FIELD_TERMINAL_ID, "TERM0001")
+	m.Set(FIELD_CARD_ACCEPTOR_ID, "MERCHANT0000001")
+	m.Set(FIELD_CURRENCY, "840")
+	return m
+}
+
+func TestPackUnpack(t *testing.T) {
+	m := authorisation()
+	packed, err := m.Pack()
+	if err != nil {
+		t.Fatalf("Pack does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	// Only the primary bitmap is sent without fields above 64
+	if string(packed[:4]) != "0100" || packed[4]&0x80 != 0 {
+		t.Errorf("Pack primary bitmap does not pass. Looking for %v, got %v", "0100 without secondary bitmap", packed[:12])
+	}
+	// Fields 3, 4 and 7 are the third, fourth and seventh bits of the bitmap
+	if packed[4] != 0x32 {
+		t.Errorf("Pack bitmap does not pass. Looking for %v, got %v", 0x32, packed[4])
+	}
+
+	unpacked, err := Unpack(packed)
+	if err != nil {
+		t.Fatalf("Unpack does not pass. Looking for %v, got %v", nil, err)
+	}
+	if unpacked.MTI != m.MTI || len(unpacked.Fields) != len(m.Fields) {
+		t.Errorf("Unpack does not pass. Looking for %v, got %v", m, unpacked)
+	}
+	for field, value := range m.Fields {
+		if unpacked.Get(field) != value {
+			t.Errorf("Unpack field %v does not pass. Looking for %v, got %v", field, value, unpacked.Get(field))
+		}
+	}
+}
+
+func TestPackSecondaryBitmap(t *testing.T) {
+	m := authorisation()
+	m.Set(FIELD_ACCOUNT_ID, "1b2ca241-0373-4610-abad-da7b06c50a7b")
+	packed, err := m.Pack()
+	if err != nil {
+		t.Fatalf("Pack secondary bitmap does not pass. Looking for %v, got %v", nil, err)
+	}
+	if packed[4]&0x80 == 0 {
+		t.Errorf("Pack secondary bitmap does not pass. Looking for %v, got %v", "bit 1 set", packed[4])
+	}
+
+	unpacked, err := Unpack(packed)
+	if err != nil || unpacked.Get(FIELD_ACCOUNT_ID) != m.Get(FIELD_ACCOUNT_ID) {
+		t.Errorf("Unpack secondary bitmap does not pass. Looking for %v, got %v %v", m.Get(FIELD_ACCOUNT_ID), unpacked.Get(FIELD_ACCOUNT_ID), err)
+	}
+}
+
+func TestPackInvalid(t *testing.T) {
+	invalid := map[string]Message{
+		"mti":         NewMessage("01"),
+		"version":     NewMessage("2100"),
+		"fixed":       {"0100", map[int]string{FIELD_STAN: "1"}},
+		"numeric":     {"0100", map[int]string{FIELD_AMOUNT: "00000000125A"}},
+		"llvar":       {"0100", map[int]string{FIELD_PAN: "12345678901234567890"}},
+		"unsupported": {"0100", map[int]string{128: "x"}},
+		"dropped":     {"1100", map[int]string{FIELD_ORIGINAL_DATA_1987: "0"}},
+	}
+	for name, m := range invalid {
+		_, err := m.Pack()
+		if err == nil {
+			t.Errorf("Pack %v does not pass. Looking for %v, got %v", name, "error", nil)
+		}
+	}
+}
+
+func TestUnpackInvalid(t *testing.T) {
+	packed, _ := authorisation().Pack()
+
+	invalid := map[string][]byte{
+		"short":     packed[:10],
+		"truncated": packed[:len(packed)-1],
+		"trailing":  append(append([]byte{}, packed...), '0'),
+	}
+	for name, data := range invalid {
+		_, err := Unpack(data)
+		if err == nil {
+			t.Errorf("Unpack %v does not pass. Looking for %v, got %v", name, "error", nil)
+		}
+	}
+}
+
+func TestVersion1993(t *testing.T) {
+	m := NewMessage("1200")
+	m.Set(FIELD_LOCAL_TIME, "160315120000")
+	m.Set(FIELD_FUNCTION_CODE, "200")
+	m.Set(FIELD_ORIGINAL_DATA_1993, "1100000001160315120000")
+	packed, err := m.Pack()
+	if err != nil {
+		t.Fatalf("Pack 1993 does not pass. Looking for %v, got %v", nil, err)
+	}
+	unpacked, err := Unpack(packed)
+	if err != nil || unpacked.Get(FIELD_LOCAL_TIME) != "160315120000" || unpacked.Get(FIELD_ORIGINAL_DATA_1993) != m.Get(FIELD_ORIGINAL_DATA_1993) {
+		t.Errorf("Unpack 1993 does not pass. Looking for %v, got %v %v", m, unpacked, err)
+	}
+
+	// Field 12 is the local time only in 1987
+	m.MTI = "0200"
+	_, err = m.Pack()
+	if err == nil {
+		t.Errorf("Pack 1987 field 12 does not pass. Looking for %v, got %v", "error", nil)
+	}
+}
+
+func TestReadWriteMessage(t *testing.T) {
+	var buffer bytes.Buffer
+	first := authorisation()
+	second := NewMessage("0800")
+	second.Set(FIELD_NETWORK_CODE, "301")
+
+	for _, m := range []Message{first, second} {
+		err := WriteMessage(&buffer, m)
+		if err != nil {
+			t.Fatalf("WriteMessage does not pass. Looking for %v, got %v", nil, err)
+		}
+	}
+	packed, _ := first.Pack()
+	if buffer.Bytes()[0] != 0 || int(buffer.Bytes()[1]) != len(packed) {
+		t.Errorf("WriteMessage length prefix does not pass. Looking for %v, got %v", len(packed), buffer.Bytes()[:2])
+	}
+
+	for _, expected := range []Message{first, second} {
+		m, err := ReadMessage(&buffer)
+		if err != nil || m.MTI != expected.MTI {
+			t.Errorf("ReadMessage does not pass. Looking for %v, got %v %v", expected.MTI, m.MTI, err)
+		}
+	}
+}
+
+func TestResponse(t *testing.T) {
+	for request, expected := range map[string]string{"0100": "0110", "0200": "0210", "0420": "0430", "0421": "0430", "1804": "1814"} {
+		response := NewMessage(request).Response()
+		if response.MTI != expected {
+			t.Errorf("Response %v does not pass. Looking for %v, got %v", request, expected, response.MTI)
+		}
+	}
+
+	response := authorisation().Response()
+	if response.Get(FIELD_STAN) != "000001" {
+		t.Errorf("Response echo does not pass. Looking for %v, got %v", "000001", response.Get(FIELD_STAN))
+	}
+}
+
+func TestSetResponseCode(t *testing.T) {
+	tests := []struct {
+		mti, code, expected string
+	}{
+		{"0110", RESPONSE_INSUFFICIENT_FUNDS, "51"},
+		{"1110", RESPONSE_INSUFFICIENT_FUNDS, "116"},
+		{"1110", RESPONSE_APPROVED, "000"},
+		{"1430", RESPONSE_APPROVED, "400"},
+		{"1814", RESPONSE_APPROVED, "800"},
+		{"1210", "99", "909"},
+	}
+	for _, test := range tests {
+		m := NewMessage(test.mti)
+		m.SetResponseCode(test.code)
+		if m.Get(FIELD_RESPONSE_CODE) != test.expected {
+			t.Errorf("SetResponseCode %v %v does not pass. Looking for %v, got %v", test.mti, test.code, test.expected, m.Get(FIELD_RESPONSE_CODE))
+		}
+		if m.Approved() != (test.code == RESPONSE_APPROVED) {
+			t.Errorf("Approved %v %v does not pass. Looking for %v, got %v", test.mti, test.code, test.code == RESPONSE_APPROVED, m.Approved())
+		}
+	}
+}
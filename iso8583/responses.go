@@ -0,0 +1,72 @@
+package iso8583
+
+// Response codes (field 39) of the 1987 version. The 1993 version answers with three digit
+// action codes instead, see SetResponseCode
+const (
+	RESPONSE_APPROVED            = "00"
+	RESPONSE_INVALID_MERCHANT    = "03"
+	RESPONSE_DO_NOT_HONOUR       = "05"
+	RESPONSE_INVALID_TRANSACTION = "12"
+	RESPONSE_INVALID_AMOUNT      = "13"
+	RESPONSE_INVALID_CARD        = "14"
+	RESPONSE_NO_ORIGINAL         = "25"
+	RESPONSE_FORMAT_ERROR        = "30"
+	RESPONSE_INSUFFICIENT_FUNDS  = "51"
+	RESPONSE_EXPIRED_CARD        = "54"
+	RESPONSE_NOT_PERMITTED       = "57"
+	RESPONSE_EXCEEDS_LIMIT       = "61"
+	RESPONSE_RESTRICTED_CARD     = "62"
+	RESPONSE_DUPLICATE           = "94"
+	RESPONSE_SYSTEM_ERROR        = "96"
+)
+
+// Action codes of the 1993 version for each 1987 response code
+var actionCodes = map[string]string{
+	RESPONSE_APPROVED:            "000",
+	RESPONSE_INVALID_MERCHANT:    "109",
+	RESPONSE_DO_NOT_HONOUR:       "100",
+	RESPONSE_INVALID_TRANSACTION: "902",
+	RESPONSE_INVALID_AMOUNT:      "110",
+	RESPONSE_INVALID_CARD:        "111",
+	RESPONSE_NO_ORIGINAL:         "914",
+	RESPONSE_FORMAT_ERROR:        "904",
+	RESPONSE_INSUFFICIENT_FUNDS:  "116",
+	RESPONSE_EXPIRED_CARD:        "101",
+	RESPONSE_NOT_PERMITTED:       "119",
+	RESPONSE_EXCEEDS_LIMIT:       "121",
+	RESPONSE_RESTRICTED_CARD:     "104",
+	RESPONSE_DUPLICATE:           "913",
+	RESPONSE_SYSTEM_ERROR:        "909",
+}
+
+// SetResponseCode sets field 39 from a 1987 response code in the message's version. In the
+// 1993 version approved reversals are answered 400 and approved network management 800
+func (m *Message) SetResponseCode(code string) {
+	if m.Version() != VERSION_1993 {
+		m.Set(FIELD_RESPONSE_CODE, code)
+		return
+	}
+
+	action, ok := actionCodes[code]
+	if !ok {
+		action = actionCodes[RESPONSE_SYSTEM_ERROR]
+	}
+	if code == RESPONSE_APPROVED {
+		switch m.Class() {
+		case CLASS_REVERSAL:
+			action = "400"
+		case CLASS_NETWORK:
+			action = "800"
+		}
+	}
+	m.Set(FIELD_RESPONSE_CODE, action)
+}
+
+// Approved reports whether a response approves its request, in either version
+func (m Message) Approved() bool {
+	switch m.Get(FIELD_RESPONSE_CODE) {
+	case RESPONSE_APPROVED, "000", "400", "800":
+		return true
+	}
+	return false
+}
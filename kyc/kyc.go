@@ -0,0 +1,89 @@
+// Package kyc checks who account holders are before their accounts can make payments.
+// It validates the format of identification numbers and dates of birth, and hands
+// identification verification requests (acmt.023) to a verifier, whose outcome is the
+// identification verification report (acmt.024). Recording the outcome is left to the
+// accounts package, which owns the account holders.
+package kyc
+
+import (
+	"errors"
+
+	"github.com/bvnk/bank/configuration"
+)
+
+// Verification statuses of an account holder. Holders start unverified, and accounts of
+// holders who are not verified cannot make payments
+const (
+	STATUS_UNVERIFIED = "unverified"
+	STATUS_PENDING    = "pending"
+	STATUS_VERIFIED   = "verified"
+	STATUS_REJECTED   = "rejected"
+)
+
+var Config configuration.Configuration
+
+func SetConfig(config *configuration.Configuration) {
+	Config = *config
+}
+
+// VerificationRequest is the identity of an account holder as they gave it when opening an account
+type VerificationRequest struct {
+	IdentificationNumber string
+	GivenName            string
+	FamilyName           string
+	DateOfBirth          string
+}
+
+// VerificationResult is a verifier's outcome. A pending result is decided later, outside the
+// bank, and the holder asks for verification again once it is. Reference is the verifier's
+// own identification of the check
+type VerificationResult struct {
+	Status    string
+	Reason    string
+	Reference string
+}
+
+// Verifier checks an identity against an outside source, such as a national register or a
+// credit bureau
+type Verifier interface {
+	Verify(request VerificationRequest) (result VerificationResult, err error)
+}
+
+// verifiers are the verifiers Config.KYCVerifier can name. None is built in, so until an outside
+// verifier is registered and configured every verification fails and holders stay restricted
+var verifiers = map[string]Verifier{}
+
+// RegisterVerifier makes a verifier available under a name, replacing any verifier registered
+// under the same name
+func RegisterVerifier(name string, verifier Verifier) {
+	verifiers[name] = verifier
+}
+
+// Verify checks the format of the request and passes it to the configured verifier. Requests
+// with badly formed details are rejected without calling the verifier
+func Verify(request VerificationRequest) (result VerificationResult, err error) {
+	verifier, ok := verifiers[Config.KYCVerifier]
+	if Config.KYCVerifier == "" || !ok {
+		return VerificationResult{}, errors.New("kyc.Verify: No identification verifier configured")
+	}
+
+	err = ValidateRequest(request)
+	if err != nil {
+		return VerificationResult{Status: STATUS_REJECTED, Reason: err.Error()}, nil
+	}
+
+	result, err = verifier.Verify(request)
+	if err != nil {
+		return VerificationResult{}, errors.New("kyc.Verify: " + err.Error())
+	}
+
+	switch result.Status {
+	case STATUS_PENDING, STATUS_VERIFIED, STATUS_REJECTED:
+		// Valid
+		break
+	default:
+		return VerificationResult{}, errors.New("kyc.Verify: Verifier returned unknown status " + result.Status)
+	}
+
+	return
+}
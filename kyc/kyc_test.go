@@ -0,0 +1,125 @@
+package kyc
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type failingVerifier struct{}
+
+func (v failingVerifier) Verify(request VerificationRequest) (VerificationResult, error) {
+	return VerificationResult{}, errors.New("Register not reachable")
+}
+
+func validRequest() VerificationRequest {
+	return VerificationRequest{
+		IdentificationNumber: "19000101-1000-100",
+		GivenName:            "John",
+		FamilyName:           "Doe",
+		DateOfBirth:          "01011990",
+	}
+}
+
+func TestValidateIdentificationNumber(t *testing.T) {
+	valid := []string{"19000101-1000-100", "010119001234123", "A1234567", "AB 12 34 56 C"}
+	for _, identificationNumber := range valid {
+		err := ValidateIdentificationNumber(identificationNumber)
+		if err != nil {
+			t.Errorf("ValidateIdentificationNumber %v does not pass. Looking for %v, got %v", identificationNumber, nil, err)
+		}
+	}
+
+	invalid := []string{"", "1234", "-1234567", "1234567-", "1234~567", "1234567890123456789012345678901"}
+	for _, identificationNumber := range invalid {
+		err := ValidateIdentificationNumber(identificationNumber)
+		if err == nil {
+			t.Errorf("ValidateIdentificationNumber %v does not pass. Looking for %v, got %v", identificationNumber, "error", nil)
+		}
+	}
+}
+
+func TestParseDateOfBirth(t *testing.T) {
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	valid := map[string]time.Time{
+		"01011990":   time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+		"1990-01-31": time.Date(1990, 1, 31, 0, 0, 0, 0, time.UTC),
+		"31122016":   time.Date(2016, 12, 31, 0, 0, 0, 0, time.UTC),
+	}
+	for dateOfBirth, expected := range valid {
+		date, err := parseDateOfBirth(dateOfBirth, now)
+		if err != nil || !date.Equal(expected) {
+			t.Errorf("ParseDateOfBirth %v does not pass. Looking for %v, got %v %v", dateOfBirth, expected, date, err)
+		}
+	}
+
+	invalid := []string{"", "19900101", "31021990", "1990/01/01", "02012017", "01011800"}
+	for _, dateOfBirth := range invalid {
+		_, err := parseDateOfBirth(dateOfBirth, now)
+		if err == nil {
+			t.Errorf("ParseDateOfBirth %v does not pass. Looking for %v, got %v", dateOfBirth, "error", nil)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	Config.KYCVerifier = ""
+	_, err := Verify(validRequest())
+	if err == nil {
+		t.Errorf("Verify does not pass. Looking for %v, got %v", "No identification verifier configured", nil)
+	}
+
+	// No verifier is built in, so naming one that was never registered fails too
+	Config.KYCVerifier = "local"
+	_, err = Verify(validRequest())
+	if err == nil || !strings.Contains(err.Error(), "No identification verifier configured") {
+		t.Errorf("Verify unregistered does not pass. Looking for %v, got %v", "No identification verifier configured", err)
+	}
+
+	RegisterVerifier("", LocalVerifier{})
+	Config.KYCVerifier = ""
+	_, err = Verify(validRequest())
+	if err == nil {
+		t.Errorf("Verify unnamed does not pass. Looking for %v, got %v", "No identification verifier configured", nil)
+	}
+
+	RegisterVerifier("test", LocalVerifier{Outcomes: map[string]VerificationResult{
+		"19000101-1000-101": {Status: STATUS_REJECTED, Reason: "Not on register"},
+		"19000101-1000-102": {Status: "unknown"},
+	}})
+	Config.KYCVerifier = "test"
+
+	result, err := Verify(validRequest())
+	if err != nil || result.Status != STATUS_VERIFIED || result.Reference == "" {
+		t.Errorf("Verify does not pass. Looking for %v, got %v %v", STATUS_VERIFIED, result, err)
+	}
+
+	request := validRequest()
+	request.IdentificationNumber = "19000101-1000-101"
+	result, err = Verify(request)
+	if err != nil || result.Status != STATUS_REJECTED || result.Reason != "Not on register" {
+		t.Errorf("Verify rejected does not pass. Looking for %v, got %v %v", STATUS_REJECTED, result, err)
+	}
+
+	request.IdentificationNumber = "19000101-1000-102"
+	_, err = Verify(request)
+	if err == nil {
+		t.Errorf("Verify unknown status does not pass. Looking for %v, got %v", "Verifier returned unknown status", nil)
+	}
+
+	// Badly formed requests never reach the verifier
+	request = validRequest()
+	request.DateOfBirth = "not a date"
+	result, err = Verify(request)
+	if err != nil || result.Status != STATUS_REJECTED {
+		t.Errorf("Verify badly formed does not pass. Looking for %v, got %v %v", STATUS_REJECTED, result, err)
+	}
+
+	RegisterVerifier("test", failingVerifier{})
+	_, err = Verify(validRequest())
+	if err == nil {
+		t.Errorf("Verify failing verifier does not pass. Looking for %v, got %v", "Register not reachable", nil)
+	}
+}
@@ -0,0 +1,18 @@
+package kyc
+
+import (
+	"strings"
+)
+
+// LocalVerifier stands in for an outside verifier in tests. It verifies every request unless
+// Outcomes holds a result for the identification number
+type LocalVerifier struct {
+	Outcomes map[string]VerificationResult
+}
+
+func (v LocalVerifier) Verify(request VerificationRequest) (result VerificationResult, err error) {
+	if outcome, ok := v.Outcomes[request.IdentificationNumber]; ok {
+		return outcome, nil
+	}
+	return VerificationResult{Status: STATUS_VERIFIED, Reference: "local:" + strings.Replace(request.IdentificationNumber, " ", "", -1)}, nil
+}
@@ -0,0 +1,82 @@
+package kyc
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Holders older than this are taken to be a mistake in the date of birth
+const MAXIMUM_AGE = 150
+
+// Dates of birth are given as ddmmyyyy or as yyyy-mm-dd
+var dateOfBirthLayouts = []string{"02012006", "2006-01-02"}
+
+// Identification numbers are national ID, passport or similar numbers: letters and digits,
+// optionally grouped with dashes, slashes or spaces
+var identificationNumberPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9/ -]*[A-Za-z0-9])?$`)
+
+const (
+	MIN_IDENTIFICATION_NUMBER_LENGTH = 5
+	MAX_IDENTIFICATION_NUMBER_LENGTH = 30
+)
+
+// ValidateIdentificationNumber checks the format of an identification number
+func ValidateIdentificationNumber(identificationNumber string) (err error) {
+	if identificationNumber == "" {
+		return errors.New("kyc.ValidateIdentificationNumber: Identification number cannot be empty")
+	}
+	if len(identificationNumber) < MIN_IDENTIFICATION_NUMBER_LENGTH || len(identificationNumber) > MAX_IDENTIFICATION_NUMBER_LENGTH {
+		return errors.New("kyc.ValidateIdentificationNumber: Identification number must be between 5 and 30 characters")
+	}
+	if !identificationNumberPattern.MatchString(identificationNumber) {
+		return errors.New("kyc.ValidateIdentificationNumber: Identification number can only contain letters, digits, dashes, slashes and spaces")
+	}
+	return
+}
+
+// ValidateDateOfBirth checks that a date of birth is a real date in the past
+func ValidateDateOfBirth(dateOfBirth string) (err error) {
+	_, err = parseDateOfBirth(dateOfBirth, time.Now())
+	if err != nil {
+		return errors.New("kyc.ValidateDateOfBirth: " + err.Error())
+	}
+	return
+}
+
+// ValidateRequest checks the format of every field of a verification request
+func ValidateRequest(request VerificationRequest) (err error) {
+	if strings.TrimSpace(request.GivenName) == "" || strings.TrimSpace(request.FamilyName) == "" {
+		return errors.New("kyc.ValidateRequest: Given and family name needed")
+	}
+	err = ValidateIdentificationNumber(request.IdentificationNumber)
+	if err != nil {
+		return errors.New("kyc.ValidateRequest: " + err.Error())
+	}
+	err = ValidateDateOfBirth(request.DateOfBirth)
+	if err != nil {
+		return errors.New("kyc.ValidateRequest: " + err.Error())
+	}
+	return
+}
+
+func parseDateOfBirth(dateOfBirth string, now time.Time) (date time.Time, err error) {
+	for _, layout := range dateOfBirthLayouts {
+		date, err = time.Parse(layout, dateOfBirth)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return time.Time{}, errors.New("kyc.parseDateOfBirth: Date of birth must be ddmmyyyy or yyyy-mm-dd")
+	}
+
+	if date.After(now) {
+		return time.Time{}, errors.New("kyc.parseDateOfBirth: Date of birth is in the future")
+	}
+	if date.Before(now.AddDate(-MAXIMUM_AGE, 0, 0)) {
+		return time.Time{}, errors.New("kyc.parseDateOfBirth: Date of birth is too far in the past")
+	}
+	return
+}
@@ -0,0 +1,164 @@
+package ledger
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/bvnk/bank/configuration"
+	"github.com/shopspring/decimal"
+)
+
+var Config configuration.Configuration
+
+func SetConfig(config *configuration.Configuration) {
+	Config = *config
+}
+
+// Preparer is satisfied by both *sql.DB and *sql.Tx, so journal entries can be written
+// inside the caller's database transaction
+type Preparer interface {
+	Prepare(query string) (*sql.Stmt, error)
+}
+
+// PostJournal validates and writes a journal entry with all of its lines
+func PostJournal(db Preparer, entry JournalEntry) (journalID int64, err error) {
+	err = entry.Validate()
+	if err != nil {
+		return 0, errors.New("ledger.PostJournal: " + err.Error())
+	}
+
+	if entry.Timestamp == 0 {
+		entry.Timestamp = int32(time.Now().Unix())
+	}
+
+	// A journal entry that is not linked to a transaction stores NULL
+	var transactionID sql.NullInt64
+	if entry.TransactionID != 0 {
+		transactionID = sql.NullInt64{Int64: entry.TransactionID, Valid: true}
+	}
+
+	stmtJournal, err := db.Prepare("INSERT INTO ledger_journal (`transactionID`, `desc`, `timestamp`) VALUES (?, ?, ?)")
+	if err != nil {
+		return 0, errors.New("ledger.PostJournal: " + err.Error())
+	}
+	defer stmtJournal.Close()
+
+	res, err := stmtJournal.Exec(transactionID, entry.Desc, entry.Timestamp)
+	if err != nil {
+		return 0, errors.New("ledger.PostJournal: " + err.Error())
+	}
+	journalID, err = res.LastInsertId()
+	if err != nil {
+		return 0, errors.New("ledger.PostJournal: Could not get journal ID. " + err.Error())
+	}
+
+	stmtLine, err := db.Prepare("INSERT INTO ledger_lines (`journalID`, `ledgerAccount`, `direction`, `amount`, `timestamp`) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return 0, errors.New("ledger.PostJournal: " + err.Error())
+	}
+	defer stmtLine.Close()
+
+	for _, line := range entry.Lines {
+		_, err = stmtLine.Exec(journalID, line.LedgerAccount, line.Direction, line.Amount, entry.Timestamp)
+		if err != nil {
+			return 0, errors.New("ledger.PostJournal: " + err.Error())
+		}
+	}
+
+	return
+}
+
+func GetTrialBalance() (trialBalance TrialBalance, err error) {
+	rows, err := Config.Db.Query("SELECT `ledgerAccount`, " +
+		"COALESCE(SUM(CASE WHEN `direction` = 'debit' THEN `amount` END), 0), " +
+		"COALESCE(SUM(CASE WHEN `direction` = 'credit' THEN `amount` END), 0) " +
+		"FROM `ledger_lines` GROUP BY `ledgerAccount` ORDER BY `ledgerAccount`")
+	if err != nil {
+		return TrialBalance{}, errors.New("ledger.GetTrialBalance: " + err.Error())
+	}
+	defer rows.Close()
+
+	trialBalance.TotalDebits = decimal.Zero
+	trialBalance.TotalCredits = decimal.Zero
+	for rows.Next() {
+		line := TrialBalanceLine{}
+		if err := rows.Scan(&line.LedgerAccount, &line.Debits, &line.Credits); err != nil {
+			return TrialBalance{}, errors.New("ledger.GetTrialBalance: " + err.Error())
+		}
+		line.Balance = line.Credits.Sub(line.Debits)
+
+		trialBalance.TotalDebits = trialBalance.TotalDebits.Add(line.Debits)
+		trialBalance.TotalCredits = trialBalance.TotalCredits.Add(line.Credits)
+		trialBalance.Lines = append(trialBalance.Lines, line)
+	}
+	trialBalance.Balanced = trialBalance.TotalDebits.Equal(trialBalance.TotalCredits)
+
+	return
+}
+
+// GetLedgerBalance returns credits - debits for a ledger account
+func GetLedgerBalance(ledgerAccount string) (balance decimal.Decimal, err error) {
+	rows, err := Config.Db.Query("SELECT COALESCE(SUM(CASE WHEN `direction` = 'credit' THEN `amount` ELSE -`amount` END), 0) FROM `ledger_lines` WHERE `ledgerAccount` = ?", ledgerAccount)
+	if err != nil {
+		return decimal.Zero, errors.New("ledger.GetLedgerBalance: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(&balance); err != nil {
+			return decimal.Zero, errors.New("ledger.GetLedgerBalance: " + err.Error())
+		}
+	}
+
+	return
+}
+
+// GetLedgerBalanceBefore returns credits - debits for a ledger account over the journal entries
+// booked before timestamp
+func GetLedgerBalanceBefore(ledgerAccount string, timestamp int32) (balance decimal.Decimal, err error) {
+	rows, err := Config.Db.Query("SELECT COALESCE(SUM(CASE WHEN `direction` = 'credit' THEN `amount` ELSE -`amount` END), 0) FROM `ledger_lines` WHERE `ledgerAccount` = ? AND `timestamp` < ?", ledgerAccount, timestamp)
+	if err != nil {
+		return decimal.Zero, errors.New("ledger.GetLedgerBalanceBefore: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(&balance); err != nil {
+			return decimal.Zero, errors.New("ledger.GetLedgerBalanceBefore: " + err.Error())
+		}
+	}
+
+	return
+}
+
+// ReconcileAccount compares the balance held on the accounts table against the journal
+func ReconcileAccount(accountNumber string) (reconciliation Reconciliation, err error) {
+	rows, err := Config.Db.Query("SELECT `accountBalance` FROM `accounts` WHERE `accountNumber` = ?", accountNumber)
+	if err != nil {
+		return Reconciliation{}, errors.New("ledger.ReconcileAccount: " + err.Error())
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		if err := rows.Scan(&reconciliation.AccountBalance); err != nil {
+			return Reconciliation{}, errors.New("ledger.ReconcileAccount: " + err.Error())
+		}
+		count++
+	}
+
+	if count == 0 {
+		return Reconciliation{}, errors.New("ledger.ReconcileAccount: Account not found")
+	}
+
+	reconciliation.AccountNumber = accountNumber
+	reconciliation.LedgerBalance, err = GetLedgerBalance(accountNumber)
+	if err != nil {
+		return Reconciliation{}, errors.New("ledger.ReconcileAccount: " + err.Error())
+	}
+	reconciliation.Difference = reconciliation.AccountBalance.Sub(reconciliation.LedgerBalance)
+	reconciliation.Reconciled = reconciliation.Difference.Sign() == 0
+
+	return
+}
@@ -0,0 +1,198 @@
+// Package ledger keeps a double-entry general ledger underneath the account balances.
+// Every posting writes a journal entry whose debit lines equal its credit lines, so the
+// trial balance always nets to zero and money can be shown to be neither created nor
+// destroyed.
+package ledger
+
+/*
+Ledger accounts are identified by a code. Customer accounts use their account number,
+the bank's own accounts use the codes below.
+
+Customer accounts are liabilities of the bank: a credit increases the customer's balance
+and a debit decreases it. The ledger balance of an account is therefore credits - debits.
+
+Ledger transactions are as follows, both for bank operators only:
+1 - TrialBalance
+2 - ReconcileAccount
+*/
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+// Bank ledger accounts
+const (
+	// Fees earned on accounts, and prefix of the income account of each transaction fee
+	FEE_INCOME = "bank:fee-income"
+	// Interest earned on overdrawn accounts
+	INTEREST_INCOME = "bank:interest-income"
+	// Interest paid on deposit accounts, less early withdrawal penalties
+	INTEREST_EXPENSE = "bank:interest-expense"
+	// Cash received over the counter for deposits
+	CASH = "bank:cash"
+	// Postings where one leg is not held at this bank
+	SUSPENSE = "bank:suspense"
+	// Balances granted when accounts are opened, and balances brought forward
+	OPENING_BALANCES = "bank:opening-balances"
+	// Prefix of the clearing account held for each correspondent bank
+	CLEARING = "bank:clearing:"
+	// Currency the bank buys and sells converting customers' amounts, kept per currency
+	FX_POSITION = "bank:fx-position"
+)
+
+const (
+	DEBIT  = "debit"
+	CREDIT = "credit"
+)
+
+type JournalLine struct {
+	LedgerAccount string
+	Direction     string
+	Amount        decimal.Decimal
+}
+
+type JournalEntry struct {
+	ID            int64
+	TransactionID int64
+	Desc          string
+	Lines         []JournalLine
+	Timestamp     int32
+}
+
+type TrialBalanceLine struct {
+	LedgerAccount string
+	Debits        decimal.Decimal
+	Credits       decimal.Decimal
+	Balance       decimal.Decimal
+}
+
+type TrialBalance struct {
+	Lines        []TrialBalanceLine
+	TotalDebits  decimal.Decimal
+	TotalCredits decimal.Decimal
+	Balanced     bool
+}
+
+type Reconciliation struct {
+	AccountNumber  string
+	AccountBalance decimal.Decimal
+	LedgerBalance  decimal.Decimal
+	Difference     decimal.Decimal
+	Reconciled     bool
+}
+
+func ProcessLedger(data []string) (result interface{}, err error) {
+	if len(data) < 3 {
+		return "", errors.New("ledger.ProcessLedger: Not all data is present")
+	}
+
+	ledgerType, err := strconv.ParseInt(data[2], 10, 64)
+	if err != nil {
+		return "", errors.New("ledger.ProcessLedger: Could not get type of ledger request. " + err.Error())
+	}
+
+	switch ledgerType {
+	case 1:
+		// token~ledger~1
+		_, err = appauth.CheckOperator(data[0])
+		if err != nil {
+			return "", errors.New("ledger.ProcessLedger: " + err.Error())
+		}
+		result, err = GetTrialBalance()
+		if err != nil {
+			return "", errors.New("ledger.ProcessLedger: " + err.Error())
+		}
+	case 2:
+		// token~ledger~2~accountNumber
+		if len(data) < 4 {
+			return "", errors.New("ledger.ProcessLedger: Not all data is present")
+		}
+		_, err = appauth.CheckOperator(data[0])
+		if err != nil {
+			return "", errors.New("ledger.ProcessLedger: " + err.Error())
+		}
+		result, err = ReconcileAccount(data[3])
+		if err != nil {
+			return "", errors.New("ledger.ProcessLedger: " + err.Error())
+		}
+	default:
+		return "", errors.New("ledger.ProcessLedger: Ledger request type invalid")
+	}
+
+	return
+}
+
+// ClearingAccount is the nostro/vostro position with another bank. A credit balance is owed to
+// the other bank, a debit balance is owed by it
+func ClearingAccount(bankNumber string) string {
+	return CLEARING + bankNumber
+}
+
+// FeeIncomeAccount is the income account the transaction fee with the code is credited to
+func FeeIncomeAccount(code string) string {
+	return FEE_INCOME + ":" + code
+}
+
+// InCurrency is the bank ledger account that holds amounts in a currency. Each bank account
+// is kept per currency so its balance never adds up different currencies; the default currency
+// keeps the plain code. Customer accounts are held in a single currency and need no suffix
+func InCurrency(ledgerAccount string, currency string) string {
+	if currency == "" || currency == money.DEFAULT_CURRENCY {
+		return ledgerAccount
+	}
+	return ledgerAccount + ":" + currency
+}
+
+// Debit returns a debit line, or nil if the amount is zero
+func Debit(ledgerAccount string, amount decimal.Decimal) []JournalLine {
+	if amount.Sign() == 0 {
+		return nil
+	}
+	return []JournalLine{JournalLine{ledgerAccount, DEBIT, amount}}
+}
+
+// Credit returns a credit line, or nil if the amount is zero
+func Credit(ledgerAccount string, amount decimal.Decimal) []JournalLine {
+	if amount.Sign() == 0 {
+		return nil
+	}
+	return []JournalLine{JournalLine{ledgerAccount, CREDIT, amount}}
+}
+
+// Validate checks that the entry is balanced. Amounts on lines are always positive,
+// the direction decides which side of the entry they fall on
+func (entry *JournalEntry) Validate() (err error) {
+	if len(entry.Lines) < 2 {
+		return errors.New("ledger.Validate: A journal entry needs at least two lines")
+	}
+
+	debits := decimal.Zero
+	credits := decimal.Zero
+	for _, line := range entry.Lines {
+		if line.LedgerAccount == "" {
+			return errors.New("ledger.Validate: Ledger account cannot be empty")
+		}
+		if line.Amount.Sign() <= 0 {
+			return errors.New("ledger.Validate: Line amounts must be positive")
+		}
+		switch line.Direction {
+		case DEBIT:
+			debits = debits.Add(line.Amount)
+		case CREDIT:
+			credits = credits.Add(line.Amount)
+		default:
+			return errors.New("ledger.Validate: Line direction must be debit or credit")
+		}
+	}
+
+	if !debits.Equal(credits) {
+		return errors.New("ledger.Validate: Journal entry does not balance. Debits " + debits.String() + ", credits " + credits.String())
+	}
+
+	return
+}
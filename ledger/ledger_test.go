@@ -0,0 +1,94 @@
+package ledger
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestProcessLedger(t *testing.T) {
+	data := []string{"", ""}
+	_, err := ProcessLedger(data)
+	if err == nil {
+		t.Errorf("ProcessLedger does not pass. Looking for %v, got %v", "Not all data is present", nil)
+	}
+
+	data = []string{"", "", "not integer"}
+	_, err = ProcessLedger(data)
+	if err == nil {
+		t.Errorf("ProcessLedger does not pass. Looking for %v, got %v", "Could not get type of ledger request", nil)
+	}
+
+	data = []string{"", "", "2"}
+	_, err = ProcessLedger(data)
+	if err == nil {
+		t.Errorf("ProcessLedger ReconcileAccount does not pass. Looking for %v, got %v", "Not all data is present", nil)
+	}
+}
+
+func TestValidateBalanced(t *testing.T) {
+	amount := decimal.NewFromFloat(20)
+	fee := decimal.NewFromFloat(0.002)
+
+	entry := JournalEntry{Desc: "Test payment"}
+	entry.Lines = append(entry.Lines, Debit("sender", amount.Add(fee))...)
+	entry.Lines = append(entry.Lines, Credit("receiver", amount)...)
+	entry.Lines = append(entry.Lines, Credit(FEE_INCOME, fee)...)
+
+	err := entry.Validate()
+	if err != nil {
+		t.Errorf("Validate does not pass. Looking for %v, got %v", nil, err)
+	}
+}
+
+func TestValidateUnbalanced(t *testing.T) {
+	entry := JournalEntry{Desc: "Test payment"}
+	entry.Lines = append(entry.Lines, Debit("sender", decimal.NewFromFloat(20))...)
+	entry.Lines = append(entry.Lines, Credit("receiver", decimal.NewFromFloat(19.99))...)
+
+	err := entry.Validate()
+	if err == nil {
+		t.Errorf("Validate does not pass. Looking for %v, got %v", "Journal entry does not balance", nil)
+	}
+}
+
+func TestValidateSingleLine(t *testing.T) {
+	entry := JournalEntry{Desc: "Test payment"}
+	entry.Lines = append(entry.Lines, Debit("sender", decimal.NewFromFloat(20))...)
+
+	err := entry.Validate()
+	if err == nil {
+		t.Errorf("Validate does not pass. Looking for %v, got %v", "A journal entry needs at least two lines", nil)
+	}
+}
+
+func TestValidateNegativeAmount(t *testing.T) {
+	entry := JournalEntry{Desc: "Test payment"}
+	entry.Lines = []JournalLine{
+		JournalLine{"sender", DEBIT, decimal.NewFromFloat(-20)},
+		JournalLine{"receiver", CREDIT, decimal.NewFromFloat(-20)},
+	}
+
+	err := entry.Validate()
+	if err == nil {
+		t.Errorf("Validate does not pass. Looking for %v, got %v", "Line amounts must be positive", nil)
+	}
+}
+
+func TestZeroLinesSkipped(t *testing.T) {
+	if len(Debit("sender", decimal.Zero)) != 0 {
+		t.Errorf("Debit does not pass. Looking for %v lines, got %v", 0, len(Debit("sender", decimal.Zero)))
+	}
+	if len(Credit("receiver", decimal.Zero)) != 0 {
+		t.Errorf("Credit does not pass. Looking for %v lines, got %v", 0, len(Credit("receiver", decimal.Zero)))
+	}
+}
+
+func TestInCurrency(t *testing.T) {
+	if account := InCurrency(CASH, "USD"); account != CASH {
+		t.Errorf("InCurrency default does not pass. Looking for %v, got %v", CASH, account)
+	}
+	if account := InCurrency(CASH, "EUR"); account != CASH+":EUR" {
+		t.Errorf("InCurrency does not pass. Looking for %v, got %v", CASH+":EUR", account)
+	}
+}
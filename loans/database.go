@@ -0,0 +1,547 @@
+package loans
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/bvnk/bank/accounts"
+	"github.com/bvnk/bank/iso20022"
+	"github.com/bvnk/bank/ledger"
+	"github.com/bvnk/bank/money"
+	"github.com/bvnk/bank/products"
+	"github.com/bvnk/bank/statements"
+	"github.com/shopspring/decimal"
+)
+
+// loanAccount is the part of an account that servicing a loan needs, read under lock
+type loanAccount struct {
+	AccountNumber    string
+	Type             string
+	Status           string
+	AccountBalance   money.Money
+	Overdraft        money.Money
+	AvailableBalance money.Money
+	Currency         string
+}
+
+func getAccountForUpdate(tx *sql.Tx, accountNumber string) (account loanAccount, err error) {
+	rows, err := tx.Query("SELECT `accountNumber`, `type`, `status`, `accountBalance`, `overdraft`, `availableBalance`, `currency` FROM `accounts` WHERE `accountNumber` = ? FOR UPDATE", accountNumber)
+	if err != nil {
+		return loanAccount{}, errors.New("loans.getAccountForUpdate: " + err.Error())
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return loanAccount{}, errors.New("loans.getAccountForUpdate: Account " + accountNumber + " not found")
+	}
+	if err := rows.Scan(&account.AccountNumber, &account.Type, &account.Status, &account.AccountBalance, &account.Overdraft, &account.AvailableBalance, &account.Currency); err != nil {
+		return loanAccount{}, errors.New("loans.getAccountForUpdate: Could not retrieve account details. " + err.Error())
+	}
+	account.AccountBalance = account.AccountBalance.In(account.Currency)
+	account.Overdraft = account.Overdraft.In(account.Currency)
+	account.AvailableBalance = account.AvailableBalance.In(account.Currency)
+
+	return
+}
+
+// checkSameHolder checks that both accounts are held by the same account holder
+func checkSameHolder(tx *sql.Tx, accountNumber string, otherAccountNumber string) (err error) {
+	rows, err := tx.Query("SELECT COUNT(*) FROM `accounts_users_accounts` a "+
+		"INNER JOIN `accounts_users_accounts` b ON b.accountHolderIdentificationNumber = a.accountHolderIdentificationNumber "+
+		"WHERE a.accountNumber = ? AND b.accountNumber = ?", accountNumber, otherAccountNumber)
+	if err != nil {
+		return errors.New("loans.checkSameHolder: " + err.Error())
+	}
+	defer rows.Close()
+
+	count := 0
+	if rows.Next() {
+		if err := rows.Scan(&count); err != nil {
+			return errors.New("loans.checkSameHolder: " + err.Error())
+		}
+	}
+	if count == 0 {
+		return errors.New("loans.checkSameHolder: Accounts are not held by the same account holder")
+	}
+
+	return
+}
+
+// doOriginateLoan disburses the principal, in the loan account's currency, from the loan account
+// to the repayment account and saves the loan with its schedule starting from start, all in one
+// database transaction
+func doOriginateLoan(loan *Loan, principal decimal.Decimal, start time.Time) (err error) {
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return errors.New("loans.doOriginateLoan: Could not start database transaction. " + err.Error())
+	}
+
+	account, err := getAccountForUpdate(tx, loan.AccountNumber)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("loans.doOriginateLoan: " + err.Error())
+	}
+	product, err := products.GetProduct(account.Type)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("loans.doOriginateLoan: " + err.Error())
+	}
+	if product.InterestType != products.INTEREST_LOAN {
+		tx.Rollback()
+		return errors.New("loans.doOriginateLoan: Loans are held on accounts of loan products")
+	}
+	if account.Status != accounts.ACCOUNT_OPEN {
+		tx.Rollback()
+		return errors.New("loans.doOriginateLoan: Loan account is not open")
+	}
+	if !account.AccountBalance.IsZero() {
+		tx.Rollback()
+		return errors.New("loans.doOriginateLoan: Loan account balance must be zero")
+	}
+
+	repaymentAccount, err := getAccountForUpdate(tx, loan.RepaymentAccountNumber)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("loans.doOriginateLoan: " + err.Error())
+	}
+	repaymentProduct, err := products.GetProduct(repaymentAccount.Type)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("loans.doOriginateLoan: " + err.Error())
+	}
+	err = repaymentProduct.CheckDisbursement()
+	if err != nil {
+		tx.Rollback()
+		return errors.New("loans.doOriginateLoan: " + err.Error())
+	}
+	if repaymentAccount.Status != accounts.ACCOUNT_OPEN {
+		tx.Rollback()
+		return errors.New("loans.doOriginateLoan: Repayment account is not open")
+	}
+	if repaymentAccount.Currency != account.Currency {
+		tx.Rollback()
+		return errors.New("loans.doOriginateLoan: Repayment account must be in the loan account's currency " + account.Currency)
+	}
+
+	loan.Principal, err = money.NewFromString(principal.String(), account.Currency)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("loans.doOriginateLoan: Principal not valid. " + err.Error())
+	}
+	loan.Payment, loan.Schedule = amortisationSchedule(loan.Principal, loan.Rate, loan.TermMonths, start)
+
+	err = checkSameHolder(tx, loan.AccountNumber, loan.RepaymentAccountNumber)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("loans.doOriginateLoan: " + err.Error())
+	}
+
+	rows, err := tx.Query("SELECT `accountNumber` FROM `loans` WHERE `accountNumber` = ? FOR UPDATE", loan.AccountNumber)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("loans.doOriginateLoan: " + err.Error())
+	}
+	exists := rows.Next()
+	rows.Close()
+	if exists {
+		tx.Rollback()
+		return errors.New("loans.doOriginateLoan: Account already holds a loan")
+	}
+
+	loan.TransactionID, err = doPostLoanTransaction(tx, TRANSACTION_DISBURSEMENT, loan.AccountNumber, loan.RepaymentAccountNumber, loan.Principal, loan.Principal, "Loan disbursement", loan.Timestamp)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("loans.doOriginateLoan: " + err.Error())
+	}
+
+	_, err = ledger.PostJournal(tx, disbursementJournalEntry(*loan))
+	if err != nil {
+		tx.Rollback()
+		return errors.New("loans.doOriginateLoan: " + err.Error())
+	}
+
+	insertStatement := "INSERT INTO loans (`accountNumber`, `repaymentAccountNumber`, `principal`, `rate`, `termMonths`, `payment`, `startDate`, `status`, `transactionID`, `timestamp`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	stmtIns, err := tx.Prepare(insertStatement)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("loans.doOriginateLoan: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	_, err = stmtIns.Exec(loan.AccountNumber, loan.RepaymentAccountNumber, loan.Principal, loan.Rate, loan.TermMonths, loan.Payment, loan.StartDate, loan.Status, loan.TransactionID, loan.Timestamp)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("loans.doOriginateLoan: " + err.Error())
+	}
+
+	insertStatement = "INSERT INTO loan_instalments (`accountNumber`, `number`, `dueDate`, `payment`, `principal`, `interest`, `balance`, `principalPaid`, `interestPaid`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	stmtInstalment, err := tx.Prepare(insertStatement)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("loans.doOriginateLoan: " + err.Error())
+	}
+	defer stmtInstalment.Close()
+
+	for _, instalment := range loan.Schedule {
+		_, err = stmtInstalment.Exec(loan.AccountNumber, instalment.Number, instalment.DueDate, instalment.Payment, instalment.Principal, instalment.Interest, instalment.Balance, instalment.PrincipalPaid, instalment.InterestPaid)
+		if err != nil {
+			tx.Rollback()
+			return errors.New("loans.doOriginateLoan: " + err.Error())
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("loans.doOriginateLoan: Could not commit transaction. " + err.Error())
+	}
+
+	return
+}
+
+// disbursementJournalEntry books the principal from the loan account, which the holder now
+// owes, to the repayment account
+func disbursementJournalEntry(loan Loan) (entry ledger.JournalEntry) {
+	entry = ledger.JournalEntry{TransactionID: loan.TransactionID, Desc: "loan~1 Loan disbursement", Timestamp: loan.Timestamp}
+	entry.Lines = append(entry.Lines, ledger.Debit(loan.AccountNumber, loan.Principal.Amount)...)
+	entry.Lines = append(entry.Lines, ledger.Credit(loan.RepaymentAccountNumber, loan.Principal.Amount)...)
+	return
+}
+
+// doPostLoanTransaction moves amount out of the sender and credited into the receiver, and
+// records it as a loan transaction so it shows on both accounts' history. The receiver is
+// notified of the credit and the sender of the debit
+func doPostLoanTransaction(tx *sql.Tx, loanType int, senderAccountNumber string, receiverAccountNumber string, amount money.Money, credited money.Money, desc string, sqlTime int32) (transactionID int64, err error) {
+	updateStatement := "UPDATE accounts SET `accountBalance` = (`accountBalance` + ?), `availableBalance` = (`availableBalance` + ?), `timestamp` = ? WHERE `accountNumber` = ? "
+	stmtUpd, err := tx.Prepare(updateStatement)
+	if err != nil {
+		return 0, errors.New("loans.doPostLoanTransaction: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	_, err = stmtUpd.Exec(amount.Neg(), amount.Neg(), sqlTime, senderAccountNumber)
+	if err != nil {
+		return 0, errors.New("loans.doPostLoanTransaction: " + err.Error())
+	}
+	_, err = stmtUpd.Exec(credited, credited, sqlTime, receiverAccountNumber)
+	if err != nil {
+		return 0, errors.New("loans.doPostLoanTransaction: " + err.Error())
+	}
+
+	insertStatement := "INSERT INTO transactions (`transaction`, `type`, `senderAccountNumber`, `senderBankNumber`, `receiverAccountNumber`, `receiverBankNumber`, `transactionAmount`, `currency`, `feeAmount`, `desc`, `timestamp`, `status`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	stmtIns, err := tx.Prepare(insertStatement)
+	if err != nil {
+		return 0, errors.New("loans.doPostLoanTransaction: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	res, err := stmtIns.Exec("loan", loanType, senderAccountNumber, "", receiverAccountNumber, "", amount, amount.Currency, 0, desc, sqlTime, "settled")
+	if err != nil {
+		return 0, errors.New("loans.doPostLoanTransaction: " + err.Error())
+	}
+	transactionID, err = res.LastInsertId()
+	if err != nil {
+		return 0, errors.New("loans.doPostLoanTransaction: Could not get transaction ID. " + err.Error())
+	}
+
+	stmtHistory, err := tx.Prepare("INSERT INTO transaction_status_history (`transactionID`, `fromStatus`, `toStatus`, `reasonCode`, `timestamp`) VALUES(?, ?, ?, ?, ?)")
+	if err != nil {
+		return 0, errors.New("loans.doPostLoanTransaction: " + err.Error())
+	}
+	defer stmtHistory.Close()
+
+	_, err = stmtHistory.Exec(transactionID, "", "settled", "", sqlTime)
+	if err != nil {
+		return 0, errors.New("loans.doPostLoanTransaction: " + err.Error())
+	}
+
+	_, err = statements.SaveNotification(tx, senderAccountNumber, transactionID, iso20022.DEBIT, amount, sqlTime)
+	if err != nil {
+		return 0, errors.New("loans.doPostLoanTransaction: " + err.Error())
+	}
+	if !credited.IsZero() {
+		_, err = statements.SaveNotification(tx, receiverAccountNumber, transactionID, iso20022.CREDIT, credited, sqlTime)
+		if err != nil {
+			return 0, errors.New("loans.doPostLoanTransaction: " + err.Error())
+		}
+	}
+
+	return
+}
+
+// getLoanWithSchedule returns a loan and every instalment of it
+func getLoanWithSchedule(accountNumber string) (loan Loan, err error) {
+	rows, err := Config.Db.Query("SELECT `accountNumber`, `repaymentAccountNumber`, `principal`, `rate`, `termMonths`, `payment`, DATE_FORMAT(`startDate`, '%Y-%m-%d'), `status`, `transactionID`, `timestamp` FROM `loans` WHERE `accountNumber` = ?", accountNumber)
+	if err != nil {
+		return Loan{}, errors.New("loans.getLoanWithSchedule: " + err.Error())
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return Loan{}, errors.New("loans.getLoanWithSchedule: No loan on account " + accountNumber)
+	}
+	if err := rows.Scan(&loan.AccountNumber, &loan.RepaymentAccountNumber, &loan.Principal, &loan.Rate, &loan.TermMonths, &loan.Payment, &loan.StartDate, &loan.Status, &loan.TransactionID, &loan.Timestamp); err != nil {
+		return Loan{}, errors.New("loans.getLoanWithSchedule: Could not retrieve loan. " + err.Error())
+	}
+	rows.Close()
+
+	rows, err = Config.Db.Query("SELECT `number`, DATE_FORMAT(`dueDate`, '%Y-%m-%d'), `payment`, `principal`, `interest`, `balance`, `principalPaid`, `interestPaid` FROM `loan_instalments` WHERE `accountNumber` = ? ORDER BY `number`", accountNumber)
+	if err != nil {
+		return Loan{}, errors.New("loans.getLoanWithSchedule: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var instalment Instalment
+		if err := rows.Scan(&instalment.Number, &instalment.DueDate, &instalment.Payment, &instalment.Principal, &instalment.Interest, &instalment.Balance, &instalment.PrincipalPaid, &instalment.InterestPaid); err != nil {
+			return Loan{}, errors.New("loans.getLoanWithSchedule: Could not retrieve instalment. " + err.Error())
+		}
+		loan.Schedule = append(loan.Schedule, instalment)
+	}
+
+	return
+}
+
+// doCollectRepayments collects the instalments due on or before date from every loan that is
+// not settled
+func doCollectRepayments(date string) (repayments []Repayment, err error) {
+	rows, err := Config.Db.Query("SELECT `accountNumber` FROM `loans` WHERE `status` != ?", LOAN_SETTLED)
+	if err != nil {
+		return nil, errors.New("loans.doCollectRepayments: " + err.Error())
+	}
+	defer rows.Close()
+
+	var accountNumbers []string
+	for rows.Next() {
+		var accountNumber string
+		if err := rows.Scan(&accountNumber); err != nil {
+			return nil, errors.New("loans.doCollectRepayments: Could not retrieve loan. " + err.Error())
+		}
+		accountNumbers = append(accountNumbers, accountNumber)
+	}
+	rows.Close()
+
+	for _, accountNumber := range accountNumbers {
+		collected, err := doCollectLoan(accountNumber, date)
+		if err != nil {
+			return repayments, errors.New("loans.doCollectRepayments: " + err.Error())
+		}
+		repayments = append(repayments, collected...)
+	}
+
+	return
+}
+
+// doCollectLoan collects a loan's unpaid instalments due on or before date, oldest first, as
+// far as the repayment account's funds go. The loan is in arrears while any of them is unpaid
+func doCollectLoan(accountNumber string, date string) (repayments []Repayment, err error) {
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return nil, errors.New("loans.doCollectLoan: Could not start database transaction. " + err.Error())
+	}
+
+	rows, err := tx.Query("SELECT `repaymentAccountNumber` FROM `loans` WHERE `accountNumber` = ? AND `status` != ? FOR UPDATE", accountNumber, LOAN_SETTLED)
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.New("loans.doCollectLoan: " + err.Error())
+	}
+	var repaymentAccountNumber string
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&repaymentAccountNumber)
+	}
+	rows.Close()
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.New("loans.doCollectLoan: Could not retrieve loan. " + err.Error())
+	}
+	if !found {
+		// Settled since the run started
+		tx.Rollback()
+		return nil, nil
+	}
+
+	rows, err = tx.Query("SELECT `number`, DATE_FORMAT(`dueDate`, '%Y-%m-%d'), `payment`, `principal`, `interest`, `balance`, `principalPaid`, `interestPaid` FROM `loan_instalments` "+
+		"WHERE `accountNumber` = ? AND `dueDate` <= ? AND (`principalPaid` < `principal` OR `interestPaid` < `interest`) ORDER BY `number` FOR UPDATE", accountNumber, date)
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.New("loans.doCollectLoan: " + err.Error())
+	}
+	var due []Instalment
+	for rows.Next() {
+		var instalment Instalment
+		if err := rows.Scan(&instalment.Number, &instalment.DueDate, &instalment.Payment, &instalment.Principal, &instalment.Interest, &instalment.Balance, &instalment.PrincipalPaid, &instalment.InterestPaid); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return nil, errors.New("loans.doCollectLoan: Could not retrieve instalment. " + err.Error())
+		}
+		due = append(due, instalment)
+	}
+	rows.Close()
+	if len(due) == 0 {
+		tx.Rollback()
+		return nil, nil
+	}
+
+	repaymentAccount, err := getAccountForUpdate(tx, repaymentAccountNumber)
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.New("loans.doCollectLoan: " + err.Error())
+	}
+
+	sqlTime := int32(time.Now().Unix())
+	funds := collectableFunds(repaymentAccount)
+	total := money.Zero(funds.Currency)
+	principalTotal := money.Zero(funds.Currency)
+	for _, instalment := range due {
+		if funds.Sign() <= 0 {
+			break
+		}
+		principal, interest := allocateRepayment(instalment, funds)
+		funds = funds.Sub(principal.Add(interest))
+		total = total.Add(principal.Add(interest))
+		principalTotal = principalTotal.Add(principal)
+		repayments = append(repayments, Repayment{
+			AccountNumber: accountNumber,
+			Instalment:    instalment.Number,
+			Principal:     principal,
+			Interest:      interest,
+			Timestamp:     sqlTime,
+		})
+	}
+
+	if !total.IsZero() {
+		// The repayment account pays the whole amount, only the principal reduces what is owed
+		transactionID, err := doPostLoanTransaction(tx, TRANSACTION_REPAYMENT, repaymentAccountNumber, accountNumber, total, principalTotal, "Loan repayment", sqlTime)
+		if err != nil {
+			tx.Rollback()
+			return nil, errors.New("loans.doCollectLoan: " + err.Error())
+		}
+		for i := range repayments {
+			repayments[i].TransactionID = transactionID
+		}
+
+		_, err = ledger.PostJournal(tx, repaymentJournalEntry(accountNumber, repaymentAccountNumber, total, principalTotal, transactionID, sqlTime))
+		if err != nil {
+			tx.Rollback()
+			return nil, errors.New("loans.doCollectLoan: " + err.Error())
+		}
+
+		err = doSaveRepayments(tx, repayments)
+		if err != nil {
+			tx.Rollback()
+			return nil, errors.New("loans.doCollectLoan: " + err.Error())
+		}
+	}
+
+	err = doUpdateLoanStatus(tx, accountNumber, date, sqlTime)
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.New("loans.doCollectLoan: " + err.Error())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New("loans.doCollectLoan: Could not commit transaction. " + err.Error())
+	}
+
+	return
+}
+
+// collectableFunds is what can be collected from a repayment account: its available balance,
+// capped at the balance plus the overdraft limit. Accounts that are not open pay nothing
+func collectableFunds(account loanAccount) money.Money {
+	if account.Status != accounts.ACCOUNT_OPEN {
+		return money.Zero(account.AccountBalance.Currency)
+	}
+	funds := account.AvailableBalance
+	limit := account.AccountBalance.Add(account.Overdraft)
+	if funds.Cmp(limit) > 0 {
+		funds = limit
+	}
+	if funds.Sign() < 0 {
+		return money.Zero(account.AccountBalance.Currency)
+	}
+	return funds
+}
+
+// repaymentJournalEntry books a repayment from the repayment account to what the loan account
+// owes, and the interest in it to the bank's interest income
+func repaymentJournalEntry(accountNumber string, repaymentAccountNumber string, total money.Money, principal money.Money, transactionID int64, sqlTime int32) (entry ledger.JournalEntry) {
+	entry = ledger.JournalEntry{TransactionID: transactionID, Desc: "loan~3 Loan repayment", Timestamp: sqlTime}
+	entry.Lines = append(entry.Lines, ledger.Debit(repaymentAccountNumber, total.Amount)...)
+	entry.Lines = append(entry.Lines, ledger.Credit(accountNumber, principal.Amount)...)
+	entry.Lines = append(entry.Lines, ledger.Credit(ledger.INTEREST_INCOME, total.Sub(principal).Amount)...)
+	return
+}
+
+func doSaveRepayments(tx *sql.Tx, repayments []Repayment) (err error) {
+	stmtUpd, err := tx.Prepare("UPDATE loan_instalments SET `principalPaid` = (`principalPaid` + ?), `interestPaid` = (`interestPaid` + ?) WHERE `accountNumber` = ? AND `number` = ?")
+	if err != nil {
+		return errors.New("loans.doSaveRepayments: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	insertStatement := "INSERT INTO loan_repayments (`accountNumber`, `instalment`, `principal`, `interest`, `transactionID`, `timestamp`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?)"
+	stmtIns, err := tx.Prepare(insertStatement)
+	if err != nil {
+		return errors.New("loans.doSaveRepayments: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	for _, repayment := range repayments {
+		_, err = stmtUpd.Exec(repayment.Principal, repayment.Interest, repayment.AccountNumber, repayment.Instalment)
+		if err != nil {
+			return errors.New("loans.doSaveRepayments: " + err.Error())
+		}
+		_, err = stmtIns.Exec(repayment.AccountNumber, repayment.Instalment, repayment.Principal, repayment.Interest, repayment.TransactionID, repayment.Timestamp)
+		if err != nil {
+			return errors.New("loans.doSaveRepayments: " + err.Error())
+		}
+	}
+
+	return
+}
+
+// doUpdateLoanStatus settles a loan once every instalment is paid, and marks it in arrears while
+// an instalment due on or before date is not
+func doUpdateLoanStatus(tx *sql.Tx, accountNumber string, date string, sqlTime int32) (err error) {
+	rows, err := tx.Query("SELECT COUNT(*), COALESCE(SUM(`dueDate` <= ?), 0) FROM `loan_instalments` "+
+		"WHERE `accountNumber` = ? AND (`principalPaid` < `principal` OR `interestPaid` < `interest`)", date, accountNumber)
+	if err != nil {
+		return errors.New("loans.doUpdateLoanStatus: " + err.Error())
+	}
+	var unpaid, overdue int
+	if rows.Next() {
+		err = rows.Scan(&unpaid, &overdue)
+	}
+	rows.Close()
+	if err != nil {
+		return errors.New("loans.doUpdateLoanStatus: " + err.Error())
+	}
+
+	status := LOAN_ACTIVE
+	switch {
+	case unpaid == 0:
+		status = LOAN_SETTLED
+	case overdue > 0:
+		status = LOAN_ARREARS
+	}
+
+	stmtUpd, err := tx.Prepare("UPDATE loans SET `status` = ?, `timestamp` = ? WHERE `accountNumber` = ?")
+	if err != nil {
+		return errors.New("loans.doUpdateLoanStatus: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	_, err = stmtUpd.Exec(status, sqlTime, accountNumber)
+	if err != nil {
+		return errors.New("loans.doUpdateLoanStatus: " + err.Error())
+	}
+
+	return
+}
@@ -0,0 +1,357 @@
+package loans
+
+/*
+Loan and mortgage servicing
+
+A loan is held on an account whose catalogue product holds loans (product~1), such as a
+loan, mortgage or credit account. The principal is disbursed to an account of the same holder
+whose product receives disbursements, such as a cheque account, which the monthly repayments
+are later collected from. The loan account's balance is
+the principal still owed, as a negative amount. Interest is part of each repayment and is
+earned by the bank, it is never added to the loan account.
+
+loan~1~
+   LoanAccountNumber~
+   RepaymentAccountNumber~
+   Principal~
+   Rate~
+   TermMonths
+
+Originates a loan at a fixed yearly Rate in percent, repaid in TermMonths equal monthly
+instalments starting a month after disbursement. The Principal is in the loan account's
+currency, which the repayment account has to hold too. Returns the loan with its amortisation
+schedule.
+
+loan~2~
+   LoanAccountNumber
+
+Returns the loan, its schedule and how much of it is in arrears.
+
+loan~3~
+   Date
+
+Collects every instalment due on or before Date (YYYY-MM-DD, today when empty) that is not
+paid yet, oldest first, by internal transfer from the repayment account. Repayment accounts
+that cannot cover an instalment are collected from as far as their funds go, and the rest
+stays in arrears until the next run.
+
+loan~4~
+   LoanAccountNumber~
+   Date
+
+Quotes the amount that settles the loan on Date (YYYY-MM-DD, today when empty): the principal
+still owed, interest due and not paid, and interest accrued since the last instalment on an
+actual/365 basis.
+
+loan~1 and loan~3 are limited to bank operators, loan~2 and loan~4 to the holder of the loan
+account.
+*/
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bvnk/bank/accounts"
+	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/configuration"
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+// Transaction types loan disbursements and repayments are recorded with. They are kept clear
+// of the PAIN types, which share the transactions table
+const (
+	TRANSACTION_DISBURSEMENT = 2001
+	TRANSACTION_REPAYMENT    = 2003
+)
+
+var Config configuration.Configuration
+
+func SetConfig(config *configuration.Configuration) {
+	Config = *config
+}
+
+// Loan statuses
+const (
+	LOAN_ACTIVE  = "active"
+	LOAN_ARREARS = "arrears"
+	LOAN_SETTLED = "settled"
+)
+
+// Instalment statuses, worked out from what has been paid and the date
+const (
+	INSTALMENT_SCHEDULED = "scheduled"
+	INSTALMENT_OVERDUE   = "overdue"
+	INSTALMENT_PAID      = "paid"
+)
+
+// Largest yearly loan rate, in percent
+const MAX_LOAN_RATE = 100
+
+// Longest loan term, 40 years
+const MAX_TERM_MONTHS = 480
+
+// Accrued interest in payoff quotes is calculated over a 365 day year
+const DAYS_IN_YEAR = 365
+
+// Loan is a loan held on a loan account, with its amortisation schedule
+type Loan struct {
+	AccountNumber          string
+	RepaymentAccountNumber string
+	Principal              money.Money
+	Rate                   decimal.Decimal
+	TermMonths             int
+	Payment                money.Money
+	StartDate              string
+	Status                 string
+	TransactionID          int64
+	Schedule               []Instalment
+	Arrears                money.Money
+	DaysInArrears          int
+	Timestamp              int32
+}
+
+// Instalment is one monthly repayment. Balance is the principal still owed once it is paid
+type Instalment struct {
+	Number        int
+	DueDate       string
+	Payment       money.Money
+	Principal     money.Money
+	Interest      money.Money
+	Balance       money.Money
+	PrincipalPaid money.Money
+	InterestPaid  money.Money
+	Status        string
+}
+
+// Repayment is an amount collected towards one instalment
+type Repayment struct {
+	AccountNumber string
+	Instalment    int
+	Principal     money.Money
+	Interest      money.Money
+	TransactionID int64
+	Timestamp     int32
+}
+
+// PayoffQuote is what settles a loan on a date
+type PayoffQuote struct {
+	AccountNumber   string
+	Date            string
+	Principal       money.Money
+	InterestDue     money.Money
+	AccruedInterest money.Money
+	Total           money.Money
+	Timestamp       int32
+}
+
+func ProcessLoan(data []string) (result interface{}, err error) {
+	if len(data) < 3 {
+		return "", errors.New("loans.ProcessLoan: Not all data is present")
+	}
+
+	// Remove null termination from the last field
+	data[len(data)-1] = strings.TrimRight(data[len(data)-1], "\x00")
+
+	loanType, err := strconv.ParseInt(data[2], 10, 64)
+	if err != nil {
+		return "", errors.New("loans.ProcessLoan: Could not get type of loan request. " + err.Error())
+	}
+
+	switch loanType {
+	case 1:
+		result, err = originateLoan(data)
+		if err != nil {
+			return "", errors.New("loans.ProcessLoan: " + err.Error())
+		}
+		break
+	case 2:
+		result, err = getLoan(data)
+		if err != nil {
+			return "", errors.New("loans.ProcessLoan: " + err.Error())
+		}
+		break
+	case 3:
+		result, err = collectRepayments(data)
+		if err != nil {
+			return "", errors.New("loans.ProcessLoan: " + err.Error())
+		}
+		break
+	case 4:
+		result, err = payoffQuote(data)
+		if err != nil {
+			return "", errors.New("loans.ProcessLoan: " + err.Error())
+		}
+		break
+	default:
+		return "", errors.New("loans.ProcessLoan: Loan request type invalid")
+	}
+
+	return
+}
+
+func originateLoan(data []string) (result interface{}, err error) {
+	if len(data) < 8 {
+		return "", errors.New("loans.originateLoan: Not all fields present")
+	}
+
+	loan := Loan{
+		AccountNumber:          data[3],
+		RepaymentAccountNumber: data[4],
+		Status:                 LOAN_ACTIVE,
+		Timestamp:              int32(time.Now().Unix()),
+	}
+	if loan.AccountNumber == "" || loan.RepaymentAccountNumber == "" {
+		return "", errors.New("loans.originateLoan: Account number missing")
+	}
+	if loan.AccountNumber == loan.RepaymentAccountNumber {
+		return "", errors.New("loans.originateLoan: Loan and repayment account must differ")
+	}
+
+	// The principal is in the currency of the loan account, which is applied once it is read
+	principal, err := decimal.NewFromString(strings.TrimSpace(data[5]))
+	if err != nil {
+		return "", errors.New("loans.originateLoan: Principal not valid. " + err.Error())
+	}
+	if principal.Sign() <= 0 {
+		return "", errors.New("loans.originateLoan: Principal must be greater than 0")
+	}
+
+	loan.Rate, err = decimal.NewFromString(strings.TrimSpace(data[6]))
+	if err != nil {
+		return "", errors.New("loans.originateLoan: Rate not valid. " + err.Error())
+	}
+	if loan.Rate.Sign() < 0 || loan.Rate.GreaterThan(decimal.New(MAX_LOAN_RATE, 0)) {
+		return "", errors.New("loans.originateLoan: Rate must be between 0 and 100 percent")
+	}
+
+	loan.TermMonths, err = strconv.Atoi(data[7])
+	if err != nil || loan.TermMonths < 1 || loan.TermMonths > MAX_TERM_MONTHS {
+		return "", errors.New("loans.originateLoan: Term must be between 1 and " + strconv.Itoa(MAX_TERM_MONTHS) + " months")
+	}
+
+	_, err = appauth.CheckOperator(data[0])
+	if err != nil {
+		return "", errors.New("loans.originateLoan: " + err.Error())
+	}
+
+	start := time.Now().In(location())
+	loan.StartDate = start.Format("2006-01-02")
+
+	err = doOriginateLoan(&loan, principal, start)
+	if err != nil {
+		return "", errors.New("loans.originateLoan: " + err.Error())
+	}
+
+	return loan, nil
+}
+
+func getLoan(data []string) (result interface{}, err error) {
+	if len(data) < 4 || data[3] == "" {
+		return "", errors.New("loans.getLoan: Account number missing")
+	}
+
+	err = checkLoanHolder(data[0], data[3])
+	if err != nil {
+		return "", errors.New("loans.getLoan: " + err.Error())
+	}
+
+	loan, err := getLoanWithSchedule(data[3])
+	if err != nil {
+		return "", errors.New("loans.getLoan: " + err.Error())
+	}
+
+	today := time.Now().In(location()).Format("2006-01-02")
+	setInstalmentStatuses(loan.Schedule, today)
+	loan.Arrears, loan.DaysInArrears = arrears(loan.Schedule, today)
+
+	return loan, nil
+}
+
+func collectRepayments(data []string) (result interface{}, err error) {
+	date := time.Now().In(location()).Format("2006-01-02")
+	if len(data) > 3 && data[3] != "" {
+		date = data[3]
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return "", errors.New("loans.collectRepayments: Date must be YYYY-MM-DD")
+	}
+	if date > time.Now().In(location()).Format("2006-01-02") {
+		return "", errors.New("loans.collectRepayments: Cannot collect instalments due in the future")
+	}
+
+	_, err = appauth.CheckOperator(data[0])
+	if err != nil {
+		return "", errors.New("loans.collectRepayments: " + err.Error())
+	}
+
+	repayments, err := doCollectRepayments(date)
+	if err != nil {
+		return "", errors.New("loans.collectRepayments: " + err.Error())
+	}
+
+	return repayments, nil
+}
+
+func payoffQuote(data []string) (result interface{}, err error) {
+	if len(data) < 4 || data[3] == "" {
+		return "", errors.New("loans.payoffQuote: Account number missing")
+	}
+
+	today := time.Now().In(location()).Format("2006-01-02")
+	date := today
+	if len(data) > 4 && data[4] != "" {
+		date = data[4]
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return "", errors.New("loans.payoffQuote: Date must be YYYY-MM-DD")
+	}
+	if date < today {
+		return "", errors.New("loans.payoffQuote: Cannot quote for a date in the past")
+	}
+
+	err = checkLoanHolder(data[0], data[3])
+	if err != nil {
+		return "", errors.New("loans.payoffQuote: " + err.Error())
+	}
+
+	loan, err := getLoanWithSchedule(data[3])
+	if err != nil {
+		return "", errors.New("loans.payoffQuote: " + err.Error())
+	}
+	if loan.Status == LOAN_SETTLED {
+		return "", errors.New("loans.payoffQuote: Loan already settled")
+	}
+
+	quote, err := payoffAmounts(loan, date)
+	if err != nil {
+		return "", errors.New("loans.payoffQuote: " + err.Error())
+	}
+	quote.Timestamp = int32(time.Now().Unix())
+
+	return quote, nil
+}
+
+// checkLoanHolder checks that the token user holds the loan account
+func checkLoanHolder(token string, accountNumber string) (err error) {
+	tokenUser, err := appauth.GetUserFromToken(token)
+	if err != nil {
+		return errors.New("loans.checkLoanHolder: " + err.Error())
+	}
+	err = accounts.CheckUserAccountValidFromToken(tokenUser, accountNumber)
+	if err != nil {
+		return errors.New("loans.checkLoanHolder: Account holder not valid")
+	}
+	return
+}
+
+// location is the bank's time zone, which decides the day instalments fall due
+func location() *time.Location {
+	loc, err := time.LoadLocation(Config.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
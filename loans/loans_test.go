@@ -0,0 +1,178 @@
+package loans
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvnk/bank/accounts"
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+func TestProcessLoan(t *testing.T) {
+	tst := []string{"", ""}
+	_, err := ProcessLoan(tst)
+	if err == nil {
+		t.Errorf("ProcessLoan does not pass. Looking for %v, got %v", "Not all data is present", nil)
+	}
+
+	// Each request fails validation before the token is checked
+	invalid := map[string]struct {
+		data     []string
+		expected string
+	}{
+		"request type":      {[]string{"", "", "0"}, "Loan request type invalid"},
+		"fields":            {[]string{"", "", "1", "loan", "cheque", "1000", "5"}, "Not all fields present"},
+		"same account":      {[]string{"", "", "1", "loan", "loan", "1000", "5", "12"}, "Loan and repayment account must differ"},
+		"principal":         {[]string{"", "", "1", "loan", "cheque", "0", "5", "12"}, "Principal must be greater than 0"},
+		"rate":              {[]string{"", "", "1", "loan", "cheque", "1000", "-5", "12"}, "Rate must be between 0 and 100 percent"},
+		"term":              {[]string{"", "", "1", "loan", "cheque", "1000", "5", "0"}, "Term must be between 1 and 480 months"},
+		"long term":         {[]string{"", "", "1", "loan", "cheque", "1000", "5", "481"}, "Term must be between 1 and 480 months"},
+		"loan account":      {[]string{"", "", "2", ""}, "Account number missing"},
+		"collection date":   {[]string{"", "", "3", "2017-13-01"}, "Date must be YYYY-MM-DD"},
+		"future collection": {[]string{"", "", "3", time.Now().AddDate(0, 0, 2).Format("2006-01-02")}, "Cannot collect instalments due in the future"},
+		"quote date":        {[]string{"", "", "4", "loan", "2017-13-01"}, "Date must be YYYY-MM-DD"},
+		"past quote":        {[]string{"", "", "4", "loan", "2017-01-01"}, "Cannot quote for a date in the past"},
+	}
+	for name, tst := range invalid {
+		_, err := ProcessLoan(tst.data)
+		if err == nil || !strings.Contains(err.Error(), tst.expected) {
+			t.Errorf("ProcessLoan %v does not pass. Looking for %v, got %v", name, tst.expected, err)
+		}
+	}
+}
+
+func TestAmortisationSchedule(t *testing.T) {
+	start := time.Date(2017, time.January, 31, 0, 0, 0, 0, time.UTC)
+
+	// 1000.00 over 12 months at 12% a year
+	payment, schedule := amortisationSchedule(money.RequireFromString("1000", money.DEFAULT_CURRENCY), decimal.New(12, 0), 12, start)
+	if payment.StringFixed() != "88.85" {
+		t.Errorf("AmortisationSchedule payment does not pass. Looking for %v, got %v", "88.85", payment.StringFixed())
+	}
+	if len(schedule) != 12 {
+		t.Fatalf("AmortisationSchedule does not pass. Looking for %v instalments, got %v", 12, len(schedule))
+	}
+	if schedule[0].Interest.StringFixed() != "10.00" || schedule[0].Principal.StringFixed() != "78.85" || schedule[0].Balance.StringFixed() != "921.15" {
+		t.Errorf("AmortisationSchedule first instalment does not pass. Looking for %v, got %v", "10.00 78.85 921.15", schedule[0])
+	}
+
+	total := money.Zero(money.DEFAULT_CURRENCY)
+	for _, instalment := range schedule {
+		total = total.Add(instalment.Principal)
+		if instalment.Payment.Cmp(instalment.Principal.Add(instalment.Interest)) != 0 {
+			t.Errorf("AmortisationSchedule instalment %v does not pass. Looking for payment %v, got %v", instalment.Number, instalment.Principal.Add(instalment.Interest), instalment.Payment)
+		}
+	}
+	if total.StringFixed() != "1000.00" || !schedule[11].Balance.IsZero() {
+		t.Errorf("AmortisationSchedule principal does not pass. Looking for %v, got %v with %v left", "1000.00", total.StringFixed(), schedule[11].Balance.StringFixed())
+	}
+
+	// Loans started on the 31st fall due on the last day of shorter months
+	dates := map[int]string{1: "2017-02-28", 2: "2017-03-31", 3: "2017-04-30", 12: "2018-01-31"}
+	for number, expected := range dates {
+		if schedule[number-1].DueDate != expected {
+			t.Errorf("AmortisationSchedule due date %v does not pass. Looking for %v, got %v", number, expected, schedule[number-1].DueDate)
+		}
+	}
+
+	// Without interest the principal is split evenly and the last payment takes the remainder
+	payment, schedule = amortisationSchedule(money.RequireFromString("100", money.DEFAULT_CURRENCY), decimal.Zero, 3, start)
+	if payment.StringFixed() != "33.33" || schedule[2].Payment.StringFixed() != "33.34" {
+		t.Errorf("AmortisationSchedule without interest does not pass. Looking for %v, got %v", "33.33 33.34", payment.StringFixed()+" "+schedule[2].Payment.StringFixed())
+	}
+}
+
+func TestAllocateRepayment(t *testing.T) {
+	instalment := Instalment{Principal: money.RequireFromString("78.85", money.DEFAULT_CURRENCY), Interest: money.RequireFromString("10", money.DEFAULT_CURRENCY), PrincipalPaid: money.RequireFromString("0", money.DEFAULT_CURRENCY), InterestPaid: money.RequireFromString("0", money.DEFAULT_CURRENCY)}
+
+	principal, interest := allocateRepayment(instalment, money.RequireFromString("5", money.DEFAULT_CURRENCY))
+	if principal.StringFixed() != "0.00" || interest.StringFixed() != "5.00" {
+		t.Errorf("AllocateRepayment interest first does not pass. Looking for %v, got %v", "0.00 5.00", principal.StringFixed()+" "+interest.StringFixed())
+	}
+
+	principal, interest = allocateRepayment(instalment, money.RequireFromString("500", money.DEFAULT_CURRENCY))
+	if principal.StringFixed() != "78.85" || interest.StringFixed() != "10.00" {
+		t.Errorf("AllocateRepayment in full does not pass. Looking for %v, got %v", "78.85 10.00", principal.StringFixed()+" "+interest.StringFixed())
+	}
+
+	instalment.InterestPaid = money.RequireFromString("10", money.DEFAULT_CURRENCY)
+	instalment.PrincipalPaid = money.RequireFromString("50", money.DEFAULT_CURRENCY)
+	principal, interest = allocateRepayment(instalment, money.RequireFromString("500", money.DEFAULT_CURRENCY))
+	if principal.StringFixed() != "28.85" || interest.StringFixed() != "0.00" {
+		t.Errorf("AllocateRepayment part paid does not pass. Looking for %v, got %v", "28.85 0.00", principal.StringFixed()+" "+interest.StringFixed())
+	}
+}
+
+func TestArrears(t *testing.T) {
+	_, schedule := amortisationSchedule(money.RequireFromString("1000", money.DEFAULT_CURRENCY), decimal.New(12, 0), 12, time.Date(2017, time.January, 15, 0, 0, 0, 0, time.UTC))
+	schedule[0].PrincipalPaid = schedule[0].Principal
+	schedule[0].InterestPaid = schedule[0].Interest
+	schedule[1].InterestPaid = schedule[1].Interest
+
+	// The second instalment is part paid and the third is unpaid
+	amount, days := arrears(schedule, "2017-04-20")
+	expected := schedule[1].Principal.Add(schedule[2].Payment)
+	if amount.Cmp(expected) != 0 || days != 36 {
+		t.Errorf("Arrears does not pass. Looking for %v %v, got %v %v", expected, 36, amount, days)
+	}
+
+	// Instalments fall into arrears the day after they are due
+	amount, days = arrears(schedule, "2017-03-15")
+	if !amount.IsZero() || days != 0 {
+		t.Errorf("Arrears on due date does not pass. Looking for %v %v, got %v %v", "0.00", 0, amount, days)
+	}
+	amount, days = arrears(schedule, "2017-03-16")
+	if amount.Cmp(schedule[1].Principal) != 0 || days != 1 {
+		t.Errorf("Arrears after due date does not pass. Looking for %v %v, got %v %v", schedule[1].Principal, 1, amount, days)
+	}
+
+	setInstalmentStatuses(schedule, "2017-04-20")
+	statuses := []string{INSTALMENT_PAID, INSTALMENT_OVERDUE, INSTALMENT_OVERDUE, INSTALMENT_SCHEDULED}
+	for i, expected := range statuses {
+		if schedule[i].Status != expected {
+			t.Errorf("SetInstalmentStatuses %v does not pass. Looking for %v, got %v", i+1, expected, schedule[i].Status)
+		}
+	}
+}
+
+func TestPayoffAmounts(t *testing.T) {
+	loan := Loan{AccountNumber: "loan", Principal: money.RequireFromString("1000", money.DEFAULT_CURRENCY), Rate: decimal.RequireFromString("36.5"), StartDate: "2017-01-15"}
+	_, loan.Schedule = amortisationSchedule(loan.Principal, loan.Rate, 12, time.Date(2017, time.January, 15, 0, 0, 0, 0, time.UTC))
+
+	// 10 days after disbursement: 1000.00 at 36.5% is 1.00 a day
+	quote, err := payoffAmounts(loan, "2017-01-25")
+	if err != nil || quote.Total.StringFixed() != "1010.00" || quote.AccruedInterest.StringFixed() != "10.00" {
+		t.Errorf("PayoffAmounts does not pass. Looking for %v, got %v %v", "1010.00", quote.Total.StringFixed(), err)
+	}
+
+	// The first instalment is due and unpaid, its interest is due on top of the principal
+	quote, err = payoffAmounts(loan, "2017-02-15")
+	expected := money.RequireFromString("1000", money.DEFAULT_CURRENCY).Add(loan.Schedule[0].Interest)
+	if err != nil || quote.Total.Cmp(expected) != 0 || !quote.AccruedInterest.IsZero() {
+		t.Errorf("PayoffAmounts on due date does not pass. Looking for %v, got %v %v", expected.StringFixed(), quote.Total.StringFixed(), err)
+	}
+
+	_, err = payoffAmounts(loan, "2017-01-01")
+	if err == nil {
+		t.Errorf("PayoffAmounts before start does not pass. Looking for %v, got %v", "Date before the loan started", nil)
+	}
+}
+
+func TestCollectableFunds(t *testing.T) {
+	funds := collectableFunds(loanAccount{Status: accounts.ACCOUNT_OPEN, AccountBalance: money.RequireFromString("100", money.DEFAULT_CURRENCY), Overdraft: money.RequireFromString("50", money.DEFAULT_CURRENCY), AvailableBalance: money.RequireFromString("150", money.DEFAULT_CURRENCY)})
+	if funds.StringFixed() != "150.00" {
+		t.Errorf("CollectableFunds does not pass. Looking for %v, got %v", "150.00", funds.StringFixed())
+	}
+
+	funds = collectableFunds(loanAccount{Status: accounts.ACCOUNT_OPEN, AccountBalance: money.RequireFromString("-80", money.DEFAULT_CURRENCY), Overdraft: money.RequireFromString("50", money.DEFAULT_CURRENCY), AvailableBalance: money.RequireFromString("-30", money.DEFAULT_CURRENCY)})
+	if !funds.IsZero() {
+		t.Errorf("CollectableFunds overdrawn does not pass. Looking for %v, got %v", "0.00", funds.StringFixed())
+	}
+
+	funds = collectableFunds(loanAccount{Status: accounts.ACCOUNT_CLOSED, AccountBalance: money.RequireFromString("100", money.DEFAULT_CURRENCY), Overdraft: money.RequireFromString("0", money.DEFAULT_CURRENCY), AvailableBalance: money.RequireFromString("100", money.DEFAULT_CURRENCY)})
+	if !funds.IsZero() {
+		t.Errorf("CollectableFunds closed does not pass. Looking for %v, got %v", "0.00", funds.StringFixed())
+	}
+}
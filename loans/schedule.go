@@ -0,0 +1,160 @@
+package loans
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+// amortisationSchedule splits a loan into equal monthly payments, each paying the month's
+// interest on the principal still owed and the rest off the principal. Payments are rounded
+// to the currency's minor unit, so the last one is adjusted to clear the principal exactly
+func amortisationSchedule(principal money.Money, rate decimal.Decimal, termMonths int, start time.Time) (payment money.Money, schedule []Instalment) {
+	monthlyRate := rate.Div(decimal.New(1200, 0))
+	payment = monthlyPayment(principal, monthlyRate, termMonths)
+
+	balance := principal
+	for number := 1; number <= termMonths; number++ {
+		interest := balance.MulRate(monthlyRate)
+		principalPart := payment.Sub(interest)
+		if number == termMonths || principalPart.Cmp(balance) > 0 {
+			principalPart = balance
+		}
+		balance = balance.Sub(principalPart)
+
+		schedule = append(schedule, Instalment{
+			Number:        number,
+			DueDate:       dueDate(start, number).Format("2006-01-02"),
+			Payment:       principalPart.Add(interest),
+			Principal:     principalPart,
+			Interest:      interest,
+			Balance:       balance,
+			PrincipalPaid: money.Zero(principal.Currency),
+			InterestPaid:  money.Zero(principal.Currency),
+			Status:        INSTALMENT_SCHEDULED,
+		})
+	}
+	return
+}
+
+// monthlyPayment is the payment that repays principal over termMonths at monthlyRate:
+// P * r / (1 - (1 + r)^-n), or P / n when no interest is charged
+func monthlyPayment(principal money.Money, monthlyRate decimal.Decimal, termMonths int) money.Money {
+	months := decimal.New(int64(termMonths), 0)
+	if monthlyRate.IsZero() {
+		return money.New(principal.Amount.Div(months), principal.Currency)
+	}
+
+	growth := decimal.New(1, 0)
+	for i := 0; i < termMonths; i++ {
+		growth = growth.Mul(decimal.New(1, 0).Add(monthlyRate))
+	}
+	return money.New(principal.Amount.Mul(monthlyRate).Mul(growth).Div(growth.Sub(decimal.New(1, 0))), principal.Currency)
+}
+
+// dueDate is the day instalment number falls due, that many months after start. Loans started
+// late in the month fall due on the last day of shorter months
+func dueDate(start time.Time, number int) time.Time {
+	firstOfMonth := time.Date(start.Year(), start.Month()+time.Month(number), 1, 0, 0, 0, 0, start.Location())
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	day := start.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(firstOfMonth.Year(), firstOfMonth.Month(), day, 0, 0, 0, 0, start.Location())
+}
+
+// outstanding is what is still to be paid of an instalment
+func (instalment Instalment) outstanding() (principal money.Money, interest money.Money) {
+	return instalment.Principal.Sub(instalment.PrincipalPaid), instalment.Interest.Sub(instalment.InterestPaid)
+}
+
+// setInstalmentStatuses marks instalments paid, overdue when they were due before date, or
+// scheduled
+func setInstalmentStatuses(schedule []Instalment, date string) {
+	for i := range schedule {
+		principal, interest := schedule[i].outstanding()
+		switch {
+		case principal.IsZero() && interest.IsZero():
+			schedule[i].Status = INSTALMENT_PAID
+		case schedule[i].DueDate < date:
+			schedule[i].Status = INSTALMENT_OVERDUE
+		default:
+			schedule[i].Status = INSTALMENT_SCHEDULED
+		}
+	}
+}
+
+// arrears is the amount of instalments due before date that is not paid, and the number of
+// days since the oldest of them fell due
+func arrears(schedule []Instalment, date string) (amount money.Money, days int) {
+	amount = money.Zero(money.DEFAULT_CURRENCY)
+	for _, instalment := range schedule {
+		if instalment.DueDate >= date {
+			break
+		}
+		principal, interest := instalment.outstanding()
+		unpaid := principal.Add(interest)
+		if unpaid.IsZero() {
+			continue
+		}
+		if amount.IsZero() {
+			days = daysBetween(instalment.DueDate, date)
+		}
+		amount = amount.Add(unpaid)
+	}
+	return
+}
+
+// allocateRepayment splits funds collected towards an instalment, paying its interest first
+func allocateRepayment(instalment Instalment, funds money.Money) (principal money.Money, interest money.Money) {
+	principalOwed, interestOwed := instalment.outstanding()
+
+	interest = interestOwed
+	if funds.Cmp(interest) < 0 {
+		interest = funds
+	}
+	principal = funds.Sub(interest)
+	if principal.Cmp(principalOwed) > 0 {
+		principal = principalOwed
+	}
+	return
+}
+
+// payoffAmounts works out what settles the loan on date. Interest accrues daily on all the
+// principal still owed since the last instalment fell due
+func payoffAmounts(loan Loan, date string) (quote PayoffQuote, err error) {
+	quote = PayoffQuote{
+		AccountNumber: loan.AccountNumber,
+		Date:          date,
+		Principal:     money.Zero(loan.Principal.Currency),
+		InterestDue:   money.Zero(loan.Principal.Currency),
+	}
+
+	lastDue := loan.StartDate
+	for _, instalment := range loan.Schedule {
+		principal, interest := instalment.outstanding()
+		quote.Principal = quote.Principal.Add(principal)
+		if instalment.DueDate <= date {
+			quote.InterestDue = quote.InterestDue.Add(interest)
+			lastDue = instalment.DueDate
+		}
+	}
+
+	days := daysBetween(lastDue, date)
+	if days < 0 {
+		return PayoffQuote{}, errors.New("loans.payoffAmounts: Date before the loan started")
+	}
+	quote.AccruedInterest = quote.Principal.MulRate(loan.Rate.Mul(decimal.New(int64(days), 0)).Div(decimal.New(100*DAYS_IN_YEAR, 0)))
+	quote.Total = quote.Principal.Add(quote.InterestDue).Add(quote.AccruedInterest)
+	return
+}
+
+// daysBetween counts the days from one YYYY-MM-DD date to another
+func daysBetween(from string, to string) int {
+	fromDate, _ := time.Parse("2006-01-02", from)
+	toDate, _ := time.Parse("2006-01-02", to)
+	return int(toDate.Sub(fromDate).Hours() / 24)
+}
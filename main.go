@@ -17,6 +17,8 @@ const (
 	CONN_PORT = "3300"
 	CONN_TYPE = "tcp"
 	HTTP_PORT = "8443"
+	// Largest command the TCP server reads, in bytes
+	MAX_COMMAND_SIZE = 1024 * 1024
 )
 
 func main() {
@@ -66,8 +68,22 @@ func parseArguments(arg string) (err error) {
 		for {
 			runServer("no-tls")
 		}
+	case "cardTerminal":
+		// Run test card terminal against the card server
+		err := runCardTerminal("tls", flag.Arg(1), flag.Arg(2), flag.Arg(3), flag.Arg(4), flag.Arg(5))
+		if err != nil {
+			log.Fatalf("Card terminal failed. " + err.Error())
+		}
+		break
+	case "cardTerminalNoTLS":
+		// Run test card terminal against the card server
+		err := runCardTerminal("no-tls", flag.Arg(1), flag.Arg(2), flag.Arg(3), flag.Arg(4), flag.Arg(5))
+		if err != nil {
+			log.Fatalf("Card terminal failed. " + err.Error())
+		}
+		break
 	default:
-		return errors.New("No valid option chosen. Valid options: client, clientNoTLS, server, serverNoTLS")
+		return errors.New("No valid option chosen. Valid options: client, clientNoTLS, server, serverNoTLS, cardTerminal, cardTerminalNoTLS")
 	}
 
 	return
@@ -0,0 +1,230 @@
+// Package money holds amounts as exact decimals together with their ISO 4217 currency.
+// Amounts are rounded to the minor unit of their currency (cents for USD), so stored
+// balances and fees never carry fractions of a unit that cannot actually be paid.
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Currency used when none is given
+const DEFAULT_CURRENCY = "USD"
+
+// Number of minor units per currency, per ISO 4217. Currencies not listed use 2
+var minorUnits = map[string]int32{
+	"BHD": 3,
+	"IQD": 3,
+	"JOD": 3,
+	"KWD": 3,
+	"LYD": 3,
+	"OMR": 3,
+	"TND": 3,
+	"CLP": 0,
+	"ISK": 0,
+	"JPY": 0,
+	"KRW": 0,
+	"PYG": 0,
+	"UGX": 0,
+	"VND": 0,
+	"XAF": 0,
+	"XOF": 0,
+}
+
+// Active ISO 4217 currency codes accounts can be held in, with their numeric codes as card
+// messages carry them
+var currencies = map[string]string{
+	"AED": "784", "AFN": "971", "ALL": "008", "AMD": "051", "ANG": "532", "AOA": "973", "ARS": "032", "AUD": "036",
+	"AWG": "533", "AZN": "944", "BAM": "977", "BBD": "052", "BDT": "050", "BGN": "975", "BHD": "048", "BIF": "108",
+	"BMD": "060", "BND": "096", "BOB": "068", "BRL": "986", "BSD": "044", "BTN": "064", "BWP": "072", "BYN": "933",
+	"BZD": "084", "CAD": "124", "CDF": "976", "CHF": "756", "CLP": "152", "CNY": "156", "COP": "170", "CRC": "188",
+	"CUP": "192", "CVE": "132", "CZK": "203", "DJF": "262", "DKK": "208", "DOP": "214", "DZD": "012", "EGP": "818",
+	"ERN": "232", "ETB": "230", "EUR": "978", "FJD": "242", "FKP": "238", "GBP": "826", "GEL": "981", "GHS": "936",
+	"GIP": "292", "GMD": "270", "GNF": "324", "GTQ": "320", "GYD": "328", "HKD": "344", "HNL": "340", "HTG": "332",
+	"HUF": "348", "IDR": "360", "ILS": "376", "INR": "356", "IQD": "368", "IRR": "364", "ISK": "352", "JMD": "388",
+	"JOD": "400", "JPY": "392", "KES": "404", "KGS": "417", "KHR": "116", "KMF": "174", "KPW": "408", "KRW": "410",
+	"KWD": "414", "KYD": "136", "KZT": "398", "LAK": "418", "LBP": "422", "LKR": "144", "LRD": "430", "LSL": "426",
+	"LYD": "434", "MAD": "504", "MDL": "498", "MGA": "969", "MKD": "807", "MMK": "104", "MNT": "496", "MOP": "446",
+	"MRU": "929", "MUR": "480", "MVR": "462", "MWK": "454", "MXN": "484", "MYR": "458", "MZN": "943", "NAD": "516",
+	"NGN": "566", "NIO": "558", "NOK": "578", "NPR": "524", "NZD": "554", "OMR": "512", "PAB": "590", "PEN": "604",
+	"PGK": "598", "PHP": "608", "PKR": "586", "PLN": "985", "PYG": "600", "QAR": "634", "RON": "946", "RSD": "941",
+	"RUB": "643", "RWF": "646", "SAR": "682", "SBD": "090", "SCR": "690", "SDG": "938", "SEK": "752", "SGD": "702",
+	"SHP": "654", "SLE": "925", "SOS": "706", "SRD": "968", "SSP": "728", "STN": "930", "SYP": "760", "SZL": "748",
+	"THB": "764", "TJS": "972", "TMT": "934", "TND": "788", "TOP": "776", "TRY": "949", "TTD": "780", "TWD": "901",
+	"TZS": "834", "UAH": "980", "UGX": "800", "USD": "840", "UYU": "858", "UZS": "860", "VES": "928", "VND": "704",
+	"VUV": "548", "WST": "882", "XAF": "950", "XCD": "951", "XOF": "952", "XPF": "953", "YER": "886", "ZAR": "710",
+	"ZMW": "967", "ZWL": "932",
+}
+
+type Money struct {
+	Amount   decimal.Decimal
+	Currency string
+	Scale    int32
+}
+
+// MinorUnits returns the number of decimal places the currency is paid in
+func MinorUnits(currency string) int32 {
+	if units, ok := minorUnits[currency]; ok {
+		return units
+	}
+	return 2
+}
+
+// ValidCurrency checks a currency is an active ISO 4217 code
+func ValidCurrency(currency string) bool {
+	_, ok := currencies[currency]
+	return ok
+}
+
+// NumericCode returns the ISO 4217 numeric code of a currency, empty when it is not valid
+func NumericCode(currency string) string {
+	return currencies[currency]
+}
+
+// CurrencyFromNumeric finds the currency with an ISO 4217 numeric code
+func CurrencyFromNumeric(code string) (currency string, ok bool) {
+	for currency, numeric := range currencies {
+		if numeric == code {
+			return currency, true
+		}
+	}
+	return "", false
+}
+
+// ParseCurrency reads a currency code given by a customer, the default currency when empty
+func ParseCurrency(currency string) (code string, err error) {
+	code = strings.ToUpper(strings.TrimSpace(currency))
+	if code == "" {
+		return DEFAULT_CURRENCY, nil
+	}
+	if !ValidCurrency(code) {
+		return "", errors.New("money.ParseCurrency: Currency " + currency + " not valid, must be an ISO 4217 code")
+	}
+	return
+}
+
+// New rounds the amount half-even to the currency's minor unit
+func New(amount decimal.Decimal, currency string) Money {
+	if currency == "" {
+		currency = DEFAULT_CURRENCY
+	}
+	scale := MinorUnits(currency)
+	return Money{amount.RoundBank(scale), currency, scale}
+}
+
+// NewFromString parses an amount given by a customer. Amounts with more decimal places
+// than the currency allows are rejected rather than silently rounded
+func NewFromString(amount string, currency string) (m Money, err error) {
+	amountDecimal, err := decimal.NewFromString(strings.TrimSpace(amount))
+	if err != nil {
+		return Money{}, errors.New("money.NewFromString: Could not convert amount to decimal. " + err.Error())
+	}
+
+	m = New(amountDecimal, currency)
+	if !m.Amount.Equal(amountDecimal) {
+		return Money{}, errors.New("money.NewFromString: Amount has more decimal places than " + m.Currency + " allows")
+	}
+
+	return
+}
+
+// RequireFromString is NewFromString for amounts known to be valid, such as constants and
+// test values. It panics when the amount is not
+func RequireFromString(amount string, currency string) Money {
+	m, err := NewFromString(amount, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func Zero(currency string) Money {
+	return New(decimal.Zero, currency)
+}
+
+// Add and Sub keep the currency of m. Callers must make sure both amounts are in the same currency
+func (m Money) Add(o Money) Money {
+	return Money{m.Amount.Add(o.Amount), m.Currency, m.Scale}
+}
+
+func (m Money) Sub(o Money) Money {
+	return Money{m.Amount.Sub(o.Amount), m.Currency, m.Scale}
+}
+
+func (m Money) Neg() Money {
+	return Money{m.Amount.Neg(), m.Currency, m.Scale}
+}
+
+// MulRate applies a rate, such as a fee percentage, and rounds the result half-even to
+// the currency's minor unit. Banker's rounding keeps rounding differences from building
+// up in one direction over many transactions
+func (m Money) MulRate(rate decimal.Decimal) Money {
+	return New(m.Amount.Mul(rate), m.Currency)
+}
+
+// In gives a stored amount the currency it is held in, rounded to that currency's minor unit.
+// Scan cannot know the currency, which is kept in its own column
+func (m Money) In(currency string) Money {
+	return New(m.Amount, currency)
+}
+
+func (m Money) Cmp(o Money) int {
+	return m.Amount.Cmp(o.Amount)
+}
+
+func (m Money) Sign() int {
+	return m.Amount.Sign()
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount.Sign() == 0
+}
+
+func (m Money) SameCurrency(o Money) bool {
+	return m.Currency == o.Currency
+}
+
+// StringFixed returns the amount with exactly as many decimal places as the currency uses
+func (m Money) StringFixed() string {
+	return m.Amount.StringFixed(MinorUnits(m.currency()))
+}
+
+func (m Money) String() string {
+	return m.StringFixed() + " " + m.currency()
+}
+
+func (m Money) currency() string {
+	if m.Currency == "" {
+		return DEFAULT_CURRENCY
+	}
+	return m.Currency
+}
+
+// Scan reads a DECIMAL column. The currency is not stored in the same column, so the
+// default currency is assumed unless one has already been set
+func (m *Money) Scan(value interface{}) (err error) {
+	err = m.Amount.Scan(value)
+	if err != nil {
+		return errors.New("money.Scan: " + err.Error())
+	}
+	m.Currency = m.currency()
+	m.Scale = MinorUnits(m.Currency)
+	return
+}
+
+// Value writes the exact amount to a DECIMAL column
+func (m Money) Value() (driver.Value, error) {
+	return m.Amount.String(), nil
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string
+		Currency string
+		Scale    int32
+	}{m.StringFixed(), m.currency(), MinorUnits(m.currency())})
+}
@@ -0,0 +1,182 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestNewRoundsToMinorUnit(t *testing.T) {
+	m := New(decimal.RequireFromString("10.005"), "USD")
+	if m.StringFixed() != "10.00" {
+		t.Errorf("New does not pass. Looking for %v, got %v", "10.00", m.StringFixed())
+	}
+
+	m = New(decimal.RequireFromString("10.015"), "USD")
+	if m.StringFixed() != "10.02" {
+		t.Errorf("New does not pass. Looking for %v, got %v", "10.02", m.StringFixed())
+	}
+
+	m = New(decimal.RequireFromString("10.0005"), "KWD")
+	if m.StringFixed() != "10.000" {
+		t.Errorf("New does not pass. Looking for %v, got %v", "10.000", m.StringFixed())
+	}
+
+	m = New(decimal.RequireFromString("1000.5"), "JPY")
+	if m.StringFixed() != "1000" {
+		t.Errorf("New does not pass. Looking for %v, got %v", "1000", m.StringFixed())
+	}
+}
+
+func TestNewDefaultCurrency(t *testing.T) {
+	m := New(decimal.New(100, 0), "")
+	if m.Currency != DEFAULT_CURRENCY {
+		t.Errorf("New does not pass. Looking for %v, got %v", DEFAULT_CURRENCY, m.Currency)
+	}
+	if m.Scale != 2 {
+		t.Errorf("New does not pass. Looking for %v, got %v", 2, m.Scale)
+	}
+}
+
+func TestNewFromString(t *testing.T) {
+	m, err := NewFromString("20.50", "USD")
+	if err != nil {
+		t.Errorf("NewFromString does not pass. Looking for %v, got %v", nil, err)
+	}
+	if !m.Amount.Equal(decimal.RequireFromString("20.5")) {
+		t.Errorf("NewFromString does not pass. Looking for %v, got %v", "20.50", m.Amount)
+	}
+
+	_, err = NewFromString("20.501", "USD")
+	if err == nil {
+		t.Errorf("NewFromString does not pass. Looking for %v, got %v", "Amount has more decimal places than USD allows", nil)
+	}
+
+	_, err = NewFromString("not a number", "USD")
+	if err == nil {
+		t.Errorf("NewFromString does not pass. Looking for %v, got %v", "Could not convert amount to decimal", nil)
+	}
+}
+
+func TestRequireFromString(t *testing.T) {
+	m := RequireFromString("100", "JPY")
+	if m.StringFixed() != "100" || m.Currency != "JPY" {
+		t.Errorf("RequireFromString does not pass. Looking for %v, got %v", "100 JPY", m)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("RequireFromString does not pass. Looking for %v, got %v", "panic", nil)
+		}
+	}()
+	RequireFromString("100.5", "JPY")
+}
+
+func TestMulRateNoSubCentDrift(t *testing.T) {
+	rate := decimal.NewFromFloat(0.0001)
+	total := Zero("USD")
+	// 0.01% of 25.00 is 0.0025, which must never be stored as a fraction of a cent
+	for i := 0; i < 1000; i++ {
+		fee := New(decimal.RequireFromString("25"), "USD").MulRate(rate)
+		if !fee.Amount.Equal(fee.Amount.Round(2)) {
+			t.Fatalf("MulRate does not pass. Looking for a whole number of cents, got %v", fee.Amount)
+		}
+		total = total.Add(fee)
+	}
+
+	if !total.Amount.Equal(total.Amount.Round(2)) {
+		t.Errorf("MulRate does not pass. Looking for a whole number of cents, got %v", total.Amount)
+	}
+}
+
+func TestMulRateHalfEven(t *testing.T) {
+	rate := decimal.NewFromFloat(0.0001)
+
+	// 0.0050 rounds to the even cent below, 0.0150 to the even cent above
+	fee := New(decimal.RequireFromString("50"), "USD").MulRate(rate)
+	if fee.StringFixed() != "0.00" {
+		t.Errorf("MulRate does not pass. Looking for %v, got %v", "0.00", fee.StringFixed())
+	}
+	fee = New(decimal.RequireFromString("150"), "USD").MulRate(rate)
+	if fee.StringFixed() != "0.02" {
+		t.Errorf("MulRate does not pass. Looking for %v, got %v", "0.02", fee.StringFixed())
+	}
+}
+
+func TestScan(t *testing.T) {
+	m := Money{}
+	err := m.Scan([]byte("100.2500"))
+	if err != nil {
+		t.Errorf("Scan does not pass. Looking for %v, got %v", nil, err)
+	}
+	if m.Currency != DEFAULT_CURRENCY || !m.Amount.Equal(decimal.RequireFromString("100.25")) {
+		t.Errorf("Scan does not pass. Looking for %v, got %v", "100.25 USD", m)
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	m := New(decimal.New(100, 0), "USD")
+	b, err := m.MarshalJSON()
+	if err != nil {
+		t.Errorf("MarshalJSON does not pass. Looking for %v, got %v", nil, err)
+	}
+	if string(b) != `{"Amount":"100.00","Currency":"USD","Scale":2}` {
+		t.Errorf("MarshalJSON does not pass. Looking for %v, got %v", `{"Amount":"100.00","Currency":"USD","Scale":2}`, string(b))
+	}
+}
+
+func TestParseCurrency(t *testing.T) {
+	valid := map[string]string{
+		"":      DEFAULT_CURRENCY,
+		"eur":   "EUR",
+		" JPY ": "JPY",
+	}
+	for currency, expected := range valid {
+		code, err := ParseCurrency(currency)
+		if err != nil || code != expected {
+			t.Errorf("ParseCurrency %v does not pass. Looking for %v, got %v %v", currency, expected, code, err)
+		}
+	}
+
+	for _, currency := range []string{"EU", "EURO", "XXX", "123"} {
+		_, err := ParseCurrency(currency)
+		if err == nil {
+			t.Errorf("ParseCurrency %v does not pass. Looking for %v, got %v", currency, "error", nil)
+		}
+	}
+}
+
+func TestIn(t *testing.T) {
+	var m Money
+	m.Scan([]byte("1000.4000"))
+	if m.Currency != DEFAULT_CURRENCY {
+		t.Errorf("Scan does not pass. Looking for %v, got %v", DEFAULT_CURRENCY, m.Currency)
+	}
+
+	jpy := m.In("JPY")
+	if jpy.Currency != "JPY" || jpy.Scale != 0 || jpy.StringFixed() != "1000" {
+		t.Errorf("In does not pass. Looking for %v, got %v", "1000 JPY", jpy)
+	}
+}
+
+func TestNumericCode(t *testing.T) {
+	codes := map[string]string{
+		"USD": "840",
+		"EUR": "978",
+		"JPY": "392",
+		"ALL": "008",
+	}
+	for currency, code := range codes {
+		if NumericCode(currency) != code {
+			t.Errorf("NumericCode %v does not pass. Looking for %v, got %v", currency, code, NumericCode(currency))
+		}
+		found, ok := CurrencyFromNumeric(code)
+		if !ok || found != currency {
+			t.Errorf("CurrencyFromNumeric %v does not pass. Looking for %v, got %v %v", code, currency, found, ok)
+		}
+	}
+
+	if _, ok := CurrencyFromNumeric("999"); ok {
+		t.Errorf("CurrencyFromNumeric %v does not pass. Looking for %v, got %v", "999", false, ok)
+	}
+}
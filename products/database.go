@@ -0,0 +1,112 @@
+package products
+
+import (
+	"errors"
+	"strings"
+)
+
+const productColumns = "`code`, `name`, `holders`, `openingBalance`, `monthlyFee`, `overdraftEligible`, `maxOverdraft`, `interestType`, `currency`, `cashAccountType`, `receivesDisbursements`, `term`, `status`, `timestamp`"
+
+// rowScanner is the part of sql.Rows and sql.Row that product rows are read with
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanProduct(row rowScanner) (product Product, err error) {
+	var holders string
+	err = row.Scan(&product.Code, &product.Name, &holders, &product.OpeningBalance, &product.MonthlyFee, &product.OverdraftEligible, &product.MaxOverdraft, &product.InterestType, &product.Currency, &product.CashAccountType, &product.ReceivesDisbursements, &product.Term, &product.Status, &product.Timestamp)
+	if err != nil {
+		return Product{}, err
+	}
+	product.Holders = strings.Split(holders, ",")
+	product.OpeningBalance = product.OpeningBalance.In(product.Currency)
+	product.MonthlyFee = product.MonthlyFee.In(product.Currency)
+	product.MaxOverdraft = product.MaxOverdraft.In(product.Currency)
+	return
+}
+
+func doSaveProduct(product Product) (err error) {
+	insertStatement := "INSERT INTO products (" + productColumns + ") "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) "
+	insertStatement += "ON DUPLICATE KEY UPDATE `name` = VALUES(`name`), `holders` = VALUES(`holders`), `openingBalance` = VALUES(`openingBalance`), "
+	insertStatement += "`monthlyFee` = VALUES(`monthlyFee`), `overdraftEligible` = VALUES(`overdraftEligible`), `maxOverdraft` = VALUES(`maxOverdraft`), "
+	insertStatement += "`interestType` = VALUES(`interestType`), `currency` = VALUES(`currency`), "
+	insertStatement += "`cashAccountType` = VALUES(`cashAccountType`), `receivesDisbursements` = VALUES(`receivesDisbursements`), `term` = VALUES(`term`), `status` = VALUES(`status`), `timestamp` = VALUES(`timestamp`)"
+	stmtIns, err := Config.Db.Prepare(insertStatement)
+	if err != nil {
+		return errors.New("products.doSaveProduct: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	_, err = stmtIns.Exec(product.Code, product.Name, strings.Join(product.Holders, ","), product.OpeningBalance, product.MonthlyFee, product.OverdraftEligible, product.MaxOverdraft, product.InterestType, product.Currency, product.CashAccountType, product.ReceivesDisbursements, product.Term, product.Status, product.Timestamp)
+	if err != nil {
+		return errors.New("products.doSaveProduct: " + err.Error())
+	}
+
+	return
+}
+
+func doSetProductStatus(code string, status string) (err error) {
+	stmtUpdate, err := Config.Db.Prepare("UPDATE products SET `status` = ? WHERE `code` = ?")
+	if err != nil {
+		return errors.New("products.doSetProductStatus: " + err.Error())
+	}
+	defer stmtUpdate.Close()
+
+	res, err := stmtUpdate.Exec(status, code)
+	if err != nil {
+		return errors.New("products.doSetProductStatus: " + err.Error())
+	}
+
+	// MySQL does not count rows updated to the values they already had, so check the
+	// product exists when nothing changed
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errors.New("products.doSetProductStatus: " + err.Error())
+	}
+	if affected == 0 {
+		_, err = GetProduct(code)
+		if err != nil {
+			return errors.New("products.doSetProductStatus: " + err.Error())
+		}
+	}
+
+	return
+}
+
+func getActiveProducts() (products []Product, err error) {
+	rows, err := Config.Db.Query("SELECT "+productColumns+" FROM `products` WHERE `status` = ? ORDER BY `code`", PRODUCT_ACTIVE)
+	if err != nil {
+		return nil, errors.New("products.getActiveProducts: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, errors.New("products.getActiveProducts: Could not retrieve product. " + err.Error())
+		}
+		products = append(products, product)
+	}
+
+	return
+}
+
+// GetProduct returns a product of the catalogue, withdrawn or not
+func GetProduct(code string) (product Product, err error) {
+	rows, err := Config.Db.Query("SELECT "+productColumns+" FROM `products` WHERE `code` = ?", code)
+	if err != nil {
+		return Product{}, errors.New("products.GetProduct: " + err.Error())
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return Product{}, errors.New("products.GetProduct: Product " + code + " not found")
+	}
+	product, err = scanProduct(rows)
+	if err != nil {
+		return Product{}, errors.New("products.GetProduct: Could not retrieve product. " + err.Error())
+	}
+
+	return
+}
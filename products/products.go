@@ -0,0 +1,390 @@
+package products
+
+/*
+Product catalogue
+
+Every account is opened as a product of the catalogue. The product code is the account's
+type, so launching a product is a catalogue entry rather than a code change.
+
+product~1~
+   Code~
+   Name~
+   Holders~
+   OpeningBalance~
+   MonthlyFee~
+   OverdraftEligible~
+   MaxOverdraft~
+   InterestType~
+   Currency~
+   CashAccountType~
+   ReceivesDisbursements~
+   Term
+
+Defines or replaces a product. Code is lower case letters, digits and hyphens. Holders is
+individual, merchant or both separated by a comma. OpeningBalance is credited by the bank to
+every new account, 0 when empty. MonthlyFee is charged to every account of the product at the
+end of each month (acmt~1009). OverdraftEligible is true or false and MaxOverdraft caps the
+limit that can be granted, 0 meaning no cap. InterestType is none, deposit for accounts that
+earn interest (interest~1) or loan for accounts that hold loans (loan~1). The amounts are in
+Currency (USD when empty). A product with an opening balance or monthly fee is only opened in
+its currency, so each currency it is offered in is priced with a product of its own.
+CashAccountType is the ISO 20022 code accounts of the product are reported with (acmt~13),
+such as CACC, SVGS or LOAN; accounts without one are reported by product code.
+ReceivesDisbursements is true for products whose accounts loans can be disbursed to (loan~1),
+and Term is true for products whose interest product must have a term (interest~1). Both are
+false when empty.
+
+product~2~
+   Code~
+   Status
+
+Withdraws (withdrawn) or relaunches (active) a product. Accounts of a withdrawn product stay
+as they are but no new ones can be opened.
+
+product~1000
+product~1001~
+   Code
+
+List the active products, and return one product.
+*/
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/configuration"
+	"github.com/bvnk/bank/money"
+)
+
+var Config configuration.Configuration
+
+func SetConfig(config *configuration.Configuration) {
+	Config = *config
+}
+
+// Who can hold accounts of a product
+const (
+	HOLDER_INDIVIDUAL = "individual"
+	HOLDER_MERCHANT   = "merchant"
+)
+
+// How a product deals with interest
+const (
+	INTEREST_NONE    = "none"
+	INTEREST_DEPOSIT = "deposit"
+	INTEREST_LOAN    = "loan"
+)
+
+// Product statuses
+const (
+	PRODUCT_ACTIVE    = "active"
+	PRODUCT_WITHDRAWN = "withdrawn"
+)
+
+var codePattern = regexp.MustCompile(`^[a-z0-9-]{1,20}$`)
+
+// ISO 20022 cash account type codes (ExternalCashAccountType1Code)
+var cashAccountTypes = map[string]bool{
+	"CACC": true, "CARD": true, "CASH": true, "CHAR": true, "CISH": true, "COMM": true,
+	"CPAC": true, "LLSV": true, "LOAN": true, "MGLD": true, "MOMA": true, "NFCA": true,
+	"NREX": true, "ODFT": true, "ONDP": true, "OTHR": true, "SACC": true, "SLRY": true,
+	"SVGS": true, "TAXE": true, "TRAN": true, "TRAS": true, "VACC": true,
+}
+
+// Product is an entry of the catalogue
+type Product struct {
+	Code                  string
+	Name                  string
+	Holders               []string
+	OpeningBalance        money.Money
+	MonthlyFee            money.Money
+	OverdraftEligible     bool
+	MaxOverdraft          money.Money
+	InterestType          string
+	Currency              string
+	CashAccountType       string
+	ReceivesDisbursements bool
+	Term                  bool
+	Status                string
+	Timestamp             int32
+}
+
+func ProcessProduct(data []string) (result interface{}, err error) {
+	if len(data) < 3 {
+		return "", errors.New("products.ProcessProduct: Not all data is present")
+	}
+
+	// Remove null termination from the last field
+	data[len(data)-1] = strings.TrimRight(data[len(data)-1], "\x00")
+
+	productType, err := strconv.ParseInt(data[2], 10, 64)
+	if err != nil {
+		return "", errors.New("products.ProcessProduct: Could not get type of product request. " + err.Error())
+	}
+
+	switch productType {
+	case 1:
+		result, err = setProduct(data)
+		if err != nil {
+			return "", errors.New("products.ProcessProduct: " + err.Error())
+		}
+		break
+	case 2:
+		result, err = setProductStatus(data)
+		if err != nil {
+			return "", errors.New("products.ProcessProduct: " + err.Error())
+		}
+		break
+	case 1000:
+		result, err = getActiveProducts()
+		if err != nil {
+			return "", errors.New("products.ProcessProduct: " + err.Error())
+		}
+		break
+	case 1001:
+		if len(data) < 4 {
+			return "", errors.New("products.ProcessProduct: Not all data is present")
+		}
+		result, err = GetProduct(data[3])
+		if err != nil {
+			return "", errors.New("products.ProcessProduct: " + err.Error())
+		}
+		break
+	default:
+		return "", errors.New("products.ProcessProduct: Product request type invalid")
+	}
+
+	return
+}
+
+func setProduct(data []string) (result interface{}, err error) {
+	if len(data) < 6 {
+		return "", errors.New("products.setProduct: Not all fields present")
+	}
+
+	currency := ""
+	if len(data) > 11 {
+		currency = data[11]
+	}
+	currency, err = money.ParseCurrency(currency)
+	if err != nil {
+		return "", errors.New("products.setProduct: " + err.Error())
+	}
+
+	product := Product{
+		Code:           data[3],
+		Name:           data[4],
+		OpeningBalance: money.Zero(currency),
+		MonthlyFee:     money.Zero(currency),
+		MaxOverdraft:   money.Zero(currency),
+		InterestType:   INTEREST_NONE,
+		Currency:       currency,
+		Status:         PRODUCT_ACTIVE,
+		Timestamp:      int32(time.Now().Unix()),
+	}
+	if !ValidCode(product.Code) {
+		return "", errors.New("products.setProduct: Code must be 1 to 20 lower case letters, digits or hyphens")
+	}
+	if product.Name == "" {
+		return "", errors.New("products.setProduct: Name cannot be empty")
+	}
+
+	product.Holders, err = parseHolders(data[5])
+	if err != nil {
+		return "", errors.New("products.setProduct: " + err.Error())
+	}
+
+	if len(data) > 6 && data[6] != "" {
+		product.OpeningBalance, err = parseAmount(data[6], currency)
+		if err != nil {
+			return "", errors.New("products.setProduct: Opening balance not valid. " + err.Error())
+		}
+	}
+	if len(data) > 7 && data[7] != "" {
+		product.MonthlyFee, err = parseAmount(data[7], currency)
+		if err != nil {
+			return "", errors.New("products.setProduct: Monthly fee not valid. " + err.Error())
+		}
+	}
+	if len(data) > 8 && data[8] != "" {
+		product.OverdraftEligible, err = strconv.ParseBool(data[8])
+		if err != nil {
+			return "", errors.New("products.setProduct: Overdraft eligibility must be true or false")
+		}
+	}
+	if len(data) > 9 && data[9] != "" {
+		product.MaxOverdraft, err = parseAmount(data[9], currency)
+		if err != nil {
+			return "", errors.New("products.setProduct: Maximum overdraft not valid. " + err.Error())
+		}
+	}
+	if !product.OverdraftEligible && !product.MaxOverdraft.IsZero() {
+		return "", errors.New("products.setProduct: Only products eligible for overdrafts have a maximum overdraft")
+	}
+	if len(data) > 10 && data[10] != "" {
+		product.InterestType = data[10]
+	}
+	if product.InterestType != INTEREST_NONE && product.InterestType != INTEREST_DEPOSIT && product.InterestType != INTEREST_LOAN {
+		return "", errors.New("products.setProduct: Interest type must be none, deposit or loan")
+	}
+	if len(data) > 12 && data[12] != "" {
+		product.CashAccountType = strings.ToUpper(strings.TrimSpace(data[12]))
+		if !cashAccountTypes[product.CashAccountType] {
+			return "", errors.New("products.setProduct: Cash account type must be an ISO 20022 code such as CACC, SVGS or LOAN")
+		}
+	}
+	if len(data) > 13 && data[13] != "" {
+		product.ReceivesDisbursements, err = strconv.ParseBool(data[13])
+		if err != nil {
+			return "", errors.New("products.setProduct: Receiving disbursements must be true or false")
+		}
+	}
+	if len(data) > 14 && data[14] != "" {
+		product.Term, err = strconv.ParseBool(data[14])
+		if err != nil {
+			return "", errors.New("products.setProduct: Term must be true or false")
+		}
+	}
+
+	_, err = appauth.CheckOperator(data[0])
+	if err != nil {
+		return "", errors.New("products.setProduct: " + err.Error())
+	}
+
+	err = doSaveProduct(product)
+	if err != nil {
+		return "", errors.New("products.setProduct: " + err.Error())
+	}
+
+	return product, nil
+}
+
+func setProductStatus(data []string) (result interface{}, err error) {
+	if len(data) < 5 {
+		return "", errors.New("products.setProductStatus: Not all fields present")
+	}
+
+	code := data[3]
+	status := data[4]
+	if status != PRODUCT_ACTIVE && status != PRODUCT_WITHDRAWN {
+		return "", errors.New("products.setProductStatus: Status must be active or withdrawn")
+	}
+
+	_, err = appauth.CheckOperator(data[0])
+	if err != nil {
+		return "", errors.New("products.setProductStatus: " + err.Error())
+	}
+
+	err = doSetProductStatus(code, status)
+	if err != nil {
+		return "", errors.New("products.setProductStatus: " + err.Error())
+	}
+
+	return GetProduct(code)
+}
+
+// ValidCode checks the format of a product code
+func ValidCode(code string) bool {
+	return codePattern.MatchString(code)
+}
+
+// AllowsHolder reports whether the holder can hold accounts of the product
+func (product Product) AllowsHolder(holder string) bool {
+	for _, allowed := range product.Holders {
+		if allowed == holder {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckOpening returns why the holder cannot open an account of the product, if they cannot
+func (product Product) CheckOpening(holder string) (err error) {
+	if product.Status != PRODUCT_ACTIVE {
+		return errors.New("products.CheckOpening: Product " + product.Code + " is withdrawn")
+	}
+	if !product.AllowsHolder(holder) {
+		return errors.New("products.CheckOpening: Product " + product.Code + " cannot be held by a " + holder)
+	}
+	return
+}
+
+// CheckCurrency returns why an account of the product cannot be held in the currency, if it
+// cannot. Product amounts are in the product's currency, so accounts in other currencies are
+// only possible when the product grants no opening balance and charges no monthly fee
+func (product Product) CheckCurrency(currency string) (err error) {
+	if currency == product.Currency {
+		return
+	}
+	if !product.OpeningBalance.IsZero() || !product.MonthlyFee.IsZero() {
+		return errors.New("products.CheckCurrency: Product " + product.Code + " is only offered in " + product.Currency)
+	}
+	return
+}
+
+// CheckTerm returns why an interest product with the term in months cannot be offered on the
+// product, if it cannot. Term products need a term
+func (product Product) CheckTerm(termMonths int) (err error) {
+	if product.Term && termMonths == 0 {
+		return errors.New("products.CheckTerm: Product " + product.Code + " is a term product and needs a term")
+	}
+	return
+}
+
+// CheckDisbursement returns why loans cannot be disbursed to an account of the product, if they
+// cannot
+func (product Product) CheckDisbursement() (err error) {
+	if !product.ReceivesDisbursements {
+		return errors.New("products.CheckDisbursement: Product " + product.Code + " does not receive loan disbursements")
+	}
+	return
+}
+
+// CheckOverdraft returns why an account of the product cannot have the overdraft limit, if it
+// cannot. Revoking an overdraft is always allowed
+func (product Product) CheckOverdraft(limit money.Money) (err error) {
+	if limit.IsZero() {
+		return
+	}
+	if !product.OverdraftEligible {
+		return errors.New("products.CheckOverdraft: Product " + product.Code + " is not eligible for overdrafts")
+	}
+	if !product.MaxOverdraft.IsZero() && !limit.SameCurrency(product.MaxOverdraft) {
+		return errors.New("products.CheckOverdraft: Product " + product.Code + " only offers overdrafts in " + product.MaxOverdraft.Currency)
+	}
+	if !product.MaxOverdraft.IsZero() && limit.Cmp(product.MaxOverdraft) > 0 {
+		return errors.New("products.CheckOverdraft: Limit above the maximum of " + product.MaxOverdraft.StringFixed() + " for product " + product.Code)
+	}
+	return
+}
+
+func parseHolders(value string) (holders []string, err error) {
+	for _, holder := range strings.Split(value, ",") {
+		holder = strings.TrimSpace(holder)
+		if holder != HOLDER_INDIVIDUAL && holder != HOLDER_MERCHANT {
+			return nil, errors.New("products.parseHolders: Holders must be individual, merchant or both")
+		}
+		for _, existing := range holders {
+			if existing == holder {
+				return nil, errors.New("products.parseHolders: Holder " + holder + " appears twice")
+			}
+		}
+		holders = append(holders, holder)
+	}
+	return
+}
+
+func parseAmount(value string, currency string) (amount money.Money, err error) {
+	amount, err = money.NewFromString(strings.TrimSpace(value), currency)
+	if err != nil {
+		return money.Money{}, errors.New("products.parseAmount: " + err.Error())
+	}
+	if amount.Sign() < 0 {
+		return money.Money{}, errors.New("products.parseAmount: Amount cannot be negative")
+	}
+	return
+}
@@ -0,0 +1,152 @@
+package products
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bvnk/bank/money"
+)
+
+func TestProcessProduct(t *testing.T) {
+	tst := []string{"", ""}
+	_, err := ProcessProduct(tst)
+	if err == nil {
+		t.Errorf("ProcessProduct does not pass. Looking for %v, got %v", "Not all data is present", nil)
+	}
+
+	// Each request fails validation before the token is checked
+	invalid := map[string]struct {
+		data     []string
+		expected string
+	}{
+		"request type":      {[]string{"", "", "0"}, "Product request type invalid"},
+		"fields":            {[]string{"", "", "1", "savings", "Savings account"}, "Not all fields present"},
+		"code":              {[]string{"", "", "1", "Savings", "Savings account", "individual"}, "Code must be 1 to 20 lower case letters"},
+		"long code":         {[]string{"", "", "1", "a-very-long-product-code", "Savings account", "individual"}, "Code must be 1 to 20 lower case letters"},
+		"name":              {[]string{"", "", "1", "savings", "", "individual"}, "Name cannot be empty"},
+		"holders":           {[]string{"", "", "1", "savings", "Savings account", "company"}, "Holders must be individual, merchant or both"},
+		"duplicate holders": {[]string{"", "", "1", "savings", "Savings account", "individual,individual"}, "Holder individual appears twice"},
+		"opening balance":   {[]string{"", "", "1", "savings", "Savings account", "individual", "-100"}, "Amount cannot be negative"},
+		"monthly fee":       {[]string{"", "", "1", "savings", "Savings account", "individual", "0", "five"}, "Monthly fee not valid"},
+		"overdraft":         {[]string{"", "", "1", "savings", "Savings account", "individual", "0", "0", "maybe"}, "Overdraft eligibility must be true or false"},
+		"max overdraft":     {[]string{"", "", "1", "savings", "Savings account", "individual", "0", "0", "false", "500"}, "Only products eligible for overdrafts have a maximum overdraft"},
+		"interest type":     {[]string{"", "", "1", "savings", "Savings account", "individual", "0", "0", "false", "0", "compound"}, "Interest type must be none, deposit or loan"},
+		"currency":          {[]string{"", "", "1", "savings", "Savings account", "individual", "0", "0", "false", "0", "none", "XYZ"}, "Currency XYZ not valid"},
+		"currency decimals": {[]string{"", "", "1", "savings", "Savings account", "individual", "100.50", "0", "false", "0", "none", "JPY"}, "Amount has more decimal places than JPY allows"},
+		"cash account type": {[]string{"", "", "1", "savings", "Savings account", "individual", "0", "0", "false", "0", "deposit", "USD", "SAVE"}, "Cash account type must be an ISO 20022 code"},
+		"disbursements":     {[]string{"", "", "1", "cheque", "Cheque account", "individual", "0", "0", "true", "0", "deposit", "USD", "CACC", "maybe"}, "Receiving disbursements must be true or false"},
+		"term":              {[]string{"", "", "1", "cd", "Certificate of deposit", "individual", "0", "0", "false", "0", "deposit", "USD", "SVGS", "false", "yearly"}, "Term must be true or false"},
+		"status fields":     {[]string{"", "", "2", "savings"}, "Not all fields present"},
+		"status":            {[]string{"", "", "2", "savings", "closed"}, "Status must be active or withdrawn"},
+		"product code":      {[]string{"", "", "1001"}, "Not all data is present"},
+	}
+	for name, tst := range invalid {
+		_, err := ProcessProduct(tst.data)
+		if err == nil || !strings.Contains(err.Error(), tst.expected) {
+			t.Errorf("ProcessProduct %v does not pass. Looking for %v, got %v", name, tst.expected, err)
+		}
+	}
+}
+
+func TestParseHolders(t *testing.T) {
+	holders, err := parseHolders("individual, merchant")
+	if err != nil || len(holders) != 2 || holders[0] != HOLDER_INDIVIDUAL || holders[1] != HOLDER_MERCHANT {
+		t.Errorf("ParseHolders does not pass. Looking for %v, got %v %v", "[individual merchant]", holders, err)
+	}
+
+	_, err = parseHolders("")
+	if err == nil {
+		t.Errorf("ParseHolders empty does not pass. Looking for %v, got %v", "error", nil)
+	}
+}
+
+func TestCheckOpening(t *testing.T) {
+	product := Product{Code: "merchant", Holders: []string{HOLDER_MERCHANT}, Status: PRODUCT_ACTIVE}
+
+	err := product.CheckOpening(HOLDER_MERCHANT)
+	if err != nil {
+		t.Errorf("CheckOpening does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	err = product.CheckOpening(HOLDER_INDIVIDUAL)
+	if err == nil {
+		t.Errorf("CheckOpening holder does not pass. Looking for %v, got %v", "cannot be held by a individual", nil)
+	}
+
+	product.Status = PRODUCT_WITHDRAWN
+	err = product.CheckOpening(HOLDER_MERCHANT)
+	if err == nil {
+		t.Errorf("CheckOpening withdrawn does not pass. Looking for %v, got %v", "Product merchant is withdrawn", nil)
+	}
+}
+
+func TestCheckCurrency(t *testing.T) {
+	product := Product{Code: "savings", OpeningBalance: money.Zero("EUR"), MonthlyFee: money.Zero("EUR"), Currency: "EUR"}
+	if err := product.CheckCurrency(money.DEFAULT_CURRENCY); err != nil {
+		t.Errorf("CheckCurrency does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	product.MonthlyFee = money.RequireFromString("5", "EUR")
+	err := product.CheckCurrency(money.DEFAULT_CURRENCY)
+	if err == nil || !strings.Contains(err.Error(), "only offered in EUR") {
+		t.Errorf("CheckCurrency monthly fee does not pass. Looking for %v, got %v", "only offered in EUR", err)
+	}
+	if err := product.CheckCurrency("EUR"); err != nil {
+		t.Errorf("CheckCurrency product currency does not pass. Looking for %v, got %v", nil, err)
+	}
+}
+
+func TestCheckTerm(t *testing.T) {
+	product := Product{Code: "cd", Term: true}
+	err := product.CheckTerm(0)
+	if err == nil || !strings.Contains(err.Error(), "needs a term") {
+		t.Errorf("CheckTerm does not pass. Looking for %v, got %v", "needs a term", err)
+	}
+	if err := product.CheckTerm(12); err != nil {
+		t.Errorf("CheckTerm with term does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	product = Product{Code: "savings"}
+	if err := product.CheckTerm(0); err != nil {
+		t.Errorf("CheckTerm not a term product does not pass. Looking for %v, got %v", nil, err)
+	}
+}
+
+func TestCheckDisbursement(t *testing.T) {
+	product := Product{Code: "savings"}
+	err := product.CheckDisbursement()
+	if err == nil || !strings.Contains(err.Error(), "does not receive loan disbursements") {
+		t.Errorf("CheckDisbursement does not pass. Looking for %v, got %v", "does not receive loan disbursements", err)
+	}
+
+	product = Product{Code: "cheque", ReceivesDisbursements: true}
+	if err := product.CheckDisbursement(); err != nil {
+		t.Errorf("CheckDisbursement receiving does not pass. Looking for %v, got %v", nil, err)
+	}
+}
+
+func TestCheckOverdraft(t *testing.T) {
+	savings := Product{Code: "savings", MaxOverdraft: money.RequireFromString("0", money.DEFAULT_CURRENCY)}
+	if err := savings.CheckOverdraft(money.RequireFromString("100", money.DEFAULT_CURRENCY)); err == nil {
+		t.Errorf("CheckOverdraft not eligible does not pass. Looking for %v, got %v", "not eligible for overdrafts", nil)
+	}
+	if err := savings.CheckOverdraft(money.RequireFromString("0", money.DEFAULT_CURRENCY)); err != nil {
+		t.Errorf("CheckOverdraft revoke does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	cheque := Product{Code: "cheque", OverdraftEligible: true, MaxOverdraft: money.RequireFromString("500", money.DEFAULT_CURRENCY)}
+	if err := cheque.CheckOverdraft(money.RequireFromString("500", money.DEFAULT_CURRENCY)); err != nil {
+		t.Errorf("CheckOverdraft does not pass. Looking for %v, got %v", nil, err)
+	}
+	if err := cheque.CheckOverdraft(money.RequireFromString("500.01", money.DEFAULT_CURRENCY)); err == nil {
+		t.Errorf("CheckOverdraft maximum does not pass. Looking for %v, got %v", "Limit above the maximum", nil)
+	}
+	if err := cheque.CheckOverdraft(money.RequireFromString("100", money.DEFAULT_CURRENCY).In("EUR")); err == nil {
+		t.Errorf("CheckOverdraft currency does not pass. Looking for %v, got %v", "only offers overdrafts in USD", nil)
+	}
+
+	cheque.MaxOverdraft = money.RequireFromString("0", money.DEFAULT_CURRENCY)
+	if err := cheque.CheckOverdraft(money.RequireFromString("100000", money.DEFAULT_CURRENCY)); err != nil {
+		t.Errorf("CheckOverdraft without maximum does not pass. Looking for %v, got %v", nil, err)
+	}
+}
@@ -16,10 +16,16 @@ type PushDevice struct {
 }
 
 func SendNotification(accountNumber string, message string, badge uint, sound string) (err error) {
+	return SendNotificationWithData(accountNumber, message, badge, sound, nil)
+}
+
+// SendNotificationWithData sends a notification carrying custom values for the app, such as the
+// ID of a record to fetch
+func SendNotificationWithData(accountNumber string, message string, badge uint, sound string, data map[string]interface{}) (err error) {
 	// Get any push tokens for the user
 	pushDevices, err := getPushTokens(accountNumber)
 	if err != nil {
-		return errors.New("push.SendNotification: Could not get push devices " + err.Error())
+		return errors.New("push.SendNotificationWithData: Could not get push devices " + err.Error())
 	}
 
 	// Loop through
@@ -27,7 +33,7 @@ func SendNotification(accountNumber string, message string, badge uint, sound st
 		// Switch on device type
 		switch pd.Platform {
 		case "ios":
-			err = doSendNotificationAPNS(pd.Token, message, badge, sound)
+			err = doSendNotificationAPNSWithData(pd.Token, message, badge, sound, data)
 			if err != nil {
 				return err
 			}
@@ -40,6 +46,10 @@ func SendNotification(accountNumber string, message string, badge uint, sound st
 }
 
 func doSendNotificationAPNS(token string, message string, badge uint, sound string) (err error) {
+	return doSendNotificationAPNSWithData(token, message, badge, sound, nil)
+}
+
+func doSendNotificationAPNSWithData(token string, message string, badge uint, sound string, data map[string]interface{}) (err error) {
 	// Set vars
 	var gateway string
 	var apnsCert string
@@ -78,6 +88,12 @@ func doSendNotificationAPNS(token string, message string, badge uint, sound stri
 	p.APS.Badge.Set(badge)
 
 	//p.SetCustomValue("link", "yourapp://precache/20140718")
+	for key, value := range data {
+		err = p.SetCustomValue(key, value)
+		if err != nil {
+			return errors.New("Could not set custom value: " + err.Error())
+		}
+	}
 
 	m := apns.NewNotification()
 	m.Payload = p
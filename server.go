@@ -1,20 +1,33 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/rand"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"strconv"
 	"strings"
 
 	"github.com/bvnk/bank/accounts"
 	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/cards"
 	"github.com/bvnk/bank/configuration"
+	"github.com/bvnk/bank/fees"
+	"github.com/bvnk/bank/fx"
+	"github.com/bvnk/bank/interest"
+	"github.com/bvnk/bank/kyc"
+	"github.com/bvnk/bank/ledger"
+	"github.com/bvnk/bank/loans"
+	"github.com/bvnk/bank/products"
 	"github.com/bvnk/bank/push"
+	"github.com/bvnk/bank/statements"
 	"github.com/bvnk/bank/transactions"
+	"github.com/bvnk/bank/vault"
 )
 
 func runServer(mode string) (message string, err error) {
@@ -30,6 +43,39 @@ func runServer(mode string) (message string, err error) {
 	transactions.SetConfig(&Config)
 	appauth.SetConfig(&Config)
 	push.SetConfig(&Config)
+	ledger.SetConfig(&Config)
+	statements.SetConfig(&Config)
+	kyc.SetConfig(&Config)
+	interest.SetConfig(&Config)
+	loans.SetConfig(&Config)
+	products.SetConfig(&Config)
+	fees.SetConfig(&Config)
+	cards.SetConfig(&Config)
+	vault.SetConfig(&Config)
+	fx.SetConfig(&Config)
+
+	// Closing a term account before it matures costs the early withdrawal penalty
+	accounts.SetEarlyWithdrawalPenalty(interest.EarlyWithdrawalPenalty, interest.ChargeEarlyWithdrawalPenalty)
+
+	// FX rates can be kept in a file, loaded again with fx~2 when it changes
+	if Config.FXRatesFile != "" {
+		_, err := fx.LoadRates()
+		if err != nil {
+			bLog(3, err.Error(), trace())
+		}
+	}
+
+	// Card messages from acquirers arrive on a port of their own
+	if Config.CardPort != "" {
+		cardServerOnce.Do(func() {
+			go func() {
+				err := runCardServer(mode, Config)
+				if err != nil {
+					bLog(3, err.Error(), trace())
+				}
+			}()
+		})
+	}
 
 	switch mode {
 	case "tls":
@@ -86,14 +132,12 @@ func runServer(mode string) (message string, err error) {
 
 // Handles incoming requests.
 func handleTCPRequest(conn net.Conn) (err error) {
-	// Make a buffer to hold incoming data.
-	buf := make([]byte, 1024)
-	// Read the incoming connection into the buffer.
-	_, err = conn.Read(buf)
+	data, err := readCommand(conn)
 	if err != nil {
+		conn.Close()
 		return err
 	}
-	s := string(buf[:])
+	s := string(data)
 
 	// Process
 	result, err := processCommand(s)
@@ -118,6 +162,24 @@ func handleTCPRequest(conn net.Conn) (err error) {
 	return
 }
 
+// readCommand reads a command up to the newline that ends it, however many reads it arrives in:
+// TLS hands over one record at a time, so long commands (e.g. ISO 20022 XML documents) come in
+// pieces. A command the client ends by closing the connection is read as it is
+func readCommand(r io.Reader) (data []byte, err error) {
+	reader := bufio.NewReader(io.LimitReader(r, MAX_COMMAND_SIZE+1))
+	data, err = reader.ReadBytes('\n')
+	if err == io.EOF && len(data) > 0 {
+		err = nil
+	}
+	if err != nil {
+		return nil, errors.New("server.readCommand: " + err.Error())
+	}
+	if len(data) > MAX_COMMAND_SIZE {
+		return nil, errors.New("server.readCommand: Command longer than " + strconv.Itoa(MAX_COMMAND_SIZE) + " bytes")
+	}
+	return
+}
+
 func processCommand(text string) (result interface{}, err error) {
 	// Commands are received split by tilde (~)
 	// command~DATA
@@ -160,13 +222,84 @@ func processCommand(text string) (result interface{}, err error) {
 	case "pain":
 		// Check "help"
 		if command[2] == "help" {
-			return "Format of PAIN transaction:\npain\npainType~senderAccountNumber@SenderBankNumber\nreceiverAccountNumber@ReceiverBankNumber\ntransactionAmount\n\nBank numbers may be left void if bank is local", nil
+			return "Format of PAIN transaction:\npain\npainType~senderAccountNumber@SenderBankNumber\nreceiverAccountNumber@ReceiverBankNumber\ntransactionAmount~lat~lon~desc~currency\n\nBank numbers may be left void if bank is local\nThe currency may be left void to pay in the sender's currency\n\nFormat of PAIN status report:\npain\n2~transactionID\n\nFormat of PAIN credit transfer as pain.001 XML:\npain\n1003~base64(XML document)\n\nFormat of PAIN reversal:\npain\n7~transactionID~reasonCode~lat~lon~desc\n\nFormat of PAIN direct debit:\npain\n8~mandateID~amount~lat~lon~desc\n\nFormat of PAIN mandates:\npain\n9~creditorAccountNumber@~debtorAccountNumber@~maxAmount~frequency~desc\npain\n10~mandateID~maxAmount~frequency\npain\n11~mandateID\npain\n12~mandateID~accepted\n\nFormat of PAIN holds:\npain\n1004~accountNumber@~payeeAccountNumber@~amount~expiryHours~desc\npain\n1005~holdID~amount to capture, all of the hold when amount is empty\npain\n1006~holdID to cancel\npain\n1007~accountNumber to list active holds\npain\n1008 to release expired holds", nil
 		}
 		result, err = transactions.ProcessPAIN(command)
 		if err != nil {
 			return "", errors.New("server.processCommand: " + err.Error())
 		}
+	case "ledger":
+		// Check "help"
+		if command[2] == "help" {
+			return "Format of ledger request:\nledger~1 for the trial balance\nledger~2~accountNumber to reconcile an account against the journal", nil
+		}
+		result, err = ledger.ProcessLedger(command)
+		if err != nil {
+			return "", errors.New("server.processCommand: " + err.Error())
+		}
+	case "interest":
+		// Check "help"
+		if command[2] == "help" {
+			return "Format of interest product:\ninterest\n1~accountType~rateType~rate~dayCount~termMonths~penaltyDays~tiers\n\nRate types are fixed, variable and tiered, tiers are minimumBalance:rate pairs separated by commas\nDay counts are ACT/365 (default), ACT/360, ACT/ACT and 30/360\n\nFormat of variable rate change:\ninterest\n2~accountType~rate~effectiveDate\n\nFormat of daily accrual:\ninterest\n3~date\n\nFormat of monthly capitalisation:\ninterest\n4~month\n\nFormat of product list:\ninterest\n1000", nil
+		}
+		result, err = interest.ProcessInterest(command)
+		if err != nil {
+			return "", errors.New("server.processCommand: " + err.Error())
+		}
+	case "loan":
+		// Check "help"
+		if command[2] == "help" {
+			return "Format of loan origination:\nloan\n1~loanAccountNumber~repaymentAccountNumber~principal~rate~termMonths\n\nFormat of loan with schedule and arrears:\nloan\n2~loanAccountNumber\n\nFormat of repayment collection:\nloan\n3~date\n\nFormat of payoff quote:\nloan\n4~loanAccountNumber~date", nil
+		}
+		result, err = loans.ProcessLoan(command)
+		if err != nil {
+			return "", errors.New("server.processCommand: " + err.Error())
+		}
+	case "fx":
+		// Check "help"
+		if command[2] == "help" {
+			return "Format of FX rate:\nfx\n1~baseCurrency~quoteCurrency~midRate~spread\n\nOne baseCurrency is worth midRate quoteCurrency, spread is in percent\n\nFormat of FX rates file load:\nfx\n2\n\nLoads FXRatesFile from the config, with a baseCurrency,quoteCurrency,midRate,spread line per pair\n\nFormat of FX quote:\nfx\n3~fromCurrency~toCurrency~amount\n\nFormat of FX conversion:\nfx\n4~quoteID~senderAccountNumber~receiverAccountNumber~desc\n\nFormat of FX rate list:\nfx\n1000", nil
+		}
+		result, err = fx.ProcessFX(command)
+		if err != nil {
+			return "", errors.New("server.processCommand: " + err.Error())
+		}
+	case "product":
+		// Check "help"
+		if command[2] == "help" {
+			return "Format of product definition:\nproduct\n1~code~name~holders~openingBalance~monthlyFee~overdraftEligible~maxOverdraft~interestType~currency~cashAccountType~receivesDisbursements~term\n\nHolders are individual, merchant or both separated by a comma\nInterest types are none (default), deposit and loan\nAmounts are in the currency (USD when empty)\nCash account types are ISO 20022 codes such as CACC, SVGS or LOAN\nReceivesDisbursements and term are true or false (default)\n\nFormat of product status:\nproduct\n2~code~status\n\nStatuses are active and withdrawn\n\nFormat of product list:\nproduct\n1000\n\nFormat of single product:\nproduct\n1001~code", nil
+		}
+		result, err = products.ProcessProduct(command)
+		if err != nil {
+			return "", errors.New("server.processCommand: " + err.Error())
+		}
+	case "fee":
+		// Check "help"
+		if command[2] == "help" {
+			return "Format of fee schedule:\nfee\n1~code~desc~painType~accountType~merchantID~method~amount~rate~minimumFee~maximumFee~tiers~currency\n\nPAIN type, account type and merchant are empty for all\nSchedules only charge transactions in their currency, USD when empty\nMethods are flat (amount), percentage (rate) and tiered, tiers are minimumAmount:rate pairs separated by commas\nMaximum fee 0 means no maximum\n\nFormat of schedule withdrawal:\nfee\n2~scheduleID\n\nFormat of fee waiver:\nfee\n3~code~painType~accountNumber~accountType~merchantID~fromDate~toDate~reason\n\nAn empty code waives all fees, dates are YYYY-MM-DD and inclusive\n\nFormat of waiver cancellation:\nfee\n4~waiverID\n\nFormat of schedule list:\nfee\n1000\n\nFormat of waiver list:\nfee\n1001\n\nFormat of transaction fees:\nfee\n1002~transactionID", nil
+		}
+		result, err = fees.ProcessFee(command)
+		if err != nil {
+			return "", errors.New("server.processCommand: " + err.Error())
+		}
+	case "card":
+		// Check "help"
+		if command[2] == "help" {
+			return "Format of card issue:\ncard\n1~accountNumber~type~perTransactionLimit~dailyLimit\n\nTypes are virtual and physical, limits are in the account's currency and empty limits are no limit\n\nFormat of card activation:\ncard\n2~cardID~CVV\n\nFormat of card freeze, unfreeze, replacement and cancellation:\ncard\n3~cardID\ncard\n4~cardID\ncard\n5~cardID\ncard\n6~cardID\n\nFormat of card limits:\ncard\n7~cardID~perTransactionLimit~dailyLimit\n\nFormat of card list:\ncard\n1000~accountNumber\n\nFormat of vaulting cards issued before the vault:\ncard\n1001", nil
+		}
+		result, err = cards.ProcessCard(command)
+		if err != nil {
+			return "", errors.New("server.processCommand: " + err.Error())
+		}
 	case "camt":
+		// Check "help"
+		if command[2] == "help" {
+			return "Format of CAMT statement:\ncamt\n53~accountNumber~fromDate~toDate~format\n\nDates are YYYY-MM-DD and inclusive\n\nFormat of CAMT intraday report:\ncamt\n52~accountNumber~format\n\nFormat of CAMT debit/credit notification:\ncamt\n54~accountNumber~notificationID~format\ncamt\n1054~accountNumber~timestamp to list notifications\n\nFormat is json (default) or xml", nil
+		}
+		result, err = statements.ProcessCAMT(command)
+		if err != nil {
+			return "", errors.New("server.processCommand: " + err.Error())
+		}
 	case "acmt":
 		// Check "help"
 		if command[2] == "help" {
@@ -176,6 +309,14 @@ func processCommand(text string) (result interface{}, err error) {
 	case "remt":
 	case "reda":
 	case "pacs":
+		// Check "help"
+		if command[2] == "help" {
+			return "Format of PACS credit transfer from another bank:\npacs\n8~bankNumber~secret~base64(pacs.008 XML document)\n\nFormat of PACS resend of queued credit transfers:\npacs\n1001", nil
+		}
+		result, err = transactions.ProcessPACS(command)
+		if err != nil {
+			return "", errors.New("server.processCommand: " + err.Error())
+		}
 	case "auth":
 		break
 	default:
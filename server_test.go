@@ -2,7 +2,9 @@ package main
 
 import (
 	"net"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestRunTLSServer(t *testing.T) {
@@ -43,3 +45,28 @@ func TestHandleTCPRequest(t *testing.T) {
 	}
 
 }
+
+func TestReadCommand(t *testing.T) {
+	// TLS hands over long commands a record at a time, here a byte at a time
+	command := "token~pain~1003~" + strings.Repeat("A", 5000) + "\n"
+	data, err := readCommand(iotest.OneByteReader(strings.NewReader(command + "next")))
+	if err != nil || string(data) != command {
+		t.Errorf("ReadCommand does not pass. Looking for %v bytes, got %v %v", len(command), len(data), err)
+	}
+
+	// Without a newline the command ends with the connection
+	data, err = readCommand(iotest.HalfReader(strings.NewReader("token~pain~2~1")))
+	if err != nil || string(data) != "token~pain~2~1" {
+		t.Errorf("ReadCommand without newline does not pass. Looking for %v, got %v %v", "token~pain~2~1", string(data), err)
+	}
+
+	_, err = readCommand(strings.NewReader(strings.Repeat("A", MAX_COMMAND_SIZE+1)))
+	if err == nil || !strings.Contains(err.Error(), "Command longer than") {
+		t.Errorf("ReadCommand too long does not pass. Looking for %v, got %v", "Command longer than", err)
+	}
+
+	_, err = readCommand(strings.NewReader(""))
+	if err == nil {
+		t.Errorf("ReadCommand empty does not pass. Looking for %v, got %v", "EOF", nil)
+	}
+}
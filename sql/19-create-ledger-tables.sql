@@ -0,0 +1,64 @@
+/*
+Double-entry general ledger. Every posting writes one journal entry with two or more
+lines, and the debit lines of an entry always equal its credit lines.
+
+Customer accounts are ledger accounts keyed on their account number. The bank's own
+accounts are keyed on a code, e.g. bank:fee-income, bank:cash, bank:suspense
+*/
+CREATE TABLE IF NOT EXISTS ledger_journal (
+`id` int NOT NULL AUTO_INCREMENT,
+`transactionID` int NULL,
+`desc` varchar(512) NOT NULL,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS ledger_lines (
+`id` int NOT NULL AUTO_INCREMENT,
+`journalID` int NOT NULL,
+`ledgerAccount` varchar(64) NOT NULL,
+`direction` enum('debit', 'credit') NOT NULL,
+`amount` decimal(19,4) NOT NULL,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`)
+);
+
+CREATE INDEX ledger_journal_transaction
+ON ledger_journal (transactionID);
+
+CREATE INDEX ledger_lines_journal
+ON ledger_lines (journalID);
+CREATE INDEX ledger_lines_account
+ON ledger_lines (ledgerAccount);
+
+/*
+Bring forward the balances that existed before the ledger so that every account
+reconciles from day one. The difference is booked against bank:opening-balances
+*/
+INSERT INTO ledger_journal (`transactionID`, `desc`, `timestamp`)
+VALUES (NULL, 'Balances brought forward', UNIX_TIMESTAMP());
+
+SET @journalID = LAST_INSERT_ID();
+
+INSERT INTO ledger_lines (`journalID`, `ledgerAccount`, `direction`, `amount`, `timestamp`)
+SELECT @journalID, `accountNumber`, IF(`accountBalance` >= 0, 'credit', 'debit'), ABS(`accountBalance`), UNIX_TIMESTAMP()
+FROM accounts
+WHERE `accountBalance` != 0;
+
+INSERT INTO ledger_lines (`journalID`, `ledgerAccount`, `direction`, `amount`, `timestamp`)
+SELECT @journalID, 'bank:fee-income', 'credit', `balance`, UNIX_TIMESTAMP()
+FROM bank_account
+WHERE `balance` > 0;
+
+INSERT INTO ledger_lines (`journalID`, `ledgerAccount`, `direction`, `amount`, `timestamp`)
+SELECT @journalID, 'bank:opening-balances', IF(t.total >= 0, 'debit', 'credit'), ABS(t.total), UNIX_TIMESTAMP()
+FROM (SELECT
+	(SELECT COALESCE(SUM(`accountBalance`), 0) FROM accounts) +
+	(SELECT COALESCE(SUM(`balance`), 0) FROM bank_account WHERE `balance` > 0) AS total
+) t
+WHERE t.total != 0;
+
+/* Down
+DROP TABLE ledger_lines;
+DROP TABLE ledger_journal;
+*/
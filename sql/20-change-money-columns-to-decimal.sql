@@ -0,0 +1,42 @@
+/*
+Money is stored as exact DECIMAL instead of float. Four decimal places leave room for
+currencies with three minor units; amounts are rounded to the currency's minor unit in
+Go (money.Money) before they are written.
+
+Existing float values are converted as they are. They were already brought forward into
+the ledger at four decimal places (19-create-ledger-tables.sql), so accounts keep
+reconciling after the conversion.
+*/
+ALTER TABLE transactions
+MODIFY `transactionAmount` decimal(19,4) NOT NULL,
+MODIFY `feeAmount` decimal(19,4) NOT NULL;
+
+ALTER TABLE accounts
+MODIFY `accountBalance` decimal(19,4) NOT NULL,
+MODIFY `overdraft` decimal(19,4) NOT NULL,
+MODIFY `availableBalance` decimal(19,4) NOT NULL;
+
+ALTER TABLE bank_account
+MODIFY `balance` decimal(19,4) NOT NULL;
+
+ALTER TABLE bank_transactions
+MODIFY `transactionAmount` decimal(19,4) NOT NULL,
+MODIFY `feeAmount` decimal(19,4) NOT NULL;
+
+/* Down
+ALTER TABLE transactions
+MODIFY `transactionAmount` float NOT NULL,
+MODIFY `feeAmount` float NOT NULL;
+
+ALTER TABLE accounts
+MODIFY `accountBalance` float NOT NULL,
+MODIFY `overdraft` float NOT NULL,
+MODIFY `availableBalance` float NOT NULL;
+
+ALTER TABLE bank_account
+MODIFY `balance` float NOT NULL;
+
+ALTER TABLE bank_transactions
+MODIFY `transactionAmount` float NOT NULL,
+MODIFY `feeAmount` float NOT NULL;
+*/
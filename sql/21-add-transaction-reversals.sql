@@ -0,0 +1,15 @@
+/*
+A reversal (pain.007) is stored as its own transaction pointing back at the original.
+The original is marked 'reversed' so it cannot be reversed twice.
+*/
+ALTER TABLE transactions
+MODIFY `status` enum('approved', 'rejected', 'pending', 'reversed') NOT NULL DEFAULT 'approved',
+ADD `reversalOf` int(11) DEFAULT NULL,
+ADD KEY `reversalOf` (`reversalOf`);
+
+/* Down
+ALTER TABLE transactions
+DROP KEY `reversalOf`,
+DROP `reversalOf`,
+MODIFY `status` enum('approved', 'rejected', 'pending') NOT NULL DEFAULT 'approved';
+*/
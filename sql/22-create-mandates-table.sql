@@ -0,0 +1,34 @@
+/*
+Direct debit mandates (pain.008-pain.012). A merchant collects from a debtor account only
+under an active mandate, up to maxAmount and once per frequency period.
+*/
+CREATE TABLE IF NOT EXISTS mandates (
+`id` int NOT NULL AUTO_INCREMENT,
+`mandateID` char(36) UNIQUE NOT NULL,
+`merchantID` char(36) NOT NULL,
+`creditorAccountNumber` char(36) NOT NULL,
+`creditorBankNumber` char(36) NOT NULL,
+`debtorAccountNumber` char(36) NOT NULL,
+`debtorBankNumber` char(36) NOT NULL,
+`maxAmount` decimal(19,4) NOT NULL,
+`frequency` char(4) NOT NULL,
+`status` enum('pending', 'active', 'rejected', 'cancelled') NOT NULL DEFAULT 'pending',
+`desc` text NOT NULL,
+`lastCollection` int NOT NULL DEFAULT 0,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`),
+KEY `creditorAccountNumber` (`creditorAccountNumber`),
+KEY `debtorAccountNumber` (`debtorAccountNumber`)
+);
+
+ALTER TABLE transactions
+ADD `mandateID` char(36) DEFAULT NULL,
+ADD KEY `mandateID` (`mandateID`);
+
+/* Down
+ALTER TABLE transactions
+DROP KEY `mandateID`,
+DROP `mandateID`;
+
+DROP TABLE mandates;
+*/
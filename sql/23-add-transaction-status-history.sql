@@ -0,0 +1,39 @@
+/*
+Transactions move through received, pending, accepted, settled, rejected and reversed.
+Every change is recorded in transaction_status_history with its ISO 20022 reason code.
+
+Existing 'approved' transactions were posted and settled, so they become 'settled'.
+*/
+ALTER TABLE transactions
+MODIFY `status` enum('approved', 'rejected', 'pending', 'reversed', 'received', 'accepted', 'settled') NOT NULL DEFAULT 'received';
+
+UPDATE transactions SET `status` = 'settled' WHERE `status` = 'approved';
+
+ALTER TABLE transactions
+MODIFY `status` enum('received', 'pending', 'accepted', 'settled', 'rejected', 'reversed') NOT NULL DEFAULT 'received';
+
+CREATE TABLE IF NOT EXISTS transaction_status_history (
+`id` int NOT NULL AUTO_INCREMENT,
+`transactionID` int NOT NULL,
+`fromStatus` varchar(16) NOT NULL DEFAULT '',
+`toStatus` varchar(16) NOT NULL,
+`reasonCode` varchar(4) NOT NULL DEFAULT '',
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`),
+KEY `transactionID` (`transactionID`)
+);
+
+INSERT INTO transaction_status_history (`transactionID`, `fromStatus`, `toStatus`, `reasonCode`, `timestamp`)
+SELECT `id`, '', `status`, '', `timestamp` FROM transactions;
+
+/* Down
+DROP TABLE transaction_status_history;
+
+ALTER TABLE transactions
+MODIFY `status` enum('approved', 'rejected', 'pending', 'reversed', 'received', 'accepted', 'settled') NOT NULL DEFAULT 'approved';
+
+UPDATE transactions SET `status` = 'approved' WHERE `status` IN ('received', 'accepted', 'settled');
+
+ALTER TABLE transactions
+MODIFY `status` enum('approved', 'rejected', 'pending', 'reversed') NOT NULL DEFAULT 'approved';
+*/
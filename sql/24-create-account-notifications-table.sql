@@ -0,0 +1,21 @@
+/*
+Debit and credit notifications (camt.054). A notification is recorded for every local account
+a payment or deposit moves money on, in the same database transaction as the posting.
+*/
+CREATE TABLE IF NOT EXISTS account_notifications (
+`id` int NOT NULL AUTO_INCREMENT,
+`notificationID` char(36) UNIQUE NOT NULL,
+`accountNumber` char(36) NOT NULL,
+`transactionID` int NOT NULL,
+`creditDebit` enum('CRDT', 'DBIT') NOT NULL,
+`amount` decimal(19,4) NOT NULL,
+`currency` char(3) NOT NULL,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`),
+KEY `accountNumber` (`accountNumber`, `timestamp`),
+KEY `transactionID` (`transactionID`)
+);
+
+/* Down
+DROP TABLE account_notifications;
+*/
@@ -0,0 +1,26 @@
+/*
+Interbank messages (pacs.008 credit transfers and their pacs.002 status reports).
+Outgoing transfers are queued with the payment and stay queued until the receiving bank
+settles or rejects them. Incoming transfers are kept with the report sent back, so a
+message that is delivered twice is only posted once.
+*/
+CREATE TABLE IF NOT EXISTS interbank_messages (
+`id` int NOT NULL AUTO_INCREMENT,
+`messageID` varchar(35) NOT NULL,
+`direction` enum('in', 'out') NOT NULL,
+`bankNumber` char(36) NOT NULL,
+`transactionID` int DEFAULT NULL,
+`status` enum('queued', 'settled', 'rejected', 'received', 'answered') NOT NULL,
+`document` mediumtext NOT NULL,
+`response` mediumtext NOT NULL,
+`attempts` int NOT NULL DEFAULT 0,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`),
+UNIQUE KEY `message` (`direction`, `bankNumber`, `messageID`),
+KEY `transactionID` (`transactionID`),
+KEY `status` (`status`)
+);
+
+/* Down
+DROP TABLE interbank_messages;
+*/
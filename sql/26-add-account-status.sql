@@ -0,0 +1,13 @@
+/*
+Accounts are closed (acmt.019) rather than deleted, so the transactions that refer to them stay
+resolvable. Nothing can be posted to a closed account.
+*/
+ALTER TABLE accounts
+ADD `status` enum('open', 'closed') NOT NULL DEFAULT 'open' AFTER `type`,
+ADD `closedTimestamp` int NOT NULL DEFAULT 0 AFTER `status`;
+
+/* Down
+ALTER TABLE accounts
+DROP `closedTimestamp`,
+DROP `status`;
+*/
@@ -0,0 +1,26 @@
+/*
+Account holder details changed with acmt.003 keep their prior values. Every modification
+raises the holder's version, and each changed field is recorded against the version it
+replaced.
+*/
+ALTER TABLE accounts_users
+ADD `version` int NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS accounts_users_history (
+`id` int NOT NULL AUTO_INCREMENT,
+`accountHolderIdentificationNumber` varchar(64) NOT NULL,
+`version` int NOT NULL,
+`field` varchar(64) NOT NULL,
+`previousValue` text NOT NULL,
+`newValue` text NOT NULL,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`),
+KEY `accountHolderIdentificationNumber` (`accountHolderIdentificationNumber`, `version`)
+);
+
+/* Down
+DROP TABLE accounts_users_history;
+
+ALTER TABLE accounts_users
+DROP `version`;
+*/
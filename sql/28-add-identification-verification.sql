@@ -0,0 +1,37 @@
+/*
+Account holders are verified (acmt.023/acmt.024) before their accounts can make payments.
+Accounts opened for a holder who is not verified are restricted: they receive money but
+cannot pay it out, and open once the holder is verified. Existing accounts stay open.
+Every verification report is kept.
+*/
+ALTER TABLE accounts_users
+ADD `verificationStatus` enum('unverified', 'pending', 'verified', 'rejected') NOT NULL DEFAULT 'unverified',
+ADD `verificationTimestamp` int NOT NULL DEFAULT 0;
+
+ALTER TABLE accounts
+MODIFY `status` enum('restricted', 'open', 'closed') NOT NULL DEFAULT 'open';
+
+CREATE TABLE IF NOT EXISTS identification_verifications (
+`id` int NOT NULL AUTO_INCREMENT,
+`verificationID` char(32) UNIQUE NOT NULL,
+`accountHolderIdentificationNumber` varchar(64) NOT NULL,
+`verifier` varchar(32) NOT NULL,
+`status` enum('pending', 'verified', 'rejected') NOT NULL,
+`reason` text NULL,
+`reference` text NULL,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`),
+KEY `accountHolderIdentificationNumber` (`accountHolderIdentificationNumber`)
+);
+
+/* Down
+DROP TABLE identification_verifications;
+
+UPDATE accounts SET `status` = 'open' WHERE `status` = 'restricted';
+ALTER TABLE accounts
+MODIFY `status` enum('open', 'closed') NOT NULL DEFAULT 'open';
+
+ALTER TABLE accounts_users
+DROP `verificationTimestamp`,
+DROP `verificationStatus`;
+*/
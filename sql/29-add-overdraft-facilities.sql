@@ -0,0 +1,42 @@
+/*
+Overdraft facilities (acmt~1007). The limit is held in `overdraft` and included in the
+available balance. Accounts that end a day overdrawn are charged interest at the yearly
+rate and the daily fee (acmt~1008), at most once per day. Every change of limit is kept.
+*/
+ALTER TABLE accounts
+ADD `overdraftRate` decimal(9,6) NOT NULL DEFAULT 0 AFTER `overdraft`,
+ADD `overdraftDailyFee` decimal(19,4) NOT NULL DEFAULT 0 AFTER `overdraftRate`;
+
+CREATE TABLE IF NOT EXISTS accounts_overdraft_history (
+`id` int NOT NULL AUTO_INCREMENT,
+`accountNumber` char(36) NOT NULL,
+`previousLimit` decimal(19,4) NOT NULL,
+`newLimit` decimal(19,4) NOT NULL,
+`rate` decimal(9,6) NOT NULL,
+`dailyFee` decimal(19,4) NOT NULL,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`),
+KEY `accountNumber` (`accountNumber`)
+);
+
+CREATE TABLE IF NOT EXISTS overdraft_accruals (
+`id` int NOT NULL AUTO_INCREMENT,
+`accountNumber` char(36) NOT NULL,
+`accrualDate` date NOT NULL,
+`balance` decimal(19,4) NOT NULL,
+`interest` decimal(19,4) NOT NULL,
+`fee` decimal(19,4) NOT NULL,
+`journalID` int NOT NULL,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`),
+UNIQUE KEY `accrual` (`accountNumber`, `accrualDate`)
+);
+
+/* Down
+DROP TABLE overdraft_accruals;
+DROP TABLE accounts_overdraft_history;
+
+ALTER TABLE accounts
+DROP `overdraftDailyFee`,
+DROP `overdraftRate`;
+*/
@@ -0,0 +1,55 @@
+/*
+Interest on deposit accounts. Each account type can have one product (interest~1), with its
+tiers and, for variable rates, every change of rate (interest~2). Daily accruals (interest~3)
+are kept unrounded and linked to the interest transaction that capitalised them (interest~4).
+*/
+CREATE TABLE IF NOT EXISTS interest_products (
+`accountType` varchar(20) NOT NULL,
+`rateType` enum('fixed','variable','tiered') NOT NULL,
+`rate` decimal(9,6) NOT NULL,
+`dayCount` varchar(7) NOT NULL,
+`termMonths` int NOT NULL DEFAULT 0,
+`penaltyDays` int NOT NULL DEFAULT 0,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`accountType`)
+);
+
+CREATE TABLE IF NOT EXISTS interest_product_tiers (
+`id` int NOT NULL AUTO_INCREMENT,
+`accountType` varchar(20) NOT NULL,
+`minimumBalance` decimal(19,4) NOT NULL,
+`rate` decimal(9,6) NOT NULL,
+PRIMARY KEY (`id`),
+UNIQUE KEY `tier` (`accountType`, `minimumBalance`)
+);
+
+CREATE TABLE IF NOT EXISTS interest_rate_changes (
+`id` int NOT NULL AUTO_INCREMENT,
+`accountType` varchar(20) NOT NULL,
+`rate` decimal(9,6) NOT NULL,
+`effectiveDate` date NOT NULL,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`),
+UNIQUE KEY `change` (`accountType`, `effectiveDate`)
+);
+
+CREATE TABLE IF NOT EXISTS interest_accruals (
+`id` int NOT NULL AUTO_INCREMENT,
+`accountNumber` char(36) NOT NULL,
+`accrualDate` date NOT NULL,
+`balance` decimal(19,4) NOT NULL,
+`rate` decimal(9,6) NOT NULL,
+`amount` decimal(19,10) NOT NULL,
+`transactionID` int NULL,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`),
+UNIQUE KEY `accrual` (`accountNumber`, `accrualDate`),
+KEY `transactionID` (`transactionID`)
+);
+
+/* Down
+DROP TABLE interest_accruals;
+DROP TABLE interest_rate_changes;
+DROP TABLE interest_product_tiers;
+DROP TABLE interest_products;
+*/
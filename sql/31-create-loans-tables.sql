@@ -0,0 +1,53 @@
+/*
+Loans held on loan, mortgage and credit accounts (loan~1), with their amortisation schedule.
+Each instalment keeps what has been paid of its principal and interest, and every amount
+collected towards it (loan~3) is kept with the transaction that collected it.
+*/
+CREATE TABLE IF NOT EXISTS loans (
+`accountNumber` char(36) NOT NULL,
+`repaymentAccountNumber` char(36) NOT NULL,
+`principal` decimal(19,4) NOT NULL,
+`rate` decimal(9,6) NOT NULL,
+`termMonths` int NOT NULL,
+`payment` decimal(19,4) NOT NULL,
+`startDate` date NOT NULL,
+`status` enum('active','arrears','settled') NOT NULL DEFAULT 'active',
+`transactionID` int NOT NULL,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`accountNumber`),
+KEY `status` (`status`)
+);
+
+CREATE TABLE IF NOT EXISTS loan_instalments (
+`id` int NOT NULL AUTO_INCREMENT,
+`accountNumber` char(36) NOT NULL,
+`number` int NOT NULL,
+`dueDate` date NOT NULL,
+`payment` decimal(19,4) NOT NULL,
+`principal` decimal(19,4) NOT NULL,
+`interest` decimal(19,4) NOT NULL,
+`balance` decimal(19,4) NOT NULL,
+`principalPaid` decimal(19,4) NOT NULL DEFAULT 0,
+`interestPaid` decimal(19,4) NOT NULL DEFAULT 0,
+PRIMARY KEY (`id`),
+UNIQUE KEY `instalment` (`accountNumber`, `number`),
+KEY `dueDate` (`dueDate`)
+);
+
+CREATE TABLE IF NOT EXISTS loan_repayments (
+`id` int NOT NULL AUTO_INCREMENT,
+`accountNumber` char(36) NOT NULL,
+`instalment` int NOT NULL,
+`principal` decimal(19,4) NOT NULL,
+`interest` decimal(19,4) NOT NULL,
+`transactionID` int NOT NULL,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`),
+KEY `accountNumber` (`accountNumber`)
+);
+
+/* Down
+DROP TABLE loan_repayments;
+DROP TABLE loan_instalments;
+DROP TABLE loans;
+*/
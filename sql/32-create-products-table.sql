@@ -0,0 +1,54 @@
+/*
+Product catalogue (product~1). Every account is opened as a product, its type being the
+product code, so account types are no longer a fixed list. Products define who can hold them,
+the opening balance, the monthly fee (acmt~1009), whether they can have an overdraft and how
+they deal with interest. The existing account types are seeded with no opening balance.
+*/
+CREATE TABLE IF NOT EXISTS products (
+`code` varchar(20) NOT NULL,
+`name` varchar(100) NOT NULL,
+`holders` set('individual','merchant') NOT NULL,
+`openingBalance` decimal(19,4) NOT NULL DEFAULT 0,
+`monthlyFee` decimal(19,4) NOT NULL DEFAULT 0,
+`overdraftEligible` tinyint(1) NOT NULL DEFAULT 0,
+`maxOverdraft` decimal(19,4) NOT NULL DEFAULT 0,
+`interestType` enum('none','deposit','loan') NOT NULL DEFAULT 'none',
+`status` enum('active','withdrawn') NOT NULL DEFAULT 'active',
+`timestamp` int NOT NULL,
+PRIMARY KEY (`code`)
+);
+
+INSERT INTO products (`code`, `name`, `holders`, `overdraftEligible`, `interestType`, `timestamp`) VALUES
+('savings', 'Savings account', 'individual', 0, 'deposit', UNIX_TIMESTAMP()),
+('cheque', 'Cheque account', 'individual', 1, 'deposit', UNIX_TIMESTAMP()),
+('merchant', 'Merchant account', 'merchant', 1, 'none', UNIX_TIMESTAMP()),
+('money-market', 'Money market account', 'individual', 0, 'deposit', UNIX_TIMESTAMP()),
+('cd', 'Certificate of deposit', 'individual', 0, 'deposit', UNIX_TIMESTAMP()),
+('ira', 'Individual retirement account', 'individual', 0, 'deposit', UNIX_TIMESTAMP()),
+('rcp', 'Revolving credit plan', 'individual', 1, 'none', UNIX_TIMESTAMP()),
+('credit', 'Credit account', 'individual,merchant', 1, 'loan', UNIX_TIMESTAMP()),
+('mortgage', 'Mortgage', 'individual,merchant', 0, 'loan', UNIX_TIMESTAMP()),
+('loan', 'Loan', 'individual,merchant', 0, 'loan', UNIX_TIMESTAMP());
+
+ALTER TABLE accounts
+MODIFY `type` varchar(20) NOT NULL DEFAULT 'cheque';
+
+CREATE TABLE IF NOT EXISTS account_monthly_fees (
+`id` int NOT NULL AUTO_INCREMENT,
+`accountNumber` char(36) NOT NULL,
+`month` char(7) NOT NULL,
+`amount` decimal(19,4) NOT NULL,
+`journalID` int NOT NULL,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`),
+UNIQUE KEY `charge` (`accountNumber`, `month`)
+);
+
+/* Down
+DROP TABLE account_monthly_fees;
+
+ALTER TABLE accounts
+MODIFY `type` enum('savings', 'cheque', 'merchant', 'money-market', 'cd', 'ira', 'rcp', 'credit', 'mortgage', 'loan') NOT NULL DEFAULT 'cheque';
+
+DROP TABLE products;
+*/
@@ -0,0 +1,96 @@
+/*
+Fee schedules (fee~1) replace the flat 0.01% transaction fee. The fee engine charges each
+transaction the fees of the schedules that apply to it, less waivers (fee~3), and keeps the
+itemised breakdown in transaction_fees. Each fee is credited to its own income ledger account,
+bank:fee-income:<code>, so the bank_account row is no longer kept up to date and is dropped;
+its balance was moved to bank:fee-income when the ledger was created.
+
+The old fee is seeded as a schedule per PAIN type it was charged on, and the fees of existing
+transactions are itemised against bank:fee-income, so refunds of them reverse the original
+income account.
+*/
+CREATE TABLE IF NOT EXISTS fee_schedules (
+`id` int NOT NULL AUTO_INCREMENT,
+`code` varchar(20) NOT NULL,
+`desc` varchar(100) NOT NULL,
+`painType` int NOT NULL DEFAULT 0,
+`accountType` varchar(20) NOT NULL DEFAULT '',
+`merchantID` char(36) NOT NULL DEFAULT '',
+`method` enum('flat','percentage','tiered') NOT NULL,
+`amount` decimal(19,4) NOT NULL DEFAULT 0,
+`rate` decimal(9,6) NOT NULL DEFAULT 0,
+`minimumFee` decimal(19,4) NOT NULL DEFAULT 0,
+`maximumFee` decimal(19,4) NOT NULL DEFAULT 0,
+`status` enum('active','withdrawn') NOT NULL DEFAULT 'active',
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`),
+KEY `code` (`code`)
+);
+
+CREATE TABLE IF NOT EXISTS fee_schedule_tiers (
+`id` int NOT NULL AUTO_INCREMENT,
+`scheduleID` int NOT NULL,
+`minimumAmount` decimal(19,4) NOT NULL,
+`rate` decimal(9,6) NOT NULL,
+PRIMARY KEY (`id`),
+UNIQUE KEY `tier` (`scheduleID`, `minimumAmount`)
+);
+
+CREATE TABLE IF NOT EXISTS fee_waivers (
+`id` int NOT NULL AUTO_INCREMENT,
+`code` varchar(20) NOT NULL DEFAULT '',
+`painType` int NOT NULL DEFAULT 0,
+`accountNumber` char(36) NOT NULL DEFAULT '',
+`accountType` varchar(20) NOT NULL DEFAULT '',
+`merchantID` char(36) NOT NULL DEFAULT '',
+`fromDate` date DEFAULT NULL,
+`toDate` date DEFAULT NULL,
+`reason` varchar(255) NOT NULL,
+`status` enum('active','withdrawn') NOT NULL DEFAULT 'active',
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS transaction_fees (
+`id` int NOT NULL AUTO_INCREMENT,
+`transactionID` int NOT NULL,
+`code` varchar(20) NOT NULL,
+`desc` varchar(100) NOT NULL,
+`scheduleID` int NOT NULL DEFAULT 0,
+`waiverID` int NOT NULL DEFAULT 0,
+`amount` decimal(19,4) NOT NULL,
+`incomeAccount` varchar(64) NOT NULL,
+PRIMARY KEY (`id`),
+KEY `transactionID` (`transactionID`)
+);
+
+INSERT INTO fee_schedules (`code`, `desc`, `painType`, `method`, `rate`, `timestamp`) VALUES
+('payment', 'Payment fee', 1, 'percentage', 0.01, UNIX_TIMESTAMP()),
+('direct-debit', 'Direct debit collection fee', 8, 'percentage', 0.01, UNIX_TIMESTAMP()),
+('deposit', 'Deposit fee', 1000, 'percentage', 0.01, UNIX_TIMESTAMP());
+
+INSERT INTO transaction_fees (`transactionID`, `code`, `desc`, `amount`, `incomeAccount`)
+SELECT `id`, 'transaction', 'Transaction fee', `feeAmount`, 'bank:fee-income'
+FROM transactions
+WHERE `feeAmount` > 0;
+
+DROP TABLE bank_account;
+
+/* Down
+CREATE TABLE IF NOT EXISTS `bank_account` (
+`id` int NOT NULL AUTO_INCREMENT,
+`balance` decimal(19,4) NOT NULL,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`)
+);
+
+INSERT INTO `bank_account`
+SELECT 1, COALESCE(SUM(IF(`direction` = 'credit', `amount`, -`amount`)), 0), UNIX_TIMESTAMP()
+FROM ledger_lines
+WHERE `ledgerAccount` LIKE 'bank:fee-income%';
+
+DROP TABLE transaction_fees;
+DROP TABLE fee_waivers;
+DROP TABLE fee_schedule_tiers;
+DROP TABLE fee_schedules;
+*/
@@ -0,0 +1,33 @@
+/*
+Authorisation holds (pain~1004-1008). A hold reserves funds on an account for a payee: only
+the available balance goes down. The payee captures all or part of it as a payment, which
+releases the rest, or cancels it, and holds not captured by their expiry are released.
+The payment a hold was captured as points back at the hold.
+*/
+CREATE TABLE IF NOT EXISTS holds (
+`id` int NOT NULL AUTO_INCREMENT,
+`accountNumber` char(36) NOT NULL,
+`payeeAccountNumber` char(36) NOT NULL,
+`amount` decimal(19,4) NOT NULL,
+`capturedAmount` decimal(19,4) NOT NULL DEFAULT 0,
+`status` enum('active', 'captured', 'cancelled', 'expired') NOT NULL DEFAULT 'active',
+`desc` text NOT NULL,
+`expires` int NOT NULL,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`),
+KEY `accountNumber` (`accountNumber`, `status`),
+KEY `payeeAccountNumber` (`payeeAccountNumber`, `status`),
+KEY `expires` (`status`, `expires`)
+);
+
+ALTER TABLE transactions
+ADD `holdID` int DEFAULT NULL,
+ADD KEY `holdID` (`holdID`);
+
+/* Down
+ALTER TABLE transactions
+DROP KEY `holdID`,
+DROP `holdID`;
+
+DROP TABLE holds;
+*/
@@ -0,0 +1,33 @@
+/*
+ISO 8583 card messages received from acquirers. Every authorisation, financial and reversal
+request is logged with the response it got, so a repeated request gets the same answer and a
+reversal can find the hold or payment of the message it reverses. Messages are identified by
+the acquirer, MTI (without the repeat digit), STAN and transmission time.
+*/
+CREATE TABLE IF NOT EXISTS card_messages (
+`id` int NOT NULL AUTO_INCREMENT,
+`mti` char(4) NOT NULL,
+`acquirerID` varchar(11) NOT NULL,
+`stan` char(6) NOT NULL,
+`transmissionTime` char(10) NOT NULL,
+`localTime` varchar(12) NOT NULL,
+`terminalID` varchar(8) NOT NULL,
+`cardAcceptorID` varchar(15) NOT NULL,
+`rrn` varchar(12) NOT NULL,
+`accountNumber` char(36) NOT NULL,
+`amount` decimal(19,4) NOT NULL,
+`holdID` int DEFAULT NULL,
+`transactionID` int DEFAULT NULL,
+`responseCode` char(2) NOT NULL,
+`authorisationCode` varchar(6) NOT NULL,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`),
+UNIQUE KEY `message` (`acquirerID`, `mti`, `stan`, `transmissionTime`),
+KEY `localTime` (`acquirerID`, `mti`, `stan`, `localTime`),
+KEY `holdID` (`holdID`),
+KEY `transactionID` (`transactionID`)
+);
+
+/* Down
+DROP TABLE card_messages;
+*/
@@ -0,0 +1,35 @@
+/*
+Cards issued against accounts. The PAN is kept so card messages can find the card; the expiry
+and CVV are only kept hashed, salted with the PAN. Zero limits are no limit. Card messages
+record the card they were for, and whether they were reversed, so a card's spending today can
+be totalled against its daily limit.
+*/
+CREATE TABLE IF NOT EXISTS cards (
+`id` int NOT NULL AUTO_INCREMENT,
+`userID` varchar(36) NOT NULL,
+`accountNumber` char(36) NOT NULL,
+`type` enum('virtual','physical') NOT NULL,
+`pan` varchar(19) NOT NULL,
+`panLast4` char(4) NOT NULL,
+`expiryHash` varchar(128) NOT NULL,
+`cvvHash` varchar(128) NOT NULL,
+`status` enum('inactive','active','frozen','cancelled') NOT NULL,
+`perTransactionLimit` decimal(19,4) NOT NULL DEFAULT 0,
+`dailyLimit` decimal(19,4) NOT NULL DEFAULT 0,
+`replacesCardID` int DEFAULT NULL,
+`expires` int NOT NULL,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`),
+UNIQUE KEY `pan` (`pan`),
+KEY `accountNumber` (`accountNumber`)
+);
+
+ALTER TABLE card_messages
+ADD COLUMN `cardID` int DEFAULT NULL AFTER `id`,
+ADD COLUMN `reversed` tinyint NOT NULL DEFAULT 0 AFTER `authorisationCode`,
+ADD KEY `cardID` (`cardID`, `timestamp`);
+
+/* Down
+ALTER TABLE card_messages DROP KEY `cardID`, DROP COLUMN `reversed`, DROP COLUMN `cardID`;
+DROP TABLE cards;
+*/
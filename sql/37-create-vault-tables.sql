@@ -0,0 +1,38 @@
+/*
+The card vault. PANs are kept here only, encrypted, and exchanged for tokens that keep their
+BIN and last four digits but fail the Luhn check. The PAN is found by a keyed hash (panHash).
+Every detokenisation is logged with its caller, including refused ones. Cards now hold the
+token of their PAN; cards issued before the vault still hold their PAN until they are
+tokenised with card~1001.
+*/
+CREATE TABLE IF NOT EXISTS vault (
+`id` int NOT NULL AUTO_INCREMENT,
+`token` varchar(19) NOT NULL,
+`panHash` char(64) NOT NULL,
+`pan` varbinary(64) NOT NULL,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`),
+UNIQUE KEY `token` (`token`),
+UNIQUE KEY `panHash` (`panHash`)
+);
+
+CREATE TABLE IF NOT EXISTS vault_detokenisations (
+`id` int NOT NULL AUTO_INCREMENT,
+`token` varchar(19) NOT NULL,
+`caller` varchar(64) NOT NULL,
+`allowed` tinyint NOT NULL,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`),
+KEY `token` (`token`)
+);
+
+ALTER TABLE cards
+DROP KEY `pan`,
+CHANGE COLUMN `pan` `token` varchar(19) NOT NULL,
+ADD UNIQUE KEY `token` (`token`);
+
+/* Down
+ALTER TABLE cards DROP KEY `token`, CHANGE COLUMN `token` `pan` varchar(19) NOT NULL, ADD UNIQUE KEY `pan` (`pan`);
+DROP TABLE vault_detokenisations;
+DROP TABLE vault;
+*/
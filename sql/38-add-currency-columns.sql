@@ -0,0 +1,29 @@
+/*
+Currencies. Accounts are held in one ISO 4217 currency, chosen when they are opened, and the
+transactions, holds and mandates on them carry the currency of their amounts. Amounts are
+rounded to the minor unit of their currency. Existing rows are in US dollars, the currency
+they were created in. Fee schedules are priced in one currency and only charge transactions
+in it.
+*/
+ALTER TABLE accounts
+ADD COLUMN `currency` char(3) NOT NULL DEFAULT 'USD' AFTER `bankNumber`;
+
+ALTER TABLE transactions
+ADD COLUMN `currency` char(3) NOT NULL DEFAULT 'USD' AFTER `transactionAmount`;
+
+ALTER TABLE holds
+ADD COLUMN `currency` char(3) NOT NULL DEFAULT 'USD' AFTER `amount`;
+
+ALTER TABLE mandates
+ADD COLUMN `currency` char(3) NOT NULL DEFAULT 'USD' AFTER `maxAmount`;
+
+ALTER TABLE fee_schedules
+ADD COLUMN `currency` char(3) NOT NULL DEFAULT 'USD' AFTER `maximumFee`;
+
+/* Down
+ALTER TABLE fee_schedules DROP COLUMN `currency`;
+ALTER TABLE mandates DROP COLUMN `currency`;
+ALTER TABLE holds DROP COLUMN `currency`;
+ALTER TABLE transactions DROP COLUMN `currency`;
+ALTER TABLE accounts DROP COLUMN `currency`;
+*/
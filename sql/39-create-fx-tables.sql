@@ -0,0 +1,45 @@
+/*
+Foreign exchange. Rates are mid rates set by the bank, per currency pair, with the spread the
+bank takes in percent. Quotes fix a rate for the user they are issued to until they expire.
+Executed quotes are posted as a transaction that records the rate it was converted at and
+the amount the receiver was paid in their currency.
+*/
+CREATE TABLE IF NOT EXISTS fx_rates (
+`baseCurrency` char(3) NOT NULL,
+`quoteCurrency` char(3) NOT NULL,
+`midRate` decimal(19,8) NOT NULL,
+`spread` decimal(9,6) NOT NULL,
+`source` varchar(16) NOT NULL,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`baseCurrency`, `quoteCurrency`)
+);
+
+CREATE TABLE IF NOT EXISTS fx_quotes (
+`id` int NOT NULL AUTO_INCREMENT,
+`quoteID` char(36) NOT NULL,
+`userID` varchar(36) NOT NULL,
+`amount` decimal(19,4) NOT NULL,
+`currency` char(3) NOT NULL,
+`convertedAmount` decimal(19,4) NOT NULL,
+`convertedCurrency` char(3) NOT NULL,
+`midRate` decimal(19,8) NOT NULL,
+`rate` decimal(19,8) NOT NULL,
+`spread` decimal(9,6) NOT NULL,
+`status` varchar(16) NOT NULL,
+`transactionID` int NOT NULL DEFAULT 0,
+`expires` int NOT NULL,
+`timestamp` int NOT NULL,
+PRIMARY KEY (`id`),
+UNIQUE KEY `quoteID` (`quoteID`)
+);
+
+ALTER TABLE transactions
+ADD COLUMN `fxRate` decimal(19,8) NULL AFTER `currency`,
+ADD COLUMN `convertedAmount` decimal(19,4) NULL AFTER `fxRate`,
+ADD COLUMN `convertedCurrency` char(3) NULL AFTER `convertedAmount`;
+
+/* Down
+ALTER TABLE transactions DROP COLUMN `convertedCurrency`, DROP COLUMN `convertedAmount`, DROP COLUMN `fxRate`;
+DROP TABLE fx_quotes;
+DROP TABLE fx_rates;
+*/
@@ -0,0 +1,14 @@
+/*
+Users are customers unless the bank makes them operators. Operators run the bank: they set
+products, prices, rates and overdrafts, originate loans, run the daily and monthly jobs and
+read the books. There is no request to grant the role, it is set here:
+
+UPDATE accounts_user_auth SET `role` = 'operator' WHERE `authUser` = ?;
+*/
+ALTER TABLE accounts_user_auth
+ADD `role` enum('customer', 'operator') NOT NULL DEFAULT 'customer' AFTER `authUser`;
+
+/* Down
+ALTER TABLE accounts_user_auth
+DROP `role`;
+*/
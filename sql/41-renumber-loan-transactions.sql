@@ -0,0 +1,11 @@
+/*
+Loan disbursements and repayments were recorded with the loan request type, and a disbursement
+(type 1) could be mistaken for a PAIN credit transfer. They move to types clear of the PAIN types
+*/
+UPDATE transactions SET `type` = 2001 WHERE `transaction` = 'loan' AND `type` = 1;
+UPDATE transactions SET `type` = 2003 WHERE `transaction` = 'loan' AND `type` = 3;
+
+/* Down
+UPDATE transactions SET `type` = 1 WHERE `transaction` = 'loan' AND `type` = 2001;
+UPDATE transactions SET `type` = 3 WHERE `transaction` = 'loan' AND `type` = 2003;
+*/
@@ -0,0 +1,11 @@
+/*
+Products are priced in one currency. Their opening balance, monthly fee and maximum overdraft
+are in it, and products with an opening balance or monthly fee are only opened in it. Existing
+products are in US dollars, the currency they were created in.
+*/
+ALTER TABLE products
+ADD COLUMN `currency` char(3) NOT NULL DEFAULT 'USD' AFTER `interestType`;
+
+/* Down
+ALTER TABLE products DROP COLUMN `currency`;
+*/
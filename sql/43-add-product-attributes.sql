@@ -0,0 +1,23 @@
+/*
+Product attributes that were decided by product code. Products carry the ISO 20022 cash account
+type their accounts are reported with (acmt~13), whether loans can be disbursed to their
+accounts and whether they are held for a fixed term. The seeded products keep the codes,
+disbursements and terms they had.
+*/
+ALTER TABLE products
+ADD COLUMN `cashAccountType` varchar(4) NOT NULL DEFAULT '' AFTER `currency`,
+ADD COLUMN `receivesDisbursements` tinyint(1) NOT NULL DEFAULT 0 AFTER `cashAccountType`,
+ADD COLUMN `term` tinyint(1) NOT NULL DEFAULT 0 AFTER `receivesDisbursements`;
+
+UPDATE products SET `cashAccountType` = 'SVGS' WHERE `code` = 'savings';
+UPDATE products SET `cashAccountType` = 'CACC', `receivesDisbursements` = 1 WHERE `code` = 'cheque';
+UPDATE products SET `cashAccountType` = 'CACC' WHERE `code` = 'merchant';
+UPDATE products SET `cashAccountType` = 'MOMA' WHERE `code` = 'money-market';
+UPDATE products SET `cashAccountType` = 'LOAN' WHERE `code` IN ('loan', 'mortgage');
+UPDATE products SET `term` = 1 WHERE `code` = 'cd';
+
+/* Down
+ALTER TABLE products DROP COLUMN `term`;
+ALTER TABLE products DROP COLUMN `receivesDisbursements`;
+ALTER TABLE products DROP COLUMN `cashAccountType`;
+*/
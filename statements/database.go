@@ -0,0 +1,205 @@
+package statements
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/bvnk/bank/configuration"
+	"github.com/bvnk/bank/ledger"
+	"github.com/bvnk/bank/money"
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+var Config configuration.Configuration
+
+func SetConfig(config *configuration.Configuration) {
+	Config = *config
+}
+
+// getBookedBalanceBefore is the account's ledger balance at the start of a period
+func getBookedBalanceBefore(accountNumber string, timestamp int32) (balance decimal.Decimal, err error) {
+	balance, err = ledger.GetLedgerBalanceBefore(accountNumber, timestamp)
+	if err != nil {
+		return decimal.Zero, errors.New("statements.getBookedBalanceBefore: " + err.Error())
+	}
+	return
+}
+
+// getLedgerRows returns the journal entries that touched the account in [from, to), netted per
+// journal, with the transaction each one posted. Entries without a transaction (such as the
+// opening balance) have zero values for the transaction fields
+func getLedgerRows(accountNumber string, from int32, to int32) (rows []ledgerRow, err error) {
+	rows, err = queryLedgerRows("`timestamp` >= ? AND `timestamp` < ?", accountNumber, from, to)
+	if err != nil {
+		return []ledgerRow{}, errors.New("statements.getLedgerRows: " + err.Error())
+	}
+	return
+}
+
+// getLedgerRowsForTransaction returns the journal entries a transaction booked on the account
+func getLedgerRowsForTransaction(accountNumber string, transactionID int64) (rows []ledgerRow, err error) {
+	rows, err = queryLedgerRows("`journalID` IN (SELECT `id` FROM `ledger_journal` WHERE `transactionID` = ?)", accountNumber, transactionID)
+	if err != nil {
+		return []ledgerRow{}, errors.New("statements.getLedgerRowsForTransaction: " + err.Error())
+	}
+	return
+}
+
+// queryLedgerRows selects the account's ledger lines matching linesWhere, which is given the
+// arguments after the account number
+func queryLedgerRows(linesWhere string, accountNumber string, args ...interface{}) (rows []ledgerRow, err error) {
+	query := "SELECT `j`.`id`, COALESCE(`j`.`transactionID`, 0), `j`.`desc`, `j`.`timestamp`, `x`.`net`, " +
+		"COALESCE(`t`.`type`, 0), COALESCE(`t`.`senderAccountNumber`, ''), COALESCE(`t`.`senderBankNumber`, ''), " +
+		"COALESCE(`t`.`receiverAccountNumber`, ''), COALESCE(`t`.`receiverBankNumber`, ''), COALESCE(`t`.`feeAmount`, 0), " +
+		"COALESCE(`t`.`status`, ''), COALESCE(`t`.`mandateID`, ''), COALESCE(`t`.`currency`, ''), COALESCE(`t`.`convertedCurrency`, '') " +
+		"FROM (SELECT `journalID`, SUM(CASE WHEN `direction` = 'credit' THEN `amount` ELSE -`amount` END) AS `net` " +
+		"FROM `ledger_lines` WHERE `ledgerAccount` = ? AND " + linesWhere + " GROUP BY `journalID`) `x` " +
+		"JOIN `ledger_journal` `j` ON `j`.`id` = `x`.`journalID` " +
+		"LEFT JOIN `transactions` `t` ON `t`.`id` = `j`.`transactionID` " +
+		"ORDER BY `j`.`id` ASC"
+	results, err := Config.Db.Query(query, append([]interface{}{accountNumber}, args...)...)
+	if err != nil {
+		return []ledgerRow{}, errors.New("statements.queryLedgerRows: " + err.Error())
+	}
+	defer results.Close()
+
+	for results.Next() {
+		row := ledgerRow{}
+		if err := results.Scan(&row.JournalID, &row.TransactionID, &row.Desc, &row.Timestamp, &row.Net, &row.PainType, &row.Sender.AccountNumber, &row.Sender.BankNumber, &row.Receiver.AccountNumber, &row.Receiver.BankNumber, &row.Fee, &row.Status, &row.MandateID, &row.Currency, &row.ConvertedCurrency); err != nil {
+			return []ledgerRow{}, errors.New("statements.queryLedgerRows: Could not retrieve entries. " + err.Error())
+		}
+		rows = append(rows, row)
+	}
+
+	return
+}
+
+// getAccountBalances returns the booked and available balances held on the account
+func getAccountBalances(accountNumber string) (bookedBalance money.Money, availableBalance money.Money, err error) {
+	rows, err := Config.Db.Query("SELECT `accountBalance`, `availableBalance`, `currency` FROM `accounts` WHERE `accountNumber` = ?", accountNumber)
+	if err != nil {
+		return money.Money{}, money.Money{}, errors.New("statements.getAccountBalances: " + err.Error())
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var currency string
+		if err := rows.Scan(&bookedBalance, &availableBalance, &currency); err != nil {
+			return money.Money{}, money.Money{}, errors.New("statements.getAccountBalances: Could not retrieve account details. " + err.Error())
+		}
+		bookedBalance = bookedBalance.In(currency)
+		availableBalance = availableBalance.In(currency)
+		count++
+	}
+
+	if count == 0 {
+		return money.Money{}, money.Money{}, errors.New("statements.getAccountBalances: Account not found")
+	}
+
+	return
+}
+
+// getAccountCurrency returns the currency the account is held in
+func getAccountCurrency(accountNumber string) (currency string, err error) {
+	err = Config.Db.QueryRow("SELECT `currency` FROM `accounts` WHERE `accountNumber` = ?", accountNumber).Scan(&currency)
+	if err == sql.ErrNoRows {
+		return "", errors.New("statements.getAccountCurrency: Account not found")
+	}
+	if err != nil {
+		return "", errors.New("statements.getAccountCurrency: " + err.Error())
+	}
+	return
+}
+
+// SaveNotification records a camt.054 notification for a transaction that moved money on a
+// local account. db is the caller's database transaction, so the notification commits with
+// the posting
+func SaveNotification(db ledger.Preparer, accountNumber string, transactionID int64, creditDebit string, amount money.Money, timestamp int32) (notificationID string, err error) {
+	newUuid, err := uuid.NewV4()
+	if err != nil {
+		return "", errors.New("statements.SaveNotification: Could not generate notification ID. " + err.Error())
+	}
+	notificationID = newUuid.String()
+
+	insertStatement := "INSERT INTO account_notifications (`notificationID`, `accountNumber`, `transactionID`, `creditDebit`, `amount`, `currency`, `timestamp`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?)"
+	stmtIns, err := db.Prepare(insertStatement)
+	if err != nil {
+		return "", errors.New("statements.SaveNotification: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	_, err = stmtIns.Exec(notificationID, accountNumber, transactionID, creditDebit, amount, amount.Currency, timestamp)
+	if err != nil {
+		return "", errors.New("statements.SaveNotification: " + err.Error())
+	}
+
+	return
+}
+
+const notificationColumns = "`notificationID`, `accountNumber`, `transactionID`, `creditDebit`, `currency`, `amount`, `timestamp`"
+
+func scanNotifications(rows *sql.Rows) (notifications []Notification, err error) {
+	for rows.Next() {
+		notification := Notification{}
+		// The currency is scanned first so the amount keeps it
+		if err := rows.Scan(&notification.NotificationID, &notification.AccountNumber, &notification.TransactionID, &notification.CreditDebit, &notification.Amount.Currency, &notification.Amount, &notification.Timestamp); err != nil {
+			return []Notification{}, errors.New("statements.scanNotifications: Could not retrieve notifications. " + err.Error())
+		}
+		notifications = append(notifications, notification)
+	}
+	return
+}
+
+func getNotification(accountNumber string, notificationID string) (notification Notification, err error) {
+	rows, err := Config.Db.Query("SELECT "+notificationColumns+" FROM `account_notifications` WHERE `notificationID` = ? AND `accountNumber` = ?", notificationID, accountNumber)
+	if err != nil {
+		return Notification{}, errors.New("statements.getNotification: " + err.Error())
+	}
+	defer rows.Close()
+
+	notifications, err := scanNotifications(rows)
+	if err != nil {
+		return Notification{}, errors.New("statements.getNotification: " + err.Error())
+	}
+	if len(notifications) == 0 {
+		return Notification{}, errors.New("statements.getNotification: Notification not found")
+	}
+
+	return notifications[0], nil
+}
+
+func getNotificationForTransaction(accountNumber string, transactionID string) (notification Notification, err error) {
+	rows, err := Config.Db.Query("SELECT "+notificationColumns+" FROM `account_notifications` WHERE `transactionID` = ? AND `accountNumber` = ?", transactionID, accountNumber)
+	if err != nil {
+		return Notification{}, errors.New("statements.getNotificationForTransaction: " + err.Error())
+	}
+	defer rows.Close()
+
+	notifications, err := scanNotifications(rows)
+	if err != nil {
+		return Notification{}, errors.New("statements.getNotificationForTransaction: " + err.Error())
+	}
+	if len(notifications) == 0 {
+		return Notification{}, errors.New("statements.getNotificationForTransaction: Notification not found")
+	}
+
+	return notifications[0], nil
+}
+
+func getNotificationList(accountNumber string, timestamp int32) (notifications []Notification, err error) {
+	rows, err := Config.Db.Query("SELECT "+notificationColumns+" FROM `account_notifications` WHERE `accountNumber` = ? AND `timestamp` >= ? ORDER BY `id` DESC", accountNumber, timestamp)
+	if err != nil {
+		return []Notification{}, errors.New("statements.getNotificationList: " + err.Error())
+	}
+	defer rows.Close()
+
+	notifications, err = scanNotifications(rows)
+	if err != nil {
+		return []Notification{}, errors.New("statements.getNotificationList: " + err.Error())
+	}
+
+	return
+}
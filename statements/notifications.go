@@ -0,0 +1,125 @@
+package statements
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/bvnk/bank/accounts"
+	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/iso20022"
+	"github.com/bvnk/bank/money"
+	"github.com/bvnk/bank/push"
+)
+
+// Notification records that a transaction debited or credited an account
+type Notification struct {
+	NotificationID string
+	AccountNumber  string
+	TransactionID  int64
+	CreditDebit    string
+	Amount         money.Money
+	Timestamp      int32
+}
+
+// DebitCreditNotification is a notification with the entries its transaction booked on the account
+type DebitCreditNotification struct {
+	Notification
+	Entries []StatementEntry
+}
+
+// PushNotification tells the account holder's devices about the notification a transaction
+// produced for the account. The notification ID is sent along so the app can fetch the camt.054
+func PushNotification(accountNumber string, transactionID string, message string) (err error) {
+	notification, err := getNotificationForTransaction(accountNumber, transactionID)
+	if err != nil {
+		return errors.New("statements.PushNotification: " + err.Error())
+	}
+
+	data := map[string]interface{}{"notificationID": notification.NotificationID}
+	err = push.SendNotificationWithData(accountNumber, message+" "+notification.Amount.String(), 1, "default", data)
+	if err != nil {
+		return errors.New("statements.PushNotification: " + err.Error())
+	}
+	return
+}
+
+func bankToCustomerDebitCreditNotification(data []string) (result interface{}, err error) {
+	accountNumber := data[3]
+	notificationID := data[4]
+	format, err := parseFormat(data, 5)
+	if err != nil {
+		return "", errors.New("statements.bankToCustomerDebitCreditNotification: " + err.Error())
+	}
+
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("statements.bankToCustomerDebitCreditNotification: " + err.Error())
+	}
+	err = accounts.CheckUserAccountValidFromToken(tokenUser, accountNumber)
+	if err != nil {
+		return "", errors.New("statements.bankToCustomerDebitCreditNotification: Account not valid")
+	}
+
+	notification, err := getNotification(accountNumber, notificationID)
+	if err != nil {
+		return "", errors.New("statements.bankToCustomerDebitCreditNotification: " + err.Error())
+	}
+
+	rows, err := getLedgerRowsForTransaction(accountNumber, notification.TransactionID)
+	if err != nil {
+		return "", errors.New("statements.bankToCustomerDebitCreditNotification: " + err.Error())
+	}
+
+	debitCreditNotification := DebitCreditNotification{Notification: notification, Entries: []StatementEntry{}}
+	for _, row := range rows {
+		debitCreditNotification.Entries = append(debitCreditNotification.Entries, newStatementEntry(accountNumber, row))
+	}
+
+	if format == "xml" {
+		return iso20022.Marshal(notificationToCamt054(debitCreditNotification))
+	}
+	return debitCreditNotification, nil
+}
+
+func listNotifications(data []string) (result interface{}, err error) {
+	accountNumber := data[3]
+	timestamp, err := strconv.ParseInt(data[4], 10, 32)
+	if err != nil {
+		return "", errors.New("statements.listNotifications: Could not parse timestamp. " + err.Error())
+	}
+
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("statements.listNotifications: " + err.Error())
+	}
+	err = accounts.CheckUserAccountValidFromToken(tokenUser, accountNumber)
+	if err != nil {
+		return "", errors.New("statements.listNotifications: Account not valid")
+	}
+
+	notifications, err := getNotificationList(accountNumber, int32(timestamp))
+	if err != nil {
+		return "", errors.New("statements.listNotifications: " + err.Error())
+	}
+
+	return notifications, nil
+}
+
+func notificationToCamt054(notification DebitCreditNotification) (document iso20022.Camt054Document) {
+	created := iso20022.ISODateTime(time.Unix(int64(notification.Timestamp), 0))
+
+	ntfctn := iso20022.AccountNotification{
+		Id:      notification.NotificationID,
+		CreDtTm: created,
+		Acct:    *iso20022.NewAccount(notification.AccountNumber, notification.Amount.Currency),
+	}
+	for _, entry := range notification.Entries {
+		ntfctn.Ntry = append(ntfctn.Ntry, entryToCamt(entry, notification.AccountNumber, iso20022.ENTRY_BOOKED))
+	}
+
+	document.Xmlns = iso20022.CAMT_054_NAMESPACE
+	document.BkToCstmrDbtCdtNtfctn.GrpHdr = iso20022.ReportGroupHeader{MsgId: notification.NotificationID, CreDtTm: created}
+	document.BkToCstmrDbtCdtNtfctn.Ntfctn = []iso20022.AccountNotification{ntfctn}
+	return
+}
@@ -0,0 +1,44 @@
+package statements
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bvnk/bank/iso20022"
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+func TestNotificationToCamt054(t *testing.T) {
+	row := ledgerRow{JournalID: 4, TransactionID: 9, PainType: 1, Net: decimal.RequireFromString("12.50"), Sender: Counterparty{"accountNumSender", ""}, Receiver: Counterparty{"accountNum", ""}, Desc: "Lunch"}
+	notification := DebitCreditNotification{
+		Notification: Notification{
+			NotificationID: "notificationID",
+			AccountNumber:  "accountNum",
+			TransactionID:  9,
+			CreditDebit:    iso20022.CREDIT,
+			Amount:         money.New(decimal.RequireFromString("12.50"), "USD"),
+		},
+		Entries: []StatementEntry{newStatementEntry("accountNum", row)},
+	}
+
+	document, err := iso20022.Marshal(notificationToCamt054(notification))
+	if err != nil {
+		t.Errorf("NotificationToCamt054 does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	expected := []string{
+		iso20022.CAMT_054_NAMESPACE,
+		"<MsgId>notificationID</MsgId>",
+		"<Amt Ccy=\"USD\">12.50</Amt>",
+		"<CdtDbtInd>CRDT</CdtDbtInd>",
+		"<SubFmlyCd>DMCT</SubFmlyCd>",
+		"<AcctSvcrRef>9</AcctSvcrRef>",
+		"<Id>accountNumSender</Id>",
+	}
+	for _, part := range expected {
+		if !strings.Contains(document, part) {
+			t.Errorf("NotificationToCamt054 does not pass. Looking for %v, got %v", part, document)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package statements
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bvnk/bank/accounts"
+	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/iso20022"
+	"github.com/bvnk/bank/money"
+	"github.com/satori/go.uuid"
+)
+
+// Report is an intraday account report: today's entries so far with the balances held on the
+// account. The available balance includes any overdraft
+type Report struct {
+	Statement
+	BookedBalance    money.Money
+	AvailableBalance money.Money
+}
+
+func bankToCustomerAccountReport(data []string) (result interface{}, err error) {
+	accountNumber := data[3]
+	format, err := parseFormat(data, 4)
+	if err != nil {
+		return "", errors.New("statements.bankToCustomerAccountReport: " + err.Error())
+	}
+
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("statements.bankToCustomerAccountReport: " + err.Error())
+	}
+	err = accounts.CheckUserAccountValidFromToken(tokenUser, accountNumber)
+	if err != nil {
+		return "", errors.New("statements.bankToCustomerAccountReport: Account not valid")
+	}
+
+	report, err := getReport(accountNumber, time.Now().In(location()))
+	if err != nil {
+		return "", errors.New("statements.bankToCustomerAccountReport: " + err.Error())
+	}
+
+	if format == "xml" {
+		return iso20022.Marshal(reportToCamt052(report))
+	}
+	return report, nil
+}
+
+// getReport covers the bank day up to now
+func getReport(accountNumber string, now time.Time) (report Report, err error) {
+	from := startOfDay(now)
+	// Entries booked in the current second are included
+	to := now.Truncate(time.Second).Add(time.Second)
+
+	openingBalance, err := getBookedBalanceBefore(accountNumber, int32(from.Unix()))
+	if err != nil {
+		return Report{}, errors.New("statements.getReport: " + err.Error())
+	}
+
+	rows, err := getLedgerRows(accountNumber, int32(from.Unix()), int32(to.Unix()))
+	if err != nil {
+		return Report{}, errors.New("statements.getReport: " + err.Error())
+	}
+
+	bookedBalance, availableBalance, err := getAccountBalances(accountNumber)
+	if err != nil {
+		return Report{}, errors.New("statements.getReport: " + err.Error())
+	}
+	currency, err := getAccountCurrency(accountNumber)
+	if err != nil {
+		return Report{}, errors.New("statements.getReport: " + err.Error())
+	}
+
+	newUuid, err := uuid.NewV4()
+	if err != nil {
+		return Report{}, errors.New("statements.getReport: Could not generate report ID. " + err.Error())
+	}
+
+	report = Report{
+		Statement:        buildStatement(accountNumber, currency, from, to, openingBalance, rows),
+		BookedBalance:    bookedBalance,
+		AvailableBalance: availableBalance,
+	}
+	report.StatementID = iso20022.MessageID(newUuid.String())
+	report.ToDate = now.Format(DATE_FORMAT)
+	return
+}
+
+// startOfDay is midnight of the day t falls on, in t's location
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// reportToCamt052 reports the opening booked balance with the interim booked and available
+// balances held on the account
+func reportToCamt052(report Report) (document iso20022.Camt052Document) {
+	rpt := accountStatement(report.Statement)
+	now := time.Unix(int64(report.Timestamp), 0)
+	rpt.Bal = []iso20022.CashBalance{
+		rpt.Bal[0],
+		newBalance(iso20022.BALANCE_INTERIM_BOOKED, report.BookedBalance, now),
+		newBalance(iso20022.BALANCE_INTERIM_AVAILABLE, report.AvailableBalance, now),
+	}
+
+	document.Xmlns = iso20022.CAMT_052_NAMESPACE
+	document.BkToCstmrAcctRpt.GrpHdr = iso20022.ReportGroupHeader{MsgId: report.StatementID, CreDtTm: rpt.CreDtTm}
+	document.BkToCstmrAcctRpt.Rpt = []iso20022.AccountStatement{rpt}
+	return
+}
@@ -0,0 +1,57 @@
+package statements
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvnk/bank/iso20022"
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+func TestStartOfDay(t *testing.T) {
+	loc := time.FixedZone("EST", -5*60*60)
+	now := time.Date(2017, 3, 4, 1, 30, 0, 0, loc)
+	start := startOfDay(now)
+	if !start.Equal(time.Date(2017, 3, 4, 0, 0, 0, 0, loc)) {
+		t.Errorf("StartOfDay does not pass. Looking for %v, got %v", "2017-03-04 00:00 EST", start)
+	}
+}
+
+func TestReportToCamt052(t *testing.T) {
+	from := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC)
+	rows := []ledgerRow{
+		{JournalID: 3, TransactionID: 7, PainType: 1000, Net: decimal.RequireFromString("50"), Sender: Counterparty{"0", "0"}, Receiver: Counterparty{"accountNum", ""}},
+	}
+	report := Report{
+		Statement:        buildStatement("accountNum", money.DEFAULT_CURRENCY, from, to, decimal.RequireFromString("10"), rows),
+		BookedBalance:    money.New(decimal.RequireFromString("60"), "USD"),
+		AvailableBalance: money.New(decimal.RequireFromString("160"), "USD"),
+	}
+	report.StatementID = "reportID"
+
+	document, err := iso20022.Marshal(reportToCamt052(report))
+	if err != nil {
+		t.Errorf("ReportToCamt052 does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	expected := []string{
+		iso20022.CAMT_052_NAMESPACE,
+		"<BkToCstmrAcctRpt>",
+		"<Cd>OPBD</Cd>",
+		"<Cd>ITBD</Cd>",
+		"<Cd>ITAV</Cd>",
+		"<Amt Ccy=\"USD\">160.00</Amt>",
+		"<SubFmlyCd>CDPT</SubFmlyCd>",
+	}
+	for _, part := range expected {
+		if !strings.Contains(document, part) {
+			t.Errorf("ReportToCamt052 does not pass. Looking for %v, got %v", part, document)
+		}
+	}
+	if strings.Contains(document, "<Cd>CLBD</Cd>") {
+		t.Errorf("ReportToCamt052 does not pass. Looking for %v, got %v", "no closing balance", document)
+	}
+}
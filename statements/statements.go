@@ -0,0 +1,478 @@
+// Package statements builds account statements and reports from the general ledger, as
+// JSON or as ISO 20022 cash management (camt) XML.
+package statements
+
+/*
+CAMT messages are as follows
+52 - BankToCustomerAccountReportV05 (intraday)
+53 - BankToCustomerStatementV05
+54 - BankToCustomerDebitCreditNotificationV05
+1054 - List debit and credit notifications
+
+Booked balances and entries are read from the ledger, so a statement always adds up:
+the opening balance plus the entries is the closing balance.
+*/
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/bvnk/bank/accounts"
+	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/iso20022"
+	"github.com/bvnk/bank/money"
+	"github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+const DATE_FORMAT = "2006-01-02"
+
+// Longest period a single statement can cover, in days
+const MAX_STATEMENT_DAYS = 366
+
+type Counterparty struct {
+	AccountNumber string
+	BankNumber    string
+}
+
+// StatementEntry is one booking on the account. Amount includes any fee charged to the account
+type StatementEntry struct {
+	JournalID     int64
+	TransactionID int64
+	PainType      int64
+	CreditDebit   string
+	Amount        money.Money
+	Fee           money.Money
+	Status        string
+	Counterparty  Counterparty
+	MandateID     string
+	Desc          string
+	Timestamp     int32
+}
+
+type Statement struct {
+	StatementID     string
+	AccountNumber   string
+	Currency        string
+	FromDate        string
+	ToDate          string
+	From            int32
+	To              int32
+	OpeningBalance  money.Money
+	ClosingBalance  money.Money
+	TotalCredits    money.Money
+	TotalDebits     money.Money
+	NumberOfCredits int
+	NumberOfDebits  int
+	Entries         []StatementEntry
+	Timestamp       int32
+}
+
+// ledgerRow is a journal entry that touched the account, with the transaction it posted
+type ledgerRow struct {
+	JournalID         int64
+	TransactionID     int64
+	Desc              string
+	Timestamp         int32
+	Net               decimal.Decimal
+	PainType          int64
+	Sender            Counterparty
+	Receiver          Counterparty
+	Fee               money.Money
+	Currency          string
+	ConvertedCurrency string
+	Status            string
+	MandateID         string
+}
+
+func ProcessCAMT(data []string) (result interface{}, err error) {
+	if len(data) < 3 {
+		return "", errors.New("statements.ProcessCAMT: Not all data is present. Run camt~help to check for needed CAMT data")
+	}
+
+	camtType, err := strconv.ParseInt(data[2], 10, 64)
+	if err != nil {
+		return "", errors.New("statements.ProcessCAMT: Could not get type of CAMT message. " + err.Error())
+	}
+
+	switch camtType {
+	case 52:
+		//token~camt~type~accountNumber~format
+		if len(data) < 4 {
+			return "", errors.New("statements.ProcessCAMT: Not all data is present.")
+		}
+		result, err = bankToCustomerAccountReport(data)
+		if err != nil {
+			return "", errors.New("statements.ProcessCAMT: " + err.Error())
+		}
+		break
+	case 53:
+		//token~camt~type~accountNumber~fromDate~toDate~format
+		if len(data) < 6 {
+			return "", errors.New("statements.ProcessCAMT: Not all data is present.")
+		}
+		result, err = bankToCustomerStatement(data)
+		if err != nil {
+			return "", errors.New("statements.ProcessCAMT: " + err.Error())
+		}
+		break
+	case 54:
+		//token~camt~type~accountNumber~notificationID~format
+		if len(data) < 5 {
+			return "", errors.New("statements.ProcessCAMT: Not all data is present.")
+		}
+		result, err = bankToCustomerDebitCreditNotification(data)
+		if err != nil {
+			return "", errors.New("statements.ProcessCAMT: " + err.Error())
+		}
+		break
+	case 1054:
+		//token~camt~type~accountNumber~timestamp
+		if len(data) < 5 {
+			return "", errors.New("statements.ProcessCAMT: Not all data is present.")
+		}
+		result, err = listNotifications(data)
+		if err != nil {
+			return "", errors.New("statements.ProcessCAMT: " + err.Error())
+		}
+		break
+	default:
+		return "", errors.New("statements.ProcessCAMT: CAMT request type invalid")
+	}
+
+	return
+}
+
+func bankToCustomerStatement(data []string) (result interface{}, err error) {
+	accountNumber := data[3]
+	format, err := parseFormat(data, 6)
+	if err != nil {
+		return "", errors.New("statements.bankToCustomerStatement: " + err.Error())
+	}
+
+	from, to, err := statementPeriod(data[4], data[5], location())
+	if err != nil {
+		return "", errors.New("statements.bankToCustomerStatement: " + err.Error())
+	}
+
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("statements.bankToCustomerStatement: " + err.Error())
+	}
+	err = accounts.CheckUserAccountValidFromToken(tokenUser, accountNumber)
+	if err != nil {
+		return "", errors.New("statements.bankToCustomerStatement: Account not valid")
+	}
+
+	statement, err := getStatement(accountNumber, from, to)
+	if err != nil {
+		return "", errors.New("statements.bankToCustomerStatement: " + err.Error())
+	}
+
+	if format == "xml" {
+		return iso20022.Marshal(statementToCamt053(statement))
+	}
+	return statement, nil
+}
+
+func getStatement(accountNumber string, from time.Time, to time.Time) (statement Statement, err error) {
+	openingBalance, err := getBookedBalanceBefore(accountNumber, int32(from.Unix()))
+	if err != nil {
+		return Statement{}, errors.New("statements.getStatement: " + err.Error())
+	}
+
+	rows, err := getLedgerRows(accountNumber, int32(from.Unix()), int32(to.Unix()))
+	if err != nil {
+		return Statement{}, errors.New("statements.getStatement: " + err.Error())
+	}
+
+	newUuid, err := uuid.NewV4()
+	if err != nil {
+		return Statement{}, errors.New("statements.getStatement: Could not generate statement ID. " + err.Error())
+	}
+
+	currency, err := getAccountCurrency(accountNumber)
+	if err != nil {
+		return Statement{}, errors.New("statements.getStatement: " + err.Error())
+	}
+
+	statement = buildStatement(accountNumber, currency, from, to, openingBalance, rows)
+	statement.StatementID = iso20022.MessageID(newUuid.String())
+	return
+}
+
+// parseFormat reads the optional output format at position i: json (default) or xml
+func parseFormat(data []string, i int) (format string, err error) {
+	format = "json"
+	if len(data) > i && data[i] != "" {
+		format = data[i]
+	}
+	if format != "json" && format != "xml" {
+		return "", errors.New("statements.parseFormat: Format must be json or xml")
+	}
+	return
+}
+
+// location is the bank's time zone, which decides where statement days begin and end
+func location() *time.Location {
+	loc, err := time.LoadLocation(Config.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// statementPeriod turns an inclusive range of dates into [from, to)
+func statementPeriod(fromDate string, toDate string, loc *time.Location) (from time.Time, to time.Time, err error) {
+	from, err = time.ParseInLocation(DATE_FORMAT, fromDate, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("statements.statementPeriod: Could not parse from date, format is YYYY-MM-DD")
+	}
+	lastDay, err := time.ParseInLocation(DATE_FORMAT, toDate, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("statements.statementPeriod: Could not parse to date, format is YYYY-MM-DD")
+	}
+	if lastDay.Before(from) {
+		return time.Time{}, time.Time{}, errors.New("statements.statementPeriod: To date is before from date")
+	}
+	to = lastDay.AddDate(0, 0, 1)
+	if to.After(from.AddDate(0, 0, MAX_STATEMENT_DAYS)) {
+		return time.Time{}, time.Time{}, errors.New("statements.statementPeriod: A statement cannot cover more than " + strconv.Itoa(MAX_STATEMENT_DAYS) + " days")
+	}
+	return
+}
+
+func buildStatement(accountNumber string, currency string, from time.Time, to time.Time, openingBalance decimal.Decimal, rows []ledgerRow) (statement Statement) {
+	statement = Statement{
+		AccountNumber:  accountNumber,
+		Currency:       currency,
+		FromDate:       from.Format(DATE_FORMAT),
+		ToDate:         to.AddDate(0, 0, -1).Format(DATE_FORMAT),
+		From:           int32(from.Unix()),
+		To:             int32(to.Unix()),
+		OpeningBalance: money.New(openingBalance, currency),
+		TotalCredits:   money.Zero(currency),
+		TotalDebits:    money.Zero(currency),
+		Entries:        []StatementEntry{},
+		Timestamp:      int32(time.Now().Unix()),
+	}
+
+	closing := openingBalance
+	for _, row := range rows {
+		// Entries booked without a transaction, such as charges, are in the account's currency
+		if row.Currency == "" {
+			row.Currency = currency
+		}
+		entry := newStatementEntry(accountNumber, row)
+		closing = closing.Add(row.Net)
+		if entry.CreditDebit == iso20022.CREDIT {
+			statement.TotalCredits = statement.TotalCredits.Add(entry.Amount)
+			statement.NumberOfCredits++
+		} else {
+			statement.TotalDebits = statement.TotalDebits.Add(entry.Amount)
+			statement.NumberOfDebits++
+		}
+		statement.Entries = append(statement.Entries, entry)
+	}
+	statement.ClosingBalance = money.New(closing, currency)
+
+	return
+}
+
+// newStatementEntry describes a journal entry from the point of view of the account
+func newStatementEntry(accountNumber string, row ledgerRow) (entry StatementEntry) {
+	// FX conversions pay the receiver in the currency the amount was converted into
+	currency := row.Currency
+	if row.ConvertedCurrency != "" && row.Receiver.AccountNumber == accountNumber {
+		currency = row.ConvertedCurrency
+	}
+	entry = StatementEntry{
+		JournalID:     row.JournalID,
+		TransactionID: row.TransactionID,
+		PainType:      row.PainType,
+		CreditDebit:   iso20022.CREDIT,
+		Amount:        money.New(row.Net.Abs(), currency),
+		Fee:           money.Zero(currency),
+		Status:        row.Status,
+		MandateID:     row.MandateID,
+		Desc:          row.Desc,
+		Timestamp:     row.Timestamp,
+	}
+	if row.Net.Sign() < 0 {
+		entry.CreditDebit = iso20022.DEBIT
+	}
+
+	isSender := row.Sender.AccountNumber == accountNumber
+	if isSender {
+		entry.Counterparty = row.Receiver
+	} else {
+		entry.Counterparty = row.Sender
+	}
+
+	// The sender pays the fee on payments, the receiver on deposits and direct debits.
+	// Fees refunded on reversals are part of the amount
+	switch row.PainType {
+	case 1, 1005, 1010:
+		if isSender {
+			entry.Fee = row.Fee.In(currency)
+		}
+	case 8, 1000:
+		if row.Receiver.AccountNumber == accountNumber {
+			entry.Fee = row.Fee.In(currency)
+		}
+	}
+
+	return
+}
+
+// bankTransactionCode classifies an entry (ISO 20022 bank transaction codes)
+func bankTransactionCode(entry StatementEntry) iso20022.BankTransactionCode {
+	credit := entry.CreditDebit == iso20022.CREDIT
+	switch entry.PainType {
+	// Payment and captured hold
+	case 1, 1005:
+		if credit {
+			return iso20022.NewBankTransactionCode("PMNT", "RCDT", "DMCT")
+		}
+		return iso20022.NewBankTransactionCode("PMNT", "ICDT", "DMCT")
+	// Card payment
+	case 1010:
+		if credit {
+			return iso20022.NewBankTransactionCode("PMNT", "MCRD", "POSP")
+		}
+		return iso20022.NewBankTransactionCode("PMNT", "CCRD", "POSD")
+	case 7:
+		if credit {
+			return iso20022.NewBankTransactionCode("PMNT", "ICDT", "RRTN")
+		}
+		return iso20022.NewBankTransactionCode("PMNT", "RCDT", "RRTN")
+	case 8:
+		if credit {
+			return iso20022.NewBankTransactionCode("PMNT", "IDDT", "PMDD")
+		}
+		return iso20022.NewBankTransactionCode("PMNT", "RDDT", "PMDD")
+	case 1000:
+		return iso20022.NewBankTransactionCode("PMNT", "CNTR", "CDPT")
+	}
+	if credit {
+		return iso20022.NewBankTransactionCode("ACMT", "MCOP", "OTHR")
+	}
+	return iso20022.NewBankTransactionCode("ACMT", "MDOP", "OTHR")
+}
+
+// entryToCamt maps an entry onto a camt report entry
+func entryToCamt(entry StatementEntry, accountNumber string, status string) (ntry iso20022.ReportEntry) {
+	bookingTime := iso20022.ISODateTime(time.Unix(int64(entry.Timestamp), 0))
+	ntry = iso20022.ReportEntry{
+		NtryRef:      strconv.FormatInt(entry.JournalID, 10),
+		Amt:          iso20022.ActiveOrHistoricCurrencyAndAmount{Value: entry.Amount.StringFixed(), Ccy: entry.Amount.Currency},
+		CdtDbtInd:    entry.CreditDebit,
+		RvslInd:      entry.PainType == 7,
+		Sts:          status,
+		BookgDt:      &iso20022.DateAndDateTime{DtTm: bookingTime},
+		ValDt:        &iso20022.DateAndDateTime{DtTm: bookingTime},
+		BkTxCd:       bankTransactionCode(entry),
+		AddtlNtryInf: entry.Desc,
+	}
+	if entry.TransactionID != 0 {
+		ntry.AcctSvcrRef = strconv.FormatInt(entry.TransactionID, 10)
+	}
+
+	if !entry.Fee.IsZero() {
+		ntry.Chrgs = &iso20022.Charges{Rcrd: []iso20022.ChargesRecord{{
+			Amt:         iso20022.ActiveOrHistoricCurrencyAndAmount{Value: entry.Fee.StringFixed(), Ccy: entry.Fee.Currency},
+			CdtDbtInd:   iso20022.DEBIT,
+			ChrgInclInd: true,
+		}}}
+	}
+
+	if entry.TransactionID != 0 {
+		details := iso20022.EntryTransaction{
+			Refs: &iso20022.TransactionReferences{AcctSvcrRef: ntry.AcctSvcrRef, MndtId: entry.MandateID},
+		}
+		// The counterparty is the creditor of a debit and the debtor of a credit
+		counterparty := iso20022.NewAccount(entry.Counterparty.AccountNumber, "")
+		own := iso20022.NewAccount(accountNumber, "")
+		if entry.CreditDebit == iso20022.DEBIT {
+			details.RltdPties = &iso20022.TransactionParties{DbtrAcct: own, CdtrAcct: counterparty}
+			if agent := iso20022.NewAgent(entry.Counterparty.BankNumber); agent != nil {
+				details.RltdAgts = &iso20022.TransactionAgents{CdtrAgt: agent}
+			}
+		} else {
+			details.RltdPties = &iso20022.TransactionParties{DbtrAcct: counterparty, CdtrAcct: own}
+			if agent := iso20022.NewAgent(entry.Counterparty.BankNumber); agent != nil {
+				details.RltdAgts = &iso20022.TransactionAgents{DbtrAgt: agent}
+			}
+		}
+		if entry.Desc != "" {
+			details.RmtInf = &iso20022.RemittanceInformation{Ustrd: []string{entry.Desc}}
+		}
+		ntry.NtryDtls = &iso20022.EntryDetails{TxDtls: []iso20022.EntryTransaction{details}}
+	}
+
+	return
+}
+
+// newBalance builds a camt balance, negative balances are debits
+func newBalance(balanceType string, balance money.Money, date time.Time) iso20022.CashBalance {
+	indicator := iso20022.CREDIT
+	if balance.Sign() < 0 {
+		indicator = iso20022.DEBIT
+	}
+	return iso20022.CashBalance{
+		Tp:        iso20022.BalanceType{CdOrPrtry: iso20022.BalanceTypeCode{Cd: balanceType}},
+		Amt:       iso20022.ActiveOrHistoricCurrencyAndAmount{Value: money.New(balance.Amount.Abs(), balance.Currency).StringFixed(), Ccy: balance.Currency},
+		CdtDbtInd: indicator,
+		Dt:        iso20022.DateAndDateTime{Dt: iso20022.ISODate(date)},
+	}
+}
+
+// newTransactionsSummary totals the credits and debits of a report
+func newTransactionsSummary(totalCredits money.Money, numberOfCredits int, totalDebits money.Money, numberOfDebits int) *iso20022.TotalTransactions {
+	net := totalCredits.Sub(totalDebits)
+	netIndicator := iso20022.CREDIT
+	if net.Sign() < 0 {
+		netIndicator = iso20022.DEBIT
+	}
+	return &iso20022.TotalTransactions{
+		TtlNtries: iso20022.NumberAndSumOfTransactions{
+			NbOfNtries: strconv.Itoa(numberOfCredits + numberOfDebits),
+			Sum:        totalCredits.Add(totalDebits).StringFixed(),
+			TtlNetNtry: &iso20022.AmountAndDirection{Amt: money.New(net.Amount.Abs(), net.Currency).StringFixed(), CdtDbtInd: netIndicator},
+		},
+		TtlCdtNtries: iso20022.NumberAndSumOfTransactions{NbOfNtries: strconv.Itoa(numberOfCredits), Sum: totalCredits.StringFixed()},
+		TtlDbtNtries: iso20022.NumberAndSumOfTransactions{NbOfNtries: strconv.Itoa(numberOfDebits), Sum: totalDebits.StringFixed()},
+	}
+}
+
+// accountStatement holds the entries, booked balances and totals shared by statements and reports
+func accountStatement(statement Statement) iso20022.AccountStatement {
+	from := time.Unix(int64(statement.From), 0)
+	to := time.Unix(int64(statement.To), 0)
+
+	stmt := iso20022.AccountStatement{
+		Id:      statement.StatementID,
+		CreDtTm: iso20022.ISODateTime(time.Unix(int64(statement.Timestamp), 0)),
+		FrToDt:  &iso20022.DateTimePeriod{FrDtTm: iso20022.ISODateTime(from), ToDtTm: iso20022.ISODateTime(to.Add(-time.Second))},
+		Acct:    *iso20022.NewAccount(statement.AccountNumber, statement.Currency),
+		Bal: []iso20022.CashBalance{
+			newBalance(iso20022.BALANCE_OPENING_BOOKED, statement.OpeningBalance, from),
+			newBalance(iso20022.BALANCE_CLOSING_BOOKED, statement.ClosingBalance, to.Add(-time.Second)),
+		},
+		TxsSummry: newTransactionsSummary(statement.TotalCredits, statement.NumberOfCredits, statement.TotalDebits, statement.NumberOfDebits),
+	}
+	for _, entry := range statement.Entries {
+		stmt.Ntry = append(stmt.Ntry, entryToCamt(entry, statement.AccountNumber, iso20022.ENTRY_BOOKED))
+	}
+	return stmt
+}
+
+func statementToCamt053(statement Statement) (document iso20022.Camt053Document) {
+	stmt := accountStatement(statement)
+
+	document.Xmlns = iso20022.CAMT_053_NAMESPACE
+	document.BkToCstmrStmt.GrpHdr = iso20022.ReportGroupHeader{MsgId: statement.StatementID, CreDtTm: stmt.CreDtTm}
+	document.BkToCstmrStmt.Stmt = []iso20022.AccountStatement{stmt}
+	return
+}
@@ -0,0 +1,216 @@
+package statements
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvnk/bank/iso20022"
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+func TestStatementPeriod(t *testing.T) {
+	from, to, err := statementPeriod("2017-01-01", "2017-01-31", time.UTC)
+	if err != nil {
+		t.Errorf("StatementPeriod does not pass. Looking for %v, got %v", nil, err)
+	}
+	if from.Unix() != time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC).Unix() {
+		t.Errorf("StatementPeriod from does not pass. Looking for %v, got %v", "2017-01-01", from)
+	}
+	// The to date is inclusive, so the period ends at the start of the next day
+	if to.Unix() != time.Date(2017, 2, 1, 0, 0, 0, 0, time.UTC).Unix() {
+		t.Errorf("StatementPeriod to does not pass. Looking for %v, got %v", "2017-02-01", to)
+	}
+
+	_, _, err = statementPeriod("2017-02-01", "2017-01-01", time.UTC)
+	if err == nil {
+		t.Errorf("StatementPeriod reversed does not pass. Looking for %v, got %v", "To date is before from date", nil)
+	}
+
+	_, _, err = statementPeriod("2017-01-01", "2018-06-01", time.UTC)
+	if err == nil {
+		t.Errorf("StatementPeriod too long does not pass. Looking for %v, got %v", "A statement cannot cover more than", nil)
+	}
+
+	_, _, err = statementPeriod("01/01/2017", "2017-01-31", time.UTC)
+	if err == nil {
+		t.Errorf("StatementPeriod format does not pass. Looking for %v, got %v", "Could not parse from date", nil)
+	}
+}
+
+func TestNewStatementEntry(t *testing.T) {
+	// Payment sent: the sender pays the fee
+	sent := ledgerRow{
+		JournalID:     1,
+		TransactionID: 10,
+		Net:           decimal.RequireFromString("-10.01"),
+		PainType:      1,
+		Sender:        Counterparty{"accountNumSender", ""},
+		Receiver:      Counterparty{"accountNumReceiver", "bankNumReceiver"},
+		Fee:           money.New(decimal.RequireFromString("0.01"), "USD"),
+	}
+	entry := newStatementEntry("accountNumSender", sent)
+	if entry.CreditDebit != iso20022.DEBIT {
+		t.Errorf("NewStatementEntry sent does not pass. Looking for %v, got %v", iso20022.DEBIT, entry.CreditDebit)
+	}
+	if entry.Amount.StringFixed() != "10.01" || entry.Fee.StringFixed() != "0.01" {
+		t.Errorf("NewStatementEntry sent amounts do not pass. Looking for %v, got %v", "10.01 0.01", entry.Amount.StringFixed()+" "+entry.Fee.StringFixed())
+	}
+	if entry.Counterparty.AccountNumber != "accountNumReceiver" || entry.Counterparty.BankNumber != "bankNumReceiver" {
+		t.Errorf("NewStatementEntry sent counterparty does not pass. Looking for %v, got %v", "accountNumReceiver", entry.Counterparty)
+	}
+
+	// The receiver of the same payment does not pay the fee
+	sent.Net = decimal.RequireFromString("10")
+	entry = newStatementEntry("accountNumReceiver", sent)
+	if entry.CreditDebit != iso20022.CREDIT || !entry.Fee.IsZero() {
+		t.Errorf("NewStatementEntry received does not pass. Looking for %v, got %v", iso20022.CREDIT+" 0.00", entry.CreditDebit+" "+entry.Fee.StringFixed())
+	}
+
+	// Deposits and direct debits are charged to the receiver
+	deposit := ledgerRow{
+		Net:      decimal.RequireFromString("9.99"),
+		PainType: 1000,
+		Sender:   Counterparty{"0", "0"},
+		Receiver: Counterparty{"accountNumReceiver", ""},
+		Fee:      money.New(decimal.RequireFromString("0.01"), "USD"),
+	}
+	entry = newStatementEntry("accountNumReceiver", deposit)
+	if entry.Fee.StringFixed() != "0.01" {
+		t.Errorf("NewStatementEntry deposit does not pass. Looking for %v, got %v", "0.01", entry.Fee.StringFixed())
+	}
+
+	// FX conversions are paid to the receiver in the converted currency
+	conversion := ledgerRow{
+		Net:               decimal.RequireFromString("1450"),
+		PainType:          4,
+		Sender:            Counterparty{"accountNumSender", ""},
+		Receiver:          Counterparty{"accountNumReceiver", ""},
+		Currency:          "USD",
+		ConvertedCurrency: "JPY",
+	}
+	entry = newStatementEntry("accountNumReceiver", conversion)
+	if entry.Amount.Currency != "JPY" || entry.Amount.StringFixed() != "1450" {
+		t.Errorf("NewStatementEntry conversion does not pass. Looking for %v, got %v", "1450 JPY", entry.Amount.StringFixed()+" "+entry.Amount.Currency)
+	}
+	conversion.Net = decimal.RequireFromString("-10")
+	entry = newStatementEntry("accountNumSender", conversion)
+	if entry.Amount.Currency != "USD" {
+		t.Errorf("NewStatementEntry conversion sender does not pass. Looking for %v, got %v", "USD", entry.Amount.Currency)
+	}
+}
+
+func TestBuildStatement(t *testing.T) {
+	from := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2017, 1, 2, 0, 0, 0, 0, time.UTC)
+	rows := []ledgerRow{
+		{JournalID: 1, Net: decimal.RequireFromString("100")},
+		{JournalID: 2, TransactionID: 5, PainType: 1, Net: decimal.RequireFromString("-25.50"), Sender: Counterparty{"accountNum", ""}, Receiver: Counterparty{"accountNumReceiver", ""}},
+	}
+
+	statement := buildStatement("accountNum", money.DEFAULT_CURRENCY, from, to, decimal.RequireFromString("10"), rows)
+	if statement.ClosingBalance.StringFixed() != "84.50" {
+		t.Errorf("BuildStatement closing balance does not pass. Looking for %v, got %v", "84.50", statement.ClosingBalance.StringFixed())
+	}
+	if statement.TotalCredits.StringFixed() != "100.00" || statement.NumberOfCredits != 1 {
+		t.Errorf("BuildStatement credits do not pass. Looking for %v, got %v", "100.00 1", statement.TotalCredits.StringFixed())
+	}
+	if statement.TotalDebits.StringFixed() != "25.50" || statement.NumberOfDebits != 1 {
+		t.Errorf("BuildStatement debits do not pass. Looking for %v, got %v", "25.50 1", statement.TotalDebits.StringFixed())
+	}
+	if statement.FromDate != "2017-01-01" || statement.ToDate != "2017-01-01" {
+		t.Errorf("BuildStatement dates do not pass. Looking for %v, got %v", "2017-01-01 2017-01-01", statement.FromDate+" "+statement.ToDate)
+	}
+}
+
+func TestStatementToCamt053(t *testing.T) {
+	from := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2017, 1, 2, 0, 0, 0, 0, time.UTC)
+	rows := []ledgerRow{
+		{JournalID: 2, TransactionID: 5, PainType: 1, Net: decimal.RequireFromString("-25.51"), Sender: Counterparty{"accountNum", ""}, Receiver: Counterparty{"accountNumReceiver", "bankNumReceiver"}, Fee: money.New(decimal.RequireFromString("0.01"), "USD"), Desc: "Rent"},
+	}
+	statement := buildStatement("accountNum", money.DEFAULT_CURRENCY, from, to, decimal.RequireFromString("10"), rows)
+	statement.StatementID = "statementID"
+
+	document, err := iso20022.Marshal(statementToCamt053(statement))
+	if err != nil {
+		t.Errorf("StatementToCamt053 does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	expected := []string{
+		iso20022.CAMT_053_NAMESPACE,
+		"<Cd>OPBD</Cd>",
+		"<Cd>CLBD</Cd>",
+		"<Amt Ccy=\"USD\">15.51</Amt>",
+		"<CdtDbtInd>DBIT</CdtDbtInd>",
+		"<AcctSvcrRef>5</AcctSvcrRef>",
+		"<SubFmlyCd>DMCT</SubFmlyCd>",
+		"<Amt Ccy=\"USD\">0.01</Amt>",
+		"<Id>bankNumReceiver</Id>",
+		"<Ustrd>Rent</Ustrd>",
+	}
+	for _, part := range expected {
+		if !strings.Contains(document, part) {
+			t.Errorf("StatementToCamt053 does not pass. Looking for %v, got %v", part, document)
+		}
+	}
+}
+
+func TestProcessCAMT(t *testing.T) {
+	data := []string{"", ""}
+	_, err := ProcessCAMT(data)
+	if err == nil {
+		t.Errorf("ProcessCAMT does not pass. Looking for %v, got %v", "Not all data is present", nil)
+	}
+
+	data = []string{"", "", "99", "accountNum"}
+	_, err = ProcessCAMT(data)
+	if err == nil || !strings.Contains(err.Error(), "CAMT request type invalid") {
+		t.Errorf("ProcessCAMT type does not pass. Looking for %v, got %v", "CAMT request type invalid", err)
+	}
+
+	data = []string{"", "", "53", "accountNum", "2017-01-01"}
+	_, err = ProcessCAMT(data)
+	if err == nil {
+		t.Errorf("ProcessCAMT CamtType53 does not pass. Looking for %v, got %v", "Not all data is present", nil)
+	}
+
+	data = []string{"", "", "53", "accountNum", "2017-01-01", "2017-01-31", "pdf"}
+	_, err = ProcessCAMT(data)
+	if err == nil {
+		t.Errorf("ProcessCAMT CamtType53 format does not pass. Looking for %v, got %v", "Format must be json or xml", nil)
+	}
+
+	data = []string{"", "", "53", "accountNum", "2017-01-31", "2017-01-01"}
+	_, err = ProcessCAMT(data)
+	if err == nil {
+		t.Errorf("ProcessCAMT CamtType53 period does not pass. Looking for %v, got %v", "To date is before from date", nil)
+	}
+}
+
+func TestProcessCAMTReports(t *testing.T) {
+	data := []string{"", "", "52"}
+	_, err := ProcessCAMT(data)
+	if err == nil {
+		t.Errorf("ProcessCAMT CamtType52 does not pass. Looking for %v, got %v", "Not all data is present", nil)
+	}
+
+	data = []string{"", "", "52", "accountNum", "pdf"}
+	_, err = ProcessCAMT(data)
+	if err == nil {
+		t.Errorf("ProcessCAMT CamtType52 format does not pass. Looking for %v, got %v", "Format must be json or xml", nil)
+	}
+
+	data = []string{"", "", "54", "accountNum"}
+	_, err = ProcessCAMT(data)
+	if err == nil {
+		t.Errorf("ProcessCAMT CamtType54 does not pass. Looking for %v, got %v", "Not all data is present", nil)
+	}
+
+	data = []string{"", "", "1054", "accountNum", "not integer"}
+	_, err = ProcessCAMT(data)
+	if err == nil {
+		t.Errorf("ProcessCAMT CamtType1054 timestamp does not pass. Looking for %v, got %v", "Could not parse timestamp", nil)
+	}
+}
@@ -0,0 +1,181 @@
+package transactions
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/bvnk/bank/interest"
+	"github.com/bvnk/bank/iso20022"
+	"github.com/bvnk/bank/ledger"
+	"github.com/bvnk/bank/money"
+	"github.com/bvnk/bank/statements"
+	"github.com/paulmach/go.geo"
+	"github.com/shopspring/decimal"
+)
+
+/*
+Currency conversions pay an amount from an account in one currency into an account at this
+bank held in another, at a rate the fx package quoted. They are executed with fx~4 rather than
+requested with pain~, and saved as PAIN type PAIN_FX_CONVERSION with the rate and the
+converted amount. The bank's FX position in each currency buys what the sender pays and sells
+what the receiver is paid
+*/
+
+// PAIN type conversions are saved as
+const PAIN_FX_CONVERSION = 1009
+
+// Conversion is a conversion of Amount, paid by Sender, into ConvertedAmount paid to Receiver
+type Conversion struct {
+	Sender          string
+	Receiver        string
+	Amount          money.Money
+	ConvertedAmount money.Money
+	Rate            decimal.Decimal
+	Desc            string
+}
+
+// PostConversion checks and posts a conversion inside tx, which the caller has begun and commits
+// or rolls back. It is checked as a credit transfer is: both accounts must be open and hold the
+// currencies converted between, and the sender verified and able to pay the amount, its fees and
+// any early withdrawal penalty. When it is refused the reason code says why
+func PostConversion(tx *sql.Tx, conversion Conversion) (transaction PAINTrans, reasonCode string, err error) {
+	transaction = PAINTrans{0, PAIN_FX_CONVERSION, AccountHolder{conversion.Sender, ""}, AccountHolder{conversion.Receiver, ""}, conversion.Amount, money.Zero(conversion.Amount.Currency), *geo.NewPoint(0, 0), conversion.Desc, STATUS_RECEIVED, 0, 0, "", nil, 0, conversion.Rate, conversion.ConvertedAmount}
+
+	closed, err := checkAccountsClosed(tx, transaction)
+	if err != nil {
+		return PAINTrans{}, "", errors.New("payments.PostConversion: " + err.Error())
+	}
+	if closed {
+		return PAINTrans{}, REASON_CLOSED_ACCOUNT, errors.New("payments.PostConversion: Account closed")
+	}
+
+	// The sender pays in the currency converted from, the receiver is paid in the one converted to
+	for _, leg := range []struct {
+		accountHolder AccountHolder
+		amount        money.Money
+	}{{transaction.Sender, transaction.Amount}, {transaction.Receiver, transaction.ConvertedAmount}} {
+		currency, err := getAccountCurrency(tx, leg.accountHolder.AccountNumber)
+		if err != nil {
+			return PAINTrans{}, "", errors.New("payments.PostConversion: " + err.Error())
+		}
+		if currency != leg.amount.Currency {
+			return PAINTrans{}, REASON_INVALID_CURRENCY, errors.New("payments.PostConversion: Account " + leg.accountHolder.AccountNumber + " does not hold " + leg.amount.Currency)
+		}
+	}
+
+	restricted, err := checkSenderRestricted(tx, transaction.Sender)
+	if err != nil {
+		return PAINTrans{}, "", errors.New("payments.PostConversion: " + err.Error())
+	}
+	if restricted {
+		return PAINTrans{}, REASON_TRANSACTION_FORBIDDEN, errors.New("payments.PostConversion: Account holder not verified")
+	}
+
+	err = assessFees(tx, &transaction)
+	if err != nil {
+		return PAINTrans{}, "", errors.New("payments.PostConversion: " + err.Error())
+	}
+
+	balanceAvailable, err := checkBalance(tx, transaction.Sender)
+	if err != nil {
+		return PAINTrans{}, "", errors.New("payments.PostConversion: " + err.Error())
+	}
+	// Withdrawing from a term account before it matures costs a penalty
+	penalty, err := interest.EarlyWithdrawalPenalty(tx, transaction.Sender.AccountNumber, transaction.Amount, time.Now())
+	if err != nil {
+		return PAINTrans{}, "", errors.New("payments.PostConversion: " + err.Error())
+	}
+	// The sender pays the fee and any penalty on top of the amount
+	if balanceAvailable.Cmp(transaction.Amount.Add(transaction.Fee).Add(penalty)) == -1 {
+		return PAINTrans{}, REASON_INSUFFICIENT_FUNDS, errors.New("payments.PostConversion: Insufficient funds available")
+	}
+
+	sqlTime := int32(time.Now().Unix())
+	err = interest.ChargeEarlyWithdrawalPenalty(tx, transaction.Sender.AccountNumber, penalty, sqlTime)
+	if err != nil {
+		return PAINTrans{}, "", errors.New("payments.PostConversion: " + err.Error())
+	}
+
+	transactionID, err := savePainTransaction(tx, transaction)
+	if err != nil {
+		return PAINTrans{}, "", errors.New("payments.PostConversion: " + err.Error())
+	}
+	transaction.ID = int32(transactionID)
+	transaction.Timestamp = sqlTime
+
+	err = processConversion(tx, transaction, sqlTime)
+	if err != nil {
+		return PAINTrans{}, "", errors.New("payments.PostConversion: " + err.Error())
+	}
+
+	for _, entry := range conversionJournalEntries(transaction) {
+		_, err = ledger.PostJournal(tx, entry)
+		if err != nil {
+			return PAINTrans{}, "", errors.New("payments.PostConversion: " + err.Error())
+		}
+	}
+
+	// Both accounts are at this bank, so the conversion settles straight away
+	err = setTransactionStatus(tx, transaction.ID, STATUS_RECEIVED, STATUS_ACCEPTED, "")
+	if err != nil {
+		return PAINTrans{}, "", errors.New("payments.PostConversion: " + err.Error())
+	}
+	err = setTransactionStatus(tx, transaction.ID, STATUS_ACCEPTED, STATUS_SETTLED, "")
+	if err != nil {
+		return PAINTrans{}, "", errors.New("payments.PostConversion: " + err.Error())
+	}
+	transaction.Status = STATUS_SETTLED
+
+	return
+}
+
+// processConversion moves the balances of a conversion: the sender pays the amount and the fee,
+// the receiver is paid the converted amount
+func processConversion(tx *sql.Tx, transaction PAINTrans, sqlTime int32) (err error) {
+	updateStatement := "UPDATE accounts SET `accountBalance` = (`accountBalance` + ?), `availableBalance` = (`availableBalance` + ?), `timestamp` = ? WHERE `accountNumber` = ? "
+	stmtUpd, err := tx.Prepare(updateStatement)
+	if err != nil {
+		return errors.New("payments.processConversion: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	paid := transaction.Amount.Add(transaction.Fee)
+	_, err = stmtUpd.Exec(paid.Neg(), paid.Neg(), sqlTime, transaction.Sender.AccountNumber)
+	if err != nil {
+		return errors.New("payments.processConversion: " + err.Error())
+	}
+	_, err = statements.SaveNotification(tx, transaction.Sender.AccountNumber, int64(transaction.ID), iso20022.DEBIT, paid, sqlTime)
+	if err != nil {
+		return errors.New("payments.processConversion: " + err.Error())
+	}
+
+	_, err = stmtUpd.Exec(transaction.ConvertedAmount, transaction.ConvertedAmount, sqlTime, transaction.Receiver.AccountNumber)
+	if err != nil {
+		return errors.New("payments.processConversion: " + err.Error())
+	}
+	_, err = statements.SaveNotification(tx, transaction.Receiver.AccountNumber, int64(transaction.ID), iso20022.CREDIT, transaction.ConvertedAmount, sqlTime)
+	if err != nil {
+		return errors.New("payments.processConversion: " + err.Error())
+	}
+
+	return
+}
+
+// conversionJournalEntries books a conversion as one balanced entry per currency: the bank's FX
+// position buys the sender's amount, and sells the converted amount to the receiver. The fees
+// are booked with the sender's amount, in its currency
+func conversionJournalEntries(transaction PAINTrans) (entries []ledger.JournalEntry) {
+	desc := "fx~4 " + transaction.Desc
+
+	bought := ledger.JournalEntry{TransactionID: int64(transaction.ID), Desc: desc, Timestamp: transaction.Timestamp}
+	bought.Lines = append(bought.Lines, ledger.Debit(ledgerAccountFor(transaction.Sender), transaction.Amount.Add(transaction.Fee).Amount)...)
+	bought.Lines = append(bought.Lines, ledger.Credit(ledger.InCurrency(ledger.FX_POSITION, transaction.Amount.Currency), transaction.Amount.Amount)...)
+	bought.Lines = append(bought.Lines, feeIncomeLines(transaction.Fees, false)...)
+
+	sold := ledger.JournalEntry{TransactionID: int64(transaction.ID), Desc: desc, Timestamp: transaction.Timestamp}
+	sold.Lines = append(sold.Lines, ledger.Debit(ledger.InCurrency(ledger.FX_POSITION, transaction.ConvertedAmount.Currency), transaction.ConvertedAmount.Amount)...)
+	sold.Lines = append(sold.Lines, ledger.Credit(ledgerAccountFor(transaction.Receiver), transaction.ConvertedAmount.Amount)...)
+
+	return []ledger.JournalEntry{bought, sold}
+}
@@ -0,0 +1,54 @@
+package transactions
+
+import (
+	"testing"
+
+	"github.com/bvnk/bank/ledger"
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+func TestConversionJournalEntries(t *testing.T) {
+	amount := money.New(decimal.New(100, 0), money.DEFAULT_CURRENCY)
+	converted := money.New(decimal.RequireFromString("79.60"), "EUR")
+	trans := withFee(PAINTrans{ID: 7, PainType: PAIN_FX_CONVERSION, Sender: AccountHolder{"sender", ""}, Receiver: AccountHolder{"receiver", ""}, Amount: amount, FXRate: decimal.RequireFromString("0.796"), ConvertedAmount: converted})
+
+	entries := conversionJournalEntries(trans)
+	if len(entries) != 2 {
+		t.Fatalf("ConversionJournalEntries does not pass. Looking for %v entries, got %v", 2, len(entries))
+	}
+	for _, entry := range entries {
+		if err := entry.Validate(); err != nil {
+			t.Errorf("ConversionJournalEntries does not pass. Looking for %v, got %v", nil, err)
+		}
+		if entry.TransactionID != 7 {
+			t.Errorf("ConversionJournalEntries transaction does not pass. Looking for %v, got %v", 7, entry.TransactionID)
+		}
+	}
+
+	// The sender pays the fee on top, booked to fee income. The default currency position keeps
+	// the plain code, others are kept per currency
+	bought := entries[0].Lines
+	if len(bought) != 3 || bought[0].LedgerAccount != "sender" || bought[0].Amount.String() != "100.01" || bought[1].LedgerAccount != ledger.FX_POSITION || bought[2].LedgerAccount != ledger.FeeIncomeAccount("transaction") {
+		t.Errorf("ConversionJournalEntries bought does not pass. Looking for %v, got %v", "sender 100.01 "+ledger.FX_POSITION+" fee income", bought)
+	}
+	sold := entries[1].Lines
+	if sold[0].LedgerAccount != ledger.FX_POSITION+":EUR" || sold[1].LedgerAccount != "receiver" || sold[1].Amount.String() != "79.6" {
+		t.Errorf("ConversionJournalEntries sold does not pass. Looking for %v, got %v", ledger.FX_POSITION+":EUR receiver 79.6", sold)
+	}
+}
+
+func TestInCurrencyConversion(t *testing.T) {
+	// Amounts are read from the database without their currency
+	trans := PAINTrans{Amount: money.New(decimal.RequireFromString("100"), money.DEFAULT_CURRENCY), Fee: money.Zero(money.DEFAULT_CURRENCY), ConvertedAmount: money.New(decimal.RequireFromString("79.6"), money.DEFAULT_CURRENCY)}
+	trans.inCurrency("GBP", "JPY")
+	if trans.Amount.Currency != "GBP" || trans.ConvertedAmount.Currency != "JPY" || trans.ConvertedAmount.Amount.String() != "80" {
+		t.Errorf("InCurrencyConversion does not pass. Looking for %v, got %v %v", "GBP JPY 80", trans.Amount.Currency, trans.ConvertedAmount)
+	}
+
+	// Other transactions have no converted amount
+	trans.inCurrency("GBP", "")
+	if trans.ConvertedAmount.Currency != "" {
+		t.Errorf("InCurrencyConversion other does not pass. Looking for %v, got %v", "no currency", trans.ConvertedAmount.Currency)
+	}
+}
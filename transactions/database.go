@@ -1,11 +1,20 @@
 package transactions
 
 import (
+	"database/sql"
 	"errors"
+	"strconv"
 	"time"
 
+	"github.com/bvnk/bank/accounts"
 	"github.com/bvnk/bank/configuration"
-	"github.com/shopspring/decimal"
+	"github.com/bvnk/bank/fees"
+	"github.com/bvnk/bank/iso20022"
+	"github.com/bvnk/bank/iso8583"
+	"github.com/bvnk/bank/ledger"
+	"github.com/bvnk/bank/money"
+	"github.com/bvnk/bank/statements"
+	"github.com/satori/go.uuid"
 )
 
 var Config configuration.Configuration
@@ -14,16 +23,16 @@ func SetConfig(config *configuration.Configuration) {
 	Config = *config
 }
 
-func savePainTransaction(transaction PAINTrans) (err error) {
+func savePainTransaction(tx *sql.Tx, transaction PAINTrans) (transactionID int64, err error) {
 	// Prepare statement for inserting data
 	// Construct geoText. These values are already cleared
 	geoText := transaction.Geo.ToWKT()
-	insertStatement := "INSERT INTO transactions (`transaction`, `type`, `senderAccountNumber`, `senderBankNumber`, `receiverAccountNumber`, `receiverBankNumber`, `transactionAmount`, `feeAmount`, `desc`, `timestamp`, `status`, `geo`) "
-	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, GeomFromText(?))"
+	insertStatement := "INSERT INTO transactions (`transaction`, `type`, `senderAccountNumber`, `senderBankNumber`, `receiverAccountNumber`, `receiverBankNumber`, `transactionAmount`, `currency`, `fxRate`, `convertedAmount`, `convertedCurrency`, `feeAmount`, `desc`, `timestamp`, `status`, `reversalOf`, `mandateID`, `holdID`, `geo`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, GeomFromText(?))"
 
-	stmtIns, err := Config.Db.Prepare(insertStatement)
+	stmtIns, err := tx.Prepare(insertStatement)
 	if err != nil {
-		return errors.New("payments.savePainTransaction: " + err.Error())
+		return 0, errors.New("payments.savePainTransaction: " + err.Error())
 	}
 	defer stmtIns.Close() // Close the statement when we leave main() / the program terminates
 
@@ -31,14 +40,50 @@ func savePainTransaction(transaction PAINTrans) (err error) {
 	sqlTime := int32(t.Unix())
 	transaction.Timestamp = sqlTime
 
-	// The feePerc is a percentage, convert to amount
-	feeAmount := transaction.Amount.Mul(transaction.Fee)
+	// Only reversals link to another transaction
+	var reversalOf sql.NullInt64
+	if transaction.ReversalOf != 0 {
+		reversalOf = sql.NullInt64{Int64: int64(transaction.ReversalOf), Valid: true}
+	}
+	// Only direct debits link to a mandate
+	var mandateID sql.NullString
+	if transaction.MandateID != "" {
+		mandateID = sql.NullString{String: transaction.MandateID, Valid: true}
+	}
+	// Only captured holds link to a hold
+	var holdID sql.NullInt64
+	if transaction.HoldID != 0 {
+		holdID = sql.NullInt64{Int64: transaction.HoldID, Valid: true}
+	}
+	// Only conversions have a rate and a converted amount
+	var fxRate, convertedAmount, convertedCurrency sql.NullString
+	if transaction.ConvertedAmount.Currency != "" {
+		fxRate = sql.NullString{String: transaction.FXRate.String(), Valid: true}
+		convertedAmount = sql.NullString{String: transaction.ConvertedAmount.Amount.String(), Valid: true}
+		convertedCurrency = sql.NullString{String: transaction.ConvertedAmount.Currency, Valid: true}
+	}
+
+	res, err := stmtIns.Exec("pain", transaction.PainType, transaction.Sender.AccountNumber, transaction.Sender.BankNumber, transaction.Receiver.AccountNumber, transaction.Receiver.BankNumber,
+		transaction.Amount, transaction.Amount.Currency, fxRate, convertedAmount, convertedCurrency, transaction.Fee, transaction.Desc, transaction.Timestamp, transaction.Status, reversalOf, mandateID, holdID, geoText)
+
+	if err != nil {
+		return 0, errors.New("payments.savePainTransaction: " + err.Error())
+	}
+
+	transactionID, err = res.LastInsertId()
+	if err != nil {
+		return 0, errors.New("payments.savePainTransaction: Could not get transaction ID. " + err.Error())
+	}
 
-	_, err = stmtIns.Exec("pain", transaction.PainType, transaction.Sender.AccountNumber, transaction.Sender.BankNumber, transaction.Receiver.AccountNumber, transaction.Receiver.BankNumber,
-		transaction.Amount, feeAmount, transaction.Desc, transaction.Timestamp, transaction.Status, geoText)
+	err = saveStatusHistory(tx, int32(transactionID), "", transaction.Status, "")
+	if err != nil {
+		return 0, errors.New("payments.savePainTransaction: " + err.Error())
+	}
 
+	// The fee breakdown is stored alongside the transaction
+	err = fees.SaveItems(tx, transactionID, transaction.Fees)
 	if err != nil {
-		return errors.New("payments.savePainTransaction: " + err.Error())
+		return 0, errors.New("payments.savePainTransaction: " + err.Error())
 	}
 
 	return
@@ -54,11 +99,8 @@ func removePainTransaction(transaction PAINTrans) (err error) {
 	}
 	defer stmtDel.Close() // Close the statement when we leave main() / the program terminates
 
-	// The feePerc is a percentage, convert to amount
-	feeAmount := transaction.Amount.Mul(transaction.Fee)
-
 	_, err = stmtDel.Exec("pain", transaction.PainType, transaction.Sender.AccountNumber, transaction.Sender.BankNumber, transaction.Receiver.AccountNumber, transaction.Receiver.BankNumber,
-		transaction.Amount, feeAmount)
+		transaction.Amount, transaction.Fee)
 
 	if err != nil {
 		return errors.New("payments.removePainTransaction: " + err.Error())
@@ -68,31 +110,48 @@ func removePainTransaction(transaction PAINTrans) (err error) {
 }
 
 //func updateAccounts(sender AccountHolder, receiver AccountHolder, transactionAmount float64, transactionFee float64) {
-func updateAccounts(transaction PAINTrans) (err error) {
+func updateAccounts(tx *sql.Tx, transaction PAINTrans) (err error) {
 	t := time.Now()
 	sqlTime := int32(t.Unix())
 
-	// The feePerc is a percentage, convert to amount
-	feeAmount := transaction.Amount.Mul(transaction.Fee)
+	// The fee is already assessed and rounded
+	feeAmount := transaction.Fee
 
 	switch transaction.PainType {
-	// Payment
-	case 1:
-		err = processCreditInitiation(transaction, sqlTime, feeAmount)
+	// Payment, card payment and captured hold
+	case 1, PAIN_CARD_PAYMENT, PAIN_HOLD_CAPTURE:
+		err = processCreditInitiation(tx, transaction, sqlTime, feeAmount)
+		if err != nil {
+			return errors.New("payments.updateAccounts: " + err.Error())
+		}
+		break
+	// Direct debit
+	case 8:
+		err = processDirectDebitInitiation(tx, transaction, sqlTime, feeAmount)
+		if err != nil {
+			return errors.New("payments.updateAccounts: " + err.Error())
+		}
+		break
+	// Reversal
+	case 7:
+		err = processReversalInitiation(tx, transaction, sqlTime, feeAmount)
 		if err != nil {
 			return errors.New("payments.updateAccounts: " + err.Error())
 		}
 		break
 	// Deposit
 	case 1000:
-		err = processDepositInitiation(transaction, sqlTime, feeAmount)
+		err = processDepositInitiation(tx, transaction, sqlTime, feeAmount)
 		if err != nil {
 			return errors.New("payments.updateAccounts: " + err.Error())
 		}
 		break
 	}
 
-	err = updateBankHoldingAccount(feeAmount, sqlTime)
+	// Record the balanced journal entry for everything moved above, fees included
+	journalEntry := journalEntryForTransaction(transaction)
+	journalEntry.Timestamp = sqlTime
+	_, err = ledger.PostJournal(tx, journalEntry)
 	if err != nil {
 		return errors.New("payments.updateAccounts: " + err.Error())
 	}
@@ -101,52 +160,117 @@ func updateAccounts(transaction PAINTrans) (err error) {
 
 }
 
-func updateBankHoldingAccount(feeAmount decimal.Decimal, sqlTime int32) (err error) {
-	// Add fees to bank holding account
-	// Only one row in this account for now - only holds single holding bank's balance
-	updateBank := "UPDATE `bank_account` SET `balance` = (`balance` + ?), `timestamp` = ?"
-	stmtUpdBank, err := Config.Db.Prepare(updateBank)
-	if err != nil {
-		return errors.New("payments.updateBankHoldingAccount: " + err.Error())
+// journalEntryForTransaction mirrors the balance movements made by updateAccounts.
+// Legs that are not held at this bank are booked to the suspense account, except payments
+// from another bank, which that bank has paid through its clearing account
+func journalEntryForTransaction(transaction PAINTrans) (entry ledger.JournalEntry) {
+	feeAmount := transaction.Fee
+	entry.TransactionID = int64(transaction.ID)
+	entry.Desc = "pain~" + strconv.FormatInt(transaction.PainType, 10) + " " + transaction.Desc
+
+	switch transaction.PainType {
+	// Payment, card payment and captured hold
+	case 1, PAIN_CARD_PAYMENT, PAIN_HOLD_CAPTURE:
+		// Sender pays the amount and the fee
+		entry.Lines = append(entry.Lines, ledger.Debit(payerLedgerAccountFor(transaction.Sender), transaction.Amount.Add(feeAmount).Amount)...)
+		entry.Lines = append(entry.Lines, ledger.Credit(ledgerAccountFor(transaction.Receiver), transaction.Amount.Amount)...)
+		entry.Lines = append(entry.Lines, feeIncomeLines(transaction.Fees, false)...)
+	// Direct debit
+	case 8:
+		// Debtor pays the amount, the merchant receives it less the fee
+		entry.Lines = append(entry.Lines, ledger.Debit(ledgerAccountFor(transaction.Sender), transaction.Amount.Amount)...)
+		entry.Lines = append(entry.Lines, ledger.Credit(ledgerAccountFor(transaction.Receiver), transaction.Amount.Sub(feeAmount).Amount)...)
+		entry.Lines = append(entry.Lines, feeIncomeLines(transaction.Fees, false)...)
+	// Reversal
+	case 7:
+		// The original receiver gives back the amount, the bank gives back any refunded fees
+		entry.Lines = append(entry.Lines, ledger.Debit(ledgerAccountFor(transaction.Sender), transaction.Amount.Amount)...)
+		entry.Lines = append(entry.Lines, feeIncomeLines(transaction.Fees, true)...)
+		entry.Lines = append(entry.Lines, ledger.Credit(ledgerAccountFor(transaction.Receiver), transaction.Amount.Add(feeAmount).Amount)...)
+	// Deposit
+	case 1000:
+		// Receiver gets the deposit less the fee
+		entry.Lines = append(entry.Lines, ledger.Debit(ledger.InCurrency(ledger.CASH, transaction.Amount.Currency), transaction.Amount.Amount)...)
+		entry.Lines = append(entry.Lines, ledger.Credit(ledgerAccountFor(transaction.Receiver), transaction.Amount.Sub(feeAmount).Amount)...)
+		entry.Lines = append(entry.Lines, feeIncomeLines(transaction.Fees, false)...)
 	}
-	defer stmtUpdBank.Close() // Close the statement when we leave main() / the program terminates
 
-	_, err = stmtUpdBank.Exec(feeAmount, sqlTime)
+	return
+}
 
-	if err != nil {
-		return errors.New("payments.updateBankHoldingAccount: " + err.Error())
+// feeIncomeLines credits each fee to its income account, or debits it back when the fees
+// are refunded
+func feeIncomeLines(items []fees.Item, refund bool) (lines []ledger.JournalLine) {
+	for _, item := range items {
+		if refund {
+			lines = append(lines, ledger.Debit(item.IncomeAccount, item.Amount.Amount)...)
+			continue
+		}
+		lines = append(lines, ledger.Credit(item.IncomeAccount, item.Amount.Amount)...)
 	}
 	return
 }
 
+// ledgerAccountFor returns the customer's ledger account if the account is local, otherwise suspense
+func ledgerAccountFor(accountHolder AccountHolder) string {
+	if accountHolder.BankNumber == "" {
+		return accountHolder.AccountNumber
+	}
+	return ledger.SUSPENSE
+}
+
+// payerLedgerAccountFor returns the customer's ledger account if the account is local, otherwise
+// the clearing account of the paying bank
+func payerLedgerAccountFor(accountHolder AccountHolder) string {
+	if accountHolder.BankNumber == "" {
+		return accountHolder.AccountNumber
+	}
+	return ledger.ClearingAccount(accountHolder.BankNumber)
+}
+
+// checkBalance returns what the account can spend: the available balance, which can never be
+// more than the balance plus the overdraft limit. It reads with SELECT ... FOR UPDATE, so the
+// account row stays locked until tx commits or rolls back
 // @TODO Look at using accounts.getAccountDetails here
-func checkBalance(account AccountHolder) (balance decimal.Decimal, err error) {
-	rows, err := Config.Db.Query("SELECT `availableBalance` FROM `accounts` WHERE `accountNumber` = ?", account.AccountNumber)
+func checkBalance(tx *sql.Tx, account AccountHolder) (balance money.Money, err error) {
+	rows, err := tx.Query("SELECT `accountBalance`, `overdraft`, `availableBalance`, `currency` FROM `accounts` WHERE `accountNumber` = ? FOR UPDATE", account.AccountNumber)
 	if err != nil {
-		return decimal.NewFromFloat(0.), errors.New("payments.checkBalance: " + err.Error())
+		return money.Money{}, errors.New("payments.checkBalance: " + err.Error())
 	}
 	defer rows.Close()
 
 	count := 0
 	for rows.Next() {
-		if err := rows.Scan(&balance); err != nil {
-			return decimal.NewFromFloat(0.), errors.New("payments.checkBalance: Could not retrieve account details. " + err.Error())
+		var accountBalance, overdraft money.Money
+		var currency string
+		if err := rows.Scan(&accountBalance, &overdraft, &balance, &currency); err != nil {
+			return money.Money{}, errors.New("payments.checkBalance: Could not retrieve account details. " + err.Error())
 		}
+		balance = spendableBalance(accountBalance.In(currency), overdraft.In(currency), balance.In(currency))
 		count++
 	}
 
 	if count > 1 {
-		return decimal.NewFromFloat(0.), errors.New("payments.checkBalance: More than one account found with uuid")
+		return money.Money{}, errors.New("payments.checkBalance: More than one account found with uuid")
 	}
 
 	return
 }
 
-func processCreditInitiation(transaction PAINTrans, sqlTime int32, feeAmount decimal.Decimal) (err error) {
+// spendableBalance caps the available balance at the balance plus the overdraft limit
+func spendableBalance(accountBalance money.Money, overdraft money.Money, availableBalance money.Money) money.Money {
+	limit := accountBalance.Add(overdraft)
+	if availableBalance.Cmp(limit) > 0 {
+		return limit
+	}
+	return availableBalance
+}
+
+func processCreditInitiation(tx *sql.Tx, transaction PAINTrans, sqlTime int32, feeAmount money.Money) (err error) {
 	// Only update if account local
 	if transaction.Sender.BankNumber == "" {
 		updateSenderStatement := "UPDATE accounts SET `accountBalance` = (`accountBalance` - ?), `availableBalance` = (`availableBalance` - ?), `timestamp` = ? WHERE `accountNumber` = ? "
-		stmtUpdSender, err := Config.Db.Prepare(updateSenderStatement)
+		stmtUpdSender, err := tx.Prepare(updateSenderStatement)
 		if err != nil {
 			return errors.New("payments.processCreditInitiation: " + err.Error())
 		}
@@ -158,15 +282,20 @@ func processCreditInitiation(transaction PAINTrans, sqlTime int32, feeAmount dec
 			return errors.New("payments.processCreditInitiation: " + err.Error())
 		}
 
+		_, err = statements.SaveNotification(tx, transaction.Sender.AccountNumber, int64(transaction.ID), iso20022.DEBIT, transaction.Amount.Add(feeAmount), sqlTime)
+		if err != nil {
+			return errors.New("payments.processCreditInitiation: " + err.Error())
+		}
+
 	} else {
-		// Drop onto ledger
+		// The sending bank has paid us through its clearing account, see journalEntryForTransaction
 	}
 
 	// Update receiver account
 	// Only update if account local
 	if transaction.Receiver.BankNumber == "" {
 		updateStatementReceiver := "UPDATE accounts SET `accountBalance` = (`accountBalance` + ?), `availableBalance` = (`availableBalance` + ?), `timestamp` = ? WHERE `accountNumber` = ? "
-		stmtUpdReceiver, err := Config.Db.Prepare(updateStatementReceiver)
+		stmtUpdReceiver, err := tx.Prepare(updateStatementReceiver)
 		if err != nil {
 			return errors.New("payments.processCreditInitiation: " + err.Error())
 		}
@@ -177,13 +306,22 @@ func processCreditInitiation(transaction PAINTrans, sqlTime int32, feeAmount dec
 		if err != nil {
 			return errors.New("payments.processCreditInitiation: " + err.Error())
 		}
+
+		_, err = statements.SaveNotification(tx, transaction.Receiver.AccountNumber, int64(transaction.ID), iso20022.CREDIT, transaction.Amount, sqlTime)
+		if err != nil {
+			return errors.New("payments.processCreditInitiation: " + err.Error())
+		}
 	} else {
-		// Drop onto ledger
+		// The amount waits in suspense until the receiving bank answers the pacs.008
+		err = queueCreditTransfer(tx, transaction, sqlTime)
+		if err != nil {
+			return errors.New("payments.processCreditInitiation: " + err.Error())
+		}
 	}
 	return
 }
 
-func processDepositInitiation(transaction PAINTrans, sqlTime int32, feeAmount decimal.Decimal) (err error) {
+func processDepositInitiation(tx *sql.Tx, transaction PAINTrans, sqlTime int32, feeAmount money.Money) (err error) {
 	// We don't update sender as it is deposit
 	// Update receiver account
 	// The total received amount is the deposited amount minus the fee
@@ -191,7 +329,7 @@ func processDepositInitiation(transaction PAINTrans, sqlTime int32, feeAmount de
 	// Only update if account local
 	if transaction.Receiver.BankNumber == "" {
 		updateStatementReceiver := "UPDATE accounts SET `accountBalance` = (`accountBalance` + ?), `availableBalance` = (`availableBalance` + ?), `timestamp` = ? WHERE `accountNumber` = ? "
-		stmtUpdReceiver, err := Config.Db.Prepare(updateStatementReceiver)
+		stmtUpdReceiver, err := tx.Prepare(updateStatementReceiver)
 		if err != nil {
 			return errors.New("payments.processDepositInitiation: " + err.Error())
 		}
@@ -202,14 +340,226 @@ func processDepositInitiation(transaction PAINTrans, sqlTime int32, feeAmount de
 		if err != nil {
 			return errors.New("payments.processDepositInitiation: " + err.Error())
 		}
+
+		_, err = statements.SaveNotification(tx, transaction.Receiver.AccountNumber, int64(transaction.ID), iso20022.CREDIT, depositTransactionAmount, sqlTime)
+		if err != nil {
+			return errors.New("payments.processDepositInitiation: " + err.Error())
+		}
 	} else {
 		// Drop onto ledger
 	}
 	return
 }
 
+// processReversalInitiation moves a payment back. The sender is the original receiver, the
+// receiver is the original sender and feeAmount is the fee refunded to the original sender
+func processReversalInitiation(tx *sql.Tx, transaction PAINTrans, sqlTime int32, feeAmount money.Money) (err error) {
+	updateSenderStatement := "UPDATE accounts SET `accountBalance` = (`accountBalance` - ?), `availableBalance` = (`availableBalance` - ?), `timestamp` = ? WHERE `accountNumber` = ? "
+	stmtUpdSender, err := tx.Prepare(updateSenderStatement)
+	if err != nil {
+		return errors.New("payments.processReversalInitiation: " + err.Error())
+	}
+	defer stmtUpdSender.Close()
+
+	_, err = stmtUpdSender.Exec(transaction.Amount, transaction.Amount, sqlTime, transaction.Sender.AccountNumber)
+	if err != nil {
+		return errors.New("payments.processReversalInitiation: " + err.Error())
+	}
+
+	_, err = statements.SaveNotification(tx, transaction.Sender.AccountNumber, int64(transaction.ID), iso20022.DEBIT, transaction.Amount, sqlTime)
+	if err != nil {
+		return errors.New("payments.processReversalInitiation: " + err.Error())
+	}
+
+	updateStatementReceiver := "UPDATE accounts SET `accountBalance` = (`accountBalance` + ?), `availableBalance` = (`availableBalance` + ?), `timestamp` = ? WHERE `accountNumber` = ? "
+	stmtUpdReceiver, err := tx.Prepare(updateStatementReceiver)
+	if err != nil {
+		return errors.New("payments.processReversalInitiation: " + err.Error())
+	}
+	defer stmtUpdReceiver.Close()
+
+	_, err = stmtUpdReceiver.Exec(transaction.Amount.Add(feeAmount), transaction.Amount.Add(feeAmount), sqlTime, transaction.Receiver.AccountNumber)
+	if err != nil {
+		return errors.New("payments.processReversalInitiation: " + err.Error())
+	}
+
+	_, err = statements.SaveNotification(tx, transaction.Receiver.AccountNumber, int64(transaction.ID), iso20022.CREDIT, transaction.Amount.Add(feeAmount), sqlTime)
+	if err != nil {
+		return errors.New("payments.processReversalInitiation: " + err.Error())
+	}
+
+	return
+}
+
+// processDirectDebitInitiation collects from the debtor (sender) into the merchant (receiver).
+// The merchant bears the fee
+func processDirectDebitInitiation(tx *sql.Tx, transaction PAINTrans, sqlTime int32, feeAmount money.Money) (err error) {
+	updateSenderStatement := "UPDATE accounts SET `accountBalance` = (`accountBalance` - ?), `availableBalance` = (`availableBalance` - ?), `timestamp` = ? WHERE `accountNumber` = ? "
+	stmtUpdSender, err := tx.Prepare(updateSenderStatement)
+	if err != nil {
+		return errors.New("payments.processDirectDebitInitiation: " + err.Error())
+	}
+	defer stmtUpdSender.Close()
+
+	_, err = stmtUpdSender.Exec(transaction.Amount, transaction.Amount, sqlTime, transaction.Sender.AccountNumber)
+	if err != nil {
+		return errors.New("payments.processDirectDebitInitiation: " + err.Error())
+	}
+
+	_, err = statements.SaveNotification(tx, transaction.Sender.AccountNumber, int64(transaction.ID), iso20022.DEBIT, transaction.Amount, sqlTime)
+	if err != nil {
+		return errors.New("payments.processDirectDebitInitiation: " + err.Error())
+	}
+
+	receivedAmount := transaction.Amount.Sub(feeAmount)
+	updateStatementReceiver := "UPDATE accounts SET `accountBalance` = (`accountBalance` + ?), `availableBalance` = (`availableBalance` + ?), `timestamp` = ? WHERE `accountNumber` = ? "
+	stmtUpdReceiver, err := tx.Prepare(updateStatementReceiver)
+	if err != nil {
+		return errors.New("payments.processDirectDebitInitiation: " + err.Error())
+	}
+	defer stmtUpdReceiver.Close()
+
+	_, err = stmtUpdReceiver.Exec(receivedAmount, receivedAmount, sqlTime, transaction.Receiver.AccountNumber)
+	if err != nil {
+		return errors.New("payments.processDirectDebitInitiation: " + err.Error())
+	}
+
+	_, err = statements.SaveNotification(tx, transaction.Receiver.AccountNumber, int64(transaction.ID), iso20022.CREDIT, receivedAmount, sqlTime)
+	if err != nil {
+		return errors.New("payments.processDirectDebitInitiation: " + err.Error())
+	}
+
+	return
+}
+
+// getTransactionForUpdate fetches a single PAIN transaction and locks its row until tx ends.
+// Transactions other packages record, such as loan disbursements, are not found
+func getTransactionForUpdate(tx *sql.Tx, transactionID int32) (transaction PAINTrans, err error) {
+	rows, err := tx.Query("SELECT `id`, `type`, `senderAccountNumber`, `senderBankNumber`, `receiverAccountNumber`, `receiverBankNumber`, `transactionAmount`, `currency`, `feeAmount`, `desc`, `timestamp`, `status` FROM `transactions` WHERE `id` = ? AND `transaction` = 'pain' FOR UPDATE", transactionID)
+	if err != nil {
+		return PAINTrans{}, errors.New("payments.getTransactionForUpdate: " + err.Error())
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var currency string
+		if err := rows.Scan(&transaction.ID, &transaction.PainType, &transaction.Sender.AccountNumber, &transaction.Sender.BankNumber, &transaction.Receiver.AccountNumber, &transaction.Receiver.BankNumber, &transaction.Amount, &currency, &transaction.Fee, &transaction.Desc, &transaction.Timestamp, &transaction.Status); err != nil {
+			return PAINTrans{}, errors.New("payments.getTransactionForUpdate: " + err.Error())
+		}
+		transaction.inCurrency(currency, "")
+		count++
+	}
+
+	if count == 0 {
+		return PAINTrans{}, errors.New("payments.getTransactionForUpdate: Transaction not found")
+	}
+
+	return
+}
+
+// setTransactionStatus moves a transaction from fromStatus to toStatus and records the change.
+// The status condition makes sure concurrent changes cannot both succeed, e.g. a payment
+// can only ever be reversed once
+func setTransactionStatus(tx *sql.Tx, transactionID int32, fromStatus string, toStatus string, reasonCode string) (err error) {
+	err = checkStatusTransition(fromStatus, toStatus)
+	if err != nil {
+		return errors.New("payments.setTransactionStatus: " + err.Error())
+	}
+
+	stmtUpd, err := tx.Prepare("UPDATE `transactions` SET `status` = ? WHERE `id` = ? AND `status` = ?")
+	if err != nil {
+		return errors.New("payments.setTransactionStatus: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	res, err := stmtUpd.Exec(toStatus, transactionID, fromStatus)
+	if err != nil {
+		return errors.New("payments.setTransactionStatus: " + err.Error())
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errors.New("payments.setTransactionStatus: Could not get rows affected. " + err.Error())
+	}
+	if affected != 1 {
+		return errors.New("payments.setTransactionStatus: Transaction is no longer " + fromStatus)
+	}
+
+	err = saveStatusHistory(tx, transactionID, fromStatus, toStatus, reasonCode)
+	if err != nil {
+		return errors.New("payments.setTransactionStatus: " + err.Error())
+	}
+
+	return
+}
+
+func saveStatusHistory(tx *sql.Tx, transactionID int32, fromStatus string, toStatus string, reasonCode string) (err error) {
+	insertStatement := "INSERT INTO transaction_status_history (`transactionID`, `fromStatus`, `toStatus`, `reasonCode`, `timestamp`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?)"
+	stmtIns, err := tx.Prepare(insertStatement)
+	if err != nil {
+		return errors.New("payments.saveStatusHistory: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	sqlTime := int32(time.Now().Unix())
+	_, err = stmtIns.Exec(transactionID, fromStatus, toStatus, reasonCode, sqlTime)
+	if err != nil {
+		return errors.New("payments.saveStatusHistory: " + err.Error())
+	}
+
+	return
+}
+
+func getStatusHistory(transactionID int32) (history []StatusChange, err error) {
+	rows, err := Config.Db.Query("SELECT `fromStatus`, `toStatus`, `reasonCode`, `timestamp` FROM `transaction_status_history` WHERE `transactionID` = ? ORDER BY `id` ASC", transactionID)
+	if err != nil {
+		return []StatusChange{}, errors.New("payments.getStatusHistory: " + err.Error())
+	}
+	defer rows.Close()
+
+	history = []StatusChange{}
+	for rows.Next() {
+		change := StatusChange{}
+		if err := rows.Scan(&change.FromStatus, &change.ToStatus, &change.ReasonCode, &change.Timestamp); err != nil {
+			return []StatusChange{}, errors.New("payments.getStatusHistory: " + err.Error())
+		}
+		history = append(history, change)
+	}
+
+	return
+}
+
+// Rate and converted amount of a conversion, empty for other transactions
+const conversionColumns = "COALESCE(`fxRate`, 0), COALESCE(`convertedAmount`, 0), COALESCE(`convertedCurrency`, '')"
+
+func getTransaction(transactionID int32) (transaction PAINTrans, err error) {
+	rows, err := Config.Db.Query("SELECT `id`, `type`, `senderAccountNumber`, `senderBankNumber`, `receiverAccountNumber`, `receiverBankNumber`, `transactionAmount`, `currency`, "+conversionColumns+", `feeAmount`, `desc`, `timestamp`, `status` FROM `transactions` WHERE `id` = ?", transactionID)
+	if err != nil {
+		return PAINTrans{}, errors.New("payments.getTransaction: " + err.Error())
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var currency, convertedCurrency string
+		if err := rows.Scan(&transaction.ID, &transaction.PainType, &transaction.Sender.AccountNumber, &transaction.Sender.BankNumber, &transaction.Receiver.AccountNumber, &transaction.Receiver.BankNumber, &transaction.Amount, &currency, &transaction.FXRate, &transaction.ConvertedAmount, &convertedCurrency, &transaction.Fee, &transaction.Desc, &transaction.Timestamp, &transaction.Status); err != nil {
+			return PAINTrans{}, errors.New("payments.getTransaction: " + err.Error())
+		}
+		transaction.inCurrency(currency, convertedCurrency)
+		count++
+	}
+
+	if count == 0 {
+		return PAINTrans{}, errors.New("payments.getTransaction: Transaction not found")
+	}
+
+	return
+}
+
 func getTransactionList(accountNumber string, offset int, perPage int) (allTransactions []PAINTrans, err error) {
-	rows, err := Config.Db.Query("SELECT `id`, `type`, `senderAccountNumber`, `senderBankNumber`, `receiverAccountNumber`, `receiverBankNumber`, `transactionAmount`, `feeAmount`, `desc`, `timestamp`, `status`, `geo` FROM `transactions` WHERE `senderAccountNumber` = ? OR `receiverAccountNumber` = ?  ORDER BY `id` DESC LIMIT ?, ?", accountNumber, accountNumber, offset, perPage)
+	rows, err := Config.Db.Query("SELECT `id`, `type`, `senderAccountNumber`, `senderBankNumber`, `receiverAccountNumber`, `receiverBankNumber`, `transactionAmount`, `currency`, "+conversionColumns+", `feeAmount`, `desc`, `timestamp`, `status`, `geo` FROM `transactions` WHERE `senderAccountNumber` = ? OR `receiverAccountNumber` = ?  ORDER BY `id` DESC LIMIT ?, ?", accountNumber, accountNumber, offset, perPage)
 	if err != nil {
 		return []PAINTrans{}, errors.New("transactions.ListTransactions: " + err.Error())
 	}
@@ -218,9 +568,11 @@ func getTransactionList(accountNumber string, offset int, perPage int) (allTrans
 	allTransactions = []PAINTrans{}
 	for rows.Next() {
 		transaction := PAINTrans{}
-		if err := rows.Scan(&transaction.ID, &transaction.PainType, &transaction.Sender.AccountNumber, &transaction.Sender.BankNumber, &transaction.Receiver.AccountNumber, &transaction.Receiver.BankNumber, &transaction.Amount, &transaction.Fee, &transaction.Desc, &transaction.Timestamp, &transaction.Status, &transaction.Geo); err != nil {
+		var currency, convertedCurrency string
+		if err := rows.Scan(&transaction.ID, &transaction.PainType, &transaction.Sender.AccountNumber, &transaction.Sender.BankNumber, &transaction.Receiver.AccountNumber, &transaction.Receiver.BankNumber, &transaction.Amount, &currency, &transaction.FXRate, &transaction.ConvertedAmount, &convertedCurrency, &transaction.Fee, &transaction.Desc, &transaction.Timestamp, &transaction.Status, &transaction.Geo); err != nil {
 			return []PAINTrans{}, errors.New("transactions.ListTransactions: " + err.Error())
 		}
+		transaction.inCurrency(currency, convertedCurrency)
 		allTransactions = append(allTransactions, transaction)
 	}
 
@@ -228,7 +580,7 @@ func getTransactionList(accountNumber string, offset int, perPage int) (allTrans
 }
 
 func getTransactionListAfterTimestamp(accountNumber string, offset int, perPage int, timestamp int) (allTransactions []PAINTrans, err error) {
-	rows, err := Config.Db.Query("SELECT `id`, `type`, `senderAccountNumber`, `senderBankNumber`, `receiverAccountNumber`, `receiverBankNumber`, `transactionAmount`, `feeAmount`, `desc`, `timestamp`, `status`, `geo` FROM `transactions` WHERE `timestamp` >= ? AND ( `senderAccountNumber` = ? OR `receiverAccountNumber` = ? ) ORDER BY `id` DESC LIMIT ?, ?", timestamp, accountNumber, accountNumber, offset, perPage)
+	rows, err := Config.Db.Query("SELECT `id`, `type`, `senderAccountNumber`, `senderBankNumber`, `receiverAccountNumber`, `receiverBankNumber`, `transactionAmount`, `currency`, "+conversionColumns+", `feeAmount`, `desc`, `timestamp`, `status`, `geo` FROM `transactions` WHERE `timestamp` >= ? AND ( `senderAccountNumber` = ? OR `receiverAccountNumber` = ? ) ORDER BY `id` DESC LIMIT ?, ?", timestamp, accountNumber, accountNumber, offset, perPage)
 	if err != nil {
 		return []PAINTrans{}, errors.New("transactions.ListTransactions: " + err.Error())
 	}
@@ -237,11 +589,677 @@ func getTransactionListAfterTimestamp(accountNumber string, offset int, perPage
 	allTransactions = []PAINTrans{}
 	for rows.Next() {
 		transaction := PAINTrans{}
-		if err := rows.Scan(&transaction.ID, &transaction.PainType, &transaction.Sender.AccountNumber, &transaction.Sender.BankNumber, &transaction.Receiver.AccountNumber, &transaction.Receiver.BankNumber, &transaction.Amount, &transaction.Fee, &transaction.Desc, &transaction.Timestamp, &transaction.Status, &transaction.Geo); err != nil {
+		var currency, convertedCurrency string
+		if err := rows.Scan(&transaction.ID, &transaction.PainType, &transaction.Sender.AccountNumber, &transaction.Sender.BankNumber, &transaction.Receiver.AccountNumber, &transaction.Receiver.BankNumber, &transaction.Amount, &currency, &transaction.FXRate, &transaction.ConvertedAmount, &convertedCurrency, &transaction.Fee, &transaction.Desc, &transaction.Timestamp, &transaction.Status, &transaction.Geo); err != nil {
 			return []PAINTrans{}, errors.New("transactions.ListTransactions: " + err.Error())
 		}
+		transaction.inCurrency(currency, convertedCurrency)
 		allTransactions = append(allTransactions, transaction)
 	}
 
 	return
 }
+
+func saveMandate(mandate Mandate) (mandateID string, err error) {
+	newUuid, err := uuid.NewV4()
+	if err != nil {
+		return "", errors.New("payments.saveMandate: Could not generate mandate ID. " + err.Error())
+	}
+	mandateID = newUuid.String()
+
+	insertStatement := "INSERT INTO mandates (`mandateID`, `merchantID`, `creditorAccountNumber`, `creditorBankNumber`, `debtorAccountNumber`, `debtorBankNumber`, `maxAmount`, `currency`, `frequency`, `status`, `desc`, `lastCollection`, `timestamp`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	stmtIns, err := Config.Db.Prepare(insertStatement)
+	if err != nil {
+		return "", errors.New("payments.saveMandate: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	sqlTime := int32(time.Now().Unix())
+	_, err = stmtIns.Exec(mandateID, mandate.MerchantID, mandate.Creditor.AccountNumber, mandate.Creditor.BankNumber, mandate.Debtor.AccountNumber, mandate.Debtor.BankNumber,
+		mandate.MaxAmount, mandate.MaxAmount.Currency, mandate.Frequency, mandate.Status, mandate.Desc, 0, sqlTime)
+	if err != nil {
+		return "", errors.New("payments.saveMandate: " + err.Error())
+	}
+
+	return
+}
+
+const mandateColumns = "`id`, `mandateID`, `merchantID`, `creditorAccountNumber`, `creditorBankNumber`, `debtorAccountNumber`, `debtorBankNumber`, `maxAmount`, `currency`, `frequency`, `status`, `desc`, `lastCollection`, `timestamp`"
+
+func scanMandate(rows *sql.Rows) (mandate Mandate, err error) {
+	var currency string
+	err = rows.Scan(&mandate.ID, &mandate.MandateID, &mandate.MerchantID, &mandate.Creditor.AccountNumber, &mandate.Creditor.BankNumber, &mandate.Debtor.AccountNumber, &mandate.Debtor.BankNumber,
+		&mandate.MaxAmount, &currency, &mandate.Frequency, &mandate.Status, &mandate.Desc, &mandate.LastCollection, &mandate.Timestamp)
+	mandate.MaxAmount = mandate.MaxAmount.In(currency)
+	return
+}
+
+func getMandate(mandateID string) (mandate Mandate, err error) {
+	rows, err := Config.Db.Query("SELECT "+mandateColumns+" FROM `mandates` WHERE `mandateID` = ?", mandateID)
+	if err != nil {
+		return Mandate{}, errors.New("payments.getMandate: " + err.Error())
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		mandate, err = scanMandate(rows)
+		if err != nil {
+			return Mandate{}, errors.New("payments.getMandate: " + err.Error())
+		}
+		count++
+	}
+
+	if count == 0 {
+		return Mandate{}, errors.New("payments.getMandate: Mandate not found")
+	}
+
+	return
+}
+
+// getMandateForUpdate fetches a mandate and locks its row until tx ends
+func getMandateForUpdate(tx *sql.Tx, mandateID string) (mandate Mandate, err error) {
+	rows, err := tx.Query("SELECT "+mandateColumns+" FROM `mandates` WHERE `mandateID` = ? FOR UPDATE", mandateID)
+	if err != nil {
+		return Mandate{}, errors.New("payments.getMandateForUpdate: " + err.Error())
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		mandate, err = scanMandate(rows)
+		if err != nil {
+			return Mandate{}, errors.New("payments.getMandateForUpdate: " + err.Error())
+		}
+		count++
+	}
+
+	if count == 0 {
+		return Mandate{}, errors.New("payments.getMandateForUpdate: Mandate not found")
+	}
+
+	return
+}
+
+func getMandateList(accountNumber string) (allMandates []Mandate, err error) {
+	rows, err := Config.Db.Query("SELECT "+mandateColumns+" FROM `mandates` WHERE `creditorAccountNumber` = ? OR `debtorAccountNumber` = ? ORDER BY `id` DESC", accountNumber, accountNumber)
+	if err != nil {
+		return []Mandate{}, errors.New("payments.getMandateList: " + err.Error())
+	}
+	defer rows.Close()
+
+	allMandates = []Mandate{}
+	for rows.Next() {
+		mandate, err := scanMandate(rows)
+		if err != nil {
+			return []Mandate{}, errors.New("payments.getMandateList: " + err.Error())
+		}
+		allMandates = append(allMandates, mandate)
+	}
+
+	return
+}
+
+// updateMandateTerms amends a pending or active mandate. The amended mandate goes back to pending
+func updateMandateTerms(mandateID string, maxAmount money.Money, frequency string) (err error) {
+	stmtUpd, err := Config.Db.Prepare("UPDATE `mandates` SET `maxAmount` = ?, `frequency` = ?, `status` = ?, `timestamp` = ? WHERE `mandateID` = ? AND `status` IN (?, ?)")
+	if err != nil {
+		return errors.New("payments.updateMandateTerms: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	sqlTime := int32(time.Now().Unix())
+	res, err := stmtUpd.Exec(maxAmount, frequency, MANDATE_PENDING, sqlTime, mandateID, MANDATE_PENDING, MANDATE_ACTIVE)
+	if err != nil {
+		return errors.New("payments.updateMandateTerms: " + err.Error())
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errors.New("payments.updateMandateTerms: Could not get rows affected. " + err.Error())
+	}
+	if affected != 1 {
+		return errors.New("payments.updateMandateTerms: Mandate cannot be amended")
+	}
+
+	return
+}
+
+// updateMandateStatus moves a mandate from currentStatus to newStatus. The update fails if the
+// status has changed in the meantime
+func updateMandateStatus(mandateID string, currentStatus string, newStatus string) (err error) {
+	stmtUpd, err := Config.Db.Prepare("UPDATE `mandates` SET `status` = ?, `timestamp` = ? WHERE `mandateID` = ? AND `status` = ?")
+	if err != nil {
+		return errors.New("payments.updateMandateStatus: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	sqlTime := int32(time.Now().Unix())
+	res, err := stmtUpd.Exec(newStatus, sqlTime, mandateID, currentStatus)
+	if err != nil {
+		return errors.New("payments.updateMandateStatus: " + err.Error())
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errors.New("payments.updateMandateStatus: Could not get rows affected. " + err.Error())
+	}
+	if affected != 1 {
+		return errors.New("payments.updateMandateStatus: Mandate status has changed, please retry")
+	}
+
+	return
+}
+
+func updateMandateLastCollection(tx *sql.Tx, mandateID string, sqlTime int32) (err error) {
+	stmtUpd, err := tx.Prepare("UPDATE `mandates` SET `lastCollection` = ? WHERE `mandateID` = ?")
+	if err != nil {
+		return errors.New("payments.updateMandateLastCollection: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	_, err = stmtUpd.Exec(sqlTime, mandateID)
+	if err != nil {
+		return errors.New("payments.updateMandateLastCollection: " + err.Error())
+	}
+
+	return
+}
+
+func saveHold(tx *sql.Tx, hold Hold) (holdID int64, err error) {
+	insertStatement := "INSERT INTO holds (`accountNumber`, `payeeAccountNumber`, `amount`, `currency`, `status`, `desc`, `expires`, `timestamp`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?, ?)"
+	stmtIns, err := tx.Prepare(insertStatement)
+	if err != nil {
+		return 0, errors.New("payments.saveHold: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	res, err := stmtIns.Exec(hold.Account.AccountNumber, hold.Payee.AccountNumber, hold.Amount, hold.Amount.Currency, hold.Status, hold.Desc, hold.Expires, hold.Timestamp)
+	if err != nil {
+		return 0, errors.New("payments.saveHold: " + err.Error())
+	}
+
+	holdID, err = res.LastInsertId()
+	if err != nil {
+		return 0, errors.New("payments.saveHold: Could not get hold ID. " + err.Error())
+	}
+
+	return
+}
+
+const holdColumns = "`id`, `accountNumber`, `payeeAccountNumber`, `amount`, `capturedAmount`, `currency`, `status`, `desc`, `expires`, `timestamp`"
+
+func scanHold(rows *sql.Rows) (hold Hold, err error) {
+	var currency string
+	err = rows.Scan(&hold.ID, &hold.Account.AccountNumber, &hold.Payee.AccountNumber, &hold.Amount, &hold.CapturedAmount, &currency, &hold.Status, &hold.Desc, &hold.Expires, &hold.Timestamp)
+	hold.Amount = hold.Amount.In(currency)
+	hold.CapturedAmount = hold.CapturedAmount.In(currency)
+	return
+}
+
+// getHoldForUpdate fetches a hold and locks its row until tx ends
+func getHoldForUpdate(tx *sql.Tx, holdID int64) (hold Hold, err error) {
+	rows, err := tx.Query("SELECT "+holdColumns+" FROM `holds` WHERE `id` = ? FOR UPDATE", holdID)
+	if err != nil {
+		return Hold{}, errors.New("payments.getHoldForUpdate: " + err.Error())
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		hold, err = scanHold(rows)
+		if err != nil {
+			return Hold{}, errors.New("payments.getHoldForUpdate: " + err.Error())
+		}
+		count++
+	}
+
+	if count == 0 {
+		return Hold{}, errors.New("payments.getHoldForUpdate: Hold not found")
+	}
+
+	return
+}
+
+// getActiveHolds lists the holds reserving funds on the account, soonest to expire first
+func getActiveHolds(accountNumber string) (allHolds []Hold, err error) {
+	rows, err := Config.Db.Query("SELECT "+holdColumns+" FROM `holds` WHERE `accountNumber` = ? AND `status` = ? ORDER BY `expires`", accountNumber, HOLD_ACTIVE)
+	if err != nil {
+		return []Hold{}, errors.New("payments.getActiveHolds: " + err.Error())
+	}
+	defer rows.Close()
+
+	allHolds = []Hold{}
+	for rows.Next() {
+		hold, err := scanHold(rows)
+		if err != nil {
+			return []Hold{}, errors.New("payments.getActiveHolds: " + err.Error())
+		}
+		allHolds = append(allHolds, hold)
+	}
+
+	return
+}
+
+func getExpiredHoldIDs(sqlTime int32) (holdIDs []int64, err error) {
+	rows, err := Config.Db.Query("SELECT `id` FROM `holds` WHERE `status` = ? AND `expires` <= ? ORDER BY `id`", HOLD_ACTIVE, sqlTime)
+	if err != nil {
+		return nil, errors.New("payments.getExpiredHoldIDs: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var holdID int64
+		if err := rows.Scan(&holdID); err != nil {
+			return nil, errors.New("payments.getExpiredHoldIDs: " + err.Error())
+		}
+		holdIDs = append(holdIDs, holdID)
+	}
+
+	return
+}
+
+func updateHoldStatus(tx *sql.Tx, holdID int64, currentStatus string, newStatus string, capturedAmount money.Money) (err error) {
+	stmtUpd, err := tx.Prepare("UPDATE `holds` SET `status` = ?, `capturedAmount` = ?, `timestamp` = ? WHERE `id` = ? AND `status` = ?")
+	if err != nil {
+		return errors.New("payments.updateHoldStatus: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	res, err := stmtUpd.Exec(newStatus, capturedAmount, int32(time.Now().Unix()), holdID, currentStatus)
+	if err != nil {
+		return errors.New("payments.updateHoldStatus: " + err.Error())
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errors.New("payments.updateHoldStatus: " + err.Error())
+	}
+	if affected == 0 {
+		return errors.New("payments.updateHoldStatus: Hold is not " + currentStatus)
+	}
+
+	return
+}
+
+// reserveHoldFunds takes held funds out of the available balance only
+func reserveHoldFunds(tx *sql.Tx, account AccountHolder, amount money.Money, sqlTime int32) (err error) {
+	stmtUpd, err := tx.Prepare("UPDATE accounts SET `availableBalance` = (`availableBalance` - ?), `timestamp` = ? WHERE `accountNumber` = ? ")
+	if err != nil {
+		return errors.New("payments.reserveHoldFunds: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	_, err = stmtUpd.Exec(amount, sqlTime, account.AccountNumber)
+	if err != nil {
+		return errors.New("payments.reserveHoldFunds: " + err.Error())
+	}
+
+	return
+}
+
+// releaseHoldFunds gives held funds back to the available balance, which never goes above the
+// balance plus the overdraft limit
+func releaseHoldFunds(tx *sql.Tx, account AccountHolder, amount money.Money, sqlTime int32) (err error) {
+	stmtUpd, err := tx.Prepare("UPDATE accounts SET `availableBalance` = LEAST(`availableBalance` + ?, `accountBalance` + `overdraft`), `timestamp` = ? WHERE `accountNumber` = ? ")
+	if err != nil {
+		return errors.New("payments.releaseHoldFunds: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	_, err = stmtUpd.Exec(amount, sqlTime, account.AccountNumber)
+	if err != nil {
+		return errors.New("payments.releaseHoldFunds: " + err.Error())
+	}
+
+	return
+}
+
+// getAccountStatusForUpdate returns the status of a local account, empty if it does not exist,
+// and locks its row until tx ends
+func getAccountStatusForUpdate(tx *sql.Tx, accountNumber string) (status string, err error) {
+	rows, err := tx.Query("SELECT `status` FROM `accounts` WHERE `accountNumber` = ? FOR UPDATE", accountNumber)
+	if err != nil {
+		return "", errors.New("payments.getAccountStatusForUpdate: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(&status); err != nil {
+			return "", errors.New("payments.getAccountStatusForUpdate: Could not retrieve account status. " + err.Error())
+		}
+	}
+
+	return
+}
+
+// checkAccountsClosed reports whether any local party to the transaction is a closed account
+func checkAccountsClosed(tx *sql.Tx, transaction PAINTrans) (closed bool, err error) {
+	for _, accountHolder := range []AccountHolder{transaction.Sender, transaction.Receiver} {
+		if accountHolder.BankNumber != "" {
+			continue
+		}
+		status, err := getAccountStatusForUpdate(tx, accountHolder.AccountNumber)
+		if err != nil {
+			return false, errors.New("payments.checkAccountsClosed: " + err.Error())
+		}
+		if status == accounts.ACCOUNT_CLOSED {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// checkSenderRestricted reports whether a local sender is a restricted account, whose holder is
+// not verified yet and cannot make payments
+func checkSenderRestricted(tx *sql.Tx, sender AccountHolder) (restricted bool, err error) {
+	if sender.BankNumber != "" {
+		return false, nil
+	}
+	status, err := getAccountStatusForUpdate(tx, sender.AccountNumber)
+	if err != nil {
+		return false, errors.New("payments.checkSenderRestricted: " + err.Error())
+	}
+	return status == accounts.ACCOUNT_RESTRICTED, nil
+}
+
+// getAccountCurrency returns the currency a local account is held in, empty when there is no
+// such account
+func getAccountCurrency(db ledger.Preparer, accountNumber string) (currency string, err error) {
+	stmt, err := db.Prepare("SELECT `currency` FROM `accounts` WHERE `accountNumber` = ?")
+	if err != nil {
+		return "", errors.New("payments.getAccountCurrency: " + err.Error())
+	}
+	defer stmt.Close()
+
+	err = stmt.QueryRow(accountNumber).Scan(&currency)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.New("payments.getAccountCurrency: Could not retrieve account currency. " + err.Error())
+	}
+	return
+}
+
+// checkCurrencies reports whether the transaction is in a currency one of its parties cannot
+// take. Local accounts only take their own currency. Other banks are paid in the currency of
+// the transfer, which they check against their own account. Amounts in another currency have
+// to be converted first
+func checkCurrencies(tx *sql.Tx, transaction PAINTrans) (mismatch bool, err error) {
+	for _, accountHolder := range []AccountHolder{transaction.Sender, transaction.Receiver} {
+		if accountHolder.BankNumber != "" {
+			continue
+		}
+		currency, err := getAccountCurrency(tx, accountHolder.AccountNumber)
+		if err != nil {
+			return false, errors.New("payments.checkCurrencies: " + err.Error())
+		}
+		if !currencyAccepted(currency, transaction.Amount) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// currencyAccepted checks an amount can be posted to an account held in currency. Parties that
+// are not accounts, such as the bank's own ledger accounts, take any currency
+func currencyAccepted(currency string, amount money.Money) bool {
+	return currency == "" || currency == amount.Currency
+}
+
+// refundSender credits money back to a local sender
+func refundSender(tx *sql.Tx, sender AccountHolder, amount money.Money, sqlTime int32) (err error) {
+	if sender.BankNumber != "" {
+		return
+	}
+
+	updateStatementSender := "UPDATE accounts SET `accountBalance` = (`accountBalance` + ?), `availableBalance` = (`availableBalance` + ?), `timestamp` = ? WHERE `accountNumber` = ? "
+	stmtUpdSender, err := tx.Prepare(updateStatementSender)
+	if err != nil {
+		return errors.New("payments.refundSender: " + err.Error())
+	}
+	defer stmtUpdSender.Close()
+
+	_, err = stmtUpdSender.Exec(amount, amount, sqlTime, sender.AccountNumber)
+	if err != nil {
+		return errors.New("payments.refundSender: " + err.Error())
+	}
+
+	return
+}
+
+func saveInterbankMessage(db ledger.Preparer, message InterbankMessage) (messageID int32, err error) {
+	// Outgoing messages belong to a transaction, incoming messages store NULL
+	var transactionID sql.NullInt64
+	if message.TransactionID != 0 {
+		transactionID = sql.NullInt64{Int64: int64(message.TransactionID), Valid: true}
+	}
+
+	insertStatement := "INSERT INTO interbank_messages (`messageID`, `direction`, `bankNumber`, `transactionID`, `status`, `document`, `response`, `attempts`, `timestamp`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	stmtIns, err := db.Prepare(insertStatement)
+	if err != nil {
+		return 0, errors.New("payments.saveInterbankMessage: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	res, err := stmtIns.Exec(message.MessageID, message.Direction, message.BankNumber, transactionID, message.Status, message.Document, message.Response, 0, message.Timestamp)
+	if err != nil {
+		return 0, errors.New("payments.saveInterbankMessage: " + err.Error())
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, errors.New("payments.saveInterbankMessage: Could not get message ID. " + err.Error())
+	}
+
+	return int32(id), nil
+}
+
+const interbankMessageColumns = "`id`, `messageID`, `direction`, `bankNumber`, COALESCE(`transactionID`, 0), `status`, `document`, `response`, `attempts`, `timestamp`"
+
+func scanInterbankMessages(rows *sql.Rows) (messages []InterbankMessage, err error) {
+	for rows.Next() {
+		message := InterbankMessage{}
+		if err := rows.Scan(&message.ID, &message.MessageID, &message.Direction, &message.BankNumber, &message.TransactionID, &message.Status, &message.Document, &message.Response, &message.Attempts, &message.Timestamp); err != nil {
+			return []InterbankMessage{}, errors.New("payments.scanInterbankMessages: Could not retrieve messages. " + err.Error())
+		}
+		messages = append(messages, message)
+	}
+	return
+}
+
+func getInboundMessage(bankNumber string, messageID string) (message InterbankMessage, found bool, err error) {
+	rows, err := Config.Db.Query("SELECT "+interbankMessageColumns+" FROM `interbank_messages` WHERE `direction` = ? AND `bankNumber` = ? AND `messageID` = ?", MESSAGE_DIRECTION_IN, bankNumber, messageID)
+	if err != nil {
+		return InterbankMessage{}, false, errors.New("payments.getInboundMessage: " + err.Error())
+	}
+	defer rows.Close()
+
+	messages, err := scanInterbankMessages(rows)
+	if err != nil {
+		return InterbankMessage{}, false, errors.New("payments.getInboundMessage: " + err.Error())
+	}
+	if len(messages) == 0 {
+		return InterbankMessage{}, false, nil
+	}
+
+	return messages[0], true, nil
+}
+
+func getOutboundMessage(transactionID string) (message InterbankMessage, err error) {
+	rows, err := Config.Db.Query("SELECT "+interbankMessageColumns+" FROM `interbank_messages` WHERE `direction` = ? AND `transactionID` = ?", MESSAGE_DIRECTION_OUT, transactionID)
+	if err != nil {
+		return InterbankMessage{}, errors.New("payments.getOutboundMessage: " + err.Error())
+	}
+	defer rows.Close()
+
+	messages, err := scanInterbankMessages(rows)
+	if err != nil {
+		return InterbankMessage{}, errors.New("payments.getOutboundMessage: " + err.Error())
+	}
+	if len(messages) == 0 {
+		return InterbankMessage{}, errors.New("payments.getOutboundMessage: Message not found")
+	}
+
+	return messages[0], nil
+}
+
+func getQueuedMessages() (messages []InterbankMessage, err error) {
+	rows, err := Config.Db.Query("SELECT "+interbankMessageColumns+" FROM `interbank_messages` WHERE `direction` = ? AND `status` = ? ORDER BY `id` ASC", MESSAGE_DIRECTION_OUT, MESSAGE_QUEUED)
+	if err != nil {
+		return []InterbankMessage{}, errors.New("payments.getQueuedMessages: " + err.Error())
+	}
+	defer rows.Close()
+
+	messages, err = scanInterbankMessages(rows)
+	if err != nil {
+		return []InterbankMessage{}, errors.New("payments.getQueuedMessages: " + err.Error())
+	}
+
+	return
+}
+
+// getInterbankMessageForUpdate fetches a single message and locks its row until tx ends
+func getInterbankMessageForUpdate(tx *sql.Tx, id int32) (message InterbankMessage, err error) {
+	rows, err := tx.Query("SELECT "+interbankMessageColumns+" FROM `interbank_messages` WHERE `id` = ? FOR UPDATE", id)
+	if err != nil {
+		return InterbankMessage{}, errors.New("payments.getInterbankMessageForUpdate: " + err.Error())
+	}
+	defer rows.Close()
+
+	messages, err := scanInterbankMessages(rows)
+	if err != nil {
+		return InterbankMessage{}, errors.New("payments.getInterbankMessageForUpdate: " + err.Error())
+	}
+	if len(messages) == 0 {
+		return InterbankMessage{}, errors.New("payments.getInterbankMessageForUpdate: Message not found")
+	}
+
+	return messages[0], nil
+}
+
+// updateInterbankMessage moves a message from fromStatus to toStatus with the response it got
+// or gave. The update fails if the status has changed in the meantime
+func updateInterbankMessage(db ledger.Preparer, id int32, fromStatus string, toStatus string, response string) (err error) {
+	stmtUpd, err := db.Prepare("UPDATE `interbank_messages` SET `status` = ?, `response` = ?, `timestamp` = ? WHERE `id` = ? AND `status` = ?")
+	if err != nil {
+		return errors.New("payments.updateInterbankMessage: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	sqlTime := int32(time.Now().Unix())
+	res, err := stmtUpd.Exec(toStatus, response, sqlTime, id, fromStatus)
+	if err != nil {
+		return errors.New("payments.updateInterbankMessage: " + err.Error())
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errors.New("payments.updateInterbankMessage: Could not get rows affected. " + err.Error())
+	}
+	if affected != 1 {
+		return errors.New("payments.updateInterbankMessage: Message status has changed")
+	}
+
+	return
+}
+
+func incrementMessageAttempts(id int32) (err error) {
+	stmtUpd, err := Config.Db.Prepare("UPDATE `interbank_messages` SET `attempts` = `attempts` + 1 WHERE `id` = ?")
+	if err != nil {
+		return errors.New("payments.incrementMessageAttempts: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	_, err = stmtUpd.Exec(id)
+	if err != nil {
+		return errors.New("payments.incrementMessageAttempts: " + err.Error())
+	}
+
+	return
+}
+
+func saveCardMessage(message CardMessage) (id int64, err error) {
+	insertStatement := "INSERT INTO card_messages (`cardID`, `mti`, `acquirerID`, `stan`, `transmissionTime`, `localTime`, `terminalID`, `cardAcceptorID`, `rrn`, `accountNumber`, `amount`, `holdID`, `transactionID`, `responseCode`, `authorisationCode`, `timestamp`) "
+	insertStatement += "VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	stmtIns, err := Config.Db.Prepare(insertStatement)
+	if err != nil {
+		return 0, errors.New("payments.saveCardMessage: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	cardID := sql.NullInt64{Int64: message.CardID, Valid: message.CardID != 0}
+	holdID := sql.NullInt64{Int64: message.HoldID, Valid: message.HoldID != 0}
+	transactionID := sql.NullInt64{Int64: message.TransactionID, Valid: message.TransactionID != 0}
+	res, err := stmtIns.Exec(cardID, message.MTI, message.AcquirerID, message.STAN, message.TransmissionTime, message.LocalTime, message.TerminalID, message.CardAcceptorID, message.RRN, message.AccountNumber, message.Amount, holdID, transactionID, message.ResponseCode, message.AuthorisationCode, message.Timestamp)
+	if err != nil {
+		return 0, errors.New("payments.saveCardMessage: " + err.Error())
+	}
+
+	id, err = res.LastInsertId()
+	if err != nil {
+		return 0, errors.New("payments.saveCardMessage: Could not get message ID. " + err.Error())
+	}
+
+	return
+}
+
+const cardMessageColumns = "`id`, COALESCE(`cardID`, 0), `mti`, `acquirerID`, `stan`, `transmissionTime`, `localTime`, `terminalID`, `cardAcceptorID`, `rrn`, `accountNumber`, `amount`, COALESCE(`holdID`, 0), COALESCE(`transactionID`, 0), `responseCode`, `authorisationCode`, `timestamp`"
+
+// getCardMessage finds a logged message by its acquirer, type and STAN, and by its
+// transmission time or, when that is empty, its local time
+func getCardMessage(mti string, acquirerID string, stan string, transmissionTime string, localTime string) (message CardMessage, found bool, err error) {
+	query := "SELECT " + cardMessageColumns + " FROM `card_messages` WHERE `mti` = ? AND `acquirerID` = ? AND `stan` = ? AND `transmissionTime` = ?"
+	args := []interface{}{mti, acquirerID, stan, transmissionTime}
+	if transmissionTime == "" {
+		query = "SELECT " + cardMessageColumns + " FROM `card_messages` WHERE `mti` = ? AND `acquirerID` = ? AND `stan` = ? AND `localTime` = ?"
+		args = []interface{}{mti, acquirerID, stan, localTime}
+	}
+
+	rows, err := Config.Db.Query(query, args...)
+	if err != nil {
+		return CardMessage{}, false, errors.New("payments.getCardMessage: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&message.ID, &message.CardID, &message.MTI, &message.AcquirerID, &message.STAN, &message.TransmissionTime, &message.LocalTime, &message.TerminalID, &message.CardAcceptorID, &message.RRN, &message.AccountNumber, &message.Amount, &message.HoldID, &message.TransactionID, &message.ResponseCode, &message.AuthorisationCode, &message.Timestamp)
+		if err != nil {
+			return CardMessage{}, false, errors.New("payments.getCardMessage: Could not retrieve message. " + err.Error())
+		}
+		found = true
+	}
+
+	return
+}
+
+// getCardSpending totals what a card has been approved to pay since a time and not had
+// reversed: its authorisations, and its payments that did not complete one
+func getCardSpending(cardID int64, since int32) (spent money.Money, err error) {
+	query := "SELECT COALESCE(SUM(`amount`), 0) FROM `card_messages` WHERE `cardID` = ? AND `timestamp` >= ? AND `responseCode` = ? AND `reversed` = 0 "
+	query += "AND (SUBSTRING(`mti`, 2, 1) = ? OR (SUBSTRING(`mti`, 2, 1) = ? AND `holdID` IS NULL))"
+	err = Config.Db.QueryRow(query, cardID, since, iso8583.RESPONSE_APPROVED, string(iso8583.CLASS_AUTHORISATION), string(iso8583.CLASS_FINANCIAL)).Scan(&spent)
+	if err != nil {
+		return money.Money{}, errors.New("payments.getCardSpending: " + err.Error())
+	}
+	return
+}
+
+func setCardMessageReversed(id int64) (err error) {
+	stmtUpd, err := Config.Db.Prepare("UPDATE `card_messages` SET `reversed` = 1 WHERE `id` = ?")
+	if err != nil {
+		return errors.New("payments.setCardMessageReversed: " + err.Error())
+	}
+	defer stmtUpd.Close()
+
+	_, err = stmtUpd.Exec(id)
+	if err != nil {
+		return errors.New("payments.setCardMessageReversed: " + err.Error())
+	}
+
+	return
+}
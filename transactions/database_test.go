@@ -2,10 +2,9 @@ package transactions
 
 import (
 	"testing"
-	"time"
 
 	"github.com/bvnk/bank/configuration"
-	"github.com/shopspring/decimal"
+	"github.com/bvnk/bank/money"
 )
 
 func TestLoadConfiguration(t *testing.T) {
@@ -22,12 +21,17 @@ func TestSavePainTransaction(t *testing.T) {
 
 	sender := AccountHolder{"accountNumSender", "bankNumSender"}
 	receiver := AccountHolder{"accountNumReceiver", "bankNumReceiver"}
-	trans := PAINTrans{101, sender, receiver, decimal.NewFromFloat(0.), decimal.NewFromFloat(0.), 10., 10., "Test desc", "approved"}
+	trans := PAINTrans{PainType: 101, Sender: sender, Receiver: receiver, Amount: money.Zero(money.DEFAULT_CURRENCY), Fee: money.Zero(money.DEFAULT_CURRENCY), Desc: "Test desc", Status: STATUS_SETTLED}
 
-	err := savePainTransaction(trans)
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		t.Fatalf("DoSavePainTransaction does not pass. Could not begin transaction. Looking for %v, got %v", nil, err)
+	}
+	_, err = savePainTransaction(tx, trans)
 	if err != nil {
 		t.Errorf("DoSavePainTransaction does not pass. Looking for %v, got %v", nil, err)
 	}
+	tx.Commit()
 
 	err = removePainTransaction(trans)
 	if err != nil {
@@ -42,37 +46,15 @@ func BenchmarkSavePainTransaction(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		sender := AccountHolder{"accountNumSender", "bankNumSender"}
 		receiver := AccountHolder{"accountNumReceiver", "bankNumReceiver"}
-		trans := PAINTrans{101, sender, receiver, decimal.NewFromFloat(0.), decimal.NewFromFloat(0.), 10., 10., "Test desc", "approved"}
+		trans := PAINTrans{PainType: 101, Sender: sender, Receiver: receiver, Amount: money.Zero(money.DEFAULT_CURRENCY), Fee: money.Zero(money.DEFAULT_CURRENCY), Desc: "Test desc", Status: STATUS_SETTLED}
 
-		_ = savePainTransaction(trans)
+		tx, _ := Config.Db.Begin()
+		_, _ = savePainTransaction(tx, trans)
+		_ = tx.Commit()
 		_ = removePainTransaction(trans)
 	}
 }
 
-func TestUpdateHoldingAccount(t *testing.T) {
-	config, _ := configuration.LoadConfig()
-	SetConfig(&config)
-
-	ti := time.Now()
-	sqlTime := int32(ti.Unix())
-
-	err := updateBankHoldingAccount(decimal.NewFromFloat(0.), sqlTime)
-	if err != nil {
-		t.Errorf("DoUpdateHoldingAccount does not pass. Looking for %v, got %v", nil, err)
-	}
-}
-
-func BenchmarkUpdateHoldingAccount(b *testing.B) {
-	config, _ := configuration.LoadConfig()
-	SetConfig(&config)
-
-	for n := 0; n < b.N; n++ {
-		ti := time.Now()
-		sqlTime := int32(ti.Unix())
-		_ = updateBankHoldingAccount(decimal.NewFromFloat(0.), sqlTime)
-	}
-}
-
 // All of the below need active accounts to be run
 // Check balance
 // Deposit
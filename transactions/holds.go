@@ -0,0 +1,492 @@
+package transactions
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bvnk/bank/accounts"
+	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/interest"
+	"github.com/bvnk/bank/money"
+	"github.com/bvnk/bank/push"
+	"github.com/bvnk/bank/statements"
+	"github.com/paulmach/go.geo"
+	"github.com/shopspring/decimal"
+)
+
+/*
+Holds reserve funds on an account before a payment is captured, as card and merchant flows
+authorise an amount first and settle it later. A hold only lowers the available balance, the
+balance moves when the hold is captured
+
+Lifecycle:
+pain~1004 - the account holder places a hold in favour of a payee, status active
+pain~1005 - the payee captures all or part of the hold as a payment, status captured. Any
+            amount not captured is released. The payment is saved as PAIN type
+            PAIN_HOLD_CAPTURE, which the account holder cannot reverse with pain~7
+pain~1006 - the payee cancels the hold, status cancelled
+pain~1008 - holds past their expiry are released, status expired (bank operators only)
+
+pain~1007 lists the active holds on an account.
+*/
+
+// PAIN type captured holds are saved as
+const PAIN_HOLD_CAPTURE = 1005
+
+const (
+	HOLD_ACTIVE    = "active"
+	HOLD_CAPTURED  = "captured"
+	HOLD_CANCELLED = "cancelled"
+	HOLD_EXPIRED   = "expired"
+)
+
+// Hours a hold lasts when no expiry is given, and the longest it can last
+const (
+	HOLD_DEFAULT_EXPIRY = 7 * 24
+	HOLD_MAX_EXPIRY     = 30 * 24
+)
+
+type Hold struct {
+	ID             int64
+	Account        AccountHolder
+	Payee          AccountHolder
+	Amount         money.Money
+	CapturedAmount money.Money
+	Status         string
+	Desc           string
+	Expires        int32
+	Timestamp      int32
+}
+
+func holdPlacement(data []string) (result string, err error) {
+	// Validate input
+	account, err := parseAccountHolder(data[3])
+	if err != nil {
+		return "", errors.New("payments.holdPlacement: " + err.Error())
+	}
+	payee, err := parseAccountHolder(data[4])
+	if err != nil {
+		return "", errors.New("payments.holdPlacement: " + err.Error())
+	}
+	account.BankNumber = localBankNumber(account.BankNumber)
+	payee.BankNumber = localBankNumber(payee.BankNumber)
+	if account.BankNumber != "" || payee.BankNumber != "" {
+		return "", errors.New("payments.holdPlacement: Holds are only supported between accounts at this bank")
+	}
+	if account.AccountNumber == payee.AccountNumber {
+		return "", errors.New("payments.holdPlacement: Account and payee must differ")
+	}
+
+	err = checkAmount(data[5])
+	if err != nil {
+		return "", errors.New("payments.holdPlacement: " + err.Error())
+	}
+
+	expiryHours, err := parseHoldExpiry(data[6])
+	if err != nil {
+		return "", errors.New("payments.holdPlacement: " + err.Error())
+	}
+	desc := data[7]
+
+	// Only the account holder can reserve their funds
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("payments.holdPlacement: " + err.Error())
+	}
+	err = accounts.CheckUserAccountValidFromToken(tokenUser, account.AccountNumber)
+	if err != nil {
+		return "", errors.New("payments.holdPlacement: Account not valid")
+	}
+
+	// Holds are in the currency of the account they reserve funds on
+	currency, err := getAccountCurrency(Config.Db, account.AccountNumber)
+	if err != nil {
+		return "", errors.New("payments.holdPlacement: " + err.Error())
+	}
+	amount, err := money.NewFromString(strings.TrimSpace(data[5]), currency)
+	if err != nil {
+		return "", errors.New("payments.holdPlacement: Could not convert amount. " + err.Error())
+	}
+	if amount.Sign() <= 0 {
+		return "", errors.New("payments.holdPlacement: Amount must be positive")
+	}
+
+	now := time.Now()
+	hold := Hold{
+		Account: account,
+		Payee:   payee,
+		Amount:  amount,
+		Status:  HOLD_ACTIVE,
+		Desc:    desc,
+		Expires: int32(now.Add(time.Duration(expiryHours) * time.Hour).Unix()),
+	}
+
+	holdID, _, err := placeHold(hold)
+	if err != nil {
+		return "", errors.New("payments.holdPlacement: " + err.Error())
+	}
+
+	go push.SendNotification(account.AccountNumber, "🔒 Funds on hold", 1, "default")
+
+	return strconv.FormatInt(holdID, 10), nil
+}
+
+// placeHold reserves the hold's amount on the account. When the hold cannot be placed the
+// reason code says why (ISO 20022 ExternalStatusReason1Code)
+func placeHold(hold Hold) (holdID int64, reasonCode string, err error) {
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return 0, "", errors.New("payments.placeHold: Could not start database transaction. " + err.Error())
+	}
+
+	// Locks both accounts until the hold is saved
+	closed, err := checkAccountsClosed(tx, PAINTrans{Sender: hold.Account, Receiver: hold.Payee})
+	if err != nil {
+		tx.Rollback()
+		return 0, "", errors.New("payments.placeHold: " + err.Error())
+	}
+	if closed {
+		tx.Rollback()
+		return 0, REASON_CLOSED_ACCOUNT, errors.New("payments.placeHold: Account closed")
+	}
+
+	restricted, err := checkSenderRestricted(tx, hold.Account)
+	if err != nil {
+		tx.Rollback()
+		return 0, "", errors.New("payments.placeHold: " + err.Error())
+	}
+	if restricted {
+		tx.Rollback()
+		return 0, REASON_TRANSACTION_FORBIDDEN, errors.New("payments.placeHold: Account holder not verified")
+	}
+
+	mismatch, err := checkCurrencies(tx, PAINTrans{Sender: hold.Account, Receiver: hold.Payee, Amount: hold.Amount})
+	if err != nil {
+		tx.Rollback()
+		return 0, "", errors.New("payments.placeHold: " + err.Error())
+	}
+	if mismatch {
+		tx.Rollback()
+		return 0, REASON_INVALID_CURRENCY, errors.New("payments.placeHold: Accounts do not hold " + hold.Amount.Currency + ", amounts must be converted first")
+	}
+
+	balanceAvailable, err := checkBalance(tx, hold.Account)
+	if err != nil {
+		tx.Rollback()
+		return 0, "", errors.New("payments.placeHold: " + err.Error())
+	}
+	if balanceAvailable.Cmp(hold.Amount) == -1 {
+		tx.Rollback()
+		return 0, REASON_INSUFFICIENT_FUNDS, errors.New("payments.placeHold: Insufficient funds available")
+	}
+
+	hold.Timestamp = int32(time.Now().Unix())
+	err = reserveHoldFunds(tx, hold.Account, hold.Amount, hold.Timestamp)
+	if err != nil {
+		tx.Rollback()
+		return 0, "", errors.New("payments.placeHold: " + err.Error())
+	}
+
+	holdID, err = saveHold(tx, hold)
+	if err != nil {
+		tx.Rollback()
+		return 0, "", errors.New("payments.placeHold: " + err.Error())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, "", errors.New("payments.placeHold: Could not commit transaction. " + err.Error())
+	}
+
+	return
+}
+
+func holdCapture(data []string) (result string, err error) {
+	holdID, err := strconv.ParseInt(data[3], 10, 64)
+	if err != nil {
+		return "", errors.New("payments.holdCapture: Could not parse hold ID. " + err.Error())
+	}
+
+	// No amount captures the whole hold
+	captureAmount := ""
+	if len(data) > 4 {
+		captureAmount = strings.TrimSpace(data[4])
+	}
+	if captureAmount != "" {
+		err = checkAmount(captureAmount)
+		if err != nil {
+			return "", errors.New("payments.holdCapture: " + err.Error())
+		}
+	}
+
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("payments.holdCapture: " + err.Error())
+	}
+
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return "", errors.New("payments.holdCapture: Could not start database transaction. " + err.Error())
+	}
+
+	// Lock the hold so it cannot be captured twice concurrently
+	hold, err := getHoldForUpdate(tx, holdID)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.holdCapture: " + err.Error())
+	}
+
+	// Only the payee can capture
+	err = accounts.CheckUserAccountValidFromToken(tokenUser, hold.Payee.AccountNumber)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.holdCapture: Payee not valid")
+	}
+
+	// The amount is in the currency of the hold
+	amount := hold.Amount
+	if captureAmount != "" {
+		amount, err = money.NewFromString(captureAmount, hold.Amount.Currency)
+		if err != nil {
+			tx.Rollback()
+			return "", errors.New("payments.holdCapture: Could not convert amount. " + err.Error())
+		}
+		if amount.Sign() <= 0 {
+			tx.Rollback()
+			return "", errors.New("payments.holdCapture: Amount must be positive")
+		}
+	}
+	err = checkHoldCapture(hold, amount, time.Now())
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.holdCapture: " + err.Error())
+	}
+
+	result, _, err = captureHold(tx, hold, amount)
+	if err != nil {
+		return "", errors.New("payments.holdCapture: " + err.Error())
+	}
+
+	go statements.PushNotification(hold.Account.AccountNumber, result, "💸 Payment sent!")
+	go statements.PushNotification(hold.Payee.AccountNumber, result, "💸 Payment received!")
+
+	return
+}
+
+// captureHold releases the hold and posts amount of it as a payment to the payee inside tx,
+// which the caller has begun with the hold locked. tx is committed on success and rolled back
+// on any failure, leaving the hold active
+func captureHold(tx *sql.Tx, hold Hold, amount money.Money) (result string, reasonCode string, err error) {
+	sqlTime := int32(time.Now().Unix())
+
+	// The funds come back to the available balance, the payment then takes what is captured
+	err = releaseHoldFunds(tx, hold.Account, hold.Amount, sqlTime)
+	if err != nil {
+		tx.Rollback()
+		return "", "", errors.New("payments.captureHold: " + err.Error())
+	}
+
+	err = updateHoldStatus(tx, hold.ID, HOLD_ACTIVE, HOLD_CAPTURED, amount)
+	if err != nil {
+		tx.Rollback()
+		return "", "", errors.New("payments.captureHold: " + err.Error())
+	}
+
+	transaction := PAINTrans{0, PAIN_HOLD_CAPTURE, hold.Account, hold.Payee, amount, money.Zero(amount.Currency), *geo.NewPoint(0, 0), hold.Desc, STATUS_RECEIVED, 0, 0, "", nil, hold.ID, decimal.Zero, money.Money{}}
+
+	err = assessFees(tx, &transaction)
+	if err != nil {
+		tx.Rollback()
+		return "", "", errors.New("payments.captureHold: " + err.Error())
+	}
+
+	// The fees and any penalty are not part of the hold, so they need funds of their own
+	balanceAvailable, err := checkBalance(tx, transaction.Sender)
+	if err != nil {
+		tx.Rollback()
+		return "", "", errors.New("payments.captureHold: " + err.Error())
+	}
+	penalty, err := interest.EarlyWithdrawalPenalty(tx, transaction.Sender.AccountNumber, transaction.Amount, time.Now())
+	if err != nil {
+		tx.Rollback()
+		return "", "", errors.New("payments.captureHold: " + err.Error())
+	}
+	if balanceAvailable.Cmp(transaction.Amount.Add(transaction.Fee).Add(penalty)) == -1 {
+		tx.Rollback()
+		return "", REASON_INSUFFICIENT_FUNDS, errors.New("payments.captureHold: Insufficient funds available for the fees")
+	}
+
+	err = interest.ChargeEarlyWithdrawalPenalty(tx, transaction.Sender.AccountNumber, penalty, sqlTime)
+	if err != nil {
+		tx.Rollback()
+		return "", "", errors.New("payments.captureHold: " + err.Error())
+	}
+
+	result, err = processPAINTransaction(tx, transaction)
+	if err != nil {
+		return "", "", errors.New("payments.captureHold: " + err.Error())
+	}
+
+	return
+}
+
+func holdCancellation(data []string) (result string, err error) {
+	holdID, err := strconv.ParseInt(data[3], 10, 64)
+	if err != nil {
+		return "", errors.New("payments.holdCancellation: Could not parse hold ID. " + err.Error())
+	}
+
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("payments.holdCancellation: " + err.Error())
+	}
+
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return "", errors.New("payments.holdCancellation: Could not start database transaction. " + err.Error())
+	}
+
+	hold, err := getHoldForUpdate(tx, holdID)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.holdCancellation: " + err.Error())
+	}
+
+	// Only the payee can cancel, the account holder waits for the hold to expire
+	err = accounts.CheckUserAccountValidFromToken(tokenUser, hold.Payee.AccountNumber)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.holdCancellation: Payee not valid")
+	}
+
+	err = releaseHold(tx, hold, HOLD_CANCELLED)
+	if err != nil {
+		return "", errors.New("payments.holdCancellation: " + err.Error())
+	}
+
+	go push.SendNotification(hold.Account.AccountNumber, "🔓 Hold released", 1, "default")
+
+	return strconv.FormatInt(holdID, 10), nil
+}
+
+// releaseHold gives the held funds back to the available balance and closes the hold with
+// status. tx is committed on success and rolled back on any failure
+func releaseHold(tx *sql.Tx, hold Hold, status string) (err error) {
+	if hold.Status != HOLD_ACTIVE {
+		tx.Rollback()
+		return errors.New("payments.releaseHold: Hold is not active, status is " + hold.Status)
+	}
+
+	err = releaseHoldFunds(tx, hold.Account, hold.Amount, int32(time.Now().Unix()))
+	if err != nil {
+		tx.Rollback()
+		return errors.New("payments.releaseHold: " + err.Error())
+	}
+
+	err = updateHoldStatus(tx, hold.ID, HOLD_ACTIVE, status, money.Zero(hold.Amount.Currency))
+	if err != nil {
+		tx.Rollback()
+		return errors.New("payments.releaseHold: " + err.Error())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("payments.releaseHold: Could not commit transaction. " + err.Error())
+	}
+
+	return
+}
+
+// releaseExpiredHolds releases every active hold past its expiry, one database transaction per
+// hold so a failure leaves the others released
+func releaseExpiredHolds() (released []int64, err error) {
+	now := time.Now()
+	holdIDs, err := getExpiredHoldIDs(int32(now.Unix()))
+	if err != nil {
+		return nil, errors.New("payments.releaseExpiredHolds: " + err.Error())
+	}
+
+	released = []int64{}
+	for _, holdID := range holdIDs {
+		tx, err := Config.Db.Begin()
+		if err != nil {
+			return released, errors.New("payments.releaseExpiredHolds: Could not start database transaction. " + err.Error())
+		}
+
+		// The hold may have been captured or cancelled since it was listed
+		hold, err := getHoldForUpdate(tx, holdID)
+		if err != nil {
+			tx.Rollback()
+			return released, errors.New("payments.releaseExpiredHolds: " + err.Error())
+		}
+		if hold.Status != HOLD_ACTIVE || !holdExpired(hold, now) {
+			tx.Rollback()
+			continue
+		}
+
+		err = releaseHold(tx, hold, HOLD_EXPIRED)
+		if err != nil {
+			return released, errors.New("payments.releaseExpiredHolds: " + err.Error())
+		}
+		released = append(released, holdID)
+	}
+
+	return
+}
+
+func listHolds(data []string) (result interface{}, err error) {
+	accountNumber := data[3]
+
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("payments.listHolds: " + err.Error())
+	}
+	err = accounts.CheckUserAccountValidFromToken(tokenUser, accountNumber)
+	if err != nil {
+		return "", errors.New("payments.listHolds: Account not valid")
+	}
+
+	result, err = getActiveHolds(accountNumber)
+	if err != nil {
+		return "", errors.New("payments.listHolds: " + err.Error())
+	}
+
+	return
+}
+
+// parseHoldExpiry reads the hours a hold lasts, HOLD_DEFAULT_EXPIRY when empty
+func parseHoldExpiry(value string) (hours int, err error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return HOLD_DEFAULT_EXPIRY, nil
+	}
+	hours, err = strconv.Atoi(value)
+	if err != nil || hours <= 0 || hours > HOLD_MAX_EXPIRY {
+		return 0, errors.New("payments.parseHoldExpiry: Expiry must be between 1 and " + strconv.Itoa(HOLD_MAX_EXPIRY) + " hours")
+	}
+	return
+}
+
+// checkHoldCapture checks a capture of amount at now against the hold's status, expiry and amount
+func checkHoldCapture(hold Hold, amount money.Money, now time.Time) (err error) {
+	if hold.Status != HOLD_ACTIVE {
+		return errors.New("payments.checkHoldCapture: Hold is not active, status is " + hold.Status)
+	}
+	if holdExpired(hold, now) {
+		return errors.New("payments.checkHoldCapture: Hold has expired")
+	}
+	if !amount.SameCurrency(hold.Amount) {
+		return errors.New("payments.checkHoldCapture: Currency does not match hold")
+	}
+	if amount.Cmp(hold.Amount) == 1 {
+		return errors.New("payments.checkHoldCapture: Amount exceeds hold of " + hold.Amount.String())
+	}
+	return
+}
+
+func holdExpired(hold Hold, now time.Time) bool {
+	return now.Unix() >= int64(hold.Expires)
+}
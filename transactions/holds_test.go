@@ -0,0 +1,96 @@
+package transactions
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+func TestProcessPAINHolds(t *testing.T) {
+	for _, painType := range []string{"1004", "1005", "1006", "1007"} {
+		data := []string{"", "", painType}
+		_, err := ProcessPAIN(data)
+		if err == nil {
+			t.Errorf("ProcessPAIN PainType%v does not pass. Looking for %v, got %v", painType, "Not all data is present", nil)
+		}
+	}
+
+	// Each request fails validation before the token is checked
+	invalid := map[string]struct {
+		data     []string
+		expected string
+	}{
+		"account":        {[]string{"", "", "1004", "accountNumber", "payee@", "10", "", "desc"}, "Not all details present"},
+		"remote payee":   {[]string{"", "", "1004", "accountNumber@", "payee@other-bank", "10", "", "desc"}, "Holds are only supported between accounts at this bank"},
+		"same account":   {[]string{"", "", "1004", "accountNumber@", "accountNumber@", "10", "", "desc"}, "Account and payee must differ"},
+		"amount":         {[]string{"", "", "1004", "accountNumber@", "payee@", "ten", "", "desc"}, "Could not convert amount"},
+		"negative":       {[]string{"", "", "1004", "accountNumber@", "payee@", "-10", "", "desc"}, "Amount must be positive"},
+		"expiry":         {[]string{"", "", "1004", "accountNumber@", "payee@", "10", "1000", "desc"}, "Expiry must be between 1 and 720 hours"},
+		"capture id":     {[]string{"", "", "1005", "one", "10"}, "Could not parse hold ID"},
+		"capture amount": {[]string{"", "", "1005", "1", "-10"}, "Amount must be positive"},
+		"cancel id":      {[]string{"", "", "1006", "one"}, "Could not parse hold ID"},
+	}
+	for name, tst := range invalid {
+		_, err := ProcessPAIN(tst.data)
+		if err == nil || !strings.Contains(err.Error(), tst.expected) {
+			t.Errorf("ProcessPAIN holds %v does not pass. Looking for %v, got %v", name, tst.expected, err)
+		}
+	}
+}
+
+func TestParseHoldExpiry(t *testing.T) {
+	hours, err := parseHoldExpiry("")
+	if err != nil || hours != HOLD_DEFAULT_EXPIRY {
+		t.Errorf("ParseHoldExpiry default does not pass. Looking for %v, got %v %v", HOLD_DEFAULT_EXPIRY, hours, err)
+	}
+
+	hours, err = parseHoldExpiry("48")
+	if err != nil || hours != 48 {
+		t.Errorf("ParseHoldExpiry does not pass. Looking for %v, got %v %v", 48, hours, err)
+	}
+
+	for _, value := range []string{"0", "721", "soon"} {
+		_, err = parseHoldExpiry(value)
+		if err == nil {
+			t.Errorf("ParseHoldExpiry %v does not pass. Looking for %v, got %v", value, "Expiry must be between 1 and 720 hours", nil)
+		}
+	}
+}
+
+func TestCheckHoldCapture(t *testing.T) {
+	now := time.Date(2016, 3, 15, 12, 0, 0, 0, time.UTC)
+	hold := Hold{
+		Amount:  money.New(decimal.New(50, 0), money.DEFAULT_CURRENCY),
+		Status:  HOLD_ACTIVE,
+		Expires: int32(now.Add(time.Hour).Unix()),
+	}
+
+	err := checkHoldCapture(hold, money.New(decimal.New(50, 0), money.DEFAULT_CURRENCY), now)
+	if err != nil {
+		t.Errorf("CheckHoldCapture full does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	err = checkHoldCapture(hold, money.New(decimal.New(2050, -2), money.DEFAULT_CURRENCY), now)
+	if err != nil {
+		t.Errorf("CheckHoldCapture partial does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	err = checkHoldCapture(hold, money.New(decimal.New(5001, -2), money.DEFAULT_CURRENCY), now)
+	if err == nil {
+		t.Errorf("CheckHoldCapture amount does not pass. Looking for %v, got %v", "Amount exceeds hold", nil)
+	}
+
+	err = checkHoldCapture(hold, money.New(decimal.New(50, 0), money.DEFAULT_CURRENCY), now.Add(time.Hour))
+	if err == nil {
+		t.Errorf("CheckHoldCapture expired does not pass. Looking for %v, got %v", "Hold has expired", nil)
+	}
+
+	hold.Status = HOLD_CANCELLED
+	err = checkHoldCapture(hold, money.New(decimal.New(50, 0), money.DEFAULT_CURRENCY), now)
+	if err == nil {
+		t.Errorf("CheckHoldCapture status does not pass. Looking for %v, got %v", "Hold is not active", nil)
+	}
+}
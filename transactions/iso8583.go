@@ -0,0 +1,594 @@
+package transactions
+
+/*
+ISO 8583 card messages from acquirers, received on the card port and mapped onto holds and
+payments. Both the 1987 (0xxx) and 1993 (1xxx) versions are answered in the version they
+arrive in
+
+0100/1100 authorisation - places a hold on the cardholder's account for the card acceptor
+0200/1200 financial - captures the authorisation in the original data elements (field 90,
+          or 56 in 1993) or, without one, pays the card acceptor straight away
+0220/1220 financial advice - as financial, for transactions the acquirer already approved
+0400/1400 reversal, 0420/1420 reversal advice - cancels the hold of an authorisation or
+          reverses the payment of a financial message named in the original data elements
+0800/1804 network management - sign on, sign off and echo
+
+The card is identified by its PAN (field 2) and expiry (field 14), and pays from the account
+it was issued on. Authorisations and payments without one are declined when the card is not
+active or they take it over its per transaction or daily limit; completions of an
+authorisation are not checked again. The card acceptor (field 42) is paid into the merchant
+account Config.CardAcceptors maps it to. Amounts (field 4) are in minor units of the currency
+in field 49 (ISO 4217 numeric), and cards are declined in any currency but their account's
+
+Payments are saved as PAIN type PAIN_CARD_PAYMENT, or PAIN_HOLD_CAPTURE when they complete an
+authorisation. The cardholder cannot reverse either with pain~7, only the acquirer can
+
+Every request but network management is logged in card_messages with its response. A repeat
+of a request already answered gets the same response without being processed again.
+*/
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bvnk/bank/cards"
+	"github.com/bvnk/bank/iso8583"
+	"github.com/bvnk/bank/money"
+	"github.com/paulmach/go.geo"
+	"github.com/shopspring/decimal"
+)
+
+// PAIN type card payments without an authorisation are saved as
+const PAIN_CARD_PAYMENT = 1010
+
+// Network management codes: field 70 in 1987, field 24 in 1993
+var networkManagementCodes = map[byte]map[string]bool{
+	iso8583.VERSION_1987: {"001": true, "002": true, "301": true},
+	iso8583.VERSION_1993: {"801": true, "802": true, "831": true},
+}
+
+// Response codes for the reasons a hold or payment is rejected
+// (ISO 20022 ExternalStatusReason1Code)
+var cardResponseCodes = map[string]string{
+	REASON_INCORRECT_ACCOUNT:     iso8583.RESPONSE_INVALID_CARD,
+	REASON_CLOSED_ACCOUNT:        iso8583.RESPONSE_RESTRICTED_CARD,
+	REASON_TRANSACTION_FORBIDDEN: iso8583.RESPONSE_NOT_PERMITTED,
+	REASON_INSUFFICIENT_FUNDS:    iso8583.RESPONSE_INSUFFICIENT_FUNDS,
+	REASON_INVALID_CURRENCY:      iso8583.RESPONSE_NOT_PERMITTED,
+	REASON_INVALID_AMOUNT:        iso8583.RESPONSE_INVALID_AMOUNT,
+}
+
+// Response codes for the reasons a card is declined
+var cardDeclineCodes = map[string]string{
+	cards.DECLINE_INACTIVE:          iso8583.RESPONSE_RESTRICTED_CARD,
+	cards.DECLINE_FROZEN:            iso8583.RESPONSE_RESTRICTED_CARD,
+	cards.DECLINE_CANCELLED:         iso8583.RESPONSE_INVALID_CARD,
+	cards.DECLINE_EXPIRED:           iso8583.RESPONSE_EXPIRED_CARD,
+	cards.DECLINE_EXPIRY_MISMATCH:   iso8583.RESPONSE_EXPIRED_CARD,
+	cards.DECLINE_TRANSACTION_LIMIT: iso8583.RESPONSE_EXCEEDS_LIMIT,
+	cards.DECLINE_DAILY_LIMIT:       iso8583.RESPONSE_EXCEEDS_LIMIT,
+}
+
+// CardMessage is a logged card request and the response it got. Response codes are kept as
+// 1987 codes whatever the version of the message
+type CardMessage struct {
+	ID                int64
+	CardID            int64
+	MTI               string
+	AcquirerID        string
+	STAN              string
+	TransmissionTime  string
+	LocalTime         string
+	TerminalID        string
+	CardAcceptorID    string
+	RRN               string
+	AccountNumber     string
+	Amount            money.Money
+	HoldID            int64
+	TransactionID     int64
+	ResponseCode      string
+	AuthorisationCode string
+	Timestamp         int32
+}
+
+// ProcessISO8583 answers a card request. The response is always sent back; err says why a
+// request was declined or could not be logged, for the card server's log
+func ProcessISO8583(request iso8583.Message) (response iso8583.Message, err error) {
+	response = request.Response()
+
+	if request.Function() != iso8583.FUNCTION_REQUEST && request.Function() != iso8583.FUNCTION_ADVICE {
+		response.SetResponseCode(iso8583.RESPONSE_INVALID_TRANSACTION)
+		return response, errors.New("payments.ProcessISO8583: Message type " + request.MTI + " not supported")
+	}
+	if request.Class() == iso8583.CLASS_NETWORK {
+		code, err := networkManagement(request)
+		response.SetResponseCode(code)
+		return response, err
+	}
+
+	message, err := cardMessageFromRequest(request)
+	if err != nil {
+		response.SetResponseCode(iso8583.RESPONSE_FORMAT_ERROR)
+		return response, errors.New("payments.ProcessISO8583: " + err.Error())
+	}
+
+	// Repeats, and requests sent again after a lost response, get the answer already given
+	previous, found, err := getCardMessage(message.MTI, message.AcquirerID, message.STAN, message.TransmissionTime, "")
+	if err != nil {
+		response.SetResponseCode(iso8583.RESPONSE_SYSTEM_ERROR)
+		return response, errors.New("payments.ProcessISO8583: " + err.Error())
+	}
+	if found {
+		cardResponse(&response, previous)
+		return response, nil
+	}
+
+	var processErr error
+	switch request.Class() {
+	case iso8583.CLASS_AUTHORISATION:
+		processErr = cardAuthorisation(request, &message)
+	case iso8583.CLASS_FINANCIAL:
+		processErr = cardFinancial(request, &message)
+	case iso8583.CLASS_REVERSAL:
+		processErr = cardReversal(request, &message)
+	default:
+		message.ResponseCode = iso8583.RESPONSE_INVALID_TRANSACTION
+		processErr = errors.New("Message type " + request.MTI + " not supported")
+	}
+	cardResponse(&response, message)
+
+	_, err = saveCardMessage(message)
+	if err != nil {
+		return response, errors.New("payments.ProcessISO8583: " + err.Error())
+	}
+	if processErr != nil {
+		return response, errors.New("payments.ProcessISO8583: " + processErr.Error())
+	}
+
+	return
+}
+
+func networkManagement(request iso8583.Message) (code string, err error) {
+	field := iso8583.FIELD_NETWORK_CODE
+	if request.Version() == iso8583.VERSION_1993 {
+		field = iso8583.FIELD_FUNCTION_CODE
+	}
+	if !request.Has(field) {
+		return iso8583.RESPONSE_FORMAT_ERROR, errors.New("payments.networkManagement: Network management code missing")
+	}
+	if !networkManagementCodes[request.Version()][request.Get(field)] {
+		return iso8583.RESPONSE_INVALID_TRANSACTION, errors.New("payments.networkManagement: Network management code " + request.Get(field) + " not supported")
+	}
+	return iso8583.RESPONSE_APPROVED, nil
+}
+
+// cardMessageFromRequest checks the fields a request must carry and reads them into its log entry
+func cardMessageFromRequest(request iso8583.Message) (message CardMessage, err error) {
+	required := []int{iso8583.FIELD_TRANSMISSION_TIME, iso8583.FIELD_STAN}
+	if request.Class() != iso8583.CLASS_REVERSAL {
+		required = append(required, iso8583.FIELD_PAN, iso8583.FIELD_PROCESSING_CODE, iso8583.FIELD_AMOUNT, iso8583.FIELD_CURRENCY, iso8583.FIELD_EXPIRY, iso8583.FIELD_CARD_ACCEPTOR_ID)
+	}
+	for _, field := range required {
+		if !request.Has(field) {
+			return CardMessage{}, errors.New("payments.cardMessageFromRequest: Field " + strconv.Itoa(field) + " missing")
+		}
+	}
+
+	message = CardMessage{
+		MTI:              requestMTI(request.MTI),
+		AcquirerID:       normaliseAcquirerID(request.Get(iso8583.FIELD_ACQUIRER_ID)),
+		STAN:             request.Get(iso8583.FIELD_STAN),
+		TransmissionTime: request.Get(iso8583.FIELD_TRANSMISSION_TIME),
+		LocalTime:        request.Get(iso8583.FIELD_LOCAL_TIME),
+		TerminalID:       strings.TrimSpace(request.Get(iso8583.FIELD_TERMINAL_ID)),
+		CardAcceptorID:   strings.TrimSpace(request.Get(iso8583.FIELD_CARD_ACCEPTOR_ID)),
+		RRN:              strings.TrimSpace(request.Get(iso8583.FIELD_RRN)),
+		Amount:           money.Zero(money.DEFAULT_CURRENCY),
+		Timestamp:        int32(time.Now().Unix()),
+	}
+
+	if request.Has(iso8583.FIELD_AMOUNT) {
+		message.Amount, err = cardAmount(request)
+		if err != nil {
+			return CardMessage{}, errors.New("payments.cardMessageFromRequest: " + err.Error())
+		}
+	}
+
+	return
+}
+
+func cardAuthorisation(request iso8583.Message, message *CardMessage) (err error) {
+	payee, code, err := cardPayee(request, *message)
+	if err != nil {
+		message.ResponseCode = code
+		return errors.New("payments.cardAuthorisation: " + err.Error())
+	}
+	account, code, err := authoriseCard(request, message)
+	if err != nil {
+		message.ResponseCode = code
+		return errors.New("payments.cardAuthorisation: " + err.Error())
+	}
+
+	hold := Hold{
+		Account: account,
+		Payee:   payee,
+		Amount:  message.Amount,
+		Status:  HOLD_ACTIVE,
+		Desc:    cardDescription(request),
+		Expires: int32(time.Now().Add(HOLD_DEFAULT_EXPIRY * time.Hour).Unix()),
+	}
+	holdID, reasonCode, err := placeHold(hold)
+	if err != nil {
+		message.ResponseCode = cardResponseCode(reasonCode)
+		return errors.New("payments.cardAuthorisation: " + err.Error())
+	}
+
+	message.HoldID = holdID
+	message.ResponseCode = iso8583.RESPONSE_APPROVED
+	message.AuthorisationCode = authorisationCode(holdID)
+	return
+}
+
+func cardFinancial(request iso8583.Message, message *CardMessage) (err error) {
+	payee, code, err := cardPayee(request, *message)
+	if err != nil {
+		message.ResponseCode = code
+		return errors.New("payments.cardFinancial: " + err.Error())
+	}
+
+	var result string
+	if hasOriginalData(request) {
+		// Completion of an earlier authorisation, on the card it authorised
+		original, code, err := originalCardMessage(request, iso8583.CLASS_AUTHORISATION)
+		if err != nil {
+			message.ResponseCode = code
+			return errors.New("payments.cardFinancial: " + err.Error())
+		}
+		card, found, err := cards.GetCardByPAN(request.Get(iso8583.FIELD_PAN))
+		if err != nil {
+			message.ResponseCode = iso8583.RESPONSE_SYSTEM_ERROR
+			return errors.New("payments.cardFinancial: " + err.Error())
+		}
+		if !found || card.ID != original.CardID {
+			message.ResponseCode = iso8583.RESPONSE_INVALID_TRANSACTION
+			return errors.New("payments.cardFinancial: Card does not match authorisation")
+		}
+		message.CardID = card.ID
+		message.AccountNumber = card.AccountNumber
+		message.HoldID = original.HoldID
+		result, code, err = captureCardHold(original.HoldID, payee, message.Amount)
+		if err != nil {
+			message.ResponseCode = code
+			return errors.New("payments.cardFinancial: " + err.Error())
+		}
+	} else {
+		account, code, err := authoriseCard(request, message)
+		if err != nil {
+			message.ResponseCode = code
+			return errors.New("payments.cardFinancial: " + err.Error())
+		}
+		transaction := PAINTrans{0, PAIN_CARD_PAYMENT, account, payee, message.Amount, money.Zero(message.Amount.Currency), *geo.NewPoint(0, 0), cardDescription(request), STATUS_RECEIVED, 0, 0, "", nil, 0, decimal.Zero, money.Money{}}
+		var reasonCode string
+		result, reasonCode, err = initiateCreditTransfer(transaction)
+		if err != nil {
+			message.ResponseCode = cardResponseCode(reasonCode)
+			return errors.New("payments.cardFinancial: " + err.Error())
+		}
+	}
+
+	message.TransactionID, err = strconv.ParseInt(result, 10, 64)
+	if err != nil {
+		message.ResponseCode = iso8583.RESPONSE_SYSTEM_ERROR
+		return errors.New("payments.cardFinancial: Could not parse transaction ID. " + err.Error())
+	}
+	message.ResponseCode = iso8583.RESPONSE_APPROVED
+	message.AuthorisationCode = authorisationCode(message.TransactionID)
+	return
+}
+
+// captureCardHold captures amount of an authorisation's hold for the card acceptor's account
+func captureCardHold(holdID int64, payee AccountHolder, amount money.Money) (result string, code string, err error) {
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return "", iso8583.RESPONSE_SYSTEM_ERROR, errors.New("payments.captureCardHold: Could not start database transaction. " + err.Error())
+	}
+
+	hold, err := getHoldForUpdate(tx, holdID)
+	if err != nil {
+		tx.Rollback()
+		return "", iso8583.RESPONSE_NO_ORIGINAL, errors.New("payments.captureCardHold: " + err.Error())
+	}
+	if hold.Payee.AccountNumber != payee.AccountNumber {
+		tx.Rollback()
+		return "", iso8583.RESPONSE_INVALID_TRANSACTION, errors.New("payments.captureCardHold: Card acceptor does not match authorisation")
+	}
+	err = checkHoldCapture(hold, amount, time.Now())
+	if err != nil {
+		tx.Rollback()
+		if amount.Cmp(hold.Amount) == 1 {
+			return "", iso8583.RESPONSE_INVALID_AMOUNT, errors.New("payments.captureCardHold: " + err.Error())
+		}
+		return "", iso8583.RESPONSE_DO_NOT_HONOUR, errors.New("payments.captureCardHold: " + err.Error())
+	}
+
+	result, reasonCode, err := captureHold(tx, hold, amount)
+	if err != nil {
+		return "", cardResponseCode(reasonCode), errors.New("payments.captureCardHold: " + err.Error())
+	}
+
+	return result, iso8583.RESPONSE_APPROVED, nil
+}
+
+func cardReversal(request iso8583.Message, message *CardMessage) (err error) {
+	original, code, err := originalCardMessage(request, 0)
+	if err != nil {
+		message.ResponseCode = code
+		return errors.New("payments.cardReversal: " + err.Error())
+	}
+	if original.MTI[1] == iso8583.CLASS_REVERSAL {
+		message.ResponseCode = iso8583.RESPONSE_INVALID_TRANSACTION
+		return errors.New("payments.cardReversal: Reversals cannot be reversed")
+	}
+	message.CardID = original.CardID
+	message.AccountNumber = original.AccountNumber
+	message.CardAcceptorID = original.CardAcceptorID
+	message.Amount = original.Amount
+
+	switch {
+	case original.ResponseCode != iso8583.RESPONSE_APPROVED:
+		// Nothing was reserved or paid, so there is nothing to undo
+		message.ResponseCode = iso8583.RESPONSE_APPROVED
+		return
+	case original.TransactionID != 0:
+		var transactionID int64
+		transactionID, message.ResponseCode, err = reverseCardPayment(original.TransactionID)
+		message.TransactionID = transactionID
+	case original.HoldID != 0:
+		message.HoldID = original.HoldID
+		message.ResponseCode, err = cancelCardHold(original.HoldID)
+	default:
+		message.ResponseCode = iso8583.RESPONSE_NO_ORIGINAL
+		err = errors.New("Original message has no hold or payment")
+	}
+	if err != nil {
+		return errors.New("payments.cardReversal: " + err.Error())
+	}
+
+	// What was reversed no longer counts towards the card's daily limit
+	err = setCardMessageReversed(original.ID)
+	if err != nil {
+		return errors.New("payments.cardReversal: " + err.Error())
+	}
+
+	return
+}
+
+// cancelCardHold releases an authorisation's hold. Holds already released need nothing more;
+// captured holds are reversed by reversing the financial message that captured them
+func cancelCardHold(holdID int64) (code string, err error) {
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return iso8583.RESPONSE_SYSTEM_ERROR, errors.New("payments.cancelCardHold: Could not start database transaction. " + err.Error())
+	}
+
+	hold, err := getHoldForUpdate(tx, holdID)
+	if err != nil {
+		tx.Rollback()
+		return iso8583.RESPONSE_NO_ORIGINAL, errors.New("payments.cancelCardHold: " + err.Error())
+	}
+	switch hold.Status {
+	case HOLD_CANCELLED, HOLD_EXPIRED:
+		tx.Rollback()
+		return iso8583.RESPONSE_APPROVED, nil
+	case HOLD_CAPTURED:
+		tx.Rollback()
+		return iso8583.RESPONSE_INVALID_TRANSACTION, errors.New("payments.cancelCardHold: Hold has been captured")
+	}
+
+	err = releaseHold(tx, hold, HOLD_CANCELLED)
+	if err != nil {
+		return iso8583.RESPONSE_SYSTEM_ERROR, errors.New("payments.cancelCardHold: " + err.Error())
+	}
+
+	return iso8583.RESPONSE_APPROVED, nil
+}
+
+// reverseCardPayment reverses a card payment with its fees refunded, as the acquirer reverses
+// transactions that did not complete
+func reverseCardPayment(originalID int64) (transactionID int64, code string, err error) {
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return 0, iso8583.RESPONSE_SYSTEM_ERROR, errors.New("payments.reverseCardPayment: Could not start database transaction. " + err.Error())
+	}
+
+	original, err := getTransactionForUpdate(tx, int32(originalID))
+	if err != nil {
+		tx.Rollback()
+		return 0, iso8583.RESPONSE_NO_ORIGINAL, errors.New("payments.reverseCardPayment: " + err.Error())
+	}
+	if original.Status == STATUS_REVERSED {
+		tx.Rollback()
+		return 0, iso8583.RESPONSE_APPROVED, nil
+	}
+
+	result, err := reversePayment(tx, original, "TECH", "Card reversal", *geo.NewPoint(0, 0), true)
+	if err != nil {
+		return 0, iso8583.RESPONSE_DO_NOT_HONOUR, errors.New("payments.reverseCardPayment: " + err.Error())
+	}
+
+	transactionID, err = strconv.ParseInt(result, 10, 64)
+	if err != nil {
+		return 0, iso8583.RESPONSE_SYSTEM_ERROR, errors.New("payments.reverseCardPayment: Could not parse transaction ID. " + err.Error())
+	}
+
+	return transactionID, iso8583.RESPONSE_APPROVED, nil
+}
+
+// cardPayee reads the card acceptor's account a purchase pays. When the purchase is not valid
+// the response code says why
+func cardPayee(request iso8583.Message, message CardMessage) (payee AccountHolder, code string, err error) {
+	// Only purchases (processing code 00xxxx) are supported
+	if !strings.HasPrefix(request.Get(iso8583.FIELD_PROCESSING_CODE), "00") {
+		return AccountHolder{}, iso8583.RESPONSE_INVALID_TRANSACTION, errors.New("payments.cardPayee: Processing code " + request.Get(iso8583.FIELD_PROCESSING_CODE) + " not supported")
+	}
+
+	payeeAccountNumber, ok := Config.CardAcceptors[message.CardAcceptorID]
+	if !ok {
+		return AccountHolder{}, iso8583.RESPONSE_INVALID_MERCHANT, errors.New("payments.cardPayee: Card acceptor " + message.CardAcceptorID + " not known")
+	}
+	if message.Amount.Sign() <= 0 {
+		return AccountHolder{}, iso8583.RESPONSE_INVALID_AMOUNT, errors.New("payments.cardPayee: Amount must be positive")
+	}
+
+	return AccountHolder{payeeAccountNumber, ""}, "", nil
+}
+
+// authoriseCard finds the card in a request and checks it can pay the amount, returning the
+// account it pays from. When it cannot the response code says why
+func authoriseCard(request iso8583.Message, message *CardMessage) (account AccountHolder, code string, err error) {
+	pan := request.Get(iso8583.FIELD_PAN)
+	card, found, err := cards.GetCardByPAN(pan)
+	if err != nil {
+		return AccountHolder{}, iso8583.RESPONSE_SYSTEM_ERROR, errors.New("payments.authoriseCard: " + err.Error())
+	}
+	if !found {
+		return AccountHolder{}, iso8583.RESPONSE_INVALID_CARD, errors.New("payments.authoriseCard: Card not found")
+	}
+	message.CardID = card.ID
+	message.AccountNumber = card.AccountNumber
+
+	if card.AccountNumber == Config.CardAcceptors[message.CardAcceptorID] {
+		return AccountHolder{}, iso8583.RESPONSE_INVALID_TRANSACTION, errors.New("payments.authoriseCard: Card account and card acceptor must differ")
+	}
+	if message.Amount.Currency != card.Currency {
+		return AccountHolder{}, iso8583.RESPONSE_NOT_PERMITTED, errors.New("payments.authoriseCard: Currency " + message.Amount.Currency + " is not the currency of the card's account")
+	}
+
+	now := time.Now()
+	spent, err := getCardSpending(card.ID, int32(cards.StartOfDay(now).Unix()))
+	if err != nil {
+		return AccountHolder{}, iso8583.RESPONSE_SYSTEM_ERROR, errors.New("payments.authoriseCard: " + err.Error())
+	}
+	decline, err := cards.CheckAuthorisation(card, pan, request.Get(iso8583.FIELD_EXPIRY), message.Amount, spent.In(card.Currency), now)
+	if err != nil {
+		return AccountHolder{}, cardDeclineCode(decline), errors.New("payments.authoriseCard: " + err.Error())
+	}
+
+	return AccountHolder{card.AccountNumber, ""}, "", nil
+}
+
+// originalCardMessage finds the approved or declined request named in a message's original
+// data elements. class limits it to requests of that message class, 0 for any
+func originalCardMessage(request iso8583.Message, class byte) (original CardMessage, code string, err error) {
+	mti, stan, transmissionTime, localTime, acquirerID, err := parseOriginalData(request)
+	if err != nil {
+		return CardMessage{}, iso8583.RESPONSE_FORMAT_ERROR, errors.New("payments.originalCardMessage: " + err.Error())
+	}
+	if class != 0 && mti[1] != class {
+		return CardMessage{}, iso8583.RESPONSE_INVALID_TRANSACTION, errors.New("payments.originalCardMessage: Original message type " + mti + " not valid")
+	}
+
+	original, found, err := getCardMessage(requestMTI(mti), acquirerID, stan, transmissionTime, localTime)
+	if err != nil {
+		return CardMessage{}, iso8583.RESPONSE_SYSTEM_ERROR, errors.New("payments.originalCardMessage: " + err.Error())
+	}
+	if !found {
+		return CardMessage{}, iso8583.RESPONSE_NO_ORIGINAL, errors.New("payments.originalCardMessage: Original message not found")
+	}
+	if class != 0 && original.ResponseCode != iso8583.RESPONSE_APPROVED {
+		return CardMessage{}, iso8583.RESPONSE_NO_ORIGINAL, errors.New("payments.originalCardMessage: Original message was declined")
+	}
+
+	return original, iso8583.RESPONSE_APPROVED, nil
+}
+
+func hasOriginalData(request iso8583.Message) bool {
+	return request.Has(iso8583.FIELD_ORIGINAL_DATA_1987) || request.Has(iso8583.FIELD_ORIGINAL_DATA_1993)
+}
+
+// parseOriginalData reads the original data elements. In 1987 (field 90) they are the original
+// MTI, STAN, transmission time and acquirer ID; in 1993 (field 56) the original MTI, STAN, local
+// time and the acquirer ID with its length
+func parseOriginalData(request iso8583.Message) (mti string, stan string, transmissionTime string, localTime string, acquirerID string, err error) {
+	if request.Version() == iso8583.VERSION_1993 {
+		data := request.Get(iso8583.FIELD_ORIGINAL_DATA_1993)
+		if len(data) < 24 {
+			return "", "", "", "", "", errors.New("payments.parseOriginalData: Original data elements missing")
+		}
+		length, err := strconv.Atoi(data[22:24])
+		if err != nil || len(data) != 24+length {
+			return "", "", "", "", "", errors.New("payments.parseOriginalData: Original acquirer ID not valid")
+		}
+		return data[:4], data[4:10], "", data[10:22], normaliseAcquirerID(data[24:]), nil
+	}
+
+	data := request.Get(iso8583.FIELD_ORIGINAL_DATA_1987)
+	if len(data) != 42 {
+		return "", "", "", "", "", errors.New("payments.parseOriginalData: Original data elements missing")
+	}
+	return data[:4], data[4:10], data[10:20], "", normaliseAcquirerID(data[20:31]), nil
+}
+
+// cardAmount reads the amount in minor units of the request's currency
+func cardAmount(request iso8583.Message) (amount money.Money, err error) {
+	currency, ok := money.CurrencyFromNumeric(request.Get(iso8583.FIELD_CURRENCY))
+	if !ok {
+		return money.Money{}, errors.New("payments.cardAmount: Currency " + request.Get(iso8583.FIELD_CURRENCY) + " not valid")
+	}
+
+	minorUnits, err := strconv.ParseInt(request.Get(iso8583.FIELD_AMOUNT), 10, 64)
+	if err != nil {
+		return money.Money{}, errors.New("payments.cardAmount: Could not parse amount. " + err.Error())
+	}
+
+	return money.New(decimal.New(minorUnits, -money.MinorUnits(currency)), currency), nil
+}
+
+// cardResponse sets the response code and authorisation code of a logged message on its response
+func cardResponse(response *iso8583.Message, message CardMessage) {
+	response.SetResponseCode(message.ResponseCode)
+	if message.AuthorisationCode != "" {
+		response.Set(iso8583.FIELD_AUTHORISATION_CODE, message.AuthorisationCode)
+	}
+}
+
+func cardResponseCode(reasonCode string) string {
+	if code, ok := cardResponseCodes[reasonCode]; ok {
+		return code
+	}
+	return iso8583.RESPONSE_DO_NOT_HONOUR
+}
+
+func cardDeclineCode(decline string) string {
+	if code, ok := cardDeclineCodes[decline]; ok {
+		return code
+	}
+	return iso8583.RESPONSE_SYSTEM_ERROR
+}
+
+func cardDescription(request iso8583.Message) string {
+	if name := strings.TrimSpace(request.Get(iso8583.FIELD_CARD_ACCEPTOR_NAME)); name != "" {
+		return name
+	}
+	return "Card payment " + strings.TrimSpace(request.Get(iso8583.FIELD_CARD_ACCEPTOR_ID))
+}
+
+// authorisationCode is the six digit code approving a request, taken from the hold or payment
+func authorisationCode(id int64) string {
+	return iso8583.PadNumber(strconv.FormatInt(id%1000000, 10), 6)
+}
+
+// requestMTI is the MTI without the repeat digit, so repeats match the request they repeat
+func requestMTI(mti string) string {
+	if len(mti) != 4 || (mti[3]-'0')%2 == 0 {
+		return mti
+	}
+	return mti[:3] + string(mti[3]-1)
+}
+
+// normaliseAcquirerID drops the zeroes the original data elements pad acquirer IDs with
+func normaliseAcquirerID(acquirerID string) string {
+	return strings.TrimLeft(strings.TrimSpace(acquirerID), "0")
+}
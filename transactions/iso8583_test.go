@@ -0,0 +1,178 @@
+package transactions
+
+import (
+	"testing"
+
+	"github.com/bvnk/bank/cards"
+	"github.com/bvnk/bank/iso8583"
+)
+
+func cardPurchase(mti string) iso8583.Message {
+	m := iso8583.NewMessage(mti)
+	m.Set(iso8583.FIELD_PAN, "4000001234567899")
+	m.Set(iso8583.FIELD_PROCESSING_CODE, "000000")
+	m.Set(iso8583.FIELD_AMOUNT, "000000001250")
+	m.Set(iso8583.FIELD_TRANSMISSION_TIME, "0315120000")
+	m.Set(iso8583.FIELD_EXPIRY, "2903")
+	m.Set(iso8583.FIELD_STAN, "000001")
+	m.Set(iso8583.FIELD_ACQUIRER_ID, "123456")
+	m.Set(iso8583.FIELD_CARD_ACCEPTOR_ID, "MERCHANT1      ")
+	m.Set(iso8583.FIELD_CURRENCY, "840")
+	return m
+}
+
+func TestProcessISO8583NetworkManagement(t *testing.T) {
+	tests := []struct {
+		mti      string
+		field    int
+		code     string
+		expected string
+	}{
+		{"0800", iso8583.FIELD_NETWORK_CODE, "301", "00"},
+		{"0800", iso8583.FIELD_NETWORK_CODE, "001", "00"},
+		{"0800", iso8583.FIELD_NETWORK_CODE, "999", "12"},
+		{"1804", iso8583.FIELD_FUNCTION_CODE, "831", "800"},
+		{"1804", iso8583.FIELD_FUNCTION_CODE, "301", "902"},
+	}
+	for _, test := range tests {
+		request := iso8583.NewMessage(test.mti)
+		request.Set(test.field, test.code)
+		response, _ := ProcessISO8583(request)
+		if response.Get(iso8583.FIELD_RESPONSE_CODE) != test.expected {
+			t.Errorf("ProcessISO8583 network management %v %v does not pass. Looking for %v, got %v", test.mti, test.code, test.expected, response.Get(iso8583.FIELD_RESPONSE_CODE))
+		}
+	}
+
+	response, err := ProcessISO8583(iso8583.NewMessage("0800"))
+	if err == nil || response.Get(iso8583.FIELD_RESPONSE_CODE) != iso8583.RESPONSE_FORMAT_ERROR {
+		t.Errorf("ProcessISO8583 network management code missing does not pass. Looking for %v, got %v", iso8583.RESPONSE_FORMAT_ERROR, response.Get(iso8583.FIELD_RESPONSE_CODE))
+	}
+}
+
+func TestProcessISO8583Invalid(t *testing.T) {
+	// Responses are not requests
+	response, err := ProcessISO8583(iso8583.NewMessage("0110"))
+	if err == nil || response.MTI != "0110" || response.Get(iso8583.FIELD_RESPONSE_CODE) != iso8583.RESPONSE_INVALID_TRANSACTION {
+		t.Errorf("ProcessISO8583 response does not pass. Looking for %v, got %v", iso8583.RESPONSE_INVALID_TRANSACTION, response.Get(iso8583.FIELD_RESPONSE_CODE))
+	}
+
+	for _, field := range []int{iso8583.FIELD_STAN, iso8583.FIELD_AMOUNT, iso8583.FIELD_CURRENCY, iso8583.FIELD_PAN, iso8583.FIELD_EXPIRY} {
+		request := cardPurchase("0100")
+		request.Unset(field)
+		response, err = ProcessISO8583(request)
+		if err == nil || response.MTI != "0110" || response.Get(iso8583.FIELD_RESPONSE_CODE) != iso8583.RESPONSE_FORMAT_ERROR {
+			t.Errorf("ProcessISO8583 field %v missing does not pass. Looking for %v, got %v", field, iso8583.RESPONSE_FORMAT_ERROR, response.Get(iso8583.FIELD_RESPONSE_CODE))
+		}
+	}
+
+	request := cardPurchase("1200")
+	request.Set(iso8583.FIELD_CURRENCY, "999")
+	response, _ = ProcessISO8583(request)
+	if response.Get(iso8583.FIELD_RESPONSE_CODE) != "904" {
+		t.Errorf("ProcessISO8583 currency does not pass. Looking for %v, got %v", "904", response.Get(iso8583.FIELD_RESPONSE_CODE))
+	}
+}
+
+func TestCardPayee(t *testing.T) {
+	acceptors := Config.CardAcceptors
+	defer func() { Config.CardAcceptors = acceptors }()
+	Config.CardAcceptors = map[string]string{"MERCHANT1": "merchantAccount"}
+
+	request := cardPurchase("0100")
+	message, err := cardMessageFromRequest(request)
+	if err != nil {
+		t.Fatalf("CardMessageFromRequest does not pass. Looking for %v, got %v", nil, err)
+	}
+	if message.Amount.StringFixed() != "12.50" || message.CardAcceptorID != "MERCHANT1" || message.AcquirerID != "123456" {
+		t.Errorf("CardMessageFromRequest does not pass. Looking for %v, got %v", "12.50 MERCHANT1 123456", message)
+	}
+
+	payee, _, err := cardPayee(request, message)
+	if err != nil || payee.AccountNumber != "merchantAccount" {
+		t.Errorf("CardPayee does not pass. Looking for %v, got %v %v", "merchantAccount", payee, err)
+	}
+
+	request.Set(iso8583.FIELD_CURRENCY, "392")
+	message, err = cardMessageFromRequest(request)
+	if err != nil || message.Amount.Currency != "JPY" || message.Amount.StringFixed() != "1250" {
+		t.Errorf("CardMessageFromRequest currency does not pass. Looking for %v, got %v %v", "1250 JPY", message.Amount, err)
+	}
+
+	invalid := map[string]struct {
+		field    int
+		value    string
+		expected string
+	}{
+		"processing code": {iso8583.FIELD_PROCESSING_CODE, "010000", iso8583.RESPONSE_INVALID_TRANSACTION},
+		"card acceptor":   {iso8583.FIELD_CARD_ACCEPTOR_ID, "MERCHANT2", iso8583.RESPONSE_INVALID_MERCHANT},
+		"amount":          {iso8583.FIELD_AMOUNT, "000000000000", iso8583.RESPONSE_INVALID_AMOUNT},
+	}
+	for name, test := range invalid {
+		request := cardPurchase("0100")
+		request.Set(test.field, test.value)
+		message, _ := cardMessageFromRequest(request)
+		_, code, err := cardPayee(request, message)
+		if err == nil || code != test.expected {
+			t.Errorf("CardPayee %v does not pass. Looking for %v, got %v", name, test.expected, code)
+		}
+	}
+}
+
+func TestParseOriginalData(t *testing.T) {
+	request := iso8583.NewMessage("0400")
+	request.Set(iso8583.FIELD_ORIGINAL_DATA_1987, "0100"+"000001"+"0315120000"+"00000123456"+"00000000000")
+	mti, stan, transmissionTime, localTime, acquirerID, err := parseOriginalData(request)
+	if err != nil || mti != "0100" || stan != "000001" || transmissionTime != "0315120000" || localTime != "" || acquirerID != "123456" {
+		t.Errorf("ParseOriginalData 1987 does not pass. Looking for %v, got %v %v %v %v %v %v", "0100 000001 0315120000 123456", mti, stan, transmissionTime, localTime, acquirerID, err)
+	}
+
+	request = iso8583.NewMessage("1420")
+	request.Set(iso8583.FIELD_ORIGINAL_DATA_1993, "1200"+"000002"+"160315120000"+"06"+"123456")
+	mti, stan, transmissionTime, localTime, acquirerID, err = parseOriginalData(request)
+	if err != nil || mti != "1200" || stan != "000002" || transmissionTime != "" || localTime != "160315120000" || acquirerID != "123456" {
+		t.Errorf("ParseOriginalData 1993 does not pass. Looking for %v, got %v %v %v %v %v %v", "1200 000002 160315120000 123456", mti, stan, transmissionTime, localTime, acquirerID, err)
+	}
+
+	request.Set(iso8583.FIELD_ORIGINAL_DATA_1993, "1200"+"000002"+"160315120000"+"07"+"123456")
+	_, _, _, _, _, err = parseOriginalData(request)
+	if err == nil {
+		t.Errorf("ParseOriginalData acquirer length does not pass. Looking for %v, got %v", "Original acquirer ID not valid", nil)
+	}
+
+	_, _, _, _, _, err = parseOriginalData(iso8583.NewMessage("0400"))
+	if err == nil {
+		t.Errorf("ParseOriginalData missing does not pass. Looking for %v, got %v", "Original data elements missing", nil)
+	}
+}
+
+func TestCardResponseCode(t *testing.T) {
+	for reasonCode, expected := range map[string]string{
+		REASON_INSUFFICIENT_FUNDS:    iso8583.RESPONSE_INSUFFICIENT_FUNDS,
+		REASON_TRANSACTION_FORBIDDEN: iso8583.RESPONSE_NOT_PERMITTED,
+		REASON_CLOSED_ACCOUNT:        iso8583.RESPONSE_RESTRICTED_CARD,
+		"":                           iso8583.RESPONSE_DO_NOT_HONOUR,
+	} {
+		if code := cardResponseCode(reasonCode); code != expected {
+			t.Errorf("CardResponseCode %v does not pass. Looking for %v, got %v", reasonCode, expected, code)
+		}
+	}
+
+	for decline, expected := range map[string]string{
+		cards.DECLINE_FROZEN:          iso8583.RESPONSE_RESTRICTED_CARD,
+		cards.DECLINE_CANCELLED:       iso8583.RESPONSE_INVALID_CARD,
+		cards.DECLINE_EXPIRY_MISMATCH: iso8583.RESPONSE_EXPIRED_CARD,
+		cards.DECLINE_DAILY_LIMIT:     iso8583.RESPONSE_EXCEEDS_LIMIT,
+		"":                            iso8583.RESPONSE_SYSTEM_ERROR,
+	} {
+		if code := cardDeclineCode(decline); code != expected {
+			t.Errorf("CardDeclineCode %v does not pass. Looking for %v, got %v", decline, expected, code)
+		}
+	}
+
+	if authorisationCode(1234567) != "234567" || authorisationCode(12) != "000012" {
+		t.Errorf("AuthorisationCode does not pass. Looking for %v, got %v %v", "234567 000012", authorisationCode(1234567), authorisationCode(12))
+	}
+	if requestMTI("0421") != "0420" || requestMTI("0100") != "0100" {
+		t.Errorf("RequestMTI does not pass. Looking for %v, got %v %v", "0420 0100", requestMTI("0421"), requestMTI("0100"))
+	}
+}
@@ -0,0 +1,463 @@
+package transactions
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bvnk/bank/accounts"
+	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/interest"
+	"github.com/bvnk/bank/money"
+	"github.com/bvnk/bank/push"
+	"github.com/paulmach/go.geo"
+)
+
+/*
+Mandates allow a merchant (creditor) to collect direct debits from a debtor account
+
+Lifecycle:
+pain.009 MandateInitiationRequest - merchant requests a mandate, status pending
+pain.012 MandateAcceptanceReport - debtor accepts (active) or rejects (rejected) a pending mandate
+pain.010 MandateAmendmentRequest - merchant changes the limits, the mandate goes back to pending
+pain.011 MandateCancellationRequest - either party cancels, status cancelled
+pain.008 CustomerDirectDebitInitiation - merchant collects against an active mandate
+*/
+
+const (
+	MANDATE_PENDING   = "pending"
+	MANDATE_ACTIVE    = "active"
+	MANDATE_REJECTED  = "rejected"
+	MANDATE_CANCELLED = "cancelled"
+)
+
+// Collection frequencies (ISO 20022 Frequency6Code). A mandate allows one collection per period,
+// ADHO allows collections at any time
+var mandateFrequencies = map[string]bool{
+	"YEAR": true,
+	"MIAN": true,
+	"QURT": true,
+	"MNTH": true,
+	"FRTN": true,
+	"WEEK": true,
+	"DAIL": true,
+	"ADHO": true,
+}
+
+type Mandate struct {
+	ID             int32
+	MandateID      string
+	MerchantID     string
+	Creditor       AccountHolder
+	Debtor         AccountHolder
+	MaxAmount      money.Money
+	Frequency      string
+	Status         string
+	Desc           string
+	LastCollection int32
+	Timestamp      int32
+}
+
+func mandateInitiationRequest(data []string) (result string, err error) {
+	// Validate input
+	creditor, err := parseAccountHolder(data[3])
+	if err != nil {
+		return "", errors.New("payments.mandateInitiationRequest: " + err.Error())
+	}
+	debtor, err := parseAccountHolder(data[4])
+	if err != nil {
+		return "", errors.New("payments.mandateInitiationRequest: " + err.Error())
+	}
+	if creditor.BankNumber != "" || debtor.BankNumber != "" {
+		return "", errors.New("payments.mandateInitiationRequest: Mandates are only supported between accounts at this bank")
+	}
+
+	desc := data[7]
+
+	// Only merchants can request mandates, from their own accounts
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("payments.mandateInitiationRequest: " + err.Error())
+	}
+	merchantID, err := accounts.CheckMerchantAccountValidFromToken(tokenUser, creditor.AccountNumber)
+	if err != nil {
+		return "", errors.New("payments.mandateInitiationRequest: Creditor not valid")
+	}
+
+	// Mandates are in the currency of the debtor account, which the creditor has to hold too
+	currency, err := mandateCurrency(creditor, debtor)
+	if err != nil {
+		return "", errors.New("payments.mandateInitiationRequest: " + err.Error())
+	}
+	maxAmount, frequency, err := parseMandateTerms(data[5], data[6], currency)
+	if err != nil {
+		return "", errors.New("payments.mandateInitiationRequest: " + err.Error())
+	}
+
+	mandate := Mandate{
+		MerchantID: merchantID,
+		Creditor:   creditor,
+		Debtor:     debtor,
+		MaxAmount:  maxAmount,
+		Frequency:  frequency,
+		Status:     MANDATE_PENDING,
+		Desc:       desc,
+	}
+
+	result, err = saveMandate(mandate)
+	if err != nil {
+		return "", errors.New("payments.mandateInitiationRequest: " + err.Error())
+	}
+
+	go push.SendNotification(debtor.AccountNumber, "📝 Direct debit mandate requested", 1, "default")
+
+	return
+}
+
+func mandateAmendmentRequest(data []string) (result string, err error) {
+	mandateID := data[3]
+
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("payments.mandateAmendmentRequest: " + err.Error())
+	}
+
+	mandate, err := getMandate(mandateID)
+	if err != nil {
+		return "", errors.New("payments.mandateAmendmentRequest: " + err.Error())
+	}
+
+	// Only the creditor can amend
+	err = accounts.CheckUserAccountValidFromToken(tokenUser, mandate.Creditor.AccountNumber)
+	if err != nil {
+		return "", errors.New("payments.mandateAmendmentRequest: Creditor not valid")
+	}
+
+	// The currency of a mandate does not change
+	maxAmount, frequency, err := parseMandateTerms(data[4], data[5], mandate.MaxAmount.Currency)
+	if err != nil {
+		return "", errors.New("payments.mandateAmendmentRequest: " + err.Error())
+	}
+
+	// The debtor has to accept the new terms before any further collections
+	err = updateMandateTerms(mandateID, maxAmount, frequency)
+	if err != nil {
+		return "", errors.New("payments.mandateAmendmentRequest: " + err.Error())
+	}
+
+	go push.SendNotification(mandate.Debtor.AccountNumber, "📝 Direct debit mandate amended", 1, "default")
+
+	return mandateID, nil
+}
+
+func mandateCancellationRequest(data []string) (result string, err error) {
+	mandateID := data[3]
+
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("payments.mandateCancellationRequest: " + err.Error())
+	}
+
+	mandate, err := getMandate(mandateID)
+	if err != nil {
+		return "", errors.New("payments.mandateCancellationRequest: " + err.Error())
+	}
+
+	// Either party can cancel
+	errCreditor := accounts.CheckUserAccountValidFromToken(tokenUser, mandate.Creditor.AccountNumber)
+	errDebtor := accounts.CheckUserAccountValidFromToken(tokenUser, mandate.Debtor.AccountNumber)
+	if errCreditor != nil && errDebtor != nil {
+		return "", errors.New("payments.mandateCancellationRequest: Account not party to mandate")
+	}
+
+	if mandate.Status != MANDATE_PENDING && mandate.Status != MANDATE_ACTIVE {
+		return "", errors.New("payments.mandateCancellationRequest: Mandate cannot be cancelled, status is " + mandate.Status)
+	}
+
+	err = updateMandateStatus(mandateID, mandate.Status, MANDATE_CANCELLED)
+	if err != nil {
+		return "", errors.New("payments.mandateCancellationRequest: " + err.Error())
+	}
+
+	go push.SendNotification(mandate.Creditor.AccountNumber, "📝 Direct debit mandate cancelled", 1, "default")
+	go push.SendNotification(mandate.Debtor.AccountNumber, "📝 Direct debit mandate cancelled", 1, "default")
+
+	return mandateID, nil
+}
+
+func mandateAcceptanceReport(data []string) (result string, err error) {
+	mandateID := data[3]
+	accepted, err := strconv.ParseBool(data[4])
+	if err != nil {
+		return "", errors.New("payments.mandateAcceptanceReport: Could not parse acceptance. " + err.Error())
+	}
+
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("payments.mandateAcceptanceReport: " + err.Error())
+	}
+
+	mandate, err := getMandate(mandateID)
+	if err != nil {
+		return "", errors.New("payments.mandateAcceptanceReport: " + err.Error())
+	}
+
+	// Only the debtor can accept
+	err = accounts.CheckUserAccountValidFromToken(tokenUser, mandate.Debtor.AccountNumber)
+	if err != nil {
+		return "", errors.New("payments.mandateAcceptanceReport: Debtor not valid")
+	}
+
+	if mandate.Status != MANDATE_PENDING {
+		return "", errors.New("payments.mandateAcceptanceReport: Mandate is not pending acceptance, status is " + mandate.Status)
+	}
+
+	newStatus := MANDATE_REJECTED
+	if accepted {
+		newStatus = MANDATE_ACTIVE
+	}
+
+	err = updateMandateStatus(mandateID, MANDATE_PENDING, newStatus)
+	if err != nil {
+		return "", errors.New("payments.mandateAcceptanceReport: " + err.Error())
+	}
+
+	go push.SendNotification(mandate.Creditor.AccountNumber, "📝 Direct debit mandate "+newStatus, 1, "default")
+
+	return newStatus, nil
+}
+
+func customerDirectDebitInitiation(painType int64, data []string) (result string, err error) {
+	// Validate input
+	mandateID := data[3]
+
+	// The amount is in the currency of the mandate, converted once the mandate is read
+	trAmt := strings.TrimRight(data[4], "\x00")
+	err = checkAmount(trAmt)
+	if err != nil {
+		return "", errors.New("payments.customerDirectDebitInitiation: " + err.Error())
+	}
+
+	lat, err := strconv.ParseFloat(data[5], 64)
+	if err != nil {
+		return "", errors.New("payments.customerDirectDebitInitiation: Could not parse coordinates into float")
+	}
+	lon, err := strconv.ParseFloat(data[6], 64)
+	if err != nil {
+		return "", errors.New("payments.customerDirectDebitInitiation: Could not parse coordinates into float")
+	}
+	desc := data[7]
+
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("payments.customerDirectDebitInitiation: " + err.Error())
+	}
+
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return "", errors.New("payments.customerDirectDebitInitiation: Could not start database transaction. " + err.Error())
+	}
+
+	// Lock the mandate so concurrent collections cannot both pass the frequency check
+	mandate, err := getMandateForUpdate(tx, mandateID)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.customerDirectDebitInitiation: " + err.Error())
+	}
+
+	// Only the creditor can collect
+	err = accounts.CheckUserAccountValidFromToken(tokenUser, mandate.Creditor.AccountNumber)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.customerDirectDebitInitiation: Creditor not valid")
+	}
+
+	transactionAmount, err := money.NewFromString(trAmt, mandate.MaxAmount.Currency)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.customerDirectDebitInitiation: Could not convert transaction amount. " + err.Error())
+	}
+	if transactionAmount.Sign() <= 0 {
+		tx.Rollback()
+		return "", errors.New("payments.customerDirectDebitInitiation: Transaction amount must be positive")
+	}
+
+	sqlTime := int32(time.Now().Unix())
+	err = checkMandateCollection(mandate, transactionAmount, time.Unix(int64(sqlTime), 0))
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.customerDirectDebitInitiation: " + err.Error())
+	}
+
+	// The debtor pays the amount, the merchant pays the fee
+	balanceAvailable, err := checkBalance(tx, mandate.Debtor)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.customerDirectDebitInitiation: " + err.Error())
+	}
+	transaction := PAINTrans{
+		PainType:  painType,
+		Sender:    mandate.Debtor,
+		Receiver:  mandate.Creditor,
+		Amount:    transactionAmount,
+		Geo:       *geo.NewPoint(lat, lon),
+		Desc:      desc,
+		Status:    STATUS_RECEIVED,
+		MandateID: mandateID,
+	}
+	err = assessFees(tx, &transaction)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.customerDirectDebitInitiation: " + err.Error())
+	}
+
+	// Debtors who are not verified cannot be collected from
+	restricted, err := checkSenderRestricted(tx, transaction.Sender)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.customerDirectDebitInitiation: " + err.Error())
+	}
+	if restricted {
+		transactionID, err := rejectPAINTransaction(tx, transaction, REASON_TRANSACTION_FORBIDDEN)
+		if err != nil {
+			return "", errors.New("payments.customerDirectDebitInitiation: " + err.Error())
+		}
+		return "", errors.New("payments.customerDirectDebitInitiation: Debtor not verified. Transaction " + transactionID + " rejected")
+	}
+
+	// Collecting from a term account before it matures costs the debtor a penalty
+	penalty, err := interest.EarlyWithdrawalPenalty(tx, transaction.Sender.AccountNumber, transactionAmount, time.Unix(int64(sqlTime), 0))
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.customerDirectDebitInitiation: " + err.Error())
+	}
+	if balanceAvailable.Cmp(transactionAmount.Add(penalty)) == -1 {
+		transactionID, err := rejectPAINTransaction(tx, transaction, REASON_INSUFFICIENT_FUNDS)
+		if err != nil {
+			return "", errors.New("payments.customerDirectDebitInitiation: " + err.Error())
+		}
+		return "", errors.New("payments.customerDirectDebitInitiation: Insufficient funds available. Transaction " + transactionID + " rejected")
+	}
+
+	err = interest.ChargeEarlyWithdrawalPenalty(tx, transaction.Sender.AccountNumber, penalty, sqlTime)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.customerDirectDebitInitiation: " + err.Error())
+	}
+
+	err = updateMandateLastCollection(tx, mandateID, sqlTime)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.customerDirectDebitInitiation: " + err.Error())
+	}
+
+	result, err = processPAINTransaction(tx, transaction)
+	if err != nil {
+		return "", errors.New("payments.customerDirectDebitInitiation: " + err.Error())
+	}
+
+	go push.SendNotification(mandate.Debtor.AccountNumber, "💸 Direct debit collected!", 1, "default")
+	go push.SendNotification(mandate.Creditor.AccountNumber, "💸 Direct debit received!", 1, "default")
+
+	return
+}
+
+func listMandates(data []string) (result interface{}, err error) {
+	accountNumber := data[3]
+
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("payments.listMandates: " + err.Error())
+	}
+	err = accounts.CheckUserAccountValidFromToken(tokenUser, accountNumber)
+	if err != nil {
+		return "", errors.New("payments.listMandates: Account not valid")
+	}
+
+	result, err = getMandateList(accountNumber)
+	if err != nil {
+		return "", errors.New("payments.listMandates: " + err.Error())
+	}
+
+	return
+}
+
+func parseMandateTerms(maxAmountStr string, frequency string, currency string) (maxAmount money.Money, freq string, err error) {
+	maxAmount, err = money.NewFromString(strings.TrimRight(maxAmountStr, "\x00"), currency)
+	if err != nil {
+		return money.Money{}, "", errors.New("payments.parseMandateTerms: Could not convert maximum amount. " + err.Error())
+	}
+	if maxAmount.Sign() <= 0 {
+		return money.Money{}, "", errors.New("payments.parseMandateTerms: Maximum amount must be positive")
+	}
+
+	freq = strings.ToUpper(strings.TrimSpace(frequency))
+	if !mandateFrequencies[freq] {
+		return money.Money{}, "", errors.New("payments.parseMandateTerms: Frequency not valid, must be one of YEAR, MIAN, QURT, MNTH, FRTN, WEEK, DAIL, ADHO")
+	}
+
+	return maxAmount, freq, nil
+}
+
+// mandateCurrency returns the currency of the debtor account, which the creditor account has
+// to be held in too
+func mandateCurrency(creditor AccountHolder, debtor AccountHolder) (currency string, err error) {
+	currency, err = getAccountCurrency(Config.Db, debtor.AccountNumber)
+	if err != nil {
+		return "", errors.New("payments.mandateCurrency: " + err.Error())
+	}
+	if currency == "" {
+		return "", errors.New("payments.mandateCurrency: Debtor account " + debtor.AccountNumber + " not found")
+	}
+
+	creditorCurrency, err := getAccountCurrency(Config.Db, creditor.AccountNumber)
+	if err != nil {
+		return "", errors.New("payments.mandateCurrency: " + err.Error())
+	}
+	if creditorCurrency != currency {
+		return "", errors.New("payments.mandateCurrency: Creditor account is not held in the debtor currency " + currency)
+	}
+
+	return
+}
+
+// checkMandateCollection checks a collection of amount at now against the mandate's status and limits
+func checkMandateCollection(mandate Mandate, amount money.Money, now time.Time) (err error) {
+	if mandate.Status != MANDATE_ACTIVE {
+		return errors.New("payments.checkMandateCollection: Mandate is not active, status is " + mandate.Status)
+	}
+	if !amount.SameCurrency(mandate.MaxAmount) {
+		return errors.New("payments.checkMandateCollection: Currency does not match mandate")
+	}
+	if amount.Cmp(mandate.MaxAmount) == 1 {
+		return errors.New("payments.checkMandateCollection: Amount exceeds mandate maximum of " + mandate.MaxAmount.String())
+	}
+	if mandate.LastCollection != 0 && now.Before(nextMandateCollection(mandate.Frequency, mandate.LastCollection)) {
+		return errors.New("payments.checkMandateCollection: Mandate frequency " + mandate.Frequency + " does not allow another collection yet")
+	}
+	return
+}
+
+// nextMandateCollection is the earliest time a collection is allowed after the one at lastCollection
+func nextMandateCollection(frequency string, lastCollection int32) time.Time {
+	last := time.Unix(int64(lastCollection), 0)
+	switch frequency {
+	case "YEAR":
+		return last.AddDate(1, 0, 0)
+	case "MIAN":
+		return last.AddDate(0, 6, 0)
+	case "QURT":
+		return last.AddDate(0, 3, 0)
+	case "MNTH":
+		return last.AddDate(0, 1, 0)
+	case "FRTN":
+		return last.AddDate(0, 0, 14)
+	case "WEEK":
+		return last.AddDate(0, 0, 7)
+	case "DAIL":
+		return last.AddDate(0, 0, 1)
+	}
+	// ADHO
+	return last
+}
@@ -0,0 +1,112 @@
+package transactions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bvnk/bank/ledger"
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+func TestProcessPAINMandates(t *testing.T) {
+	for _, painType := range []string{"8", "9", "10", "11", "12", "1002"} {
+		data := []string{"", "", painType}
+		_, err := ProcessPAIN(data)
+		if err == nil {
+			t.Errorf("ProcessPAIN PainType%v does not pass. Looking for %v, got %v", painType, "Not all data is present", nil)
+		}
+	}
+}
+
+func TestParseMandateTerms(t *testing.T) {
+	maxAmount, frequency, err := parseMandateTerms("50.00", "mnth", money.DEFAULT_CURRENCY)
+	if err != nil {
+		t.Errorf("ParseMandateTerms does not pass. Looking for %v, got %v", nil, err)
+	}
+	if maxAmount.StringFixed() != "50.00" || frequency != "MNTH" {
+		t.Errorf("ParseMandateTerms does not pass. Looking for %v, got %v", "50.00 MNTH", maxAmount.StringFixed()+" "+frequency)
+	}
+
+	_, _, err = parseMandateTerms("-1", "MNTH", money.DEFAULT_CURRENCY)
+	if err == nil {
+		t.Errorf("ParseMandateTerms negative amount does not pass. Looking for %v, got %v", "Maximum amount must be positive", nil)
+	}
+
+	_, _, err = parseMandateTerms("50", "HOURLY", money.DEFAULT_CURRENCY)
+	if err == nil {
+		t.Errorf("ParseMandateTerms frequency does not pass. Looking for %v, got %v", "Frequency not valid", nil)
+	}
+
+	// Amounts are limited to the minor units of the mandate currency
+	_, _, err = parseMandateTerms("50.5", "MNTH", "JPY")
+	if err == nil {
+		t.Errorf("ParseMandateTerms minor units does not pass. Looking for %v, got %v", "Could not convert maximum amount", nil)
+	}
+}
+
+func TestCheckMandateCollection(t *testing.T) {
+	now := time.Date(2016, 3, 15, 12, 0, 0, 0, time.UTC)
+	mandate := Mandate{
+		MaxAmount: money.New(decimal.New(50, 0), money.DEFAULT_CURRENCY),
+		Frequency: "MNTH",
+		Status:    MANDATE_ACTIVE,
+	}
+	amount := money.New(decimal.New(50, 0), money.DEFAULT_CURRENCY)
+
+	err := checkMandateCollection(mandate, amount, now)
+	if err != nil {
+		t.Errorf("CheckMandateCollection first collection does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	err = checkMandateCollection(mandate, money.New(decimal.New(5001, -2), money.DEFAULT_CURRENCY), now)
+	if err == nil {
+		t.Errorf("CheckMandateCollection maximum does not pass. Looking for %v, got %v", "Amount exceeds mandate maximum", nil)
+	}
+
+	mandate.LastCollection = int32(now.AddDate(0, 0, -20).Unix())
+	err = checkMandateCollection(mandate, amount, now)
+	if err == nil {
+		t.Errorf("CheckMandateCollection frequency does not pass. Looking for %v, got %v", "Mandate frequency MNTH does not allow another collection yet", nil)
+	}
+
+	mandate.LastCollection = int32(now.AddDate(0, -1, 0).Unix())
+	err = checkMandateCollection(mandate, amount, now)
+	if err != nil {
+		t.Errorf("CheckMandateCollection next period does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	mandate.Frequency = "ADHO"
+	mandate.LastCollection = int32(now.Unix())
+	err = checkMandateCollection(mandate, amount, now)
+	if err != nil {
+		t.Errorf("CheckMandateCollection ad hoc does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	mandate.Status = MANDATE_PENDING
+	err = checkMandateCollection(mandate, amount, now)
+	if err == nil {
+		t.Errorf("CheckMandateCollection status does not pass. Looking for %v, got %v", "Mandate is not active", nil)
+	}
+}
+
+func TestJournalEntryForTransactionDirectDebit(t *testing.T) {
+	sender := AccountHolder{"accountNumDebtor", ""}
+	receiver := AccountHolder{"accountNumMerchant", ""}
+	amount := money.New(decimal.New(12345, 0), money.DEFAULT_CURRENCY)
+	trans := withFee(PAINTrans{ID: 1, PainType: 8, Sender: sender, Receiver: receiver, Amount: amount, MandateID: "mandate"})
+
+	entry := journalEntryForTransaction(trans)
+	err := entry.Validate()
+	if err != nil {
+		t.Errorf("JournalEntryForTransaction direct debit does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	if !entry.Lines[1].Amount.Equals(decimal.RequireFromString("12343.77")) {
+		t.Errorf("JournalEntryForTransaction direct debit does not pass. Looking for %v, got %v", "12343.77", entry.Lines[1].Amount)
+	}
+
+	if entry.Lines[2].LedgerAccount != ledger.FeeIncomeAccount("transaction") {
+		t.Errorf("JournalEntryForTransaction direct debit does not pass. Looking for %v, got %v", ledger.FeeIncomeAccount("transaction"), entry.Lines[2].LedgerAccount)
+	}
+}
@@ -0,0 +1,686 @@
+package transactions
+
+/*
+PACS messages are as follows
+8 - FIToFICustomerCreditTransferV05, received from another bank and answered with a
+    FIToFIPaymentStatusReportV07 (pacs.002)
+
+#### Custom
+1001 - Send queued credit transfers (bank operators only)
+
+Payments to accounts at another bank are queued as pacs.008 together with the payment, and
+sent to the peer configured for the receiving bank number. The sender is debited straight
+away and the amount waits in suspense. When the peer answers with pacs.002 the payment is
+either settled, moving the amount to the peer's clearing account, or rejected, returning the
+amount and the fee to the sender. A transfer that gets no final answer stays queued and is
+sent again with the same message identification, so the peer posts it only once.
+
+Credit transfers from another bank are credited straight away against that bank's clearing
+account.
+*/
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bvnk/bank/accounts"
+	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/configuration"
+	"github.com/bvnk/bank/fees"
+	"github.com/bvnk/bank/iso20022"
+	"github.com/bvnk/bank/ledger"
+	"github.com/bvnk/bank/money"
+	"github.com/bvnk/bank/statements"
+	"github.com/paulmach/go.geo"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	MESSAGE_DIRECTION_IN  = "in"
+	MESSAGE_DIRECTION_OUT = "out"
+)
+
+// Interbank message statuses. Outgoing messages are queued until they are settled or
+// rejected, incoming messages are received until they are answered
+const (
+	MESSAGE_QUEUED   = "queued"
+	MESSAGE_SETTLED  = "settled"
+	MESSAGE_REJECTED = "rejected"
+	MESSAGE_RECEIVED = "received"
+	MESSAGE_ANSWERED = "answered"
+)
+
+// Time a peer bank has to answer
+const PEER_TIMEOUT = 30 * time.Second
+
+// Largest answer read from a peer bank, in bytes
+const MAX_PEER_RESPONSE_SIZE = 1024 * 1024
+
+type InterbankMessage struct {
+	ID            int32
+	MessageID     string
+	Direction     string
+	BankNumber    string
+	TransactionID int32
+	Status        string
+	Document      string
+	Response      string
+	Attempts      int32
+	Timestamp     int32
+}
+
+func ProcessPACS(data []string) (result interface{}, err error) {
+	if len(data) < 3 {
+		return "", errors.New("payments.ProcessPACS: Not all data is present. Run pacs~help to check for needed PACS data")
+	}
+
+	pacsType, err := strconv.ParseInt(data[2], 10, 64)
+	if err != nil {
+		return "", errors.New("payments.ProcessPACS: Could not get type of PACS message. " + err.Error())
+	}
+
+	switch pacsType {
+	case 8:
+		//token~pacs~type~bankNumber~secret~base64(pacs.008 XML)
+		if len(data) < 6 {
+			return "", errors.New("payments.ProcessPACS: Not all data is present.")
+		}
+		result, err = receiveInterbankCreditTransfer(data)
+		if err != nil {
+			return "", errors.New("payments.ProcessPACS: " + err.Error())
+		}
+		break
+	case 1001:
+		//token~pacs~type
+		_, err = appauth.CheckOperator(data[0])
+		if err != nil {
+			return "", errors.New("payments.ProcessPACS: " + err.Error())
+		}
+		result, err = sendQueuedCreditTransfers()
+		if err != nil {
+			return "", errors.New("payments.ProcessPACS: " + err.Error())
+		}
+		break
+	default:
+		return "", errors.New("payments.ProcessPACS: PACS message type invalid")
+	}
+
+	return
+}
+
+// checkPeer authenticates another bank by the secret we share with it
+func checkPeer(bankNumber string, secret string) (peer configuration.Peer, err error) {
+	peer, ok := Config.Peers[bankNumber]
+	if !ok || peer.Secret == "" || subtle.ConstantTimeCompare([]byte(peer.Secret), []byte(secret)) != 1 {
+		return configuration.Peer{}, errors.New("payments.checkPeer: Bank not recognised")
+	}
+	return
+}
+
+// localBankNumber treats this bank's own number as a local account
+func localBankNumber(bankNumber string) string {
+	if Config.BankNumber != "" && bankNumber == Config.BankNumber {
+		return ""
+	}
+	return bankNumber
+}
+
+// receiveInterbankCreditTransfer processes a pacs.008.001.05 document from another bank,
+// base64 encoded so it survives the tilde protocol, and reports the outcome of every credit
+// transfer in a pacs.002.001.07 document
+func receiveInterbankCreditTransfer(data []string) (result string, err error) {
+	bankNumber := data[3]
+	_, err = checkPeer(bankNumber, data[4])
+	if err != nil {
+		return "", errors.New("payments.receiveInterbankCreditTransfer: " + err.Error())
+	}
+
+	document, err := base64.StdEncoding.DecodeString(strings.TrimRight(data[5], "\x00"))
+	if err != nil {
+		return "", errors.New("payments.receiveInterbankCreditTransfer: Could not decode document. " + err.Error())
+	}
+
+	transfer, err := iso20022.ParsePacs008(document)
+	if err != nil {
+		return "", errors.New("payments.receiveInterbankCreditTransfer: " + err.Error())
+	}
+
+	report, err := newPacs002(transfer)
+	if err != nil {
+		return "", errors.New("payments.receiveInterbankCreditTransfer: " + err.Error())
+	}
+
+	// A document that fails validation is rejected as a whole, nothing is posted
+	reasonCode, err := transfer.Validate()
+	if err == nil {
+		instructedAgent := transfer.FIToFICstmrCdtTrf.GrpHdr.InstdAgt.ID()
+		if instructedAgent != "" && instructedAgent != Config.BankNumber {
+			reasonCode, err = REASON_INCORRECT_AGENT, errors.New("Instructed agent "+instructedAgent+" is not this bank")
+		}
+	}
+	if err != nil {
+		report.FIToFIPmtStsRpt.OrgnlGrpInfAndSts.GrpSts = iso20022.GROUP_STATUS_REJECTED
+		report.FIToFIPmtStsRpt.OrgnlGrpInfAndSts.StsRsnInf = iso20022.NewStatusReason(reasonCode, err.Error())
+		return iso20022.Marshal(report)
+	}
+
+	// A message delivered twice gets the report sent the first time
+	messageID := transfer.FIToFICstmrCdtTrf.GrpHdr.MsgId
+	previous, found, err := getInboundMessage(bankNumber, messageID)
+	if err != nil {
+		return "", errors.New("payments.receiveInterbankCreditTransfer: " + err.Error())
+	}
+	if found {
+		if previous.Status != MESSAGE_ANSWERED {
+			return "", errors.New("payments.receiveInterbankCreditTransfer: Message " + messageID + " is still being processed")
+		}
+		return previous.Response, nil
+	}
+
+	message := InterbankMessage{
+		MessageID:  messageID,
+		Direction:  MESSAGE_DIRECTION_IN,
+		BankNumber: bankNumber,
+		Status:     MESSAGE_RECEIVED,
+		Document:   string(document),
+		Timestamp:  int32(time.Now().Unix()),
+	}
+	message.ID, err = saveInterbankMessage(Config.Db, message)
+	if err != nil {
+		return "", errors.New("payments.receiveInterbankCreditTransfer: " + err.Error())
+	}
+
+	statuses := []string{}
+	for _, creditTransfer := range transfer.FIToFICstmrCdtTrf.CdtTrfTxInf {
+		transactionStatus := creditTransferFromPacs008(bankNumber, creditTransfer)
+		report.FIToFIPmtStsRpt.TxInfAndSts = append(report.FIToFIPmtStsRpt.TxInfAndSts, transactionStatus)
+		statuses = append(statuses, transactionStatus.TxSts)
+	}
+	report.FIToFIPmtStsRpt.OrgnlGrpInfAndSts.GrpSts = iso20022.GroupStatus(statuses)
+
+	result, err = iso20022.Marshal(report)
+	if err != nil {
+		return "", errors.New("payments.receiveInterbankCreditTransfer: " + err.Error())
+	}
+
+	err = updateInterbankMessage(Config.Db, message.ID, MESSAGE_RECEIVED, MESSAGE_ANSWERED, result)
+	if err != nil {
+		return "", errors.New("payments.receiveInterbankCreditTransfer: " + err.Error())
+	}
+
+	return
+}
+
+func newPacs002(transfer iso20022.Pacs008Document) (report iso20022.Pacs002Document, err error) {
+	newUuid, err := uuid.NewV4()
+	if err != nil {
+		return iso20022.Pacs002Document{}, errors.New("payments.newPacs002: Could not generate message ID. " + err.Error())
+	}
+
+	groupHeader := transfer.FIToFICstmrCdtTrf.GrpHdr
+	report.Xmlns = iso20022.PACS_002_NAMESPACE
+	report.FIToFIPmtStsRpt.GrpHdr = iso20022.StatusGroupHeader{
+		MsgId:   iso20022.MessageID(newUuid.String()),
+		CreDtTm: iso20022.ISODateTime(time.Now()),
+	}
+	report.FIToFIPmtStsRpt.OrgnlGrpInfAndSts = iso20022.OriginalGroupHeader{
+		OrgnlMsgId:   groupHeader.MsgId,
+		OrgnlMsgNmId: iso20022.PACS_008_MESSAGE,
+		OrgnlNbOfTxs: groupHeader.NbOfTxs,
+	}
+	if groupHeader.TtlIntrBkSttlmAmt != nil {
+		report.FIToFIPmtStsRpt.OrgnlGrpInfAndSts.OrgnlCtrlSum = groupHeader.TtlIntrBkSttlmAmt.Value
+	}
+	return
+}
+
+// creditTransferFromPacs008 checks and credits a single incoming credit transfer and reports
+// its status
+func creditTransferFromPacs008(bankNumber string, creditTransfer iso20022.InterbankCreditTransferTransaction) (status iso20022.InterbankPaymentTransactionStatus) {
+	status.OrgnlInstrId = creditTransfer.PmtId.InstrId
+	status.OrgnlEndToEndId = creditTransfer.PmtId.EndToEndId
+	status.OrgnlTxId = creditTransfer.PmtId.TxId
+
+	transaction, reasonCode, err := painTransFromPacs008(bankNumber, creditTransfer)
+	if err != nil {
+		status.TxSts = iso20022.TX_STATUS_REJECTED
+		status.StsRsnInf = iso20022.NewStatusReason(reasonCode, err.Error())
+		return
+	}
+
+	transactionID, reasonCode, err := receiveCreditTransfer(transaction)
+	status.AcctSvcrRef = transactionID
+	if err != nil {
+		if reasonCode == "" {
+			reasonCode = REASON_NARRATIVE
+		}
+		status.TxSts = iso20022.TX_STATUS_REJECTED
+		status.StsRsnInf = iso20022.NewStatusReason(reasonCode, err.Error())
+		return
+	}
+
+	status.TxSts = isoStatusCodes[postedStatus(transaction)]
+	return
+}
+
+// painTransFromPacs008 maps an incoming credit transfer onto a payment from an account at the
+// sending bank to an account at this bank. The sending bank has charged its customer already
+func painTransFromPacs008(bankNumber string, creditTransfer iso20022.InterbankCreditTransferTransaction) (transaction PAINTrans, reasonCode string, err error) {
+	creditorAgent := creditTransfer.CdtrAgt.ID()
+	if creditorAgent != "" && creditorAgent != Config.BankNumber {
+		return PAINTrans{}, REASON_INCORRECT_AGENT, errors.New("payments.painTransFromPacs008: Creditor agent " + creditorAgent + " is not this bank")
+	}
+
+	creditorAccount := ""
+	if creditTransfer.CdtrAcct != nil {
+		creditorAccount = creditTransfer.CdtrAcct.Id.ID()
+	}
+	if creditorAccount == "" {
+		return PAINTrans{}, REASON_INCORRECT_ACCOUNT, errors.New("payments.painTransFromPacs008: Creditor account missing")
+	}
+	debtorAccount := ""
+	if creditTransfer.DbtrAcct != nil {
+		debtorAccount = creditTransfer.DbtrAcct.Id.ID()
+	}
+
+	// The amount has to be in the currency of the creditor account, which is checked when it
+	// is received
+	currency := creditTransfer.IntrBkSttlmAmt.Ccy
+	if !money.ValidCurrency(currency) {
+		return PAINTrans{}, REASON_INVALID_CURRENCY, errors.New("payments.painTransFromPacs008: Currency " + currency + " not supported")
+	}
+
+	transactionAmount, err := money.NewFromString(strings.TrimSpace(creditTransfer.IntrBkSttlmAmt.Value), currency)
+	if err != nil {
+		return PAINTrans{}, REASON_INVALID_AMOUNT, errors.New("payments.painTransFromPacs008: Could not convert transaction amount. " + err.Error())
+	}
+	if transactionAmount.Sign() <= 0 {
+		return PAINTrans{}, REASON_INVALID_AMOUNT, errors.New("payments.painTransFromPacs008: Transaction amount must be positive")
+	}
+
+	transaction = PAINTrans{
+		PainType: 1,
+		Sender:   AccountHolder{debtorAccount, bankNumber},
+		Receiver: AccountHolder{creditorAccount, ""},
+		Amount:   transactionAmount,
+		Fee:      money.Zero(currency),
+		Geo:      *geo.NewPoint(0, 0),
+		Desc:     creditTransfer.Description(),
+		Status:   STATUS_RECEIVED,
+	}
+	return
+}
+
+// receiveCreditTransfer credits an incoming credit transfer. Transfers to accounts that do not
+// exist, are closed or are held in another currency are rejected
+func receiveCreditTransfer(transaction PAINTrans) (result string, reasonCode string, err error) {
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return "", "", errors.New("payments.receiveCreditTransfer: Could not start database transaction. " + err.Error())
+	}
+
+	status, err := getAccountStatusForUpdate(tx, transaction.Receiver.AccountNumber)
+	if err != nil {
+		tx.Rollback()
+		return "", "", errors.New("payments.receiveCreditTransfer: " + err.Error())
+	}
+	if status == "" {
+		transactionID, err := rejectPAINTransaction(tx, transaction, REASON_INCORRECT_ACCOUNT)
+		if err != nil {
+			return "", "", errors.New("payments.receiveCreditTransfer: " + err.Error())
+		}
+		return transactionID, REASON_INCORRECT_ACCOUNT, errors.New("payments.receiveCreditTransfer: Creditor account not found. Transaction " + transactionID + " rejected")
+	}
+	if status == accounts.ACCOUNT_CLOSED {
+		transactionID, err := rejectPAINTransaction(tx, transaction, REASON_CLOSED_ACCOUNT)
+		if err != nil {
+			return "", "", errors.New("payments.receiveCreditTransfer: " + err.Error())
+		}
+		return transactionID, REASON_CLOSED_ACCOUNT, errors.New("payments.receiveCreditTransfer: Creditor account closed. Transaction " + transactionID + " rejected")
+	}
+
+	currency, err := getAccountCurrency(tx, transaction.Receiver.AccountNumber)
+	if err != nil {
+		tx.Rollback()
+		return "", "", errors.New("payments.receiveCreditTransfer: " + err.Error())
+	}
+	if !currencyAccepted(currency, transaction.Amount) {
+		transactionID, err := rejectPAINTransaction(tx, transaction, REASON_INVALID_CURRENCY)
+		if err != nil {
+			return "", "", errors.New("payments.receiveCreditTransfer: " + err.Error())
+		}
+		return transactionID, REASON_INVALID_CURRENCY, errors.New("payments.receiveCreditTransfer: Creditor account does not hold " + transaction.Amount.Currency + ". Transaction " + transactionID + " rejected")
+	}
+
+	result, err = processPAINTransaction(tx, transaction)
+	if err != nil {
+		return "", "", errors.New("payments.receiveCreditTransfer: " + err.Error())
+	}
+
+	go statements.PushNotification(transaction.Receiver.AccountNumber, result, "💸 Payment received!")
+
+	return
+}
+
+// queueCreditTransfer stores the pacs.008 for a payment to another bank inside tx, so the
+// message is only queued if the payment is posted
+func queueCreditTransfer(tx *sql.Tx, transaction PAINTrans, sqlTime int32) (err error) {
+	if Config.BankNumber == "" {
+		return errors.New("payments.queueCreditTransfer: Bank number not configured")
+	}
+	if _, ok := Config.Peers[transaction.Receiver.BankNumber]; !ok {
+		return errors.New("payments.queueCreditTransfer: No peer configured for bank " + transaction.Receiver.BankNumber)
+	}
+
+	newUuid, err := uuid.NewV4()
+	if err != nil {
+		return errors.New("payments.queueCreditTransfer: Could not generate message ID. " + err.Error())
+	}
+	messageID := iso20022.MessageID(newUuid.String())
+
+	document, err := iso20022.Marshal(pacs008FromTransaction(transaction, messageID, time.Unix(int64(sqlTime), 0)))
+	if err != nil {
+		return errors.New("payments.queueCreditTransfer: " + err.Error())
+	}
+
+	message := InterbankMessage{
+		MessageID:     messageID,
+		Direction:     MESSAGE_DIRECTION_OUT,
+		BankNumber:    transaction.Receiver.BankNumber,
+		TransactionID: transaction.ID,
+		Status:        MESSAGE_QUEUED,
+		Document:      document,
+		Timestamp:     sqlTime,
+	}
+	_, err = saveInterbankMessage(tx, message)
+	if err != nil {
+		return errors.New("payments.queueCreditTransfer: " + err.Error())
+	}
+
+	return
+}
+
+// pacs008FromTransaction builds the credit transfer for a payment to another bank. The
+// transaction ID identifies the transfer end to end
+func pacs008FromTransaction(transaction PAINTrans, messageID string, created time.Time) (document iso20022.Pacs008Document) {
+	amount := iso20022.ActiveOrHistoricCurrencyAndAmount{Value: transaction.Amount.StringFixed(), Ccy: transaction.Amount.Currency}
+	reference := strconv.FormatInt(int64(transaction.ID), 10)
+
+	document.Xmlns = iso20022.PACS_008_NAMESPACE
+	document.FIToFICstmrCdtTrf.GrpHdr = iso20022.InterbankGroupHeader{
+		MsgId:             messageID,
+		CreDtTm:           iso20022.ISODateTime(created),
+		NbOfTxs:           "1",
+		TtlIntrBkSttlmAmt: &amount,
+		IntrBkSttlmDt:     iso20022.ISODate(created),
+		SttlmInf:          iso20022.SettlementInstruction{SttlmMtd: iso20022.SETTLEMENT_INSTRUCTED_AGENT},
+		InstgAgt:          iso20022.NewAgent(Config.BankNumber),
+		InstdAgt:          iso20022.NewAgent(transaction.Receiver.BankNumber),
+	}
+
+	creditTransfer := iso20022.InterbankCreditTransferTransaction{
+		PmtId:          iso20022.InterbankPaymentIdentification{InstrId: reference, EndToEndId: reference, TxId: reference},
+		IntrBkSttlmAmt: amount,
+		ChrgBr:         iso20022.CHARGES_SERVICE_LEVEL,
+		DbtrAcct:       iso20022.NewAccount(transaction.Sender.AccountNumber, ""),
+		DbtrAgt:        iso20022.NewAgent(Config.BankNumber),
+		CdtrAgt:        iso20022.NewAgent(transaction.Receiver.BankNumber),
+		CdtrAcct:       iso20022.NewAccount(transaction.Receiver.AccountNumber, ""),
+	}
+	if transaction.Desc != "" {
+		creditTransfer.RmtInf = &iso20022.RemittanceInformation{Ustrd: []string{transaction.Desc}}
+	}
+	document.FIToFICstmrCdtTrf.CdtTrfTxInf = []iso20022.InterbankCreditTransferTransaction{creditTransfer}
+
+	return
+}
+
+// sendCreditTransfer sends the queued pacs.008 of a payment to its peer bank
+func sendCreditTransfer(transactionID string) (message InterbankMessage, err error) {
+	message, err = getOutboundMessage(transactionID)
+	if err != nil {
+		return InterbankMessage{}, errors.New("payments.sendCreditTransfer: " + err.Error())
+	}
+
+	message, err = deliverCreditTransfer(message)
+	if err != nil {
+		return message, errors.New("payments.sendCreditTransfer: " + err.Error())
+	}
+	return
+}
+
+// sendQueuedCreditTransfers sends every queued pacs.008 again. Transfers that fail to send
+// stay queued
+func sendQueuedCreditTransfers() (messages []InterbankMessage, err error) {
+	queued, err := getQueuedMessages()
+	if err != nil {
+		return []InterbankMessage{}, errors.New("payments.sendQueuedCreditTransfers: " + err.Error())
+	}
+
+	for _, message := range queued {
+		message, _ = deliverCreditTransfer(message)
+		// The documents are only needed by the banks
+		message.Document = ""
+		message.Response = ""
+		messages = append(messages, message)
+	}
+
+	return
+}
+
+// deliverCreditTransfer posts a queued pacs.008 to the peer bank and applies the pacs.002 it
+// answers with
+func deliverCreditTransfer(message InterbankMessage) (InterbankMessage, error) {
+	if message.Status != MESSAGE_QUEUED {
+		return message, nil
+	}
+
+	peer, ok := Config.Peers[message.BankNumber]
+	if !ok {
+		return message, errors.New("payments.deliverCreditTransfer: No peer configured for bank " + message.BankNumber)
+	}
+
+	err := incrementMessageAttempts(message.ID)
+	if err != nil {
+		return message, errors.New("payments.deliverCreditTransfer: " + err.Error())
+	}
+	message.Attempts++
+
+	response, err := postToPeer(peer, "/interbank/pacs008", message.Document)
+	if err != nil {
+		return message, errors.New("payments.deliverCreditTransfer: " + err.Error())
+	}
+
+	report, err := iso20022.ParsePacs002(response)
+	if err != nil {
+		return message, errors.New("payments.deliverCreditTransfer: " + err.Error())
+	}
+	if report.FIToFIPmtStsRpt.OrgnlGrpInfAndSts.OrgnlMsgId != message.MessageID {
+		return message, errors.New("payments.deliverCreditTransfer: Status report is for message " + report.FIToFIPmtStsRpt.OrgnlGrpInfAndSts.OrgnlMsgId)
+	}
+
+	txStatus, reasonCode := report.TransactionStatus(strconv.FormatInt(int64(message.TransactionID), 10))
+	message, err = completeCreditTransfer(message, txStatus, reasonCode, string(response))
+	if err != nil {
+		return message, errors.New("payments.deliverCreditTransfer: " + err.Error())
+	}
+	return message, nil
+}
+
+// postToPeer sends a document to the HTTP API of a peer bank and returns its answer
+func postToPeer(peer configuration.Peer, path string, document string) (response []byte, err error) {
+	client := &http.Client{
+		Timeout:   PEER_TIMEOUT,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: peer.InsecureSkipVerify}},
+	}
+
+	request, err := http.NewRequest("POST", strings.TrimRight(peer.Endpoint, "/")+path, strings.NewReader(document))
+	if err != nil {
+		return nil, errors.New("payments.postToPeer: " + err.Error())
+	}
+	request.Header.Set("Content-Type", "application/xml; charset=UTF-8")
+	request.Header.Set("X-Bank-Number", Config.BankNumber)
+	request.Header.Set("X-Bank-Secret", peer.Secret)
+
+	resp, err := client.Do(request)
+	if err != nil {
+		return nil, errors.New("payments.postToPeer: " + err.Error())
+	}
+	defer resp.Body.Close()
+
+	response, err = ioutil.ReadAll(io.LimitReader(resp.Body, MAX_PEER_RESPONSE_SIZE))
+	if err != nil {
+		return nil, errors.New("payments.postToPeer: Could not read response. " + err.Error())
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("payments.postToPeer: Peer answered " + resp.Status + ". " + string(response))
+	}
+
+	return
+}
+
+// completeCreditTransfer settles or rejects a payment once the peer bank has given a final
+// status. Any other status leaves the transfer queued
+func completeCreditTransfer(message InterbankMessage, txStatus string, reasonCode string, response string) (InterbankMessage, error) {
+	newStatus := ""
+	switch txStatus {
+	case iso20022.TX_STATUS_SETTLED:
+		newStatus = MESSAGE_SETTLED
+	case iso20022.TX_STATUS_REJECTED:
+		newStatus = MESSAGE_REJECTED
+	default:
+		return message, nil
+	}
+
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return message, errors.New("payments.completeCreditTransfer: Could not start database transaction. " + err.Error())
+	}
+
+	// Another delivery of the same message may have finished first
+	current, err := getInterbankMessageForUpdate(tx, message.ID)
+	if err != nil {
+		tx.Rollback()
+		return message, errors.New("payments.completeCreditTransfer: " + err.Error())
+	}
+	if current.Status != MESSAGE_QUEUED {
+		tx.Rollback()
+		return current, nil
+	}
+
+	transaction, err := getTransactionForUpdate(tx, message.TransactionID)
+	if err != nil {
+		tx.Rollback()
+		return message, errors.New("payments.completeCreditTransfer: " + err.Error())
+	}
+
+	if newStatus == MESSAGE_SETTLED {
+		err = settleCreditTransfer(tx, transaction)
+	} else {
+		if reasonCode == "" {
+			reasonCode = REASON_NARRATIVE
+		}
+		err = returnCreditTransfer(tx, transaction, reasonCode)
+	}
+	if err != nil {
+		tx.Rollback()
+		return message, errors.New("payments.completeCreditTransfer: " + err.Error())
+	}
+
+	err = updateInterbankMessage(tx, message.ID, MESSAGE_QUEUED, newStatus, response)
+	if err != nil {
+		tx.Rollback()
+		return message, errors.New("payments.completeCreditTransfer: " + err.Error())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return message, errors.New("payments.completeCreditTransfer: Could not commit transaction. " + err.Error())
+	}
+
+	if newStatus == MESSAGE_REJECTED {
+		go statements.PushNotification(transaction.Sender.AccountNumber, strconv.FormatInt(int64(transaction.ID), 10), "💸 Payment returned!")
+	}
+
+	message.Status = newStatus
+	message.Response = response
+	return message, nil
+}
+
+// settleCreditTransfer moves a payment the peer bank has credited out of suspense
+func settleCreditTransfer(tx *sql.Tx, transaction PAINTrans) (err error) {
+	_, err = ledger.PostJournal(tx, settlementJournalEntry(transaction))
+	if err != nil {
+		return errors.New("payments.settleCreditTransfer: " + err.Error())
+	}
+
+	err = setTransactionStatus(tx, transaction.ID, STATUS_ACCEPTED, STATUS_SETTLED, "")
+	if err != nil {
+		return errors.New("payments.settleCreditTransfer: " + err.Error())
+	}
+	return
+}
+
+// returnCreditTransfer gives the sender back a payment the peer bank rejected, fees included
+func returnCreditTransfer(tx *sql.Tx, transaction PAINTrans, reasonCode string) (err error) {
+	sqlTime := int32(time.Now().Unix())
+
+	// Each fee is given back from the income account it was credited to
+	transaction.Fees, err = fees.GetItems(tx, int64(transaction.ID))
+	if err != nil {
+		return errors.New("payments.returnCreditTransfer: " + err.Error())
+	}
+	transaction.Fee = fees.Total(transaction.Fees, transaction.Amount.Currency)
+	refund := transaction.Amount.Add(transaction.Fee)
+
+	err = refundSender(tx, transaction.Sender, refund, sqlTime)
+	if err != nil {
+		return errors.New("payments.returnCreditTransfer: " + err.Error())
+	}
+
+	entry := returnJournalEntry(transaction)
+	entry.Timestamp = sqlTime
+	_, err = ledger.PostJournal(tx, entry)
+	if err != nil {
+		return errors.New("payments.returnCreditTransfer: " + err.Error())
+	}
+
+	_, err = statements.SaveNotification(tx, transaction.Sender.AccountNumber, int64(transaction.ID), iso20022.CREDIT, refund, sqlTime)
+	if err != nil {
+		return errors.New("payments.returnCreditTransfer: " + err.Error())
+	}
+
+	err = setTransactionStatus(tx, transaction.ID, STATUS_ACCEPTED, STATUS_REJECTED, reasonCode)
+	if err != nil {
+		return errors.New("payments.returnCreditTransfer: " + err.Error())
+	}
+	return
+}
+
+// settlementJournalEntry books a settled payment against the receiving bank's clearing account
+func settlementJournalEntry(transaction PAINTrans) (entry ledger.JournalEntry) {
+	entry.TransactionID = int64(transaction.ID)
+	entry.Desc = "pacs~2 settled " + transaction.Desc
+	entry.Lines = append(entry.Lines, ledger.Debit(ledger.SUSPENSE, transaction.Amount.Amount)...)
+	entry.Lines = append(entry.Lines, ledger.Credit(ledger.ClearingAccount(transaction.Receiver.BankNumber), transaction.Amount.Amount)...)
+	return
+}
+
+// returnJournalEntry reverses the posting of a payment the receiving bank rejected
+func returnJournalEntry(transaction PAINTrans) (entry ledger.JournalEntry) {
+	entry.TransactionID = int64(transaction.ID)
+	entry.Desc = "pacs~2 returned " + transaction.Desc
+	entry.Lines = append(entry.Lines, ledger.Debit(ledger.SUSPENSE, transaction.Amount.Amount)...)
+	entry.Lines = append(entry.Lines, feeIncomeLines(transaction.Fees, true)...)
+	entry.Lines = append(entry.Lines, ledger.Credit(ledgerAccountFor(transaction.Sender), transaction.Amount.Add(transaction.Fee).Amount)...)
+	return
+}
@@ -0,0 +1,249 @@
+package transactions
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvnk/bank/configuration"
+	"github.com/bvnk/bank/iso20022"
+	"github.com/bvnk/bank/ledger"
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
+
+func setPeerConfig() {
+	Config.BankNumber = "this-bank"
+	Config.Peers = map[string]configuration.Peer{
+		"other-bank": configuration.Peer{Endpoint: "https://other-bank.example.com:8443", Secret: "shared-secret"},
+	}
+}
+
+func TestProcessPACS(t *testing.T) {
+	setPeerConfig()
+
+	data := []string{"", ""}
+	_, err := ProcessPACS(data)
+	if err == nil {
+		t.Errorf("ProcessPACS does not pass. Looking for %v, got %v", "Not all data is present. Run pacs~help to check for needed PACS data", nil)
+	}
+
+	data = []string{"", "", "not integer"}
+	_, err = ProcessPACS(data)
+	if err == nil {
+		t.Errorf("ProcessPACS CheckMessageType does not pass. Looking for %v, got %v", "Could not get type of PACS message", nil)
+	}
+
+	data = []string{"", "", "9"}
+	_, err = ProcessPACS(data)
+	if err == nil || !strings.Contains(err.Error(), "PACS message type invalid") {
+		t.Errorf("ProcessPACS PacsType9 does not pass. Looking for %v, got %v", "PACS message type invalid", err)
+	}
+
+	data = []string{"", "", "8", "other-bank"}
+	_, err = ProcessPACS(data)
+	if err == nil {
+		t.Errorf("ProcessPACS PacsType8 does not pass. Looking for %v, got %v", "Not all data is present.", nil)
+	}
+
+	data = []string{"", "", "8", "other-bank", "wrong-secret", ""}
+	_, err = ProcessPACS(data)
+	if err == nil {
+		t.Errorf("ProcessPACS PacsType8 secret does not pass. Looking for %v, got %v", "Bank not recognised", nil)
+	}
+
+	data = []string{"", "", "8", "unknown-bank", "shared-secret", ""}
+	_, err = ProcessPACS(data)
+	if err == nil {
+		t.Errorf("ProcessPACS PacsType8 bank does not pass. Looking for %v, got %v", "Bank not recognised", nil)
+	}
+}
+
+func TestReceiveInterbankCreditTransferRejected(t *testing.T) {
+	setPeerConfig()
+
+	document, err := ioutil.ReadFile("../iso20022/testdata/pacs.008.001.05.xml")
+	if err != nil {
+		t.Fatalf("ReceiveInterbankCreditTransfer does not pass. Could not read fixture. Looking for %v, got %v", nil, err)
+	}
+
+	// Documents for another bank are rejected as a whole before anything is stored
+	Config.BankNumber = "third-bank"
+	result, err := receiveInterbankCreditTransfer([]string{"", "pacs", "8", "other-bank", "shared-secret", base64.StdEncoding.EncodeToString(document)})
+	if err != nil {
+		t.Fatalf("ReceiveInterbankCreditTransfer does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	report, err := iso20022.ParsePacs002([]byte(result))
+	if err != nil {
+		t.Fatalf("ReceiveInterbankCreditTransfer does not pass. Looking for %v, got %v", nil, err)
+	}
+	status, reasonCode := report.TransactionStatus("101")
+	if status != iso20022.GROUP_STATUS_REJECTED || reasonCode != REASON_INCORRECT_AGENT {
+		t.Errorf("ReceiveInterbankCreditTransfer does not pass. Looking for %v, got %v %v", iso20022.GROUP_STATUS_REJECTED+" "+REASON_INCORRECT_AGENT, status, reasonCode)
+	}
+	if report.FIToFIPmtStsRpt.OrgnlGrpInfAndSts.OrgnlMsgId != "PACS-20160315-0001" {
+		t.Errorf("ReceiveInterbankCreditTransfer does not pass. Looking for %v, got %v", "PACS-20160315-0001", report.FIToFIPmtStsRpt.OrgnlGrpInfAndSts.OrgnlMsgId)
+	}
+}
+
+func TestPainTransFromPacs008(t *testing.T) {
+	setPeerConfig()
+
+	document, err := ioutil.ReadFile("../iso20022/testdata/pacs.008.001.05.xml")
+	if err != nil {
+		t.Fatalf("PainTransFromPacs008 does not pass. Could not read fixture. Looking for %v, got %v", nil, err)
+	}
+	doc, err := iso20022.ParsePacs008(document)
+	if err != nil {
+		t.Fatalf("PainTransFromPacs008 does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	creditTransfer := doc.FIToFICstmrCdtTrf.CdtTrfTxInf[0]
+	transaction, _, err := painTransFromPacs008("other-bank", creditTransfer)
+	if err != nil {
+		t.Fatalf("PainTransFromPacs008 does not pass. Looking for %v, got %v", nil, err)
+	}
+	if transaction.Sender.BankNumber != "other-bank" || transaction.Receiver.AccountNumber != "181ac0ae-45cb-461d-b740-15ce33e4612f" || transaction.Receiver.BankNumber != "" {
+		t.Errorf("PainTransFromPacs008 does not pass. Looking for %v, got %v", "other-bank to local account", transaction)
+	}
+	if transaction.Amount.StringFixed() != "150.25" || !transaction.Fee.IsZero() {
+		t.Errorf("PainTransFromPacs008 does not pass. Looking for %v, got %v", "150.25 fee 0.00", transaction.Amount.StringFixed()+" fee "+transaction.Fee.StringFixed())
+	}
+	if postedStatus(transaction) != STATUS_SETTLED {
+		t.Errorf("PainTransFromPacs008 does not pass. Looking for %v, got %v", STATUS_SETTLED, postedStatus(transaction))
+	}
+
+	// Creditor agent is another bank
+	wrongAgent := creditTransfer
+	wrongAgent.CdtrAgt = iso20022.NewAgent("third-bank")
+	_, reasonCode, err := painTransFromPacs008("other-bank", wrongAgent)
+	if err == nil || reasonCode != REASON_INCORRECT_AGENT {
+		t.Errorf("PainTransFromPacs008 agent does not pass. Looking for %v, got %v", REASON_INCORRECT_AGENT, reasonCode)
+	}
+
+	// Other currencies are checked against the creditor account when received
+	otherCurrency := creditTransfer
+	otherCurrency.IntrBkSttlmAmt.Ccy = "EUR"
+	transaction, _, err = painTransFromPacs008("other-bank", otherCurrency)
+	if err != nil || transaction.Amount.Currency != "EUR" || transaction.Fee.Currency != "EUR" {
+		t.Errorf("PainTransFromPacs008 other currency does not pass. Looking for %v, got %v %v", "EUR", transaction.Amount, err)
+	}
+
+	// Currency not valid
+	wrongCurrency := creditTransfer
+	wrongCurrency.IntrBkSttlmAmt.Ccy = "XYZ"
+	_, reasonCode, err = painTransFromPacs008("other-bank", wrongCurrency)
+	if err == nil || reasonCode != REASON_INVALID_CURRENCY {
+		t.Errorf("PainTransFromPacs008 currency does not pass. Looking for %v, got %v", REASON_INVALID_CURRENCY, reasonCode)
+	}
+
+	// Amount not valid
+	wrongAmount := creditTransfer
+	wrongAmount.IntrBkSttlmAmt.Value = "0"
+	_, reasonCode, err = painTransFromPacs008("other-bank", wrongAmount)
+	if err == nil || reasonCode != REASON_INVALID_AMOUNT {
+		t.Errorf("PainTransFromPacs008 amount does not pass. Looking for %v, got %v", REASON_INVALID_AMOUNT, reasonCode)
+	}
+}
+
+func TestPacs008FromTransaction(t *testing.T) {
+	setPeerConfig()
+
+	amount := money.New(decimal.New(2050, -2), money.DEFAULT_CURRENCY)
+	trans := withFee(PAINTrans{ID: 42, PainType: 1, Sender: AccountHolder{"accountNumSender", ""}, Receiver: AccountHolder{"accountNumReceiver", "other-bank"}, Amount: amount, Desc: "Rent"})
+
+	result, err := iso20022.Marshal(pacs008FromTransaction(trans, "MSG", time.Date(2016, 3, 15, 10, 30, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatalf("Pacs008FromTransaction does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	expected := []string{
+		`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pacs.008.001.05">`,
+		`<MsgId>MSG</MsgId>`,
+		`<TtlIntrBkSttlmAmt Ccy="USD">20.50</TtlIntrBkSttlmAmt>`,
+		`<SttlmMtd>INDA</SttlmMtd>`,
+		`<TxId>42</TxId>`,
+		`<Id>this-bank</Id>`,
+		`<Id>other-bank</Id>`,
+		`<Id>accountNumReceiver</Id>`,
+		`<Ustrd>Rent</Ustrd>`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(result, e) {
+			t.Errorf("Pacs008FromTransaction does not pass. Looking for %v, got %v", e, result)
+		}
+	}
+
+	// The receiving bank reads what we send
+	doc, err := iso20022.ParsePacs008([]byte(result))
+	if err != nil {
+		t.Fatalf("Pacs008FromTransaction does not pass. Looking for %v, got %v", nil, err)
+	}
+	reasonCode, err := doc.Validate()
+	if err != nil {
+		t.Errorf("Pacs008FromTransaction does not pass. Looking for %v, got %v %v", nil, reasonCode, err)
+	}
+}
+
+func TestJournalEntryForTransactionRemoteSender(t *testing.T) {
+	sender := AccountHolder{"accountNumSender", "other-bank"}
+	receiver := AccountHolder{"accountNumReceiver", ""}
+	amount := money.New(decimal.New(20, 0), money.DEFAULT_CURRENCY)
+	trans := PAINTrans{ID: 1, PainType: 1, Sender: sender, Receiver: receiver, Amount: amount, Fee: money.Zero(money.DEFAULT_CURRENCY)}
+
+	entry := journalEntryForTransaction(trans)
+	err := entry.Validate()
+	if err != nil {
+		t.Errorf("JournalEntryForTransaction remote sender does not pass. Looking for %v, got %v", nil, err)
+	}
+	if entry.Lines[0].LedgerAccount != ledger.ClearingAccount("other-bank") || entry.Lines[0].Direction != ledger.DEBIT {
+		t.Errorf("JournalEntryForTransaction remote sender does not pass. Looking for %v, got %v", "debit "+ledger.ClearingAccount("other-bank"), entry.Lines[0])
+	}
+}
+
+func TestSettlementJournalEntry(t *testing.T) {
+	sender := AccountHolder{"accountNumSender", ""}
+	receiver := AccountHolder{"accountNumReceiver", "other-bank"}
+	amount := money.New(decimal.New(20, 0), money.DEFAULT_CURRENCY)
+	trans := withFee(PAINTrans{ID: 1, PainType: 1, Sender: sender, Receiver: receiver, Amount: amount})
+
+	entry := settlementJournalEntry(trans)
+	err := entry.Validate()
+	if err != nil {
+		t.Errorf("SettlementJournalEntry does not pass. Looking for %v, got %v", nil, err)
+	}
+	if entry.Lines[0].LedgerAccount != ledger.SUSPENSE || entry.Lines[1].LedgerAccount != ledger.ClearingAccount("other-bank") {
+		t.Errorf("SettlementJournalEntry does not pass. Looking for %v, got %v", "suspense to clearing", entry.Lines)
+	}
+}
+
+func TestReturnJournalEntry(t *testing.T) {
+	sender := AccountHolder{"accountNumSender", ""}
+	receiver := AccountHolder{"accountNumReceiver", "other-bank"}
+	amount := money.New(decimal.New(20, 0), money.DEFAULT_CURRENCY)
+	trans := withFee(PAINTrans{ID: 1, PainType: 1, Sender: sender, Receiver: receiver, Amount: amount})
+
+	// Posting and returning a payment leaves every account where it started
+	balances := map[string]decimal.Decimal{}
+	for _, entry := range []ledger.JournalEntry{journalEntryForTransaction(trans), returnJournalEntry(trans)} {
+		err := entry.Validate()
+		if err != nil {
+			t.Errorf("ReturnJournalEntry does not pass. Looking for %v, got %v", nil, err)
+		}
+		for _, line := range entry.Lines {
+			if line.Direction == ledger.DEBIT {
+				balances[line.LedgerAccount] = balances[line.LedgerAccount].Add(line.Amount)
+			} else {
+				balances[line.LedgerAccount] = balances[line.LedgerAccount].Sub(line.Amount)
+			}
+		}
+	}
+	for ledgerAccount, balance := range balances {
+		if !balance.IsZero() {
+			t.Errorf("ReturnJournalEntry does not pass. Looking for %v, got %v %v", 0, ledgerAccount, balance)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package transactions
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bvnk/bank/accounts"
+	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/iso20022"
+	"github.com/bvnk/bank/money"
+	"github.com/paulmach/go.geo"
+	"github.com/satori/go.uuid"
+)
+
+// customerCreditTransferInitiationXML processes a pain.001.001.06 document, base64 encoded so
+// it survives the tilde protocol, and reports the outcome of every credit transfer in a
+// pain.002.001.07 document. Each credit transfer is checked and posted on its own, as if it
+// had been sent as pain~1
+func customerCreditTransferInitiationXML(data []string) (result string, err error) {
+	document, err := base64.StdEncoding.DecodeString(strings.TrimRight(data[3], "\x00"))
+	if err != nil {
+		return "", errors.New("payments.customerCreditTransferInitiationXML: Could not decode document. " + err.Error())
+	}
+
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("payments.customerCreditTransferInitiationXML: " + err.Error())
+	}
+
+	initiation, err := iso20022.ParsePain001(document)
+	if err != nil {
+		return "", errors.New("payments.customerCreditTransferInitiationXML: " + err.Error())
+	}
+
+	report, err := newPain002(initiation)
+	if err != nil {
+		return "", errors.New("payments.customerCreditTransferInitiationXML: " + err.Error())
+	}
+
+	// A document that fails validation is rejected as a whole
+	reasonCode, err := initiation.Validate()
+	if err != nil {
+		report.CstmrPmtStsRpt.OrgnlGrpInfAndSts.GrpSts = iso20022.GROUP_STATUS_REJECTED
+		report.CstmrPmtStsRpt.OrgnlGrpInfAndSts.StsRsnInf = iso20022.NewStatusReason(reasonCode, err.Error())
+		return iso20022.Marshal(report)
+	}
+
+	allStatuses := []string{}
+	for _, paymentInfo := range initiation.CstmrCdtTrfInitn.PmtInf {
+		paymentStatus := iso20022.OriginalPaymentInstructionAndStatus{OrgnlPmtInfId: paymentInfo.PmtInfId}
+		statuses := []string{}
+		for _, creditTransfer := range paymentInfo.CdtTrfTxInf {
+			transactionStatus := creditTransferFromPain001(tokenUser, paymentInfo, creditTransfer)
+			paymentStatus.TxInfAndSts = append(paymentStatus.TxInfAndSts, transactionStatus)
+			statuses = append(statuses, transactionStatus.TxSts)
+		}
+		paymentStatus.PmtInfSts = iso20022.GroupStatus(statuses)
+		report.CstmrPmtStsRpt.OrgnlPmtInfAndSts = append(report.CstmrPmtStsRpt.OrgnlPmtInfAndSts, paymentStatus)
+		allStatuses = append(allStatuses, statuses...)
+	}
+	report.CstmrPmtStsRpt.OrgnlGrpInfAndSts.GrpSts = iso20022.GroupStatus(allStatuses)
+
+	return iso20022.Marshal(report)
+}
+
+func newPain002(initiation iso20022.Pain001Document) (report iso20022.Pain002Document, err error) {
+	newUuid, err := uuid.NewV4()
+	if err != nil {
+		return iso20022.Pain002Document{}, errors.New("payments.newPain002: Could not generate message ID. " + err.Error())
+	}
+
+	report.Xmlns = iso20022.PAIN_002_NAMESPACE
+	report.CstmrPmtStsRpt.GrpHdr = iso20022.StatusGroupHeader{
+		MsgId:   iso20022.MessageID(newUuid.String()),
+		CreDtTm: iso20022.ISODateTime(time.Now()),
+	}
+	report.CstmrPmtStsRpt.OrgnlGrpInfAndSts = iso20022.OriginalGroupHeader{
+		OrgnlMsgId:   initiation.CstmrCdtTrfInitn.GrpHdr.MsgId,
+		OrgnlMsgNmId: iso20022.PAIN_001_MESSAGE,
+		OrgnlNbOfTxs: initiation.CstmrCdtTrfInitn.GrpHdr.NbOfTxs,
+		OrgnlCtrlSum: initiation.CstmrCdtTrfInitn.GrpHdr.CtrlSum,
+	}
+	return
+}
+
+// creditTransferFromPain001 checks and posts a single credit transfer and reports its status
+func creditTransferFromPain001(tokenUser string, paymentInfo iso20022.PaymentInstruction, creditTransfer iso20022.CreditTransferTransaction) (status iso20022.PaymentTransactionStatus) {
+	status.OrgnlInstrId = creditTransfer.PmtId.InstrId
+	status.OrgnlEndToEndId = creditTransfer.PmtId.EndToEndId
+
+	transaction, reasonCode, err := painTransFromPain001(paymentInfo, creditTransfer)
+	if err != nil {
+		status.TxSts = iso20022.TX_STATUS_REJECTED
+		status.StsRsnInf = iso20022.NewStatusReason(reasonCode, err.Error())
+		return
+	}
+
+	err = accounts.CheckUserAccountValidFromToken(tokenUser, transaction.Sender.AccountNumber)
+	if err != nil {
+		status.TxSts = iso20022.TX_STATUS_REJECTED
+		status.StsRsnInf = iso20022.NewStatusReason(REASON_TRANSACTION_FORBIDDEN, "Debtor account not valid")
+		return
+	}
+
+	transactionID, reasonCode, err := initiateCreditTransfer(transaction)
+	status.AcctSvcrRef = transactionID
+	if err != nil {
+		if reasonCode == "" {
+			reasonCode = REASON_NARRATIVE
+		}
+		status.TxSts = iso20022.TX_STATUS_REJECTED
+		status.StsRsnInf = iso20022.NewStatusReason(reasonCode, err.Error())
+		return
+	}
+
+	status.TxSts = isoStatusCodes[postedStatus(transaction)]
+	return
+}
+
+// painTransFromPain001 maps a credit transfer onto a payment. The debtor is always an account
+// at this bank; a creditor without an agent is too
+func painTransFromPain001(paymentInfo iso20022.PaymentInstruction, creditTransfer iso20022.CreditTransferTransaction) (transaction PAINTrans, reasonCode string, err error) {
+	creditorAccount := creditTransfer.CdtrAcct.Id.ID()
+	if creditorAccount == "" {
+		return PAINTrans{}, REASON_INCORRECT_ACCOUNT, errors.New("payments.painTransFromPain001: Creditor account missing")
+	}
+
+	// The amount has to be in the currency of the accounts, which is checked when it is posted
+	currency := creditTransfer.Amt.InstdAmt.Ccy
+	if !money.ValidCurrency(currency) || (paymentInfo.DbtrAcct.Ccy != "" && paymentInfo.DbtrAcct.Ccy != currency) {
+		return PAINTrans{}, REASON_INVALID_CURRENCY, errors.New("payments.painTransFromPain001: Currency " + currency + " not supported")
+	}
+
+	transactionAmount, err := money.NewFromString(strings.TrimSpace(creditTransfer.Amt.InstdAmt.Value), currency)
+	if err != nil {
+		return PAINTrans{}, REASON_INVALID_AMOUNT, errors.New("payments.painTransFromPain001: Could not convert transaction amount. " + err.Error())
+	}
+	if transactionAmount.Sign() <= 0 {
+		return PAINTrans{}, REASON_INVALID_AMOUNT, errors.New("payments.painTransFromPain001: Transaction amount must be positive")
+	}
+
+	transaction = PAINTrans{
+		PainType: 1,
+		Sender:   AccountHolder{paymentInfo.DbtrAcct.Id.ID(), ""},
+		Receiver: AccountHolder{creditorAccount, localBankNumber(creditTransfer.CdtrAgt.ID())},
+		Amount:   transactionAmount,
+		Fee:      money.Zero(currency),
+		Geo:      *geo.NewPoint(0, 0),
+		Desc:     creditTransfer.Description(),
+		Status:   STATUS_RECEIVED,
+	}
+	return
+}
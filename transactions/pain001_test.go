@@ -0,0 +1,88 @@
+package transactions
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"testing"
+
+	"github.com/bvnk/bank/iso20022"
+)
+
+func TestPainTransFromPain001(t *testing.T) {
+	document, err := ioutil.ReadFile("../iso20022/testdata/pain.001.001.06.xml")
+	if err != nil {
+		t.Fatalf("PainTransFromPain001 does not pass. Could not read fixture. Looking for %v, got %v", nil, err)
+	}
+	doc, err := iso20022.ParsePain001(document)
+	if err != nil {
+		t.Fatalf("PainTransFromPain001 does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	paymentInfo := doc.CstmrCdtTrfInitn.PmtInf[0]
+	transaction, _, err := painTransFromPain001(paymentInfo, paymentInfo.CdtTrfTxInf[0])
+	if err != nil {
+		t.Fatalf("PainTransFromPain001 does not pass. Looking for %v, got %v", nil, err)
+	}
+	if transaction.Sender.AccountNumber != "1b2ca241-0373-4610-abad-da7b06c50a7b" || transaction.Sender.BankNumber != "" {
+		t.Errorf("PainTransFromPain001 does not pass. Looking for %v, got %v", "1b2ca241-0373-4610-abad-da7b06c50a7b@", transaction.Sender)
+	}
+	// Fees are assessed when the transfer is initiated
+	if transaction.Amount.StringFixed() != "150.25" || !transaction.Fee.IsZero() {
+		t.Errorf("PainTransFromPain001 does not pass. Looking for %v, got %v", "150.25 fee 0.00", transaction.Amount.StringFixed()+" fee "+transaction.Fee.StringFixed())
+	}
+	if transaction.Desc != "Invoice 1001 March" {
+		t.Errorf("PainTransFromPain001 does not pass. Looking for %v, got %v", "Invoice 1001 March", transaction.Desc)
+	}
+
+	// Creditor at another bank
+	transaction, _, err = painTransFromPain001(paymentInfo, paymentInfo.CdtTrfTxInf[1])
+	if err != nil {
+		t.Fatalf("PainTransFromPain001 does not pass. Looking for %v, got %v", nil, err)
+	}
+	if transaction.Receiver.BankNumber != "other-bank" || postedStatus(transaction) != STATUS_ACCEPTED {
+		t.Errorf("PainTransFromPain001 remote does not pass. Looking for %v, got %v", "other-bank", transaction.Receiver.BankNumber)
+	}
+
+	// Other currencies are carried through to be checked against the accounts
+	paymentInfo = doc.CstmrCdtTrfInitn.PmtInf[1]
+	transaction, _, err = painTransFromPain001(paymentInfo, paymentInfo.CdtTrfTxInf[0])
+	if err != nil || transaction.Amount.Currency != "EUR" {
+		t.Errorf("PainTransFromPain001 currency does not pass. Looking for %v, got %v", "EUR", transaction.Amount.Currency)
+	}
+
+	// Currency not supported
+	creditTransfer := paymentInfo.CdtTrfTxInf[0]
+	creditTransfer.Amt.InstdAmt.Ccy = "XYZ"
+	_, reasonCode, err := painTransFromPain001(paymentInfo, creditTransfer)
+	if err == nil || reasonCode != REASON_INVALID_CURRENCY {
+		t.Errorf("PainTransFromPain001 currency does not pass. Looking for %v, got %v", REASON_INVALID_CURRENCY, reasonCode)
+	}
+
+	// Amount not valid
+	creditTransfer = doc.CstmrCdtTrfInitn.PmtInf[0].CdtTrfTxInf[0]
+	creditTransfer.Amt.InstdAmt.Value = "-1"
+	_, reasonCode, err = painTransFromPain001(doc.CstmrCdtTrfInitn.PmtInf[0], creditTransfer)
+	if err == nil || reasonCode != REASON_INVALID_AMOUNT {
+		t.Errorf("PainTransFromPain001 amount does not pass. Looking for %v, got %v", REASON_INVALID_AMOUNT, reasonCode)
+	}
+}
+
+func TestProcessPAINCreditTransferXML(t *testing.T) {
+	data := []string{"", "", "1003"}
+	_, err := ProcessPAIN(data)
+	if err == nil {
+		t.Errorf("ProcessPAIN PainType1003 does not pass. Looking for %v, got %v", "Not all data is present", nil)
+	}
+
+	data = []string{"", "", "1003", "not base64!"}
+	_, err = ProcessPAIN(data)
+	if err == nil {
+		t.Errorf("ProcessPAIN PainType1003 does not pass. Looking for %v, got %v", "Could not decode document", nil)
+	}
+
+	data = []string{"", "", "1003", base64.StdEncoding.EncodeToString([]byte("<Document/>"))}
+	_, err = ProcessPAIN(data)
+	if err == nil {
+		t.Errorf("ProcessPAIN PainType1003 does not pass. Looking for %v, got %v", "Token invalid", nil)
+	}
+}
@@ -0,0 +1,139 @@
+package transactions
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/bvnk/bank/accounts"
+	"github.com/bvnk/bank/appauth"
+)
+
+/*
+Transaction statuses
+
+received - saved, no checks done yet
+pending - waiting on something outside the bank (e.g. another bank)
+accepted - checks passed and accounts posted
+settled - funds have reached the receiver
+rejected - checks failed and nothing was posted, or another bank refused it and it was returned
+reversed - a settled payment that has been paid back (pain.007)
+
+rejected and reversed are final
+*/
+const (
+	STATUS_RECEIVED = "received"
+	STATUS_PENDING  = "pending"
+	STATUS_ACCEPTED = "accepted"
+	STATUS_SETTLED  = "settled"
+	STATUS_REJECTED = "rejected"
+	STATUS_REVERSED = "reversed"
+)
+
+// Status reason codes (ISO 20022 ExternalStatusReason1Code)
+const (
+	REASON_INCORRECT_ACCOUNT     = "AC01"
+	REASON_CLOSED_ACCOUNT        = "AC04"
+	REASON_TRANSACTION_FORBIDDEN = "AG01"
+	REASON_INCORRECT_AGENT       = "AGNT"
+	REASON_INSUFFICIENT_FUNDS    = "AM04"
+	REASON_INVALID_CURRENCY      = "AM11"
+	REASON_INVALID_AMOUNT        = "AM12"
+	// The reason is in the additional information
+	REASON_NARRATIVE = "NARR"
+)
+
+// statusTransitions lists the statuses each status can move to
+var statusTransitions = map[string][]string{
+	STATUS_RECEIVED: []string{STATUS_PENDING, STATUS_ACCEPTED, STATUS_REJECTED},
+	STATUS_PENDING:  []string{STATUS_ACCEPTED, STATUS_REJECTED},
+	STATUS_ACCEPTED: []string{STATUS_SETTLED, STATUS_REJECTED},
+	STATUS_SETTLED:  []string{STATUS_REVERSED},
+}
+
+// ISO 20022 ExternalPaymentTransactionStatus1Code for each status. A reversal has no pain.002
+// status of its own, the reversing transaction reports it
+var isoStatusCodes = map[string]string{
+	STATUS_RECEIVED: "RCVD",
+	STATUS_PENDING:  "PDNG",
+	STATUS_ACCEPTED: "ACCP",
+	STATUS_SETTLED:  "ACSC",
+	STATUS_REJECTED: "RJCT",
+}
+
+type StatusChange struct {
+	FromStatus string
+	ToStatus   string
+	ReasonCode string
+	Timestamp  int32
+}
+
+// PaymentStatusReport is the answer to a pain.002 status query
+type PaymentStatusReport struct {
+	TransactionID int32
+	Status        string
+	ISOStatus     string
+	ReasonCode    string
+	History       []StatusChange
+}
+
+func checkStatusTransition(fromStatus string, toStatus string) (err error) {
+	for _, allowed := range statusTransitions[fromStatus] {
+		if allowed == toStatus {
+			return
+		}
+	}
+	return errors.New("payments.checkStatusTransition: Transaction cannot move from " + fromStatus + " to " + toStatus)
+}
+
+// postedStatus is the status a transaction ends in once its accounts are posted. Payments to
+// other banks are only accepted here and settle when the other bank confirms them
+func postedStatus(transaction PAINTrans) string {
+	if transaction.Receiver.BankNumber != "" {
+		return STATUS_ACCEPTED
+	}
+	return STATUS_SETTLED
+}
+
+func paymentStatusReport(data []string) (result interface{}, err error) {
+	transactionID, err := strconv.ParseInt(data[3], 10, 32)
+	if err != nil {
+		return "", errors.New("payments.paymentStatusReport: Could not parse transaction ID. " + err.Error())
+	}
+
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("payments.paymentStatusReport: " + err.Error())
+	}
+
+	transaction, err := getTransaction(int32(transactionID))
+	if err != nil {
+		return "", errors.New("payments.paymentStatusReport: " + err.Error())
+	}
+
+	// Either side of the transaction can see its status
+	errSender := accounts.CheckUserAccountValidFromToken(tokenUser, transaction.Sender.AccountNumber)
+	errReceiver := accounts.CheckUserAccountValidFromToken(tokenUser, transaction.Receiver.AccountNumber)
+	if errSender != nil && errReceiver != nil {
+		return "", errors.New("payments.paymentStatusReport: Account not party to transaction")
+	}
+
+	history, err := getStatusHistory(transaction.ID)
+	if err != nil {
+		return "", errors.New("payments.paymentStatusReport: " + err.Error())
+	}
+
+	report := PaymentStatusReport{
+		TransactionID: transaction.ID,
+		Status:        transaction.Status,
+		ISOStatus:     isoStatusCodes[transaction.Status],
+		History:       history,
+	}
+	// The reason for the most recent change that had one
+	for _, change := range history {
+		if change.ReasonCode != "" {
+			report.ReasonCode = change.ReasonCode
+		}
+	}
+
+	return report, nil
+}
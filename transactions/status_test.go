@@ -0,0 +1,61 @@
+package transactions
+
+import (
+	"testing"
+)
+
+func TestCheckStatusTransition(t *testing.T) {
+	allowed := [][]string{
+		{STATUS_RECEIVED, STATUS_ACCEPTED},
+		{STATUS_RECEIVED, STATUS_REJECTED},
+		{STATUS_PENDING, STATUS_ACCEPTED},
+		{STATUS_ACCEPTED, STATUS_SETTLED},
+		{STATUS_SETTLED, STATUS_REVERSED},
+	}
+	for _, transition := range allowed {
+		err := checkStatusTransition(transition[0], transition[1])
+		if err != nil {
+			t.Errorf("CheckStatusTransition %v does not pass. Looking for %v, got %v", transition, nil, err)
+		}
+	}
+
+	notAllowed := [][]string{
+		{STATUS_RECEIVED, STATUS_SETTLED},
+		{STATUS_ACCEPTED, STATUS_REVERSED},
+		{STATUS_REJECTED, STATUS_ACCEPTED},
+		{STATUS_REVERSED, STATUS_SETTLED},
+		{STATUS_SETTLED, STATUS_REJECTED},
+	}
+	for _, transition := range notAllowed {
+		err := checkStatusTransition(transition[0], transition[1])
+		if err == nil {
+			t.Errorf("CheckStatusTransition %v does not pass. Looking for %v, got %v", transition, "Transaction cannot move", nil)
+		}
+	}
+}
+
+func TestPostedStatus(t *testing.T) {
+	local := PAINTrans{Sender: AccountHolder{"accountNumSender", ""}, Receiver: AccountHolder{"accountNumReceiver", ""}}
+	if postedStatus(local) != STATUS_SETTLED {
+		t.Errorf("PostedStatus local does not pass. Looking for %v, got %v", STATUS_SETTLED, postedStatus(local))
+	}
+
+	remote := PAINTrans{Sender: AccountHolder{"accountNumSender", ""}, Receiver: AccountHolder{"accountNumReceiver", "bankNumReceiver"}}
+	if postedStatus(remote) != STATUS_ACCEPTED {
+		t.Errorf("PostedStatus remote does not pass. Looking for %v, got %v", STATUS_ACCEPTED, postedStatus(remote))
+	}
+}
+
+func TestProcessPAINStatusReport(t *testing.T) {
+	data := []string{"", "", "2"}
+	_, err := ProcessPAIN(data)
+	if err == nil {
+		t.Errorf("ProcessPAIN PainType2 does not pass. Looking for %v, got %v", "Not all data is present", nil)
+	}
+
+	data = []string{"", "", "2", "not integer"}
+	_, err = ProcessPAIN(data)
+	if err == nil {
+		t.Errorf("ProcessPAIN PainType2 TransactionID does not pass. Looking for %v, got %v", "Could not parse transaction ID", nil)
+	}
+}
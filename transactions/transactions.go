@@ -5,7 +5,7 @@ PAIN transactions are as follows
 
 Payments initiation:
 1 - CustomerCreditTransferInitiationV06
-2 - CustomerPaymentStatusReportV06
+2 - CustomerPaymentStatusReportV06 (status query)
 7 - CustomerPaymentReversalV05
 8 - CustomerDirectDebitInitiationV05
 
@@ -18,22 +18,47 @@ Payments mandates:
 #### Custom payments
 1000 - CustomerDepositInitiation (@FIXME Will need to implement this properly, for now we use it to demonstrate functionality)
 1001 - ListTransactions
+1002 - ListMandates
+1003 - CustomerCreditTransferInitiationV06 as ISO 20022 XML, answered with a pain.002 XML status report
+1004 - HoldPlacement
+1005 - HoldCapture
+1006 - HoldCancellation
+1007 - ListHolds
+1008 - ReleaseExpiredHolds
+1009 - FXConversion (posted by fx~4, not accepted by ProcessPAIN)
+1010 - CardPayment (posted from ISO 8583 financial messages, not accepted by ProcessPAIN)
 
 */
 
 import (
+	"database/sql"
 	"errors"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bvnk/bank/accounts"
 	"github.com/bvnk/bank/appauth"
+	"github.com/bvnk/bank/fees"
+	"github.com/bvnk/bank/interest"
+	"github.com/bvnk/bank/money"
 	"github.com/bvnk/bank/push"
+	"github.com/bvnk/bank/statements"
 	"github.com/paulmach/go.geo"
 	"github.com/shopspring/decimal"
 )
 
-const TRANSACTION_FEE = 0.0001 // 0.01%
+// Reversal reason codes (ISO 20022 ExternalReversalReason1Code) and whether the fee on the
+// original payment is refunded. Fees are refunded when the payment should never have been
+// made, and kept when the customer asks for their money back
+var reversalFeeRefund = map[string]bool{
+	"AM05": true,  // Duplication
+	"DUPL": true,  // Duplicate payment
+	"TECH": true,  // Technical problem
+	"FRAD": true,  // Fraudulent origin
+	"CUST": false, // Requested by customer
+	"UPAY": false, // Undue payment
+}
 
 // @TODO Have this struct not repeat in payments and accounts
 type AccountHolder struct {
@@ -46,12 +71,35 @@ type PAINTrans struct {
 	PainType  int64
 	Sender    AccountHolder
 	Receiver  AccountHolder
-	Amount    decimal.Decimal
-	Fee       decimal.Decimal
+	Amount    money.Money
+	Fee       money.Money
 	Geo       geo.Point
 	Desc      string
 	Status    string
 	Timestamp int32
+	// ID of the transaction this one reverses, 0 if it is not a reversal
+	ReversalOf int32
+	// Mandate a direct debit was collected under, empty for other transactions
+	MandateID string
+	// Itemised fees, Fee being their total. On a reversal these are the fees refunded
+	Fees []fees.Item
+	// Hold a payment was captured from, 0 for other transactions
+	HoldID int64
+	// Rate a conversion was made at and what its receiver was paid, zero for other transactions
+	FXRate          decimal.Decimal
+	ConvertedAmount money.Money
+}
+
+// inCurrency puts the amounts of a transaction read from the database in its currency, and the
+// converted amount of a conversion in the currency it was converted to
+func (transaction *PAINTrans) inCurrency(currency string, convertedCurrency string) {
+	transaction.Amount = transaction.Amount.In(currency)
+	transaction.Fee = transaction.Fee.In(currency)
+	if convertedCurrency == "" {
+		transaction.ConvertedAmount = money.Money{}
+		return
+	}
+	transaction.ConvertedAmount = transaction.ConvertedAmount.In(convertedCurrency)
 }
 
 func ProcessPAIN(data []string) (result interface{}, err error) {
@@ -68,7 +116,8 @@ func ProcessPAIN(data []string) (result interface{}, err error) {
 
 	switch painType {
 	case 1:
-		//There must be at least 9 elements
+		//There must be at least 9 elements, the currency is optional
+		//token~pain~type~sender@~receiver@~amount~lat~lon~desc~currency
 		if len(data) < 9 {
 			return "", errors.New("payments.ProcessPAIN: Not all data is present.")
 		}
@@ -78,6 +127,76 @@ func ProcessPAIN(data []string) (result interface{}, err error) {
 			return "", errors.New("payments.ProcessPAIN: " + err.Error())
 		}
 		break
+	case 2:
+		//token~pain~type~transactionID
+		if len(data) < 4 {
+			return "", errors.New("payments.ProcessPAIN: Not all data is present.")
+		}
+		result, err = paymentStatusReport(data)
+		if err != nil {
+			return "", errors.New("payments.ProcessPAIN: " + err.Error())
+		}
+		break
+	case 7:
+		//token~pain~type~transactionID~reasonCode~lat~lon~desc
+		if len(data) < 8 {
+			return "", errors.New("payments.ProcessPAIN: Not all data is present.")
+		}
+		result, err = customerPaymentReversal(painType, data)
+		if err != nil {
+			return "", errors.New("payments.ProcessPAIN: " + err.Error())
+		}
+		break
+	case 8:
+		//token~pain~type~mandateID~amount~lat~lon~desc
+		if len(data) < 8 {
+			return "", errors.New("payments.ProcessPAIN: Not all data is present.")
+		}
+		result, err = customerDirectDebitInitiation(painType, data)
+		if err != nil {
+			return "", errors.New("payments.ProcessPAIN: " + err.Error())
+		}
+		break
+	case 9:
+		//token~pain~type~creditorAccountNumber@~debtorAccountNumber@~maxAmount~frequency~desc
+		if len(data) < 8 {
+			return "", errors.New("payments.ProcessPAIN: Not all data is present.")
+		}
+		result, err = mandateInitiationRequest(data)
+		if err != nil {
+			return "", errors.New("payments.ProcessPAIN: " + err.Error())
+		}
+		break
+	case 10:
+		//token~pain~type~mandateID~maxAmount~frequency
+		if len(data) < 6 {
+			return "", errors.New("payments.ProcessPAIN: Not all data is present.")
+		}
+		result, err = mandateAmendmentRequest(data)
+		if err != nil {
+			return "", errors.New("payments.ProcessPAIN: " + err.Error())
+		}
+		break
+	case 11:
+		//token~pain~type~mandateID
+		if len(data) < 4 {
+			return "", errors.New("payments.ProcessPAIN: Not all data is present.")
+		}
+		result, err = mandateCancellationRequest(data)
+		if err != nil {
+			return "", errors.New("payments.ProcessPAIN: " + err.Error())
+		}
+		break
+	case 12:
+		//token~pain~type~mandateID~accepted
+		if len(data) < 5 {
+			return "", errors.New("payments.ProcessPAIN: Not all data is present.")
+		}
+		result, err = mandateAcceptanceReport(data)
+		if err != nil {
+			return "", errors.New("payments.ProcessPAIN: " + err.Error())
+		}
+		break
 	case 1000:
 		//There must be at least 8 elements
 		//token~pain~type~amount~lat~lon~desc
@@ -99,6 +218,77 @@ func ProcessPAIN(data []string) (result interface{}, err error) {
 			return "", errors.New("payments.ProcessPAIN: " + err.Error())
 		}
 		break
+	case 1003:
+		//token~pain~type~base64(pain.001 XML)
+		if len(data) < 4 {
+			return "", errors.New("payments.ProcessPAIN: Not all data is present.")
+		}
+		result, err = customerCreditTransferInitiationXML(data)
+		if err != nil {
+			return "", errors.New("payments.ProcessPAIN: " + err.Error())
+		}
+		break
+	case 1002:
+		//token~pain~type~accountNumber
+		if len(data) < 4 {
+			return "", errors.New("payments.ProcessPAIN: Not all data is present.")
+		}
+		result, err = listMandates(data)
+		if err != nil {
+			return "", errors.New("payments.ProcessPAIN: " + err.Error())
+		}
+		break
+	case 1004:
+		//token~pain~type~accountNumber@~payeeAccountNumber@~amount~expiryHours~desc
+		if len(data) < 8 {
+			return "", errors.New("payments.ProcessPAIN: Not all data is present.")
+		}
+		result, err = holdPlacement(data)
+		if err != nil {
+			return "", errors.New("payments.ProcessPAIN: " + err.Error())
+		}
+		break
+	case 1005:
+		//token~pain~type~holdID~amount
+		if len(data) < 4 {
+			return "", errors.New("payments.ProcessPAIN: Not all data is present.")
+		}
+		result, err = holdCapture(data)
+		if err != nil {
+			return "", errors.New("payments.ProcessPAIN: " + err.Error())
+		}
+		break
+	case 1006:
+		//token~pain~type~holdID
+		if len(data) < 4 {
+			return "", errors.New("payments.ProcessPAIN: Not all data is present.")
+		}
+		result, err = holdCancellation(data)
+		if err != nil {
+			return "", errors.New("payments.ProcessPAIN: " + err.Error())
+		}
+		break
+	case 1007:
+		//token~pain~type~accountNumber
+		if len(data) < 4 {
+			return "", errors.New("payments.ProcessPAIN: Not all data is present.")
+		}
+		result, err = listHolds(data)
+		if err != nil {
+			return "", errors.New("payments.ProcessPAIN: " + err.Error())
+		}
+		break
+	case 1008:
+		//token~pain~type
+		_, err = appauth.CheckOperator(data[0])
+		if err != nil {
+			return "", errors.New("payments.ProcessPAIN: " + err.Error())
+		}
+		result, err = releaseExpiredHolds()
+		if err != nil {
+			return "", errors.New("payments.ProcessPAIN: " + err.Error())
+		}
+		break
 	}
 
 	return
@@ -115,11 +305,28 @@ func painCreditTransferInitiation(painType int64, data []string) (result string,
 	if err != nil {
 		return "", errors.New("payments.painCreditTransferInitiation: " + err.Error())
 	}
+	sender.BankNumber = localBankNumber(sender.BankNumber)
+	receiver.BankNumber = localBankNumber(receiver.BankNumber)
+	if sender.BankNumber != "" {
+		return "", errors.New("payments.painCreditTransferInitiation: Sender must be an account at this bank")
+	}
 
 	trAmt := strings.TrimRight(data[5], "\x00")
-	transactionAmountDecimal, err := decimal.NewFromString(trAmt)
+	err = checkAmount(trAmt)
 	if err != nil {
-		return "", errors.New("payments.painCreditTransferInitiation: Could not convert transaction amount to decimal. " + err.Error())
+		return "", errors.New("payments.painCreditTransferInitiation: " + err.Error())
+	}
+
+	// The currency is optional, payments are in the sender's currency by default
+	currency := ""
+	if len(data) > 9 {
+		currency = strings.TrimRight(data[9], "\x00")
+	}
+	if currency != "" {
+		currency, err = money.ParseCurrency(currency)
+		if err != nil {
+			return "", errors.New("payments.painCreditTransferInitiation: " + err.Error())
+		}
 	}
 
 	// Check if sender valid
@@ -132,6 +339,20 @@ func painCreditTransferInitiation(painType int64, data []string) (result string,
 		return "", errors.New("payments.painCreditTransferInitiation: Sender not valid")
 	}
 
+	if currency == "" {
+		currency, err = getAccountCurrency(Config.Db, sender.AccountNumber)
+		if err != nil {
+			return "", errors.New("payments.painCreditTransferInitiation: " + err.Error())
+		}
+	}
+	transactionAmount, err := money.NewFromString(trAmt, currency)
+	if err != nil {
+		return "", errors.New("payments.painCreditTransferInitiation: Could not convert transaction amount. " + err.Error())
+	}
+	if transactionAmount.Sign() <= 0 {
+		return "", errors.New("payments.painCreditTransferInitiation: Transaction amount must be positive")
+	}
+
 	lat, err := strconv.ParseFloat(data[6], 64)
 	if err != nil {
 		return "", errors.New("payments.painCreditTransferInitiation: Could not parse coordinates into float")
@@ -143,46 +364,254 @@ func painCreditTransferInitiation(painType int64, data []string) (result string,
 	desc := data[8]
 
 	geo := *geo.NewPoint(lat, lon)
-	transaction := PAINTrans{0, painType, sender, receiver, transactionAmountDecimal, decimal.NewFromFloat(TRANSACTION_FEE), geo, desc, "approved", 0}
+	transaction := PAINTrans{0, painType, sender, receiver, transactionAmount, money.Zero(transactionAmount.Currency), geo, desc, STATUS_RECEIVED, 0, 0, "", nil, 0, decimal.Zero, money.Money{}}
 
-	// Checks for transaction (avail balance, accounts open, etc)
-	balanceAvailable, err := checkBalance(transaction.Sender)
+	result, _, err = initiateCreditTransfer(transaction)
 	if err != nil {
 		return "", errors.New("payments.painCreditTransferInitiation: " + err.Error())
 	}
+
+	return
+}
+
+// initiateCreditTransfer checks and posts a validated credit transfer. When the transfer is
+// rejected the reason code says why (ISO 20022 ExternalStatusReason1Code)
+func initiateCreditTransfer(transaction PAINTrans) (result string, reasonCode string, err error) {
+	// The balance check and the posting run in the same database transaction.
+	// checkBalance locks the sender's row, so two concurrent payments cannot both
+	// pass the check and overdraw the account
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return "", "", errors.New("payments.initiateCreditTransfer: Could not start database transaction. " + err.Error())
+	}
+
+	// Payments to another bank need a peer to send the pacs.008 to
+	if transaction.Receiver.BankNumber != "" {
+		if _, ok := Config.Peers[transaction.Receiver.BankNumber]; !ok {
+			transactionID, err := rejectPAINTransaction(tx, transaction, REASON_INCORRECT_AGENT)
+			if err != nil {
+				return "", "", errors.New("payments.initiateCreditTransfer: " + err.Error())
+			}
+			return transactionID, REASON_INCORRECT_AGENT, errors.New("payments.initiateCreditTransfer: Bank " + transaction.Receiver.BankNumber + " not reachable. Transaction " + transactionID + " rejected")
+		}
+	}
+
+	closed, err := checkAccountsClosed(tx, transaction)
+	if err != nil {
+		tx.Rollback()
+		return "", "", errors.New("payments.initiateCreditTransfer: " + err.Error())
+	}
+	if closed {
+		transactionID, err := rejectPAINTransaction(tx, transaction, REASON_CLOSED_ACCOUNT)
+		if err != nil {
+			return "", "", errors.New("payments.initiateCreditTransfer: " + err.Error())
+		}
+		return transactionID, REASON_CLOSED_ACCOUNT, errors.New("payments.initiateCreditTransfer: Account closed. Transaction " + transactionID + " rejected")
+	}
+
+	mismatch, err := checkCurrencies(tx, transaction)
+	if err != nil {
+		tx.Rollback()
+		return "", "", errors.New("payments.initiateCreditTransfer: " + err.Error())
+	}
+	if mismatch {
+		transactionID, err := rejectPAINTransaction(tx, transaction, REASON_INVALID_CURRENCY)
+		if err != nil {
+			return "", "", errors.New("payments.initiateCreditTransfer: " + err.Error())
+		}
+		return transactionID, REASON_INVALID_CURRENCY, errors.New("payments.initiateCreditTransfer: Accounts do not hold " + transaction.Amount.Currency + ", amounts must be converted first. Transaction " + transactionID + " rejected")
+	}
+
+	restricted, err := checkSenderRestricted(tx, transaction.Sender)
+	if err != nil {
+		tx.Rollback()
+		return "", "", errors.New("payments.initiateCreditTransfer: " + err.Error())
+	}
+	if restricted {
+		transactionID, err := rejectPAINTransaction(tx, transaction, REASON_TRANSACTION_FORBIDDEN)
+		if err != nil {
+			return "", "", errors.New("payments.initiateCreditTransfer: " + err.Error())
+		}
+		return transactionID, REASON_TRANSACTION_FORBIDDEN, errors.New("payments.initiateCreditTransfer: Account holder not verified. Transaction " + transactionID + " rejected")
+	}
+
+	err = assessFees(tx, &transaction)
+	if err != nil {
+		tx.Rollback()
+		return "", "", errors.New("payments.initiateCreditTransfer: " + err.Error())
+	}
+
+	// Checks for transaction (avail balance, etc)
+	balanceAvailable, err := checkBalance(tx, transaction.Sender)
+	if err != nil {
+		tx.Rollback()
+		return "", "", errors.New("payments.initiateCreditTransfer: " + err.Error())
+	}
+	// Withdrawing from a term account before it matures costs a penalty
+	penalty, err := interest.EarlyWithdrawalPenalty(tx, transaction.Sender.AccountNumber, transaction.Amount, time.Now())
+	if err != nil {
+		tx.Rollback()
+		return "", "", errors.New("payments.initiateCreditTransfer: " + err.Error())
+	}
+	// The sender pays the fee and any penalty on top of the amount
 	// Comparing decimals results in -1 if <
-	if balanceAvailable.Cmp(transaction.Amount) == -1 {
-		return "", errors.New("payments.painCreditTransferInitiation: Insufficient funds available")
+	if balanceAvailable.Cmp(transaction.Amount.Add(transaction.Fee).Add(penalty)) == -1 {
+		transactionID, err := rejectPAINTransaction(tx, transaction, REASON_INSUFFICIENT_FUNDS)
+		if err != nil {
+			return "", "", errors.New("payments.initiateCreditTransfer: " + err.Error())
+		}
+		return transactionID, REASON_INSUFFICIENT_FUNDS, errors.New("payments.initiateCreditTransfer: Insufficient funds available. Transaction " + transactionID + " rejected")
+	}
+
+	// The penalty commits or rolls back with the transaction
+	err = interest.ChargeEarlyWithdrawalPenalty(tx, transaction.Sender.AccountNumber, penalty, int32(time.Now().Unix()))
+	if err != nil {
+		tx.Rollback()
+		return "", "", errors.New("payments.initiateCreditTransfer: " + err.Error())
 	}
 
 	// Save transaction
-	result, err = processPAINTransaction(transaction)
+	result, err = processPAINTransaction(tx, transaction)
 	if err != nil {
-		return "", errors.New("payments.painCreditTransferInitiation: " + err.Error())
+		return "", "", errors.New("payments.initiateCreditTransfer: " + err.Error())
 	}
 
-	go push.SendNotification(sender.AccountNumber, "💸 Payment sent!", 1, "default")
-	go push.SendNotification(receiver.AccountNumber, "💸 Payment received!", 1, "default")
+	go statements.PushNotification(transaction.Sender.AccountNumber, result, "💸 Payment sent!")
+	if transaction.Receiver.BankNumber == "" {
+		go statements.PushNotification(transaction.Receiver.AccountNumber, result, "💸 Payment received!")
+	} else {
+		// Transfers that cannot be sent now stay queued for pacs~1001
+		go sendCreditTransfer(result)
+	}
 
 	return
 }
 
-func processPAINTransaction(transaction PAINTrans) (result string, err error) {
-	// Test: pain~1~1b2ca241-0373-4610-abad-da7b06c50a7b@~181ac0ae-45cb-461d-b740-15ce33e4612f@~20
+// processPAINTransaction posts the transaction inside tx, which the caller has begun.
+// The transaction row, the account movements and the fee all commit together: tx is
+// committed on success and rolled back on any failure.
+func processPAINTransaction(tx *sql.Tx, transaction PAINTrans) (result string, err error) {
+	// Test: pain~1~1b2ca241-0373-4610-abad-da7b06c50a7b@~181ac0ae-45cb-461d-b740-15ce33e4612f@~20~0~0~Rent~USD
+
+	// Nothing is posted to a closed account
+	closed, err := checkAccountsClosed(tx, transaction)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.processPAINTransaction: " + err.Error())
+	}
+	if closed {
+		transactionID, err := rejectPAINTransaction(tx, transaction, REASON_CLOSED_ACCOUNT)
+		if err != nil {
+			return "", errors.New("payments.processPAINTransaction: " + err.Error())
+		}
+		return "", errors.New("payments.processPAINTransaction: Account closed. Transaction " + transactionID + " rejected")
+	}
+
+	// Nor anything in a currency the accounts are not held in
+	mismatch, err := checkCurrencies(tx, transaction)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.processPAINTransaction: " + err.Error())
+	}
+	if mismatch {
+		transactionID, err := rejectPAINTransaction(tx, transaction, REASON_INVALID_CURRENCY)
+		if err != nil {
+			return "", errors.New("payments.processPAINTransaction: " + err.Error())
+		}
+		return "", errors.New("payments.processPAINTransaction: Accounts do not hold " + transaction.Amount.Currency + ", amounts must be converted first. Transaction " + transactionID + " rejected")
+	}
 
 	// Save in transaction table
-	err = savePainTransaction(transaction)
+	transaction.Status = STATUS_RECEIVED
+	transactionID, err := savePainTransaction(tx, transaction)
 	if err != nil {
+		tx.Rollback()
 		return "", errors.New("payments.processPAINTransaction: " + err.Error())
 	}
+	transaction.ID = int32(transactionID)
+	result = strconv.FormatInt(transactionID, 10)
 
 	// Amend sender and receiver accounts
 	// Amend bank's account with fee addition
-	err = updateAccounts(transaction)
+	err = updateAccounts(tx, transaction)
 	if err != nil {
+		tx.Rollback()
 		return "", errors.New("payments.processPAINTransaction: " + err.Error())
 	}
 
+	err = setTransactionStatus(tx, transaction.ID, STATUS_RECEIVED, STATUS_ACCEPTED, "")
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.processPAINTransaction: " + err.Error())
+	}
+	if postedStatus(transaction) == STATUS_SETTLED {
+		err = setTransactionStatus(tx, transaction.ID, STATUS_ACCEPTED, STATUS_SETTLED, "")
+		if err != nil {
+			tx.Rollback()
+			return "", errors.New("payments.processPAINTransaction: " + err.Error())
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", errors.New("payments.processPAINTransaction: Could not commit transaction. " + err.Error())
+	}
+
+	return
+}
+
+// rejectPAINTransaction records a transaction that failed its checks, so the outcome can be
+// queried with pain.002. Nothing is posted to the accounts. tx is committed on success and
+// rolled back on any failure
+func rejectPAINTransaction(tx *sql.Tx, transaction PAINTrans, reasonCode string) (result string, err error) {
+	transaction.Status = STATUS_RECEIVED
+	transactionID, err := savePainTransaction(tx, transaction)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.rejectPAINTransaction: " + err.Error())
+	}
+
+	err = setTransactionStatus(tx, int32(transactionID), STATUS_RECEIVED, STATUS_REJECTED, reasonCode)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.rejectPAINTransaction: " + err.Error())
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", errors.New("payments.rejectPAINTransaction: Could not commit transaction. " + err.Error())
+	}
+
+	return strconv.FormatInt(transactionID, 10), nil
+}
+
+// assessFees itemises the fees on the transaction with the fee engine and sets Fee to their
+// total. The account that initiates the transaction pays: the sender of a payment, and the
+// receiver of a direct debit or deposit, whose fees come out of the amount. Accounts at other
+// banks pay no fees here
+func assessFees(tx *sql.Tx, transaction *PAINTrans) (err error) {
+	payer := transaction.Sender
+	if transaction.PainType == 8 || transaction.PainType == 1000 {
+		payer = transaction.Receiver
+	}
+
+	transaction.Fees = nil
+	transaction.Fee = money.Zero(transaction.Amount.Currency)
+	if payer.BankNumber != "" {
+		return
+	}
+
+	items, err := fees.Assess(tx, transaction.PainType, payer.AccountNumber, transaction.Amount, time.Now())
+	if err != nil {
+		return errors.New("payments.assessFees: " + err.Error())
+	}
+	total := fees.Total(items, transaction.Amount.Currency)
+	if payer == transaction.Receiver && total.Cmp(transaction.Amount) > 0 {
+		return errors.New("payments.assessFees: Fees of " + total.StringFixed() + " are more than the amount")
+	}
+
+	transaction.Fees = items
+	transaction.Fee = total
 	return
 }
 
@@ -197,6 +626,19 @@ func parseAccountHolder(account string) (accountHolder AccountHolder, err error)
 	return
 }
 
+// checkAmount validates an amount before the currency it is in is known. Its minor units are
+// checked once it is converted
+func checkAmount(amount string) (err error) {
+	value, err := decimal.NewFromString(strings.TrimSpace(amount))
+	if err != nil {
+		return errors.New("payments.checkAmount: Could not convert amount. " + err.Error())
+	}
+	if value.Sign() <= 0 {
+		return errors.New("payments.checkAmount: Amount must be positive")
+	}
+	return
+}
+
 func customerDepositInitiation(painType int64, data []string) (result string, err error) {
 	// Validate input
 	// Sender is bank
@@ -211,9 +653,9 @@ func customerDepositInitiation(painType int64, data []string) (result string, er
 	}
 
 	trAmt := strings.TrimRight(data[4], "\x00")
-	transactionAmountDecimal, err := decimal.NewFromString(trAmt)
+	err = checkAmount(trAmt)
 	if err != nil {
-		return "", errors.New("payments.customerDepositInitiation: Could not convert transaction amount to decimal. " + err.Error())
+		return "", errors.New("payments.customerDepositInitiation: " + err.Error())
 	}
 
 	// Check if sender valid
@@ -226,6 +668,19 @@ func customerDepositInitiation(painType int64, data []string) (result string, er
 		return "", errors.New("payments.customerDepositInitiation: Sender not valid")
 	}
 
+	// Deposits are in the currency of the account they are paid into
+	currency, err := getAccountCurrency(Config.Db, receiver.AccountNumber)
+	if err != nil {
+		return "", errors.New("payments.customerDepositInitiation: " + err.Error())
+	}
+	transactionAmount, err := money.NewFromString(trAmt, currency)
+	if err != nil {
+		return "", errors.New("payments.customerDepositInitiation: Could not convert transaction amount. " + err.Error())
+	}
+	if transactionAmount.Sign() <= 0 {
+		return "", errors.New("payments.customerDepositInitiation: Transaction amount must be positive")
+	}
+
 	lat, err := strconv.ParseFloat(data[5], 64)
 	if err != nil {
 		return "", errors.New("payments.customerDepositInitiation: Could not parse coordinates into float")
@@ -240,14 +695,163 @@ func customerDepositInitiation(painType int64, data []string) (result string, er
 	// @TODO This flow show be fixed. Maybe have banks approve deposits before initiation, or
 	// immediate approval below a certain amount subject to rate limiting
 	geo := *geo.NewPoint(lat, lon)
-	transaction := PAINTrans{0, painType, sender, receiver, transactionAmountDecimal, decimal.NewFromFloat(TRANSACTION_FEE), geo, desc, "approved", 0}
+	transaction := PAINTrans{0, painType, sender, receiver, transactionAmount, money.Zero(transactionAmount.Currency), geo, desc, STATUS_RECEIVED, 0, 0, "", nil, 0, decimal.Zero, money.Money{}}
+
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return "", errors.New("payments.CustomerDepositInitiation: Could not start database transaction. " + err.Error())
+	}
+
+	err = assessFees(tx, &transaction)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.CustomerDepositInitiation: " + err.Error())
+	}
+
 	// Save transaction
-	result, err = processPAINTransaction(transaction)
+	result, err = processPAINTransaction(tx, transaction)
 	if err != nil {
 		return "", errors.New("payments.CustomerDepositInitiation: " + err.Error())
 	}
 
-	go push.SendNotification(receiver.AccountNumber, "💸 Deposit received!", 1, "default")
+	go statements.PushNotification(receiver.AccountNumber, result, "💸 Deposit received!")
+
+	return
+}
+
+// checkReversible returns why a transaction cannot be reversed. Customers reverse their credit
+// transfers; card payments and captured holds are the merchant's once settled, so only the
+// acquirer reverses them
+func checkReversible(original PAINTrans, byAcquirer bool) (err error) {
+	switch original.PainType {
+	case 1:
+		// Valid
+		break
+	case PAIN_CARD_PAYMENT, PAIN_HOLD_CAPTURE:
+		if !byAcquirer {
+			return errors.New("payments.checkReversible: Card payments and captured holds can only be reversed by the acquirer")
+		}
+	default:
+		return errors.New("payments.checkReversible: Only payments can be reversed")
+	}
+	if original.Status != STATUS_SETTLED {
+		return errors.New("payments.checkReversible: Transaction cannot be reversed, status is " + original.Status)
+	}
+	if original.Sender.BankNumber != "" || original.Receiver.BankNumber != "" {
+		return errors.New("payments.checkReversible: Only payments between accounts at this bank can be reversed")
+	}
+	return
+}
+
+func customerPaymentReversal(painType int64, data []string) (result string, err error) {
+	// Validate input
+	originalID, err := strconv.ParseInt(data[3], 10, 32)
+	if err != nil {
+		return "", errors.New("payments.customerPaymentReversal: Could not parse transaction ID. " + err.Error())
+	}
+
+	reasonCode := data[4]
+	if _, ok := reversalFeeRefund[reasonCode]; !ok {
+		return "", errors.New("payments.customerPaymentReversal: Reversal reason code not valid, must be one of AM05, DUPL, TECH, FRAD, CUST, UPAY")
+	}
+
+	lat, err := strconv.ParseFloat(data[5], 64)
+	if err != nil {
+		return "", errors.New("payments.customerPaymentReversal: Could not parse coordinates into float")
+	}
+	lon, err := strconv.ParseFloat(data[6], 64)
+	if err != nil {
+		return "", errors.New("payments.customerPaymentReversal: Could not parse coordinates into float")
+	}
+	desc := data[7]
+
+	tokenUser, err := appauth.GetUserFromToken(data[0])
+	if err != nil {
+		return "", errors.New("payments.customerPaymentReversal: " + err.Error())
+	}
+
+	tx, err := Config.Db.Begin()
+	if err != nil {
+		return "", errors.New("payments.customerPaymentReversal: Could not start database transaction. " + err.Error())
+	}
+
+	// Lock the original so it cannot be reversed twice concurrently
+	original, err := getTransactionForUpdate(tx, int32(originalID))
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.customerPaymentReversal: " + err.Error())
+	}
+
+	// Only the sender of a payment can reverse it
+	err = accounts.CheckUserAccountValidFromToken(tokenUser, original.Sender.AccountNumber)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.customerPaymentReversal: Sender not valid")
+	}
+
+	result, err = reversePayment(tx, original, reasonCode, desc, *geo.NewPoint(lat, lon), false)
+	if err != nil {
+		return "", errors.New("payments.customerPaymentReversal: " + err.Error())
+	}
+
+	go push.SendNotification(original.Sender.AccountNumber, "💸 Payment reversed!", 1, "default")
+	go push.SendNotification(original.Receiver.AccountNumber, "💸 Payment reversed!", 1, "default")
+
+	return
+}
+
+// reversePayment reverses a settled payment between accounts at this bank inside tx, which the
+// caller has begun with the original locked. The reason code (ExternalReversalReason1Code)
+// decides whether the fees are refunded. byAcquirer is set for card reversals, which are the
+// only way to reverse card payments and captured holds. tx is committed on success and rolled
+// back on any failure
+func reversePayment(tx *sql.Tx, original PAINTrans, reasonCode string, desc string, point geo.Point, byAcquirer bool) (result string, err error) {
+	refundFee, ok := reversalFeeRefund[reasonCode]
+	if !ok {
+		tx.Rollback()
+		return "", errors.New("payments.reversePayment: Reversal reason code not valid")
+	}
+
+	err = checkReversible(original, byAcquirer)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.reversePayment: " + err.Error())
+	}
+
+	// The money comes back from the original receiver
+	balanceAvailable, err := checkBalance(tx, original.Receiver)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.reversePayment: " + err.Error())
+	}
+	if balanceAvailable.Cmp(original.Amount) == -1 {
+		tx.Rollback()
+		return "", errors.New("payments.reversePayment: Receiver has insufficient funds available to reverse the payment")
+	}
+
+	err = setTransactionStatus(tx, original.ID, STATUS_SETTLED, STATUS_REVERSED, reasonCode)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("payments.reversePayment: " + err.Error())
+	}
+
+	// On a reversal the fees hold the fees refunded to the original sender, each from the
+	// income account it was credited to
+	var refunds []fees.Item
+	if refundFee {
+		refunds, err = fees.GetItems(tx, int64(original.ID))
+		if err != nil {
+			tx.Rollback()
+			return "", errors.New("payments.reversePayment: " + err.Error())
+		}
+	}
+
+	reversal := PAINTrans{0, 7, original.Receiver, original.Sender, original.Amount, fees.Total(refunds, original.Amount.Currency), point, reasonCode + " " + desc, STATUS_RECEIVED, 0, original.ID, "", refunds, 0, decimal.Zero, money.Money{}}
+
+	result, err = processPAINTransaction(tx, reversal)
+	if err != nil {
+		return "", errors.New("payments.reversePayment: " + err.Error())
+	}
 
 	return
 }
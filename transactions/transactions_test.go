@@ -1,6 +1,14 @@
 package transactions
 
-import "testing"
+import (
+	"strings"
+	"testing"
+
+	"github.com/bvnk/bank/fees"
+	"github.com/bvnk/bank/ledger"
+	"github.com/bvnk/bank/money"
+	"github.com/shopspring/decimal"
+)
 
 func TestProcessPAIN(t *testing.T) {
 	data := []string{"", ""}
@@ -26,6 +34,24 @@ func TestProcessPAIN(t *testing.T) {
 	if err == nil {
 		t.Errorf("ProcessPAIN PainType1000 does not pass. Looking for %v, got %v", "Not all data is present. Run pain~help to check for needed PAIN data", nil)
 	}
+
+	data = []string{"", "", "7"}
+	_, err = ProcessPAIN(data)
+	if err == nil {
+		t.Errorf("ProcessPAIN PainType7 does not pass. Looking for %v, got %v", "Not all data is present. Run pain~help to check for needed PAIN data", nil)
+	}
+
+	data = []string{"", "", "7", "not integer", "DUPL", "0", "0", "desc"}
+	_, err = ProcessPAIN(data)
+	if err == nil {
+		t.Errorf("ProcessPAIN PainType7 TransactionID does not pass. Looking for %v, got %v", "Could not parse transaction ID", nil)
+	}
+
+	data = []string{"", "", "7", "1", "NOPE", "0", "0", "desc"}
+	_, err = ProcessPAIN(data)
+	if err == nil {
+		t.Errorf("ProcessPAIN PainType7 ReasonCode does not pass. Looking for %v, got %v", "Reversal reason code not valid", nil)
+	}
 }
 
 func BenchmarkProcessPAIN(b *testing.B) {
@@ -44,3 +70,183 @@ func BenchmarkProcessPAIN(b *testing.B) {
 		_, _ = ProcessPAIN(data)
 	}
 }
+
+func TestJournalEntryForTransactionPayment(t *testing.T) {
+	sender := AccountHolder{"accountNumSender", ""}
+	receiver := AccountHolder{"accountNumReceiver", ""}
+	amount := money.New(decimal.New(20, 0), money.DEFAULT_CURRENCY)
+	trans := withFee(PAINTrans{ID: 1, PainType: 1, Sender: sender, Receiver: receiver, Amount: amount})
+
+	entry := journalEntryForTransaction(trans)
+	err := entry.Validate()
+	if err != nil {
+		t.Errorf("JournalEntryForTransaction payment does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	if entry.Lines[0].LedgerAccount != "accountNumSender" || entry.Lines[0].Direction != ledger.DEBIT {
+		t.Errorf("JournalEntryForTransaction payment does not pass. Looking for %v, got %v", "debit accountNumSender", entry.Lines[0])
+	}
+}
+
+func TestJournalEntryForTransactionRemoteReceiver(t *testing.T) {
+	sender := AccountHolder{"accountNumSender", ""}
+	receiver := AccountHolder{"accountNumReceiver", "bankNumReceiver"}
+	amount := money.New(decimal.New(20, 0), money.DEFAULT_CURRENCY)
+	trans := withFee(PAINTrans{ID: 1, PainType: 1, Sender: sender, Receiver: receiver, Amount: amount})
+
+	entry := journalEntryForTransaction(trans)
+	if entry.Lines[1].LedgerAccount != ledger.SUSPENSE {
+		t.Errorf("JournalEntryForTransaction remote receiver does not pass. Looking for %v, got %v", ledger.SUSPENSE, entry.Lines[1].LedgerAccount)
+	}
+}
+
+func TestJournalEntryForTransactionDeposit(t *testing.T) {
+	sender := AccountHolder{"0", "0"}
+	receiver := AccountHolder{"accountNumReceiver", ""}
+	amount := money.New(decimal.New(20, 0), money.DEFAULT_CURRENCY)
+	trans := withFee(PAINTrans{ID: 1, PainType: 1000, Sender: sender, Receiver: receiver, Amount: amount})
+
+	entry := journalEntryForTransaction(trans)
+	err := entry.Validate()
+	if err != nil {
+		t.Errorf("JournalEntryForTransaction deposit does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	if entry.Lines[0].LedgerAccount != ledger.CASH {
+		t.Errorf("JournalEntryForTransaction deposit does not pass. Looking for %v, got %v", ledger.CASH, entry.Lines[0].LedgerAccount)
+	}
+}
+
+func TestJournalEntryForTransactionReversal(t *testing.T) {
+	// The original receiver pays back to the original sender
+	sender := AccountHolder{"accountNumReceiver", ""}
+	receiver := AccountHolder{"accountNumSender", ""}
+	amount := money.New(decimal.New(12345, 0), money.DEFAULT_CURRENCY)
+	trans := withFee(PAINTrans{ID: 2, PainType: 7, Sender: sender, Receiver: receiver, Amount: amount, ReversalOf: 1})
+
+	entry := journalEntryForTransaction(trans)
+	err := entry.Validate()
+	if err != nil {
+		t.Errorf("JournalEntryForTransaction reversal does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	if entry.Lines[1].LedgerAccount != ledger.FeeIncomeAccount("transaction") || entry.Lines[1].Direction != ledger.DEBIT {
+		t.Errorf("JournalEntryForTransaction reversal does not pass. Looking for %v, got %v", "debit "+ledger.FeeIncomeAccount("transaction"), entry.Lines[1])
+	}
+
+	// Without a fee refund only the amount moves back
+	trans.Fee = money.Zero(money.DEFAULT_CURRENCY)
+	trans.Fees = nil
+	entry = journalEntryForTransaction(trans)
+	err = entry.Validate()
+	if err != nil {
+		t.Errorf("JournalEntryForTransaction reversal does not pass. Looking for %v, got %v", nil, err)
+	}
+	if len(entry.Lines) != 2 {
+		t.Errorf("JournalEntryForTransaction reversal does not pass. Looking for %v, got %v", 2, len(entry.Lines))
+	}
+}
+
+func TestCheckReversible(t *testing.T) {
+	original := PAINTrans{ID: 1, PainType: 1, Sender: AccountHolder{"accountNumSender", ""}, Receiver: AccountHolder{"accountNumReceiver", ""}, Status: STATUS_SETTLED}
+
+	err := checkReversible(original, false)
+	if err != nil {
+		t.Errorf("CheckReversible does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	// The cardholder cannot take back what a merchant was paid, the acquirer can
+	for _, painType := range []int64{PAIN_CARD_PAYMENT, PAIN_HOLD_CAPTURE} {
+		card := original
+		card.PainType = painType
+		err = checkReversible(card, false)
+		if err == nil || !strings.Contains(err.Error(), "can only be reversed by the acquirer") {
+			t.Errorf("CheckReversible %v does not pass. Looking for %v, got %v", painType, "can only be reversed by the acquirer", err)
+		}
+		err = checkReversible(card, true)
+		if err != nil {
+			t.Errorf("CheckReversible %v acquirer does not pass. Looking for %v, got %v", painType, nil, err)
+		}
+	}
+
+	for _, painType := range []int64{7, 8, 1000, PAIN_FX_CONVERSION} {
+		other := original
+		other.PainType = painType
+		err = checkReversible(other, true)
+		if err == nil || !strings.Contains(err.Error(), "Only payments can be reversed") {
+			t.Errorf("CheckReversible %v does not pass. Looking for %v, got %v", painType, "Only payments can be reversed", err)
+		}
+	}
+
+	pending := original
+	pending.Status = STATUS_ACCEPTED
+	err = checkReversible(pending, false)
+	if err == nil || !strings.Contains(err.Error(), "status is "+STATUS_ACCEPTED) {
+		t.Errorf("CheckReversible pending does not pass. Looking for %v, got %v", "status is "+STATUS_ACCEPTED, err)
+	}
+
+	remote := original
+	remote.Receiver.BankNumber = "bankNumReceiver"
+	err = checkReversible(remote, false)
+	if err == nil || !strings.Contains(err.Error(), "between accounts at this bank") {
+		t.Errorf("CheckReversible remote does not pass. Looking for %v, got %v", "Only payments between accounts at this bank can be reversed", err)
+	}
+}
+
+func TestJournalEntryForTransactionFeeItems(t *testing.T) {
+	sender := AccountHolder{"accountNumSender", ""}
+	receiver := AccountHolder{"accountNumReceiver", ""}
+	amount := money.New(decimal.New(100, 0), money.DEFAULT_CURRENCY)
+	trans := PAINTrans{ID: 1, PainType: 8, Sender: sender, Receiver: receiver, Amount: amount}
+	trans.Fees = []fees.Item{
+		{Code: "collection", Amount: money.New(decimal.New(1, 0), money.DEFAULT_CURRENCY), IncomeAccount: ledger.FeeIncomeAccount("collection")},
+		{Code: "direct-debit", Amount: money.New(decimal.New(50, -2), money.DEFAULT_CURRENCY), IncomeAccount: ledger.FeeIncomeAccount("direct-debit")},
+	}
+	trans.Fee = fees.Total(trans.Fees, money.DEFAULT_CURRENCY)
+
+	entry := journalEntryForTransaction(trans)
+	err := entry.Validate()
+	if err != nil {
+		t.Errorf("JournalEntryForTransaction fee items does not pass. Looking for %v, got %v", nil, err)
+	}
+
+	// Each fee is credited to its own income account
+	if len(entry.Lines) != 4 || entry.Lines[2].LedgerAccount != ledger.FeeIncomeAccount("collection") || entry.Lines[3].LedgerAccount != ledger.FeeIncomeAccount("direct-debit") {
+		t.Errorf("JournalEntryForTransaction fee items does not pass. Looking for %v, got %v", "a credit per fee", entry.Lines)
+	}
+	if entry.Lines[1].Amount.String() != "98.5" {
+		t.Errorf("JournalEntryForTransaction fee items does not pass. Looking for %v, got %v", "98.5", entry.Lines[1].Amount)
+	}
+}
+
+// withFee charges the transaction a 0.01% fee, as the seeded schedules do
+func withFee(trans PAINTrans) PAINTrans {
+	schedule := fees.Schedule{ID: 1, Code: "transaction", Method: fees.METHOD_PERCENTAGE, Rate: decimal.New(1, -2), Currency: money.DEFAULT_CURRENCY, Status: fees.STATUS_ACTIVE}
+	trans.Fees = fees.Breakdown([]fees.Schedule{schedule}, nil, fees.Payer{}, trans.PainType, trans.Amount, "")
+	trans.Fee = fees.Total(trans.Fees, trans.Amount.Currency)
+	return trans
+}
+
+func TestSpendableBalance(t *testing.T) {
+	usd := func(amount int64) money.Money {
+		return money.New(decimal.New(amount, 0), money.DEFAULT_CURRENCY)
+	}
+
+	// Available balance includes the overdraft
+	spendable := spendableBalance(usd(-20), usd(100), usd(80))
+	if spendable.Cmp(usd(80)) != 0 {
+		t.Errorf("SpendableBalance does not pass. Looking for %v, got %v", "80.00", spendable.StringFixed())
+	}
+
+	// Held funds lower the available balance
+	spendable = spendableBalance(usd(50), usd(0), usd(30))
+	if spendable.Cmp(usd(30)) != 0 {
+		t.Errorf("SpendableBalance hold does not pass. Looking for %v, got %v", "30.00", spendable.StringFixed())
+	}
+
+	// Never more than the balance plus the limit
+	spendable = spendableBalance(usd(50), usd(0), usd(150))
+	if spendable.Cmp(usd(50)) != 0 {
+		t.Errorf("SpendableBalance capped does not pass. Looking for %v, got %v", "50.00", spendable.StringFixed())
+	}
+}
@@ -0,0 +1,93 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+// Purposes the vault key is derived for, so the key that encrypts PANs is not the one that
+// hashes them
+const (
+	KEY_ENCRYPTION = "vault encryption"
+	KEY_LOOKUP     = "vault lookup"
+)
+
+// deriveKey derives the 32 byte key for a purpose from Config.VaultKey (hex encoded, 32 bytes)
+func deriveKey(purpose string) (key []byte, err error) {
+	master, err := hex.DecodeString(Config.VaultKey)
+	if err != nil {
+		return nil, errors.New("vault.deriveKey: Vault key not valid hex. " + err.Error())
+	}
+	if len(master) != 32 {
+		return nil, errors.New("vault.deriveKey: Vault key must be 32 bytes")
+	}
+
+	mac := hmac.New(sha256.New, master)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil), nil
+}
+
+// lookupHash is the keyed hash a PAN is found by without decrypting every PAN in the vault
+func lookupHash(pan string) (hash string, err error) {
+	key, err := deriveKey(KEY_LOOKUP)
+	if err != nil {
+		return "", errors.New("vault.lookupHash: " + err.Error())
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(pan))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// encryptPAN encrypts a PAN with AES-256-GCM, returning the nonce followed by the ciphertext
+func encryptPAN(pan string) (encrypted []byte, err error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return nil, errors.New("vault.encryptPAN: " + err.Error())
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, errors.New("vault.encryptPAN: Could not generate nonce. " + err.Error())
+	}
+
+	return gcm.Seal(nonce, nonce, []byte(pan), nil), nil
+}
+
+func decryptPAN(encrypted []byte) (pan string, err error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return "", errors.New("vault.decryptPAN: " + err.Error())
+	}
+	if len(encrypted) < gcm.NonceSize() {
+		return "", errors.New("vault.decryptPAN: Encrypted PAN too short")
+	}
+
+	plain, err := gcm.Open(nil, encrypted[:gcm.NonceSize()], encrypted[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("vault.decryptPAN: Could not decrypt PAN. " + err.Error())
+	}
+
+	return string(plain), nil
+}
+
+func newGCM() (gcm cipher.AEAD, err error) {
+	key, err := deriveKey(KEY_ENCRYPTION)
+	if err != nil {
+		return nil, errors.New("vault.newGCM: " + err.Error())
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.New("vault.newGCM: " + err.Error())
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,81 @@
+package vault
+
+import (
+	"errors"
+	"time"
+)
+
+func saveToken(token string, hash string, encrypted []byte) (err error) {
+	stmtIns, err := Config.Db.Prepare("INSERT INTO vault (`token`, `panHash`, `pan`, `timestamp`) VALUES(?, ?, ?, ?)")
+	if err != nil {
+		return errors.New("vault.saveToken: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	_, err = stmtIns.Exec(token, hash, encrypted, int32(time.Now().Unix()))
+	if err != nil {
+		return errors.New("vault.saveToken: " + err.Error())
+	}
+
+	return
+}
+
+func getToken(hash string) (token string, found bool, err error) {
+	rows, err := Config.Db.Query("SELECT `token` FROM `vault` WHERE `panHash` = ?", hash)
+	if err != nil {
+		return "", false, errors.New("vault.getToken: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&token)
+		if err != nil {
+			return "", false, errors.New("vault.getToken: Could not retrieve token. " + err.Error())
+		}
+		found = true
+	}
+
+	return
+}
+
+func tokenUsed(token string) (used bool, err error) {
+	count := 0
+	err = Config.Db.QueryRow("SELECT COUNT(*) FROM `vault` WHERE `token` = ?", token).Scan(&count)
+	if err != nil {
+		return false, errors.New("vault.tokenUsed: " + err.Error())
+	}
+	return count > 0, nil
+}
+
+func getEncryptedPAN(token string) (encrypted []byte, found bool, err error) {
+	rows, err := Config.Db.Query("SELECT `pan` FROM `vault` WHERE `token` = ?", token)
+	if err != nil {
+		return nil, false, errors.New("vault.getEncryptedPAN: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&encrypted)
+		if err != nil {
+			return nil, false, errors.New("vault.getEncryptedPAN: Could not retrieve PAN. " + err.Error())
+		}
+		found = true
+	}
+
+	return
+}
+
+func logDetokenisation(token string, caller string, allowed bool) (err error) {
+	stmtIns, err := Config.Db.Prepare("INSERT INTO vault_detokenisations (`token`, `caller`, `allowed`, `timestamp`) VALUES(?, ?, ?, ?)")
+	if err != nil {
+		return errors.New("vault.logDetokenisation: " + err.Error())
+	}
+	defer stmtIns.Close()
+
+	_, err = stmtIns.Exec(token, caller, allowed, int32(time.Now().Unix()))
+	if err != nil {
+		return errors.New("vault.logDetokenisation: " + err.Error())
+	}
+
+	return
+}
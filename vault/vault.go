@@ -0,0 +1,206 @@
+// Package vault keeps card numbers (PANs) out of the rest of the bank. It exchanges each PAN for
+// a token of the same length that keeps the PAN's BIN and last four digits, so it can stand in
+// wherever a card number is expected, but fails the Luhn check so it is never mistaken for one.
+// PANs are stored encrypted in the vault's own table and found by a keyed hash. Turning a token
+// back into its PAN is limited to the internal callers that need it, and every attempt is
+// logged with its caller.
+package vault
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+
+	"github.com/bvnk/bank/configuration"
+)
+
+var Config configuration.Configuration
+
+func SetConfig(config *configuration.Configuration) {
+	Config = *config
+}
+
+// Internal callers allowed to detokenise, and why they need the PAN
+const (
+	// Card activation checks the CVV, which is hashed with the PAN
+	CALLER_CARD_ACTIVATION = "cards.activateCard"
+	// The test card terminal sends the PAN in its card messages
+	CALLER_CARD_TERMINAL = "cardTerminal"
+)
+
+var detokenisers = map[string]bool{
+	CALLER_CARD_ACTIVATION: true,
+	CALLER_CARD_TERMINAL:   true,
+}
+
+// Digits of the PAN kept in its token: the BIN at the start and the last four
+const (
+	TOKEN_PREFIX_LENGTH = 6
+	TOKEN_SUFFIX_LENGTH = 4
+)
+
+// Attempts at a token not used before
+const MAX_TOKEN_ATTEMPTS = 10
+
+// Tokenise returns the token of a PAN, vaulting the PAN when it has no token yet
+func Tokenise(pan string) (token string, err error) {
+	if !validPAN(pan) {
+		return "", errors.New("vault.Tokenise: PAN not valid")
+	}
+
+	token, found, err := LookupToken(pan)
+	if err != nil {
+		return "", errors.New("vault.Tokenise: " + err.Error())
+	}
+	if found {
+		return token, nil
+	}
+
+	encrypted, err := encryptPAN(pan)
+	if err != nil {
+		return "", errors.New("vault.Tokenise: " + err.Error())
+	}
+	hash, err := lookupHash(pan)
+	if err != nil {
+		return "", errors.New("vault.Tokenise: " + err.Error())
+	}
+
+	for i := 0; i < MAX_TOKEN_ATTEMPTS; i++ {
+		token, err = newToken(pan)
+		if err != nil {
+			return "", errors.New("vault.Tokenise: " + err.Error())
+		}
+		used, err := tokenUsed(token)
+		if err != nil {
+			return "", errors.New("vault.Tokenise: " + err.Error())
+		}
+		if used {
+			continue
+		}
+
+		err = saveToken(token, hash, encrypted)
+		if err != nil {
+			// The PAN may have been vaulted at the same time, in which case its token stands
+			existing, found, lookupErr := LookupToken(pan)
+			if lookupErr == nil && found {
+				return existing, nil
+			}
+			return "", errors.New("vault.Tokenise: " + err.Error())
+		}
+		return token, nil
+	}
+
+	return "", errors.New("vault.Tokenise: Could not generate an unused token")
+}
+
+// LookupToken finds the token of a PAN already in the vault
+func LookupToken(pan string) (token string, found bool, err error) {
+	hash, err := lookupHash(pan)
+	if err != nil {
+		return "", false, errors.New("vault.LookupToken: " + err.Error())
+	}
+
+	token, found, err = getToken(hash)
+	if err != nil {
+		return "", false, errors.New("vault.LookupToken: " + err.Error())
+	}
+
+	return
+}
+
+// Detokenise returns the PAN of a token to one of the allowed internal callers. Every attempt
+// is logged, and nothing is returned unless the log is written
+func Detokenise(token string, caller string) (pan string, err error) {
+	allowed := detokenisers[caller]
+	err = logDetokenisation(token, caller, allowed)
+	if err != nil {
+		return "", errors.New("vault.Detokenise: " + err.Error())
+	}
+	if !allowed {
+		return "", errors.New("vault.Detokenise: Caller " + caller + " may not detokenise")
+	}
+
+	encrypted, found, err := getEncryptedPAN(token)
+	if err != nil {
+		return "", errors.New("vault.Detokenise: " + err.Error())
+	}
+	if !found {
+		return "", errors.New("vault.Detokenise: Token not found")
+	}
+
+	pan, err = decryptPAN(encrypted)
+	if err != nil {
+		return "", errors.New("vault.Detokenise: " + err.Error())
+	}
+
+	return
+}
+
+// IsToken tells a token from a PAN: tokens are digits that fail the Luhn check
+func IsToken(value string) bool {
+	return isDigits(value) && !luhnValid(value)
+}
+
+// newToken returns a random token for a PAN, keeping its BIN and last four digits and failing
+// the Luhn check
+func newToken(pan string) (token string, err error) {
+	if len(pan) <= TOKEN_PREFIX_LENGTH+TOKEN_SUFFIX_LENGTH {
+		return "", errors.New("vault.newToken: PAN too short to tokenise")
+	}
+
+	prefix := pan[:TOKEN_PREFIX_LENGTH]
+	suffix := pan[len(pan)-TOKEN_SUFFIX_LENGTH:]
+	for {
+		middle, err := randomDigits(len(pan) - TOKEN_PREFIX_LENGTH - TOKEN_SUFFIX_LENGTH)
+		if err != nil {
+			return "", errors.New("vault.newToken: " + err.Error())
+		}
+		token = prefix + middle + suffix
+		if token != pan && !luhnValid(token) {
+			return token, nil
+		}
+	}
+}
+
+func validPAN(pan string) bool {
+	return len(pan) >= 12 && len(pan) <= 19 && isDigits(pan) && luhnValid(pan)
+}
+
+func luhnValid(digits string) bool {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		digit := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 0 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
+
+func randomDigits(n int) (digits string, err error) {
+	b := make([]byte, n)
+	for i := range b {
+		digit, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", errors.New("vault.randomDigits: " + err.Error())
+		}
+		b[i] = byte('0' + digit.Int64())
+	}
+	return string(b), nil
+}
+
+func isDigits(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
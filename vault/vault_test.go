@@ -0,0 +1,103 @@
+package vault
+
+import (
+	"strings"
+	"testing"
+)
+
+const testKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func TestNewToken(t *testing.T) {
+	pan := "4000001234567899"
+	for i := 0; i < 20; i++ {
+		token, err := newToken(pan)
+		if err != nil || len(token) != len(pan) || !strings.HasPrefix(token, "400000") || !strings.HasSuffix(token, "7899") {
+			t.Fatalf("NewToken does not pass. Looking for %v, got %v %v", "400000xxxxxx7899", token, err)
+		}
+		if token == pan || luhnValid(token) || !IsToken(token) {
+			t.Errorf("NewToken Luhn does not pass. Looking for %v, got %v", "token failing the Luhn check", token)
+		}
+	}
+
+	_, err := newToken("4000001234")
+	if err == nil {
+		t.Errorf("NewToken short PAN does not pass. Looking for %v, got %v", "PAN too short to tokenise", nil)
+	}
+}
+
+func TestIsToken(t *testing.T) {
+	tests := map[string]bool{
+		"4000001234567899": false,
+		"4000001234567898": true,
+		"400000123456789a": false,
+		"":                 false,
+	}
+	for value, expected := range tests {
+		if IsToken(value) != expected {
+			t.Errorf("IsToken %v does not pass. Looking for %v, got %v", value, expected, !expected)
+		}
+	}
+}
+
+func TestTokeniseInvalid(t *testing.T) {
+	for _, pan := range []string{"", "4000001234567898", "40000012345678a9", "4111"} {
+		_, err := Tokenise(pan)
+		if err == nil {
+			t.Errorf("Tokenise %v does not pass. Looking for %v, got %v", pan, "PAN not valid", nil)
+		}
+	}
+}
+
+func TestEncryptPAN(t *testing.T) {
+	key := Config.VaultKey
+	defer func() { Config.VaultKey = key }()
+	Config.VaultKey = testKey
+
+	pan := "4000001234567899"
+	encrypted, err := encryptPAN(pan)
+	if err != nil || strings.Contains(string(encrypted), pan) {
+		t.Fatalf("EncryptPAN does not pass. Looking for %v, got %v %v", "ciphertext", encrypted, err)
+	}
+	again, _ := encryptPAN(pan)
+	if string(again) == string(encrypted) {
+		t.Errorf("EncryptPAN nonce does not pass. Looking for %v, got %v", "different ciphertexts", again)
+	}
+
+	decrypted, err := decryptPAN(encrypted)
+	if err != nil || decrypted != pan {
+		t.Errorf("DecryptPAN does not pass. Looking for %v, got %v %v", pan, decrypted, err)
+	}
+
+	encrypted[len(encrypted)-1] ^= 1
+	_, err = decryptPAN(encrypted)
+	if err == nil {
+		t.Errorf("DecryptPAN tampered does not pass. Looking for %v, got %v", "Could not decrypt PAN", nil)
+	}
+}
+
+func TestLookupHash(t *testing.T) {
+	key := Config.VaultKey
+	defer func() { Config.VaultKey = key }()
+	Config.VaultKey = testKey
+
+	hash, err := lookupHash("4000001234567899")
+	again, _ := lookupHash("4000001234567899")
+	other, _ := lookupHash("4000001234567881")
+	if err != nil || len(hash) != 64 || hash != again || hash == other {
+		t.Errorf("LookupHash does not pass. Looking for %v, got %v %v %v %v", "stable hash per PAN", hash, again, other, err)
+	}
+
+	Config.VaultKey = strings.Replace(testKey, "00", "ff", 1)
+	rekeyed, _ := lookupHash("4000001234567899")
+	if rekeyed == hash {
+		t.Errorf("LookupHash key does not pass. Looking for %v, got %v", "hash depending on key", rekeyed)
+	}
+
+	for _, invalid := range []string{"", "not hex", "0001"} {
+		Config.VaultKey = invalid
+		_, err = lookupHash("4000001234567899")
+		if err == nil {
+			t.Errorf("LookupHash key %v does not pass. Looking for %v, got %v", invalid, "Vault key not valid", nil)
+		}
+	}
+}